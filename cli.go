@@ -0,0 +1,233 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"AIGenerator/internal/config"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/texts"
+
+	"github.com/joho/godotenv"
+)
+
+// maxCLIGenerations сколько генераций можно начислить одной командой, как в /addgenerations
+const maxCLIGenerations = 1000
+
+// command подкоманда: aigenerator <имя> [аргументы]
+type command struct {
+	usage       string
+	description string
+	run         func(args []string, out io.Writer) error
+}
+
+// commands подкоманды. Все читают те же настройки окружения, что и бот.
+var commands = map[string]command{
+	"run": {
+		usage:       "run",
+		description: "запустить бота (по умолчанию)",
+		run: func(args []string, out io.Writer) error {
+			runBot()
+			return nil
+		},
+	},
+	"adduser": {
+		usage:       "adduser <chatID> <генераций>",
+		description: "начислить генерации пользователю (бот должен быть остановлен)",
+		run:         runAddUser,
+	},
+	"stats": {
+		usage:       "stats [--period 30d]",
+		description: "вывести статистику за период",
+		run:         runStats,
+	},
+	"checkconfig": {
+		usage:       "checkconfig",
+		description: "проверить настройки окружения, не запуская бота",
+		run:         runCheckConfig,
+	},
+}
+
+// runCommand выполняет подкоманду из args и возвращает код завершения процесса
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	name := "run"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" || name == "-h" || name == "--help" {
+		printUsage(stdout)
+		return 0
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "❌ Неизвестная команда: %s\n\n", name)
+		printUsage(stderr)
+		return 2
+	}
+	if err := cmd.run(args, stdout); err != nil {
+		fmt.Fprintf(stderr, "❌ %s: %v\n", name, err)
+		return 1
+	}
+	return 0
+}
+
+// printUsage выводит список подкоманд
+func printUsage(out io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(out, "Использование: aigenerator <команда> [аргументы]")
+	fmt.Fprintln(out, "\nКоманды:")
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-30s %s\n", commands[name].usage, commands[name].description)
+	}
+}
+
+// loadConfig читает .env, если он есть, и настройки окружения
+func loadConfig() (config.Config, error) {
+	godotenv.Load()
+	return config.Load()
+}
+
+// openDatabase загружает базу по настройкам окружения
+func openDatabase() (*database.Database, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	db := database.NewDatabase(cfg.Database)
+	if err := db.Load(); err != nil {
+		return nil, fmt.Errorf("ошибка загрузки базы: %w", err)
+	}
+	return db, nil
+}
+
+// runAddUser начисляет генерации пользователю, создавая его при необходимости
+func runAddUser(args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("использование: aigenerator adduser <chatID> <генераций>")
+	}
+	chatID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("неверный chatID %q: должен быть числом", args[0])
+	}
+	count, err := strconv.Atoi(args[1])
+	if err != nil || count <= 0 || count > maxCLIGenerations {
+		return fmt.Errorf("неверное количество генераций %q: от 1 до %d", args[1], maxCLIGenerations)
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	adjustment, err := db.AdjustGenerations(chatID, count, 0, "aigenerator adduser", true)
+	if err != nil {
+		return fmt.Errorf("ошибка добавления генераций: %w", err)
+	}
+
+	fmt.Fprintf(out, "✅ Пользователю %d добавлено %d генераций, доступно %d\n", chatID, count, adjustment.Balance)
+	return nil
+}
+
+// runStats выводит статистику за период
+func runStats(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	flags.SetOutput(out)
+	periodFlag := flags.String("period", "30d", "период: 24h, 7d, 30d")
+	topics := flags.Int("topics", 5, "сколько популярных тем показать")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	period, err := parsePeriod(*periodFlag)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	report := db.GetPeriodReport(now.Add(-period), now, *topics)
+	printStats(out, *periodFlag, report)
+	return nil
+}
+
+// parsePeriod разбирает период: дни с суффиксом d или длительность Go (12h, 90m)
+func parsePeriod(value string) (time.Duration, error) {
+	var period time.Duration
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("неверный период %q", value)
+		}
+		period = time.Duration(n) * 24 * time.Hour
+	} else {
+		var err error
+		if period, err = time.ParseDuration(value); err != nil {
+			return 0, fmt.Errorf("неверный период %q: ожидается 30d или 12h", value)
+		}
+	}
+	if period <= 0 {
+		return 0, fmt.Errorf("период должен быть положительным: %s", value)
+	}
+	return period, nil
+}
+
+// printStats выводит сводку за период
+func printStats(out io.Writer, period string, report database.PeriodReport) {
+	fmt.Fprintf(out, "📊 Статистика за %s (%s — %s)\n\n", period,
+		report.From.Format("02.01.2006 15:04"), report.To.Format("02.01.2006 15:04"))
+	fmt.Fprintf(out, "👥 Пользователей: %d, новых %d\n", report.TotalUsers, report.NewUsers)
+	fmt.Fprintf(out, "🔄 Генераций: %d, отклонено тем %d\n", report.Generations, report.Rejected)
+	fmt.Fprintf(out, "💰 Покупки: 10(%d) 25(%d) 100(%d)\n",
+		report.Purchases["10"], report.Purchases["25"], report.Purchases["100"])
+	fmt.Fprintf(out, "💵 Выручка: %d руб.\n", report.TotalRevenue)
+	if report.Ratings > 0 {
+		fmt.Fprintf(out, "⭐ Средняя оценка: %.1f (%d оценок)\n", report.AverageRating, report.Ratings)
+	}
+	if len(report.TopTopics) > 0 {
+		fmt.Fprintln(out, "\n🎯 Популярные темы:")
+		for i, topic := range report.TopTopics {
+			fmt.Fprintf(out, "%d. %s - %d раз\n", i+1, topic.Topic, topic.Count)
+		}
+	}
+}
+
+// runCheckConfig проверяет настройки окружения и выводит основные из них без секретов
+func runCheckConfig(args []string, out io.Writer) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := texts.Configure(cfg.Texts); err != nil {
+		return fmt.Errorf("шаблоны текстов: %w", err)
+	}
+
+	fmt.Fprintln(out, "✅ Конфигурация корректна")
+	fmt.Fprintf(out, "📁 Каталог данных: %s\n", cfg.DataDir)
+	fmt.Fprintf(out, "🤖 AI: %s\n", cfg.AI.Provider)
+	fmt.Fprintf(out, "💳 ЮKassa: %s\n", enabledText(cfg.PaymentEnabled()))
+	fmt.Fprintf(out, "👤 ADMIN_CHAT_ID: %s\n", enabledText(cfg.Bot.AdminChatID != 0))
+	fmt.Fprintf(out, "🩺 Проверки состояния: %s\n", enabledText(cfg.Health.Port > 0))
+	fmt.Fprintf(out, "🚨 Отправка ошибок: %s\n", enabledText(cfg.Reporting.DSN != ""))
+	fmt.Fprintf(out, "🧪 Самопроверка при запуске: %s\n", cfg.SelfTest.Mode)
+	return nil
+}
+
+func enabledText(enabled bool) string {
+	if enabled {
+		return "включено"
+	}
+	return "выключено"
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/database"
+)
+
+// useEnv готовит окружение подкоманд: минимальные настройки и пустой каталог данных.
+// Файлы базы пишутся относительно рабочего каталога.
+func useEnv(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	t.Setenv("TELEGRAM_BOT_TOKEN", "123:token")
+	t.Setenv("YANDEX_GPT_API_KEY", "yandex-key")
+	t.Setenv("YANDEX_FOLDER_ID", "b1gtest")
+}
+
+// run выполняет подкоманду и возвращает код завершения, stdout и stderr
+func run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := runCommand(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestRunCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantStdout string
+		wantStderr string
+	}{
+		{"справка", []string{"help"}, 0, "adduser <chatID> <генераций>", ""},
+		{"справка флагом", []string{"--help"}, 0, "checkconfig", ""},
+		{"неизвестная команда", []string{"deploy"}, 2, "", "❌ Неизвестная команда: deploy"},
+		{"adduser без аргументов", []string{"adduser"}, 1, "", "использование: aigenerator adduser"},
+		{"adduser с неверным chatID", []string{"adduser", "abc", "5"}, 1, "", `неверный chatID "abc"`},
+		{"adduser сверх предела", []string{"adduser", "42", "1001"}, 1, "", "от 1 до 1000"},
+		{"adduser с нулем", []string{"adduser", "42", "0"}, 1, "", "от 1 до 1000"},
+		{"adduser", []string{"adduser", "42", "5"}, 0, "✅ Пользователю 42 добавлено 5 генераций, доступно 5", ""},
+		{"stats", []string{"stats"}, 0, "📊 Статистика за 30d", ""},
+		{"stats за неделю", []string{"stats", "--period", "7d"}, 0, "📊 Статистика за 7d", ""},
+		{"stats с неверным периодом", []string{"stats", "--period", "месяц"}, 1, "", `неверный период "месяц"`},
+		{"stats с неизвестным флагом", []string{"stats", "--users"}, 1, "", "stats:"},
+		{"checkconfig", []string{"checkconfig"}, 0, "✅ Конфигурация корректна", ""},
+		// Переноса в SQLite нет: хранилище бота — JSON-файлы
+		{"migrate", []string{"migrate"}, 2, "", "❌ Неизвестная команда: migrate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useEnv(t)
+			code, stdout, stderr := run(tt.args...)
+			if code != tt.wantCode {
+				t.Errorf("код %d, ожидался %d; stderr %q", code, tt.wantCode, stderr)
+			}
+			if !strings.Contains(stdout, tt.wantStdout) {
+				t.Errorf("stdout %q, ожидалось %q", stdout, tt.wantStdout)
+			}
+			if !strings.Contains(stderr, tt.wantStderr) {
+				t.Errorf("stderr %q, ожидалось %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestCommandsRequireConfig(t *testing.T) {
+	useEnv(t)
+	t.Setenv("TELEGRAM_BOT_TOKEN", "")
+
+	for _, args := range [][]string{{"checkconfig"}, {"stats"}, {"adduser", "42", "5"}} {
+		if code, _, stderr := run(args...); code != 1 || !strings.Contains(stderr, "TELEGRAM_BOT_TOKEN") {
+			t.Errorf("%v: код %d, stderr %q", args, code, stderr)
+		}
+	}
+}
+
+func TestAddUserWritesDatabase(t *testing.T) {
+	useEnv(t)
+
+	if code, _, stderr := run("adduser", "42", "5"); code != 0 {
+		t.Fatalf("adduser: %s", stderr)
+	}
+	// Повторное начисление добавляется к балансу
+	if code, stdout, _ := run("adduser", "42", "3"); code != 0 || !strings.Contains(stdout, "доступно 8") {
+		t.Fatalf("повторный adduser: %q", stdout)
+	}
+
+	db := database.NewDatabase(database.Config{File: "users.json"})
+	if err := db.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if user := db.GetUser(42); user == nil || user.AvailableGenerations != 8 {
+		t.Fatalf("пользователь после adduser: %+v", user)
+	}
+	// Начисление из командной строки попадает в журнал без администратора
+	adjustments := db.BalanceAdjustments(time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if len(adjustments) != 2 || adjustments[0].AdminChatID != 0 || !adjustments[0].Created ||
+		adjustments[1].Reason != "aigenerator adduser" {
+		t.Errorf("журнал начислений: %+v", adjustments)
+	}
+
+	if code, stdout, _ := run("stats", "--period", "24h"); code != 0 || !strings.Contains(stdout, "👥 Пользователей: 1, новых 1") {
+		t.Errorf("stats после adduser: %q", stdout)
+	}
+}
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"30d", 30 * 24 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"12h", 12 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"0d", 0, true},
+		{"-1h", 0, true},
+		{"d", 0, true},
+		{"неделя", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePeriod(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parsePeriod(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
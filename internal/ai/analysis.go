@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	// maxAnalysisMessages сколько последних сообщений канала передается модели
+	maxAnalysisMessages = 30
+	// maxAnalysisMessageLength максимальная длина одного сообщения в символах
+	maxAnalysisMessageLength = 500
+)
+
+// channelAnalysisPromptData данные для шаблона анализа канала
+type channelAnalysisPromptData struct {
+	Title       string
+	Description string
+	Messages    []string
+}
+
+// AnalyzeChannel анализирует тематику и стиль канала и возвращает JSON по схеме GPTAnalysis
+// (main_topic, subtopics, content_style, target_audience, keywords, content_angle).
+// Если модель вернула не JSON, запрос повторяется один раз с требованием вернуть только JSON.
+func (w postWriter) AnalyzeChannel(ctx context.Context, title, description string, messages []string) (string, error) {
+	log.Printf("[AI] Анализ канала: %s (%d сообщений)", title, len(messages))
+	ctx = withRequestType(ctx, RequestAnalysis)
+
+	if len(messages) > maxAnalysisMessages {
+		messages = messages[len(messages)-maxAnalysisMessages:]
+	}
+	prepared := make([]string, 0, len(messages))
+	for _, message := range messages {
+		message = strings.Join(strings.Fields(message), " ")
+		if message == "" {
+			continue
+		}
+		runes := []rune(message)
+		if len(runes) > maxAnalysisMessageLength {
+			message = string(runes[:maxAnalysisMessageLength]) + "…"
+		}
+		prepared = append(prepared, message)
+	}
+
+	chat, err := promptMessages(promptChannelAnalysisSystem, promptChannelAnalysisUser, channelAnalysisPromptData{
+		Title:       strings.TrimSpace(title),
+		Description: strings.TrimSpace(description),
+		Messages:    prepared,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	params := w.params(ctx)
+	response, err := w.completer.CompleteMessages(ctx, chat, params.Temperature, params.MaxTokens)
+	if err != nil {
+		return "", err
+	}
+
+	result, parseErr := extractJSONObject(response)
+	if parseErr == nil {
+		return result, nil
+	}
+
+	log.Printf("[AI] ⚠️ Анализ канала вернул не JSON (%v), повторяем запрос", parseErr)
+	chat = append(chat,
+		Message{Role: "assistant", Content: response},
+		Message{Role: "user", Content: "Верни только JSON-объект по схеме, без пояснений и без markdown."},
+	)
+	response, err = w.completer.CompleteMessages(ctx, chat, 0.1, params.MaxTokens)
+	if err != nil {
+		return "", err
+	}
+
+	result, parseErr = extractJSONObject(response)
+	if parseErr != nil {
+		log.Printf("[AI] ❌ Анализ канала: ответ не является JSON: %s", response)
+		return "", fmt.Errorf("модель вернула некорректный JSON: %w", parseErr)
+	}
+	return result, nil
+}
+
+// extractJSONObject убирает markdown-обрамление и текст вокруг JSON-объекта
+// и проверяет, что результат является корректным JSON-объектом
+func extractJSONObject(response string) (string, error) {
+	text := strings.TrimSpace(response)
+
+	// ```json ... ``` или ``` ... ```
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```")
+		if newline := strings.Index(text, "\n"); newline >= 0 {
+			text = text[newline+1:]
+		}
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("JSON-объект не найден")
+	}
+	text = text[start : end+1]
+
+	var object map[string]any
+	if err := json.Unmarshal([]byte(text), &object); err != nil {
+		return "", err
+	}
+	return text, nil
+}
@@ -0,0 +1,137 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scriptedCompleter отвечает заранее заданными ответами по очереди и запоминает запросы
+type scriptedCompleter struct {
+	mu        sync.Mutex
+	responses []string
+	err       error
+	requests  [][]Message
+}
+
+func (c *scriptedCompleter) CompleteMessages(ctx context.Context, messages []Message, temperature float64, maxTokens int) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests = append(c.requests, messages)
+	if c.err != nil {
+		return "", c.err
+	}
+	if len(c.responses) == 0 {
+		return "", errors.New("ответы закончились")
+	}
+	response := c.responses[0]
+	c.responses = c.responses[1:]
+	return response, nil
+}
+
+func (c *scriptedCompleter) calls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.requests)
+}
+
+// newScriptedWriter создает postWriter, которому модель отвечает responses по очереди
+func newScriptedWriter(responses ...string) (postWriter, *scriptedCompleter) {
+	completer := &scriptedCompleter{responses: responses}
+	return postWriter{completer: completer, config: DefaultAIConfig()}, completer
+}
+
+const analysisJSON = `{"main_topic": "технологии", "subtopics": ["ИИ"], "content_style": {"formality": 3}, "target_audience": "разработчики", "keywords": ["нейросети"], "content_angle": "практика"}`
+
+func TestAnalyzeChannelResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses []string
+		wantCalls int
+		wantErr   bool
+	}{
+		{"чистый JSON", []string{analysisJSON}, 1, false},
+		{"код-блок json", []string{"```json\n" + analysisJSON + "\n```"}, 1, false},
+		{"код-блок без языка", []string{"```\n" + analysisJSON + "\n```"}, 1, false},
+		{"пояснение вокруг JSON", []string{"Вот анализ канала:\n" + analysisJSON + "\nНадеюсь, это поможет!"}, 1, false},
+		{"исправлен после повтора", []string{"Канал посвящен технологиям и ИИ.", analysisJSON}, 2, false},
+		{"оборванный JSON исправлен", []string{`{"main_topic": "технологии", "subtopics": [`, analysisJSON}, 2, false},
+		{"висящая запятая дважды", []string{`{"main_topic": "технологии",}`, `{"main_topic": "технологии",}`}, 2, true},
+		{"массив вместо объекта", []string{`["технологии"]`, `нет`}, 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer, completer := newScriptedWriter(tt.responses...)
+			result, err := writer.AnalyzeChannel(context.Background(), "Технологии", "Канал про ИИ", []string{"Пост"})
+			if completer.calls() != tt.wantCalls {
+				t.Errorf("запросов = %d, ожидалось %d", completer.calls(), tt.wantCalls)
+			}
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ожидалась ошибка, получено %q", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AnalyzeChannel: %v", err)
+			}
+			if result != analysisJSON {
+				t.Errorf("результат = %q", result)
+			}
+		})
+	}
+}
+
+func TestAnalyzeChannelRepairRequest(t *testing.T) {
+	writer, completer := newScriptedWriter("Не JSON", analysisJSON)
+	if _, err := writer.AnalyzeChannel(context.Background(), "Канал", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Повтор содержит прежний ответ модели и требование вернуть только JSON
+	repair := completer.requests[1]
+	if len(repair) != 4 || repair[2].Role != "assistant" || repair[2].Content != "Не JSON" {
+		t.Fatalf("повторный запрос = %+v", repair)
+	}
+	if !strings.Contains(repair[3].Content, "только JSON") {
+		t.Errorf("требование повтора = %q", repair[3].Content)
+	}
+}
+
+func TestAnalyzeChannelPreparesMessages(t *testing.T) {
+	messages := make([]string, maxAnalysisMessages+5)
+	for i := range messages {
+		messages[i] = "сообщение"
+	}
+	messages[0] = "самое старое"
+	messages[len(messages)-1] = "  последнее\n\nс   пробелами  "
+	messages[len(messages)-2] = strings.Repeat("я", maxAnalysisMessageLength+100)
+	messages[len(messages)-3] = "   "
+
+	writer, completer := newScriptedWriter(analysisJSON)
+	if _, err := writer.AnalyzeChannel(context.Background(), "Канал", "", messages); err != nil {
+		t.Fatal(err)
+	}
+
+	user := completer.requests[0][1].Content
+	if strings.Contains(user, "самое старое") {
+		t.Error("в промпт попали сообщения старше последних maxAnalysisMessages")
+	}
+	if !strings.Contains(user, "последнее с пробелами") {
+		t.Error("пробелы в сообщении не схлопнуты")
+	}
+	if !strings.Contains(user, strings.Repeat("я", maxAnalysisMessageLength)+"…") || strings.Contains(user, strings.Repeat("я", maxAnalysisMessageLength+1)) {
+		t.Error("длинное сообщение не обрезано")
+	}
+}
+
+func TestAnalyzeChannelModelError(t *testing.T) {
+	writer, completer := newScriptedWriter()
+	completer.err = errors.New("сервис недоступен")
+	if _, err := writer.AnalyzeChannel(context.Background(), "Канал", "", nil); err == nil {
+		t.Error("ошибка модели не возвращена")
+	}
+}
@@ -0,0 +1,64 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// SourceArticle статья, по которой написан пост: на вопросы о ней отвечает AnswerQuestion
+type SourceArticle struct {
+	Title       string
+	URL         string
+	Source      string
+	Content     string
+	PublishedAt time.Time
+}
+
+// answerPromptData данные для шаблона ответа на вопрос о статье
+type answerPromptData struct {
+	Title     string
+	URL       string
+	Source    string
+	Published string
+	Content   string
+	Question  string
+}
+
+// AnswerQuestion отвечает на вопрос пользователя строго по тексту статьи.
+// Если ответа в статье нет, модель должна сказать об этом, а не придумывать.
+func (w postWriter) AnswerQuestion(ctx context.Context, question string, article SourceArticle) (string, error) {
+	log.Printf("[AI] Вопрос о статье: %s", article.Title)
+	ctx = withRequestType(ctx, RequestAnswer)
+
+	var published string
+	if !article.PublishedAt.IsZero() {
+		published = article.PublishedAt.Format("02.01.2006 15:04 MST")
+	}
+	messages, err := w.fitPrompt(ctx, strings.TrimSpace(article.Content), question, func(content string) ([]Message, error) {
+		return promptMessages(promptAnswerSystem, promptAnswerUser, answerPromptData{
+			Title:     strings.TrimSpace(article.Title),
+			URL:       article.URL,
+			Source:    article.Source,
+			Published: published,
+			Content:   content,
+			Question:  strings.TrimSpace(question),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	params := w.params(ctx)
+	answer, err := w.completer.CompleteMessages(ctx, messages, params.Temperature, params.MaxTokens)
+	if err != nil {
+		return "", err
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return "", errors.New("модель вернула пустой ответ")
+	}
+	return answer, nil
+}
@@ -0,0 +1,477 @@
+package ai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// auditFilePrefix имя файлов журнала: ai_audit-2006-01-02-01.jsonl
+	auditFilePrefix = "ai_audit-"
+	// defaultAuditMaxSizeMB размер одного файла журнала, после которого начинается следующий
+	defaultAuditMaxSizeMB = 10
+	// defaultAuditKeepDays сколько дней хранятся файлы журнала
+	defaultAuditKeepDays = 14
+	// auditArticleLength до скольких символов сокращается ссылка на статью
+	auditArticleLength = 200
+)
+
+// Типы запросов к модели в журнале
+const (
+	RequestComplete     = "complete"
+	RequestPost         = "post"
+	RequestURLPost      = "url_post"
+	RequestRewrite      = "rewrite"
+	RequestExpand       = "expand"
+	RequestHeadlines    = "headlines"
+	RequestAnswer       = "answer"
+	RequestTranslate    = "translate"
+	RequestRerank       = "rerank"
+	RequestTopicCheck   = "topic_check"
+	RequestSafetyCheck  = "safety_check"
+	RequestRefusalCheck = "refusal_check"
+	RequestAnalysis     = "analysis"
+	RequestPing         = "ping"
+)
+
+// AuditEntry запись журнала запросов к модели
+type AuditEntry struct {
+	Time       time.Time `json:"time"`
+	UserID     int64     `json:"user_id,omitempty"`
+	Type       string    `json:"type"`
+	Model      string    `json:"model"`
+	PromptHash string    `json:"prompt_hash"`
+	Article    string    `json:"article,omitempty"`
+	Response   string    `json:"response,omitempty"`
+	Usage      Usage     `json:"usage"`
+	LatencyMs  int64     `json:"latency_ms"`
+	Outcome    string    `json:"outcome"`
+}
+
+// auditLogger журнал запросов к модели: JSONL в DATA_DIR, новый файл каждый день
+// и при превышении размера, старые файлы удаляются
+type auditLogger struct {
+	mu        sync.Mutex
+	dir       string
+	maxSize   int64
+	keepDays  int
+	responses bool
+	secrets   []string
+	now       func() time.Time
+
+	file *os.File
+	day  string
+	part int
+	size int64
+}
+
+// audit общий журнал запросов; nil, если журнал выключен. Настраивается Configure:
+// до этого журнал не ведется.
+var audit *auditLogger
+
+func newAuditLogger(dir string, maxSize int64, keepDays int, responses bool, secrets []string, now func() time.Time) *auditLogger {
+	return &auditLogger{
+		dir:       dir,
+		maxSize:   maxSize,
+		keepDays:  keepDays,
+		responses: responses,
+		secrets:   secrets,
+		now:       now,
+	}
+}
+
+type auditUserKey struct{}
+type auditArticleKey struct{}
+type requestTypeKey struct{}
+
+// WithAuditUser указывает пользователя, для которого выполняются запросы с этим контекстом
+func WithAuditUser(ctx context.Context, userID int64) context.Context {
+	return context.WithValue(ctx, auditUserKey{}, userID)
+}
+
+// WithAuditArticle указывает статью (обычно ссылку), по которой генерируется пост
+func WithAuditArticle(ctx context.Context, article string) context.Context {
+	return context.WithValue(ctx, auditArticleKey{}, article)
+}
+
+// withRequestType задает тип запросов для журнала
+func withRequestType(ctx context.Context, requestType string) context.Context {
+	return context.WithValue(ctx, requestTypeKey{}, requestType)
+}
+
+// recordAudit записывает запрос к модели в журнал
+func recordAudit(ctx context.Context, model string, messages []Message, response string, usage Usage, started time.Time, err error) {
+	if audit == nil {
+		return
+	}
+
+	entry := AuditEntry{
+		Time:       started,
+		Type:       RequestComplete,
+		Model:      model,
+		PromptHash: promptHash(messages),
+		Response:   response,
+		Usage:      usage,
+		LatencyMs:  time.Since(started).Milliseconds(),
+		Outcome:    "ok",
+	}
+	if userID, ok := ctx.Value(auditUserKey{}).(int64); ok {
+		entry.UserID = userID
+	}
+	if article, ok := ctx.Value(auditArticleKey{}).(string); ok {
+		entry.Article = truncateRunes(article, auditArticleLength)
+	}
+	if requestType, ok := ctx.Value(requestTypeKey{}).(string); ok {
+		entry.Type = requestType
+	}
+	switch {
+	case errors.Is(err, ErrCircuitOpen):
+		entry.Outcome = "circuit_open"
+	case err != nil && ctx.Err() != nil:
+		entry.Outcome = "canceled"
+	case err != nil:
+		entry.Outcome = "error: " + err.Error()
+	}
+
+	if err := audit.write(entry); err != nil {
+		log.Printf("[AUDIT] ❌ Ошибка записи журнала запросов: %v", err)
+	}
+}
+
+// promptHash короткий хеш сообщений запроса: одинаковые промпты дают одинаковый хеш
+func promptHash(messages []Message) string {
+	hash := sha256.New()
+	for _, message := range messages {
+		hash.Write([]byte(message.Role))
+		hash.Write([]byte{0})
+		hash.Write([]byte(message.Content))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
+
+// truncateRunes обрезает строку до maxRunes символов
+func truncateRunes(text string, maxRunes int) string {
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	return string([]rune(text)[:maxRunes]) + "…"
+}
+
+// redact убирает из текста ключи API
+func (a *auditLogger) redact(text string) string {
+	for _, secret := range a.secrets {
+		text = strings.ReplaceAll(text, secret, "[REDACTED]")
+	}
+	return text
+}
+
+// write дописывает запись в текущий файл журнала
+func (a *auditLogger) write(entry AuditEntry) error {
+	if !a.responses {
+		entry.Response = ""
+	}
+	entry.Response = a.redact(entry.Response)
+	entry.Outcome = a.redact(entry.Outcome)
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга записи: %w", err)
+	}
+	line = append(line, '\n')
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := a.rotate(int64(len(line))); err != nil {
+		return err
+	}
+
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("ошибка записи журнала: %w", err)
+	}
+	return nil
+}
+
+// rotate открывает новый файл, если наступил новый день или запись не помещается в текущий.
+// Вызывается под mu.
+func (a *auditLogger) rotate(next int64) error {
+	day := a.now().Format("2006-01-02")
+	if a.file != nil && a.day == day && (a.size == 0 || a.size+next <= a.maxSize) {
+		return nil
+	}
+
+	if a.file != nil {
+		a.file.Close()
+		a.file = nil
+	}
+
+	if a.day != day {
+		// После перезапуска продолжаем последний файл дня
+		a.day, a.part = day, max(a.lastPart(day), 1)
+		a.removeOld()
+	} else {
+		a.part++
+	}
+
+	for {
+		path := a.path(a.day, a.part)
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return fmt.Errorf("ошибка открытия журнала %s: %w", path, err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("ошибка чтения размера журнала %s: %w", path, err)
+		}
+		if info.Size() > 0 && info.Size()+next > a.maxSize {
+			file.Close()
+			a.part++
+			continue
+		}
+
+		a.file, a.size = file, info.Size()
+		return nil
+	}
+}
+
+// path возвращает путь к файлу журнала за день
+func (a *auditLogger) path(day string, part int) string {
+	return filepath.Join(a.dir, fmt.Sprintf("%s%s-%02d.jsonl", auditFilePrefix, day, part))
+}
+
+// files возвращает файлы журнала от старых к новым
+func (a *auditLogger) files() []string {
+	files, _ := filepath.Glob(filepath.Join(a.dir, auditFilePrefix+"*.jsonl"))
+	slices.Sort(files)
+	return files
+}
+
+// lastPart номер последнего файла журнала за день или 0, если файлов нет
+func (a *auditLogger) lastPart(day string) int {
+	last := 0
+	for _, path := range a.files() {
+		name := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+		part, ok := strings.CutPrefix(name, auditFilePrefix+day+"-")
+		if !ok {
+			continue
+		}
+		if number, err := strconv.Atoi(part); err == nil {
+			last = max(last, number)
+		}
+	}
+	return last
+}
+
+// removeOld удаляет файлы журнала старше keepDays дней
+func (a *auditLogger) removeOld() {
+	cutoff := auditFilePrefix + a.now().AddDate(0, 0, -a.keepDays).Format("2006-01-02")
+	for _, path := range a.files() {
+		if filepath.Base(path) >= cutoff {
+			break
+		}
+		if err := os.Remove(path); err != nil {
+			log.Printf("[AUDIT] ⚠️ Не удалось удалить старый журнал %s: %v", path, err)
+			continue
+		}
+		log.Printf("[AUDIT] 🗑 Удален старый журнал %s", filepath.Base(path))
+	}
+}
+
+// RecentAuditEntries возвращает последние limit записей журнала для пользователя, новые первыми
+func RecentAuditEntries(userID int64, limit int) ([]AuditEntry, error) {
+	if audit == nil {
+		return nil, fmt.Errorf("журнал запросов выключен (AI_AUDIT_LOG=false)")
+	}
+	return audit.recent(userID, limit)
+}
+
+func (a *auditLogger) recent(userID int64, limit int) ([]AuditEntry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	files := a.files()
+	var entries []AuditEntry
+	for i := len(files) - 1; i >= 0 && len(entries) < limit; i-- {
+		fileEntries, err := readAuditFile(files[i], func(entry AuditEntry) bool { return entry.UserID == userID })
+		if err != nil {
+			return nil, err
+		}
+		// В файле записи идут от старых к новым
+		for j := len(fileEntries) - 1; j >= 0 && len(entries) < limit; j-- {
+			entries = append(entries, fileEntries[j])
+		}
+	}
+	return entries, nil
+}
+
+// AnonymizeAuditUser обезличивает записи журнала пользователя, удалившего свои данные:
+// убирает пользователя, статью и ответ модели, оставляя токены и стоимость для отчетов.
+// Возвращает число измененных записей.
+func AnonymizeAuditUser(userID int64) (int, error) {
+	if audit == nil {
+		return 0, nil
+	}
+	return audit.anonymize(userID)
+}
+
+func (a *auditLogger) anonymize(userID int64) (int, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	changed := 0
+	for _, path := range a.files() {
+		n, err := anonymizeAuditFile(path, userID)
+		changed += n
+		if err != nil {
+			return changed, err
+		}
+	}
+	if changed == 0 || a.file == nil {
+		return changed, nil
+	}
+
+	// Текущий файл подменен: дописывание продолжается в новый файл по тому же пути
+	path := a.file.Name()
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return changed, fmt.Errorf("ошибка открытия журнала %s: %w", path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return changed, fmt.Errorf("ошибка чтения размера журнала %s: %w", path, err)
+	}
+	a.file.Close()
+	a.file, a.size = file, info.Size()
+	return changed, nil
+}
+
+// anonymizeAuditFile обезличивает записи пользователя в файле журнала и возвращает их число
+func anonymizeAuditFile(path string, userID int64) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка чтения журнала %s: %w", path, err)
+	}
+
+	var rewritten []byte
+	changed := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		var entry AuditEntry
+		if json.Unmarshal(line, &entry) != nil || entry.UserID != userID {
+			rewritten = append(rewritten, line...)
+			continue
+		}
+		entry.UserID, entry.Article, entry.Response = 0, "", ""
+		encoded, err := json.Marshal(entry)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка маршалинга записи: %w", err)
+		}
+		rewritten = append(append(rewritten, encoded...), '\n')
+		changed++
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+
+	temp := path + ".tmp"
+	if err := os.WriteFile(temp, rewritten, 0o600); err != nil {
+		return 0, fmt.Errorf("ошибка записи журнала %s: %w", temp, err)
+	}
+	if err := os.Rename(temp, path); err != nil {
+		return 0, fmt.Errorf("ошибка замены журнала %s: %w", path, err)
+	}
+	return changed, nil
+}
+
+// AuditSummary итог журнала запросов за период
+type AuditSummary struct {
+	Requests int
+	// Failed запросы, завершившиеся ошибкой модели; отмененные не считаются
+	Failed int
+	Usage  Usage
+	// Cost стоимость токенов в рублях по ценам моделей
+	Cost float64
+}
+
+// SummarizeAudit подводит итог журнала запросов за период [from, to)
+func SummarizeAudit(from, to time.Time) (AuditSummary, error) {
+	if audit == nil {
+		return AuditSummary{}, fmt.Errorf("журнал запросов выключен (AI_AUDIT_LOG=false)")
+	}
+	return audit.summarize(from, to)
+}
+
+func (a *auditLogger) summarize(from, to time.Time) (AuditSummary, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Файлы называются по дню записи, более ранние дни можно не читать
+	first := auditFilePrefix + from.Format("2006-01-02")
+	inPeriod := func(entry AuditEntry) bool { return !entry.Time.Before(from) && entry.Time.Before(to) }
+
+	var summary AuditSummary
+	for _, path := range a.files() {
+		if filepath.Base(path) < first {
+			continue
+		}
+		entries, err := readAuditFile(path, inPeriod)
+		if err != nil {
+			return AuditSummary{}, err
+		}
+		for _, entry := range entries {
+			summary.Requests++
+			if strings.HasPrefix(entry.Outcome, "error") {
+				summary.Failed++
+			}
+			summary.Usage.InputTokens += entry.Usage.InputTokens
+			summary.Usage.CompletionTokens += entry.Usage.CompletionTokens
+			summary.Usage.TotalTokens += entry.Usage.TotalTokens
+			summary.Cost += float64(entry.Usage.TotalTokens) * modelPrice(entry.Model) / 1000
+		}
+	}
+	return summary, nil
+}
+
+// readAuditFile читает из файла журнала записи, для которых keep возвращает true
+func readAuditFile(path string, keep func(AuditEntry) bool) ([]AuditEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия журнала %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4<<20)
+	for scanner.Scan() {
+		var entry AuditEntry
+		// Оборванная при сбое строка не должна мешать чтению остальных
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || !keep(entry) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("ошибка чтения журнала %s: %w", path, err)
+	}
+	return entries, nil
+}
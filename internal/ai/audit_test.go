@@ -0,0 +1,300 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestAuditLogger создает журнал во временном каталоге со временем clock
+func newTestAuditLogger(t *testing.T, dir string, maxSize int64, clock *fakeClock) *auditLogger {
+	t.Helper()
+	a := newAuditLogger(dir, maxSize, 3, true, []string{"AQVN-secret-key"}, clock.now)
+	t.Cleanup(func() {
+		if a.file != nil {
+			a.file.Close()
+		}
+	})
+	return a
+}
+
+// auditFiles имена файлов журнала в каталоге dir
+func auditFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var names []string
+	for _, path := range (&auditLogger{dir: dir}).files() {
+		names = append(names, filepath.Base(path))
+	}
+	return names
+}
+
+// auditLineSize размер строки журнала с записью entry
+func auditLineSize(t *testing.T, entry AuditEntry) int64 {
+	t.Helper()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int64(len(line)) + 1
+}
+
+func writeAudit(t *testing.T, a *auditLogger, entry AuditEntry) {
+	t.Helper()
+	if err := a.write(entry); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+}
+
+func TestAuditRotatesDaily(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{current: time.Date(2025, 3, 1, 23, 59, 0, 0, time.UTC)}
+	a := newTestAuditLogger(t, dir, 1<<20, clock)
+
+	writeAudit(t, a, AuditEntry{UserID: 1, Type: RequestPost})
+	writeAudit(t, a, AuditEntry{UserID: 1, Type: RequestPost})
+	clock.current = clock.current.Add(2 * time.Minute)
+	writeAudit(t, a, AuditEntry{UserID: 1, Type: RequestPost})
+
+	want := []string{"ai_audit-2025-03-01-01.jsonl", "ai_audit-2025-03-02-01.jsonl"}
+	if got := auditFiles(t, dir); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("файлы = %v, ожидалось %v", got, want)
+	}
+}
+
+func TestAuditRotatesBySize(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{current: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	entry := AuditEntry{UserID: 1, Type: RequestPost, Response: strings.Repeat("x", 150)}
+	maxSize := 2*auditLineSize(t, entry) + 10
+	a := newTestAuditLogger(t, dir, maxSize, clock)
+	for i := 0; i < 5; i++ {
+		writeAudit(t, a, entry)
+	}
+
+	// В файл помещаются две записи
+	files := auditFiles(t, dir)
+	if len(files) != 3 || files[2] != "ai_audit-2025-03-01-03.jsonl" {
+		t.Fatalf("файлы = %v", files)
+	}
+	for _, name := range files {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() > maxSize {
+			t.Errorf("%s: %d байт больше предела", name, info.Size())
+		}
+	}
+
+	// Запись больше предела пишется в отдельный файл, а не теряется
+	writeAudit(t, a, AuditEntry{UserID: 2, Response: strings.Repeat("y", 1000)})
+	entries, err := a.recent(2, 5)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("большая запись: %d записей, %v", len(entries), err)
+	}
+}
+
+func TestAuditContinuesAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{current: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	entry := AuditEntry{UserID: 1, Type: RequestPost, Response: strings.Repeat("x", 150)}
+	maxSize := 2*auditLineSize(t, entry) + 10
+
+	first := newTestAuditLogger(t, dir, maxSize, clock)
+	for i := 0; i < 3; i++ {
+		writeAudit(t, first, entry)
+	}
+	first.file.Close()
+	first.file = nil
+
+	// Второй файл дня заполнен наполовину: запись дописывается в него, следующая — в третий
+	second := newTestAuditLogger(t, dir, maxSize, clock)
+	writeAudit(t, second, entry)
+	if got := auditFiles(t, dir); len(got) != 2 {
+		t.Fatalf("после перезапуска файлы = %v", got)
+	}
+	writeAudit(t, second, entry)
+	if got := auditFiles(t, dir); len(got) != 3 {
+		t.Errorf("после заполнения файлы = %v", got)
+	}
+}
+
+func TestAuditRemovesOldFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"ai_audit-2025-02-20-01.jsonl", "ai_audit-2025-02-25-01.jsonl", "ai_audit-2025-02-25-02.jsonl", "ai_audit-2025-02-26-01.jsonl", "ai_audit-2025-02-26-02.jsonl", "ai_audit-2025-02-27-01.jsonl", "other.jsonl"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clock := &fakeClock{current: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	writeAudit(t, newTestAuditLogger(t, dir, 1<<20, clock), AuditEntry{UserID: 1})
+
+	// Файлы старше трех дней (до 26 февраля) удаляются, чужие файлы не трогаются
+	want := []string{"ai_audit-2025-02-26-01.jsonl", "ai_audit-2025-02-26-02.jsonl", "ai_audit-2025-02-27-01.jsonl", "ai_audit-2025-03-01-01.jsonl"}
+	if got := auditFiles(t, dir); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("файлы = %v, ожидалось %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other.jsonl")); err != nil {
+		t.Errorf("чужой файл удален: %v", err)
+	}
+}
+
+func TestAuditRedactsSecrets(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	a := newTestAuditLogger(t, t.TempDir(), 1<<20, clock)
+	writeAudit(t, a, AuditEntry{UserID: 1, Response: "ключ AQVN-secret-key в ответе", Outcome: "error: Api-Key AQVN-secret-key отклонен"})
+
+	entries, err := a.recent(1, 1)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("recent: %v, %v", entries, err)
+	}
+	if entry := entries[0]; strings.Contains(entry.Response+entry.Outcome, "AQVN-secret-key") ||
+		!strings.Contains(entry.Outcome, "[REDACTED]") {
+		t.Errorf("ключ в журнале: %+v", entry)
+	}
+
+	a.responses = false
+	writeAudit(t, a, AuditEntry{UserID: 2, Response: "текст поста", Outcome: "ok"})
+	if entries, _ := a.recent(2, 1); len(entries) != 1 || entries[0].Response != "" {
+		t.Errorf("ответ записан при выключенных ответах: %+v", entries)
+	}
+}
+
+func TestAuditRecentAcrossFiles(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	a := newTestAuditLogger(t, t.TempDir(), 1<<20, clock)
+	for i := 1; i <= 4; i++ {
+		writeAudit(t, a, AuditEntry{UserID: 1, Type: fmt.Sprintf("day1-%d", i)})
+		writeAudit(t, a, AuditEntry{UserID: 2, Type: "чужой"})
+	}
+	clock.current = clock.current.AddDate(0, 0, 1)
+	writeAudit(t, a, AuditEntry{UserID: 1, Type: "day2-1"})
+
+	entries, err := a.recent(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, entry := range entries {
+		types = append(types, entry.Type)
+	}
+	if want := []string{"day2-1", "day1-4", "day1-3"}; fmt.Sprint(types) != fmt.Sprint(want) {
+		t.Errorf("записи = %v, ожидалось %v", types, want)
+	}
+}
+
+func TestAuditAnonymize(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{current: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	a := newTestAuditLogger(t, dir, 1<<20, clock)
+	usage := Usage{InputTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+	writeAudit(t, a, AuditEntry{Time: clock.current, UserID: 1, Type: RequestPost, Article: "https://example.com", Response: "Пост", Usage: usage})
+	clock.current = clock.current.AddDate(0, 0, 1)
+	writeAudit(t, a, AuditEntry{Time: clock.current, UserID: 2, Type: RequestPost, Response: "Чужой пост", Usage: usage})
+	writeAudit(t, a, AuditEntry{Time: clock.current, UserID: 1, Type: RequestRewrite, Response: "Переписано", Usage: usage})
+	before, _ := a.summarize(time.Time{}, clock.current.Add(time.Hour))
+
+	changed, err := a.anonymize(1)
+	if err != nil || changed != 2 {
+		t.Fatalf("обезличено %d записей, ошибка %v", changed, err)
+	}
+	if entries, _ := a.recent(1, 10); len(entries) != 0 {
+		t.Errorf("записи удаленного пользователя: %+v", entries)
+	}
+	if entries, _ := a.recent(2, 10); len(entries) != 1 || entries[0].Response != "Чужой пост" {
+		t.Errorf("записи другого пользователя: %+v", entries)
+	}
+	for _, path := range a.files() {
+		data, _ := os.ReadFile(path)
+		if strings.Contains(string(data), "Переписано") || strings.Contains(string(data), "example.com") {
+			t.Errorf("в %s остался текст пользователя: %s", filepath.Base(path), data)
+		}
+	}
+	// Токены и стоимость остаются в отчетах
+	if after, _ := a.summarize(time.Time{}, clock.current.Add(time.Hour)); after != before {
+		t.Errorf("итог после обезличивания %+v, до %+v", after, before)
+	}
+
+	// Запись продолжается в подмененный текущий файл
+	writeAudit(t, a, AuditEntry{Time: clock.current, UserID: 3, Type: RequestPost})
+	if entries, _ := a.recent(3, 10); len(entries) != 1 {
+		t.Errorf("запись после обезличивания: %+v", entries)
+	}
+	if want := []string{"ai_audit-2025-03-01-01.jsonl", "ai_audit-2025-03-02-01.jsonl"}; fmt.Sprint(auditFiles(t, dir)) != fmt.Sprint(want) {
+		t.Errorf("файлы = %v, ожидалось %v", auditFiles(t, dir), want)
+	}
+}
+
+func TestAuditSummarize(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	a := newTestAuditLogger(t, t.TempDir(), 1<<20, clock)
+	day := clock.current
+
+	writeAudit(t, a, AuditEntry{Time: day.Add(-24 * time.Hour), Usage: Usage{TotalTokens: 1000}, Outcome: "ok"})
+	writeAudit(t, a, AuditEntry{Time: day, Usage: Usage{InputTokens: 300, CompletionTokens: 100, TotalTokens: 400}, Outcome: "ok"})
+	writeAudit(t, a, AuditEntry{Time: day.Add(time.Hour), Usage: Usage{TotalTokens: 100}, Outcome: "error: 500"})
+	writeAudit(t, a, AuditEntry{Time: day.Add(2 * time.Hour), Outcome: "canceled"})
+
+	summary, err := a.summarize(day, day.Add(24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Requests != 3 || summary.Failed != 1 || summary.Usage.TotalTokens != 500 || summary.Usage.InputTokens != 300 {
+		t.Errorf("итог = %+v", summary)
+	}
+}
+
+func TestRecordAudit(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)}
+	saved := audit
+	audit = newTestAuditLogger(t, t.TempDir(), 1<<20, clock)
+	t.Cleanup(func() { audit = saved })
+
+	messages := []Message{{Role: "user", Content: "промпт"}}
+	ctx := withRequestType(WithAuditArticle(WithAuditUser(context.Background(), 7), strings.Repeat("a", 300)), RequestRerank)
+	recordAudit(ctx, "yandexgpt-lite", messages, `{"index": 1}`, Usage{TotalTokens: 50}, time.Now(), nil)
+
+	canceled, cancel := context.WithCancel(WithAuditUser(context.Background(), 7))
+	cancel()
+	recordAudit(canceled, "yandexgpt", messages, "", Usage{}, time.Now(), context.Canceled)
+	recordAudit(WithAuditUser(context.Background(), 7), "yandexgpt", messages, "", Usage{}, time.Now(), ErrCircuitOpen)
+	recordAudit(WithAuditUser(context.Background(), 7), "yandexgpt", messages, "", Usage{}, time.Now(), errors.New("HTTP 500"))
+
+	entries, err := RecentAuditEntries(7, 10)
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("записи: %d, %v", len(entries), err)
+	}
+
+	outcomes := []string{entries[3].Outcome, entries[2].Outcome, entries[1].Outcome, entries[0].Outcome}
+	if want := []string{"ok", "canceled", "circuit_open", "error: HTTP 500"}; fmt.Sprint(outcomes) != fmt.Sprint(want) {
+		t.Errorf("исходы = %q, ожидалось %q", outcomes, want)
+	}
+
+	first := entries[3]
+	if first.Type != RequestRerank || first.Model != "yandexgpt-lite" || first.PromptHash != promptHash(messages) {
+		t.Errorf("запись = %+v", first)
+	}
+	if first.Article != strings.Repeat("a", auditArticleLength)+"…" {
+		t.Errorf("ссылка на статью не сокращена: %d символов", len([]rune(first.Article)))
+	}
+	if entries[0].Type != RequestComplete {
+		t.Errorf("тип по умолчанию = %q", entries[0].Type)
+	}
+}
+
+func TestPromptHash(t *testing.T) {
+	a := promptHash([]Message{{Role: "system", Content: "x"}, {Role: "user", Content: "y"}})
+	if len(a) != 16 {
+		t.Errorf("длина хеша %d", len(a))
+	}
+	if b := promptHash([]Message{{Role: "system", Content: "xy"}, {Role: "user", Content: ""}}); a == b {
+		t.Error("хеш не различает границы сообщений")
+	}
+}
@@ -0,0 +1,187 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultBreakerThreshold ошибок подряд, после которых запросы блокируются
+	defaultBreakerThreshold = 5
+	// defaultBreakerCooldown сколько запросы блокируются перед пробным запросом
+	defaultBreakerCooldown = 2 * time.Minute
+)
+
+// ErrCircuitOpen запросы к модели временно не выполняются из-за серии ошибок
+var ErrCircuitOpen = errors.New("сервис генерации временно недоступен")
+
+// BreakerState состояние автомата
+type BreakerState string
+
+const (
+	// BreakerClosed запросы выполняются
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen запросы сразу завершаются ошибкой ErrCircuitOpen
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen выполняется один пробный запрос
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+// BreakerStatus состояние автомата для админских команд
+type BreakerStatus struct {
+	State               BreakerState
+	ConsecutiveFailures int
+	OpenedAt            time.Time
+	Opens               int
+	Rejected            int
+}
+
+// circuitBreaker размыкает запросы к модели после серии временных ошибок
+type circuitBreaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+	opens    int
+	rejected int
+
+	onChange func(from, to BreakerState)
+}
+
+// breaker общий автомат для запросов к модели; настраивается Configure
+var breaker = newCircuitBreaker(defaultBreakerThreshold, defaultBreakerCooldown, time.Now)
+
+func newCircuitBreaker(threshold int, cooldown time.Duration, now func() time.Time) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       now,
+		state:     BreakerClosed,
+	}
+}
+
+// SetBreakerListener задает функцию, вызываемую при смене состояния автомата
+func SetBreakerListener(listener func(from, to BreakerState)) {
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	breaker.onChange = listener
+}
+
+// listener возвращает функцию, вызываемую при смене состояния
+func (b *circuitBreaker) listener() func(from, to BreakerState) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.onChange
+}
+
+// Breaker возвращает состояние автомата запросов к модели
+func Breaker() BreakerStatus {
+	return breaker.status()
+}
+
+// CircuitOpen сообщает, что запросы к модели сейчас будут отклонены без отправки
+func CircuitOpen() bool {
+	return breaker.rejects()
+}
+
+// rejects проверяет, отклонит ли allow запрос, не меняя состояние
+func (b *circuitBreaker) rejects() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		return b.now().Sub(b.openedAt) < b.cooldown
+	case BreakerHalfOpen:
+		return b.probing
+	}
+	return false
+}
+
+// allow проверяет, можно ли выполнить запрос. После cooldown пропускает один пробный
+// запрос; probe сообщает, что разрешенный запрос пробный.
+func (b *circuitBreaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			b.rejected++
+			return false, ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+		return true, nil
+	case BreakerHalfOpen:
+		if b.probing {
+			b.rejected++
+			return false, ErrCircuitOpen
+		}
+		b.probing = true
+		return true, nil
+	}
+	return false, nil
+}
+
+// record учитывает результат запроса. Ошибкой сервиса считаются только временные ошибки;
+// отмена запроса пользователем на состояние не влияет.
+func (b *circuitBreaker) record(ctx context.Context, probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+
+	switch {
+	case err != nil && ctx.Err() != nil:
+		return
+	case IsRetryable(err):
+		b.failures++
+		if probe || (b.state == BreakerClosed && b.failures >= b.threshold) {
+			b.openedAt = b.now()
+			b.opens++
+			b.setState(BreakerOpen)
+		}
+	default:
+		b.failures = 0
+		if b.state != BreakerClosed {
+			b.setState(BreakerClosed)
+		}
+	}
+}
+
+// setState меняет состояние; вызывается под mu
+func (b *circuitBreaker) setState(state BreakerState) {
+	from := b.state
+	b.state = state
+	if from == state {
+		return
+	}
+
+	log.Printf("[AI] ⚡ Автомат запросов к модели: %s → %s (ошибок подряд: %d)", from, state, b.failures)
+	if b.onChange != nil {
+		go b.onChange(from, state)
+	}
+}
+
+func (b *circuitBreaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return BreakerStatus{
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		OpenedAt:            b.openedAt,
+		Opens:               b.opens,
+		Rejected:            b.rejected,
+	}
+}
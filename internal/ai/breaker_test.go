@@ -0,0 +1,186 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock время, которое двигает тест
+type fakeClock struct {
+	current time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.current
+}
+
+var (
+	errOverloaded = &AIError{StatusCode: 503, Retryable: true, Err: errors.New("перегрузка")}
+	errBadRequest = &AIError{StatusCode: 400, Err: errors.New("некорректный запрос")}
+)
+
+// failRequests проводит через автомат n запросов, завершившихся err
+func failRequests(t *testing.T, b *circuitBreaker, n int, err error) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		probe, allowErr := b.allow()
+		if allowErr != nil {
+			t.Fatalf("запрос %d отклонен: %v", i+1, allowErr)
+		}
+		b.record(context.Background(), probe, err)
+	}
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := newCircuitBreaker(3, time.Minute, clock.now)
+
+	failRequests(t, b, 2, errOverloaded)
+	if state := b.status().State; state != BreakerClosed {
+		t.Fatalf("после 2 ошибок состояние %s", state)
+	}
+
+	failRequests(t, b, 1, errOverloaded)
+	status := b.status()
+	if status.State != BreakerOpen || status.Opens != 1 || !status.OpenedAt.Equal(clock.current) {
+		t.Fatalf("после 3 ошибок: %+v", status)
+	}
+
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("разомкнутый автомат пропустил запрос: %v", err)
+	}
+	if !b.rejects() || b.status().Rejected != 1 {
+		t.Errorf("rejects = %v, отклонено %d", b.rejects(), b.status().Rejected)
+	}
+}
+
+func TestBreakerSuccessResetsFailures(t *testing.T) {
+	b := newCircuitBreaker(3, time.Minute, time.Now)
+	failRequests(t, b, 2, errOverloaded)
+	failRequests(t, b, 1, nil)
+	failRequests(t, b, 2, errOverloaded)
+
+	if status := b.status(); status.State != BreakerClosed || status.ConsecutiveFailures != 2 {
+		t.Errorf("ошибки не подряд разомкнули автомат: %+v", status)
+	}
+}
+
+func TestBreakerIgnoresPermanentAndCanceled(t *testing.T) {
+	b := newCircuitBreaker(2, time.Minute, time.Now)
+
+	// Постоянная ошибка — проблема запроса, а не сервиса
+	failRequests(t, b, 5, errBadRequest)
+	if state := b.status().State; state != BreakerClosed {
+		t.Fatalf("постоянные ошибки разомкнули автомат: %s", state)
+	}
+
+	// Отмена пользователем не влияет на счетчик
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 5; i++ {
+		probe, _ := b.allow()
+		b.record(ctx, probe, errOverloaded)
+	}
+	if status := b.status(); status.State != BreakerClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("отмененные запросы учтены: %+v", status)
+	}
+}
+
+func TestBreakerHalfOpenProbe(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := newCircuitBreaker(1, time.Minute, clock.now)
+	failRequests(t, b, 1, errOverloaded)
+
+	clock.current = clock.current.Add(59 * time.Second)
+	if _, err := b.allow(); err == nil {
+		t.Fatal("запрос пропущен до конца cooldown")
+	}
+
+	// После cooldown пропускается один пробный запрос, остальные отклоняются
+	clock.current = clock.current.Add(time.Second)
+	if b.rejects() {
+		t.Error("rejects после cooldown")
+	}
+	probe, err := b.allow()
+	if err != nil || !probe {
+		t.Fatalf("пробный запрос: probe %v, %v", probe, err)
+	}
+	if state := b.status().State; state != BreakerHalfOpen {
+		t.Fatalf("состояние %s, ожидалось half-open", state)
+	}
+	if _, err := b.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Error("второй запрос пропущен во время пробного")
+	}
+
+	// Неудачный пробный запрос снова размыкает автомат с новым отсчетом
+	b.record(context.Background(), probe, errOverloaded)
+	status := b.status()
+	if status.State != BreakerOpen || status.Opens != 2 || !status.OpenedAt.Equal(clock.current) {
+		t.Fatalf("после неудачной пробы: %+v", status)
+	}
+
+	// Удачный пробный запрос замыкает автомат
+	clock.current = clock.current.Add(time.Minute)
+	probe, err = b.allow()
+	if err != nil || !probe {
+		t.Fatalf("пробный запрос: probe %v, %v", probe, err)
+	}
+	b.record(context.Background(), probe, nil)
+	if status := b.status(); status.State != BreakerClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("после удачной пробы: %+v", status)
+	}
+	if probe, err := b.allow(); err != nil || probe {
+		t.Errorf("замкнутый автомат: probe %v, %v", probe, err)
+	}
+}
+
+func TestBreakerCanceledProbeAllowsNextProbe(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := newCircuitBreaker(1, time.Minute, clock.now)
+	failRequests(t, b, 1, errOverloaded)
+	clock.current = clock.current.Add(time.Minute)
+
+	probe, _ := b.allow()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.record(ctx, probe, context.Canceled)
+
+	// Пробный запрос отменен: состояние не изменилось, но следующий может стать пробным
+	if state := b.status().State; state != BreakerHalfOpen {
+		t.Fatalf("состояние %s", state)
+	}
+	if probe, err := b.allow(); err != nil || !probe {
+		t.Errorf("следующий пробный запрос: probe %v, %v", probe, err)
+	}
+}
+
+func TestBreakerNotifiesTransitions(t *testing.T) {
+	clock := &fakeClock{current: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	b := newCircuitBreaker(1, time.Minute, clock.now)
+
+	transitions := make(chan [2]BreakerState, 10)
+	b.onChange = func(from, to BreakerState) { transitions <- [2]BreakerState{from, to} }
+
+	failRequests(t, b, 1, errOverloaded)
+	clock.current = clock.current.Add(time.Minute)
+	probe, _ := b.allow()
+	b.record(context.Background(), probe, nil)
+
+	want := [][2]BreakerState{{BreakerClosed, BreakerOpen}, {BreakerOpen, BreakerHalfOpen}, {BreakerHalfOpen, BreakerClosed}}
+	got := make(map[[2]BreakerState]bool)
+	for range want {
+		select {
+		case transition := <-transitions:
+			got[transition] = true
+		case <-time.After(time.Second):
+			t.Fatalf("получено переходов: %d из %d", len(got), len(want))
+		}
+	}
+	for _, transition := range want {
+		if !got[transition] {
+			t.Errorf("нет перехода %s → %s", transition[0], transition[1])
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// charsPerToken примерное число символов русского текста на токен.
+	// Берем с запасом: лучше недооценить бюджет, чем получить ошибку API.
+	charsPerToken = 2.5
+	// messageOverheadTokens служебные токены на каждое сообщение
+	messageOverheadTokens = 8
+	// defaultContextTokens контекст модели, размер которого неизвестен
+	defaultContextTokens = 8192
+	// keepLeadingParagraphs сколько первых абзацев статьи сохраняется всегда
+	keepLeadingParagraphs = 2
+	// minKeywordLength короче этого слова запроса не ищутся в абзацах
+	minKeywordLength = 3
+)
+
+// contextBudget клиент, знающий размер контекста своей модели
+type contextBudget interface {
+	ContextTokens(ctx context.Context) int
+}
+
+// estimateTokens грубо оценивает число токенов в тексте
+func estimateTokens(text string) int {
+	return int(float64(utf8.RuneCountInString(text))/charsPerToken) + 1
+}
+
+// estimateMessagesTokens оценивает число токенов в наборе сообщений
+func estimateMessagesTokens(messages []Message) int {
+	total := 0
+	for _, message := range messages {
+		total += estimateTokens(message.Content) + messageOverheadTokens
+	}
+	return total
+}
+
+// ParseContextTokens разбирает размеры контекста моделей вида "lite=8000,pro=32000"
+func ParseContextTokens(value string) (map[ModelTier]int, error) {
+	sizes := make(map[ModelTier]int)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		name, size, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("ожидается модель=токены: %s", part)
+		}
+		tier, err := ParseModelTier(name)
+		if err != nil {
+			return nil, err
+		}
+		tokens, err := strconv.Atoi(strings.TrimSpace(size))
+		if err != nil || tokens <= 0 {
+			return nil, fmt.Errorf("некорректный размер контекста для %s: %s", name, size)
+		}
+		sizes[tier] = tokens
+	}
+	return sizes, nil
+}
+
+// fitPrompt рендерит сообщения и, если они не помещаются в контекст модели вместе с ответом,
+// сокращает текст статьи: render получает текст статьи и возвращает готовые сообщения.
+func (w postWriter) fitPrompt(ctx context.Context, content, keywords string, render func(content string) ([]Message, error)) ([]Message, error) {
+	messages, err := render(content)
+	if err != nil {
+		return nil, err
+	}
+
+	contextTokens := defaultContextTokens
+	if budget, ok := w.completer.(contextBudget); ok {
+		contextTokens = budget.ContextTokens(ctx)
+	}
+	budget := contextTokens - w.params(ctx).MaxTokens
+
+	total := estimateMessagesTokens(messages)
+	if total <= budget {
+		return messages, nil
+	}
+
+	// Остальная часть промпта не сокращается, под статью остается разница
+	contentBudget := estimateTokens(content) - (total - budget)
+	if contentBudget <= 0 {
+		return nil, fmt.Errorf("промпт не помещается в контекст модели (%d из %d токенов) даже без статьи", total, budget)
+	}
+
+	trimmed := trimContent(content, keywords, int(float64(contentBudget)*charsPerToken))
+	log.Printf("[AI] ✂️ Статья сокращена с %d до %d символов: промпт ~%d токенов, бюджет %d",
+		utf8.RuneCountInString(content), utf8.RuneCountInString(trimmed), total, budget)
+
+	return render(trimmed)
+}
+
+// trimContent сокращает текст до maxRunes символов по абзацам: сохраняет первые абзацы
+// и абзацы с ключевыми словами, затем добирает остальные по порядку.
+func trimContent(content, keywords string, maxRunes int) string {
+	if utf8.RuneCountInString(content) <= maxRunes {
+		return content
+	}
+
+	paragraphs := splitParagraphs(content)
+	terms := keywordTerms(keywords)
+
+	// Приоритеты: 0 — первые абзацы, 1 — с ключевыми словами, 2 — остальные
+	priority := make([]int, len(paragraphs))
+	for i, paragraph := range paragraphs {
+		switch {
+		case i < keepLeadingParagraphs:
+			priority[i] = 0
+		case containsAny(strings.ToLower(paragraph), terms):
+			priority[i] = 1
+		default:
+			priority[i] = 2
+		}
+	}
+
+	keep := make([]bool, len(paragraphs))
+	used := 0
+	for level := 0; level <= 2; level++ {
+		for i, paragraph := range paragraphs {
+			if priority[i] != level {
+				continue
+			}
+			// Разделитель между абзацами
+			size := utf8.RuneCountInString(paragraph) + 2
+			if used+size > maxRunes {
+				continue
+			}
+			keep[i] = true
+			used += size
+		}
+	}
+
+	var kept []string
+	for i, paragraph := range paragraphs {
+		if keep[i] {
+			kept = append(kept, paragraph)
+		}
+	}
+
+	// Даже первый абзац не поместился: режем его по словам
+	if len(kept) == 0 {
+		return truncateWords(paragraphs[0], maxRunes)
+	}
+	return strings.Join(kept, "\n\n")
+}
+
+// splitParagraphs делит текст на непустые абзацы
+func splitParagraphs(content string) []string {
+	var paragraphs []string
+	for _, line := range strings.Split(content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return paragraphs
+}
+
+// keywordTerms слова запроса в нижнем регистре, достаточно длинные для поиска
+func keywordTerms(keywords string) []string {
+	var terms []string
+	for _, word := range strings.Fields(strings.ToLower(keywords)) {
+		word = strings.Trim(word, `"'.,:;!?()«»-`)
+		runes := []rune(word)
+		if len(runes) < minKeywordLength {
+			continue
+		}
+		// Отбрасываем окончание, чтобы находить другие падежи: «выборы» → «выбо»
+		if len(runes) > 5 {
+			word = string(runes[:len(runes)-2])
+		}
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+func containsAny(text string, terms []string) bool {
+	for _, term := range terms {
+		if strings.Contains(text, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncateWords обрезает текст до maxRunes символов по границе слова
+func truncateWords(text string, maxRunes int) string {
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+
+	truncated := string(runes[:maxRunes])
+	if lastSpace := strings.LastIndex(truncated, " "); lastSpace > 0 {
+		truncated = truncated[:lastSpace]
+	}
+	return truncated + "..."
+}
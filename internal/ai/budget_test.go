@@ -0,0 +1,161 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// budgetCompleter scriptedCompleter с заданным размером контекста модели
+type budgetCompleter struct {
+	*scriptedCompleter
+	contextTokens int
+}
+
+func (c budgetCompleter) ContextTokens(ctx context.Context) int {
+	return c.contextTokens
+}
+
+// syntheticArticle статья из paragraphs абзацев по ~400 символов; в абзаце keywordAt
+// упоминается ключевая ставка
+func syntheticArticle(paragraphs, keywordAt int) string {
+	var parts []string
+	for i := 0; i < paragraphs; i++ {
+		text := fmt.Sprintf("Абзац %d. %s", i, strings.Repeat("Прочие подробности рынка и комментарии аналитиков. ", 8))
+		if i == keywordAt {
+			text = fmt.Sprintf("Абзац %d. Совет директоров сохранил ключевую ставку на уровне 21%%. %s", i, strings.Repeat("Детали решения. ", 10))
+		}
+		parts = append(parts, strings.TrimSpace(text))
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+func TestGeneratePostFromURLStaysWithinBudget(t *testing.T) {
+	saved := posts
+	posts = newPostCache(0, 0)
+	t.Cleanup(func() { posts = saved })
+
+	for _, contextTokens := range []int{3000, 4000, 8000} {
+		t.Run(fmt.Sprint(contextTokens), func(t *testing.T) {
+			completer := &scriptedCompleter{responses: []string{string(readFixture(t, "post_valid.json"))}}
+			writer := postWriter{completer: budgetCompleter{completer, contextTokens}, config: DefaultAIConfig()}
+
+			content := syntheticArticle(120, 90)
+			if _, err := writer.GeneratePostFromURL(context.Background(), "Ключевая ставка", content); err != nil {
+				t.Fatalf("GeneratePostFromURL: %v", err)
+			}
+
+			request := completer.requests[0]
+			budget := contextTokens - writer.config.Post.MaxTokens
+			if total := estimateMessagesTokens(request); total > budget {
+				t.Errorf("промпт ~%d токенов при бюджете %d", total, budget)
+			}
+			user := request[1].Content
+			if !strings.Contains(user, "Абзац 0.") || !strings.Contains(user, "Абзац 1.") {
+				t.Error("первые абзацы не сохранены")
+			}
+			if !strings.Contains(user, "сохранил ключевую ставку") {
+				t.Error("абзац с ключевыми словами заголовка не сохранен")
+			}
+			if strings.Contains(user, "Абзац 119.") {
+				t.Error("статья не сокращена")
+			}
+		})
+	}
+}
+
+func TestFitPromptKeepsShortContent(t *testing.T) {
+	writer, completer := newScriptedWriter(string(readFixture(t, "post_valid.json")))
+	content := syntheticArticle(3, 2)
+	if _, err := writer.GeneratePostFromURL(context.Background(), "Ставка", content); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(completer.requests[0][1].Content, content) {
+		t.Error("короткая статья изменена")
+	}
+}
+
+func TestFitPromptFailsWhenInstructionsDoNotFit(t *testing.T) {
+	completer := &scriptedCompleter{}
+	writer := postWriter{completer: budgetCompleter{completer, 900}, config: DefaultAIConfig()}
+	if _, err := writer.GeneratePostFromURL(context.Background(), "Ставка", syntheticArticle(10, 5)); err == nil {
+		t.Fatal("промпт отправлен, хотя не помещается даже без статьи")
+	}
+	if completer.calls() != 0 {
+		t.Error("запрос к модели отправлен")
+	}
+}
+
+func TestTrimContent(t *testing.T) {
+	content := "Первый абзац.\n\nВторой абзац.\n\nПро погоду.\n\nВыборы в парламент прошли спокойно.\n\nЕще про погоду."
+
+	got := trimContent(content, "выборы", 70)
+	want := "Первый абзац.\n\nВторой абзац.\n\nВыборы в парламент прошли спокойно."
+	if got != want {
+		t.Errorf("trimContent = %q, ожидалось %q", got, want)
+	}
+	if utf8.RuneCountInString(got) > 70 {
+		t.Errorf("длина %d больше 70", utf8.RuneCountInString(got))
+	}
+
+	// Оставшееся место добирается прочими абзацами, порядок абзацев сохраняется
+	want = "Первый абзац.\n\nВторой абзац.\n\nПро погоду.\n\nВыборы в парламент прошли спокойно."
+	if got := trimContent(content, "выборы", 90); got != want {
+		t.Errorf("trimContent = %q, ожидалось %q", got, want)
+	}
+
+	if got := trimContent(content, "", 1000); got != content {
+		t.Error("текст в пределах лимита изменен")
+	}
+
+	long := strings.TrimSpace(strings.Repeat("слово ", 100))
+	if got := trimContent(long, "", 50); !strings.HasSuffix(got, "...") || utf8.RuneCountInString(got) > 53 {
+		t.Errorf("первый абзац не обрезан по словам: %q", got)
+	}
+}
+
+func TestKeywordTerms(t *testing.T) {
+	got := keywordTerms("«Выборы» в Госдуму, ЦБ и курс")
+	want := []string{"выбо", "госду", "курс"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("keywordTerms = %q, ожидалось %q", got, want)
+	}
+}
+
+func TestTruncateWords(t *testing.T) {
+	if got := truncateWords("Короткий текст", 50); got != "Короткий текст" {
+		t.Errorf("truncateWords = %q", got)
+	}
+	if got := truncateWords("Банк России сохранил ставку", 15); got != "Банк России..." {
+		t.Errorf("truncateWords = %q", got)
+	}
+}
+
+func TestParseContextTokens(t *testing.T) {
+	got, err := ParseContextTokens(" lite=8000, pro = 32000 ,")
+	if err != nil {
+		t.Fatalf("ParseContextTokens: %v", err)
+	}
+	if len(got) != 2 || got[TierLite] != 8000 || got[TierPro] != 32000 {
+		t.Errorf("ParseContextTokens = %v", got)
+	}
+
+	for _, input := range []string{"lite", "max=1000", "lite=0", "pro=много"} {
+		if _, err := ParseContextTokens(input); err == nil {
+			t.Errorf("ParseContextTokens(%q) без ошибки", input)
+		}
+	}
+}
+
+func TestEstimateTokens(t *testing.T) {
+	// Оценка с запасом: 2,5 символа на токен
+	if got := estimateTokens(strings.Repeat("я", 250)); got != 101 {
+		t.Errorf("estimateTokens = %d", got)
+	}
+	messages := []Message{{Content: strings.Repeat("я", 25)}, {Content: ""}}
+	if got := estimateMessagesTokens(messages); got != 11+1+2*messageOverheadTokens {
+		t.Errorf("estimateMessagesTokens = %d", got)
+	}
+}
@@ -0,0 +1,136 @@
+package ai
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultPostCacheSize сколько последних постов хранить по умолчанию
+	defaultPostCacheSize = 200
+	// defaultPostCacheTTL сколько по умолчанию живет пост в кэше
+	defaultPostCacheTTL = 15 * time.Minute
+)
+
+// postCache LRU-кэш сгенерированных постов с ограничением по размеру и времени жизни
+type postCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List // от недавно использованных к давно использованным
+
+	hits, misses, evictions int
+}
+
+// postCacheEntry элемент кэша
+type postCacheEntry struct {
+	key     string
+	post    Post
+	expires time.Time
+}
+
+// CacheStats счетчики кэша постов
+type CacheStats struct {
+	Size      int
+	Hits      int
+	Misses    int
+	Evictions int
+}
+
+// posts общий кэш постов всех клиентов; настраивается Configure
+var posts = newPostCache(defaultPostCacheSize, defaultPostCacheTTL)
+
+func newPostCache(size int, ttl time.Duration) *postCache {
+	return &postCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// ChargeCachedPosts сообщает, списывать ли генерацию за пост из кэша (по умолчанию да)
+func ChargeCachedPosts() bool {
+	return currentConfig().Cache.ChargeUser
+}
+
+// PostCacheStats возвращает счетчики кэша постов
+func PostCacheStats() CacheStats {
+	return posts.stats()
+}
+
+// postCacheKey строит ключ по модели и отрендеренным сообщениям. Сообщения уже содержат
+// текст шаблона промпта, ключевые слова и статью, поэтому правка шаблона или другая
+// новость дают другой ключ.
+func postCacheKey(ctx context.Context, messages []Message) string {
+	hash := sha256.New()
+	hash.Write([]byte(tierFromContext(ctx, "")))
+	for _, message := range messages {
+		hash.Write([]byte{0})
+		hash.Write([]byte(message.Role))
+		hash.Write([]byte{0})
+		hash.Write([]byte(message.Content))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// get возвращает пост, если он есть в кэше и не устарел
+func (c *postCache) get(key string) (Post, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.misses++
+		return Post{}, false
+	}
+
+	entry := element.Value.(*postCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		c.misses++
+		return Post{}, false
+	}
+
+	c.order.MoveToFront(element)
+	c.hits++
+	return entry.post, true
+}
+
+// put сохраняет пост, вытесняя давно не использованные
+func (c *postCache) put(key string, post Post) {
+	if c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*postCacheEntry)
+		entry.post = post
+		entry.expires = time.Now().Add(c.ttl)
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&postCacheEntry{key: key, post: post, expires: time.Now().Add(c.ttl)})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*postCacheEntry).key)
+		c.evictions++
+	}
+}
+
+func (c *postCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Size: c.order.Len(), Hits: c.hits, Misses: c.misses, Evictions: c.evictions}
+}
@@ -0,0 +1,169 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPostCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newPostCache(2, time.Hour)
+	cache.put("a", Post{Title: "A"})
+	cache.put("b", Post{Title: "B"})
+
+	// Чтение делает "a" недавно использованным, поэтому вытесняется "b"
+	if _, ok := cache.get("a"); !ok {
+		t.Fatal("a не найден")
+	}
+	cache.put("c", Post{Title: "C"})
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("b не вытеснен")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := cache.get(key); !ok {
+			t.Errorf("%s вытеснен", key)
+		}
+	}
+
+	want := CacheStats{Size: 2, Hits: 3, Misses: 1, Evictions: 1}
+	if got := cache.stats(); got != want {
+		t.Errorf("stats = %+v, ожидалось %+v", got, want)
+	}
+}
+
+func TestPostCacheUpdateKeepsSize(t *testing.T) {
+	cache := newPostCache(2, time.Hour)
+	cache.put("a", Post{Title: "старый"})
+	cache.put("b", Post{Title: "B"})
+	cache.put("a", Post{Title: "новый"})
+	cache.put("c", Post{Title: "C"})
+
+	// Повторная запись "a" обновила его, поэтому вытеснен "b"
+	if post, ok := cache.get("a"); !ok || post.Title != "новый" {
+		t.Errorf("a = %+v, %v", post, ok)
+	}
+	if _, ok := cache.get("b"); ok {
+		t.Error("b не вытеснен")
+	}
+}
+
+func TestPostCacheExpires(t *testing.T) {
+	cache := newPostCache(10, 20*time.Millisecond)
+	cache.put("a", Post{Title: "A"})
+	time.Sleep(40 * time.Millisecond)
+
+	if _, ok := cache.get("a"); ok {
+		t.Error("устаревший пост возвращен")
+	}
+	if got := cache.stats(); got.Size != 0 || got.Misses != 1 {
+		t.Errorf("stats = %+v", got)
+	}
+}
+
+func TestPostCacheDisabled(t *testing.T) {
+	cache := newPostCache(0, time.Hour)
+	cache.put("a", Post{Title: "A"})
+	if _, ok := cache.get("a"); ok {
+		t.Error("выключенный кэш вернул пост")
+	}
+}
+
+func TestPostCacheConcurrentAccess(t *testing.T) {
+	cache := newPostCache(16, time.Hour)
+	var wg sync.WaitGroup
+	for worker := 0; worker < 8; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprint((worker + i) % 32)
+				cache.put(key, Post{Title: key})
+				if post, ok := cache.get(key); ok && post.Title != key {
+					t.Errorf("по ключу %s пост %q", key, post.Title)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if size := cache.stats().Size; size > 16 {
+		t.Errorf("размер кэша %d больше предела", size)
+	}
+}
+
+func TestPostCacheKey(t *testing.T) {
+	base := []Message{{Role: "system", Content: "инструкция"}, {Role: "user", Content: "статья"}}
+	key := postCacheKey(context.Background(), base)
+
+	if again := postCacheKey(context.Background(), []Message{{Role: "system", Content: "инструкция"}, {Role: "user", Content: "статья"}}); again != key {
+		t.Error("одинаковые сообщения дали разные ключи")
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		messages []Message
+	}{
+		{"другая модель", WithModelTier(context.Background(), TierPro), base},
+		{"другая статья", context.Background(), []Message{base[0], {Role: "user", Content: "другая статья"}}},
+		{"другой шаблон", context.Background(), []Message{{Role: "system", Content: "инструкция v2"}, base[1]}},
+		{"другая роль", context.Background(), []Message{{Role: "user", Content: "инструкция"}, base[1]}},
+		{"текст перенесен между сообщениями", context.Background(), []Message{{Role: "system", Content: "инструкциястатья"}, {Role: "user", Content: ""}}},
+	}
+	for _, tt := range tests {
+		if postCacheKey(tt.ctx, tt.messages) == key {
+			t.Errorf("%s: ключ совпал", tt.name)
+		}
+	}
+}
+
+func TestGeneratePostUsesCache(t *testing.T) {
+	saved := posts
+	posts = newPostCache(10, time.Hour)
+	t.Cleanup(func() { posts = saved })
+
+	valid := string(readFixture(t, "post_valid.json"))
+	writer, completer := newScriptedWriter(valid, valid)
+	article := ArticleInfo{Title: "Ставка ЦБ", Summary: "Решение совета директоров"}
+
+	first, err := writer.GeneratePost(context.Background(), "ставка", article)
+	if err != nil || first.Cached {
+		t.Fatalf("первый пост = %+v, %v", first, err)
+	}
+	second, err := writer.GeneratePost(context.Background(), "ставка", article)
+	if err != nil || !second.Cached || second.Title != first.Title {
+		t.Fatalf("второй пост = %+v, %v", second, err)
+	}
+	if completer.calls() != 1 {
+		t.Errorf("запросов к модели = %d, ожидался 1", completer.calls())
+	}
+
+	// Другие ключевые слова — другой промпт и новый запрос
+	if _, err := writer.GeneratePost(context.Background(), "инфляция", article); err != nil {
+		t.Fatal(err)
+	}
+	if completer.calls() != 2 {
+		t.Errorf("запросов к модели = %d, ожидалось 2", completer.calls())
+	}
+}
+
+func TestGeneratePostDoesNotCacheRefusal(t *testing.T) {
+	saved := posts
+	posts = newPostCache(10, time.Hour)
+	t.Cleanup(func() { posts = saved })
+
+	refused := string(readFixture(t, "post_refused.json"))
+	writer, completer := newScriptedWriter(refused, refused)
+	for i := 0; i < 2; i++ {
+		post, err := writer.GeneratePost(context.Background(), "тема", ArticleInfo{Title: "Статья"})
+		if err != nil || !post.Refused || post.Cached {
+			t.Fatalf("пост = %+v, %v", post, err)
+		}
+	}
+	if completer.calls() != 2 {
+		t.Errorf("запросов к модели = %d, отказ не должен кэшироваться", completer.calls())
+	}
+}
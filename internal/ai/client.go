@@ -1,246 +1,189 @@
 package ai
 
 import (
-	"bytes"
+	"AIGenerator/internal/httpx"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
-	"os"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
+// YandexGPTClient реализация TextGenerator поверх YandexGPT
 type YandexGPTClient struct {
+	postWriter
+
 	apiKey     string
 	folderID   string
-	modelURI   string
 	baseURL    string
+	protocol   string
 	httpClient *http.Client
-}
+	proxyURL   *url.URL
 
-type ChatCompletionRequest struct {
-	Model       string    `json:"model"`
-	Messages    []Message `json:"messages"`
-	Temperature float64   `json:"temperature,omitempty"`
-	MaxTokens   int       `json:"max_tokens,omitempty"`
-}
+	// defaultTier модель по умолчанию, переключается командой /setmodel
+	defaultTier ModelTier
+	mu          sync.RWMutex
 
-type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	// contextTokens размер контекста каждой модели
+	contextTokens map[ModelTier]int
 }
 
-type ChatCompletionResponse struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int    `json:"created"`
-	Model   string `json:"model"`
-	Choices []struct {
-		Index   int `json:"index"`
-		Message struct {
-			Role    string `json:"role"`
-			Content string `json:"content"`
-		} `json:"message"`
-		FinishReason string `json:"finish_reason"`
-	} `json:"choices"`
-	Usage struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
-		TotalTokens      int `json:"total_tokens"`
-	} `json:"usage"`
-}
+const (
+	// protocolNative нативный API foundationModels/v1/completion
+	protocolNative = "native"
+	// protocolOpenAI OpenAI-совместимый endpoint YandexGPT
+	protocolOpenAI = "openai"
+)
+
+// openAICompletionURL OpenAI-совместимый endpoint YandexGPT
+const openAICompletionURL = "https://llm.api.cloud.yandex.net/v1/chat/completions"
 
-func NewYandexGPTClient() (*YandexGPTClient, error) {
-	apiKey := os.Getenv("YANDEX_GPT_API_KEY")
-	if apiKey == "" {
+// NewYandexGPTClient создает клиент YandexGPT по ключу и каталогу из config.Yandex
+func NewYandexGPTClient(config AIConfig) (*YandexGPTClient, error) {
+	yandex := config.Yandex
+	if yandex.APIKey == "" {
 		return nil, fmt.Errorf("YANDEX_GPT_API_KEY не установлен")
 	}
-
-	folderID := os.Getenv("YANDEX_FOLDER_ID")
-	if folderID == "" {
+	if yandex.FolderID == "" {
 		return nil, fmt.Errorf("YANDEX_FOLDER_ID не установлен")
 	}
 
-	modelURI := fmt.Sprintf("gpt://%s/yandexgpt-lite", folderID)
-
-	return &YandexGPTClient{
-		apiKey:   apiKey,
-		folderID: folderID,
-		modelURI: modelURI,
-		baseURL:  "https://llm.api.cloud.yandex.net/v1/chat/completions",
-		httpClient: &http.Client{
-			Timeout: 120 * time.Second,
-		},
-	}, nil
-}
-
-func (c *YandexGPTClient) GeneratePost(ctx context.Context, keywords string, article ArticleInfo) (string, error) {
-	log.Printf("[AI] Генерация поста по теме: %s", keywords)
-
-	prompt := fmt.Sprintf(`Ты профессиональный копирайтер Telegram-канала "Бэкдор". Создай виральный пост в формате:
-
-⚡️ [Заголовок] — кратко, провокационно
-[Текст поста]
-
-Требования к посту:
-1. Заголовок должен быть цепляющим, выделен жирным цветом и отражать суть поста
-2. Текст: 2-3 абзаца по 2-3 предложения
-3. Выделяй *жирным* ключевые моменты и цифры
-4. Используй разговорный язык, без канцелярита
-5. Не добавляй хештеги, источник или "Новость взята с"
-6. Не отказывайся от генерации поста, если тема приемлема
-
-Пример хорошего поста:
-⚡️ Кризис ОЗУ привёл к тотальной дурке — Samsung не может купить чипы памяти у самой себя!
-
-Подразделение Samsung Galaxy не смогло заключить долгосрочный контракт с командой, поставляющей чипы HBM и LPDDR. Не помогло даже высшее руководство — *настолько быстро растут цены*.
-
-В начале года чип LPDDR5X 12 ГБ стоил *$33*, а теперь стоит целых *$70* — и цена будет только расти.
-
-Теперь создай пост на основе этой информации:
-
-ТЕМА ЗАПРОСА: %s
-ЗАГОЛОВОК НОВОСТИ: %s
-ОПИСАНИЕ НОВОСТИ: %s
-
-Создай пост, который зацепит аудиторию Telegram. Не отказывайся от генерации, если тема не нарушает этических норм.`,
-		strings.TrimSpace(keywords),
-		strings.TrimSpace(article.Title),
-		strings.TrimSpace(article.Summary))
+	defaultTier := yandex.Model
+	if _, ok := modelTiers[defaultTier]; !ok {
+		return nil, fmt.Errorf("неизвестная модель YandexGPT: %s", defaultTier)
+	}
+	log.Printf("[AI] Модель YandexGPT по умолчанию: %s", modelTiers[defaultTier].name)
 
-	response, err := c.makeRequest(ctx, prompt, 0.7, 800)
-	if err != nil {
-		return "", err
+	contextTokens := make(map[ModelTier]int, len(modelTiers))
+	for tier, model := range modelTiers {
+		contextTokens[tier] = model.contextTokens
+	}
+	for tier, tokens := range yandex.ContextTokens {
+		contextTokens[tier] = tokens
 	}
 
-	post := strings.TrimSpace(response)
+	proxyURL := httpx.ProxyURL(httpx.PurposeAI)
+	if proxyURL != nil {
+		log.Printf("[AI] Запросы к YandexGPT идут через прокси %s", proxyURL.Host)
+	}
 
-	// Убедимся, что пост начинается с эмодзи
-	if !strings.HasPrefix(post, "⚡️") && !strings.HasPrefix(post, "🔥") && !strings.HasPrefix(post, "🚨") {
-		post = "⚡️ " + post
+	// Протокол openai возвращает прежний OpenAI-совместимый endpoint
+	protocol := yandex.Protocol
+	baseURL := nativeCompletionURL
+	switch protocol {
+	case "", protocolNative:
+		protocol = protocolNative
+	case protocolOpenAI:
+		baseURL = openAICompletionURL
+	default:
+		return nil, fmt.Errorf("неизвестный YANDEX_GPT_PROTOCOL: %s (native или openai)", protocol)
 	}
+	log.Printf("[AI] Протокол YandexGPT: %s", protocol)
 
-	log.Printf("[AI] ✅ Пост сгенерирован, длина: %d символов", len(post))
-	return post, nil
+	client := &YandexGPTClient{
+		apiKey:   yandex.APIKey,
+		folderID: yandex.FolderID,
+		baseURL:  baseURL,
+		protocol: protocol,
+		httpClient: &http.Client{
+			Timeout:   config.Timeout,
+			Transport: httpx.NewTransport(proxyURL),
+		},
+		proxyURL:      proxyURL,
+		defaultTier:   defaultTier,
+		contextTokens: contextTokens,
+	}
+	client.postWriter = postWriter{completer: client, config: config}
+	return client, nil
 }
 
-func (c *YandexGPTClient) GeneratePostFromURL(ctx context.Context, title, content string) (string, error) {
-	log.Printf("[AI] Генерация поста по статье: %s", title)
-
-	prompt := fmt.Sprintf(`Ты профессиональный копирайтер Telegram-канала "Бэкдор". Создай виральный пост на основе статьи.
-
-Формат поста:
-⚡️ [Заголовок] — кратко, провокационно
-[Текст поста]
-
-Требования:
-1. Заголовок должен быть цепляющим, выделен жирным цветом
-2. Текст: 2-3 абзаца по 2-3 предложения
-3. Выделяй *жирным* ключевые моменты и цифры
-4. Используй разговорный язык, без канцелярита
-5. Не добавляй хештеги, источник или "Новость взята с"
-6. Не отказывайся от генерации поста, если тема приемлема
-7. Используй только информацию из предоставленного текста
-
-Пример хорошего поста:
-⚡️ Кризис ОЗУ привёл к тотальной дурке — Samsung не может купить чипы памяти у самой себя!
-
-Подразделение Samsung Galaxy не смогло заключить долгосрочный контракт с командой, поставляющей чипы HBM и LPDDR. Не помогло даже высшее руководство — *настолько быстро растут цены*.
-
-В начале года чип LPDDR5X 12 ГБ стоил *$33*, а теперь стоит целых *$70* — и цена будет только расти.
-
-Теперь создай пост на основе этой статьи:
-
-ЗАГОЛОВОК СТАТЬИ: %s
-СОДЕРЖАНИЕ СТАТЬИ: %s
-
-Создай пост, который зацепит аудиторию Telegram. Не отказывайся от генерации, если тема не нарушает этических норм.`,
-		strings.TrimSpace(title),
-		strings.TrimSpace(content))
-
-	response, err := c.makeRequest(ctx, prompt, 0.7, 800)
-	if err != nil {
-		return "", err
-	}
+// DefaultTier возвращает модель по умолчанию
+func (c *YandexGPTClient) DefaultTier() ModelTier {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.defaultTier
+}
 
-	post := strings.TrimSpace(response)
+// SetDefaultTier переключает модель по умолчанию
+func (c *YandexGPTClient) SetDefaultTier(tier ModelTier) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.defaultTier = tier
+	log.Printf("[AI] Модель по умолчанию переключена на %s", modelTiers[tier].name)
+}
 
-	// Убедимся, что пост начинается с эмодзи
-	if !strings.HasPrefix(post, "⚡️") && !strings.HasPrefix(post, "🔥") && !strings.HasPrefix(post, "🚨") {
-		post = "⚡️ " + post
-	}
+// ContextTokens возвращает размер контекста модели, выбранной для запроса
+func (c *YandexGPTClient) ContextTokens(ctx context.Context) int {
+	return c.contextTokens[tierFromContext(ctx, c.DefaultTier())]
+}
 
-	log.Printf("[AI] ✅ Пост по ссылке сгенерирован, длина: %d символов", len(post))
-	return post, nil
+// Complete отправляет промпт в YandexGPT и возвращает текст ответа.
+// Модель берется из контекста (WithModelTier), иначе используется модель по умолчанию.
+func (c *YandexGPTClient) Complete(ctx context.Context, prompt string, temperature float64, maxTokens int) (string, error) {
+	return c.request(ctx, []Message{{Role: "user", Content: prompt}}, temperature, maxTokens, nil)
 }
 
-func (c *YandexGPTClient) makeRequest(ctx context.Context, prompt string, temperature float64, maxTokens int) (string, error) {
-	request := ChatCompletionRequest{
-		Model: c.modelURI,
-		Messages: []Message{
-			{
-				Role:    "user",
-				Content: prompt,
-			},
-		},
-		Temperature: temperature,
-		MaxTokens:   maxTokens,
-	}
+// CompleteMessages отправляет готовый набор сообщений (system, user)
+func (c *YandexGPTClient) CompleteMessages(ctx context.Context, messages []Message, temperature float64, maxTokens int) (string, error) {
+	return c.request(ctx, messages, temperature, maxTokens, nil)
+}
 
-	jsonData, err := json.Marshal(request)
-	if err != nil {
-		log.Printf("[AI] ❌ Ошибка маршалинга запроса: %v", err)
-		return "", fmt.Errorf("ошибка маршалинга: %w", err)
-	}
+// CompleteMessagesStream как CompleteMessages, но передает накопленный текст в onText по мере генерации
+func (c *YandexGPTClient) CompleteMessagesStream(ctx context.Context, messages []Message, temperature float64, maxTokens int, onText func(string)) (string, error) {
+	return c.request(ctx, messages, temperature, maxTokens, onText)
+}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		log.Printf("[AI] ❌ Ошибка создания запроса: %v", err)
-		return "", fmt.Errorf("ошибка создания запроса: %w", err)
-	}
+// request выполняет запрос к YandexGPT; при onText != nil — в потоковом режиме
+func (c *YandexGPTClient) request(ctx context.Context, messages []Message, temperature float64, maxTokens int, onText func(string)) (string, error) {
+	tier := tierFromContext(ctx, c.DefaultTier())
+	model := modelTiers[tier]
+	modelURI := fmt.Sprintf("gpt://%s/%s", c.folderID, model.name)
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Api-Key %s", c.apiKey))
-	req.Header.Set("OpenAI-Project", c.folderID)
+	log.Printf("[AI] Отправка запроса к YandexGPT (%s)...", model.name)
 
-	log.Printf("[AI] Отправка запроса к YandexGPT...")
-	resp, err := c.httpClient.Do(req)
+	started := time.Now()
+	probe, err := breaker.allow()
 	if err != nil {
-		log.Printf("[AI] ❌ Ошибка HTTP запроса: %v", err)
-		return "", fmt.Errorf("ошибка запроса: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("[AI] ❌ Ошибка API: статус %d, тело: %s", resp.StatusCode, string(body))
-		return "", fmt.Errorf("ошибка API: статус %d", resp.StatusCode)
+		log.Printf("[AI] ⛔ Запрос не отправлен: автомат разомкнут после серии ошибок")
+		recordAudit(ctx, model.name, messages, "", Usage{}, started, err)
+		return "", err
 	}
 
-	var chatResponse ChatCompletionResponse
-	body, err := io.ReadAll(resp.Body)
+	text, usage, err := withRetry(ctx, func() (string, Usage, error) {
+		if c.protocol == protocolOpenAI {
+			headers := map[string]string{
+				"Authorization":  fmt.Sprintf("Api-Key %s", c.apiKey),
+				"OpenAI-Project": c.folderID,
+			}
+			request := ChatCompletionRequest{
+				Model:       modelURI,
+				Messages:    messages,
+				Temperature: temperature,
+				MaxTokens:   maxTokens,
+			}
+			if onText != nil {
+				return streamChatCompletion(ctx, c.httpClient, c.proxyURL, c.baseURL, headers, request, onText)
+			}
+			return postChatCompletion(ctx, c.httpClient, c.proxyURL, c.baseURL, headers, request)
+		}
+		if onText != nil {
+			return c.streamNative(ctx, modelURI, messages, temperature, maxTokens, onText)
+		}
+		return c.completeNative(ctx, modelURI, messages, temperature, maxTokens)
+	})
+	breaker.record(ctx, probe, err)
+	recordAudit(ctx, model.name, messages, text, usage, started, err)
 	if err != nil {
-		log.Printf("[AI] ❌ Ошибка чтения ответа: %v", err)
-		return "", fmt.Errorf("ошибка чтения ответа: %w", err)
-	}
-
-	if err := json.Unmarshal(body, &chatResponse); err != nil {
-		log.Printf("[AI] ❌ Ошибка парсинга: %v", err)
-		return "", fmt.Errorf("ошибка парсинга: %w", err)
-	}
-
-	if len(chatResponse.Choices) == 0 {
-		log.Printf("[AI] ❌ Пустой ответ от GPT")
-		return "", fmt.Errorf("пустой ответ от GPT")
+		return "", err
 	}
 
 	// Логируем использование токенов
-	totalTokens := chatResponse.Usage.TotalTokens
-	cost := float64(totalTokens) * 0.20 / 1000 // 20 копеек за 1000 токенов
-	log.Printf("[COST] Использовано токенов: %d (%.3f руб)", totalTokens, cost)
+	cost := float64(usage.TotalTokens) * model.pricePer1K / 1000
+	log.Printf("[COST] Использовано токенов %s: %d (вход %d, ответ %d) (%.3f руб)",
+		model.name, usage.TotalTokens, usage.InputTokens, usage.CompletionTokens, cost)
 
-	return strings.TrimSpace(chatResponse.Choices[0].Message.Content), nil
+	return strings.TrimSpace(text), nil
 }
@@ -0,0 +1,223 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// GenerationParams параметры генерации одной функции бота
+type GenerationParams struct {
+	Temperature float64
+	MaxTokens   int
+}
+
+// AIConfig настройки AI: параметры запросов, провайдер, кэш, журнал и правила проверок
+type AIConfig struct {
+	// Post пост по новости или по ссылке
+	Post GenerationParams
+	// Rewrite пост из текста пользователя
+	Rewrite GenerationParams
+	// Translate перевод поста
+	Translate GenerationParams
+	// Analysis анализ канала
+	Analysis GenerationParams
+	// Expand дополнение готового поста абзацем
+	Expand GenerationParams
+	// Answer ответ на вопрос о статье, по которой написан пост
+	Answer GenerationParams
+	// Timeout предельное время одного HTTP-запроса к модели
+	Timeout time.Duration
+
+	// Provider провайдер модели: ProviderYandex или ProviderOpenAI
+	Provider string
+	Yandex   YandexConfig
+	OpenAI   OpenAIConfig
+	// EmbeddingModel модель эмбеддингов; пустая — семантический поиск выключен
+	EmbeddingModel string
+
+	Cache   CacheConfig
+	Breaker BreakerConfig
+	Audit   AuditConfig
+
+	// PromptsDir каталог, файлы которого переопределяют встроенные шаблоны промптов
+	PromptsDir string
+	// ModerationRulesFile файл правил проверки темы вместо встроенных
+	ModerationRulesFile string
+	// ModerationAICheck проверять тему запросом к lite-модели
+	ModerationAICheck bool
+	// BrandSafetyFile файл стоп-слов вместо встроенного списка
+	BrandSafetyFile string
+	// BrandSafetyMode реакция на нарушение: SafetyModeRegenerate, SafetyModeWarn или SafetyModeOff
+	BrandSafetyMode string
+	// BrandSafetyAICheck проверять готовый пост запросом к модели
+	BrandSafetyAICheck bool
+}
+
+// YandexConfig доступ к YandexGPT, YandexART и эмбеддингам Yandex
+type YandexConfig struct {
+	APIKey   string
+	FolderID string
+	// Model модель по умолчанию
+	Model ModelTier
+	// ContextTokens размер контекста моделей
+	ContextTokens map[ModelTier]int
+	// Protocol протокол запросов: native или openai
+	Protocol string
+	// ArtURL, OperationsURL и EmbeddingURL endpoints YandexART, операций и эмбеддингов
+	ArtURL        string
+	OperationsURL string
+	EmbeddingURL  string
+}
+
+// OpenAIConfig доступ к OpenAI-совместимому серверу
+type OpenAIConfig struct {
+	BaseURL string
+	// APIKey необязателен: локальные серверы обычно работают без авторизации
+	APIKey        string
+	Model         string
+	ContextTokens int
+}
+
+// CacheConfig кэш сгенерированных постов
+type CacheConfig struct {
+	// Size сколько постов хранить; 0 выключает кэш
+	Size int
+	TTL  time.Duration
+	// ChargeUser списывать генерацию за пост из кэша
+	ChargeUser bool
+}
+
+// BreakerConfig автомат, блокирующий запросы после серии ошибок
+type BreakerConfig struct {
+	Threshold int
+	Cooldown  time.Duration
+}
+
+// AuditConfig журнал запросов к модели
+type AuditConfig struct {
+	Enabled   bool
+	Dir       string
+	MaxSizeMB int
+	KeepDays  int
+	// Responses записывать тексты ответов
+	Responses bool
+}
+
+// DefaultAIConfig возвращает параметры по умолчанию
+func DefaultAIConfig() AIConfig {
+	contextTokens := make(map[ModelTier]int, len(modelTiers))
+	for tier, model := range modelTiers {
+		contextTokens[tier] = model.contextTokens
+	}
+
+	return AIConfig{
+		Post:      GenerationParams{Temperature: 0.7, MaxTokens: 800},
+		Rewrite:   GenerationParams{Temperature: 0.7, MaxTokens: 800},
+		Translate: GenerationParams{Temperature: 0.3, MaxTokens: 1600},
+		Analysis:  GenerationParams{Temperature: 0.2, MaxTokens: 1000},
+		Expand:    GenerationParams{Temperature: 0.5, MaxTokens: 1200},
+		Answer:    GenerationParams{Temperature: 0.2, MaxTokens: 400},
+		Timeout:   120 * time.Second,
+
+		Provider: ProviderYandex,
+		Yandex: YandexConfig{
+			Model:         TierLite,
+			ContextTokens: contextTokens,
+			Protocol:      protocolNative,
+			ArtURL:        imageGenerationURL,
+			OperationsURL: imageOperationURL,
+			EmbeddingURL:  yandexEmbeddingURL,
+		},
+		OpenAI: OpenAIConfig{ContextTokens: defaultContextTokens},
+
+		Cache:   CacheConfig{Size: defaultPostCacheSize, TTL: defaultPostCacheTTL, ChargeUser: true},
+		Breaker: BreakerConfig{Threshold: defaultBreakerThreshold, Cooldown: defaultBreakerCooldown},
+		Audit: AuditConfig{
+			Enabled:   true,
+			Dir:       ".",
+			MaxSizeMB: defaultAuditMaxSizeMB,
+			KeepDays:  defaultAuditKeepDays,
+			Responses: true,
+		},
+
+		ModerationAICheck: true,
+		BrandSafetyMode:   SafetyModeRegenerate,
+	}
+}
+
+var (
+	configured   = DefaultAIConfig()
+	configuredMu sync.RWMutex
+)
+
+// currentConfig возвращает настройки, заданные Configure
+func currentConfig() AIConfig {
+	configuredMu.RLock()
+	defer configuredMu.RUnlock()
+	return configured
+}
+
+// Configure применяет настройки к общим для всех клиентов кэшу, автомату и журналу
+// и перечитывает шаблоны промптов и правила проверок. Вызывается при запуске до
+// создания клиентов.
+func Configure(config AIConfig) error {
+	configuredMu.Lock()
+	configured = config
+	configuredMu.Unlock()
+
+	posts = newPostCache(config.Cache.Size, config.Cache.TTL)
+
+	listener := breaker.listener()
+	breaker = newCircuitBreaker(config.Breaker.Threshold, config.Breaker.Cooldown, time.Now)
+	SetBreakerListener(listener)
+
+	audit = nil
+	if config.Audit.Enabled {
+		// Ключи не пишутся в журнал, но могут оказаться в тексте ошибки API
+		var secrets []string
+		for _, secret := range []string{config.Yandex.APIKey, config.OpenAI.APIKey} {
+			if len(secret) >= 8 {
+				secrets = append(secrets, secret)
+			}
+		}
+		audit = newAuditLogger(config.Audit.Dir, int64(config.Audit.MaxSizeMB)<<20, config.Audit.KeepDays,
+			config.Audit.Responses, secrets, time.Now)
+	}
+
+	var errs []error
+	for _, reload := range []func() error{ReloadPrompts, ReloadModerationRules, ReloadBrandSafetyRules} {
+		if err := reload(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("ошибка настройки AI: %w", errors.Join(errs...))
+	}
+
+	log.Printf("[AI] Настройки применены: провайдер %s, кэш %d постов, журнал %t",
+		config.Provider, config.Cache.Size, config.Audit.Enabled)
+	return nil
+}
+
+// params возвращает параметры генерации для типа запроса из контекста
+func (w postWriter) params(ctx context.Context) GenerationParams {
+	requestType, _ := ctx.Value(requestTypeKey{}).(string)
+	switch requestType {
+	case RequestRewrite:
+		return w.config.Rewrite
+	case RequestTranslate:
+		return w.config.Translate
+	case RequestAnalysis:
+		return w.config.Analysis
+	case RequestExpand:
+		return w.config.Expand
+	case RequestAnswer:
+		return w.config.Answer
+	default:
+		return w.config.Post
+	}
+}
@@ -0,0 +1,292 @@
+package ai
+
+import (
+	"AIGenerator/internal/httpx"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// yandexEmbeddingURL endpoint эмбеддингов Yandex Foundation Models
+	yandexEmbeddingURL = "https://llm.api.cloud.yandex.net/foundationModels/v1/textEmbedding"
+	// yandexEmbeddingModel модели text-search-doc и text-search-query
+	yandexEmbeddingModel = "text-search"
+	// yandexEmbeddingConcurrency сколько текстов эмбеддится одновременно: API YandexGPT
+	// принимает по одному тексту в запросе
+	yandexEmbeddingConcurrency = 4
+	// openAIEmbeddingBatch сколько текстов отправляется в одном запросе /embeddings
+	openAIEmbeddingBatch = 32
+	// maxEmbeddingTextLength до скольких символов сокращается текст перед эмбеддингом
+	maxEmbeddingTextLength = 2000
+	// embeddingTimeout предельное время одного запроса эмбеддингов
+	embeddingTimeout = 30 * time.Second
+)
+
+// Embedder строит векторы текстов для семантического поиска.
+// Документы и запросы могут кодироваться разными моделями.
+type Embedder interface {
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+}
+
+// NewEmbedder создает клиент эмбеддингов провайдера config.Provider.
+// Модель задается config.EmbeddingModel: для YandexGPT — text-search, для OpenAI-совместимого
+// сервера — имя модели. Без модели возвращает nil: семантический поиск выключен.
+func NewEmbedder(config AIConfig) (Embedder, error) {
+	model := strings.TrimSpace(config.EmbeddingModel)
+	if model == "" {
+		return nil, nil
+	}
+
+	proxyURL := httpx.ProxyURL(httpx.PurposeAI)
+	httpClient := &http.Client{
+		Timeout:   embeddingTimeout,
+		Transport: httpx.NewTransport(proxyURL),
+	}
+
+	switch provider := config.Provider; provider {
+	case ProviderYandex:
+		if model != yandexEmbeddingModel {
+			return nil, fmt.Errorf("EMBEDDING_MODEL: для YandexGPT поддерживается только %s", yandexEmbeddingModel)
+		}
+		if config.Yandex.APIKey == "" || config.Yandex.FolderID == "" {
+			return nil, fmt.Errorf("YANDEX_GPT_API_KEY и YANDEX_FOLDER_ID не установлены")
+		}
+		endpoint := yandexEmbeddingURL
+		if config.Yandex.EmbeddingURL != "" {
+			endpoint = config.Yandex.EmbeddingURL
+		}
+		return &yandexEmbedder{
+			apiKey:     config.Yandex.APIKey,
+			folderID:   config.Yandex.FolderID,
+			endpoint:   endpoint,
+			httpClient: httpClient,
+			proxyURL:   proxyURL,
+		}, nil
+	case ProviderOpenAI:
+		baseURL := strings.TrimSpace(config.OpenAI.BaseURL)
+		if baseURL == "" {
+			return nil, fmt.Errorf("AI_BASE_URL не установлен")
+		}
+		endpoint := strings.TrimSuffix(strings.TrimRight(baseURL, "/"), "/chat/completions") + "/embeddings"
+		return &openAIEmbedder{
+			endpoint:   endpoint,
+			apiKey:     config.OpenAI.APIKey,
+			model:      model,
+			httpClient: httpClient,
+			proxyURL:   proxyURL,
+		}, nil
+	default:
+		return nil, fmt.Errorf("неизвестный AI_PROVIDER: %s (yandex или openai)", provider)
+	}
+}
+
+// embeddingText сокращает текст до ограничения модели эмбеддингов
+func embeddingText(text string) string {
+	return truncateRunes(strings.TrimSpace(text), maxEmbeddingTextLength)
+}
+
+// postJSON отправляет JSON-запрос и разбирает JSON-ответ
+func postJSON(ctx context.Context, client *http.Client, proxyURL *url.URL, endpoint string, headers map[string]string, request, response any) error {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return newNetworkError(ctx, httpx.WrapProxyError(err, proxyURL))
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[EMBED] ❌ Ошибка API: статус %d, тело: %s", resp.StatusCode, string(body))
+		return newStatusError(resp, "")
+	}
+
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("ошибка парсинга: %w", err)
+	}
+	return nil
+}
+
+// yandexEmbedder эмбеддинги text-search-doc / text-search-query YandexGPT
+type yandexEmbedder struct {
+	apiKey     string
+	folderID   string
+	endpoint   string
+	httpClient *http.Client
+	proxyURL   *url.URL
+}
+
+// yandexEmbeddingRequest запрос к textEmbedding
+type yandexEmbeddingRequest struct {
+	ModelURI string `json:"modelUri"`
+	Text     string `json:"text"`
+}
+
+// yandexEmbeddingResponse ответ textEmbedding
+type yandexEmbeddingResponse struct {
+	Embedding []float32 `json:"embedding"`
+	NumTokens string    `json:"numTokens"`
+}
+
+// EmbedDocuments строит векторы статей моделью text-search-doc.
+// API принимает один текст за запрос, поэтому тексты отправляются параллельно.
+func (e *yandexEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	errs := make([]error, len(texts))
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, yandexEmbeddingConcurrency)
+	for i, text := range texts {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			vectors[i], errs[i] = e.embed(ctx, "text-search-doc", text)
+		}()
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("[EMBED] Построено %d векторов статей", len(texts))
+	return vectors, nil
+}
+
+// EmbedQuery строит вектор запроса моделью text-search-query
+func (e *yandexEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	return e.embed(ctx, "text-search-query", text)
+}
+
+func (e *yandexEmbedder) embed(ctx context.Context, model, text string) ([]float32, error) {
+	headers := map[string]string{
+		"Authorization": fmt.Sprintf("Api-Key %s", e.apiKey),
+		"x-folder-id":   e.folderID,
+	}
+	request := yandexEmbeddingRequest{
+		ModelURI: fmt.Sprintf("emb://%s/%s/latest", e.folderID, model),
+		Text:     embeddingText(text),
+	}
+
+	var response yandexEmbeddingResponse
+	if err := postJSON(ctx, e.httpClient, e.proxyURL, e.endpoint, headers, request, &response); err != nil {
+		return nil, err
+	}
+	if len(response.Embedding) == 0 {
+		return nil, fmt.Errorf("пустой вектор в ответе")
+	}
+	return response.Embedding, nil
+}
+
+// openAIEmbedder эмбеддинги OpenAI-совместимого сервера (/embeddings)
+type openAIEmbedder struct {
+	endpoint   string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+	proxyURL   *url.URL
+}
+
+// openAIEmbeddingRequest запрос в формате OpenAI Embeddings
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+// openAIEmbeddingResponse ответ в формате OpenAI Embeddings
+type openAIEmbeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// EmbedDocuments строит векторы статей пачками по openAIEmbeddingBatch
+func (e *openAIEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	tokens := 0
+	for start := 0; start < len(texts); start += openAIEmbeddingBatch {
+		batch, batchTokens, err := e.embed(ctx, texts[start:min(start+openAIEmbeddingBatch, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+		tokens += batchTokens
+	}
+	log.Printf("[EMBED] Построено %d векторов статей, токенов: %d", len(texts), tokens)
+	return vectors, nil
+}
+
+// EmbedQuery строит вектор запроса той же моделью, что и статьи
+func (e *openAIEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	vectors, _, err := e.embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+func (e *openAIEmbedder) embed(ctx context.Context, texts []string) ([][]float32, int, error) {
+	headers := map[string]string{}
+	if e.apiKey != "" {
+		headers["Authorization"] = "Bearer " + e.apiKey
+	}
+	request := openAIEmbeddingRequest{Model: e.model, Input: make([]string, len(texts))}
+	for i, text := range texts {
+		request.Input[i] = embeddingText(text)
+	}
+
+	var response openAIEmbeddingResponse
+	if err := postJSON(ctx, e.httpClient, e.proxyURL, e.endpoint, headers, request, &response); err != nil {
+		return nil, 0, err
+	}
+	if len(response.Data) != len(texts) {
+		return nil, 0, fmt.Errorf("получено %d векторов вместо %d", len(response.Data), len(texts))
+	}
+
+	// Порядок в ответе не гарантирован, сопоставляем по index
+	vectors := make([][]float32, len(texts))
+	for _, item := range response.Data {
+		if item.Index < 0 || item.Index >= len(texts) || len(item.Embedding) == 0 {
+			return nil, 0, fmt.Errorf("некорректный вектор с индексом %d", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, 0, fmt.Errorf("нет вектора для текста %d", i)
+		}
+	}
+	return vectors, response.Usage.TotalTokens, nil
+}
@@ -0,0 +1,332 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	// ProviderYandex YandexGPT (по умолчанию)
+	ProviderYandex = "yandex"
+	// ProviderOpenAI любой OpenAI-совместимый сервер
+	ProviderOpenAI = "openai"
+)
+
+// TextGenerator генератор текстов, от которого зависит бот.
+// Реализации: YandexGPTClient и OpenAICompatibleClient.
+type TextGenerator interface {
+	GeneratePost(ctx context.Context, keywords string, article ArticleInfo) (Post, error)
+	GeneratePostFromURL(ctx context.Context, title, content string) (Post, error)
+	GeneratePostStream(ctx context.Context, keywords string, article ArticleInfo, partial chan<- string) (Post, error)
+	GeneratePostFromURLStream(ctx context.Context, title, content string, partial chan<- string) (Post, error)
+	RewriteAsPost(ctx context.Context, text string) (Post, error)
+	ExpandPost(ctx context.Context, post Post, source string) (Post, error)
+	AnswerQuestion(ctx context.Context, question string, article SourceArticle) (string, error)
+	GenerateHeadlines(ctx context.Context, text string) ([]Headline, error)
+	TranslatePost(ctx context.Context, text string, language Language) (string, error)
+	RerankArticles(ctx context.Context, query string, candidates []ArticleInfo) (Rerank, error)
+	AnalyzeChannel(ctx context.Context, title, description string, messages []string) (string, error)
+	CheckTopic(ctx context.Context, keywords string) TopicCheck
+	IsRefusal(ctx context.Context, text string) bool
+	Ping(ctx context.Context) error
+	Complete(ctx context.Context, prompt string, temperature float64, maxTokens int) (string, error)
+}
+
+// NewTextGenerator создает клиент провайдера config.Provider
+func NewTextGenerator(config AIConfig) (TextGenerator, error) {
+	switch provider := config.Provider; provider {
+	case ProviderYandex:
+		client, err := NewYandexGPTClient(config)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	case ProviderOpenAI:
+		client, err := NewOpenAICompatibleClient(config)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	default:
+		return nil, fmt.Errorf("неизвестный AI_PROVIDER: %s (yandex или openai)", provider)
+	}
+}
+
+// completer низкоуровневый запрос к модели
+type completer interface {
+	CompleteMessages(ctx context.Context, messages []Message, temperature float64, maxTokens int) (string, error)
+}
+
+// postWriter общая логика генерации постов, не зависящая от провайдера
+type postWriter struct {
+	completer completer
+	config    AIConfig
+}
+
+// Ping проверяет, что модель отвечает, минимальным запросом к lite-модели
+func (w postWriter) Ping(ctx context.Context) error {
+	ctx = withRequestType(WithModelTier(ctx, TierLite), RequestPing)
+	if _, err := w.completer.CompleteMessages(ctx, []Message{{Role: "user", Content: "ping"}}, 0, 1); err != nil {
+		return fmt.Errorf("модель не отвечает: %w", err)
+	}
+	return nil
+}
+
+// postPromptData данные для шаблона поста по новости
+type postPromptData struct {
+	Keywords string
+	Title    string
+	Summary  string
+	Language Language
+	Example  string
+	// InlineSource под постом будет ссылка на источник: текст должен быть короче
+	InlineSource bool
+}
+
+// urlPostPromptData данные для шаблона поста по статье с сайта
+type urlPostPromptData struct {
+	Title        string
+	Content      string
+	Language     Language
+	Example      string
+	InlineSource bool
+}
+
+// rewritePromptData данные для шаблона поста из текста пользователя
+type rewritePromptData struct {
+	Text     string
+	Language Language
+	Example  string
+}
+
+// expandPromptData данные для шаблона дополнения поста
+type expandPromptData struct {
+	Title    string
+	Body     string
+	Source   string
+	Language Language
+}
+
+// translatePromptData данные для шаблона перевода поста
+type translatePromptData struct {
+	Text     string
+	Language Language
+}
+
+// postLanguage возвращает язык поста из контекста и пример поста на этом языке
+func postLanguage(ctx context.Context) (Language, string, error) {
+	language := LanguageFromContext(ctx)
+	example, err := renderPrompt(promptPostExample+language.Code, nil)
+	if err != nil {
+		return Language{}, "", err
+	}
+	return language, example, nil
+}
+
+func (w postWriter) GeneratePost(ctx context.Context, keywords string, article ArticleInfo) (Post, error) {
+	return w.GeneratePostStream(ctx, keywords, article, nil)
+}
+
+// GeneratePostStream генерирует пост, отправляя в partial накопленный текст по мере генерации.
+// partial закрывается по завершении; если провайдер не поддерживает потоковую генерацию,
+// промежуточных значений не будет. partial может быть nil.
+func (w postWriter) GeneratePostStream(ctx context.Context, keywords string, article ArticleInfo, partial chan<- string) (Post, error) {
+	log.Printf("[AI] Генерация поста по теме: %s", keywords)
+	ctx = withRequestType(ctx, RequestPost)
+
+	var messages []Message
+	language, example, err := postLanguage(ctx)
+	if err == nil {
+		messages, err = w.fitPrompt(ctx, strings.TrimSpace(article.Summary), keywords, func(summary string) ([]Message, error) {
+			return postPromptMessages(ctx, postPromptData{
+				Keywords:     strings.TrimSpace(keywords),
+				Title:        strings.TrimSpace(article.Title),
+				Summary:      summary,
+				Language:     language,
+				Example:      example,
+				InlineSource: inlineSourceFromContext(ctx),
+			})
+		})
+	}
+	if err != nil {
+		if partial != nil {
+			close(partial)
+		}
+		return Post{}, err
+	}
+
+	post, err := w.generatePost(ctx, messages, partial)
+	if err != nil {
+		return Post{}, err
+	}
+
+	log.Printf("[AI] ✅ Пост сгенерирован, длина: %d символов", len(post.Text()))
+	return post, nil
+}
+
+func (w postWriter) GeneratePostFromURL(ctx context.Context, title, content string) (Post, error) {
+	return w.GeneratePostFromURLStream(ctx, title, content, nil)
+}
+
+// GeneratePostFromURLStream потоковый вариант GeneratePostFromURL
+func (w postWriter) GeneratePostFromURLStream(ctx context.Context, title, content string, partial chan<- string) (Post, error) {
+	log.Printf("[AI] Генерация поста по статье: %s", title)
+	ctx = withRequestType(ctx, RequestURLPost)
+
+	var messages []Message
+	language, example, err := postLanguage(ctx)
+	if err == nil {
+		// Ключевых слов у ссылки нет, поэтому при сокращении сохраняем абзацы со словами заголовка
+		messages, err = w.fitPrompt(ctx, strings.TrimSpace(content), title, func(content string) ([]Message, error) {
+			return promptMessages(promptURLPostSystem, promptURLPostUser, urlPostPromptData{
+				Title:        strings.TrimSpace(title),
+				Content:      content,
+				Language:     language,
+				Example:      example,
+				InlineSource: inlineSourceFromContext(ctx),
+			})
+		})
+	}
+	if err != nil {
+		if partial != nil {
+			close(partial)
+		}
+		return Post{}, err
+	}
+
+	post, err := w.generatePost(ctx, messages, partial)
+	if err != nil {
+		return Post{}, err
+	}
+
+	log.Printf("[AI] ✅ Пост по ссылке сгенерирован, длина: %d символов", len(post.Text()))
+	return post, nil
+}
+
+// RewriteAsPost переписывает текст пользователя в пост по тем же правилам, что и GeneratePost
+func (w postWriter) RewriteAsPost(ctx context.Context, text string) (Post, error) {
+	log.Printf("[AI] Рерайт текста пользователя, длина: %d символов", len(text))
+	ctx = withRequestType(ctx, RequestRewrite)
+
+	var messages []Message
+	language, example, err := postLanguage(ctx)
+	if err == nil {
+		messages, err = w.fitPrompt(ctx, strings.TrimSpace(text), "", func(text string) ([]Message, error) {
+			return promptMessages(promptRewriteSystem, promptRewriteUser, rewritePromptData{
+				Text:     text,
+				Language: language,
+				Example:  example,
+			})
+		})
+	}
+	if err != nil {
+		return Post{}, err
+	}
+
+	post, err := w.generatePost(ctx, messages, nil)
+	if err != nil {
+		return Post{}, err
+	}
+
+	log.Printf("[AI] ✅ Пост из текста сгенерирован, длина: %d символов", len(post.Text()))
+	return post, nil
+}
+
+// ExpandPost добавляет в готовый пост абзац с подробностями из исходной статьи source
+func (w postWriter) ExpandPost(ctx context.Context, post Post, source string) (Post, error) {
+	log.Printf("[AI] Расширение поста: %s", post.Title)
+	ctx = withRequestType(ctx, RequestExpand)
+
+	language := LanguageFromContext(ctx)
+	messages, err := w.fitPrompt(ctx, strings.TrimSpace(source), post.Title, func(source string) ([]Message, error) {
+		return promptMessages(promptExpandSystem, promptExpandUser, expandPromptData{
+			Title:    strings.TrimSpace(post.Title),
+			Body:     strings.TrimSpace(post.Body),
+			Source:   source,
+			Language: language,
+		})
+	})
+	if err != nil {
+		return Post{}, err
+	}
+
+	expanded, err := w.generatePost(ctx, messages, nil)
+	if err != nil {
+		return Post{}, err
+	}
+
+	// Заголовок и хештеги остаются прежними, даже если модель их опустила
+	if expanded.Title == "" {
+		expanded.Title = post.Title
+	}
+	if len(expanded.Hashtags) == 0 {
+		expanded.Hashtags = post.Hashtags
+	}
+	expanded.Structured = expanded.Structured || post.Structured
+
+	log.Printf("[AI] ✅ Пост расширен: %d → %d символов", len(post.Text()), len(expanded.Text()))
+	return expanded, nil
+}
+
+// TranslatePost переводит готовый пост на другой язык, сохраняя разметку Markdown
+func (w postWriter) TranslatePost(ctx context.Context, text string, language Language) (string, error) {
+	log.Printf("[AI] Перевод поста на язык: %s", language.Code)
+
+	messages, err := promptMessages(promptTranslateSystem, promptTranslateUser, translatePromptData{
+		Text:     strings.TrimSpace(text),
+		Language: language,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	ctx = withRequestType(ctx, RequestTranslate)
+	params := w.params(ctx)
+	translation, err := w.completer.CompleteMessages(ctx, messages, params.Temperature, params.MaxTokens)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(translation), nil
+}
+
+// finishPost приводит ответ модели к формату поста
+func finishPost(response string) string {
+	post := strings.TrimSpace(response)
+
+	// Убедимся, что пост начинается с эмодзи
+	if !strings.HasPrefix(post, "⚡️") && !strings.HasPrefix(post, "🔥") && !strings.HasPrefix(post, "🚨") {
+		post = "⚡️ " + post
+	}
+	return post
+}
+
+// complete выполняет запрос; если задан partial — в потоковом режиме, когда провайдер
+// его поддерживает. Закрывает partial по завершении.
+func (w postWriter) complete(ctx context.Context, messages []Message, partial chan<- string) (string, error) {
+	params := w.params(ctx)
+	if partial == nil {
+		return w.completer.CompleteMessages(ctx, messages, params.Temperature, params.MaxTokens)
+	}
+	defer close(partial)
+
+	streamer, ok := w.completer.(streamCompleter)
+	if !ok {
+		return w.completer.CompleteMessages(ctx, messages, params.Temperature, params.MaxTokens)
+	}
+
+	response, err := streamer.CompleteMessagesStream(ctx, messages, params.Temperature, params.MaxTokens, func(text string) {
+		// Промежуточный текст накопительный, поэтому медленный получатель может пропускать значения
+		select {
+		case partial <- postPreview(text):
+		default:
+		}
+	})
+	if errors.Is(err, ErrStreamingUnsupported) {
+		log.Printf("[AI] ⚠️ Провайдер не поддерживает потоковую генерацию, используем обычный запрос")
+		return w.completer.CompleteMessages(ctx, messages, params.Temperature, params.MaxTokens)
+	}
+	return response, err
+}
@@ -0,0 +1,234 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeModel отвечает на запросы обоих протоколов текстом из reply и запоминает сообщения
+type fakeModel struct {
+	mu       sync.Mutex
+	requests [][]Message
+	reply    func(messages []Message) string
+	status   int
+}
+
+func (m *fakeModel) answer(w http.ResponseWriter, messages []Message) (string, bool) {
+	m.mu.Lock()
+	m.requests = append(m.requests, messages)
+	m.mu.Unlock()
+
+	if m.status != 0 {
+		w.WriteHeader(m.status)
+		fmt.Fprint(w, `{"error": {"message": "fake error"}}`)
+		return "", false
+	}
+	return m.reply(messages), true
+}
+
+func (m *fakeModel) lastRequest() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.requests) == 0 {
+		return nil
+	}
+	return m.requests[len(m.requests)-1]
+}
+
+// serveNative отдает ответы в формате foundationModels/v1/completion
+func (m *fakeModel) serveNative(w http.ResponseWriter, r *http.Request) {
+	var request NativeCompletionRequest
+	json.NewDecoder(r.Body).Decode(&request)
+	var messages []Message
+	for _, message := range request.Messages {
+		messages = append(messages, Message{Role: message.Role, Content: message.Text})
+	}
+
+	text, ok := m.answer(w, messages)
+	if !ok {
+		return
+	}
+	var response NativeCompletionResponse
+	response.Result.Alternatives = append(response.Result.Alternatives, struct {
+		Message NativeMessage `json:"message"`
+		Status  string        `json:"status"`
+	}{Message: NativeMessage{Role: "assistant", Text: text}, Status: "ALTERNATIVE_STATUS_FINAL"})
+	response.Result.Usage.TotalTokens = "10"
+	json.NewEncoder(w).Encode(response)
+}
+
+// serveOpenAI отдает ответы в формате OpenAI Chat Completions
+func (m *fakeModel) serveOpenAI(w http.ResponseWriter, r *http.Request) {
+	var request ChatCompletionRequest
+	json.NewDecoder(r.Body).Decode(&request)
+
+	text, ok := m.answer(w, request.Messages)
+	if !ok {
+		return
+	}
+	data, _ := json.Marshal(text)
+	fmt.Fprintf(w, `{"choices": [{"message": {"role": "assistant", "content": %s}}], "usage": {"total_tokens": 10}}`, data)
+}
+
+// contractGenerator реализация TextGenerator с фальшивым сервером
+type contractGenerator struct {
+	name      string
+	generator TextGenerator
+}
+
+// contractGenerators создает клиенты YandexGPT (оба протокола) и OpenAI-совместимый,
+// которые обращаются к model. Кэш постов выключается, чтобы каждый клиент делал запрос.
+func contractGenerators(t *testing.T, model *fakeModel) []contractGenerator {
+	t.Helper()
+	saved := posts
+	posts = newPostCache(0, 0)
+	t.Cleanup(func() { posts = saved })
+
+	native := httptest.NewServer(http.HandlerFunc(model.serveNative))
+	t.Cleanup(native.Close)
+	shim := httptest.NewServer(http.HandlerFunc(model.serveOpenAI))
+	t.Cleanup(shim.Close)
+
+	config := DefaultAIConfig()
+	config.OpenAI.BaseURL = shim.URL + "/v1"
+	config.OpenAI.Model = "llama3"
+	openAI, err := NewOpenAICompatibleClient(config)
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleClient: %v", err)
+	}
+
+	return []contractGenerator{
+		{"yandex native", newTestYandexClient(t, native.URL, protocolNative)},
+		{"yandex openai", newTestYandexClient(t, shim.URL, protocolOpenAI)},
+		{"openai-compatible", openAI},
+	}
+}
+
+func TestContractComplete(t *testing.T) {
+	model := &fakeModel{reply: func([]Message) string { return "  pong\n" }}
+	for _, tt := range contractGenerators(t, model) {
+		t.Run(tt.name, func(t *testing.T) {
+			text, err := tt.generator.Complete(context.Background(), "ping", 0, 5)
+			if err != nil {
+				t.Fatalf("Complete: %v", err)
+			}
+			if text != "pong" {
+				t.Errorf("Complete = %q, ожидалось %q", text, "pong")
+			}
+			if request := model.lastRequest(); len(request) != 1 || request[0].Role != "user" || request[0].Content != "ping" {
+				t.Errorf("запрос = %+v", request)
+			}
+			if err := tt.generator.Ping(context.Background()); err != nil {
+				t.Errorf("Ping: %v", err)
+			}
+		})
+	}
+}
+
+func TestContractGeneratePost(t *testing.T) {
+	model := &fakeModel{reply: func([]Message) string {
+		return `{"title": "Центробанк сохранил ставку", "body_markdown": "Регулятор оставил ставку без изменений.", "hashtags": ["ЦБ", "ставка"], "refused": false, "refusal_reason": ""}`
+	}}
+	article := ArticleInfo{Title: "Банк России сохранил ключевую ставку", Summary: "Совет директоров принял решение."}
+
+	for _, tt := range contractGenerators(t, model) {
+		t.Run(tt.name, func(t *testing.T) {
+			post, err := tt.generator.GeneratePost(context.Background(), "ключевая ставка", article)
+			if err != nil {
+				t.Fatalf("GeneratePost: %v", err)
+			}
+			if !post.Structured || post.Title != "Центробанк сохранил ставку" || post.HashtagLine() != "#цб #ставка" {
+				t.Errorf("пост = %+v", post)
+			}
+
+			request := model.lastRequest()
+			if len(request) != 2 || request[0].Role != "system" || request[1].Role != "user" {
+				t.Fatalf("запрос = %+v", request)
+			}
+			// Данные статьи передаются только в сообщении пользователя
+			if !strings.Contains(request[1].Content, article.Title) || strings.Contains(request[0].Content, article.Title) {
+				t.Errorf("статья не в сообщении пользователя: %+v", request)
+			}
+		})
+	}
+}
+
+func TestContractAnalyzeChannel(t *testing.T) {
+	model := &fakeModel{reply: func([]Message) string {
+		return "```json\n{\"main_topic\": \"технологии\", \"keywords\": [\"ИИ\"]}\n```"
+	}}
+	for _, tt := range contractGenerators(t, model) {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := tt.generator.AnalyzeChannel(context.Background(), "Технологии", "Новости ИИ", []string{"Вышла новая модель"})
+			if err != nil {
+				t.Fatalf("AnalyzeChannel: %v", err)
+			}
+			if result != `{"main_topic": "технологии", "keywords": ["ИИ"]}` {
+				t.Errorf("AnalyzeChannel = %q", result)
+			}
+		})
+	}
+}
+
+func TestContractStatusError(t *testing.T) {
+	model := &fakeModel{status: http.StatusUnauthorized}
+	for _, tt := range contractGenerators(t, model) {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.generator.Complete(context.Background(), "ping", 0, 5)
+			var aiErr *AIError
+			if !errors.As(err, &aiErr) {
+				t.Fatalf("ошибка %v не является AIError", err)
+			}
+			if aiErr.StatusCode != http.StatusUnauthorized || IsRetryable(err) {
+				t.Errorf("AIError = %+v", aiErr)
+			}
+		})
+	}
+}
+
+func TestNewTextGeneratorSelectsProvider(t *testing.T) {
+	config := DefaultAIConfig()
+	config.Yandex.APIKey = "key"
+	config.Yandex.FolderID = "folder"
+	config.OpenAI.BaseURL = "http://localhost:11434/v1/"
+	config.OpenAI.Model = "llama3"
+
+	config.Provider = ProviderYandex
+	if generator, err := NewTextGenerator(config); err != nil {
+		t.Errorf("yandex: %v", err)
+	} else if _, ok := generator.(*YandexGPTClient); !ok {
+		t.Errorf("yandex: %T", generator)
+	}
+
+	config.Provider = ProviderOpenAI
+	generator, err := NewTextGenerator(config)
+	if err != nil {
+		t.Fatalf("openai: %v", err)
+	}
+	client, ok := generator.(*OpenAICompatibleClient)
+	if !ok {
+		t.Fatalf("openai: %T", generator)
+	}
+	// Путь /chat/completions дописывается к AI_BASE_URL
+	if client.endpoint != "http://localhost:11434/v1/chat/completions" {
+		t.Errorf("endpoint = %q", client.endpoint)
+	}
+
+	config.Provider = "gigachat"
+	if _, err := NewTextGenerator(config); err == nil {
+		t.Error("неизвестный провайдер принят")
+	}
+
+	config.Provider = ProviderOpenAI
+	config.OpenAI.Model = ""
+	if _, err := NewTextGenerator(config); err == nil {
+		t.Error("OpenAI-совместимый клиент создан без AI_MODEL")
+	}
+}
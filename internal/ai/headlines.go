@@ -0,0 +1,127 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+)
+
+// HeadlineStyles стили вариантов заголовка в порядке, в котором их возвращает модель
+var HeadlineStyles = []string{"провокационный", "вопрос", "цифры", "интрига", "прямой"}
+
+// maxHeadlineLength максимальная длина заголовка в символах
+const maxHeadlineLength = 150
+
+// headlineAttempts сколько раз запрашивать заголовки, если ответ не прошел проверку
+const headlineAttempts = 2
+
+// Headline вариант заголовка поста
+type Headline struct {
+	Style string
+	Text  string
+}
+
+// headlinesPromptData данные для шаблона вариантов заголовка
+type headlinesPromptData struct {
+	Text     string
+	Styles   []string
+	Language Language
+}
+
+// headlineNumber номер в начале строки списка: "1.", "1)", "1 -"
+var headlineNumber = regexp.MustCompile(`^\s*(\d+)\s*[.)\-:]\s*`)
+
+// GenerateHeadlines придумывает по одному заголовку каждого стиля из HeadlineStyles.
+// Если модель вернула не столько вариантов или повторы, запрос повторяется один раз.
+func (w postWriter) GenerateHeadlines(ctx context.Context, text string) ([]Headline, error) {
+	log.Printf("[AI] Варианты заголовка для текста из %d символов", len(text))
+	ctx = withRequestType(ctx, RequestHeadlines)
+
+	messages, err := promptMessages(promptHeadlinesSystem, promptHeadlinesUser, headlinesPromptData{
+		Text:     strings.TrimSpace(text),
+		Styles:   HeadlineStyles,
+		Language: LanguageFromContext(ctx),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	params := w.params(ctx)
+	var lastErr error
+	for attempt := 1; attempt <= headlineAttempts; attempt++ {
+		response, err := w.completer.CompleteMessages(ctx, messages, params.Temperature, params.MaxTokens)
+		if err != nil {
+			return nil, err
+		}
+
+		headlines, err := parseHeadlines(response, len(HeadlineStyles))
+		if err == nil {
+			log.Printf("[AI] ✅ Получено %d вариантов заголовка", len(headlines))
+			return headlines, nil
+		}
+		log.Printf("[AI] ⚠️ Некорректные варианты заголовка (попытка %d): %v", attempt, err)
+		lastErr = err
+	}
+	return nil, fmt.Errorf("модель не вернула %d разных заголовков: %w", len(HeadlineStyles), lastErr)
+}
+
+// parseHeadlines разбирает пронумерованный список заголовков и проверяет, что их ровно
+// count и все они разные. Стиль определяется по позиции в списке.
+func parseHeadlines(response string, count int) ([]Headline, error) {
+	var lines []string
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "```") {
+			continue
+		}
+		match := headlineNumber.FindStringSubmatch(line)
+		if match == nil {
+			// Пояснения до и после списка пропускаем
+			continue
+		}
+		line = cleanHeadline(line[len(match[0]):])
+		if line == "" {
+			return nil, fmt.Errorf("пустой заголовок под номером %s", match[1])
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) != count {
+		return nil, fmt.Errorf("получено %d заголовков вместо %d", len(lines), count)
+	}
+
+	seen := make(map[string]bool, count)
+	headlines := make([]Headline, count)
+	for i, line := range lines {
+		key := strings.ToLower(strings.Join(strings.Fields(line), " "))
+		if seen[key] {
+			return nil, fmt.Errorf("заголовок повторяется: %s", line)
+		}
+		seen[key] = true
+
+		style := ""
+		if i < len(HeadlineStyles) {
+			style = HeadlineStyles[i]
+		}
+		headlines[i] = Headline{Style: style, Text: truncateRunes(line, maxHeadlineLength)}
+	}
+	return headlines, nil
+}
+
+// cleanHeadline убирает разметку, кавычки и подпись стиля, которые модель иногда добавляет
+func cleanHeadline(line string) string {
+	line = strings.Trim(line, "*_` ")
+	for _, style := range HeadlineStyles {
+		for _, prefix := range []string{"[" + style + "]", "(" + style + ")", style + ":"} {
+			if len(line) >= len(prefix) && strings.EqualFold(line[:len(prefix)], prefix) {
+				line = strings.TrimSpace(line[len(prefix):])
+			}
+		}
+	}
+	line = strings.Trim(line, "\"«»“”„ ")
+	// Заголовки показываются в `code`, поэтому обратные кавычки внутри недопустимы
+	line = strings.ReplaceAll(line, "`", "'")
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,110 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const validHeadlines = `Вот варианты:
+1. Банк России удивил всех
+2) Почему ЦБ не снизил ставку?
+3 - 21% уже третий месяц подряд
+4: Что скрывает решение регулятора
+5. ЦБ сохранил ключевую ставку на уровне 21%`
+
+func TestParseHeadlines(t *testing.T) {
+	headlines, err := parseHeadlines(validHeadlines, 5)
+	if err != nil {
+		t.Fatalf("parseHeadlines: %v", err)
+	}
+	want := []Headline{
+		{"провокационный", "Банк России удивил всех"},
+		{"вопрос", "Почему ЦБ не снизил ставку?"},
+		{"цифры", "21% уже третий месяц подряд"},
+		{"интрига", "Что скрывает решение регулятора"},
+		{"прямой", "ЦБ сохранил ключевую ставку на уровне 21%"},
+	}
+	for i, headline := range headlines {
+		if headline != want[i] {
+			t.Errorf("заголовок %d = %+v, ожидалось %+v", i+1, headline, want[i])
+		}
+	}
+}
+
+func TestParseHeadlinesCleansMarkup(t *testing.T) {
+	response := "```\n" +
+		"1. **Провокационный: «Банк России удивил всех»**\n" +
+		"2. [вопрос] \"Почему ЦБ не снизил ставку?\"\n" +
+		"3. (Цифры) `21%` третий месяц\n" +
+		"4. _Что скрывает регулятор_\n" +
+		"5. прямой: ЦБ сохранил ставку\n" +
+		"```\nВыберите подходящий."
+
+	headlines, err := parseHeadlines(response, 5)
+	if err != nil {
+		t.Fatalf("parseHeadlines: %v", err)
+	}
+	want := []string{"Банк России удивил всех", "Почему ЦБ не снизил ставку?", "'21%' третий месяц", "Что скрывает регулятор", "ЦБ сохранил ставку"}
+	for i, headline := range headlines {
+		if headline.Text != want[i] {
+			t.Errorf("заголовок %d = %q, ожидалось %q", i+1, headline.Text, want[i])
+		}
+	}
+}
+
+func TestParseHeadlinesRejects(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{"четыре варианта", "1. А\n2. Б\n3. В\n4. Г"},
+		{"шесть вариантов", "1. А\n2. Б\n3. В\n4. Г\n5. Д\n6. Е"},
+		{"повтор", "1. Ставка\n2. Б\n3. В\n4. Г\n5. ставка"},
+		{"повтор с другими пробелами", "1. ЦБ  сохранил ставку\n2. Б\n3. В\n4. Г\n5. ЦБ сохранил ставку"},
+		{"пустой вариант", "1. А\n2. **\n3. В\n4. Г\n5. Д"},
+		{"без нумерации", "А\nБ\nВ\nГ\nД"},
+		{"пустой ответ", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if headlines, err := parseHeadlines(tt.response, 5); err == nil {
+				t.Errorf("ответ принят: %+v", headlines)
+			}
+		})
+	}
+}
+
+func TestParseHeadlinesTruncatesLong(t *testing.T) {
+	long := strings.Repeat("д", maxHeadlineLength+20)
+	headlines, err := parseHeadlines("1. "+long+"\n2. Б\n3. В\n4. Г\n5. Д", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := []rune(headlines[0].Text); len(got) != maxHeadlineLength+1 || got[len(got)-1] != '…' {
+		t.Errorf("длинный заголовок: %d символов", len(got))
+	}
+}
+
+func TestGenerateHeadlinesRetriesOnce(t *testing.T) {
+	writer, completer := newScriptedWriter("1. А\n2. Б\n3. В", validHeadlines)
+	headlines, err := writer.GenerateHeadlines(context.Background(), "Текст поста о ставке")
+	if err != nil {
+		t.Fatalf("GenerateHeadlines: %v", err)
+	}
+	if len(headlines) != 5 || completer.calls() != 2 {
+		t.Errorf("заголовков %d, запросов %d", len(headlines), completer.calls())
+	}
+	if !strings.Contains(completer.requests[0][1].Content, "Текст поста о ставке") {
+		t.Errorf("текст не передан: %q", completer.requests[0][1].Content)
+	}
+
+	writer, completer = newScriptedWriter("1. А", "1. А\n2. А\n3. В\n4. Г\n5. Д", validHeadlines)
+	if _, err := writer.GenerateHeadlines(context.Background(), "Текст"); err == nil {
+		t.Error("принят ответ после двух неудачных попыток")
+	}
+	if completer.calls() != headlineAttempts {
+		t.Errorf("запросов %d, ожидалось %d", completer.calls(), headlineAttempts)
+	}
+}
@@ -0,0 +1,215 @@
+package ai
+
+import (
+	"AIGenerator/internal/httpx"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// imageGenerationURL асинхронный endpoint YandexART
+	imageGenerationURL = "https://llm.api.cloud.yandex.net/foundationModels/v1/imageGenerationAsync"
+	// imageOperationURL endpoint статуса асинхронных операций
+	imageOperationURL = "https://llm.api.cloud.yandex.net/operations/"
+	// imageGenerationTimeout сколько ждем готовности картинки
+	imageGenerationTimeout = 60 * time.Second
+	// imagePollInterval интервал опроса операции
+	imagePollInterval = 3 * time.Second
+	// imagePrice стоимость одной картинки YandexART, руб
+	imagePrice = 2.20
+	// maxImagePromptLength ограничение длины промпта картинки
+	maxImagePromptLength = 500
+)
+
+// ImageClient генерирует иллюстрации через YandexART
+type ImageClient struct {
+	apiKey       string
+	folderID     string
+	generateURL  string
+	operationURL string
+	httpClient   *http.Client
+	proxyURL     *url.URL
+	// pollInterval интервал опроса операции; в тестах короче
+	pollInterval time.Duration
+}
+
+// imageGenerationRequest запрос к imageGenerationAsync
+type imageGenerationRequest struct {
+	ModelURI          string `json:"modelUri"`
+	GenerationOptions struct {
+		Seed        string `json:"seed"`
+		AspectRatio struct {
+			WidthRatio  string `json:"widthRatio"`
+			HeightRatio string `json:"heightRatio"`
+		} `json:"aspectRatio"`
+	} `json:"generationOptions"`
+	Messages []imagePromptMessage `json:"messages"`
+}
+
+// imagePromptMessage текстовое описание картинки
+type imagePromptMessage struct {
+	Weight string `json:"weight"`
+	Text   string `json:"text"`
+}
+
+// imageOperation асинхронная операция YandexART
+type imageOperation struct {
+	ID    string `json:"id"`
+	Done  bool   `json:"done"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Response struct {
+		Image string `json:"image"`
+	} `json:"response"`
+}
+
+// NewImageClient создает клиент YandexART с ключом и каталогом YandexGPT из config.Yandex
+func NewImageClient(config AIConfig) (*ImageClient, error) {
+	yandex := config.Yandex
+	if yandex.APIKey == "" {
+		return nil, fmt.Errorf("YANDEX_GPT_API_KEY не установлен")
+	}
+	if yandex.FolderID == "" {
+		return nil, fmt.Errorf("YANDEX_FOLDER_ID не установлен")
+	}
+
+	generateURL := imageGenerationURL
+	if yandex.ArtURL != "" {
+		generateURL = yandex.ArtURL
+	}
+	operationURL := imageOperationURL
+	if yandex.OperationsURL != "" {
+		operationURL = strings.TrimRight(yandex.OperationsURL, "/") + "/"
+	}
+
+	proxyURL := httpx.ProxyURL(httpx.PurposeAI)
+	return &ImageClient{
+		apiKey:       yandex.APIKey,
+		folderID:     yandex.FolderID,
+		generateURL:  generateURL,
+		operationURL: operationURL,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: httpx.NewTransport(proxyURL),
+		},
+		proxyURL:     proxyURL,
+		pollInterval: imagePollInterval,
+	}, nil
+}
+
+// ImagePrompt строит короткое описание иллюстрации по заголовку поста
+func ImagePrompt(title string) string {
+	title = strings.TrimSpace(title)
+	if runes := []rune(title); len(runes) > maxImagePromptLength {
+		title = string(runes[:maxImagePromptLength])
+	}
+	return fmt.Sprintf("Иллюстрация к новости: %s. Цифровая иллюстрация, без текста и надписей", title)
+}
+
+// GenerateImage запускает генерацию и ждет готовности картинки не дольше imageGenerationTimeout.
+// Возвращает изображение в формате JPEG.
+func (c *ImageClient) GenerateImage(ctx context.Context, prompt string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, imageGenerationTimeout)
+	defer cancel()
+
+	log.Printf("[IMAGE] Запуск генерации картинки: %s", prompt)
+
+	request := imageGenerationRequest{
+		ModelURI: fmt.Sprintf("art://%s/yandex-art/latest", c.folderID),
+		Messages: []imagePromptMessage{{Weight: "1", Text: prompt}},
+	}
+	request.GenerationOptions.Seed = fmt.Sprint(time.Now().UnixNano() % 1000000)
+	request.GenerationOptions.AspectRatio.WidthRatio = "16"
+	request.GenerationOptions.AspectRatio.HeightRatio = "9"
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка маршалинга: %w", err)
+	}
+
+	operation, err := c.do(ctx, "POST", c.generateURL, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка запуска генерации картинки: %w", err)
+	}
+
+	ticker := time.NewTicker(c.pollInterval)
+	defer ticker.Stop()
+
+	for !operation.Done {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("картинка не готова за %v: %w", imageGenerationTimeout, ctx.Err())
+		case <-ticker.C:
+		}
+
+		operation, err = c.do(ctx, "GET", c.operationURL+operation.ID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка опроса операции: %w", err)
+		}
+	}
+
+	if operation.Error != nil {
+		return nil, fmt.Errorf("ошибка генерации картинки: %s", operation.Error.Message)
+	}
+
+	image, err := base64.StdEncoding.DecodeString(operation.Response.Image)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка декодирования картинки: %w", err)
+	}
+	if len(image) == 0 {
+		return nil, fmt.Errorf("пустая картинка в ответе")
+	}
+
+	log.Printf("[COST] Сгенерирована картинка YandexART (%.2f руб)", imagePrice)
+	return image, nil
+}
+
+// do выполняет запрос к API и разбирает операцию из ответа
+func (c *ImageClient) do(ctx context.Context, method, endpoint string, body io.Reader) (imageOperation, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return imageOperation{}, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", fmt.Sprintf("Api-Key %s", c.apiKey))
+	req.Header.Set("x-folder-id", c.folderID)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return imageOperation{}, newNetworkError(ctx, httpx.WrapProxyError(err, c.proxyURL))
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return imageOperation{}, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[IMAGE] ❌ Ошибка API: статус %d, тело: %s", resp.StatusCode, string(data))
+		var apiError nativeErrorResponse
+		json.Unmarshal(data, &apiError)
+		return imageOperation{}, newStatusError(resp, apiError.Error.Message)
+	}
+
+	var operation imageOperation
+	if err := json.Unmarshal(data, &operation); err != nil {
+		return imageOperation{}, fmt.Errorf("ошибка парсинга: %w", err)
+	}
+	if operation.ID == "" && !operation.Done {
+		return imageOperation{}, fmt.Errorf("в ответе нет идентификатора операции")
+	}
+	return operation, nil
+}
@@ -0,0 +1,174 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeArtServer имитирует YandexART: операция готова после polls опросов
+type fakeArtServer struct {
+	polls    int32
+	image    []byte
+	failWith string
+
+	started atomic.Int32
+	polled  atomic.Int32
+	request imageGenerationRequest
+}
+
+func (s *fakeArtServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Api-Key test-key" || r.Header.Get("x-folder-id") != "b1gtest" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		s.started.Add(1)
+		json.NewDecoder(r.Body).Decode(&s.request)
+		fmt.Fprint(w, s.operation(s.polls == 0))
+		return
+	}
+	if r.URL.Path != "/operations/op-1" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	fmt.Fprint(w, s.operation(s.polled.Add(1) >= s.polls))
+}
+
+func (s *fakeArtServer) operation(done bool) string {
+	if !done {
+		return `{"id": "op-1", "done": false}`
+	}
+	if s.failWith != "" {
+		return fmt.Sprintf(`{"id": "op-1", "done": true, "error": {"code": 3, "message": %q}}`, s.failWith)
+	}
+	return fmt.Sprintf(`{"id": "op-1", "done": true, "response": {"image": %q}}`, base64.StdEncoding.EncodeToString(s.image))
+}
+
+// newTestImageClient создает клиент YandexART, обращающийся к fake
+func newTestImageClient(t *testing.T, fake http.Handler) *ImageClient {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	config := DefaultAIConfig()
+	config.Yandex.APIKey = "test-key"
+	config.Yandex.FolderID = "b1gtest"
+	config.Yandex.ArtURL = server.URL + "/imageGenerationAsync"
+	config.Yandex.OperationsURL = server.URL + "/operations"
+
+	client, err := NewImageClient(config)
+	if err != nil {
+		t.Fatalf("NewImageClient: %v", err)
+	}
+	client.pollInterval = 10 * time.Millisecond
+	return client
+}
+
+func TestGenerateImagePollsOperation(t *testing.T) {
+	fake := &fakeArtServer{polls: 3, image: []byte("\xff\xd8jpeg")}
+	client := newTestImageClient(t, fake)
+
+	image, err := client.GenerateImage(context.Background(), ImagePrompt("Запуск спутника"))
+	if err != nil {
+		t.Fatalf("GenerateImage: %v", err)
+	}
+	if !bytes.Equal(image, fake.image) {
+		t.Errorf("картинка = %q", image)
+	}
+	if fake.started.Load() != 1 || fake.polled.Load() != 3 {
+		t.Errorf("запусков %d, опросов %d", fake.started.Load(), fake.polled.Load())
+	}
+	if fake.request.ModelURI != "art://b1gtest/yandex-art/latest" {
+		t.Errorf("modelUri = %q", fake.request.ModelURI)
+	}
+	if len(fake.request.Messages) != 1 || !strings.Contains(fake.request.Messages[0].Text, "Запуск спутника") {
+		t.Errorf("промпт = %+v", fake.request.Messages)
+	}
+}
+
+func TestGenerateImageReadyImmediately(t *testing.T) {
+	fake := &fakeArtServer{image: []byte("jpeg")}
+	if _, err := newTestImageClient(t, fake).GenerateImage(context.Background(), "кот"); err != nil {
+		t.Fatalf("GenerateImage: %v", err)
+	}
+	if fake.polled.Load() != 0 {
+		t.Errorf("готовая операция опрошена %d раз", fake.polled.Load())
+	}
+}
+
+func TestGenerateImageFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		fake *fakeArtServer
+	}{
+		{"ошибка операции", &fakeArtServer{polls: 1, failWith: "prompt is not allowed"}},
+		{"пустая картинка", &fakeArtServer{polls: 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newTestImageClient(t, tt.fake).GenerateImage(context.Background(), "кот"); err == nil {
+				t.Error("ожидалась ошибка")
+			}
+		})
+	}
+
+	badImage := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"id": "op-1", "done": true, "response": {"image": "не base64"}}`)
+	})
+	if _, err := newTestImageClient(t, badImage).GenerateImage(context.Background(), "кот"); err == nil {
+		t.Error("некорректный base64 принят")
+	}
+
+	noID := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"done": false}`)
+	})
+	if _, err := newTestImageClient(t, noID).GenerateImage(context.Background(), "кот"); err == nil {
+		t.Error("операция без идентификатора принята")
+	}
+
+	overloaded := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error": {"message": "too many requests"}}`)
+	})
+	_, err := newTestImageClient(t, overloaded).GenerateImage(context.Background(), "кот")
+	if !IsRetryable(err) || !strings.Contains(err.Error(), "too many requests") {
+		t.Errorf("ошибка перегрузки = %v", err)
+	}
+}
+
+func TestGenerateImageStopsOnDeadline(t *testing.T) {
+	fake := &fakeArtServer{polls: 1 << 20}
+	client := newTestImageClient(t, fake)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	if _, err := client.GenerateImage(ctx, "кот"); err == nil {
+		t.Fatal("незавершенная операция вернула картинку")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("ожидание длилось %v после дедлайна", elapsed)
+	}
+}
+
+func TestImagePrompt(t *testing.T) {
+	prompt := ImagePrompt("  " + strings.Repeat("ж", maxImagePromptLength+50) + "  ")
+	if strings.Contains(prompt, strings.Repeat("ж", maxImagePromptLength+1)) || !strings.Contains(prompt, strings.Repeat("ж", maxImagePromptLength)) {
+		t.Error("заголовок не обрезан до maxImagePromptLength")
+	}
+	if !strings.HasPrefix(ImagePrompt(" Запуск "), "Иллюстрация к новости: Запуск.") {
+		t.Errorf("ImagePrompt = %q", ImagePrompt(" Запуск "))
+	}
+}
@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Language язык, на котором пишется пост
+type Language struct {
+	// Code код языка: ru, en, kk
+	Code string
+	// Name название языка для промпта («на <Name> языке»)
+	Name string
+	// Title название языка для пользователя
+	Title string
+	// DefaultHashtags хештеги, если модель их не вернула
+	DefaultHashtags []string
+}
+
+// DefaultLanguage язык постов по умолчанию
+const DefaultLanguage = "ru"
+
+var languages = []Language{
+	{Code: "ru", Name: "русском", Title: "🇷🇺 Русский", DefaultHashtags: []string{"новости", "интересное"}},
+	{Code: "en", Name: "английском", Title: "🇬🇧 English", DefaultHashtags: []string{"news", "trending"}},
+	{Code: "kk", Name: "казахском", Title: "🇰🇿 Қазақша", DefaultHashtags: []string{"жаңалықтар", "қызықты"}},
+}
+
+// Languages возвращает поддерживаемые языки
+func Languages() []Language {
+	return languages
+}
+
+// ParseLanguage находит язык по коду; пустой код означает язык по умолчанию
+func ParseLanguage(code string) (Language, error) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if code == "" {
+		code = DefaultLanguage
+	}
+	for _, language := range languages {
+		if language.Code == code {
+			return language, nil
+		}
+	}
+
+	codes := make([]string, 0, len(languages))
+	for _, language := range languages {
+		codes = append(codes, language.Code)
+	}
+	return Language{}, fmt.Errorf("неизвестный язык: %s (%s)", code, strings.Join(codes, ", "))
+}
+
+// LanguageOrDefault возвращает язык по коду, а для неизвестного кода — язык по умолчанию
+func LanguageOrDefault(code string) Language {
+	language, err := ParseLanguage(code)
+	if err != nil {
+		language, _ = ParseLanguage(DefaultLanguage)
+	}
+	return language
+}
+
+type languageKey struct{}
+
+// WithLanguage задает язык постов для запросов с этим контекстом
+func WithLanguage(ctx context.Context, language Language) context.Context {
+	return context.WithValue(ctx, languageKey{}, language)
+}
+
+// LanguageFromContext возвращает язык из контекста или язык по умолчанию
+func LanguageFromContext(ctx context.Context) Language {
+	if language, ok := ctx.Value(languageKey{}).(Language); ok {
+		return language
+	}
+	return LanguageOrDefault(DefaultLanguage)
+}
+
+type inlineSourceKey struct{}
+
+// WithInlineSource сообщает, что под постом будет строка со ссылкой на источник,
+// и промпт просит текст покороче
+func WithInlineSource(ctx context.Context) context.Context {
+	return context.WithValue(ctx, inlineSourceKey{}, true)
+}
+
+// inlineSourceFromContext будет ли под постом ссылка на источник
+func inlineSourceFromContext(ctx context.Context) bool {
+	inline, _ := ctx.Value(inlineSourceKey{}).(bool)
+	return inline
+}
@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPostPromptPerLanguage(t *testing.T) {
+	saved := posts
+	posts = newPostCache(0, 0)
+	t.Cleanup(func() { posts = saved })
+
+	for _, language := range Languages() {
+		t.Run(language.Code, func(t *testing.T) {
+			example, err := renderPrompt(promptPostExample+language.Code, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			writer, completer := newScriptedWriter(string(readFixture(t, "post_valid.json")))
+			ctx := WithLanguage(context.Background(), language)
+			if _, err := writer.GeneratePost(ctx, "ставка", ArticleInfo{Title: "ЦБ", Summary: "Решение"}); err != nil {
+				t.Fatal(err)
+			}
+
+			system := completer.requests[0][0].Content
+			if !strings.Contains(system, "на "+language.Name+" языке") {
+				t.Errorf("в системном промпте нет языка %s", language.Name)
+			}
+			if !strings.Contains(system, example) {
+				t.Errorf("в системном промпте нет примера поста на языке %s", language.Code)
+			}
+			for _, other := range Languages() {
+				if other.Code == language.Code {
+					continue
+				}
+				otherExample, _ := renderPrompt(promptPostExample+other.Code, nil)
+				if strings.Contains(system, otherExample) {
+					t.Errorf("в промпте пример на языке %s", other.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestTranslatePrompt(t *testing.T) {
+	writer, completer := newScriptedWriter("  *Heading*\n\nText #news  ")
+	english := LanguageOrDefault("en")
+
+	translation, err := writer.TranslatePost(context.Background(), "*Заголовок*\n\nТекст #новости", english)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if translation != "*Heading*\n\nText #news" {
+		t.Errorf("перевод = %q", translation)
+	}
+
+	request := completer.requests[0]
+	if !strings.Contains(request[0].Content, "на английском язык") {
+		t.Errorf("системный промпт перевода: %q", request[0].Content)
+	}
+	if !strings.Contains(request[1].Content, "*Заголовок*\n\nТекст #новости") {
+		t.Errorf("пост не передан без изменений: %q", request[1].Content)
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	for input, want := range map[string]string{"": "ru", "EN": "en", " kk ": "kk", "ru": "ru"} {
+		language, err := ParseLanguage(input)
+		if err != nil || language.Code != want {
+			t.Errorf("ParseLanguage(%q) = %q, %v", input, language.Code, err)
+		}
+	}
+	if _, err := ParseLanguage("de"); err == nil || !strings.Contains(err.Error(), "ru, en, kk") {
+		t.Errorf("ParseLanguage(de): %v", err)
+	}
+	if got := LanguageOrDefault("de"); got.Code != DefaultLanguage {
+		t.Errorf("LanguageOrDefault(de) = %q", got.Code)
+	}
+	if got := LanguageFromContext(context.Background()); got.Code != DefaultLanguage {
+		t.Errorf("язык без контекста = %q", got.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ModelTier уровень модели YandexGPT: lite дешевле, pro качественнее на длинных текстах
+type ModelTier string
+
+const (
+	TierLite ModelTier = "lite"
+	TierPro  ModelTier = "pro"
+)
+
+// modelInfo имя модели, цена в рублях за 1000 токенов и размер контекста по умолчанию
+type modelInfo struct {
+	name          string
+	pricePer1K    float64
+	contextTokens int
+}
+
+var modelTiers = map[ModelTier]modelInfo{
+	TierLite: {name: "yandexgpt-lite", pricePer1K: 0.20, contextTokens: 8000},
+	TierPro:  {name: "yandexgpt", pricePer1K: 1.20, contextTokens: 32000},
+}
+
+// modelPrice цена модели в рублях за 1000 токенов по имени; 0 для неизвестной модели
+func modelPrice(name string) float64 {
+	for _, info := range modelTiers {
+		if info.name == name {
+			return info.pricePer1K
+		}
+	}
+	return 0
+}
+
+// ParseModelTier разбирает уровень модели из строки (lite или pro)
+func ParseModelTier(value string) (ModelTier, error) {
+	tier := ModelTier(strings.ToLower(strings.TrimSpace(value)))
+	if _, ok := modelTiers[tier]; !ok {
+		return "", fmt.Errorf("неизвестная модель: %s (lite или pro)", value)
+	}
+	return tier, nil
+}
+
+// TierSelector клиент, у которого можно переключить модель по умолчанию
+type TierSelector interface {
+	DefaultTier() ModelTier
+	SetDefaultTier(tier ModelTier)
+}
+
+type modelTierKey struct{}
+
+// WithModelTier переопределяет модель для запросов с этим контекстом.
+// Используется для функций, которым нужна полная модель.
+func WithModelTier(ctx context.Context, tier ModelTier) context.Context {
+	return context.WithValue(ctx, modelTierKey{}, tier)
+}
+
+// tierFromContext возвращает модель из контекста или fallback
+func tierFromContext(ctx context.Context, fallback ModelTier) ModelTier {
+	if tier, ok := ctx.Value(modelTierKey{}).(ModelTier); ok {
+		return tier
+	}
+	return fallback
+}
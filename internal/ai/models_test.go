@@ -0,0 +1,114 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// modelURIServer запоминает modelUri (нативный протокол) или model (OpenAI) последнего запроса
+func modelURIServer(t *testing.T, uris *[]string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ModelURI string `json:"modelUri"`
+			Model    string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.ModelURI != "" {
+			*uris = append(*uris, request.ModelURI)
+			w.Write([]byte(`{"result": {"alternatives": [{"message": {"role": "assistant", "text": "ok"}}]}}`))
+			return
+		}
+		*uris = append(*uris, request.Model)
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "ok"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestModelURIPerRequest(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultTier ModelTier
+		ctx         context.Context
+		want        string
+	}{
+		{"lite по умолчанию", TierLite, context.Background(), "gpt://b1gtest/yandexgpt-lite"},
+		{"pro по умолчанию", TierPro, context.Background(), "gpt://b1gtest/yandexgpt"},
+		{"pro для премиум-функции", TierLite, WithModelTier(context.Background(), TierPro), "gpt://b1gtest/yandexgpt"},
+		{"lite для пинга при pro по умолчанию", TierPro, WithModelTier(context.Background(), TierLite), "gpt://b1gtest/yandexgpt-lite"},
+	}
+
+	for _, protocol := range []string{protocolNative, protocolOpenAI} {
+		for _, tt := range tests {
+			t.Run(protocol+"/"+tt.name, func(t *testing.T) {
+				var uris []string
+				client := newTestYandexClient(t, modelURIServer(t, &uris).URL, protocol)
+				client.SetDefaultTier(tt.defaultTier)
+
+				if _, err := client.Complete(tt.ctx, "тест", 0.5, 10); err != nil {
+					t.Fatalf("Complete: %v", err)
+				}
+				if len(uris) != 1 || uris[0] != tt.want {
+					t.Errorf("modelUri = %v, ожидалось %s", uris, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestPingUsesLiteModel(t *testing.T) {
+	var uris []string
+	client := newTestYandexClient(t, modelURIServer(t, &uris).URL, protocolNative)
+	client.SetDefaultTier(TierPro)
+
+	if err := client.Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+	if len(uris) != 1 || uris[0] != "gpt://b1gtest/yandexgpt-lite" {
+		t.Errorf("modelUri = %v", uris)
+	}
+}
+
+func TestContextTokensFollowTier(t *testing.T) {
+	config := DefaultAIConfig()
+	config.Yandex.APIKey = "key"
+	config.Yandex.FolderID = "folder"
+	config.Yandex.ContextTokens = map[ModelTier]int{TierPro: 16000}
+
+	client, err := NewYandexGPTClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := client.ContextTokens(context.Background()); got != modelTiers[TierLite].contextTokens {
+		t.Errorf("контекст lite = %d", got)
+	}
+	if got := client.ContextTokens(WithModelTier(context.Background(), TierPro)); got != 16000 {
+		t.Errorf("контекст pro = %d, ожидалось 16000 из настроек", got)
+	}
+}
+
+func TestParseModelTier(t *testing.T) {
+	for input, want := range map[string]ModelTier{"lite": TierLite, " PRO ": TierPro, "Lite": TierLite} {
+		if got, err := ParseModelTier(input); err != nil || got != want {
+			t.Errorf("ParseModelTier(%q) = %q, %v", input, got, err)
+		}
+	}
+	for _, input := range []string{"", "max", "yandexgpt"} {
+		if _, err := ParseModelTier(input); err == nil {
+			t.Errorf("ParseModelTier(%q) без ошибки", input)
+		}
+	}
+}
+
+func TestModelPrice(t *testing.T) {
+	if lite, pro := modelPrice("yandexgpt-lite"), modelPrice("yandexgpt"); lite <= 0 || pro <= lite {
+		t.Errorf("цены: lite %v, pro %v", lite, pro)
+	}
+	if got := modelPrice("llama3"); got != 0 {
+		t.Errorf("цена неизвестной модели = %v", got)
+	}
+}
@@ -0,0 +1,155 @@
+package ai
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+//go:embed moderation_rules.json
+var defaultModerationRules []byte
+
+// TopicCheck результат проверки темы перед генерацией
+type TopicCheck struct {
+	Allowed  bool   `json:"allowed"`
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+}
+
+// ModerationRule локальное правило: тема отклоняется, если содержит один из шаблонов
+type ModerationRule struct {
+	Category string   `json:"category"`
+	Reason   string   `json:"reason"`
+	Patterns []string `json:"patterns"`
+}
+
+// moderationPromptData данные для шаблона проверки темы
+type moderationPromptData struct {
+	Keywords string
+}
+
+var (
+	moderationRules   []ModerationRule
+	moderationRulesMu sync.RWMutex
+)
+
+func init() {
+	if err := ReloadModerationRules(); err != nil {
+		log.Printf("[AI] ❌ Ошибка загрузки правил модерации: %v", err)
+	}
+}
+
+// ReloadModerationRules загружает правила модерации из файла ModerationRulesFile
+// или встроенные правила. При ошибке остаются ранее загруженные правила.
+func ReloadModerationRules() error {
+	data := defaultModerationRules
+	source := "встроенные"
+	if path := currentConfig().ModerationRulesFile; path != "" {
+		fileData, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения правил модерации %s: %w", path, err)
+		}
+		data, source = fileData, path
+	}
+
+	var rules []ModerationRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("ошибка разбора правил модерации (%s): %w", source, err)
+	}
+
+	moderationRulesMu.Lock()
+	moderationRules = rules
+	moderationRulesMu.Unlock()
+
+	log.Printf("[AI] ✅ Загружено %d правил модерации (%s)", len(rules), source)
+	return nil
+}
+
+// ModerationCategories возвращает категории загруженных правил модерации без повторов,
+// в порядке правил: их перечисляют тексты /start и /help
+func ModerationCategories() []string {
+	moderationRulesMu.RLock()
+	defer moderationRulesMu.RUnlock()
+
+	var categories []string
+	for _, rule := range moderationRules {
+		if rule.Category != "" && !slices.Contains(categories, rule.Category) {
+			categories = append(categories, rule.Category)
+		}
+	}
+	return categories
+}
+
+// checkTopicRules проверяет тему по локальным правилам
+func checkTopicRules(keywords string) TopicCheck {
+	topic := strings.ToLower(keywords)
+
+	moderationRulesMu.RLock()
+	defer moderationRulesMu.RUnlock()
+
+	for _, rule := range moderationRules {
+		for _, pattern := range rule.Patterns {
+			if pattern != "" && strings.Contains(topic, strings.ToLower(pattern)) {
+				return TopicCheck{Allowed: false, Category: rule.Category, Reason: rule.Reason}
+			}
+		}
+	}
+	return TopicCheck{Allowed: true}
+}
+
+// aiModerationEnabled проверяет, включена ли проверка темы через AI
+func aiModerationEnabled() bool {
+	return currentConfig().ModerationAICheck
+}
+
+// CheckTopic проверяет тему до поиска новостей: сначала по локальным правилам,
+// затем, если не отключено, коротким запросом к lite-модели.
+// При ошибке AI тема считается разрешенной, чтобы сбой модерации не блокировал генерацию.
+func (w postWriter) CheckTopic(ctx context.Context, keywords string) TopicCheck {
+	if check := checkTopicRules(keywords); !check.Allowed {
+		log.Printf("[AI] ⛔ Тема отклонена правилом (%s): %s", check.Category, keywords)
+		return check
+	}
+
+	if !aiModerationEnabled() {
+		return TopicCheck{Allowed: true}
+	}
+
+	messages, err := promptMessages(promptModerationSystem, promptModerationUser, moderationPromptData{
+		Keywords: strings.TrimSpace(keywords),
+	})
+	if err != nil {
+		log.Printf("[AI] ⚠️ Проверка темы пропущена: %v", err)
+		return TopicCheck{Allowed: true}
+	}
+
+	response, err := w.completer.CompleteMessages(withRequestType(WithModelTier(ctx, TierLite), RequestTopicCheck), messages, 0, 100)
+	if err != nil {
+		log.Printf("[AI] ⚠️ Ошибка проверки темы, пропускаем: %v", err)
+		return TopicCheck{Allowed: true}
+	}
+
+	text, err := extractJSONObject(response)
+	var check TopicCheck
+	if err == nil {
+		err = json.Unmarshal([]byte(text), &check)
+	}
+	if err != nil {
+		log.Printf("[AI] ⚠️ Некорректный ответ проверки темы (%v): %s", err, response)
+		return TopicCheck{Allowed: true}
+	}
+
+	if !check.Allowed {
+		if check.Reason == "" {
+			check.Reason = "Тема не подходит для генерации поста"
+		}
+		log.Printf("[AI] ⛔ Тема отклонена моделью (%s): %s", check.Category, keywords)
+	}
+	return check
+}
@@ -0,0 +1,161 @@
+package ai
+
+import (
+	"AIGenerator/internal/httpx"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// nativeCompletionURL endpoint нативного API YandexGPT
+const nativeCompletionURL = "https://llm.api.cloud.yandex.net/foundationModels/v1/completion"
+
+// nativeContentFilterStatus статус альтернативы, заблокированной фильтром контента
+const nativeContentFilterStatus = "ALTERNATIVE_STATUS_CONTENT_FILTER"
+
+// NativeMessage сообщение в формате foundationModels
+type NativeMessage struct {
+	Role string `json:"role"`
+	Text string `json:"text"`
+}
+
+// NativeCompletionRequest запрос к foundationModels/v1/completion
+type NativeCompletionRequest struct {
+	ModelURI          string `json:"modelUri"`
+	CompletionOptions struct {
+		Stream      bool    `json:"stream"`
+		Temperature float64 `json:"temperature"`
+		MaxTokens   string  `json:"maxTokens"`
+	} `json:"completionOptions"`
+	Messages []NativeMessage `json:"messages"`
+}
+
+// NativeCompletionResponse ответ foundationModels/v1/completion.
+// Количество токенов API возвращает строками.
+type NativeCompletionResponse struct {
+	Result struct {
+		Alternatives []struct {
+			Message NativeMessage `json:"message"`
+			Status  string        `json:"status"`
+		} `json:"alternatives"`
+		Usage struct {
+			InputTextTokens  string `json:"inputTextTokens"`
+			CompletionTokens string `json:"completionTokens"`
+			TotalTokens      string `json:"totalTokens"`
+		} `json:"usage"`
+		ModelVersion string `json:"modelVersion"`
+	} `json:"result"`
+}
+
+// nativeErrorResponse тело ответа нативного API с ошибкой
+type nativeErrorResponse struct {
+	Error struct {
+		GRPCCode int    `json:"grpcCode"`
+		HTTPCode int    `json:"httpCode"`
+		Message  string `json:"message"`
+	} `json:"error"`
+}
+
+// completeNative отправляет запрос в нативный API foundationModels
+func (c *YandexGPTClient) completeNative(ctx context.Context, modelURI string, messages []Message, temperature float64, maxTokens int) (string, Usage, error) {
+	request := newNativeRequest(modelURI, messages, temperature, maxTokens)
+
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		log.Printf("[AI] ❌ Ошибка маршалинга запроса: %v", err)
+		return "", Usage{}, fmt.Errorf("ошибка маршалинга: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		log.Printf("[AI] ❌ Ошибка создания запроса: %v", err)
+		return "", Usage{}, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range c.nativeHeaders() {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		err = httpx.WrapProxyError(err, c.proxyURL)
+		log.Printf("[AI] ❌ Ошибка HTTP запроса: %v", err)
+		return "", Usage{}, newNetworkError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("[AI] ❌ Ошибка чтения ответа: %v", err)
+		return "", Usage{}, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[AI] ❌ Ошибка API: статус %d, тело: %s", resp.StatusCode, string(body))
+		var apiError nativeErrorResponse
+		json.Unmarshal(body, &apiError)
+		return "", Usage{}, newStatusError(resp, apiError.Error.Message)
+	}
+
+	return parseNativeCompletion(body)
+}
+
+// parseNativeCompletion разбирает ответ foundationModels/v1/completion
+func parseNativeCompletion(body []byte) (string, Usage, error) {
+	var response NativeCompletionResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		log.Printf("[AI] ❌ Ошибка парсинга: %v", err)
+		return "", Usage{}, fmt.Errorf("ошибка парсинга: %w", err)
+	}
+
+	if len(response.Result.Alternatives) == 0 {
+		log.Printf("[AI] ❌ Пустой ответ от GPT")
+		return "", Usage{}, fmt.Errorf("пустой ответ от GPT")
+	}
+
+	alternative := response.Result.Alternatives[0]
+	if alternative.Status == nativeContentFilterStatus {
+		log.Printf("[AI] ⚠️ Ответ заблокирован фильтром контента YandexGPT")
+	}
+
+	return alternative.Message.Text, nativeUsage(response), nil
+}
+
+// nativeUsage переводит строковые счетчики токенов в Usage.
+// Некорректные значения считаем нулем: учет токенов не должен ломать генерацию.
+func nativeUsage(response NativeCompletionResponse) Usage {
+	inputTokens, _ := strconv.Atoi(response.Result.Usage.InputTextTokens)
+	completionTokens, _ := strconv.Atoi(response.Result.Usage.CompletionTokens)
+	totalTokens, _ := strconv.Atoi(response.Result.Usage.TotalTokens)
+
+	return Usage{
+		InputTokens:      inputTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      totalTokens,
+	}
+}
+
+// newNativeRequest собирает запрос foundationModels из сообщений
+func newNativeRequest(modelURI string, messages []Message, temperature float64, maxTokens int) NativeCompletionRequest {
+	request := NativeCompletionRequest{ModelURI: modelURI}
+	request.CompletionOptions.Temperature = temperature
+	request.CompletionOptions.MaxTokens = strconv.Itoa(maxTokens)
+	for _, message := range messages {
+		request.Messages = append(request.Messages, NativeMessage{Role: message.Role, Text: message.Content})
+	}
+	return request
+}
+
+// nativeHeaders заголовки авторизации нативного API
+func (c *YandexGPTClient) nativeHeaders() map[string]string {
+	return map[string]string{
+		"Authorization": fmt.Sprintf("Api-Key %s", c.apiKey),
+		"x-folder-id":   c.folderID,
+	}
+}
@@ -0,0 +1,201 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// readFixture читает записанный ответ API из testdata
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// newTestYandexClient создает клиент YandexGPT, отправляющий запросы на тестовый сервер
+func newTestYandexClient(t *testing.T, endpoint, protocol string) *YandexGPTClient {
+	t.Helper()
+	config := DefaultAIConfig()
+	config.Yandex.APIKey = "test-key"
+	config.Yandex.FolderID = "b1gtest"
+	config.Yandex.Protocol = protocol
+
+	client, err := NewYandexGPTClient(config)
+	if err != nil {
+		t.Fatalf("NewYandexGPTClient: %v", err)
+	}
+	client.baseURL = endpoint
+	return client
+}
+
+func TestParseNativeCompletion(t *testing.T) {
+	text, usage, err := parseNativeCompletion(readFixture(t, "native_completion.json"))
+	if err != nil {
+		t.Fatalf("parseNativeCompletion: %v", err)
+	}
+	if !strings.HasPrefix(text, "⚡️ **Центробанк сохранил ключевую ставку**") {
+		t.Errorf("текст = %q", text)
+	}
+	want := Usage{InputTokens: 412, CompletionTokens: 68, TotalTokens: 480}
+	if usage != want {
+		t.Errorf("usage = %+v, ожидалось %+v", usage, want)
+	}
+}
+
+func TestParseNativeCompletionContentFilter(t *testing.T) {
+	// Заблокированный ответ возвращается как есть: отказ распознает IsRefusal
+	text, usage, err := parseNativeCompletion(readFixture(t, "native_content_filter.json"))
+	if err != nil {
+		t.Fatalf("parseNativeCompletion: %v", err)
+	}
+	if !strings.Contains(text, "В интернете есть много сайтов") {
+		t.Errorf("текст = %q", text)
+	}
+	if usage.InputTokens != 35 || usage.CompletionTokens != 0 {
+		t.Errorf("usage = %+v", usage)
+	}
+}
+
+func TestParseNativeCompletionErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"битый JSON", `{"result": {"alternatives": [`},
+		{"нет альтернатив", `{"result": {"alternatives": [], "usage": {"totalTokens": "10"}}}`},
+		{"ответ OpenAI вместо нативного", string(readFixture(t, "openai_completion.json"))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if text, _, err := parseNativeCompletion([]byte(tt.body)); err == nil {
+				t.Errorf("ожидалась ошибка, получено %q", text)
+			}
+		})
+	}
+}
+
+func TestNativeUsageIgnoresMalformedCounts(t *testing.T) {
+	var response NativeCompletionResponse
+	response.Result.Usage.InputTextTokens = "12"
+	response.Result.Usage.CompletionTokens = "много"
+	response.Result.Usage.TotalTokens = ""
+
+	want := Usage{InputTokens: 12}
+	if got := nativeUsage(response); got != want {
+		t.Errorf("nativeUsage = %+v, ожидалось %+v", got, want)
+	}
+}
+
+func TestParseChatCompletion(t *testing.T) {
+	text, usage, err := parseChatCompletion(readFixture(t, "openai_completion.json"))
+	if err != nil {
+		t.Fatalf("parseChatCompletion: %v", err)
+	}
+	if !strings.HasPrefix(text, "⚡️ **Центробанк сохранил ключевую ставку**") {
+		t.Errorf("текст = %q", text)
+	}
+	want := Usage{InputTokens: 398, CompletionTokens: 41, TotalTokens: 439}
+	if usage != want {
+		t.Errorf("usage = %+v, ожидалось %+v", usage, want)
+	}
+
+	for _, body := range []string{`{"choices": [`, `{"choices": []}`} {
+		if _, _, err := parseChatCompletion([]byte(body)); err == nil {
+			t.Errorf("parseChatCompletion(%q): ожидалась ошибка", body)
+		}
+	}
+}
+
+func TestNewNativeRequest(t *testing.T) {
+	request := newNativeRequest("gpt://b1gtest/yandexgpt-lite", []Message{
+		{Role: "system", Content: "Ты редактор"},
+		{Role: "user", Content: "Напиши пост"},
+	}, 0.3, 800)
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["modelUri"] != "gpt://b1gtest/yandexgpt-lite" {
+		t.Errorf("modelUri = %v", got["modelUri"])
+	}
+	options := got["completionOptions"].(map[string]any)
+	// API принимает maxTokens строкой
+	if options["maxTokens"] != "800" || options["temperature"] != 0.3 || options["stream"] != false {
+		t.Errorf("completionOptions = %v", options)
+	}
+	messages := got["messages"].([]any)
+	if len(messages) != 2 {
+		t.Fatalf("сообщений: %d", len(messages))
+	}
+	if first := messages[0].(map[string]any); first["role"] != "system" || first["text"] != "Ты редактор" {
+		t.Errorf("первое сообщение = %v", first)
+	}
+}
+
+func TestCompleteNativeRoundTrip(t *testing.T) {
+	var request NativeCompletionRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Api-Key test-key" {
+			t.Errorf("Authorization = %q", got)
+		}
+		if got := r.Header.Get("x-folder-id"); got != "b1gtest" {
+			t.Errorf("x-folder-id = %q", got)
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &request)
+		w.Write(readFixture(t, "native_completion.json"))
+	}))
+	defer server.Close()
+
+	client := newTestYandexClient(t, server.URL, protocolNative)
+	text, err := client.Complete(context.Background(), "Напиши пост", 0.7, 800)
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if !strings.HasPrefix(text, "⚡️ **Центробанк") {
+		t.Errorf("текст = %q", text)
+	}
+	if len(request.Messages) != 1 || request.Messages[0].Text != "Напиши пост" {
+		t.Errorf("сообщения запроса = %+v", request.Messages)
+	}
+}
+
+func TestCompleteNativeErrorMessage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write(readFixture(t, "native_error.json"))
+	}))
+	defer server.Close()
+
+	client := newTestYandexClient(t, server.URL, protocolNative)
+	_, err := client.Complete(context.Background(), "Напиши пост", 0.7, 800)
+
+	var aiErr *AIError
+	if !errors.As(err, &aiErr) {
+		t.Fatalf("ошибка %v не является AIError", err)
+	}
+	if aiErr.StatusCode != http.StatusBadRequest || aiErr.Retryable {
+		t.Errorf("AIError = %+v", aiErr)
+	}
+	if !strings.Contains(err.Error(), "Number of input tokens") {
+		t.Errorf("ошибка %q не содержит сообщение API", err)
+	}
+}
@@ -0,0 +1,234 @@
+package ai
+
+import (
+	"AIGenerator/internal/httpx"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OpenAICompatibleClient реализация TextGenerator для OpenAI-совместимых серверов
+// (Ollama, vLLM, OpenRouter и т.п.)
+type OpenAICompatibleClient struct {
+	postWriter
+
+	endpoint   string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+	proxyURL   *url.URL
+
+	// contextTokens размер контекста модели (AI_CONTEXT_TOKENS)
+	contextTokens int
+}
+
+// NewOpenAICompatibleClient создает клиент по адресу, ключу и модели из config.OpenAI
+func NewOpenAICompatibleClient(config AIConfig) (*OpenAICompatibleClient, error) {
+	baseURL := strings.TrimSpace(config.OpenAI.BaseURL)
+	if baseURL == "" {
+		return nil, fmt.Errorf("AI_BASE_URL не установлен")
+	}
+
+	model := strings.TrimSpace(config.OpenAI.Model)
+	if model == "" {
+		return nil, fmt.Errorf("AI_MODEL не установлен")
+	}
+
+	// AI_BASE_URL можно указать как с /chat/completions, так и без
+	endpoint := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(endpoint, "/chat/completions") {
+		endpoint += "/chat/completions"
+	}
+
+	proxyURL := httpx.ProxyURL(httpx.PurposeAI)
+	if proxyURL != nil {
+		log.Printf("[AI] Запросы к %s идут через прокси %s", endpoint, proxyURL.Host)
+	}
+
+	client := &OpenAICompatibleClient{
+		endpoint: endpoint,
+		// Ключ необязателен: локальные серверы обычно работают без авторизации
+		apiKey: config.OpenAI.APIKey,
+		model:  model,
+		httpClient: &http.Client{
+			Timeout:   config.Timeout,
+			Transport: httpx.NewTransport(proxyURL),
+		},
+		proxyURL:      proxyURL,
+		contextTokens: config.OpenAI.ContextTokens,
+	}
+	client.postWriter = postWriter{completer: client, config: config}
+	return client, nil
+}
+
+// ContextTokens возвращает размер контекста модели
+func (c *OpenAICompatibleClient) ContextTokens(ctx context.Context) int {
+	return c.contextTokens
+}
+
+// Complete отправляет промпт в OpenAI-совместимый сервер и возвращает текст ответа
+func (c *OpenAICompatibleClient) Complete(ctx context.Context, prompt string, temperature float64, maxTokens int) (string, error) {
+	return c.request(ctx, []Message{{Role: "user", Content: prompt}}, temperature, maxTokens, nil)
+}
+
+// CompleteMessages отправляет готовый набор сообщений (system, user)
+func (c *OpenAICompatibleClient) CompleteMessages(ctx context.Context, messages []Message, temperature float64, maxTokens int) (string, error) {
+	return c.request(ctx, messages, temperature, maxTokens, nil)
+}
+
+// CompleteMessagesStream как CompleteMessages, но передает накопленный текст в onText по мере генерации
+func (c *OpenAICompatibleClient) CompleteMessagesStream(ctx context.Context, messages []Message, temperature float64, maxTokens int, onText func(string)) (string, error) {
+	return c.request(ctx, messages, temperature, maxTokens, onText)
+}
+
+// request выполняет запрос к серверу; при onText != nil — в потоковом режиме
+func (c *OpenAICompatibleClient) request(ctx context.Context, messages []Message, temperature float64, maxTokens int, onText func(string)) (string, error) {
+	headers := map[string]string{}
+	if c.apiKey != "" {
+		headers["Authorization"] = "Bearer " + c.apiKey
+	}
+
+	request := ChatCompletionRequest{
+		Model:       c.model,
+		Messages:    messages,
+		Temperature: temperature,
+		MaxTokens:   maxTokens,
+	}
+
+	log.Printf("[AI] Отправка запроса к %s (модель %s)...", c.endpoint, c.model)
+	started := time.Now()
+	probe, err := breaker.allow()
+	if err != nil {
+		log.Printf("[AI] ⛔ Запрос не отправлен: автомат разомкнут после серии ошибок")
+		recordAudit(ctx, c.model, messages, "", Usage{}, started, err)
+		return "", err
+	}
+
+	text, usage, err := withRetry(ctx, func() (string, Usage, error) {
+		if onText != nil {
+			return streamChatCompletion(ctx, c.httpClient, c.proxyURL, c.endpoint, headers, request, onText)
+		}
+		return postChatCompletion(ctx, c.httpClient, c.proxyURL, c.endpoint, headers, request)
+	})
+	breaker.record(ctx, probe, err)
+	recordAudit(ctx, c.model, messages, text, usage, started, err)
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("[COST] Использовано токенов: %d (вход %d, ответ %d)",
+		usage.TotalTokens, usage.InputTokens, usage.CompletionTokens)
+
+	return strings.TrimSpace(text), nil
+}
+
+// ChatCompletionRequest запрос в формате OpenAI Chat Completions
+type ChatCompletionRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	Temperature float64   `json:"temperature,omitempty"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatCompletionResponse ответ в формате OpenAI Chat Completions
+type ChatCompletionResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int    `json:"created"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// Usage количество токенов, потраченных на запрос
+type Usage struct {
+	InputTokens      int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// postChatCompletion отправляет запрос в OpenAI-совместимый endpoint и возвращает текст ответа
+func postChatCompletion(ctx context.Context, client *http.Client, proxyURL *url.URL, endpoint string, headers map[string]string, request ChatCompletionRequest) (string, Usage, error) {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		log.Printf("[AI] ❌ Ошибка маршалинга запроса: %v", err)
+		return "", Usage{}, fmt.Errorf("ошибка маршалинга: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		log.Printf("[AI] ❌ Ошибка создания запроса: %v", err)
+		return "", Usage{}, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = httpx.WrapProxyError(err, proxyURL)
+		log.Printf("[AI] ❌ Ошибка HTTP запроса: %v", err)
+		return "", Usage{}, newNetworkError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		log.Printf("[AI] ❌ Ошибка чтения ответа: %v", err)
+		return "", Usage{}, fmt.Errorf("ошибка чтения ответа: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("[AI] ❌ Ошибка API: статус %d, тело: %s", resp.StatusCode, string(body))
+		return "", Usage{}, newStatusError(resp, "")
+	}
+
+	return parseChatCompletion(body)
+}
+
+// parseChatCompletion разбирает ответ OpenAI Chat Completions
+func parseChatCompletion(body []byte) (string, Usage, error) {
+	var chatResponse ChatCompletionResponse
+	if err := json.Unmarshal(body, &chatResponse); err != nil {
+		log.Printf("[AI] ❌ Ошибка парсинга: %v", err)
+		return "", Usage{}, fmt.Errorf("ошибка парсинга: %w", err)
+	}
+
+	if len(chatResponse.Choices) == 0 {
+		log.Printf("[AI] ❌ Пустой ответ от GPT")
+		return "", Usage{}, fmt.Errorf("пустой ответ от GPT")
+	}
+
+	usage := Usage{
+		InputTokens:      chatResponse.Usage.PromptTokens,
+		CompletionTokens: chatResponse.Usage.CompletionTokens,
+		TotalTokens:      chatResponse.Usage.TotalTokens,
+	}
+	return chatResponse.Choices[0].Message.Content, usage, nil
+}
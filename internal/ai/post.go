@@ -0,0 +1,216 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Post сгенерированный пост в структурированном виде
+type Post struct {
+	Title         string   `json:"title"`
+	Body          string   `json:"body_markdown"`
+	Hashtags      []string `json:"hashtags"`
+	Refused       bool     `json:"refused"`
+	RefusalReason string   `json:"refusal_reason"`
+	// Structured false, если модель так и не вернула JSON и пост собран из свободного текста
+	Structured bool `json:"-"`
+	// Cached true, если пост взят из кэша без запроса к модели
+	Cached bool `json:"-"`
+	// SafetyWarning true, если в посте остались недопустимые формулировки
+	SafetyWarning bool `json:"-"`
+}
+
+// Text собирает текст поста для отправки в Telegram (Markdown)
+func (p Post) Text() string {
+	body := strings.TrimSpace(p.Body)
+	heading := p.Heading()
+	if heading == "" {
+		return body
+	}
+	return fmt.Sprintf("*%s*\n\n%s", heading, body)
+}
+
+// Heading заголовок поста с эмодзи в начале, без разметки; пустой, если пост собран
+// из свободного текста или модель не вернула заголовок
+func (p Post) Heading() string {
+	title := strings.TrimSpace(p.Title)
+	if !p.Structured || title == "" {
+		return ""
+	}
+
+	title = strings.Trim(title, "*")
+	if !strings.HasPrefix(title, "⚡️") && !strings.HasPrefix(title, "🔥") && !strings.HasPrefix(title, "🚨") {
+		title = "⚡️ " + title
+	}
+	return title
+}
+
+// HashtagLine возвращает хештеги поста в виде строки "#тег1 #тег2"
+func (p Post) HashtagLine() string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range p.Hashtags {
+		tag = strings.ToLower(strings.Join(strings.Fields(strings.TrimLeft(tag, "#")), ""))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, "#"+tag)
+	}
+	return strings.Join(tags, " ")
+}
+
+// parsePost разбирает JSON-ответ модели и проверяет обязательные поля
+func parsePost(response string) (Post, error) {
+	text, err := extractJSONObject(response)
+	if err != nil {
+		return Post{}, err
+	}
+
+	var post Post
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&post); err != nil {
+		return Post{}, fmt.Errorf("некорректный JSON поста: %w", err)
+	}
+
+	if !post.Refused && (strings.TrimSpace(post.Title) == "" || strings.TrimSpace(post.Body) == "") {
+		return Post{}, fmt.Errorf("в JSON поста нет title или body_markdown")
+	}
+
+	post.Structured = true
+	return post, nil
+}
+
+// plaintextPost собирает пост из ответа, который не удалось разобрать как JSON.
+// Если ответ похож на оборванный JSON, из него извлекается текст поста.
+func plaintextPost(response string) Post {
+	text := strings.TrimSpace(response)
+	if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "```") {
+		title := partialJSONField(text, "title")
+		body := partialJSONField(text, "body_markdown")
+		if body != "" {
+			return Post{Title: title, Body: body, Structured: title != ""}
+		}
+	}
+	return Post{Body: finishPost(text)}
+}
+
+// generatePost возвращает пост из кэша или запрашивает его у модели
+func (w postWriter) generatePost(ctx context.Context, messages []Message, partial chan<- string) (Post, error) {
+	key := postCacheKey(ctx, messages)
+	if post, ok := posts.get(key); ok {
+		if partial != nil {
+			close(partial)
+		}
+		log.Printf("[CACHE] ✅ Пост взят из кэша")
+		post.Cached = true
+		return post, nil
+	}
+
+	post, err := w.requestPost(ctx, messages, partial)
+	if err != nil {
+		return Post{}, err
+	}
+
+	post = w.ensureSafe(ctx, messages, post)
+	if !post.Refused {
+		posts.put(key, post)
+	}
+	return post, nil
+}
+
+// requestPost выполняет запрос поста в формате JSON с одной повторной попыткой
+// при некорректном ответе и откатом на свободный текст
+func (w postWriter) requestPost(ctx context.Context, messages []Message, partial chan<- string) (Post, error) {
+	response, err := w.complete(ctx, messages, partial)
+	if err != nil {
+		return Post{}, err
+	}
+
+	post, parseErr := parsePost(response)
+	if parseErr == nil {
+		return post, nil
+	}
+
+	log.Printf("[AI] ⚠️ Пост получен не в формате JSON (%v), повторяем запрос", parseErr)
+	repair := append(slices.Clone(messages),
+		Message{Role: "assistant", Content: response},
+		Message{Role: "user", Content: "Верни только JSON-объект по схеме, без пояснений и без markdown."},
+	)
+	repaired, err := w.completer.CompleteMessages(ctx, repair, 0.3, w.params(ctx).MaxTokens)
+	if err != nil {
+		log.Printf("[AI] ⚠️ Повторный запрос JSON не удался: %v, используем исходный текст", err)
+		return plaintextPost(response), nil
+	}
+
+	post, parseErr = parsePost(repaired)
+	if parseErr == nil {
+		return post, nil
+	}
+
+	log.Printf("[AI] ⚠️ Пост так и не получен в формате JSON (%v), используем текст", parseErr)
+	return plaintextPost(repaired), nil
+}
+
+// postPreview превращает накопленный при потоковой генерации JSON в читаемый текст
+func postPreview(text string) string {
+	trimmed := strings.TrimSpace(text)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "```") {
+		return text
+	}
+
+	title := partialJSONField(trimmed, "title")
+	body := partialJSONField(trimmed, "body_markdown")
+	if body == "" {
+		return title
+	}
+	return title + "\n\n" + body
+}
+
+// partialJSONField извлекает строковое значение поля из, возможно, незаконченного JSON
+func partialJSONField(text, field string) string {
+	key := `"` + field + `"`
+	idx := strings.Index(text, key)
+	if idx < 0 {
+		return ""
+	}
+	rest := strings.TrimLeft(text[idx+len(key):], " \t\r\n")
+	rest, ok := strings.CutPrefix(rest, ":")
+	if !ok {
+		return ""
+	}
+	rest, ok = strings.CutPrefix(strings.TrimLeft(rest, " \t\r\n"), `"`)
+	if !ok {
+		return ""
+	}
+
+	// Ищем закрывающую кавычку, пропуская экранированные символы
+	end := len(rest)
+	for i := 0; i < len(rest); i++ {
+		if rest[i] == '\\' {
+			i++
+			continue
+		}
+		if rest[i] == '"' {
+			end = i
+			break
+		}
+	}
+	raw := rest[:end]
+
+	// В оборванной строке может остаться неполная escape-последовательность
+	if slash := strings.LastIndex(raw, `\`); slash >= 0 && len(raw)-slash < 6 && end == len(rest) {
+		raw = raw[:slash]
+	}
+
+	var value string
+	if err := json.Unmarshal([]byte(`"`+raw+`"`), &value); err != nil {
+		return strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(raw)
+	}
+	return value
+}
@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParsePostFixtures(t *testing.T) {
+	post, err := parsePost(string(readFixture(t, "post_valid.json")))
+	if err != nil {
+		t.Fatalf("parsePost: %v", err)
+	}
+	if !post.Structured || post.Refused {
+		t.Errorf("пост = %+v", post)
+	}
+	if post.Heading() != "🔥 Центробанк сохранил ключевую ставку" {
+		t.Errorf("Heading = %q", post.Heading())
+	}
+	// Хештеги без # дополняются, повторы в другом регистре отбрасываются
+	if got := post.HashtagLine(); got != "#цб #ставка #экономика" {
+		t.Errorf("HashtagLine = %q", got)
+	}
+	if !strings.HasPrefix(post.Text(), "*🔥 Центробанк сохранил ключевую ставку*\n\nБанк России") {
+		t.Errorf("Text = %q", post.Text())
+	}
+
+	refused, err := parsePost(string(readFixture(t, "post_refused.json")))
+	if err != nil {
+		t.Fatalf("parsePost отказа: %v", err)
+	}
+	if !refused.Refused || refused.RefusalReason != "Статья содержит призывы к насилию" {
+		t.Errorf("отказ = %+v", refused)
+	}
+
+	for _, name := range []string{"post_broken.txt", "post_plaintext.txt"} {
+		if post, err := parsePost(string(readFixture(t, name))); err == nil {
+			t.Errorf("%s разобран как JSON: %+v", name, post)
+		}
+	}
+}
+
+func TestParsePostValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr bool
+	}{
+		{"пустой заголовок", `{"title": " ", "body_markdown": "Текст", "hashtags": [], "refused": false, "refusal_reason": ""}`, true},
+		{"нет текста", `{"title": "Заголовок", "hashtags": [], "refused": false}`, true},
+		{"лишнее поле", `{"title": "Заголовок", "body_markdown": "Текст", "image_prompt": "кот"}`, true},
+		{"отказ без текста", `{"refused": true, "refusal_reason": "нельзя"}`, false},
+		{"JSON в код-блоке", "```json\n{\"title\": \"Заголовок\", \"body_markdown\": \"Текст\"}\n```", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parsePost(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parsePost: ошибка %v, ожидалась ошибка %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlaintextPost(t *testing.T) {
+	// Оборванный JSON: текст поста извлекается из полей
+	broken := plaintextPost(string(readFixture(t, "post_broken.txt")))
+	if broken.Title != "Центробанк сохранил ключевую ставку" || !broken.Structured {
+		t.Errorf("пост из оборванного JSON = %+v", broken)
+	}
+	if !strings.HasPrefix(broken.Body, "Банк России оставил ставку на уровне 21%.\n\nРегулятор допустил \"снижение\"") {
+		t.Errorf("Body = %q", broken.Body)
+	}
+
+	// Свободный текст отправляется как есть с эмодзи в начале
+	plain := plaintextPost(string(readFixture(t, "post_plaintext.txt")))
+	if plain.Structured || !strings.HasPrefix(plain.Body, "⚡️ Центробанк сохранил") {
+		t.Errorf("пост из текста = %+v", plain)
+	}
+	if plain.Text() != plain.Body {
+		t.Errorf("у поста из текста появился заголовок: %q", plain.Text())
+	}
+}
+
+func TestRequestPostRepair(t *testing.T) {
+	valid := string(readFixture(t, "post_valid.json"))
+	broken := string(readFixture(t, "post_broken.txt"))
+	plaintext := string(readFixture(t, "post_plaintext.txt"))
+
+	tests := []struct {
+		name           string
+		responses      []string
+		wantCalls      int
+		wantStructured bool
+		wantRefused    bool
+	}{
+		{"корректный JSON", []string{valid}, 1, true, false},
+		{"отказ модели", []string{string(readFixture(t, "post_refused.json"))}, 1, true, true},
+		{"исправлен повтором", []string{plaintext, valid}, 2, true, false},
+		{"повтор снова не JSON", []string{plaintext, plaintext}, 2, false, false},
+		{"оборванный JSON дважды", []string{broken, broken}, 2, true, false},
+		{"повтор не удался", []string{plaintext}, 2, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			writer, completer := newScriptedWriter(tt.responses...)
+			post, err := writer.requestPost(context.Background(), []Message{{Role: "user", Content: "пост"}}, nil)
+			if err != nil {
+				t.Fatalf("requestPost: %v", err)
+			}
+			if completer.calls() != tt.wantCalls {
+				t.Errorf("запросов = %d, ожидалось %d", completer.calls(), tt.wantCalls)
+			}
+			if post.Structured != tt.wantStructured || post.Refused != tt.wantRefused {
+				t.Errorf("пост = %+v", post)
+			}
+			if !post.Refused && strings.TrimSpace(post.Text()) == "" {
+				t.Error("пустой текст поста")
+			}
+		})
+	}
+}
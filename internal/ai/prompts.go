@@ -0,0 +1,178 @@
+package ai
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+//go:embed prompts/*.tmpl
+var defaultPrompts embed.FS
+
+// Имена шаблонов промптов (файлы <имя>.tmpl)
+const (
+	promptPostSystem    = "post_system"
+	promptPostUser      = "post_user"
+	promptURLPostSystem = "url_post_system"
+	promptURLPostUser   = "url_post_user"
+
+	promptChannelAnalysisSystem = "channel_analysis_system"
+	promptChannelAnalysisUser   = "channel_analysis_user"
+
+	promptModerationSystem = "moderation_system"
+	promptModerationUser   = "moderation_user"
+
+	promptTranslateSystem = "translate_system"
+	promptTranslateUser   = "translate_user"
+
+	promptRewriteSystem = "rewrite_system"
+	promptRewriteUser   = "rewrite_user"
+
+	promptRerankSystem = "rerank_system"
+	promptRerankUser   = "rerank_user"
+
+	promptSafetySystem = "safety_system"
+	promptSafetyUser   = "safety_user"
+
+	promptExpandSystem = "expand_system"
+	promptExpandUser   = "expand_user"
+
+	promptHeadlinesSystem = "headlines_system"
+	promptHeadlinesUser   = "headlines_user"
+
+	promptRefusalSystem = "refusal_system"
+	promptRefusalUser   = "refusal_user"
+
+	promptAnswerSystem = "answer_system"
+	promptAnswerUser   = "answer_user"
+
+	// promptPostExample пример поста на языке: post_example_<код языка>
+	promptPostExample = "post_example_"
+)
+
+var promptNames = []string{
+	promptPostSystem, promptPostUser,
+	promptURLPostSystem, promptURLPostUser,
+	promptChannelAnalysisSystem, promptChannelAnalysisUser,
+	promptModerationSystem, promptModerationUser,
+	promptTranslateSystem, promptTranslateUser,
+	promptRewriteSystem, promptRewriteUser,
+	promptRerankSystem, promptRerankUser,
+	promptSafetySystem, promptSafetyUser,
+	promptExpandSystem, promptExpandUser,
+	promptHeadlinesSystem, promptHeadlinesUser,
+	promptRefusalSystem, promptRefusalUser,
+	promptAnswerSystem, promptAnswerUser,
+}
+
+// promptFuncs функции, доступные в шаблонах
+var promptFuncs = template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}
+
+var (
+	promptTemplates map[string]*template.Template
+	promptsMu       sync.RWMutex
+)
+
+func init() {
+	for _, language := range languages {
+		promptNames = append(promptNames, promptPostExample+language.Code)
+	}
+
+	if err := ReloadPrompts(); err != nil {
+		log.Printf("[AI] ❌ Ошибка загрузки промптов: %v", err)
+	}
+}
+
+// ReloadPrompts загружает шаблоны промптов. Встроенные шаблоны можно переопределить
+// файлами из каталога PromptsDir. При ошибке остаются ранее загруженные шаблоны.
+func ReloadPrompts() error {
+	dir := currentConfig().PromptsDir
+
+	templates := make(map[string]*template.Template, len(promptNames))
+	for _, name := range promptNames {
+		text, source, err := readPrompt(dir, name)
+		if err != nil {
+			return err
+		}
+
+		tmpl, err := template.New(name).Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return fmt.Errorf("ошибка разбора шаблона %s (%s): %w", name, source, err)
+		}
+		templates[name] = tmpl
+	}
+
+	promptsMu.Lock()
+	promptTemplates = templates
+	promptsMu.Unlock()
+
+	log.Printf("[AI] ✅ Загружено %d шаблонов промптов", len(templates))
+	return nil
+}
+
+// readPrompt читает шаблон из PROMPTS_DIR, а если его там нет — из встроенных
+func readPrompt(dir, name string) (string, string, error) {
+	fileName := name + ".tmpl"
+	if dir != "" {
+		path := filepath.Join(dir, fileName)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return string(data), path, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", path, fmt.Errorf("ошибка чтения шаблона %s: %w", path, err)
+		}
+	}
+
+	data, err := defaultPrompts.ReadFile("prompts/" + fileName)
+	if err != nil {
+		return "", "встроенный", fmt.Errorf("встроенный шаблон %s не найден: %w", name, err)
+	}
+	return string(data), "встроенный", nil
+}
+
+// renderPrompt подставляет данные в шаблон промпта
+func renderPrompt(name string, data any) (string, error) {
+	promptsMu.RLock()
+	tmpl, ok := promptTemplates[name]
+	promptsMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("шаблон промпта %s не загружен", name)
+	}
+
+	return executePrompt(tmpl, data)
+}
+
+// executePrompt заполняет разобранный шаблон промпта данными
+func executePrompt(tmpl *template.Template, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("ошибка заполнения шаблона %s: %w", tmpl.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// promptMessages собирает системное и пользовательское сообщения из пары шаблонов
+func promptMessages(systemName, userName string, data any) ([]Message, error) {
+	system, err := renderPrompt(systemName, data)
+	if err != nil {
+		return nil, err
+	}
+	user, err := renderPrompt(userName, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: user},
+	}, nil
+}
@@ -0,0 +1,199 @@
+package ai
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// usePromptsDir задает каталог переопределенных шаблонов на время теста
+func usePromptsDir(t *testing.T, dir string) {
+	t.Helper()
+	configuredMu.Lock()
+	saved := configured
+	configured.PromptsDir = dir
+	configuredMu.Unlock()
+
+	t.Cleanup(func() {
+		configuredMu.Lock()
+		configured = saved
+		configuredMu.Unlock()
+		if err := ReloadPrompts(); err != nil {
+			t.Errorf("ReloadPrompts: %v", err)
+		}
+	})
+}
+
+// checkRendered проверяет, что в тексте не осталось незаполненных мест шаблона
+func checkRendered(t *testing.T, name, text string) {
+	t.Helper()
+	if strings.TrimSpace(text) == "" {
+		t.Errorf("%s: пустой текст", name)
+	}
+	for _, leftover := range []string{"{{", "}}", "<no value>", "%!"} {
+		if strings.Contains(text, leftover) {
+			t.Errorf("%s: в тексте осталось %q:\n%s", name, leftover, text)
+		}
+	}
+}
+
+func TestPromptTemplatesRender(t *testing.T) {
+	russian := LanguageOrDefault("ru")
+	article := ArticleInfo{Title: "Банк России сохранил ставку", Summary: "Совет директоров принял решение"}
+
+	tests := []struct {
+		system, user string
+		data         any
+		// want должно оказаться в сообщении пользователя
+		want string
+	}{
+		{promptPostSystem, promptPostUser, postPromptData{Keywords: "ключевая ставка", Title: article.Title, Summary: article.Summary, Language: russian, Example: "пример", InlineSource: true}, "ключевая ставка"},
+		{promptURLPostSystem, promptURLPostUser, urlPostPromptData{Title: article.Title, Content: "Полный текст статьи", Language: russian}, "Полный текст статьи"},
+		{promptChannelAnalysisSystem, promptChannelAnalysisUser, channelAnalysisPromptData{Title: "Технологии", Description: "Про ИИ", Messages: []string{"Первый пост", "Второй пост"}}, "Второй пост"},
+		{promptModerationSystem, promptModerationUser, moderationPromptData{Keywords: "курс рубля"}, "курс рубля"},
+		{promptTranslateSystem, promptTranslateUser, translatePromptData{Text: "*Заголовок*\n\nТекст", Language: LanguageOrDefault("en")}, "*Заголовок*"},
+		{promptRewriteSystem, promptRewriteUser, rewritePromptData{Text: "Пресс-релиз компании", Language: russian}, "Пресс-релиз компании"},
+		{promptRerankSystem, promptRerankUser, rerankPromptData{Query: "ставка ЦБ", Articles: []ArticleInfo{article, {Title: "Курс доллара", Summary: "Рубль укрепился"}}}, "Курс доллара"},
+		{promptSafetySystem, promptSafetyUser, safetyPromptData{Text: "Готовый пост"}, "Готовый пост"},
+		{promptExpandSystem, promptExpandUser, expandPromptData{Title: "Ставка", Body: "Текст поста", Source: "Исходная статья", Language: russian}, "Исходная статья"},
+		{promptHeadlinesSystem, promptHeadlinesUser, headlinesPromptData{Text: "Текст для заголовков", Styles: HeadlineStyles, Language: russian}, "Текст для заголовков"},
+		{promptRefusalSystem, promptRefusalUser, refusalPromptData{Text: "Я не могу помочь"}, "Я не могу помочь"},
+		{promptAnswerSystem, promptAnswerUser, answerPromptData{Title: article.Title, URL: "https://example.com", Source: "РБК", Published: "12.01.2025", Content: "Текст статьи", Question: "Когда заседание?"}, "Когда заседание?"},
+	}
+
+	covered := make(map[string]bool)
+	for _, tt := range tests {
+		t.Run(tt.system, func(t *testing.T) {
+			messages, err := promptMessages(tt.system, tt.user, tt.data)
+			if err != nil {
+				t.Fatalf("promptMessages: %v", err)
+			}
+			checkRendered(t, tt.system, messages[0].Content)
+			checkRendered(t, tt.user, messages[1].Content)
+			if !strings.Contains(messages[1].Content, tt.want) {
+				t.Errorf("сообщение пользователя не содержит %q:\n%s", tt.want, messages[1].Content)
+			}
+		})
+		covered[tt.system], covered[tt.user] = true, true
+	}
+
+	for _, language := range Languages() {
+		name := promptPostExample + language.Code
+		text, err := renderPrompt(name, nil)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		checkRendered(t, name, text)
+		covered[name] = true
+	}
+
+	for _, name := range promptNames {
+		if !covered[name] {
+			t.Errorf("шаблон %s не проверяется тестом", name)
+		}
+	}
+}
+
+func TestPromptRejectsMissingField(t *testing.T) {
+	// Данные другого шаблона не подходят: missingkey=error не дает отправить промпт с пропуском
+	if _, err := renderPrompt(promptAnswerUser, moderationPromptData{Keywords: "тема"}); err == nil {
+		t.Error("шаблон заполнен данными без нужных полей")
+	}
+	if _, err := renderPrompt("unknown_prompt", nil); err == nil {
+		t.Error("неизвестный шаблон заполнен")
+	}
+}
+
+func TestReloadPromptsOverridesFromDir(t *testing.T) {
+	dir := t.TempDir()
+	usePromptsDir(t, dir)
+
+	override := "Своя тема: {{.Keywords}}"
+	if err := os.WriteFile(filepath.Join(dir, promptModerationUser+".tmpl"), []byte(override), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadPrompts(); err != nil {
+		t.Fatalf("ReloadPrompts: %v", err)
+	}
+	if got, _ := renderPrompt(promptModerationUser, moderationPromptData{Keywords: "курс"}); got != "Своя тема: курс" {
+		t.Errorf("переопределенный шаблон = %q", got)
+	}
+	// Остальные шаблоны остаются встроенными
+	if _, err := renderPrompt(promptModerationSystem, moderationPromptData{Keywords: "курс"}); err != nil {
+		t.Errorf("встроенный шаблон: %v", err)
+	}
+
+	// Сломанный шаблон не заменяет загруженные
+	if err := os.WriteFile(filepath.Join(dir, promptModerationUser+".tmpl"), []byte("{{.Keywords"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadPrompts(); err == nil {
+		t.Fatal("сломанный шаблон загружен без ошибки")
+	}
+	if got, _ := renderPrompt(promptModerationUser, moderationPromptData{Keywords: "курс"}); got != "Своя тема: курс" {
+		t.Errorf("после ошибки шаблон = %q, ожидался прежний", got)
+	}
+}
+
+func TestLoadPromptVariant(t *testing.T) {
+	dir := t.TempDir()
+	usePromptsDir(t, dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "short.tmpl"), []byte("Пиши коротко на {{.Language.Name}} языке"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte("{{.Audience}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	variant, err := LoadPromptVariant("short", "short.tmpl")
+	if err != nil {
+		t.Fatalf("LoadPromptVariant: %v", err)
+	}
+	if variant.Name != "short" {
+		t.Errorf("Name = %q", variant.Name)
+	}
+	if _, err := LoadPromptVariant("broken", "broken.tmpl"); err == nil {
+		t.Error("шаблон с неизвестным полем загружен")
+	}
+	if _, err := LoadPromptVariant("missing", "missing.tmpl"); err == nil {
+		t.Error("отсутствующий шаблон загружен")
+	}
+}
+
+func TestPostPromptMessagesVariant(t *testing.T) {
+	dir := t.TempDir()
+	usePromptsDir(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, "short.tmpl"), []byte("Пиши кратко о {{.Keywords}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	variant, err := LoadPromptVariant("B", "short.tmpl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := postPromptData{Keywords: "ключевая ставка", Title: "Ставка", Summary: "Описание", Language: LanguageOrDefault("ru")}
+
+	standard, err := postPromptMessages(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	experimental, err := postPromptMessages(WithPromptVariant(context.Background(), variant), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Вариант заменяет только системный промпт
+	if experimental[0].Content != "Пиши кратко о ключевая ставка" {
+		t.Errorf("системный промпт варианта: %q", experimental[0].Content)
+	}
+	if standard[0].Content == experimental[0].Content || standard[1].Content != experimental[1].Content {
+		t.Errorf("промпты без варианта и с ним:\n%+v\n%+v", standard, experimental)
+	}
+	// Пустой вариант не подменяет шаблон
+	if empty, _ := postPromptMessages(WithPromptVariant(context.Background(), PromptVariant{}), data); empty[0].Content != standard[0].Content {
+		t.Errorf("пустой вариант: %q", empty[0].Content)
+	}
+}
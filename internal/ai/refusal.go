@@ -0,0 +1,112 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+// refusalPrefixLength сколько первых символов текста проверяется на фразы отказа:
+// модель отказывается в начале ответа, а дальше фразы встречаются в цитатах
+const refusalPrefixLength = 200
+
+// maxRefusalCheckLength до скольких символов сокращается текст для классификатора
+const maxRefusalCheckLength = 600
+
+// refusalPhrases фразы, которыми модель однозначно отказывается
+var refusalPhrases = []string{
+	"я не могу обсуждать эту тему",
+	"отказываюсь обсуждать",
+	"извините, но я не могу",
+	"сожалею, но я не могу",
+	"давайте поговорим о чём-нибудь ещё",
+	"давайте поговорим о чем-нибудь еще",
+}
+
+// refusalHints фразы, которые встречаются и в отказах, и в обычных постах с цитатами:
+// по ним решает классификатор
+var refusalHints = []string{
+	"не могу обсуждать",
+	"это неэтично",
+	"это неприемлемо",
+	"я не буду",
+	"не могу создать",
+	"не могу написать",
+	"не могу помочь",
+	"не могу выполнить",
+	"к сожалению, я",
+	"как языковая модель",
+	"as an ai",
+	"i can't",
+	"i cannot",
+}
+
+// refusalPromptData данные для шаблона классификатора отказов
+type refusalPromptData struct {
+	Text string
+}
+
+// refusalResponse ответ классификатора отказов
+type refusalResponse struct {
+	Refusal bool `json:"refusal"`
+}
+
+// refusalHeuristic проверяет начало текста по фразам отказа. certain=false означает,
+// что найдена только неоднозначная фраза и нужен классификатор.
+func refusalHeuristic(text string) (refusal, certain bool) {
+	prefix := strings.ToLower(truncateRunes(strings.TrimSpace(text), refusalPrefixLength))
+	prefix = strings.ReplaceAll(prefix, "’", "'")
+
+	for _, phrase := range refusalPhrases {
+		if strings.Contains(prefix, phrase) {
+			return true, true
+		}
+	}
+	for _, hint := range refusalHints {
+		if strings.Contains(prefix, hint) {
+			return true, false
+		}
+	}
+	return false, true
+}
+
+// IsRefusal определяет, отказалась ли модель писать пост вместо самого поста.
+// Однозначные случаи решает эвристика по началу текста, неоднозначные — короткий
+// запрос к lite-модели. Если классификатор недоступен, используется ответ эвристики.
+func (w postWriter) IsRefusal(ctx context.Context, text string) bool {
+	refusal, certain := refusalHeuristic(text)
+	if certain {
+		return refusal
+	}
+
+	messages, err := promptMessages(promptRefusalSystem, promptRefusalUser, refusalPromptData{
+		Text: truncateRunes(strings.TrimSpace(text), maxRefusalCheckLength),
+	})
+	if err != nil {
+		log.Printf("[AI] ⚠️ Проверка отказа пропущена: %v", err)
+		return refusal
+	}
+
+	response, err := w.completer.CompleteMessages(withRequestType(WithModelTier(ctx, TierLite), RequestRefusalCheck), messages, 0, 50)
+	if err != nil {
+		log.Printf("[AI] ⚠️ Ошибка проверки отказа, используем эвристику: %v", err)
+		return refusal
+	}
+
+	jsonText, err := extractJSONObject(response)
+	var parsed refusalResponse
+	if err == nil {
+		err = json.Unmarshal([]byte(jsonText), &parsed)
+	}
+	if err != nil {
+		log.Printf("[AI] ⚠️ Некорректный ответ проверки отказа (%v): %s", err, response)
+		return refusal
+	}
+
+	if parsed.Refusal != refusal {
+		log.Printf("[AI] 🔀 Эвристика (отказ: %t) и классификатор (отказ: %t) разошлись: %s",
+			refusal, parsed.Refusal, truncateRunes(strings.TrimSpace(text), refusalPrefixLength))
+	}
+	return parsed.Refusal
+}
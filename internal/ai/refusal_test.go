@@ -0,0 +1,88 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// labeledOutput ответ модели с разметкой: отказ ли это и должна ли эвристика решать сама
+type labeledOutput struct {
+	Text    string `json:"text"`
+	Refusal bool   `json:"refusal"`
+	Certain bool   `json:"certain"`
+}
+
+func readLabeledOutputs(t *testing.T) []labeledOutput {
+	t.Helper()
+	var outputs []labeledOutput
+	if err := json.Unmarshal(readFixture(t, "refusals.json"), &outputs); err != nil {
+		t.Fatalf("разбор refusals.json: %v", err)
+	}
+	return outputs
+}
+
+func TestRefusalHeuristicLabeled(t *testing.T) {
+	outputs := readLabeledOutputs(t)
+	if len(outputs) < 30 {
+		t.Fatalf("в наборе %d ответов", len(outputs))
+	}
+
+	for _, output := range outputs {
+		refusal, certain := refusalHeuristic(output.Text)
+		if certain != output.Certain {
+			t.Errorf("уверенность %t, ожидалась %t: %q", certain, output.Certain, output.Text)
+		}
+		// Уверенный ответ эвристики не перепроверяется и обязан быть верным
+		if certain && refusal != output.Refusal {
+			t.Errorf("эвристика: отказ %t, ожидалось %t: %q", refusal, output.Refusal, output.Text)
+		}
+	}
+}
+
+func TestIsRefusalLabeled(t *testing.T) {
+	for _, output := range readLabeledOutputs(t) {
+		writer, completer := newScriptedWriter(fmt.Sprintf(`{"refusal": %t}`, output.Refusal))
+		if got := writer.IsRefusal(context.Background(), output.Text); got != output.Refusal {
+			t.Errorf("IsRefusal = %t: %q", got, output.Text)
+		}
+
+		// Классификатор вызывается только для неоднозначных ответов
+		wantCalls := 1
+		if output.Certain {
+			wantCalls = 0
+		}
+		if completer.calls() != wantCalls {
+			t.Errorf("запросов классификатора %d, ожидалось %d: %q", completer.calls(), wantCalls, output.Text)
+			continue
+		}
+		if wantCalls == 1 && !strings.Contains(completer.requests[0][1].Content, strings.TrimSpace(output.Text)) {
+			t.Errorf("текст не передан классификатору: %q", completer.requests[0][1].Content)
+		}
+	}
+}
+
+func TestIsRefusalClassifierUnavailable(t *testing.T) {
+	quote := "⚡️ Илон Маск: «Я не буду продавать Tesla»."
+
+	// Без ответа классификатора неоднозначная фраза считается отказом, как и раньше
+	for _, responses := range [][]string{nil, {"не знаю"}, {`{"refusal": "нет"}`}} {
+		writer, _ := newScriptedWriter(responses...)
+		if !writer.IsRefusal(context.Background(), quote) {
+			t.Errorf("ответ классификатора %q: эвристика не использована", responses)
+		}
+	}
+}
+
+func TestIsRefusalTruncatesClassifierInput(t *testing.T) {
+	text := "Я не буду " + strings.Repeat("длинный текст ", 200)
+	writer, completer := newScriptedWriter(`{"refusal": false}`)
+	writer.IsRefusal(context.Background(), text)
+
+	if content := completer.requests[0][1].Content; strings.Contains(content, strings.TrimSpace(text)) ||
+		!strings.Contains(content, truncateRunes(strings.TrimSpace(text), maxRefusalCheckLength)) {
+		t.Errorf("текст для классификатора не сокращен до %d символов", maxRefusalCheckLength)
+	}
+}
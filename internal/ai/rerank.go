@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// maxRerankSummaryLength ограничение описания новости в запросе выбора
+const maxRerankSummaryLength = 300
+
+// Rerank выбор моделью лучшей новости из кандидатов
+type Rerank struct {
+	// Index номер выбранной новости в списке кандидатов, начиная с 0
+	Index int
+	// Reason короткое обоснование выбора
+	Reason string
+}
+
+// rerankPromptData данные для шаблона выбора новости
+type rerankPromptData struct {
+	Query    string
+	Articles []ArticleInfo
+}
+
+// rerankResponse ответ модели: номер новости в списке начинается с 1
+type rerankResponse struct {
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+}
+
+// RerankArticles просит модель выбрать новость, лучше всего подходящую к запросу.
+// Возвращает ошибку, если модель недоступна или ответила не по схеме.
+func (w postWriter) RerankArticles(ctx context.Context, query string, candidates []ArticleInfo) (Rerank, error) {
+	if len(candidates) == 0 {
+		return Rerank{}, fmt.Errorf("нет кандидатов для выбора")
+	}
+
+	articles := make([]ArticleInfo, len(candidates))
+	for i, candidate := range candidates {
+		articles[i] = ArticleInfo{
+			Title:   strings.TrimSpace(candidate.Title),
+			Summary: truncateWords(strings.TrimSpace(candidate.Summary), maxRerankSummaryLength),
+		}
+	}
+
+	messages, err := promptMessages(promptRerankSystem, promptRerankUser, rerankPromptData{
+		Query:    strings.TrimSpace(query),
+		Articles: articles,
+	})
+	if err != nil {
+		return Rerank{}, err
+	}
+
+	response, err := w.completer.CompleteMessages(withRequestType(WithModelTier(ctx, TierLite), RequestRerank), messages, 0, 150)
+	if err != nil {
+		return Rerank{}, fmt.Errorf("ошибка запроса выбора новости: %w", err)
+	}
+
+	return parseRerank(response, len(candidates))
+}
+
+// parseRerank разбирает ответ модели и проверяет номер новости
+func parseRerank(response string, candidates int) (Rerank, error) {
+	text, err := extractJSONObject(response)
+	if err != nil {
+		return Rerank{}, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.DisallowUnknownFields()
+
+	var parsed rerankResponse
+	if err := decoder.Decode(&parsed); err != nil {
+		return Rerank{}, fmt.Errorf("некорректный ответ выбора новости: %w", err)
+	}
+	if parsed.Index < 1 || parsed.Index > candidates {
+		return Rerank{}, fmt.Errorf("номер новости вне списка: %d из %d", parsed.Index, candidates)
+	}
+
+	log.Printf("[AI] Модель выбрала новость %d из %d: %s", parsed.Index, candidates, parsed.Reason)
+	return Rerank{Index: parsed.Index - 1, Reason: strings.TrimSpace(parsed.Reason)}, nil
+}
@@ -0,0 +1,90 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseRerank(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     Rerank
+		wantErr  bool
+	}{
+		{"первая новость", `{"index": 1, "reason": "точное совпадение"}`, Rerank{Index: 0, Reason: "точное совпадение"}, false},
+		{"последняя новость", `{"index": 3, "reason": "  свежее  "}`, Rerank{Index: 2, Reason: "свежее"}, false},
+		{"блок кода", "```json\n{\"index\": 2, \"reason\": \"по теме\"}\n```", Rerank{Index: 1, Reason: "по теме"}, false},
+		{"текст вокруг", "Выбираю:\n{\"index\": 2, \"reason\": \"по теме\"}\nГотово.", Rerank{Index: 1, Reason: "по теме"}, false},
+		{"номер с нуля", `{"index": 0, "reason": "первая"}`, Rerank{}, true},
+		{"номер за списком", `{"index": 4, "reason": "лишняя"}`, Rerank{}, true},
+		{"отрицательный номер", `{"index": -1, "reason": ""}`, Rerank{}, true},
+		{"лишнее поле", `{"index": 1, "reason": "", "score": 0.9}`, Rerank{}, true},
+		{"номер строкой", `{"index": "1", "reason": ""}`, Rerank{}, true},
+		{"не JSON", "Лучше всего подходит вторая новость", Rerank{}, true},
+		{"оборванный JSON", `{"index": 1, "reason": "обор`, Rerank{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRerank(tt.response, 3)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRerank() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseRerank() = %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRerankArticles(t *testing.T) {
+	writer, completer := newScriptedWriter(`{"index": 2, "reason": "о решении ЦБ"}`)
+	candidates := []ArticleInfo{
+		{Title: " Погода на выходные ", Summary: "Дожди"},
+		{Title: "ЦБ сохранил ставку", Summary: strings.Repeat("подробности решения ", 40), URL: "https://example.com/cb"},
+	}
+
+	choice, err := writer.RerankArticles(context.Background(), " ставка ЦБ ", candidates)
+	if err != nil {
+		t.Fatalf("RerankArticles: %v", err)
+	}
+	if choice.Index != 1 || choice.Reason != "о решении ЦБ" {
+		t.Errorf("выбор = %+v", choice)
+	}
+
+	user := completer.requests[0][1].Content
+	if !strings.Contains(user, "ставка ЦБ") || !strings.Contains(user, "Погода на выходные") {
+		t.Errorf("запрос без темы или заголовков: %q", user)
+	}
+	if strings.Contains(user, "https://example.com/cb") {
+		t.Error("в запрос попала ссылка статьи")
+	}
+	if summary := strings.Repeat("подробности решения ", 40); strings.Contains(user, strings.TrimSpace(summary)) {
+		t.Error("длинное описание не сокращено")
+	}
+}
+
+func TestRerankArticlesErrors(t *testing.T) {
+	writer, completer := newScriptedWriter()
+	if _, err := writer.RerankArticles(context.Background(), "ставка", nil); err == nil {
+		t.Error("выбор без кандидатов без ошибки")
+	}
+	if completer.calls() != 0 {
+		t.Error("запрос к модели без кандидатов")
+	}
+
+	failure := errors.New("модель недоступна")
+	writer, completer = newScriptedWriter()
+	completer.err = failure
+	if _, err := writer.RerankArticles(context.Background(), "ставка", []ArticleInfo{{Title: "A"}, {Title: "B"}}); !errors.Is(err, failure) {
+		t.Errorf("ошибка модели = %v", err)
+	}
+
+	writer, _ = newScriptedWriter(`{"index": 5, "reason": "нет такой"}`)
+	if _, err := writer.RerankArticles(context.Background(), "ставка", []ArticleInfo{{Title: "A"}, {Title: "B"}}); err == nil {
+		t.Error("номер вне списка принят")
+	}
+}
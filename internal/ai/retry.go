@@ -0,0 +1,117 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// aiMaxAttempts максимальное число попыток запроса к модели
+	aiMaxAttempts = 3
+	// aiRetryBaseDelay базовая задержка перед повтором
+	aiRetryBaseDelay = 1 * time.Second
+	// aiMaxRetryDelay максимальная задержка перед повтором
+	aiMaxRetryDelay = 10 * time.Second
+)
+
+// AIError ошибка запроса к модели. Retryable отличает временные ошибки
+// (перегрузка, 5xx, сеть) от постоянных (неверный ключ, некорректный запрос).
+type AIError struct {
+	StatusCode int
+	Retryable  bool
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *AIError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *AIError) Unwrap() error {
+	return e.Err
+}
+
+// isRetryableStatus проверяет, имеет ли смысл повторить запрос с таким статусом
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// newStatusError создает AIError для ответа с ошибочным статусом
+func newStatusError(resp *http.Response, message string) *AIError {
+	err := fmt.Errorf("ошибка API: статус %d", resp.StatusCode)
+	if message != "" {
+		err = fmt.Errorf("ошибка API: статус %d: %s", resp.StatusCode, message)
+	}
+
+	aiErr := &AIError{
+		StatusCode: resp.StatusCode,
+		Retryable:  isRetryableStatus(resp.StatusCode),
+		Err:        err,
+	}
+	if seconds, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil && seconds > 0 {
+		aiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return aiErr
+}
+
+// newNetworkError создает AIError для сетевой ошибки.
+// Отмена или истечение контекста не повторяются.
+func newNetworkError(ctx context.Context, err error) *AIError {
+	return &AIError{
+		Retryable: ctx.Err() == nil,
+		Err:       fmt.Errorf("ошибка запроса: %w", err),
+	}
+}
+
+// IsRetryable сообщает, что запрос не удался из-за временной проблемы сервиса
+func IsRetryable(err error) bool {
+	var aiErr *AIError
+	return errors.As(err, &aiErr) && aiErr.Retryable
+}
+
+// withRetry повторяет запрос при временных ошибках с экспоненциальной задержкой.
+// Повтор не выполняется, если задержка не укладывается в дедлайн контекста.
+func withRetry(ctx context.Context, call func() (string, Usage, error)) (string, Usage, error) {
+	for attempt := 1; ; attempt++ {
+		text, usage, err := call()
+		if err == nil || attempt >= aiMaxAttempts || !IsRetryable(err) {
+			return text, usage, err
+		}
+
+		delay := aiRetryDelay(attempt, err)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			log.Printf("[AI] ⚠️ Повтор не уложится в лимит времени, прекращаем попытки: %v", err)
+			return text, usage, err
+		}
+
+		log.Printf("[AI] ⚠️ Попытка %d/%d не удалась: %v, повтор через %v", attempt, aiMaxAttempts, err, delay)
+		select {
+		case <-ctx.Done():
+			return "", Usage{}, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// aiRetryDelay вычисляет задержку перед повтором с учетом Retry-After
+func aiRetryDelay(attempt int, err error) time.Duration {
+	var aiErr *AIError
+	if errors.As(err, &aiErr) && aiErr.RetryAfter > 0 {
+		return min(aiErr.RetryAfter, aiMaxRetryDelay)
+	}
+
+	delay := aiRetryBaseDelay << (attempt - 1)
+	jitter := time.Duration(rand.Int64N(int64(delay / 2)))
+	return min(delay+jitter, aiMaxRetryDelay)
+}
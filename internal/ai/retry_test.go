@@ -0,0 +1,160 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestRetriesTransientStatus(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(readFixture(t, "native_completion.json"))
+	}))
+	defer server.Close()
+
+	client := newTestYandexClient(t, server.URL, protocolNative)
+	if _, err := client.Complete(context.Background(), "тест", 0.5, 10); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("запросов = %d, ожидалось 2", got)
+	}
+}
+
+func TestRequestDoesNotRetryPermanentStatus(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	client := newTestYandexClient(t, server.URL, protocolNative)
+	_, err := client.Complete(context.Background(), "тест", 0.5, 10)
+	if err == nil || IsRetryable(err) {
+		t.Fatalf("ошибка = %v, ожидалась постоянная", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("запросов = %d, ожидался 1", got)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	transient := &AIError{StatusCode: http.StatusTooManyRequests, Retryable: true, RetryAfter: time.Millisecond, Err: errors.New("перегрузка")}
+	permanent := &AIError{StatusCode: http.StatusBadRequest, Err: errors.New("некорректный запрос")}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"успех с первой попытки", []error{nil}, 1, nil},
+		{"успех после двух сбоев", []error{transient, transient, nil}, 3, nil},
+		{"все попытки неудачны", []error{transient, transient, transient, nil}, aiMaxAttempts, transient},
+		{"постоянная ошибка не повторяется", []error{permanent, nil}, 1, permanent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			_, _, err := withRetry(context.Background(), func() (string, Usage, error) {
+				err := tt.errs[calls]
+				calls++
+				return "ok", Usage{}, err
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("попыток = %d, ожидалось %d", calls, tt.wantCalls)
+			}
+			if err != tt.wantErr {
+				t.Errorf("ошибка = %v, ожидалось %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithRetrySkipsDelayBeyondDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	calls := 0
+	started := time.Now()
+	_, _, err := withRetry(ctx, func() (string, Usage, error) {
+		calls++
+		return "", Usage{}, &AIError{Retryable: true, RetryAfter: 5 * time.Second, Err: errors.New("перегрузка")}
+	})
+	if err == nil || calls != 1 {
+		t.Fatalf("попыток = %d, ошибка = %v", calls, err)
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("повтор ждал %v, хотя не укладывался в дедлайн", elapsed)
+	}
+}
+
+func TestNewStatusError(t *testing.T) {
+	tests := []struct {
+		status     int
+		retryAfter string
+		retryable  bool
+		wantAfter  time.Duration
+	}{
+		{http.StatusTooManyRequests, "7", true, 7 * time.Second},
+		{http.StatusInternalServerError, "", true, 0},
+		{http.StatusBadGateway, "abc", true, 0},
+		{http.StatusServiceUnavailable, "-1", true, 0},
+		{http.StatusGatewayTimeout, "", true, 0},
+		{http.StatusBadRequest, "", false, 0},
+		{http.StatusUnauthorized, "", false, 0},
+		{http.StatusNotFound, "", false, 0},
+	}
+
+	for _, tt := range tests {
+		resp := &http.Response{StatusCode: tt.status, Header: http.Header{}}
+		if tt.retryAfter != "" {
+			resp.Header.Set("Retry-After", tt.retryAfter)
+		}
+		err := newStatusError(resp, "")
+		if err.StatusCode != tt.status || err.Retryable != tt.retryable || err.RetryAfter != tt.wantAfter {
+			t.Errorf("статус %d: %+v", tt.status, err)
+		}
+	}
+}
+
+func TestNewNetworkErrorAfterCancel(t *testing.T) {
+	if err := newNetworkError(context.Background(), errors.New("connection reset")); !err.Retryable {
+		t.Error("сетевая ошибка должна повторяться")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := newNetworkError(ctx, context.Canceled); err.Retryable {
+		t.Error("отмененный запрос не должен повторяться")
+	}
+}
+
+func TestAIRetryDelay(t *testing.T) {
+	for attempt := 1; attempt <= 3; attempt++ {
+		base := aiRetryBaseDelay << (attempt - 1)
+		got := aiRetryDelay(attempt, errors.New("сбой"))
+		if got < base || got >= base+base/2 {
+			t.Errorf("попытка %d: задержка %v вне [%v, %v)", attempt, got, base, base+base/2)
+		}
+	}
+
+	if got := aiRetryDelay(1, &AIError{RetryAfter: time.Hour}); got != aiMaxRetryDelay {
+		t.Errorf("Retry-After не ограничен: %v", got)
+	}
+	if got := aiRetryDelay(5, errors.New("сбой")); got != aiMaxRetryDelay {
+		t.Errorf("задержка не ограничена: %v", got)
+	}
+}
@@ -0,0 +1,261 @@
+package ai
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+//go:embed brand_safety.json
+var defaultBrandSafetyRules []byte
+
+const (
+	// SafetyModeRegenerate при нарушении пост перегенерируется один раз (по умолчанию)
+	SafetyModeRegenerate = "regenerate"
+	// SafetyModeWarn пост отдается как есть с предупреждением
+	SafetyModeWarn = "warn"
+	// SafetyModeOff проверка выключена
+	SafetyModeOff = "off"
+)
+
+// SafetyCheck результат проверки готового поста
+type SafetyCheck struct {
+	Safe     bool   `json:"safe"`
+	Category string `json:"category"`
+	Reason   string `json:"reason"`
+}
+
+// safetyPromptData данные для шаблона проверки поста
+type safetyPromptData struct {
+	Text string
+}
+
+var (
+	brandSafetyRules   []ModerationRule
+	brandSafetyRulesMu sync.RWMutex
+)
+
+// lookalikes латинские буквы и цифры, которыми заменяют кириллицу, чтобы обойти фильтр
+var lookalikes = map[rune]rune{
+	'a': 'а', 'b': 'в', 'c': 'с', 'e': 'е', 'h': 'н', 'k': 'к', 'm': 'м', 'n': 'п',
+	'o': 'о', 'p': 'р', 't': 'т', 'u': 'и', 'x': 'х', 'y': 'у',
+	'0': 'о', '3': 'з', '6': 'б', '@': 'а', 'ё': 'е',
+}
+
+func init() {
+	if err := ReloadBrandSafetyRules(); err != nil {
+		log.Printf("[AI] ❌ Ошибка загрузки списка стоп-слов: %v", err)
+	}
+}
+
+// ReloadBrandSafetyRules загружает стоп-слова из BRAND_SAFETY_FILE или встроенный список.
+// Шаблон совпадает с началом слова; шаблон вида "=слово" — только со словом целиком.
+// При ошибке остается ранее загруженный список.
+func ReloadBrandSafetyRules() error {
+	data := defaultBrandSafetyRules
+	source := "встроенные"
+	if path := currentConfig().BrandSafetyFile; path != "" {
+		fileData, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения стоп-слов %s: %w", path, err)
+		}
+		data, source = fileData, path
+	}
+
+	var rules []ModerationRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("ошибка разбора стоп-слов (%s): %w", source, err)
+	}
+
+	brandSafetyRulesMu.Lock()
+	brandSafetyRules = rules
+	brandSafetyRulesMu.Unlock()
+
+	log.Printf("[AI] ✅ Загружено %d групп стоп-слов (%s)", len(rules), source)
+	return nil
+}
+
+// SafetyMode возвращает реакцию на нарушение, заданную BrandSafetyMode
+func SafetyMode() string {
+	switch mode := currentConfig().BrandSafetyMode; mode {
+	case SafetyModeWarn, SafetyModeOff:
+		return mode
+	default:
+		return SafetyModeRegenerate
+	}
+}
+
+// aiSafetyCheckEnabled проверяет, включена ли проверка поста через AI
+func aiSafetyCheckEnabled() bool {
+	return currentConfig().BrandSafetyAICheck
+}
+
+// normalizeWords разбивает текст на слова и приводит их к виду, в котором сравниваются
+// стоп-слова: нижний регистр, латиница и цифры-двойники заменены кириллицей,
+// точки, дефисы и подчеркивания внутри слова убраны, повторы букв схлопнуты.
+// Звездочка сохраняется и при сравнении совпадает с любой буквой.
+func normalizeWords(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("*@.-_", r)
+	})
+
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		// Звездочки по краям слова — разметка *жирного*, а не замена буквы
+		field = strings.Trim(field, "*")
+
+		var word []rune
+		for _, r := range field {
+			if strings.ContainsRune(".-_", r) {
+				continue
+			}
+			if replacement, ok := lookalikes[r]; ok {
+				r = replacement
+			}
+			if len(word) > 0 && word[len(word)-1] == r {
+				continue
+			}
+			word = append(word, r)
+		}
+		if len(word) > 0 {
+			words = append(words, string(word))
+		}
+	}
+	return words
+}
+
+// matchWord сравнивает слово текста со словом шаблона; * в тексте совпадает с любой буквой
+func matchWord(word, pattern []rune, exact bool) bool {
+	if len(word) < len(pattern) || (exact && len(word) != len(pattern)) {
+		return false
+	}
+	for i, r := range pattern {
+		if word[i] != r && word[i] != '*' {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPattern ищет в словах текста последовательность слов шаблона.
+// Все слова шаблона, кроме последнего, должны совпасть целиком.
+func matchPattern(words []string, pattern string) bool {
+	exact := strings.HasPrefix(pattern, "=")
+	patternWords := normalizeWords(strings.TrimPrefix(pattern, "="))
+	if len(patternWords) == 0 {
+		return false
+	}
+
+	for start := 0; start+len(patternWords) <= len(words); start++ {
+		matched := true
+		for i, patternWord := range patternWords {
+			last := i == len(patternWords)-1
+			if !matchWord([]rune(words[start+i]), []rune(patternWord), exact || !last) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSafetyRules проверяет текст по списку стоп-слов
+func checkSafetyRules(text string) SafetyCheck {
+	words := normalizeWords(text)
+
+	brandSafetyRulesMu.RLock()
+	defer brandSafetyRulesMu.RUnlock()
+
+	for _, rule := range brandSafetyRules {
+		for _, pattern := range rule.Patterns {
+			if pattern != "" && matchPattern(words, pattern) {
+				return SafetyCheck{Safe: false, Category: rule.Category, Reason: rule.Reason}
+			}
+		}
+	}
+	return SafetyCheck{Safe: true}
+}
+
+// checkPostSafety проверяет готовый пост: по стоп-словам и, если включено, через lite-модель.
+// Ошибка AI-проверки не блокирует пост.
+func (w postWriter) checkPostSafety(ctx context.Context, post Post) SafetyCheck {
+	text := post.Text() + "\n" + strings.Join(post.Hashtags, " ")
+	if check := checkSafetyRules(text); !check.Safe {
+		return check
+	}
+
+	if !aiSafetyCheckEnabled() {
+		return SafetyCheck{Safe: true}
+	}
+
+	messages, err := promptMessages(promptSafetySystem, promptSafetyUser, safetyPromptData{Text: text})
+	if err != nil {
+		log.Printf("[AI] ⚠️ Проверка поста пропущена: %v", err)
+		return SafetyCheck{Safe: true}
+	}
+
+	response, err := w.completer.CompleteMessages(withRequestType(WithModelTier(ctx, TierLite), RequestSafetyCheck), messages, 0, 100)
+	if err != nil {
+		log.Printf("[AI] ⚠️ Ошибка проверки поста, пропускаем: %v", err)
+		return SafetyCheck{Safe: true}
+	}
+
+	jsonText, err := extractJSONObject(response)
+	var check SafetyCheck
+	if err == nil {
+		err = json.Unmarshal([]byte(jsonText), &check)
+	}
+	if err != nil {
+		log.Printf("[AI] ⚠️ Некорректный ответ проверки поста (%v): %s", err, response)
+		return SafetyCheck{Safe: true}
+	}
+	return check
+}
+
+// ensureSafe проверяет пост после генерации. В режиме regenerate при нарушении пост
+// один раз перегенерируется с дополнительной инструкцией; если нарушение осталось
+// или включен режим warn, пост помечается SafetyWarning.
+func (w postWriter) ensureSafe(ctx context.Context, messages []Message, post Post) Post {
+	mode := SafetyMode()
+	if mode == SafetyModeOff || post.Refused {
+		return post
+	}
+
+	check := w.checkPostSafety(ctx, post)
+	if check.Safe {
+		return post
+	}
+	log.Printf("[AI] ⚠️ Пост не прошел проверку (%s: %s)", check.Category, check.Reason)
+
+	if mode == SafetyModeRegenerate {
+		retry := append(slices.Clone(messages),
+			Message{Role: "assistant", Content: post.Text()},
+			Message{Role: "user", Content: fmt.Sprintf("В посте есть недопустимые формулировки (%s). "+
+				"Перепиши пост без ненормативной лексики, оскорблений и непроверяемых обещаний. "+
+				"Верни JSON-объект по той же схеме.", check.Category)},
+		)
+		regenerated, err := w.requestPost(ctx, retry, nil)
+		if err == nil && !regenerated.Refused {
+			if recheck := w.checkPostSafety(ctx, regenerated); recheck.Safe {
+				log.Printf("[AI] ✅ Пост перегенерирован и прошел проверку")
+				return regenerated
+			}
+			post = regenerated
+		} else if err != nil {
+			log.Printf("[AI] ⚠️ Перегенерация поста не удалась: %v", err)
+		}
+	}
+
+	post.SafetyWarning = true
+	return post
+}
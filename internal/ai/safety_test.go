@@ -0,0 +1,185 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+// useConfig подменяет настройки пакета на время теста
+func useConfig(t *testing.T, change func(config *AIConfig)) {
+	t.Helper()
+	saved := currentConfig()
+	config := saved
+	change(&config)
+
+	configuredMu.Lock()
+	configured = config
+	configuredMu.Unlock()
+	t.Cleanup(func() {
+		configuredMu.Lock()
+		configured = saved
+		configuredMu.Unlock()
+	})
+}
+
+func TestCheckSafetyRules(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		category string
+	}{
+		{"мат", "Это просто пиздец какой-то", "ненормативная лексика"},
+		{"латиница вместо кириллицы", "Полный пuздeц на рынке", "ненормативная лексика"},
+		{"латиница целиком", "xyй знает, что будет", "ненормативная лексика"},
+		{"цифры вместо букв", "3аебали эти новости", "ненормативная лексика"},
+		{"точки между буквами", "Ну и с.у.к.а же этот курс", "оскорбления"},
+		{"дефисы и подчеркивания", "е-б_а-т-ь какой рост", "ненормативная лексика"},
+		{"повтор букв", "сууууука, опять подорожало", "оскорбления"},
+		{"звездочка вместо буквы", "бл*ть, опять", "ненормативная лексика"},
+		{"ё вместо е", "Ёбаный стыд", "ненормативная лексика"},
+		{"заглавные", "ДЕБИЛЫ у руля", "оскорбления"},
+		{"английский мат", "What the FUCK", "ненормативная лексика"},
+		{"фраза", "Гарантированный доход 30% в месяц", "непроверяемые утверждения"},
+		{"фраза с другим окончанием", "Обещают гарантированный доходом", "непроверяемые утверждения"},
+		{"фраза с лишними пробелами", "гарантированная   прибыль", "непроверяемые утверждения"},
+
+		{"обычный текст", "Банк России сохранил ключевую ставку", ""},
+		{"похожее слово целиком", "Сукно подорожало на 10%", ""},
+		{"стоп-слово внутри слова", "Потребление электроэнергии выросло", ""},
+		{"точное слово с окончанием", "Уродливая архитектура", ""},
+		{"разметка жирного", "*Курс* рубля *укрепился*", ""},
+		{"слова фразы порознь", "Доход не гарантированный", ""},
+		{"английское слово, похожее на мат", "Shitake mushrooms", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := checkSafetyRules(tt.text)
+			if tt.category == "" {
+				if !check.Safe {
+					t.Errorf("безопасный текст отклонен: %+v", check)
+				}
+				return
+			}
+			if check.Safe || check.Category != tt.category {
+				t.Errorf("checkSafetyRules(%q) = %+v, ожидалась категория %q", tt.text, check, tt.category)
+			}
+		})
+	}
+}
+
+func TestNormalizeWords(t *testing.T) {
+	got := normalizeWords("*Ахтунг*: ccyкa, п.о.х.у.й, 3 ёжика")
+	want := []string{"ахтунг", "сука", "похуй", "з", "ежика"}
+	if len(got) != len(want) {
+		t.Fatalf("normalizeWords = %q, ожидалось %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("слово %d = %q, ожидалось %q", i, got[i], want[i])
+		}
+	}
+}
+
+// unsafePost пост с матом в формате ответа модели
+func unsafePost(t *testing.T) string {
+	t.Helper()
+	data, err := json.Marshal(map[string]any{
+		"title":         "Рынок упал",
+		"body_markdown": "Индекс Мосбиржи обвалился, пиздец полный.",
+		"hashtags":      []string{"#рынок"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestEnsureSafeModes(t *testing.T) {
+	saved := posts
+	posts = newPostCache(0, 0)
+	t.Cleanup(func() { posts = saved })
+
+	valid := string(readFixture(t, "post_valid.json"))
+	tests := []struct {
+		name        string
+		mode        string
+		responses   []string
+		wantCalls   int
+		wantWarning bool
+		wantTitle   string
+	}{
+		{"перегенерация исправила пост", SafetyModeRegenerate, []string{unsafePost(t), valid}, 2, false, "🔥 Центробанк сохранил ключевую ставку"},
+		{"перегенерация не помогла", SafetyModeRegenerate, []string{unsafePost(t), unsafePost(t)}, 2, true, "Рынок упал"},
+		{"перегенерация не удалась", SafetyModeRegenerate, []string{unsafePost(t)}, 2, true, "Рынок упал"},
+		{"предупреждение", SafetyModeWarn, []string{unsafePost(t)}, 1, true, "Рынок упал"},
+		{"проверка выключена", SafetyModeOff, []string{unsafePost(t)}, 1, false, "Рынок упал"},
+		{"чистый пост", SafetyModeRegenerate, []string{valid}, 1, false, "🔥 Центробанк сохранил ключевую ставку"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfig(t, func(config *AIConfig) {
+				config.BrandSafetyMode = tt.mode
+				config.BrandSafetyAICheck = false
+			})
+
+			writer, completer := newScriptedWriter(tt.responses...)
+			post, err := writer.GeneratePost(context.Background(), "рынок", ArticleInfo{Title: "Индекс упал"})
+			if err != nil {
+				t.Fatalf("GeneratePost: %v", err)
+			}
+			if completer.calls() != tt.wantCalls {
+				t.Errorf("запросов к модели %d, ожидалось %d", completer.calls(), tt.wantCalls)
+			}
+			if post.SafetyWarning != tt.wantWarning || post.Title != tt.wantTitle {
+				t.Errorf("пост %q, предупреждение %t", post.Title, post.SafetyWarning)
+			}
+		})
+	}
+}
+
+func TestCheckPostSafetyWithModel(t *testing.T) {
+	useConfig(t, func(config *AIConfig) { config.BrandSafetyAICheck = true })
+	post := Post{Title: "Новое лекарство", Body: "Препарат помогает всем"}
+
+	writer, _ := newScriptedWriter(`{"safe": false, "category": "непроверяемые утверждения", "reason": "обещание излечения"}`)
+	if check := writer.checkPostSafety(context.Background(), post); check.Safe || check.Category != "непроверяемые утверждения" {
+		t.Errorf("проверка моделью = %+v", check)
+	}
+
+	// Ошибка или непонятный ответ модели не блокирует пост
+	writer, _ = newScriptedWriter("не знаю")
+	if check := writer.checkPostSafety(context.Background(), post); !check.Safe {
+		t.Errorf("непонятный ответ заблокировал пост: %+v", check)
+	}
+	writer, _ = newScriptedWriter()
+	if check := writer.checkPostSafety(context.Background(), post); !check.Safe {
+		t.Errorf("ошибка модели заблокировала пост: %+v", check)
+	}
+
+	// Стоп-слова проверяются без запроса к модели
+	writer, completer := newScriptedWriter()
+	if check := writer.checkPostSafety(context.Background(), Post{Body: "Какой пиздец"}); check.Safe {
+		t.Error("мат пропущен")
+	}
+	if completer.calls() != 0 {
+		t.Error("запрос к модели при найденном стоп-слове")
+	}
+}
+
+func TestSafetyMode(t *testing.T) {
+	for mode, want := range map[string]string{
+		"":                   SafetyModeRegenerate,
+		"что-то":             SafetyModeRegenerate,
+		SafetyModeWarn:       SafetyModeWarn,
+		SafetyModeOff:        SafetyModeOff,
+		SafetyModeRegenerate: SafetyModeRegenerate,
+	} {
+		useConfig(t, func(config *AIConfig) { config.BrandSafetyMode = mode })
+		if got := SafetyMode(); got != want {
+			t.Errorf("SafetyMode(%q) = %q, ожидалось %q", mode, got, want)
+		}
+	}
+}
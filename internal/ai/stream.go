@@ -0,0 +1,195 @@
+package ai
+
+import (
+	"AIGenerator/internal/httpx"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrStreamingUnsupported провайдер не поддерживает потоковую генерацию
+var ErrStreamingUnsupported = errors.New("потоковая генерация не поддерживается")
+
+// streamCompleter низкоуровневый потоковый запрос к модели.
+// onText вызывается с накопленным на текущий момент текстом.
+type streamCompleter interface {
+	CompleteMessagesStream(ctx context.Context, messages []Message, temperature float64, maxTokens int, onText func(string)) (string, error)
+}
+
+// chatCompletionChunk фрагмент потокового ответа OpenAI Chat Completions
+type chatCompletionChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// postStream отправляет потоковый запрос и возвращает ответ для чтения.
+// Статусы, которыми серверы отвергают потоковый режим, превращаются в ErrStreamingUnsupported.
+func postStream(ctx context.Context, client *http.Client, proxyURL *url.URL, endpoint string, headers map[string]string, payload any) (*http.Response, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("[AI] ❌ Ошибка маршалинга запроса: %v", err)
+		return nil, fmt.Errorf("ошибка маршалинга: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		log.Printf("[AI] ❌ Ошибка создания запроса: %v", err)
+		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		err = httpx.WrapProxyError(err, proxyURL)
+		log.Printf("[AI] ❌ Ошибка HTTP запроса: %v", err)
+		return nil, newNetworkError(ctx, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		log.Printf("[AI] ❌ Ошибка API (поток): статус %d, тело: %s", resp.StatusCode, string(body))
+		switch resp.StatusCode {
+		case http.StatusBadRequest, http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+			return nil, ErrStreamingUnsupported
+		}
+		return nil, newStatusError(resp, "")
+	}
+
+	return resp, nil
+}
+
+// streamChatCompletion выполняет потоковый запрос к OpenAI-совместимому endpoint (SSE).
+// Если сервер ответил обычным JSON, ответ разбирается как непотоковый.
+func streamChatCompletion(ctx context.Context, client *http.Client, proxyURL *url.URL, endpoint string, headers map[string]string, request ChatCompletionRequest, onText func(string)) (string, Usage, error) {
+	request.Stream = true
+	resp, err := postStream(ctx, client, proxyURL, endpoint, headers, request)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	if !strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", Usage{}, fmt.Errorf("ошибка чтения ответа: %w", err)
+		}
+		return parseChatCompletion(body)
+	}
+
+	return readSSE(resp.Body, onText)
+}
+
+// readSSE собирает текст из SSE-потока OpenAI Chat Completions
+func readSSE(body io.Reader, onText func(string)) (string, Usage, error) {
+	var text strings.Builder
+	var usage Usage
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk chatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			log.Printf("[AI] ⚠️ Пропущен некорректный фрагмент потока: %v", err)
+			continue
+		}
+		if chunk.Usage != nil {
+			usage = Usage{
+				InputTokens:      chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+		if len(chunk.Choices) > 0 && chunk.Choices[0].Delta.Content != "" {
+			text.WriteString(chunk.Choices[0].Delta.Content)
+			onText(text.String())
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", usage, fmt.Errorf("ошибка чтения потока: %w", err)
+	}
+
+	if text.Len() == 0 {
+		return "", usage, fmt.Errorf("пустой ответ от GPT")
+	}
+	return text.String(), usage, nil
+}
+
+// streamNative выполняет потоковый запрос к foundationModels/v1/completion.
+// API присылает последовательность JSON-объектов, каждый с накопленным текстом.
+func (c *YandexGPTClient) streamNative(ctx context.Context, modelURI string, messages []Message, temperature float64, maxTokens int, onText func(string)) (string, Usage, error) {
+	request := newNativeRequest(modelURI, messages, temperature, maxTokens)
+	request.CompletionOptions.Stream = true
+
+	resp, err := postStream(ctx, c.httpClient, c.proxyURL, c.baseURL, c.nativeHeaders(), request)
+	if err != nil {
+		return "", Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	return readNativeStream(resp.Body, onText)
+}
+
+// readNativeStream собирает ответ из потока foundationModels
+func readNativeStream(body io.Reader, onText func(string)) (string, Usage, error) {
+	var text string
+	var usage Usage
+
+	decoder := json.NewDecoder(body)
+	for {
+		var chunk NativeCompletionResponse
+		if err := decoder.Decode(&chunk); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", usage, fmt.Errorf("ошибка чтения потока: %w", err)
+		}
+
+		if len(chunk.Result.Alternatives) == 0 {
+			continue
+		}
+		alternative := chunk.Result.Alternatives[0]
+		if alternative.Status == nativeContentFilterStatus {
+			log.Printf("[AI] ⚠️ Ответ заблокирован фильтром контента YandexGPT")
+		}
+		if alternative.Message.Text != text {
+			text = alternative.Message.Text
+			onText(text)
+		}
+		usage = nativeUsage(chunk)
+	}
+
+	if text == "" {
+		return "", usage, fmt.Errorf("пустой ответ от GPT")
+	}
+	return text, usage, nil
+}
@@ -0,0 +1,128 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadSSE(t *testing.T) {
+	stream := strings.Join([]string{
+		`: keep-alive`,
+		`data: {"choices": [{"delta": {"role": "assistant"}}]}`,
+		``,
+		`data: {"choices": [{"delta": {"content": "⚡️ Центробанк"}}]}`,
+		`data: не JSON`,
+		`data: {"choices": [{"delta": {"content": " сохранил"}}]}`,
+		`data: {"choices": [{"delta": {"content": " ставку"}}]}`,
+		`data: {"choices": [], "usage": {"prompt_tokens": 20, "completion_tokens": 6, "total_tokens": 26}}`,
+		`data: [DONE]`,
+		`data: {"choices": [{"delta": {"content": " после DONE"}}]}`,
+	}, "\n")
+
+	var partials []string
+	text, usage, err := readSSE(strings.NewReader(stream), func(text string) { partials = append(partials, text) })
+	if err != nil {
+		t.Fatalf("readSSE: %v", err)
+	}
+	if text != "⚡️ Центробанк сохранил ставку" {
+		t.Errorf("текст = %q", text)
+	}
+	want := []string{"⚡️ Центробанк", "⚡️ Центробанк сохранил", "⚡️ Центробанк сохранил ставку"}
+	if fmt.Sprint(partials) != fmt.Sprint(want) {
+		t.Errorf("промежуточный текст = %q, ожидалось %q", partials, want)
+	}
+	if usage.TotalTokens != 26 {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	if _, _, err := readSSE(strings.NewReader("data: [DONE]\n"), func(string) {}); err == nil {
+		t.Error("пустой поток принят")
+	}
+}
+
+func TestReadNativeStream(t *testing.T) {
+	chunk := func(text, total string) string {
+		return fmt.Sprintf(`{"result": {"alternatives": [{"message": {"role": "assistant", "text": %q}, "status": "ALTERNATIVE_STATUS_PARTIAL"}], "usage": {"totalTokens": %q}}}`, text, total)
+	}
+	// Нативный API присылает накопленный текст целиком, повторы не передаются дальше
+	stream := chunk("⚡️ Центробанк", "5") + "\n" + chunk("⚡️ Центробанк", "5") + "\n" + chunk("⚡️ Центробанк сохранил ставку", "9")
+
+	var partials []string
+	text, usage, err := readNativeStream(strings.NewReader(stream), func(text string) { partials = append(partials, text) })
+	if err != nil {
+		t.Fatalf("readNativeStream: %v", err)
+	}
+	if text != "⚡️ Центробанк сохранил ставку" || len(partials) != 2 {
+		t.Errorf("текст = %q, промежуточный = %q", text, partials)
+	}
+	if usage.TotalTokens != 9 {
+		t.Errorf("usage = %+v", usage)
+	}
+
+	if _, _, err := readNativeStream(strings.NewReader(chunk("начало", "1")+"{обрыв"), func(string) {}); err == nil {
+		t.Error("оборванный поток принят")
+	}
+}
+
+func TestPostPreview(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"свободный текст", "⚡️ Просто текст", "⚡️ Просто текст"},
+		{"только заголовок", `{"title": "Ставка`, "Ставка"},
+		{"заголовок и начало текста", `{"title": "Ставка", "body_markdown": "Первая строка\nвтор`, "Ставка\n\nПервая строка\nвтор"},
+		{"оборванное экранирование", `{"title": "Ставка", "body_markdown": "Цитата \"ЦБ\" и \u04`, "Ставка\n\nЦитата \"ЦБ\" и "},
+		{"код-блок", "```json\n{\"title\": \"Ставка\", \"body_markdown\": \"Текст\"}\n```", "Ставка\n\nТекст"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := postPreview(tt.text); got != tt.want {
+				t.Errorf("postPreview(%q) = %q, ожидалось %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeneratePostStreamFallsBackWithoutStreaming(t *testing.T) {
+	saved := posts
+	posts = newPostCache(0, 0)
+	t.Cleanup(func() { posts = saved })
+
+	var streamed, plain int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request NativeCompletionRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		if request.CompletionOptions.Stream {
+			streamed++
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		plain++
+		fmt.Fprint(w, `{"result": {"alternatives": [{"message": {"role": "assistant", "text": "{\"title\": \"Ставка\", \"body_markdown\": \"Текст\", \"hashtags\": [], \"refused\": false, \"refusal_reason\": \"\"}"}}]}}`)
+	}))
+	defer server.Close()
+
+	client := newTestYandexClient(t, server.URL, protocolNative)
+	partial := make(chan string, 10)
+	post, err := client.GeneratePostStream(context.Background(), "ставка", ArticleInfo{Title: "ЦБ"}, partial)
+	if err != nil {
+		t.Fatalf("GeneratePostStream: %v", err)
+	}
+	if post.Title != "Ставка" {
+		t.Errorf("пост = %+v", post)
+	}
+	if streamed != 1 || plain != 1 {
+		t.Errorf("потоковых запросов %d, обычных %d; ожидалось по одному", streamed, plain)
+	}
+	if _, open := <-partial; open {
+		t.Error("канал partial не закрыт")
+	}
+}
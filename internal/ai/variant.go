@@ -0,0 +1,71 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+// PromptVariant системный промпт поста по ключевым словам из эксперимента:
+// заменяет шаблон post_system для генераций с этим вариантом в контексте
+type PromptVariant struct {
+	// Name имя варианта для журнала генераций
+	Name string
+	tmpl *template.Template
+}
+
+// LoadPromptVariant читает шаблон системного промпта поста для эксперимента. Относительный
+// путь ищется в каталоге PromptsDir. Шаблон сразу заполняется данными примера, чтобы
+// ошибка в нем обнаружилась при запуске эксперимента, а не на генерациях пользователей.
+func LoadPromptVariant(name, path string) (PromptVariant, error) {
+	if dir := currentConfig().PromptsDir; dir != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PromptVariant{}, fmt.Errorf("ошибка чтения шаблона %s: %w", path, err)
+	}
+
+	tmpl, err := template.New(promptPostSystem).Funcs(promptFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return PromptVariant{}, fmt.Errorf("ошибка разбора шаблона %s: %w", path, err)
+	}
+
+	language := LanguageOrDefault(DefaultLanguage)
+	sample := postPromptData{Keywords: "пример", Title: "Заголовок", Summary: "Описание", Language: language}
+	if _, err := executePrompt(tmpl, sample); err != nil {
+		return PromptVariant{}, fmt.Errorf("шаблон %s не заполняется: %w", path, err)
+	}
+	return PromptVariant{Name: name, tmpl: tmpl}, nil
+}
+
+type promptVariantKey struct{}
+
+// WithPromptVariant задает системный промпт поста из эксперимента
+func WithPromptVariant(ctx context.Context, variant PromptVariant) context.Context {
+	return context.WithValue(ctx, promptVariantKey{}, variant)
+}
+
+// postPromptMessages собирает промпт поста по ключевым словам: системный — из варианта
+// эксперимента, если он задан, иначе из шаблона post_system
+func postPromptMessages(ctx context.Context, data postPromptData) ([]Message, error) {
+	variant, ok := ctx.Value(promptVariantKey{}).(PromptVariant)
+	if !ok || variant.tmpl == nil {
+		return promptMessages(promptPostSystem, promptPostUser, data)
+	}
+
+	system, err := executePrompt(variant.tmpl, data)
+	if err != nil {
+		return nil, err
+	}
+	user, err := renderPrompt(promptPostUser, data)
+	if err != nil {
+		return nil, err
+	}
+	return []Message{
+		{Role: "system", Content: system},
+		{Role: "user", Content: user},
+	}, nil
+}
@@ -2,97 +2,437 @@ package bot
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"regexp"
+	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	"AIGenerator/internal/ai"
+	"AIGenerator/internal/calendar"
 	"AIGenerator/internal/database"
+	"AIGenerator/internal/events"
+	"AIGenerator/internal/generator"
+	"AIGenerator/internal/health"
+	"AIGenerator/internal/httpx"
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/logging"
 	"AIGenerator/internal/news"
 	"AIGenerator/internal/payment"
+	"AIGenerator/internal/reporting"
+	"AIGenerator/internal/richtext"
+	"AIGenerator/internal/selftest"
+	"AIGenerator/internal/texts"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
+const (
+	// defaultGenerationTimeout лимит времени на всю генерацию: поиск новостей, загрузку страницы и AI
+	defaultGenerationTimeout = 90 * time.Second
+	// defaultShutdownTimeout сколько ждать начатые обработчики при завершении
+	defaultShutdownTimeout = 30 * time.Second
+	// defaultHeadlinesCost доля генерации, которая списывается за варианты заголовка
+	defaultHeadlinesCost = 0.5
+)
+
+// Config настройки бота
+type Config struct {
+	Token string
+	// AdminChatID чат администратора для отзывов, оценок и уведомлений; 0 — не отправлять
+	AdminChatID int64
+	// AdminPassword пароль админских команд
+	AdminPassword string
+	// GenerationTimeout общий лимит времени на генерацию поста
+	GenerationTimeout time.Duration
+	// ExpandChargeGeneration списывать генерацию за кнопку «Расширить»
+	ExpandChargeGeneration bool
+	// HeadlinesCost доля генерации, которая списывается за /headlines (0–1)
+	HeadlinesCost float64
+	// LogFile файл лога, хвост которого отправляет /logs
+	LogFile string
+	// ShutdownTimeout сколько ждать начатые обработчики при завершении
+	ShutdownTimeout time.Duration
+	// ReportHour час ежедневного отчета в чат администратора и сводок пользователям (0–23);
+	// -1 — не отправлять ни то, ни другое
+	ReportHour int
+	// ReportLocation часовой пояс календарных периодов статистики и часа отчета;
+	// nil — пояс сервера
+	ReportLocation *time.Location
+	// WeeklySummaries присылать активным пользователям еженедельную сводку в час отчета
+	// по понедельникам
+	WeeklySummaries bool
+	// GenerationWorkers сколько генераций выполняется одновременно
+	GenerationWorkers int
+	// GenerationQueueSize сколько генераций может ждать свободного обработчика
+	GenerationQueueSize int
+	// PhotoRetryMaxDelay сколько ждать перед повторной отправкой фото поста, если Telegram
+	// просит подождать; при более долгой паузе картинка сразу загружается ботом. 0 — без повтора.
+	PhotoRetryMaxDelay time.Duration
+}
+
+// DefaultConfig возвращает настройки бота по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		GenerationTimeout:   defaultGenerationTimeout,
+		HeadlinesCost:       defaultHeadlinesCost,
+		ShutdownTimeout:     defaultShutdownTimeout,
+		ReportHour:          defaultReportHour,
+		WeeklySummaries:     true,
+		GenerationWorkers:   defaultGenerationWorkers,
+		GenerationQueueSize: defaultGenerationQueueSize,
+		PhotoRetryMaxDelay:  defaultPhotoRetryMaxDelay,
+	}
+}
+
+// TelegramSender методы Telegram Bot API, которыми пользуется бот. Реализуется
+// *tgbotapi.BotAPI; в тестах его заменяет testutil.FakeTelegram.
+type TelegramSender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
+	StopReceivingUpdates()
+}
+
 type Bot struct {
-	api            *tgbotapi.BotAPI
+	config         Config
+	api            TelegramSender
+	self           tgbotapi.User
 	newsAggregator *news.NewsAggregator
-	gptClient      *ai.YandexGPTClient
-	db             *database.Database
-	yooMoney       *payment.YooMoneyClient
-	mu             sync.Mutex
-	adminChatID    int64
+	gptClient      ai.TextGenerator
+	imageClient    *ai.ImageClient
+	// generator генерация постов: от резерва генерации до готового поста
+	generator   *generator.Service
+	db          *database.Database
+	yooMoney    *payment.YooMoneyClient
+	mu          sync.Mutex
+	adminChatID int64
+
+	// posts последний отправленный пост каждого чата вместе с исходной статьей для «Расширить»
+	posts   map[int64]*deliveredPost
+	postsMu sync.Mutex
+	// inlinePosts последние посты пользователей для inline-режима
+	inlinePosts *recentPosts
+	// broadcasts рассылки /sendmsg, ожидающие подтверждения
+	broadcasts   map[string]*pendingBroadcast
+	broadcastsMu sync.Mutex
+	// previews посты в предпросмотре, ожидающие решения пользователя: не больше одного на чат
+	previews   map[int64]*pendingPreview
+	previewsMu sync.Mutex
+	// experiment идущий эксперимент с промптами; nil — генерации идут с обычным промптом
+	experiment   *runningExperiment
+	experimentMu sync.RWMutex
+
+	// startedAt и lastUpdate (unix nano) для проверки, что бот получает обновления
+	startedAt  time.Time
+	lastUpdate atomic.Int64
+	// handlers начатые обработчики, которых ждет завершение; stopping закрывается при завершении
+	handlers sync.WaitGroup
+	stopping chan struct{}
+	// panics число перехваченных паник обработчиков
+	panics atomic.Int64
+	// health проверки состояния для /status; nil — команда недоступна
+	health *health.Checker
+	// selfTest самопроверка внешних зависимостей для /selftest; nil — команда недоступна
+	selfTest *selftest.Suite
+	// events журнал событий для статистики; nil — события не ведутся
+	events *events.Pipeline
+	// queue очередь генераций постов
+	queue *generationQueue
+	// recent недавние запросы на генерацию, чтобы не выполнять повторы
+	recent *recentGenerations
+	// dispatcher очереди обновлений по чатам
+	dispatcher *chatDispatcher
+	// outboxWake будит отправку уведомлений администратору после постановки в очередь
+	outboxWake chan struct{}
 }
 
-func New(token string, newsAggregator *news.NewsAggregator, gptClient *ai.YandexGPTClient, db *database.Database, yooMoney *payment.YooMoneyClient, adminChatID int64) (*Bot, error) {
-	api, err := tgbotapi.NewBotAPI(token)
+func New(config Config, newsAggregator *news.NewsAggregator, gptClient ai.TextGenerator, imageClient *ai.ImageClient, db *database.Database, yooMoney *payment.YooMoneyClient) (*Bot, error) {
+	api, err := tgbotapi.NewBotAPI(config.Token)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания бота: %w", err)
 	}
 
 	log.Printf("[BOT] Бот @%s создан успешно", api.Self.UserName)
-	return &Bot{
-		api:            api,
+	return NewWithSender(config, api, api.Self, newsAggregator, gptClient, imageClient, db, yooMoney), nil
+}
+
+// NewWithSender создает бота поверх готового клиента Telegram; self — учетная запись бота,
+// по ней бот узнает свои сообщения в ответах пользователей
+func NewWithSender(config Config, api TelegramSender, self tgbotapi.User, newsAggregator *news.NewsAggregator, gptClient ai.TextGenerator,
+	imageClient *ai.ImageClient, db *database.Database, yooMoney *payment.YooMoneyClient) *Bot {
+	b := &Bot{
+		config:         config,
+		api:            newOrderedSender(api),
+		self:           self,
 		newsAggregator: newsAggregator,
 		gptClient:      gptClient,
+		imageClient:    imageClient,
 		db:             db,
 		yooMoney:       yooMoney,
-		adminChatID:    adminChatID,
-	}, nil
+		adminChatID:    config.AdminChatID,
+		posts:          make(map[int64]*deliveredPost),
+		inlinePosts:    newRecentPosts(inlineRecentPosts),
+		broadcasts:     make(map[string]*pendingBroadcast),
+		previews:       make(map[int64]*pendingPreview),
+		startedAt:      time.Now(),
+		stopping:       make(chan struct{}),
+		queue:          newGenerationQueue(config.GenerationWorkers, config.GenerationQueueSize),
+		recent:         newRecentGenerations(generationDedupWindow, generationDedupSize),
+		dispatcher:     newChatDispatcher(),
+		outboxWake:     make(chan struct{}, 1),
+	}
+	b.generator = generator.New(newsAggregator, generator.PageFetcherFunc(b.fetchPage), gptClient, db)
+	return b
+}
+
+// SetHealthChecker подключает проверки состояния к команде /status
+func (b *Bot) SetHealthChecker(checker *health.Checker) {
+	b.health = checker
+}
+
+// SetSelfTest подключает самопроверку к команде /selftest
+func (b *Bot) SetSelfTest(suite *selftest.Suite) {
+	b.selfTest = suite
+}
+
+// SetEvents подключает журнал событий: обработчики отправляют в него события,
+// /statistics берет из него показатели генераций и платежей
+func (b *Bot) SetEvents(pipeline *events.Pipeline) {
+	b.events = pipeline
+}
+
+// NotifyAdmin ставит сообщение в очередь уведомлений администратору, если его чат задан
+func (b *Bot) NotifyAdmin(text string) {
+	b.notifyAdmin(text, false, "")
+}
+
+// LastUpdateAt время последнего обновления Telegram или запуска бота, если обновлений еще не было
+func (b *Bot) LastUpdateAt() time.Time {
+	if last := b.lastUpdate.Load(); last != 0 {
+		return time.Unix(0, last)
+	}
+	return b.startedAt
 }
 
+// Start получает обновления, пока не отменен ctx. После отмены перестает запрашивать
+// обновления, ждет завершения начатых обработчиков (не дольше ShutdownTimeout),
+// сохраняет базу и только затем возвращается.
 func (b *Bot) Start(ctx context.Context) {
-	u := tgbotapi.NewUpdate(0)
+	// Смещение продолжается с последнего обработанного обновления: иначе после
+	// перезапуска Telegram повторил бы уже выполненные запросы
+	u := tgbotapi.NewUpdate(b.updateOffset())
 	u.Timeout = 60
 	updates := b.api.GetUpdatesChan(u)
 
 	log.Println("[BOT] Ожидание обновлений...")
 
-	go func() {
-		<-ctx.Done()
-		log.Println("[BOT] Получен сигнал завершения, останавливаю бота...")
-	}()
+	ai.SetBreakerListener(b.notifyBreakerChange)
+	b.restoreExperiment()
+	for range b.config.GenerationWorkers {
+		b.safeGo("generation_worker", 0, b.generationWorker)
+	}
+	if b.config.ReportHour >= 0 {
+		b.safeGo("reports", 0, func() { b.runReports(ctx) })
+	}
+	b.safeGo("update_offset", 0, func() { b.runOffsetFlush(ctx) })
+	b.safeGo("premium_sweep", 0, func() { b.runPremiumSweep(ctx) })
+	b.safeGo("outbox", 0, func() { b.runOutbox(ctx) })
+	b.safeGo("payment_poller", 0, func() { b.runPaymentPoller(ctx) })
 
-	for update := range updates {
-		if update.CallbackQuery != nil {
-			go b.handleCallback(update.CallbackQuery)
-			continue
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("[BOT] Получен сигнал завершения, останавливаю бота...")
+			b.shutdown()
+			return
+		case update, ok := <-updates:
+			if !ok {
+				b.shutdown()
+				return
+			}
+			b.handleUpdate(update)
 		}
+	}
+}
 
-		if update.Message == nil {
-			continue
-		}
+// handleUpdate ставит обновление в очередь его чата
+func (b *Bot) handleUpdate(update tgbotapi.Update) {
+	b.lastUpdate.Store(time.Now().UnixNano())
+	if !b.acceptUpdate(update) {
+		return
+	}
 
-		if update.Message.IsCommand() {
-			go b.handleCommand(update.Message)
-			continue
+	// Ответ на inline-запрос не ждет очереди чата: Telegram ждет его всего несколько секунд
+	if update.InlineQuery != nil {
+		b.safeGo("inline", update.InlineQuery.From.ID, func() { b.handleInlineQuery(update.InlineQuery) })
+		return
+	}
+
+	if update.CallbackQuery != nil {
+		b.dispatch(update.CallbackQuery.From.ID, "callback", func() { b.handleCallback(update.CallbackQuery) })
+		return
+	}
+
+	if update.Message == nil {
+		return
+	}
+
+	name := "message"
+	if update.Message.IsCommand() {
+		name = "command"
+	}
+	b.dispatch(update.Message.Chat.ID, name, func() { b.handleMessage(update.Message) })
+}
+
+// handleMessage направляет сообщение обработчику. Состояние ожидания отзыва и рерайта
+// проверяется в очереди чата, после обработки предыдущих сообщений.
+func (b *Bot) handleMessage(msg *tgbotapi.Message) {
+	// Написавший боту пользователь снова получает сообщения
+	if b.db.IsBlocked(msg.Chat.ID) {
+		if err := b.db.SetBlocked(msg.Chat.ID, false); err != nil {
+			log.Printf("[DB] ❌ Ошибка сохранения пользователя %d: %v", msg.Chat.ID, err)
 		}
+	}
+
+	switch {
+	case msg.IsCommand():
+		b.handleCommand(msg)
+	case b.db.IsUserPendingFeedback(msg.Chat.ID):
+		b.handleFeedbackText(msg)
+	case b.db.IsUserPendingRewrite(msg.Chat.ID):
+		b.handleRewriteText(msg)
+	case b.isFollowUpQuestion(msg):
+		// Ответ ждет модели и не должен задерживать очередь чата
+		b.safeGo("followup", msg.Chat.ID, func() { b.handleFollowUpQuestion(msg) })
+	default:
+		b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "message.not_command"))
+	}
+}
+
+// safeGo запускает обработчик name в горутине и учитывает его при завершении.
+// Фоновая работа обработчика (генерация после ответа на команду) тоже запускается
+// через safeGo. Паника не роняет процесс: см. recoverPanic.
+func (b *Bot) safeGo(name string, chatID int64, handler func()) {
+	b.handlers.Add(1)
+	go func() {
+		defer b.handlers.Done()
+		defer b.recoverPanic(name, chatID)
+		handler()
+	}()
+}
+
+// recoverPanic перехватывает панику обработчика: пишет стек в лог, извиняется перед
+// пользователем и сообщает администратору. Вызывается только через defer.
+func (b *Bot) recoverPanic(name string, chatID int64) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	hash := stackHash(stack)
+	b.panics.Add(1)
+
+	logging.For("bot").Error("паника в обработчике",
+		"handler", name, logging.KeyUserID, chatID, "panic", fmt.Sprint(r),
+		"stack_hash", hash, "stack", string(stack))
+	reporting.Capture(reporting.Event{
+		Level:       reporting.LevelFatal,
+		Operation:   "panic",
+		Message:     fmt.Sprintf("паника в обработчике %s: %v", name, r),
+		UserID:      chatID,
+		Tags:        map[string]string{"handler": name, "stack_hash": hash},
+		Fingerprint: []string{"panic", hash},
+		Stack:       string(stack),
+	})
+
+	if chatID != 0 {
+		b.sendMessage(chatID, b.t(chatID, "error.internal"))
+	}
+	// Одна и та же паника сообщается не чаще раза в час
+	b.notifyAdmin(fmt.Sprintf("💥 Паника в обработчике %s (чат %d): %v\nСтек %s, подробности в логе",
+		name, chatID, r, hash), false, "panic:"+hash+":"+time.Now().Format("2006-01-02T15"))
+}
 
-		if b.db.IsUserPendingFeedback(update.Message.Chat.ID) {
-			go b.handleFeedbackText(update.Message)
+// stackFrameArgs аргументы вызова в строке стека: main.f(0xc000010000, 0x1)
+var stackFrameArgs = regexp.MustCompile(`\(.*\)$`)
+
+// stackHash короткий хэш стека по именам функций: одна и та же паника дает один хэш,
+// хотя адреса и номер горутины меняются
+func stackHash(stack []byte) string {
+	hash := sha256.New()
+	for _, line := range strings.Split(string(stack), "\n") {
+		if line == "" || strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "goroutine ") {
 			continue
 		}
+		hash.Write([]byte(stackFrameArgs.ReplaceAllString(line, "")))
+		hash.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:8]
+}
+
+// Panics возвращает число перехваченных паник с запуска
+func (b *Bot) Panics() int64 {
+	return b.panics.Load()
+}
+
+// shutdown перестает получать обновления, ждет обработчики и сохраняет базу
+func (b *Bot) shutdown() {
+	b.api.StopReceivingUpdates()
+	close(b.stopping)
+	// Принятые генерации доделываются: обработчики очереди завершаются, когда она пуста
+	b.queue.close()
 
-		b.sendMessage(update.Message.Chat.ID,
-			"❌ Для генерации поста используйте команду /generate\n"+
-				"Пример: /generate искусственный интеллект\n"+
-				"Или отправьте ссылку на статью: /generate https://example.com/news\n"+
-				"Подробнее: /help")
+	drained := make(chan struct{})
+	go func() {
+		b.handlers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		log.Println("[BOT] Все обработчики завершены")
+	case <-time.After(b.config.ShutdownTimeout):
+		log.Printf("[BOT] ⚠️ Обработчики не завершились за %v, сохраняю базу без них", b.config.ShutdownTimeout)
+	}
+
+	if !b.events.Close(b.config.ShutdownTimeout) {
+		log.Printf("[BOT] ⚠️ Журнал событий не дописан за %v", b.config.ShutdownTimeout)
+	}
+	if err := b.db.Close(); err != nil {
+		log.Printf("[BOT] ❌ Ошибка сохранения базы при завершении: %v", err)
 	}
 }
 
+// lang возвращает язык интерфейса пользователя
+func (b *Bot) lang(userID int64) string {
+	return b.db.GetSettings(userID).InterfaceLanguage
+}
+
+// t возвращает сообщение key на языке интерфейса пользователя
+func (b *Bot) t(userID int64, key string, args ...any) string {
+	return i18n.T(b.lang(userID), key, args...)
+}
+
 func (b *Bot) handleCommand(msg *tgbotapi.Message) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	log.Printf("[COMMAND] Получена команда /%s от %d", msg.Command(), msg.Chat.ID)
+	b.events.Emit(events.Event{Type: events.CommandReceived, UserID: msg.Chat.ID, Command: msg.Command()})
 
 	switch msg.Command() {
 	case "start":
@@ -105,6 +445,10 @@ func (b *Bot) handleCommand(msg *tgbotapi.Message) {
 		b.handleBuy(msg)
 	case "balance":
 		b.handleBalance(msg)
+	case "premium":
+		b.handlePremium(msg)
+	case "setpremium":
+		b.handleSetPremium(msg)
 	case "statistics":
 		b.handleStatistics(msg)
 	case "feedback":
@@ -117,452 +461,822 @@ func (b *Bot) handleCommand(msg *tgbotapi.Message) {
 		b.handleSendMessageCommand(msg)
 	case "addgenerations":
 		b.handleAddGenerationsCommand(msg)
+	case "trends":
+		b.handleTrends(msg)
+	case "sourcestatus":
+		b.handleSourceStatus(msg)
+	case "setmodel":
+		b.handleSetModel(msg)
+	case "reloadprompts":
+		b.handleReloadPrompts(msg)
+	case "reloadtexts":
+		b.handleReloadTexts(msg)
+	case "settings":
+		b.handleSettings(msg)
+	case "translate":
+		b.handleTranslate(msg)
+	case "rewrite":
+		b.handleRewriteCommand(msg)
+	case "ailog":
+		b.handleAILog(msg)
+	case "headlines":
+		b.handleHeadlines(msg)
+	case "logs":
+		b.handleLogs(msg)
+	case "findlogs":
+		b.handleFindLogs(msg)
+	case "status":
+		b.handleStatus(msg)
+	case "selftest":
+		b.handleSelfTest(msg)
+	case "testgen":
+		b.handleTestGen(msg)
+	case "language":
+		b.handleLanguage(msg)
+	case "removegenerations":
+		b.handleRemoveGenerationsCommand(msg)
+	case "userinfo":
+		b.handleUserInfo(msg)
+	case "deletemydata":
+		b.handleDeleteMyData(msg)
+	case "experiment":
+		b.handleExperiment(msg)
 	default:
-		b.sendMessage(msg.Chat.ID, "❌ Неизвестная команда. Используйте /help для списка команд.")
+		b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "command.unknown"))
 	}
 }
 
+// handleStart приветствует пользователя. При первом запуске язык интерфейса
+// выбирается по языку Telegram; потом его меняет /language.
 func (b *Bot) handleStart(msg *tgbotapi.Message) {
+	if msg.From != nil && b.lang(msg.Chat.ID) == "" {
+		code := i18n.Detect(msg.From.LanguageCode)
+		if _, err := b.db.UpdateSettings(msg.Chat.ID, func(settings *database.Settings) {
+			settings.InterfaceLanguage = code
+		}); err != nil {
+			log.Printf("[DB] ❌ Ошибка сохранения языка интерфейса %d: %v", msg.Chat.ID, err)
+		}
+	}
 
-	text := `🤖 AI Content Generator
+	if b.handleInlineStart(msg) {
+		return
+	}
+	b.sendText(msg.Chat.ID, texts.Start)
+}
 
-Я помогу создавать качественные посты для Telegram каналов на основе актуальных новостей или по ссылке на статью.
+func (b *Bot) handleHelp(msg *tgbotapi.Message) {
+	b.sendText(msg.Chat.ID, texts.Help)
+}
 
-✨ Основные команды:
-/generate - создать пост по ключевым словам или ссылке
-/balance - проверить баланс генераций  
-/buy - приобрести дополнительные генерации
-/feedback - оставить отзыв о работе бота
-/help - показать справку
+// sendText отправляет текст из шаблонов texts с актуальными тарифами и ограничениями
+func (b *Bot) sendText(chatID int64, name string) {
+	text, err := texts.Render(name, b.lang(chatID), b.textData())
+	if err != nil {
+		log.Printf("[BOT] ❌ Ошибка текста %s: %v", name, err)
+		b.sendMessage(chatID, b.t(chatID, "error.internal"))
+		return
+	}
+	b.sendMessage(chatID, text)
+}
 
-🎯 Для всех новых пользователей 10 бесплатных генераций!
+// textData собирает значения для шаблонов текстов
+func (b *Bot) textData() texts.Data {
+	data := texts.Data{
+		FreeGenerations:   b.db.FreeTrialGenerations(),
+		BlockedCategories: ai.ModerationCategories(),
+	}
+	for code, price := range b.db.GetPricing() {
+		count, err := strconv.Atoi(code)
+		if err != nil {
+			continue
+		}
+		data.Packages = append(data.Packages, texts.Package{Count: count, Price: price})
+	}
+	slices.SortFunc(data.Packages, func(a, b texts.Package) int { return a.Count - b.Count })
+	return data
+}
 
-🚀 Для генерации поста используйте:
-• /generate ключевые_слова
-• /generate ссылка_на_статью
+// handleLanguage меняет язык интерфейса: /language en или выбор кнопкой
+func (b *Bot) handleLanguage(msg *tgbotapi.Message) {
+	code := strings.TrimSpace(msg.CommandArguments())
+	if code == "" {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, language := range i18n.Languages() {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(language.Title, languageCallbackPrefix+language.Code))
+		}
+		b.sendMessageWithKeyboard(msg.Chat.ID, b.t(msg.Chat.ID, "language.choose"), tgbotapi.NewInlineKeyboardMarkup(row))
+		return
+	}
 
-⚠️ Ограничения:
-• Посты на военную тематику и новости с военной тематикой не обрабатываются.
-• ИИ может отказаться генерировать пост на некоторые темы.
-• На ваш запрос может не найтись новости в наших источниках, поэтому пост может быть не точным.
-Если вы найдете новость, которую не нашел наш бот, отправьте ссылку на нее и ваш запрос в обратную связь (команда /feedback) и мы вернем вам генерацию!
-Сделаем бота лучше вместе!
+	language, ok := i18n.Parse(code)
+	if !ok {
+		b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "language.unknown", code, i18n.Codes()))
+		return
+	}
+	b.sendMessage(msg.Chat.ID, b.setInterfaceLanguage(msg.Chat.ID, language))
+}
 
-✨ Примеры:
-/generate искусственный интеллект
-/generate https://habr.com/ru/news/...`
+// languageCallbackPrefix префикс данных кнопок выбора языка интерфейса
+const languageCallbackPrefix = "uilang_"
 
-	b.sendMessage(msg.Chat.ID, text)
+// handleLanguageCallback меняет язык интерфейса по нажатию кнопки
+func (b *Bot) handleLanguageCallback(callback *tgbotapi.CallbackQuery) {
+	language, ok := i18n.Parse(strings.TrimPrefix(callback.Data, languageCallbackPrefix))
+	if !ok {
+		return
+	}
+	chatID := callback.Message.Chat.ID
+	b.editMessage(chatID, callback.Message.MessageID, b.setInterfaceLanguage(chatID, language))
 }
 
-func (b *Bot) handleHelp(msg *tgbotapi.Message) {
-	text := `📖 Справка по командам
-
-🎯 Основные команды:
-/generate - создать пост по ключевым словам или ссылке
-/balance - проверить баланс
-/buy - купить генерации
-/feedback - оставить отзыв о работе бота
-/help - эта справка
-
-📝 Как использовать:
-• Используйте команду /generate ключевые_слова
-• Или отправьте ссылку на статью: /generate https://example.com/news
-
-✨ Примеры:
-  /generate искусственный интеллект
-  /generate https://example.com/ru/news/...
-
-⚠️ Ограничения:
-• Посты на военную тематику и новости с военной тематикой не обрабатываются.
-• ИИ может отказаться генерировать пост на некоторые темы.
-• На ваш запрос может не найтись новости в наших источниках, поэтому пост может быть не точным.
-Если вы найдете новость, которую не нашел наш бот, отправьте ссылку на нее и ваш запрос в обратную связь (команда /feedback) и мы вернем вам генерацию!
-Сделаем бота лучше вместе!
-
-💎 Тарифы:
-• 10 генераций - 99 руб
-• 25 генераций - 199 руб  
-• 100 генераций - 499 руб
-
-⏰ Лимиты:
-• Первые 10 генераций - бесплатно
-• Генерация списывается только при успешном создании поста
-
-💳 Оплата:
-• Безопасная оплата через ЮKassa
-• Мгновенное зачисление
-• Поддержка банковских карт и электронных кошельков`
-
-	b.sendMessage(msg.Chat.ID, text)
+// setInterfaceLanguage сохраняет язык интерфейса и возвращает подтверждение уже на нем
+func (b *Bot) setInterfaceLanguage(userID int64, language i18n.Language) string {
+	if _, err := b.db.UpdateSettings(userID, func(settings *database.Settings) {
+		settings.InterfaceLanguage = language.Code
+	}); err != nil {
+		log.Printf("[DB] ❌ Ошибка сохранения языка интерфейса %d: %v", userID, err)
+	}
+	log.Printf("[COMMAND] Пользователь %d выбрал язык интерфейса %s", userID, language.Code)
+	return i18n.T(language.Code, "language.changed", language.Title)
 }
 
 func (b *Bot) handleGenerateCommand(msg *tgbotapi.Message) {
 	args := strings.TrimSpace(strings.TrimPrefix(msg.Text, "/generate"))
 	if args == "" {
-		b.sendMessage(msg.Chat.ID,
-			"❌ Не указаны ключевые слова или ссылка\n\n"+
-				"📝 Используйте:\n"+
-				"/generate ключевые слова\n"+
-				"или\n"+
-				"/generate https://example.com/news\n\n"+
-				"✨ Примеры:\n"+
-				"/generate искусственный интеллект\n"+
-				"/generate https://habr.com/ru/news/...")
+		b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "generate.usage"))
 		return
 	}
+	b.startGeneration(msg, args, false)
+}
 
-	// Проверяем, является ли аргумент ссылкой
-	if b.isURL(args) {
-		go b.handleGenerateFromURL(context.Background(), msg, args)
-	} else {
-		go b.handleGenerateFromKeywords(context.Background(), msg, args)
+// startGeneration ставит в очередь генерацию по теме или ссылке args. inline — пост
+// заказан из inline-режима и получит кнопку «Вставить в чат».
+func (b *Bot) startGeneration(msg *tgbotapi.Message, args string, inline bool) {
+	// Язык поста: флаг -lang=xx важнее настройки пользователя
+	language, rest, err := b.generationLanguage(msg.Chat.ID, args)
+	if err != nil {
+		code, _ := extractLanguageFlag(args)
+		b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "post_language.unknown", code, postLanguageCodes()))
+		return
 	}
-}
+	args = rest
 
-// isURL проверяет, является ли строка URL
-func (b *Bot) isURL(text string) bool {
-	return strings.HasPrefix(text, "http://") ||
-		strings.HasPrefix(text, "https://") ||
-		strings.Contains(text, "://")
-}
+	// Во время сбоя AI не ищем новости и не заставляем ждать
+	if ai.CircuitOpen() {
+		b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "ai.circuit_open"))
+		return
+	}
 
-// handleGenerateFromKeywords обрабатывает генерацию по ключевым словам
-func (b *Bot) handleGenerateFromKeywords(ctx context.Context, msg *tgbotapi.Message, keywords string) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("[PANIC] Восстановление после паники в handleGenerateFromKeywords: %v", r)
-			b.sendMessage(msg.Chat.ID, "❌ Произошла внутренняя ошибка. Попробуйте позже.")
+	// Повтор того же запроса, пока первый выполняется или только что закончился,
+	// получает ответ о нем, а не новую генерацию
+	isURL := b.isURL(args)
+	key := generationDedupKey(msg.Chat.ID, language.Code, args, isURL)
+	if previous, ok := b.recent.begin(key, time.Now()); !ok {
+		b.answerDuplicate(msg.Chat.ID, previous)
+		return
+	}
+
+	// Генерация ждет свободного обработчика; время на нее отсчитывается с начала работы,
+	// а хронология — с постановки в очередь
+	queued := time.Now()
+	enqueued := b.enqueueGeneration(msg.Chat.ID, func(ctx context.Context, status tgbotapi.Message) {
+		defer func() { b.recent.finish(key, time.Now()) }()
+		ctx, cancel := context.WithTimeout(ctx, b.config.GenerationTimeout)
+		defer cancel()
+		ctx = withQueueStatus(ctx, status)
+		ctx = withDedupKey(ctx, key)
+		ctx = withCitation(ctx, b.db.GetSettings(msg.Chat.ID).CitationMode())
+		ctx = ai.WithLanguage(ctx, language)
+		ctx = ai.WithAuditUser(ctx, msg.Chat.ID)
+		ctx = b.withUserTier(ctx, msg.Chat.ID)
+		if inline {
+			ctx = withInlineOrigin(ctx)
 		}
-	}()
 
-	userID := msg.Chat.ID
+		if isURL {
+			b.handleGenerateFromURL(withTrace(ctx, newGenerationTrace(msg.Chat.ID, "url", queued)), msg, args)
+		} else {
+			b.handleGenerateFromKeywords(withTrace(ctx, newGenerationTrace(msg.Chat.ID, "keywords", queued)), msg, args)
+		}
+	})
+	if !enqueued {
+		b.recent.forget(key)
+	}
+}
 
-	if keywords == "" {
-		b.sendMessage(userID, "❌ Пожалуйста, укажите ключевые слова для генерации поста.\n"+
-			"Пример: /generate искусственный интеллект")
-		return
+// trackGeneration отправляет событие начала генерации и возвращает функцию, отправляющую
+// ее исход. Она же заканчивает хронологию генерации: пишет ее в лог, а длительность —
+// в журнал генераций и событие исхода. Тестовые генерации в статистику не попадают.
+func (b *Bot) trackGeneration(ctx context.Context, userID int64, topic string) func(outcome string) {
+	trace := traceFrom(ctx)
+	_, isDryRun := dryRunFrom(ctx)
+	if !isDryRun {
+		b.events.Emit(events.Event{Type: events.GenerationStarted, UserID: userID, Topic: topic, RequestID: trace.ID()})
+	}
+	return func(outcome string) {
+		trace.finish(outcome)
+		b.db.SetGenerationDuration(trace.ID(), trace.total())
+		if isDryRun {
+			return
+		}
+		b.recordVariant(ctx, trace.ID())
+		b.events.Emit(events.Event{Type: events.GenerationFinished, UserID: userID, Topic: topic, Outcome: outcome,
+			RequestID: trace.ID(), DurationMs: trace.total().Milliseconds(), StagesMs: trace.stageMillis()})
 	}
+}
 
-	log.Printf("[GENERATE] Начало обработки запроса от %d: %s", userID, keywords)
+// withUserTier дает премиум-пользователям полную модель, если провайдер поддерживает выбор модели
+func (b *Bot) withUserTier(ctx context.Context, userID int64) context.Context {
+	if _, ok := b.gptClient.(ai.TierSelector); ok && b.db.IsPremium(userID) {
+		return ai.WithModelTier(ctx, ai.TierPro)
+	}
+	return ctx
+}
 
-	// Проверяем доступные генерации
-	user := b.db.GetUser(userID)
-	log.Printf("[GENERATE] Пользователь %d: доступно %d генераций", userID, user.AvailableGenerations)
+type dryRunKey struct{}
 
-	if user.AvailableGenerations <= 0 {
-		text := "❌ Закончились генерации!\n\n" +
-			"💎 Используйте команду /buy чтобы приобрести дополнительные генерации\n\n" +
-			"✨ Доступные пакеты:\n" +
-			"• 10 генераций - 99 руб\n" +
-			"• 25 генераций - 199 руб\n" +
-			"• 100 генераций - 499 руб"
-		b.sendMessage(userID, text)
-		return
-	}
+// dryRun тестовая генерация администратора: проходит весь путь генерации,
+// но не списывает генерации и не меняет счетчики пользователя
+type dryRun struct {
+	// target пользователь, с чьими настройками выполняется генерация
+	target int64
+}
 
-	// Шаг 1: Начало процесса
-	step1Msg := b.sendMessage(userID, fmt.Sprintf("🔄 Генерация поста начата\n\n🎯 Тема: %s\n\n⏳ Шаг 1/3: Ищу новости по теме...", keywords))
+// withDryRun помечает генерацию как тестовую. Вызывается только из /testgen после
+// проверки пароля: у обычных команд способа передать эту пометку нет.
+func withDryRun(ctx context.Context, target int64) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, dryRun{target: target})
+}
 
-	// Шаг 2: Поиск новостей
-	b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-		fmt.Sprintf("🔄 Генерация поста начата\n\n🎯 Тема: %s\n\n✅ Шаг 1/3: ✓ Готово\n⏳ Шаг 2/3: Анализирую новости...", keywords))
+// dryRunFrom возвращает пометку тестовой генерации
+func dryRunFrom(ctx context.Context) (dryRun, bool) {
+	dry, ok := ctx.Value(dryRunKey{}).(dryRun)
+	return dry, ok
+}
 
-	log.Printf("[GENERATE] Шаг 2/3: Поиск новостей...")
+// dryRunLabel метка результатов тестовой генерации
+const dryRunLabel = "🧪 ТЕСТОВАЯ ГЕНЕРАЦИЯ — баланс не списан"
 
-	// Получаем релевантные новости
-	articles, err := b.newsAggregator.FindRelevantArticles(keywords, 5)
-	if err != nil {
-		log.Printf("[GENERATE] ❌ Ошибка при поиске новостей: %v", err)
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ Ошибка генерации\n\n🎯 Тема: %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: Ошибка при поиске новостей", keywords))
+// handleTestGen выполняет тестовую генерацию с настройками пользователя chatID,
+// результат получает администратор: /testgen пароль chatID тема
+func (b *Bot) handleTestGen(msg *tgbotapi.Message) {
+	parts := strings.SplitN(strings.TrimSpace(msg.CommandArguments()), " ", 3)
+	if len(parts) < 3 || strings.TrimSpace(parts[2]) == "" {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/testgen пароль chatid тема\n\n"+
+			"Генерация с настройками пользователя chatid без списания генераций")
 		return
 	}
 
-	log.Printf("[GENERATE] Найдено %d статей", len(articles))
+	if parts[0] != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
 
-	if len(articles) == 0 {
-		log.Printf("[GENERATE] ❌ Не найдено новостей по запросу: %s", keywords)
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ Новости не найдены\n\n🎯 Тема: %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: Не найдено подходящих новостей по теме", keywords))
+	target, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный chatid. Должен быть числом.")
 		return
 	}
 
-	// Выбираем статью с изображением, если есть
-	var selectedArticle news.Article
-	for _, article := range articles {
-		if article.ImageURL != "" {
-			selectedArticle = article
-			break
-		}
+	language, keywords, err := b.generationLanguage(target, strings.TrimSpace(parts[2]))
+	if err != nil {
+		code, _ := extractLanguageFlag(parts[2])
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Неизвестный язык: %s (%s)", code, postLanguageCodes()))
+		return
+	}
+	if b.isURL(keywords) {
+		b.sendMessage(msg.Chat.ID, "❌ Тестовая генерация работает только по теме, не по ссылке")
+		return
 	}
 
-	// Если нет статьи с изображением, берем первую
-	if selectedArticle.Title == "" && len(articles) > 0 {
-		selectedArticle = articles[0]
+	if ai.CircuitOpen() {
+		b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "ai.circuit_open"))
+		return
 	}
 
-	// Шаг 3: Генерация через AI
-	b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-		fmt.Sprintf("🔄 Генерация поста начата\n\n🎯 Тема: %s\n\n✅ Шаг 1/3: ✓ Готово\n✅ Шаг 2/3: ✓ Найдено %d новостей\n⏳ Шаг 3/3: Генерация поста через AI...",
-			keywords, len(articles)))
+	log.Printf("[TESTGEN] Администратор %d: тестовая генерация для %d: %s", msg.Chat.ID, target, keywords)
+	b.sendMessage(msg.Chat.ID, fmt.Sprintf("%s\nПользователь %d, язык %s, тема: %s", dryRunLabel, target, language.Code, keywords))
+
+	queued := time.Now()
+	b.enqueueGeneration(msg.Chat.ID, func(ctx context.Context, status tgbotapi.Message) {
+		ctx, cancel := context.WithTimeout(ctx, b.config.GenerationTimeout)
+		defer cancel()
+		ctx = withQueueStatus(ctx, status)
+		ctx = withTrace(ctx, newGenerationTrace(msg.Chat.ID, "dry_run", queued))
+		ctx = withDryRun(ctx, target)
+		ctx = withCitation(ctx, b.db.GetSettings(target).CitationMode())
+		ctx = ai.WithLanguage(ctx, language)
+		ctx = ai.WithAuditUser(ctx, target)
+		ctx = b.withUserTier(ctx, target)
 
-	log.Printf("[GENERATE] Шаг 3/3: Выбрана статья: %s", selectedArticle.Title)
+		b.handleGenerateFromKeywords(ctx, msg, keywords)
+	})
+}
 
-	// Генерируем пост через GPT
-	articleInfo := ai.ArticleInfo{
-		Title:    selectedArticle.Title,
-		Summary:  selectedArticle.Summary,
-		URL:      selectedArticle.URL,
-		Source:   selectedArticle.Source,
-		ImageURL: selectedArticle.ImageURL,
+// generationLanguage определяет язык поста по флагу -lang=xx в запросе или по настройкам
+// пользователя. Возвращает запрос без флага.
+func (b *Bot) generationLanguage(userID int64, args string) (ai.Language, string, error) {
+	code, rest := extractLanguageFlag(args)
+	if code == "" {
+		return ai.LanguageOrDefault(b.db.GetSettings(userID).Language), rest, nil
 	}
 
-	log.Printf("[GENERATE] Генерация поста через AI...")
-	post, err := b.gptClient.GeneratePost(ctx, keywords, articleInfo)
+	language, err := ai.ParseLanguage(code)
 	if err != nil {
-		log.Printf("[GENERATE] ❌ Ошибка генерации поста для темы: %s, ошибка: %v", keywords, err)
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ Ошибка генерации\n\n🎯 Тема: %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: Ошибка AI при генерации поста", keywords))
-		return
+		return ai.Language{}, rest, err
 	}
+	return language, rest, nil
+}
 
-	// Проверяем, не отказался ли GPT
-	if b.isGPTRefusal(post) {
-		log.Printf("[GENERATE] ❌ GPT отказался генерировать пост для темы: %s", keywords)
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ ИИ отказался делать пост на данную тему\n\n🎯 Тема: %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: ИИ отказался обсуждать данную тему\n\n💡 Попробуйте другую тему или выберите другую новость", keywords))
-		return
+// extractLanguageFlag извлекает из запроса флаг -lang=xx
+func extractLanguageFlag(args string) (string, string) {
+	var code string
+	var rest []string
+	for _, field := range strings.Fields(args) {
+		if value, ok := strings.CutPrefix(strings.ToLower(field), "-lang="); ok {
+			code = value
+			continue
+		}
+		rest = append(rest, field)
 	}
+	return code, strings.Join(rest, " ")
+}
 
-	if strings.TrimSpace(post) == "" {
-		log.Printf("[GENERATE] ❌ Получен пустой пост")
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ Ошибка генерации\n\n🎯 Тема: %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: AI вернул пустой пост", keywords))
-		return
+// postLanguageCodes коды языков постов через запятую, для подсказок пользователю
+func postLanguageCodes() string {
+	var codes []string
+	for _, language := range ai.Languages() {
+		codes = append(codes, language.Code)
 	}
+	return strings.Join(codes, ", ")
+}
 
-	log.Printf("[GENERATE] Пост сгенерирован, длина: %d символов", len(post))
+// notifyBreakerChange сообщает администратору о сбое и восстановлении AI
+func (b *Bot) notifyBreakerChange(from, to ai.BreakerState) {
+	if to == ai.BreakerOpen {
+		reporting.Capture(reporting.Event{
+			Level:     reporting.LevelError,
+			Operation: "ai_breaker",
+			Message:   fmt.Sprintf("AI недоступен: %d ошибок подряд", ai.Breaker().ConsecutiveFailures),
+		})
+	}
+	switch to {
+	case ai.BreakerOpen:
+		status := ai.Breaker()
+		b.notifyAdmin(fmt.Sprintf("🚨 AI недоступен: запросы заблокированы после %d ошибок подряд", status.ConsecutiveFailures), false, "")
+	case ai.BreakerClosed:
+		if from == ai.BreakerHalfOpen {
+			b.notifyAdmin("✅ AI снова доступен, запросы возобновлены", false, "")
+		}
+	}
+}
 
-	// ТОЛЬКО ЗДЕСЬ списываем генерацию, когда все этапы успешно пройдены
-	success, err := b.db.UseGeneration(userID)
-	if err != nil || !success {
-		log.Printf("[GENERATE] ❌ Ошибка списания генерации: %v", err)
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ Ошибка системы\n\n🎯 Тема: %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: Ошибка при списании генерации", keywords))
+// reportAIError отправляет ошибку модели в сервис отчетов: временные сбои как
+// предупреждения, остальные как ошибки. Отказы открытого автомата не отправляются,
+// о самом срабатывании автомата сообщает notifyBreakerChange.
+func reportAIError(operation string, userID int64, err error) {
+	if errors.Is(err, ai.ErrCircuitOpen) {
 		return
 	}
+	level := reporting.LevelError
+	if ai.IsRetryable(err) {
+		level = reporting.LevelWarning
+	}
+	reporting.Capture(reporting.Event{Level: level, Operation: operation, Message: err.Error(), UserID: userID})
+}
 
-	b.db.AddGeneration(userID, keywords)
+// aiFailureReason формулирует для пользователя причину ошибки AI
+func aiFailureReason(lang string, err error) string {
+	if ai.IsRetryable(err) {
+		return i18n.T(lang, "reason.ai_overloaded")
+	}
+	return i18n.T(lang, "reason.ai_internal")
+}
 
-	// Увеличиваем счетчик генераций для напоминания об отзыве
-	b.db.IncrementGenerationsCount(userID)
+// describeNoNews объясняет пользователю, почему по запросу не нашлось новостей
+func describeNoNews(lang string, diag news.SearchDiagnostics) string {
+	switch {
+	case diag.Fetched == 0 && diag.SourcesFailed > 0:
+		return i18n.T(lang, "no_news.sources_down")
+	case diag.Fetched == 0:
+		return i18n.T(lang, "no_news.empty")
+	case diag.ContentFiltered > 0 && diag.Excluded == 0 && diag.Scored == 0 &&
+		diag.ContentFiltered == diag.Fetched-diag.OtherSources-diag.OutOfWindow:
+		return i18n.T(lang, "no_news.forbidden")
+	case diag.Excluded > 0 && diag.Scored == 0:
+		return i18n.T(lang, "no_news.excluded")
+	case diag.BestRejectedTitle != "":
+		return i18n.T(lang, "no_news.closest", diag.BestRejectedTitle)
+	default:
+		return i18n.T(lang, "no_news.default")
+	}
+}
 
-	// Все шаги завершены успешно
-	b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-		fmt.Sprintf("🔄 Генерация поста начата\n\n🎯 Тема: %s\n\n✅ Шаг 1/3: ✓ Готово\n✅ Шаг 2/3: ✓ Найдено %d новостей\n✅ Шаг 3/3: ✓ Генерация завершена\n\n✨ Все этапы завершены! Отправляю результат...",
-			keywords, len(articles)))
-
-	// Отправляем результат
-	user = b.db.GetUser(userID)
-
-	// 1. Отправляем изображение прямо в пост (если есть)
-	if selectedArticle.ImageURL != "" && b.isValidImageURL(selectedArticle.ImageURL) {
-		// Создаем сообщение с фото и текстом
-		if err := b.sendPhotoWithCaption(userID, selectedArticle.ImageURL, post); err != nil {
-			log.Printf("[GENERATE] ❌ Ошибка отправки фото с текстом: %v, отправляю только текст", err)
-			// Если не удалось отправить с фото, отправляем только текст
-			b.sendMessageWithMarkdown(userID, post)
-		} else {
-			log.Printf("[GENERATE] ✅ Пост отправлен с изображением")
+// parseSearchFlags извлекает параметры поиска из начала запроса: -period=today (за сутки),
+// -period=week (за неделю), -period=Nd / -period=Nh (за N дней / часов), -source=Имя
+// и -minscore=N (релевантность новости не ниже N из 100). У флагов есть значение после =,
+// поэтому -слово без него остается исключением из запроса, даже если это -week или -3d.
+// Возвращает параметры поиска и оставшийся запрос.
+func parseSearchFlags(args string) (news.SearchOptions, string) {
+	var opts news.SearchOptions
+	fields := strings.Fields(args)
+
+	i := 0
+	for ; i < len(fields); i++ {
+		name, value, ok := strings.Cut(fields[i], "=")
+		if !ok || !strings.HasPrefix(name, "-") {
+			break
 		}
-	} else {
-		// Если нет изображения, отправляем только текст
-		b.sendMessageWithMarkdown(userID, post)
-	}
 
-	// 2. Отправляем метаданные отдельным сообщением
-	hashtags := b.generateHashtags(selectedArticle)
-	metadata := fmt.Sprintf(
-		"📋 *Метаданные для поста (добавьте по желанию):*\n\n"+
-			"🔖 *Рекомендуемые хештеги:*\n"+
-			"%s\n\n"+
-			"📰 *Источник:* [Новость](%s) взята с %s\n\n"+
-			"✨ *Осталось генераций:* %d",
-		hashtags,
-		selectedArticle.URL,
-		selectedArticle.Source,
-		user.AvailableGenerations)
+		switch strings.ToLower(name) {
+		case "-period", "-период":
+			age, ok := parsePeriodFlag(strings.ToLower(value))
+			if !ok {
+				return opts, strings.Join(fields[i:], " ")
+			}
+			opts.MaxAge = age
+		case "-source":
+			opts.SourceFilter = append(opts.SourceFilter, value)
+		case "-minscore":
+			score, err := strconv.Atoi(value)
+			if err != nil || score < 0 || score > 100 {
+				return opts, strings.Join(fields[i:], " ")
+			}
+			opts.MinScore = float64(score)
+		default:
+			// Это не флаг, а часть запроса
+			return opts, strings.Join(fields[i:], " ")
+		}
+	}
 
-	b.sendMessageWithMarkdown(userID, metadata)
+	return opts, strings.Join(fields[i:], " ")
+}
 
-	// 3. Отправляем кнопки для оценки качества
-	b.sendRatingRequest(userID, keywords)
+// parsePeriodFlag разбирает период: today, week или вида 3d и 12h
+func parsePeriodFlag(value string) (time.Duration, bool) {
+	switch value {
+	case "today", "сегодня":
+		return 24 * time.Hour, true
+	case "week", "неделя":
+		return 7 * 24 * time.Hour, true
+	}
+	if len(value) < 2 {
+		return 0, false
+	}
 
-	// 4. Проверяем, нужно ли напомнить об отзыве
-	if b.db.ShouldRemindFeedback(userID) {
-		b.sendFeedbackReminder(userID)
+	count, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || count <= 0 {
+		return 0, false
 	}
 
-	log.Printf("[GENERATE] ✅ Завершена обработка запроса от %d", userID)
+	switch value[len(value)-1] {
+	case 'd':
+		return time.Duration(count) * 24 * time.Hour, true
+	case 'h':
+		return time.Duration(count) * time.Hour, true
+	}
+	return 0, false
 }
 
-// handleGenerateFromURL обрабатывает генерацию по ссылке
-func (b *Bot) handleGenerateFromURL(ctx context.Context, msg *tgbotapi.Message, url string) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("[PANIC] Восстановление после паники в handleGenerateFromURL: %v", r)
-			b.sendMessage(msg.Chat.ID, "❌ Произошла внутренняя ошибка. Попробуйте позже.")
-		}
-	}()
+// isURL проверяет, является ли строка URL
+func (b *Bot) isURL(text string) bool {
+	return strings.HasPrefix(text, "http://") ||
+		strings.HasPrefix(text, "https://") ||
+		strings.Contains(text, "://")
+}
 
+// handleGenerateFromKeywords обрабатывает генерацию по ключевым словам
+func (b *Bot) handleGenerateFromKeywords(ctx context.Context, msg *tgbotapi.Message, keywords string) {
 	userID := msg.Chat.ID
+	lang := b.lang(userID)
+	trace := traceFrom(ctx)
 
-	log.Printf("[GENERATE] Начало обработки ссылки от %d: %s", userID, url)
+	// Тестовая генерация идет с настройками проверяемого пользователя и ничего не списывает
+	dry, isDryRun := dryRunFrom(ctx)
+	settingsUser := userID
+	if isDryRun {
+		settingsUser = dry.target
+	}
+	// В эксперимент попадают только генерации с кодом запроса: по нему в журнале
+	// отмечается вариант
+	if !isDryRun && trace.ID() != "" {
+		ctx = b.withExperiment(ctx, userID)
+	}
 
-	// Проверяем доступные генерации
-	user := b.db.GetUser(userID)
-	log.Printf("[GENERATE] Пользователь %d: доступно %d генераций", userID, user.AvailableGenerations)
+	searchOpts, keywords := parseSearchFlags(keywords)
 
-	if user.AvailableGenerations <= 0 {
-		text := "❌ Закончились генерации!\n\n" +
-			"💎 Используйте команду /buy чтобы приобрести дополнительные генерации\n\n" +
-			"✨ Доступные пакеты:\n" +
-			"• 10 генераций - 99 руб\n" +
-			"• 25 генераций - 199 руб\n" +
-			"• 100 генераций - 499 руб"
-		b.sendMessage(userID, text)
+	if keywords == "" {
+		b.sendMessage(userID, i18n.T(lang, "generate.no_keywords"))
 		return
 	}
 
-	// Шаг 1: Начало процесса
-	step1Msg := b.sendMessage(userID, fmt.Sprintf("🔄 Генерация поста по ссылке\n\n🔗 %s\n\n⏳ Шаг 1/3: Получаю содержимое страницы...", b.truncateURL(url)))
-
-	// Шаг 2: Получаем содержимое страницы
-	b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-		fmt.Sprintf("🔄 Генерация поста по ссылке\n\n🔗 %s\n\n✅ Шаг 1/3: ✓ Готово\n⏳ Шаг 2/3: Анализирую содержимое...", b.truncateURL(url)))
+	log.Printf("[GENERATE] Начало обработки запроса от %d: %s", userID, keywords)
+	log.Printf("[GENERATE] Пользователь %d: доступно %d генераций", userID, b.db.GetUser(userID).AvailableGenerations)
 
-	title, content, mainImage, err := b.fetchWebContent(url)
-	if err != nil {
-		log.Printf("[GENERATE] ❌ Ошибка получения содержимого: %v", err)
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ Ошибка генерации\n\n🔗 %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: Не удалось получить содержимое страницы", b.truncateURL(url)))
+	if !isDryRun && b.hasPreview(userID) {
+		b.sendMessage(userID, i18n.T(lang, "preview.pending"))
 		return
 	}
 
-	if title == "" {
-		title = "Новость с сайта"
+	progress := b.newGenerationProgress(ctx, userID, generationRequest{
+		kind:        "generate",
+		subject:     keywords,
+		topic:       keywords,
+		firstStep:   i18n.T(lang, "generate.step_search", keywords),
+		stepAI:      func(found int) string { return i18n.T(lang, "generate.step_ai", keywords, found) },
+		fetchFailed: i18n.T(lang, "generate.failed", keywords, i18n.T(lang, "reason.search_failed")),
+	})
+	outcome := events.OutcomeFailed
+	defer func() { progress.finished(outcome) }()
+
+	result, err := b.generator.GenerateFromKeywords(ctx, userID, keywords, generator.Options{
+		Search:         searchOpts,
+		SmartSelection: b.db.GetSettings(settingsUser).SmartSelection,
+		DryRun:         isDryRun,
+		Progress:       progress,
+	})
+	progress.wait()
+	if err != nil {
+		outcome = b.generationFailed(ctx, progress, err)
+		return
 	}
+	// Пост в предпросмотре держит резерв до решения пользователя, на остальных исходах
+	// неиспользованный резерв возвращается
+	defer func() {
+		if outcome != events.OutcomePreview {
+			result.Reservation.Release()
+		}
+	}()
 
-	// Обрезаем контент до 3000 символов (чтобы не тратить много токенов)
-	if len(content) > 3000 {
-		content = content[:3000] + "..."
+	selectedArticle, post := result.Article, result.Post
+	source := selectedArticle.Content
+	if strings.TrimSpace(source) == "" {
+		source = selectedArticle.Summary
+	}
+	article := ai.SourceArticle{
+		Title:       selectedArticle.Title,
+		URL:         selectedArticle.URL,
+		Source:      selectedArticle.Source,
+		Content:     source,
+		PublishedAt: selectedArticle.PublishedAt,
+	}
+	hashtags := post.HashtagLine()
+	if hashtags == "" {
+		hashtags = b.generateHashtags(selectedArticle, ai.LanguageFromContext(ctx))
+	}
+	metadata := func(generations int) richtext.Text {
+		if !sourceInMetadata(ctx) {
+			return richT(lang, "rewrite.metadata", hashtags, generations)
+		}
+		return richT(lang, "generate.metadata", hashtags, selectedArticle.URL, selectedArticle.Source, generations)
 	}
 
-	// Шаг 3: Генерация через AI
-	b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-		fmt.Sprintf("🔄 Генерация поста по ссылке\n\n🔗 %s\n\n✅ Шаг 1/3: ✓ Готово\n✅ Шаг 2/3: ✓ Содержимое получено\n⏳ Шаг 3/3: Генерация поста через AI...", b.truncateURL(url)))
+	if isDryRun {
+		// Тестовая генерация только записывается в журнал с пометкой. Оценки и напоминания
+		// исказили бы веса источников и счетчики.
+		trace.stage(stageCharge)
+		b.db.AddGenerationOutcome(dry.target, keywords, database.OutcomeDryRun, selectedArticle.Source, trace.ID())
+		progress.message.finish(dryRunLabel + "\n\n" + i18n.T(lang, "generate.done", keywords, result.Found))
 
-	log.Printf("[GENERATE] Генерация поста через AI...")
-	post, err := b.gptClient.GeneratePostFromURL(ctx, title, content)
-	if err != nil {
-		log.Printf("[GENERATE] ❌ Ошибка генерации поста для ссылки: %s, ошибка: %v", url, err)
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ Ошибка генерации\n\n🔗 %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: Ошибка AI при генерации поста", b.truncateURL(url)))
+		trace.stage(stageDelivery)
+		b.sendPost(ctx, userID, result.ImageURL, post, article)
+		b.sendRichText(userID, metadata(b.db.GetUser(userID).AvailableGenerations).Append(richtext.Plain("\n\n"+dryRunLabel)), nil)
+		log.Printf("[TESTGEN] ✅ Тестовая генерация для %d завершена", dry.target)
 		return
 	}
 
-	// Проверяем, не отказался ли GPT
-	if b.isGPTRefusal(post) {
-		log.Printf("[GENERATE] ❌ GPT отказался генерировать пост для ссылки: %s", url)
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ ИИ отказался делать пост на данную тему\n\n🔗 %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: ИИ отказался обсуждать данную тему\n\n💡 Попробуйте другую ссылку", b.truncateURL(url)))
+	ready := &readyPost{
+		ctx:         ctx,
+		userID:      userID,
+		requestID:   trace.ID(),
+		post:        post,
+		imageURL:    result.ImageURL,
+		article:     article,
+		topic:       keywords,
+		source:      selectedArticle.Source,
+		ratingTopic: keywords,
+		metadata:    metadata,
+		done:        i18n.T(lang, "generate.done", keywords, result.Found),
+		reservation: result.Reservation,
+	}
+	if b.previewWanted(userID) {
+		if b.offerPreview(ready, progress.message) {
+			outcome = events.OutcomePreview
+		}
 		return
 	}
-
-	if strings.TrimSpace(post) == "" {
-		log.Printf("[GENERATE] ❌ Получен пустой пост")
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ Ошибка генерации\n\n🔗 %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: AI вернул пустой пост", b.truncateURL(url)))
+	// ТОЛЬКО ЗДЕСЬ списываем зарезервированную генерацию, когда все этапы успешно пройдены
+	if !b.deliverReadyPost(ready, progress.message, trace) {
 		return
 	}
+	outcome = events.OutcomeSuccess
 
-	log.Printf("[GENERATE] Пост сгенерирован, длина: %d символов", len(post))
-
-	// ТОЛЬКО ЗДЕСЬ списываем генерацию, когда все этапы успешно пройдены
-	success, err := b.db.UseGeneration(userID)
-	if err != nil || !success {
-		log.Printf("[GENERATE] ❌ Ошибка списания генерации: %v", err)
-		b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-			fmt.Sprintf("❌ Ошибка системы\n\n🔗 %s\n\n⏹️ Процесс остановлен\n\n📛 Причина: Ошибка при списании генерации", b.truncateURL(url)))
-		return
-	}
+	log.Printf("[GENERATE] ✅ Завершена обработка запроса от %d", userID)
+}
 
-	b.db.AddGeneration(userID, "ссылка: "+b.truncateURL(url))
+// handleGenerateFromURL обрабатывает генерацию по ссылке
+func (b *Bot) handleGenerateFromURL(ctx context.Context, msg *tgbotapi.Message, url string) {
+	userID := msg.Chat.ID
+	lang := b.lang(userID)
+	trace := traceFrom(ctx)
 
-	// Увеличиваем счетчик генераций для напоминания об отзыве
-	b.db.IncrementGenerationsCount(userID)
+	log.Printf("[GENERATE] Начало обработки ссылки от %d: %s", userID, url)
+	log.Printf("[GENERATE] Пользователь %d: доступно %d генераций", userID, b.db.GetUser(userID).AvailableGenerations)
 
-	// Все шаги завершены успешно
-	b.editMessage(step1Msg.Chat.ID, step1Msg.MessageID,
-		fmt.Sprintf("🔄 Генерация поста по ссылке\n\n🔗 %s\n\n✅ Шаг 1/3: ✓ Готово\n✅ Шаг 2/3: ✓ Содержимое получено\n✅ Шаг 3/3: ✓ Генерация завершена\n\n✨ Все этапы завершены! Отправляю результат...", b.truncateURL(url)))
+	if b.hasPreview(userID) {
+		b.sendMessage(userID, i18n.T(lang, "preview.pending"))
+		return
+	}
 
-	// Отправляем результат
-	user = b.db.GetUser(userID)
+	shortURL := b.truncateURL(url)
+	topic := "ссылка: " + shortURL
+	progress := b.newGenerationProgress(ctx, userID, generationRequest{
+		kind:        "generate_url",
+		subject:     shortURL,
+		topic:       topic,
+		firstStep:   i18n.T(lang, "generate_url.step_fetch", shortURL),
+		stepAI:      func(int) string { return i18n.T(lang, "generate_url.step_ai", shortURL) },
+		fetchFailed: i18n.T(lang, "generate_url.fetch_failed", shortURL),
+	})
+	outcome := events.OutcomeFailed
+	defer func() { progress.finished(outcome) }()
+
+	result, err := b.generator.GenerateFromURL(ctx, userID, url, generator.Options{Progress: progress})
+	progress.wait()
+	if err != nil {
+		outcome = b.generationFailed(ctx, progress, err)
+		return
+	}
+	defer func() {
+		if outcome != events.OutcomePreview {
+			result.Reservation.Release()
+		}
+	}()
 
-	// 1. Отправляем изображение прямо в пост (если есть)
-	if mainImage != "" && b.isValidImageURL(mainImage) {
-		// Создаем сообщение с фото и текстом
-		if err := b.sendPhotoWithCaption(userID, mainImage, post); err != nil {
-			log.Printf("[GENERATE] ❌ Ошибка отправки фото с текстом: %v, отправляю только текст", err)
-			// Если не удалось отправить с фото, отправляем только текст
-			b.sendMessageWithMarkdown(userID, post)
-		} else {
-			log.Printf("[GENERATE] ✅ Пост отправлен с изображением")
+	post, page := result.Post, result.Article
+	hashtags := post.HashtagLine()
+	if hashtags == "" {
+		hashtags = "#" + strings.Join(ai.LanguageFromContext(ctx).DefaultHashtags, " #")
+	}
+	ready := &readyPost{
+		ctx:         ctx,
+		userID:      userID,
+		requestID:   trace.ID(),
+		post:        post,
+		imageURL:    result.ImageURL,
+		article:     ai.SourceArticle{Title: page.Title, URL: url, Content: page.Content},
+		topic:       topic,
+		ratingTopic: "ссылка",
+		metadata: func(generations int) richtext.Text {
+			if !sourceInMetadata(ctx) {
+				return richT(lang, "rewrite.metadata", hashtags, generations)
+			}
+			return richT(lang, "generate_url.metadata", hashtags, url, generations)
+		},
+		done:        i18n.T(lang, "generate_url.done", shortURL),
+		reservation: result.Reservation,
+	}
+	if b.previewWanted(userID) {
+		if b.offerPreview(ready, progress.message) {
+			outcome = events.OutcomePreview
 		}
-	} else {
-		// Если нет изображения, отправляем только текст
-		b.sendMessageWithMarkdown(userID, post)
+		return
 	}
-
-	// 2. Отправляем метаданные отдельным сообщением
-	metadata := fmt.Sprintf(
-		"📋 *Метаданные для поста (добавьте по желанию):*\n\n"+
-			"🔖 *Рекомендуемые хештеги:*\n"+
-			"#новости #интересное\n\n"+
-			"📰 *Источник:* [Ссылка на статью](%s)\n\n"+
-			"✨ *Осталось генераций:* %d",
-		url,
-		user.AvailableGenerations)
-
-	b.sendMessageWithMarkdown(userID, metadata)
-
-	// 3. Отправляем кнопки для оценки качества
-	b.sendRatingRequest(userID, "ссылка")
+	// ТОЛЬКО ЗДЕСЬ списываем зарезервированную генерацию, когда все этапы успешно пройдены
+	if !b.deliverReadyPost(ready, progress.message, trace) {
+		return
+	}
+	outcome = events.OutcomeSuccess
 
 	log.Printf("[GENERATE] ✅ Завершена обработка ссылки от %d", userID)
 }
 
-// sendPhotoWithCaption отправляет фото с текстом поста
-func (b *Bot) sendPhotoWithCaption(chatID int64, photoURL, caption string) error {
-	// Ограничение Telegram на длину подписи к фото
-	maxCaptionLength := 1024
-	if len(caption) > maxCaptionLength {
-		caption = b.truncateText(caption, maxCaptionLength-3) + "..."
+// sendPost отправляет пост с картинкой новости. Если картинки нет, а пользователь
+// включил генерацию иллюстраций, рисует ее; при любой ошибке отправляет только текст.
+// Если пользователь выбрал ссылку на источник в посте, она добавляется последней строкой.
+// Пост и исходная статья запоминаются для кнопки «Расширить» и вопросов о статье,
+// а пост — еще и для inline-режима.
+// Возвращает номер сообщения с постом; 0 — пост не отправлен.
+func (b *Bot) sendPost(ctx context.Context, userID int64, imageURL string, post ai.Post, article ai.SourceArticle) int {
+	lang := b.lang(userID)
+	source := sourceLine(ctx, lang, article.URL)
+	text := withSourceLine(postMessageText(lang, post), source, maxSendMessageLength)
+	caption := withSourceLine(postMessageText(lang, post), source, maxCaptionLength)
+	_, isDryRun := dryRunFrom(ctx)
+
+	delivered := &deliveredPost{post: post, article: article, source: source, language: ai.LanguageFromContext(ctx),
+		delivered: time.Now(), recentID: newRequestID(), inline: fromInline(ctx)}
+	keyboard := *delivered.keyboard(lang, true)
+	defer func() {
+		if delivered.messageID == 0 {
+			return
+		}
+		if !isDryRun {
+			b.inlinePosts.add(userID, newRecentPost(delivered.recentID, post, text))
+		}
+		b.postsMu.Lock()
+		b.posts[userID] = delivered
+		b.postsMu.Unlock()
+		b.rememberDelivered(ctx, delivered.messageID)
+	}()
+
+	if imageURL != "" && b.isValidImageURL(imageURL) {
+		message, stage := b.sendPostPhoto(userID, imageURL, caption, keyboard)
+		traceFrom(ctx).photo(stage)
+		if stage == photoTextOnly {
+			log.Printf("[GENERATE] ❌ Фото не отправлено ни одним способом, отправляю только текст")
+			delivered.messageID = b.sendRichText(userID, text, &keyboard).MessageID
+		} else {
+			delivered.messageID, delivered.photo = message.MessageID, true
+			log.Printf("[GENERATE] ✅ Пост отправлен с изображением (%s)", stage)
+		}
+		return delivered.messageID
+	}
+
+	if image := b.generateIllustration(userID, post); image != nil {
+		message, err := b.sendPhotoBytesWithCaption(userID, image, caption, keyboard)
+		if err != nil {
+			log.Printf("[GENERATE] ❌ Ошибка отправки иллюстрации: %v, отправляю только текст", err)
+			delivered.messageID = b.sendRichText(userID, text, &keyboard).MessageID
+		} else {
+			delivered.messageID, delivered.photo = message.MessageID, true
+			log.Printf("[GENERATE] ✅ Пост отправлен со сгенерированной иллюстрацией")
+		}
+		return delivered.messageID
+	}
+
+	// Если нет изображения, отправляем только текст
+	delivered.messageID = b.sendRichText(userID, text, &keyboard).MessageID
+	return delivered.messageID
+}
+
+// postMessageText текст сообщения с постом, с предупреждением, если пост не прошел проверку.
+// Заголовок выделяется жирным, разметка модели в тексте поста становится сущностями.
+func postMessageText(lang string, post ai.Post) richtext.Text {
+	text := richtext.Markers(strings.TrimSpace(post.Body))
+	if heading := post.Heading(); heading != "" {
+		text = richtext.Concat(richtext.Bold(heading), richtext.Plain("\n\n"), text)
+	}
+	if post.SafetyWarning {
+		text = richtext.Plain(i18n.T(lang, "post.safety_warning")).Append(text)
+	}
+	return text
+}
+
+// generateIllustration рисует картинку по заголовку поста, если это включено в настройках.
+// Возвращает nil, если генерация выключена или не удалась.
+func (b *Bot) generateIllustration(userID int64, post ai.Post) []byte {
+	if b.imageClient == nil || !b.db.GetSettings(userID).GenerateImages {
+		return nil
 	}
 
-	photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(photoURL))
-	photo.Caption = caption
-	photo.ParseMode = "Markdown"
+	title := post.Title
+	if title == "" {
+		title = b.truncateText(post.Text(), 200)
+	}
 
-	_, err := b.api.Send(photo)
+	// Пост уже оплачен, поэтому картинка ограничена собственным таймаутом, а не дедлайном генерации
+	image, err := b.imageClient.GenerateImage(context.Background(), ai.ImagePrompt(title))
+	if err != nil {
+		log.Printf("[GENERATE] ⚠️ Не удалось сгенерировать иллюстрацию для %d: %v", userID, err)
+		return nil
+	}
+	return image
+}
+
+// sendPhotoFile отправляет фото с текстом поста
+func (b *Bot) sendPhotoFile(chatID int64, file tgbotapi.RequestFileData, caption richtext.Text, keyboard tgbotapi.InlineKeyboardMarkup) (tgbotapi.Message, error) {
+	caption = caption.Truncate(maxCaptionLength)
+
+	photo := tgbotapi.NewPhoto(chatID, file)
+	photo.Caption = caption.String()
+	photo.CaptionEntities = caption.Entities()
+	photo.ReplyMarkup = keyboard
+	return b.api.Send(photo)
+}
+
+// sendPhotoBytesWithCaption отправляет картинку из памяти с текстом поста
+func (b *Bot) sendPhotoBytesWithCaption(chatID int64, image []byte, caption richtext.Text, keyboard tgbotapi.InlineKeyboardMarkup) (tgbotapi.Message, error) {
+	message, err := b.sendPhotoFile(chatID, tgbotapi.FileBytes{Name: "illustration.jpg", Bytes: image}, caption, keyboard)
+	if err != nil {
+		log.Printf("[ERROR] Ошибка отправки иллюстрации: %v", err)
+		return tgbotapi.Message{}, err
+	}
+
+	log.Printf("[MESSAGE] Отправлена иллюстрация с подписью в чат %d", chatID)
+	return message, nil
+}
+
+// sendPhotoWithCaption отправляет фото с текстом поста
+func (b *Bot) sendPhotoWithCaption(chatID int64, photoURL string, caption richtext.Text, keyboard tgbotapi.InlineKeyboardMarkup) (tgbotapi.Message, error) {
+	message, err := b.sendPhotoFile(chatID, tgbotapi.FileURL(photoURL), caption, keyboard)
 	if err != nil {
 		log.Printf("[ERROR] Ошибка отправки фото: %v, URL: %s", err, photoURL)
-		return err
+		return tgbotapi.Message{}, err
 	}
 
 	log.Printf("[MESSAGE] Отправлено фото с подписью в чат %d", chatID)
-	return nil
+	return message, nil
 }
 
 // sendDocumentWithCaption отправляет документ с подписью
@@ -608,18 +1322,27 @@ func (b *Bot) isValidImageURL(url string) bool {
 	return true
 }
 
+// webClient HTTP-клиент для загрузки страниц по ссылкам пользователей
+var webClient = httpx.NewClient(30 * time.Second)
+
+// streamEditInterval минимальный интервал между правками сообщения при потоковой генерации
+const streamEditInterval = 1500 * time.Millisecond
+
+// streamPreviewLength сколько последних символов поста показывать в сообщении прогресса
+const streamPreviewLength = 3500
+
 // fetchWebContent получает содержимое веб-страницы
-func (b *Bot) fetchWebContent(url string) (string, string, string, error) {
-	client := &http.Client{Timeout: 30 * time.Second}
+func (b *Bot) fetchWebContent(ctx context.Context, url string) (string, string, string, error) {
+	if !webClient.AllowedByRobots(ctx, url) {
+		return "", "", "", fmt.Errorf("загрузка страницы запрещена robots.txt")
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", "", "", err
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-
-	resp, err := client.Do(req)
+	resp, err := webClient.Do(req)
 	if err != nil {
 		return "", "", "", err
 	}
@@ -648,7 +1371,6 @@ func (b *Bot) fetchWebContent(url string) (string, string, string, error) {
 
 	// Извлекаем текст
 	content := b.extractTextFromHTML(html)
-	content = b.truncateText(content, 5000)
 
 	return title, content, mainImage, nil
 }
@@ -694,21 +1416,35 @@ func (b *Bot) extractTextFromHTML(html string) string {
 	html = regexp.MustCompile(`<script[^>]*>[\s\S]*?</script>`).ReplaceAllString(html, "")
 	html = regexp.MustCompile(`<style[^>]*>[\s\S]*?</style>`).ReplaceAllString(html, "")
 
+	// Границы блоков становятся абзацами, чтобы AI мог сокращать статью по абзацам
+	html = regexp.MustCompile(`(?i)</(p|div|h[1-6]|li|blockquote|section|article)>|<br\s*/?>`).ReplaceAllString(html, "\n")
+
 	// Убираем HTML теги
 	html = regexp.MustCompile(`<[^>]+>`).ReplaceAllString(html, " ")
 
-	// Убираем множественные пробелы и переносы строк
-	html = regexp.MustCompile(`\s+`).ReplaceAllString(html, " ")
+	// Убираем множественные пробелы внутри абзацев и пустые абзацы
+	var paragraphs []string
+	words := 0
+	for _, line := range strings.Split(html, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		paragraphs = append(paragraphs, strings.Join(fields, " "))
 
-	// Берем первые 1000 слов
-	words := strings.Fields(html)
-	if len(words) > 1000 {
-		words = words[:1000]
+		// Ограничиваем объем страницы; точнее под контекст модели статью сократит AI-клиент
+		words += len(fields)
+		if words > maxWebContentWords {
+			break
+		}
 	}
 
-	return strings.Join(words, " ")
+	return strings.Join(paragraphs, "\n")
 }
 
+// maxWebContentWords сколько слов страницы передавать дальше
+const maxWebContentWords = 5000
+
 // truncateText обрезает текст до указанной длины
 func (b *Bot) truncateText(text string, maxLength int) string {
 	if len(text) <= maxLength {
@@ -733,72 +1469,76 @@ func (b *Bot) truncateURL(url string) string {
 }
 
 // isGPTRefusal проверяет, отказался ли GPT генерировать пост
-func (b *Bot) isGPTRefusal(post string) bool {
-	refusalPhrases := []string{
-		"я не могу обсуждать эту тему",
-		"не могу обсуждать",
-		"отказываюсь обсуждать",
-		"это неэтично",
-		"это неприемлемо",
-		"я не буду",
-		"не могу создать",
-		"не могу написать",
-		"извините, но я не могу",
-		"сожалею, но я не могу",
-	}
-
-	postLower := strings.ToLower(strings.TrimSpace(post))
-	for _, phrase := range refusalPhrases {
-		if strings.Contains(postLower, phrase) {
-			return true
-		}
-	}
-
-	return false
+func (b *Bot) isGPTRefusal(ctx context.Context, post string) bool {
+	return b.gptClient.IsRefusal(ctx, post)
 }
 
 func (b *Bot) handleBuy(msg *tgbotapi.Message) {
 	// Проверяем, доступна ли платежная система
 	if b.yooMoney == nil {
-		b.sendMessage(msg.Chat.ID,
-			"❌ Платежная система временно недоступна\n\n"+
-				"💡 Пожалуйста, попробуйте позже или свяжитесь с нами (команда /feedback).")
+		b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "buy.unavailable"))
 		return
 	}
 
 	pricing := b.db.GetPricing()
+	text := b.t(msg.Chat.ID, "buy.text", pricing["10"], pricing["25"], pricing["100"])
 
-	text := fmt.Sprintf("💎 Приобретите дополнительные генерации\n\n"+
-		"Выберите пакет:\n\n"+
-		"🔹 10 генераций - %d руб.\n"+
-		"🔹 25 генераций - %d руб.\n"+
-		"🔹 100 генераций - %d руб.\n\n"+
-		"💳 Оплата через ЮKassa\n"+
-		"✨ Генерация списывается только при успешном создании поста!",
-		pricing["10"], pricing["25"], pricing["100"])
-
-	b.sendMessageWithKeyboard(msg.Chat.ID, text, b.createBuyMenu())
+	b.sendMessageWithKeyboard(msg.Chat.ID, text, b.createBuyMenu(b.lang(msg.Chat.ID)))
 }
 
 func (b *Bot) handleBalance(msg *tgbotapi.Message) {
 	user := b.db.GetUser(msg.Chat.ID)
 
-	text := fmt.Sprintf(
-		"🎯 Ваш баланс\n\n"+
-			"✨ Доступно генераций: %d\n"+
-			"📊 Всего использовано: %d\n\n"+
-			"💡 Генерация списывается только при успешном создании поста\n"+
-			"💰 Используйте /buy для покупки дополнительных генераций",
-		user.AvailableGenerations,
-		user.TotalGenerations)
+	// Зарезервированные генерации заняты идущими генерациями и предпросмотрами
+	reserved := ""
+	if user.ReservedGenerations > 0 {
+		reserved = b.t(msg.Chat.ID, "balance.reserved", user.ReservedGenerations)
+	}
+	b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "balance.text", user.AvailableGenerations, reserved, user.TotalGenerations))
+}
 
-	b.sendMessage(msg.Chat.ID, text)
+// handleTrends показывает популярные темы в новостях за последние сутки
+func (b *Bot) handleTrends(msg *tgbotapi.Message) {
+	lang := b.lang(msg.Chat.ID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.GenerationTimeout)
+	defer cancel()
+	articles := b.newsAggregator.RecentArticles(ctx, 24*time.Hour)
+	trends := news.ExtractTrends(articles, 10)
+
+	if len(trends) == 0 {
+		b.sendMessage(msg.Chat.ID, i18n.T(lang, "trends.empty"))
+		return
+	}
+
+	text := i18n.T(lang, "trends.header", len(articles))
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, trend := range trends {
+		text += i18n.T(lang, "trends.item", i+1, trend.Label, trend.Articles)
+
+		// Данные кнопки ограничены 64 байтами
+		topic := trend.Label
+		if len("trend_"+topic) > 64 {
+			topic = strings.Fields(topic)[0]
+		}
+		for len("trend_"+topic) > 64 {
+			runes := []rune(topic)
+			topic = string(runes[:len(runes)-1])
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "trends.button", trend.Label), "trend_"+topic),
+		))
+	}
+	text += i18n.T(lang, "trends.footer")
+
+	b.sendMessageWithKeyboard(msg.Chat.ID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
 }
 
-func (b *Bot) generateHashtags(article news.Article) string {
-	hashtags := []string{"новости", "интересное"}
+func (b *Bot) generateHashtags(article news.Article, language ai.Language) string {
+	hashtags := slices.Clone(language.DefaultHashtags)
 
-	if len(article.Tags) > 0 {
+	// Теги статьи на языке источника, для поста на другом языке они не подходят
+	if len(article.Tags) > 0 && language.Code == ai.DefaultLanguage {
 		for _, tag := range article.Tags {
 			if tag != "" {
 				cleanTag := strings.ToLower(strings.ReplaceAll(tag, " ", ""))
@@ -838,47 +1578,53 @@ func (b *Bot) handleStatistics(msg *tgbotapi.Message) {
 		return
 	}
 
-	stats := b.db.GetStatistics(args)
+	now := time.Now()
+	periods := b.statisticsPeriods(now)
+	byKey := make(map[string]calendar.Period, len(periods))
+	for _, period := range periods {
+		byKey[period.key] = period.period
+	}
+
+	stats := b.db.GetStatistics(args, byKey)
 	if stats == nil {
 		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
 		return
 	}
 
-	text := "📊 СТАТИСТИКА БОТА\n\n"
-
-	// Все время
-	if allTime, ok := stats["all_time"].(map[string]interface{}); ok {
-		text += "⏳ ЗА ВСЕ ВРЕМЯ:\n"
-		text += fmt.Sprintf("👥 Всего пользователей: %d\n", safeInt(allTime["users"]))
-		text += fmt.Sprintf("🆕 Новых пользователей: %d\n", safeInt(allTime["new_users"]))
-		text += fmt.Sprintf("🔄 Генераций: %d\n", safeInt(allTime["generations"]))
-		text += fmt.Sprintf("💰 Покупки: 10(%d) 25(%d) 100(%d)\n",
-			safeInt(allTime["purchases_10"]), safeInt(allTime["purchases_25"]), safeInt(allTime["purchases_100"]))
-		text += fmt.Sprintf("💵 Прибыль: %d руб.\n\n", safeInt(allTime["total_revenue"]))
+	// Показатели генераций и платежей берутся из журнала событий, если он ведется
+	if b.events != nil {
+		for key, period := range byKey {
+			if values, ok := stats[key].(map[string]interface{}); ok {
+				applyEventStats(values, b.events.Stats(period.From, period.To))
+			}
+		}
 	}
 
-	// Месяц
-	if month, ok := stats["last_month"].(map[string]interface{}); ok {
-		text += "📅 ЗА ПОСЛЕДНИЙ МЕСЯЦ:\n"
-		text += fmt.Sprintf("👥 Всего пользователей: %d\n", safeInt(month["users"]))
-		text += fmt.Sprintf("🆕 Новых пользователей: %d\n", safeInt(month["new_users"]))
-		text += fmt.Sprintf("🔄 Генераций: %d\n", safeInt(month["generations"]))
-		text += fmt.Sprintf("💰 Покупки: 10(%d) 25(%d) 100(%d)\n",
-			safeInt(month["purchases_10"]), safeInt(month["purchases_25"]), safeInt(month["purchases_100"]))
-		text += fmt.Sprintf("💵 Прибыль: %d руб.\n\n", safeInt(month["total_revenue"]))
+	text := fmt.Sprintf("📊 СТАТИСТИКА БОТА\n🕰 Часовой пояс: %s\n\n", b.reportLocation())
+	rolling := false
+	for _, period := range periods {
+		values, ok := stats[period.key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if period.rolling && !rolling {
+			text += "🔁 СКОЛЬЗЯЩИЕ ОКНА\n\n"
+			rolling = true
+		}
+		text += statisticsSection(period.title, values)
 	}
+	text = strings.TrimSuffix(text, "\n")
 
-	// День
-	if day, ok := stats["last_24h"].(map[string]interface{}); ok {
-		text += "🌞 ЗА ПОСЛЕДНИЕ 24 ЧАСА:\n"
-		text += fmt.Sprintf("👥 Всего пользователей: %d\n", safeInt(day["users"]))
-		text += fmt.Sprintf("🆕 Новых пользователей: %d\n", safeInt(day["new_users"]))
-		text += fmt.Sprintf("🔄 Генераций: %d\n", safeInt(day["generations"]))
-		text += fmt.Sprintf("💰 Покупки: 10(%d) 25(%d) 100(%d)\n",
-			safeInt(day["purchases_10"]), safeInt(day["purchases_25"]), safeInt(day["purchases_100"]))
-		text += fmt.Sprintf("💵 Прибыль: %d руб.\n", safeInt(day["total_revenue"]))
+	if dropped := b.events.Dropped(); dropped > 0 {
+		text += fmt.Sprintf("\n⚠️ Событий не учтено из-за переполненной очереди: %d", dropped)
 	}
 
+	cache := ai.PostCacheStats()
+	text += fmt.Sprintf("\n\n🗃 Кэш постов: %d записей, попаданий %d, промахов %d, вытеснено %d",
+		cache.Size, cache.Hits, cache.Misses, cache.Evictions)
+
+	text += stageDurationsText(b.events.Stats(now.Add(-24*time.Hour), now))
+
 	// Топ темы
 	topTopics := b.db.GetTopGenerationTopics(time.Time{}, time.Now(), 5)
 	if len(topTopics) > 0 {
@@ -896,336 +1642,1233 @@ func (b *Bot) handleStatistics(msg *tgbotapi.Message) {
 	b.sendMessage(msg.Chat.ID, text)
 }
 
-// handleSendMessageCommand - команда для отправки сообщений всем пользователям или конкретному
-func (b *Bot) handleSendMessageCommand(msg *tgbotapi.Message) {
-	args := strings.TrimSpace(msg.CommandArguments())
-	if args == "" {
-		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n"+
-			"/sendmsg пароль текст_сообщения - отправить всем\n"+
-			"/sendmsg пароль chatid текст_сообщения - отправить конкретному пользователю")
+// stageDurationsText медианная и 95-я процентильная длительность этапов генерации
+// за период; пустая строка, если генераций не было
+func stageDurationsText(counters events.Counters) string {
+	total50, ok := counters.Percentile(events.TotalStage, 50)
+	if !ok {
+		return ""
+	}
+	total95, _ := counters.Percentile(events.TotalStage, 95)
+	text := fmt.Sprintf("\n\n⏱ ДЛИТЕЛЬНОСТЬ ГЕНЕРАЦИЙ ЗА 24 ЧАСА (p50 / p95):\nВсего: %s / %s\n",
+		formatStageDuration(total50), formatStageDuration(total95))
+	for _, stage := range traceStages {
+		p50, ok := counters.Percentile(stage, 50)
+		if !ok {
+			continue
+		}
+		p95, _ := counters.Percentile(stage, 95)
+		text += fmt.Sprintf("%s: %s / %s\n", stage, formatStageDuration(p50), formatStageDuration(p95))
+	}
+	return strings.TrimSuffix(text, "\n")
+}
+
+// formatStageDuration длительность этапа с точностью, удобной для чтения
+func formatStageDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// statisticsPeriod раздел /statistics
+type statisticsPeriod struct {
+	key    string
+	title  string
+	period calendar.Period
+	// rolling скользящее окно от текущего момента, а не календарный период
+	rolling bool
+}
+
+// statisticsPeriods разделы /statistics: календарные периоды в поясе отчетов,
+// все время и скользящие окна
+func (b *Bot) statisticsPeriods(now time.Time) []statisticsPeriod {
+	location := b.reportLocation()
+	yesterday := calendar.Yesterday(now, location)
+	week := calendar.ThisWeek(now, location)
+	month := calendar.ThisMonth(now, location)
+	return []statisticsPeriod{
+		{key: "today", title: "🌞 СЕГОДНЯ:", period: calendar.Today(now, location)},
+		{key: "yesterday", title: fmt.Sprintf("🌙 ВЧЕРА, %s:", yesterday.From.Format("02.01")), period: yesterday},
+		{key: "this_week", title: fmt.Sprintf("📆 ЭТА НЕДЕЛЯ, С %s:", week.From.Format("02.01")), period: week},
+		{key: "this_month", title: fmt.Sprintf("📅 ЭТОТ МЕСЯЦ, С %s:", month.From.Format("02.01")), period: month},
+		{key: "all_time", title: "⏳ ЗА ВСЕ ВРЕМЯ:", period: calendar.Period{To: now}},
+		{key: "last_24h", title: "🕐 ЗА ПОСЛЕДНИЕ 24 ЧАСА:", rolling: true,
+			period: calendar.Period{From: now.Add(-24 * time.Hour), To: now}},
+		{key: "last_month", title: "🗓 ЗА ПОСЛЕДНИЕ 30 ДНЕЙ:", rolling: true,
+			period: calendar.Period{From: now.AddDate(0, 0, -30), To: now}},
+	}
+}
+
+// statisticsSection раздел /statistics с показателями одного периода
+func statisticsSection(title string, period map[string]interface{}) string {
+	text := title + "\n"
+	text += fmt.Sprintf("👥 Всего пользователей: %d\n", safeInt(period["users"]))
+	text += fmt.Sprintf("🆕 Новых пользователей: %d\n", safeInt(period["new_users"]))
+	text += fmt.Sprintf("🔄 Генераций: %d\n", safeInt(period["generations"]))
+	text += fmt.Sprintf("⛔ Отклонено тем: %d\n", safeInt(period["rejected"]))
+	text += fmt.Sprintf("💰 Покупки: 10(%d) 25(%d) 100(%d)\n",
+		safeInt(period["purchases_10"]), safeInt(period["purchases_25"]), safeInt(period["purchases_100"]))
+	text += fmt.Sprintf("💵 Прибыль: %d руб.\n\n", safeInt(period["total_revenue"]))
+	return text
+}
+
+// applyEventStats заменяет показатели генераций и платежей периода данными журнала событий
+func applyEventStats(period map[string]interface{}, counters events.Counters) {
+	period["generations"] = counters.Generations
+	period["rejected"] = counters.Rejected
+	for _, code := range []string{"10", "25", "100"} {
+		period["purchases_"+code] = counters.Purchases[code]
+		period["revenue_"+code] = counters.Revenue[code]
+	}
+	period["total_revenue"] = counters.TotalRevenue
+}
+
+func (b *Bot) handlePaymentsCommand(msg *tgbotapi.Message) {
+	userID := msg.Chat.ID
+
+	if b.yooMoney == nil {
+		b.sendMessage(userID, b.t(userID, "payments.unavailable"))
 		return
 	}
 
-	parts := strings.Fields(args)
-	if len(parts) < 2 {
-		b.sendMessage(msg.Chat.ID, "❌ Недостаточно аргументов. Формат:\n"+
-			"/sendmsg пароль текст_сообщения\n"+
-			"или\n"+
-			"/sendmsg пароль chatid текст_сообщения")
+	b.sendMessage(userID, b.t(userID, "payments.text"))
+}
+
+// maxRewriteLength максимальная длина текста для /rewrite в символах
+const maxRewriteLength = 6000
+
+// handleRewriteCommand делает пост из текста пользователя: из аргументов команды,
+// из сообщения, на которое ответили командой, или из следующего сообщения
+func (b *Bot) handleRewriteCommand(msg *tgbotapi.Message) {
+	userID := msg.Chat.ID
+
+	text := strings.TrimSpace(msg.CommandArguments())
+	if text == "" && msg.ReplyToMessage != nil {
+		text = strings.TrimSpace(msg.ReplyToMessage.Text)
+		if text == "" {
+			text = strings.TrimSpace(msg.ReplyToMessage.Caption)
+		}
+	}
+
+	if text != "" {
+		b.safeGo("rewrite", msg.Chat.ID, func() { b.rewrite(msg, text) })
 		return
 	}
 
-	// Проверяем пароль
-	password := parts[0]
-	adminPassword := b.getAdminPassword()
+	b.db.SetPendingRewrite(userID, true)
+	b.sendMessage(userID, b.t(userID, "rewrite.prompt", maxRewriteLength))
+}
 
-	if password != adminPassword {
-		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+// handleRewriteText обрабатывает текст, присланный после /rewrite
+func (b *Bot) handleRewriteText(msg *tgbotapi.Message) {
+	text := strings.TrimSpace(msg.Text)
+	if text == "" {
+		text = strings.TrimSpace(msg.Caption)
+	}
+	if text == "" {
+		b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "rewrite.no_text"))
 		return
 	}
 
-	// Определяем, есть ли chatid
-	var chatID int64
-	var messageText string
-	var sendToAll bool
+	b.db.SetPendingRewrite(msg.Chat.ID, false)
+	b.safeGo("rewrite", msg.Chat.ID, func() { b.rewrite(msg, text) })
+}
 
-	if len(parts) >= 3 {
-		parsedChatID, err := strconv.ParseInt(parts[1], 10, 64)
-		if err != nil {
-			sendToAll = true
-			messageText = strings.Join(parts[1:], " ")
-		} else {
-			chatID = parsedChatID
-			messageText = strings.Join(parts[2:], " ")
+// rewrite генерирует пост из текста пользователя и списывает одну генерацию
+func (b *Bot) rewrite(msg *tgbotapi.Message, text string) {
+	userID := msg.Chat.ID
+	lang := b.lang(userID)
+
+	if length := utf8.RuneCountInString(text); length > maxRewriteLength {
+		b.sendMessage(userID, i18n.T(lang, "rewrite.too_long", length, maxRewriteLength))
+		return
+	}
+
+	if ai.CircuitOpen() {
+		b.sendMessage(userID, i18n.T(lang, "ai.circuit_open"))
+		return
+	}
+
+	held, ok := generator.Reserve(b.db, userID)
+	if !ok {
+		b.sendMessage(userID, i18n.T(lang, "generations.exhausted"))
+		return
+	}
+	defer held.Release()
+
+	log.Printf("[REWRITE] Начало рерайта для %d, длина: %d символов", userID, len(text))
+	topic := "рерайт: " + b.truncateText(text, 50)
+	outcome := events.OutcomeFailed
+	finish := b.trackGeneration(context.Background(), userID, topic)
+	defer func() { finish(outcome) }()
+	progressMsg := b.sendMessage(userID, i18n.T(lang, "rewrite.progress"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.GenerationTimeout)
+	defer cancel()
+	ctx = ai.WithLanguage(ctx, ai.LanguageOrDefault(b.db.GetSettings(userID).Language))
+	ctx = ai.WithAuditUser(ctx, userID)
+	ctx = b.withUserTier(ctx, userID)
+
+	post, err := b.gptClient.RewriteAsPost(ctx, text)
+	if ctx.Err() != nil {
+		log.Printf("[REWRITE] ⏱ Превышен лимит времени для %d", userID)
+		b.editMessage(userID, progressMsg.MessageID, i18n.T(lang, "ai.deadline_exceeded"))
+		return
+	}
+	if err != nil {
+		log.Printf("[REWRITE] ❌ Ошибка рерайта для %d: %v", userID, err)
+		reportAIError("rewrite", userID, err)
+		if errors.Is(err, ai.ErrCircuitOpen) {
+			b.editMessage(userID, progressMsg.MessageID, i18n.T(lang, "ai.circuit_open"))
+			return
 		}
+		b.editMessage(userID, progressMsg.MessageID, i18n.T(lang, "rewrite.failed", aiFailureReason(lang, err)))
+		return
+	}
+
+	if post.Refused || b.isGPTRefusal(ctx, post.Text()) {
+		if post.RefusalReason != "" {
+			log.Printf("[REWRITE] Причина отказа: %s", post.RefusalReason)
+		}
+		b.editMessage(userID, progressMsg.MessageID, i18n.T(lang, "rewrite.refused"))
+		return
+	}
+
+	if strings.TrimSpace(post.Text()) == "" {
+		log.Printf("[REWRITE] ❌ Получен пустой пост")
+		b.editMessage(userID, progressMsg.MessageID, i18n.T(lang, "rewrite.failed", i18n.T(lang, "reason.empty_post")))
+		return
+	}
+
+	if generator.FreePost(post) {
+		held.Release()
 	} else {
-		sendToAll = true
-		messageText = strings.Join(parts[1:], " ")
+		held.Commit()
+	}
+
+	b.db.AddGenerationOutcome(userID, topic, database.OutcomeRewrite, "", "")
+	outcome = events.OutcomeRewrite
+	b.db.IncrementGenerationsCount(userID)
+
+	b.editMessage(userID, progressMsg.MessageID, i18n.T(lang, "rewrite.done"))
+
+	b.sendPost(ctx, userID, "", post, ai.SourceArticle{Content: text})
+
+	hashtags := post.HashtagLine()
+	if hashtags == "" {
+		hashtags = "#" + strings.Join(ai.LanguageFromContext(ctx).DefaultHashtags, " #")
 	}
+	b.sendRichText(userID, richT(lang, "rewrite.metadata", hashtags, b.db.GetUser(userID).AvailableGenerations), nil)
 
-	if sendToAll {
-		users := b.db.GetAllUsers()
-		totalUsers := len(users)
-		successCount := 0
-		failCount := 0
+	b.sendRatingRequest(userID, "рерайт")
+
+	log.Printf("[REWRITE] ✅ Завершен рерайт для %d", userID)
+}
+
+func (b *Bot) handleFeedbackCommand(msg *tgbotapi.Message) {
+	userID := msg.Chat.ID
+
+	b.db.SetPendingFeedback(userID, true)
+
+	b.sendMessage(userID, b.t(userID, "feedback.prompt"))
+}
+
+func (b *Bot) handleCancelCommand(msg *tgbotapi.Message) {
+	userID := msg.Chat.ID
+
+	if b.db.IsUserPendingRewrite(userID) {
+		b.db.SetPendingRewrite(userID, false)
+		b.sendMessage(userID, b.t(userID, "cancel.rewrite"))
+		return
+	}
+
+	if !b.db.IsUserPendingFeedback(userID) {
+		b.sendMessage(userID, b.t(userID, "cancel.nothing"))
+		return
+	}
+
+	b.db.SetPendingFeedback(userID, false)
+	b.db.ResetGenerationsCount(userID)
+
+	b.sendMessage(userID, b.t(userID, "cancel.feedback"))
+}
+
+func (b *Bot) handleFeedbackText(msg *tgbotapi.Message) {
+	userID := msg.Chat.ID
+	feedbackText := msg.Text
+
+	if !b.db.IsUserPendingFeedback(userID) {
+		return
+	}
+
+	username := "Без имени"
+	if msg.From != nil && msg.From.UserName != "" {
+		username = "@" + msg.From.UserName
+	} else if msg.From != nil && msg.From.FirstName != "" {
+		username = msg.From.FirstName
+		if msg.From.LastName != "" {
+			username += " " + msg.From.LastName
+		}
+	}
+
+	adminMessage := fmt.Sprintf(
+		"📨 *НОВЫЙ ОТЗЫВ*\n\n"+
+			"👤 Пользователь: %s\n"+
+			"🆔 ID: %d\n"+
+			"📅 Дата: %s\n\n"+
+			"💬 Отзыв:\n%s",
+		username,
+		userID,
+		time.Now().Format("02.01.2006 15:04"),
+		feedbackText)
+
+	b.notifyAdmin(adminMessage, true, fmt.Sprintf("feedback:%d:%d", userID, msg.MessageID))
+	b.events.Emit(events.Event{Type: events.FeedbackLeft, UserID: userID})
+
+	b.db.SetPendingFeedback(userID, false)
+	b.db.ResetGenerationsCount(userID)
+
+	b.sendMessage(userID, b.t(userID, "feedback.thanks"))
+}
+
+func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
+	_, _ = b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
+
+	data := callback.Data
+
+	if strings.HasPrefix(data, "buy_") {
+		b.handlePurchase(callback.Message.Chat.ID, data)
+	} else if strings.HasPrefix(data, "settings_") {
+		b.handleSettingsCallback(callback)
+	} else if strings.HasPrefix(data, languageCallbackPrefix) {
+		b.handleLanguageCallback(callback)
+	} else if data == expandCallback {
+		// Дописывание обращается к AI и не должно задерживать очередь чата
+		b.safeGo("expand", callback.Message.Chat.ID, func() { b.handleExpandCallback(callback) })
+	} else if strings.HasPrefix(data, "rate_") {
+		b.handleRating(callback)
+	} else if strings.HasPrefix(data, deleteDataCallbackPrefix) {
+		b.handleDeleteDataCallback(callback)
+	} else if strings.HasPrefix(data, previewCallbackPrefix) {
+		b.handlePreviewCallback(callback)
+	} else if strings.HasPrefix(data, sendMessageCallbackPrefix) {
+		b.handleSendMessageCallback(callback)
+	} else if strings.HasPrefix(data, inlineGeneratePayload) {
+		b.handleInlineGenerateCallback(callback)
+	} else if strings.HasPrefix(data, "check_") {
+		b.handleCheckPayment(callback)
+	} else if strings.HasPrefix(data, "cancel_") {
+		b.handleCancelPayment(callback)
+	} else if strings.HasPrefix(data, "trend_") {
+		topic := strings.TrimPrefix(data, "trend_")
+		ctx, cancel := context.WithTimeout(context.Background(), b.config.GenerationTimeout)
+		defer cancel()
+		ctx = ai.WithAuditUser(ctx, callback.Message.Chat.ID)
+		ctx = b.withUserTier(ctx, callback.Message.Chat.ID)
+		b.handleGenerateFromKeywords(ctx, callback.Message, topic)
+	}
+}
+
+func (b *Bot) handleRating(callback *tgbotapi.CallbackQuery) {
+	userID := callback.Message.Chat.ID
+	data := callback.Data
+
+	parts := strings.SplitN(data, "_", 3)
+	if len(parts) != 3 {
+		return
+	}
+
+	rating, err := strconv.Atoi(parts[1])
+	if err != nil || rating < 1 || rating > 5 {
+		return
+	}
+
+	topic := parts[2]
+
+	// Сохраняем оценку и пересчитываем вес источника новости
+	source, weight, err := b.db.AddRating(userID, rating, topic)
+	if err != nil {
+		log.Printf("[DB] ❌ Ошибка сохранения оценки: %v", err)
+	}
+	b.events.Emit(events.Event{Type: events.RatingGiven, UserID: userID, Topic: topic, Rating: rating})
+	if source != "" {
+		b.newsAggregator.SetSourceWeight(source, weight)
+	}
+
+	username := "Без имени"
+	if callback.From != nil && callback.From.UserName != "" {
+		username = "@" + callback.From.UserName
+	} else if callback.From != nil && callback.From.FirstName != "" {
+		username = callback.From.FirstName
+		if callback.From.LastName != "" {
+			username += " " + callback.From.LastName
+		}
+	}
+
+	adminMessage := fmt.Sprintf(
+		"⭐️ *НОВАЯ ОЦЕНКА*\n\n"+
+			"👤 Пользователь: %s\n"+
+			"🆔 ID: %d\n"+
+			"🎯 Тема генерации: %s\n"+
+			"📅 Дата: %s\n\n"+
+			"⭐️ Оценка: %d/5",
+		username,
+		userID,
+		topic,
+		time.Now().Format("02.01.2006 15:04"),
+		rating)
+
+	b.notifyAdmin(adminMessage, true, "rating:"+callback.ID)
+
+	b.editMessage(callback.Message.Chat.ID, callback.Message.MessageID, b.t(userID, "rating.thanks_edit"))
+
+	b.sendMessage(userID, b.t(userID, "rating.thanks", rating))
+}
+
+// expandCallback данные кнопки «Расширить» под постом
+const expandCallback = "expand_post"
+
+// maxPostExpansions сколько раз можно расширить один пост
+const maxPostExpansions = 2
+
+// deliveredPost последний отправленный пользователю пост и контекст его генерации
+type deliveredPost struct {
+	messageID int
+	photo     bool
+	post      ai.Post
+	// article статья, по которой написан пост; у поста из текста пользователя — только текст
+	article ai.SourceArticle
+	// source строка со ссылкой на источник в конце поста; пустая, если ссылки в посте нет
+	source     richtext.Text
+	language   ai.Language
+	delivered  time.Time
+	expansions int
+	expanding  bool
+	// questions сколько вопросов о статье уже задано
+	questions int
+	// recentID номер поста в кэше inline-режима; inline — пост заказан из inline-режима
+	recentID string
+	inline   bool
+}
+
+// keyboard кнопки под постом: «Расширить», если expand, и «Вставить в чат» у поста,
+// заказанного из inline-режима; nil — кнопок нет
+func (d *deliveredPost) keyboard(lang string, expand bool) *tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if expand {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "expand.button"), expandCallback)))
+	}
+	if d.inline {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonSwitch(i18n.T(lang, "inline.insert_button"), inlinePostQuery(d.recentID))))
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return &markup
+}
+
+// handleExpandCallback дописывает к последнему посту чата абзац по фактам исходной статьи.
+// Текстовый пост редактируется на месте, к посту с картинкой приходит новое сообщение.
+func (b *Bot) handleExpandCallback(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+	messageID := callback.Message.MessageID
+	lang := b.lang(chatID)
+
+	b.postsMu.Lock()
+	delivered := b.posts[chatID]
+	switch {
+	case delivered == nil || delivered.messageID != messageID:
+		b.postsMu.Unlock()
+		b.sendMessage(chatID, i18n.T(lang, "expand.not_latest"))
+		return
+	case delivered.expanding:
+		b.postsMu.Unlock()
+		return
+	case delivered.expansions >= maxPostExpansions:
+		b.postsMu.Unlock()
+		b.sendMessage(chatID, i18n.T(lang, "expand.limit", maxPostExpansions))
+		return
+	}
+	delivered.expanding = true
+	post, source, language := delivered.post, delivered.article.Content, delivered.language
+	b.postsMu.Unlock()
+
+	defer func() {
+		b.postsMu.Lock()
+		delivered.expanding = false
+		b.postsMu.Unlock()
+	}()
+
+	if ai.CircuitOpen() {
+		b.sendMessage(chatID, i18n.T(lang, "ai.circuit_open"))
+		return
+	}
+
+	// Без списания за расширение held остается nil: резервировать нечего
+	var held *generator.Reservation
+	if b.config.ExpandChargeGeneration {
+		var ok bool
+		if held, ok = generator.Reserve(b.db, chatID); !ok {
+			b.sendMessage(chatID, i18n.T(lang, "generations.exhausted_short"))
+			return
+		}
+		defer held.Release()
+	}
+
+	log.Printf("[EXPAND] Расширение поста %d для %d", messageID, chatID)
+	progressMsg := b.sendMessage(chatID, i18n.T(lang, "expand.progress"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.GenerationTimeout)
+	defer cancel()
+	ctx = ai.WithLanguage(ctx, language)
+	ctx = ai.WithAuditUser(ctx, chatID)
+	ctx = b.withUserTier(ctx, chatID)
+
+	expanded, err := b.gptClient.ExpandPost(ctx, post, source)
+	if ctx.Err() != nil {
+		log.Printf("[EXPAND] ⏱ Превышен лимит времени для %d", chatID)
+		b.editMessage(chatID, progressMsg.MessageID, i18n.T(lang, "ai.deadline_exceeded"))
+		return
+	}
+	if err != nil {
+		log.Printf("[EXPAND] ❌ Ошибка расширения для %d: %v", chatID, err)
+		reportAIError("expand", chatID, err)
+		if errors.Is(err, ai.ErrCircuitOpen) {
+			b.editMessage(chatID, progressMsg.MessageID, i18n.T(lang, "ai.circuit_open"))
+			return
+		}
+		b.editMessage(chatID, progressMsg.MessageID, i18n.T(lang, "expand.failed", aiFailureReason(lang, err)))
+		return
+	}
+	if expanded.Refused || strings.TrimSpace(expanded.Body) == "" {
+		log.Printf("[EXPAND] ❌ Модель не дописала пост для %d", chatID)
+		b.editMessage(chatID, progressMsg.MessageID, i18n.T(lang, "expand.no_facts"))
+		return
+	}
+
+	held.Commit()
+	b.deleteMessage(chatID, progressMsg.MessageID)
+
+	b.postsMu.Lock()
+	delivered.post = expanded
+	delivered.expansions++
+	expansions, photo, citation := delivered.expansions, delivered.photo, delivered.source
+	more := expansions < maxPostExpansions
+	b.postsMu.Unlock()
+
+	text := withSourceLine(postMessageText(lang, expanded), citation, maxSendMessageLength)
+	keyboard := delivered.keyboard(lang, more)
+	b.inlinePosts.update(chatID, delivered.recentID, text)
+
+	if !photo {
+		// Без reply_markup Telegram убирает кнопку, когда расширений больше не осталось
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, text.String())
+		edit.Entities = text.Entities()
+		edit.DisableWebPagePreview = true
+		edit.ReplyMarkup = keyboard
+		if _, err := b.api.Send(edit); err != nil {
+			log.Printf("[ERROR] Ошибка редактирования поста с разметкой: %v", err)
+			edit.Entities = nil
+			if _, err := b.api.Send(edit); err != nil {
+				log.Printf("[ERROR] Ошибка редактирования поста %d в чате %d: %v", messageID, chatID, err)
+			}
+		}
+		log.Printf("[EXPAND] ✅ Пост %d расширен (%d/%d)", messageID, expansions, maxPostExpansions)
+		return
+	}
+
+	// Подпись к фото ограничена по длине, поэтому расширенный пост приходит отдельным сообщением
+	message := b.sendRichText(chatID, text, keyboard)
+	removeKeyboard := tgbotapi.NewEditMessageReplyMarkup(chatID, messageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}})
+	if _, err := b.api.Request(removeKeyboard); err != nil {
+		log.Printf("[ERROR] Ошибка удаления кнопок у поста %d в чате %d: %v", messageID, chatID, err)
+	}
+
+	if message.MessageID != 0 {
+		b.postsMu.Lock()
+		delivered.messageID = message.MessageID
+		delivered.photo = false
+		b.postsMu.Unlock()
+	}
+	log.Printf("[EXPAND] ✅ Пост %d расширен новым сообщением (%d/%d)", messageID, expansions, maxPostExpansions)
+}
+
+// handleReloadPrompts перечитывает шаблоны промптов, правила модерации и стоп-слова без перезапуска бота
+func (b *Bot) handleReloadPrompts(msg *tgbotapi.Message) {
+	password := strings.TrimSpace(msg.CommandArguments())
+	if password == "" {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/reloadprompts пароль")
+		return
+	}
+
+	if password != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	if err := ai.ReloadPrompts(); err != nil {
+		log.Printf("[COMMAND] ❌ Ошибка перезагрузки промптов: %v", err)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Промпты не обновлены, используются прежние:\n%v", err))
+		return
+	}
+
+	if err := ai.ReloadModerationRules(); err != nil {
+		log.Printf("[COMMAND] ❌ Ошибка перезагрузки правил модерации: %v", err)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("⚠️ Промпты перезагружены, но правила модерации не обновлены:\n%v", err))
+		return
+	}
+
+	if err := ai.ReloadBrandSafetyRules(); err != nil {
+		log.Printf("[COMMAND] ❌ Ошибка перезагрузки стоп-слов: %v", err)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("⚠️ Промпты и правила модерации перезагружены, но стоп-слова не обновлены:\n%v", err))
+		return
+	}
+
+	b.sendMessage(msg.Chat.ID, "✅ Промпты, правила модерации и стоп-слова перезагружены")
+}
+
+// handleReloadTexts перечитывает шаблоны /start и /help без перезапуска бота
+func (b *Bot) handleReloadTexts(msg *tgbotapi.Message) {
+	password := strings.TrimSpace(msg.CommandArguments())
+	if password == "" {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/reloadtexts пароль")
+		return
+	}
+
+	if password != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	if err := texts.Reload(); err != nil {
+		log.Printf("[COMMAND] ❌ Ошибка перезагрузки текстов: %v", err)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Тексты не обновлены, используются прежние:\n%v", err))
+		return
+	}
+
+	b.sendMessage(msg.Chat.ID, "✅ Тексты /start и /help перезагружены")
+}
+
+// handleAILog показывает последние запросы к модели для пользователя: что модель вернула
+// до обработки ботом. Помогает разбирать жалобы на посты.
+func (b *Bot) handleAILog(msg *tgbotapi.Message) {
+	parts := strings.Fields(msg.CommandArguments())
+	if len(parts) != 2 {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/ailog пароль chatid")
+		return
+	}
+
+	if parts[0] != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	userID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный chatid. Должен быть числом.")
+		return
+	}
+
+	entries, err := ai.RecentAuditEntries(userID, aiLogEntries)
+	if err != nil {
+		log.Printf("[COMMAND] ❌ Ошибка чтения журнала запросов: %v", err)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Не удалось прочитать журнал: %v", err))
+		return
+	}
+	if len(entries) == 0 {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("📭 В журнале нет запросов пользователя %d", userID))
+		return
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "🧾 Последние запросы к AI пользователя %d:\n", userID)
+	for _, entry := range entries {
+		fmt.Fprintf(&text, "\n🕐 %s · %s · %s\n", entry.Time.Format("02.01 15:04:05"), entry.Type, entry.Model)
+		fmt.Fprintf(&text, "📊 %d токенов (вход %d, ответ %d), %d мс · %s\n",
+			entry.Usage.TotalTokens, entry.Usage.InputTokens, entry.Usage.CompletionTokens, entry.LatencyMs, entry.Outcome)
+		fmt.Fprintf(&text, "🔑 Промпт %s\n", entry.PromptHash)
+		if entry.Article != "" {
+			fmt.Fprintf(&text, "📰 %s\n", entry.Article)
+		}
+		if entry.Response != "" {
+			fmt.Fprintf(&text, "💬 %s\n", b.truncateText(entry.Response, aiLogResponseLength))
+		}
+	}
+
+	b.sendMessage(msg.Chat.ID, text.String())
+}
+
+const (
+	// aiLogEntries сколько записей журнала показывает /ailog
+	aiLogEntries = 5
+	// aiLogResponseLength до скольких символов сокращается ответ модели в /ailog
+	aiLogResponseLength = 500
+)
+
+// handleStatus показывает администратору результаты проверок состояния, как /healthz и /readyz
+func (b *Bot) handleStatus(msg *tgbotapi.Message) {
+	password := strings.TrimSpace(msg.CommandArguments())
+	if password == "" {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/status пароль")
+		return
+	}
+
+	if password != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	if b.health == nil {
+		b.sendMessage(msg.Chat.ID, "❌ Проверки состояния не настроены")
+		return
+	}
+
+	// Проверки обращаются к зависимостям, поэтому выполняются вне b.mu
+	b.safeGo("status", msg.Chat.ID, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+		defer cancel()
+
+		var text strings.Builder
+		writeReport := func(title string, report health.Report) {
+			icon := "✅"
+			if !report.OK {
+				icon = "❌"
+			}
+			fmt.Fprintf(&text, "%s %s\n", icon, title)
+			for _, check := range report.Checks {
+				icon := "✅"
+				detail := check.Detail
+				if !check.OK {
+					icon = "❌"
+					detail = check.Error
+				}
+				fmt.Fprintf(&text, "   %s %s", icon, check.Name)
+				if detail != "" {
+					fmt.Fprintf(&text, ": %s", detail)
+				}
+				text.WriteString("\n")
+			}
+		}
+
+		text.WriteString("🩺 СОСТОЯНИЕ БОТА\n\n")
+		writeReport("Процесс", b.health.Liveness(ctx))
+		text.WriteString("\n")
+		writeReport("Зависимости", b.health.Readiness(ctx))
+
+		b.sendMessage(msg.Chat.ID, text.String())
+	})
+}
+
+// handleSelfTest заново выполняет самопроверку внешних зависимостей, как при запуске
+func (b *Bot) handleSelfTest(msg *tgbotapi.Message) {
+	password := strings.TrimSpace(msg.CommandArguments())
+	if password == "" {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/selftest пароль")
+		return
+	}
+
+	if password != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	if b.selfTest == nil {
+		b.sendMessage(msg.Chat.ID, "❌ Самопроверка не настроена")
+		return
+	}
+
+	// Проверки обращаются к зависимостям, поэтому выполняются вне b.mu
+	b.safeGo("selftest", msg.Chat.ID, func() {
+		progress := b.sendMessage(msg.Chat.ID, "🧪 Проверяю зависимости...")
+		report := b.selfTest.Run(context.Background())
+		if progress.MessageID != 0 {
+			b.editMessage(msg.Chat.ID, progress.MessageID, report.Format())
+			return
+		}
+		b.sendMessage(msg.Chat.ID, report.Format())
+	})
+}
+
+// logsTailBytes сколько последних байт лога отправляет /logs
+const logsTailBytes = 512 << 10
+
+// handleLogs отправляет администратору конец текущего файла лога документом
+func (b *Bot) handleLogs(msg *tgbotapi.Message) {
+	password := strings.TrimSpace(msg.CommandArguments())
+	if password == "" {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/logs пароль")
+		return
+	}
+
+	if password != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	if b.config.LogFile == "" {
+		b.sendMessage(msg.Chat.ID, "❌ Файл лога не задан")
+		return
+	}
+
+	tail, err := logging.Tail(b.config.LogFile, logsTailBytes)
+	if err != nil {
+		log.Printf("[COMMAND] ❌ Ошибка чтения лога: %v", err)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Не удалось прочитать лог: %v", err))
+		return
+	}
+	if len(tail) == 0 {
+		b.sendMessage(msg.Chat.ID, "📭 Лог пуст")
+		return
+	}
+
+	name := fmt.Sprintf("logs-%s.txt", time.Now().Format("2006-01-02-150405"))
+	doc := tgbotapi.NewDocument(msg.Chat.ID, tgbotapi.FileBytes{Name: name, Bytes: tail})
+	doc.Caption = fmt.Sprintf("📄 Последние %d КБ лога", (len(tail)+1023)/1024)
+	if _, err := b.api.Send(doc); err != nil {
+		log.Printf("[COMMAND] ❌ Ошибка отправки лога: %v", err)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Не удалось отправить лог: %v", err))
+	}
+}
+
+const (
+	// findLogsDefaultHours и findLogsMaxHours за сколько часов /findlogs ищет по умолчанию и максимум
+	findLogsDefaultHours = 24
+	findLogsMaxHours     = 14 * 24
+	// findLogsMaxLines сколько последних найденных строк отправляет /findlogs
+	findLogsMaxLines = 2000
+	// findLogsInlineLines до скольких строк результат отправляется сообщением, а не файлом
+	findLogsInlineLines = 15
+	// findLogsTimeout сколько времени дается на поиск
+	findLogsTimeout = 30 * time.Second
+)
+
+// handleFindLogs ищет в логе строки с chatID или ключевым словом:
+// /findlogs пароль chatID|слово [часов]. Поиск идет в отдельной горутине, чтобы не держать
+// обработку команд.
+func (b *Bot) handleFindLogs(msg *tgbotapi.Message) {
+	parts := strings.Fields(msg.CommandArguments())
+	if len(parts) < 2 {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("🔐 Использование:\n/findlogs пароль chatid|слово [часов]\n\n"+
+			"По умолчанию поиск за %d ч., максимум %d ч.", findLogsDefaultHours, findLogsMaxHours))
+		return
+	}
+
+	if parts[0] != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	hours := findLogsDefaultHours
+	query := parts[1:]
+	if len(query) > 1 {
+		if value, err := strconv.Atoi(query[len(query)-1]); err == nil {
+			if value <= 0 || value > findLogsMaxHours {
+				b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Неверное число часов. Должно быть от 1 до %d.", findLogsMaxHours))
+				return
+			}
+			hours, query = value, query[:len(query)-1]
+		}
+	}
+	text := strings.Join(query, " ")
 
-		b.sendMessage(msg.Chat.ID, fmt.Sprintf("🔄 Начинаю рассылку сообщения для %d пользователей...", totalUsers))
+	chatID := msg.Chat.ID
+	b.safeGo("findlogs", chatID, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), findLogsTimeout)
+		defer cancel()
 
-		for i, userID := range users {
-			err := b.sendMessageToUser(userID, messageText)
-			if err != nil {
-				failCount++
-				log.Printf("[SENDMSG] ❌ Ошибка отправки пользователю %d: %v", userID, err)
-			} else {
-				successCount++
-			}
+		since := time.Now().Add(-time.Duration(hours) * time.Hour)
+		lines, err := logging.Search(ctx, b.config.LogFile, text, since, findLogsMaxLines)
+		partial := ""
+		if err != nil {
+			log.Printf("[COMMAND] ⚠️ Поиск в логе прерван: %v", err)
+			partial = fmt.Sprintf("\n⚠️ Поиск прерван (%v), результат неполный", err)
+		}
+		if len(lines) == 0 {
+			b.sendMessage(chatID, fmt.Sprintf("📭 За %d ч. ничего не найдено по «%s»%s", hours, text, partial))
+			return
+		}
 
-			if i%10 == 0 && i > 0 {
-				time.Sleep(1 * time.Second)
-			}
+		header := fmt.Sprintf("🔎 «%s» за %d ч.: %d строк", text, hours, len(lines))
+		if len(lines) == findLogsMaxLines {
+			header += fmt.Sprintf(" (последние %d)", findLogsMaxLines)
 		}
+		header += partial
 
-		report := fmt.Sprintf("✅ Рассылка завершена!\n\n"+
-			"📊 Статистика:\n"+
-			"👥 Всего пользователей: %d\n"+
-			"✅ Успешно отправлено: %d\n"+
-			"❌ Ошибок: %d",
-			totalUsers, successCount, failCount)
+		result := strings.Join(lines, "\n")
+		if len(lines) <= findLogsInlineLines && len(header)+len(result) < 3500 {
+			b.sendMessage(chatID, header+"\n\n"+result)
+			return
+		}
 
-		b.sendMessage(msg.Chat.ID, report)
-	} else {
-		err := b.sendMessageToUser(chatID, messageText)
-		if err != nil {
-			b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Ошибка отправки пользователю %d: %v", chatID, err))
-		} else {
-			b.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ Сообщение успешно отправлено пользователю %d", chatID))
+		name := fmt.Sprintf("findlogs-%s.txt", time.Now().Format("2006-01-02-150405"))
+		doc := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: []byte(result + "\n")})
+		doc.Caption = header
+		if _, err := b.api.Send(doc); err != nil {
+			log.Printf("[COMMAND] ❌ Ошибка отправки результатов поиска: %v", err)
+			b.sendMessage(chatID, fmt.Sprintf("❌ Не удалось отправить результат: %v", err))
 		}
-	}
+	})
 }
 
-// getAdminPassword возвращает пароль админа
-func (b *Bot) getAdminPassword() string {
-	adminPassword := os.Getenv("STATISTICS_PASSWORD")
-	if adminPassword == "" {
-		adminPassword = "admin123"
-	}
-	return adminPassword
+// handleSettings показывает настройки генерации с кнопками-переключателями
+func (b *Bot) handleSettings(msg *tgbotapi.Message) {
+	settings := b.db.GetSettings(msg.Chat.ID)
+	b.sendMessageWithKeyboard(msg.Chat.ID, i18n.T(settings.InterfaceLanguage, "settings.text"), settingsKeyboard(settings))
 }
 
-// sendMessageToUser отправляет сообщение конкретному пользователю
-func (b *Bot) sendMessageToUser(chatID int64, message string) error {
-	msg := tgbotapi.NewMessage(chatID, message)
-	_, err := b.api.Send(msg)
-	return err
+// settingsKeyboard строит клавиатуру с текущими значениями настроек
+func settingsKeyboard(settings database.Settings) tgbotapi.InlineKeyboardMarkup {
+	lang := settings.InterfaceLanguage
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				i18n.T(lang, "settings.images", settingState(settings.GenerateImages)),
+				"settings_images"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				i18n.T(lang, "settings.post_language", ai.LanguageOrDefault(settings.Language).Title),
+				"settings_language"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				i18n.T(lang, "settings.smart", settingState(settings.SmartSelection)),
+				"settings_smart"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				i18n.T(lang, "settings.citation", i18n.T(lang, "settings.citation_"+settings.CitationMode())),
+				"settings_citation"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				i18n.T(lang, "settings.weekly_summary", settingState(!settings.WeeklySummaryOff)),
+				"settings_summary"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(
+				i18n.T(lang, "settings.preview", settingState(settings.PreviewBeforeCharge)),
+				"settings_preview"),
+		),
+	)
 }
 
-// handleAddGenerationsCommand - команда для добавления генераций пользователю
-func (b *Bot) handleAddGenerationsCommand(msg *tgbotapi.Message) {
-	args := strings.TrimSpace(msg.CommandArguments())
-	if args == "" {
-		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n"+
-			"/addgenerations пароль chatid количество_генераций\n\n"+
-			"Пример: /addgenerations admin123 123456789 10")
-		return
+// nextLanguage возвращает следующий по кругу язык постов
+func nextLanguage(code string) string {
+	languages := ai.Languages()
+	current := ai.LanguageOrDefault(code).Code
+	for i, language := range languages {
+		if language.Code == current {
+			return languages[(i+1)%len(languages)].Code
+		}
 	}
+	return ai.DefaultLanguage
+}
 
-	parts := strings.Fields(args)
-	if len(parts) != 3 {
-		b.sendMessage(msg.Chat.ID, "❌ Неверное количество аргументов. Формат:\n"+
-			"/addgenerations пароль chatid количество_генераций")
-		return
+// settingState отображение включенной или выключенной настройки
+func settingState(enabled bool) string {
+	if enabled {
+		return "✅"
 	}
+	return "❌"
+}
 
-	// Проверяем пароль
-	password := parts[0]
-	adminPassword := b.getAdminPassword()
-
-	if password != adminPassword {
-		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+// handleSettingsCallback переключает настройку и обновляет клавиатуру
+func (b *Bot) handleSettingsCallback(callback *tgbotapi.CallbackQuery) {
+	chatID := callback.Message.Chat.ID
+
+	var toggle func(*database.Settings)
+	switch strings.TrimPrefix(callback.Data, "settings_") {
+	case "images":
+		toggle = func(settings *database.Settings) { settings.GenerateImages = !settings.GenerateImages }
+	case "language":
+		toggle = func(settings *database.Settings) { settings.Language = nextLanguage(settings.Language) }
+	case "smart":
+		toggle = func(settings *database.Settings) { settings.SmartSelection = !settings.SmartSelection }
+	case "citation":
+		toggle = func(settings *database.Settings) { settings.Citation = nextCitation(settings.Citation) }
+	case "summary":
+		toggle = func(settings *database.Settings) { settings.WeeklySummaryOff = !settings.WeeklySummaryOff }
+	case "preview":
+		toggle = func(settings *database.Settings) { settings.PreviewBeforeCharge = !settings.PreviewBeforeCharge }
+	default:
 		return
 	}
 
-	// Парсим chatid
-	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	settings, err := b.db.UpdateSettings(chatID, toggle)
 	if err != nil {
-		b.sendMessage(msg.Chat.ID, "❌ Неверный chatid. Должен быть числом.")
-		return
+		log.Printf("[DB] ❌ Ошибка сохранения настроек %d: %v", chatID, err)
 	}
 
-	// Парсим количество генераций
-	count, err := strconv.Atoi(parts[2])
-	if err != nil {
-		b.sendMessage(msg.Chat.ID, "❌ Неверное количество генераций. Должно быть числом.")
-		return
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID, settingsKeyboard(settings))
+	if _, err := b.api.Request(edit); err != nil {
+		log.Printf("[ERROR] Ошибка обновления настроек в чате %d: %v", chatID, err)
 	}
+}
 
-	if count <= 0 {
-		b.sendMessage(msg.Chat.ID, "❌ Количество генераций должно быть больше 0.")
+// handleTranslate переводит пост, на который пользователь ответил командой
+func (b *Bot) handleTranslate(msg *tgbotapi.Message) {
+	lang := b.lang(msg.Chat.ID)
+
+	code := strings.TrimSpace(msg.CommandArguments())
+	if code == "" {
+		var codes []string
+		for _, language := range ai.Languages() {
+			codes = append(codes, fmt.Sprintf("%s - %s", language.Code, language.Title))
+		}
+		b.sendMessage(msg.Chat.ID, i18n.T(lang, "translate.usage", strings.Join(codes, "\n")))
 		return
 	}
 
-	if count > 1000 {
-		b.sendMessage(msg.Chat.ID, "❌ Слишком большое количество генераций. Максимум 1000.")
+	language, err := ai.ParseLanguage(code)
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, i18n.T(lang, "post_language.unknown", code, postLanguageCodes()))
 		return
 	}
 
-	// Добавляем генерации
-	err = b.db.AddGenerations(chatID, count)
-	if err != nil {
-		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Ошибка добавления генераций: %v", err))
+	text := replyPostText(msg.ReplyToMessage, b.self.ID)
+	if text == "" {
+		b.sendMessage(msg.Chat.ID, i18n.T(lang, "translate.no_reply"))
 		return
 	}
 
-	// Получаем обновленные данные пользователя
-	user := b.db.GetUser(chatID)
+	b.safeGo("translate", msg.Chat.ID, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), b.config.GenerationTimeout)
+		defer cancel()
+		ctx = ai.WithAuditUser(ctx, msg.Chat.ID)
+		ctx = b.withUserTier(ctx, msg.Chat.ID)
 
-	// Отправляем подтверждение админу
-	b.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ Пользователю %d успешно добавлено %d генераций.\n"+
-		"Теперь у него доступно: %d генераций", chatID, count, user.AvailableGenerations))
+		translation, err := b.gptClient.TranslatePost(ctx, text, language)
+		if err != nil {
+			log.Printf("[TRANSLATE] ❌ Ошибка перевода для %d: %v", msg.Chat.ID, err)
+			reportAIError("translate", msg.Chat.ID, err)
+			if errors.Is(err, ai.ErrCircuitOpen) {
+				b.sendMessage(msg.Chat.ID, i18n.T(lang, "ai.circuit_open"))
+				return
+			}
+			b.sendMessage(msg.Chat.ID, i18n.T(lang, "translate.failed", aiFailureReason(lang, err)))
+			return
+		}
 
-	// Отправляем уведомление пользователю
-	b.sendMessage(chatID, fmt.Sprintf("🎉 Администратор добавил вам %d генераций!\n\n"+
-		"✨ Теперь доступно: %d генераций\n"+
-		"📊 Всего использовано: %d\n\n"+
-		"Спасибо за использование нашего бота! 🚀",
-		count, user.AvailableGenerations, user.TotalGenerations))
+		b.sendRichText(msg.Chat.ID, richtext.Markers(translation), nil)
+	})
 }
 
-func (b *Bot) handlePaymentsCommand(msg *tgbotapi.Message) {
+// handleHeadlines предлагает варианты заголовка для текста из аргументов команды
+// или из сообщения, на которое пользователь ответил
+func (b *Bot) handleHeadlines(msg *tgbotapi.Message) {
 	userID := msg.Chat.ID
+	lang := b.lang(userID)
 
-	if b.yooMoney == nil {
-		b.sendMessage(userID, "❌ Платежная система временно недоступна.")
+	text := strings.TrimSpace(msg.CommandArguments())
+	if text == "" && msg.ReplyToMessage != nil {
+		text = replyPostText(msg.ReplyToMessage, b.self.ID)
+		if text == "" {
+			text = entitiesToMarkdown(msg.ReplyToMessage.Text, msg.ReplyToMessage.Entities)
+		}
+		if text == "" {
+			text = entitiesToMarkdown(msg.ReplyToMessage.Caption, msg.ReplyToMessage.CaptionEntities)
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		b.sendMessage(userID, i18n.T(lang, "headlines.usage", len(ai.HeadlineStyles)))
+		return
+	}
+	if length := utf8.RuneCountInString(text); length > maxRewriteLength {
+		b.sendMessage(userID, i18n.T(lang, "headlines.too_long", length, maxRewriteLength))
 		return
 	}
 
-	text := `💳 Управление платежами
-
-Здесь вы можете:
-• Проверить статус своих платежей
-• Получить помощь по оплате
-• Отменить ожидающие платежи
-
-Для покупки генераций используйте команду /buy
-
-📞 Если у вас возникли проблемы с оплатой, свяжитесь с нами (/feedback).`
-
-	b.sendMessage(userID, text)
-}
+	cost := b.config.HeadlinesCost
+	if cost > 0 && b.db.GetUser(userID).AvailableGenerations <= 0 {
+		b.sendMessage(userID, i18n.T(lang, "generations.exhausted_short"))
+		return
+	}
+	if ai.CircuitOpen() {
+		b.sendMessage(userID, i18n.T(lang, "ai.circuit_open"))
+		return
+	}
 
-func (b *Bot) handleFeedbackCommand(msg *tgbotapi.Message) {
-	userID := msg.Chat.ID
+	b.safeGo("headlines", userID, func() {
+		progressMsg := b.sendMessage(userID, i18n.T(lang, "headlines.progress"))
 
-	b.db.SetPendingFeedback(userID, true)
+		ctx, cancel := context.WithTimeout(context.Background(), b.config.GenerationTimeout)
+		defer cancel()
+		ctx = ai.WithLanguage(ctx, ai.LanguageOrDefault(b.db.GetSettings(userID).Language))
+		ctx = ai.WithAuditUser(ctx, userID)
+		ctx = b.withUserTier(ctx, userID)
 
-	text := `📝 Оставьте отзыв о работе бота
+		headlines, err := b.gptClient.GenerateHeadlines(ctx, text)
+		if ctx.Err() != nil {
+			log.Printf("[HEADLINES] ⏱ Превышен лимит времени для %d", userID)
+			b.editMessage(userID, progressMsg.MessageID, i18n.T(lang, "ai.deadline_exceeded"))
+			return
+		}
+		if err != nil {
+			log.Printf("[HEADLINES] ❌ Ошибка для %d: %v", userID, err)
+			reportAIError("headlines", userID, err)
+			if errors.Is(err, ai.ErrCircuitOpen) {
+				b.editMessage(userID, progressMsg.MessageID, i18n.T(lang, "ai.circuit_open"))
+				return
+			}
+			b.editMessage(userID, progressMsg.MessageID, i18n.T(lang, "headlines.failed", aiFailureReason(lang, err)))
+			return
+		}
 
-Пожалуйста, напишите ваш отзыв, предложения или замечания по работе бота.
+		if cost > 0 {
+			if success, err := b.db.UseGenerationFraction(userID, cost); err != nil || !success {
+				log.Printf("[HEADLINES] ❌ Ошибка списания генерации: %v", err)
+				b.editMessage(userID, progressMsg.MessageID, i18n.T(lang, "generations.charge_failed"))
+				return
+			}
+		}
+		b.deleteMessage(userID, progressMsg.MessageID)
 
-Ваш отзыв поможет нам стать лучше!
+		var result strings.Builder
+		result.WriteString(i18n.T(lang, "headlines.result"))
+		for i, headline := range headlines {
+			fmt.Fprintf(&result, "\n%d. _%s_\n`%s`\n", i+1, headline.Style, headline.Text)
+		}
+		b.sendMessageWithMarkdown(userID, result.String())
 
-Если передумали, используйте команду /cancel`
+		log.Printf("[HEADLINES] ✅ Отправлено %d вариантов заголовка пользователю %d", len(headlines), userID)
+	})
+}
 
-	b.sendMessage(userID, text)
+// replyPostText возвращает текст поста из сообщения бота, на которое ответил пользователь,
+// восстанавливая разметку Markdown. Для чужих сообщений возвращает пустую строку.
+func replyPostText(reply *tgbotapi.Message, botID int64) string {
+	if reply == nil || reply.From == nil || reply.From.ID != botID {
+		return ""
+	}
+	if reply.Text != "" {
+		return entitiesToMarkdown(reply.Text, reply.Entities)
+	}
+	return entitiesToMarkdown(reply.Caption, reply.CaptionEntities)
 }
 
-func (b *Bot) handleCancelCommand(msg *tgbotapi.Message) {
-	userID := msg.Chat.ID
+// entitiesToMarkdown возвращает разметку *жирного* и _курсива_, которую Telegram
+// передает отдельно от текста. Смещения сущностей указаны в UTF-16.
+func entitiesToMarkdown(text string, entities []tgbotapi.MessageEntity) string {
+	markers := map[string]string{"bold": "*", "italic": "_"}
 
-	if !b.db.IsUserPendingFeedback(userID) {
-		b.sendMessage(userID, "❌ У вас нет активного запроса на отзыв.")
-		return
+	units := utf16.Encode([]rune(text))
+	inserts := make(map[int]string)
+	for _, entity := range entities {
+		marker, ok := markers[entity.Type]
+		if !ok || entity.Offset < 0 || entity.Offset+entity.Length > len(units) {
+			continue
+		}
+		inserts[entity.Offset] += marker
+		inserts[entity.Offset+entity.Length] = marker + inserts[entity.Offset+entity.Length]
+	}
+	if len(inserts) == 0 {
+		return text
 	}
 
-	b.db.SetPendingFeedback(userID, false)
-	b.db.ResetGenerationsCount(userID)
-
-	b.sendMessage(userID, "✅ Отправка отзыва отменена.")
+	var result strings.Builder
+	start := 0
+	for i := 0; i <= len(units); i++ {
+		marker, ok := inserts[i]
+		if !ok {
+			continue
+		}
+		result.WriteString(string(utf16.Decode(units[start:i])))
+		result.WriteString(marker)
+		start = i
+	}
+	result.WriteString(string(utf16.Decode(units[start:])))
+	return result.String()
 }
 
-func (b *Bot) handleFeedbackText(msg *tgbotapi.Message) {
-	userID := msg.Chat.ID
-	feedbackText := msg.Text
-
-	if !b.db.IsUserPendingFeedback(userID) {
+// handleSetModel переключает модель YandexGPT по умолчанию
+func (b *Bot) handleSetModel(msg *tgbotapi.Message) {
+	parts := strings.Fields(msg.CommandArguments())
+	if len(parts) != 2 {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/setmodel пароль lite|pro")
 		return
 	}
 
-	username := "Без имени"
-	if msg.From != nil && msg.From.UserName != "" {
-		username = "@" + msg.From.UserName
-	} else if msg.From != nil && msg.From.FirstName != "" {
-		username = msg.From.FirstName
-		if msg.From.LastName != "" {
-			username += " " + msg.From.LastName
-		}
+	if parts[0] != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
 	}
 
-	adminMessage := fmt.Sprintf(
-		"📨 *НОВЫЙ ОТЗЫВ*\n\n"+
-			"👤 Пользователь: %s\n"+
-			"🆔 ID: %d\n"+
-			"📅 Дата: %s\n\n"+
-			"💬 Отзыв:\n%s",
-		username,
-		userID,
-		time.Now().Format("02.01.2006 15:04"),
-		feedbackText)
-
-	b.sendMessageWithMarkdown(b.adminChatID, adminMessage)
+	selector, ok := b.gptClient.(ai.TierSelector)
+	if !ok {
+		b.sendMessage(msg.Chat.ID, "❌ Текущий AI провайдер не поддерживает выбор модели")
+		return
+	}
 
-	b.db.SetPendingFeedback(userID, false)
-	b.db.ResetGenerationsCount(userID)
+	tier, err := ai.ParseModelTier(parts[1])
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ %v", err))
+		return
+	}
 
-	b.sendMessage(userID, "✅ Спасибо за ваш отзыв! Это очень ценно для нас! 🙏")
+	previous := selector.DefaultTier()
+	selector.SetDefaultTier(tier)
+	log.Printf("[COMMAND] Модель по умолчанию изменена: %s → %s", previous, tier)
+	b.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ Модель по умолчанию: %s (была %s)", tier, previous))
 }
 
-func (b *Bot) handleCallback(callback *tgbotapi.CallbackQuery) {
-	_, _ = b.api.Request(tgbotapi.NewCallback(callback.ID, ""))
-
-	data := callback.Data
-
-	if strings.HasPrefix(data, "buy_") {
-		b.handlePurchase(callback.Message.Chat.ID, data)
-	} else if strings.HasPrefix(data, "rate_") {
-		b.handleRating(callback)
-	} else if strings.HasPrefix(data, "check_") {
-		b.handleCheckPayment(callback)
-	} else if strings.HasPrefix(data, "cancel_") {
-		b.handleCancelPayment(callback)
+// handleSourceStatus показывает администратору состояние и веса источников новостей
+func (b *Bot) handleSourceStatus(msg *tgbotapi.Message) {
+	password := strings.TrimSpace(msg.CommandArguments())
+	if password == "" {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/sourcestatus пароль")
+		return
 	}
-}
-
-func (b *Bot) handleRating(callback *tgbotapi.CallbackQuery) {
-	userID := callback.Message.Chat.ID
-	data := callback.Data
 
-	parts := strings.SplitN(data, "_", 3)
-	if len(parts) != 3 {
+	if password != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
 		return
 	}
 
-	rating, err := strconv.Atoi(parts[1])
-	if err != nil || rating < 1 || rating > 5 {
+	breakerStatus := ai.Breaker()
+	text := fmt.Sprintf("🤖 AI: %s, ошибок подряд %d, отключений %d, отклонено запросов %d\n\n",
+		breakerStatus.State, breakerStatus.ConsecutiveFailures, breakerStatus.Opens, breakerStatus.Rejected)
+
+	statuses := b.newsAggregator.SourceStatuses()
+	if len(statuses) == 0 {
+		b.sendMessage(msg.Chat.ID, text+"📡 Источники еще не опрашивались")
 		return
 	}
 
-	topic := parts[2]
+	text += "📡 СОСТОЯНИЕ ИСТОЧНИКОВ\n\n"
+	for _, status := range statuses {
+		icon := "✅"
+		if status.Quarantined() {
+			icon = "⛔"
+		} else if status.ConsecutiveFailures > 0 {
+			icon = "⚠️"
+		}
 
-	username := "Без имени"
-	if callback.From != nil && callback.From.UserName != "" {
-		username = "@" + callback.From.UserName
-	} else if callback.From != nil && callback.From.FirstName != "" {
-		username = callback.From.FirstName
-		if callback.From.LastName != "" {
-			username += " " + callback.From.LastName
+		text += fmt.Sprintf("%s %s (вес %.2f)\n", icon, status.Name, b.newsAggregator.SourceWeight(status.Name))
+		if !status.LastSuccessAt.IsZero() {
+			text += fmt.Sprintf("   Успешно: %s\n", status.LastSuccessAt.Format("02.01 15:04"))
+		}
+		if status.ConsecutiveFailures > 0 {
+			text += fmt.Sprintf("   Ошибок подряд: %d, последняя: %s\n", status.ConsecutiveFailures, status.LastError)
+		}
+		if status.Quarantined() {
+			text += fmt.Sprintf("   Карантин до %s\n", status.QuarantinedUntil.Format("02.01 15:04"))
 		}
 	}
 
-	adminMessage := fmt.Sprintf(
-		"⭐️ *НОВАЯ ОЦЕНКА*\n\n"+
-			"👤 Пользователь: %s\n"+
-			"🆔 ID: %d\n"+
-			"🎯 Тема генерации: %s\n"+
-			"📅 Дата: %s\n\n"+
-			"⭐️ Оценка: %d/5",
-		username,
-		userID,
-		topic,
-		time.Now().Format("02.01.2006 15:04"),
-		rating)
-
-	b.sendMessageWithMarkdown(b.adminChatID, adminMessage)
-
-	b.editMessage(callback.Message.Chat.ID, callback.Message.MessageID,
-		"✅ Спасибо за вашу оценку! Ваше мнение важно для нас! ⭐️")
-
-	b.sendMessage(userID, fmt.Sprintf("✅ Спасибо за оценку %d/5! Ваше мнение помогает нам становиться лучше! 🙌", rating))
+	b.sendMessage(msg.Chat.ID, text)
 }
 
 func (b *Bot) handlePurchase(chatID int64, packageType string) {
+	lang := b.lang(chatID)
+
 	if b.yooMoney == nil {
-		b.sendMessage(chatID, "❌ Платежная система временно недоступна. Попробуйте позже.")
+		b.sendMessage(chatID, i18n.T(lang, "purchase.unavailable"))
 		return
 	}
 
@@ -1246,7 +2889,7 @@ func (b *Bot) handlePurchase(chatID int64, packageType string) {
 		count = 100
 		description = "Покупка 100 генераций в AI Content Generator"
 	default:
-		b.sendMessage(chatID, "❌ Неизвестный тип пакета")
+		b.sendMessage(chatID, i18n.T(lang, "purchase.unknown_package"))
 		return
 	}
 
@@ -1260,9 +2903,9 @@ func (b *Bot) handlePurchase(chatID int64, packageType string) {
 
 		// Проверяем, является ли ошибка из-за отсутствия настроек платежной системы
 		if strings.Contains(err.Error(), "не установлены") {
-			b.sendMessage(chatID, "❌ Платежная система не настроена. Обратитесь к нам с помощью команды (/feedback).")
+			b.sendMessage(chatID, i18n.T(lang, "purchase.not_configured"))
 		} else {
-			b.sendMessage(chatID, fmt.Sprintf("❌ Ошибка при создании платежа: %v", err))
+			b.sendMessage(chatID, i18n.T(lang, "purchase.create_failed", err))
 		}
 		return
 	}
@@ -1276,38 +2919,31 @@ func (b *Bot) handlePurchase(chatID int64, packageType string) {
 		Status:      "pending",
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
+		NextCheck:   time.Now().Add(paymentFirstCheck),
 	}
 
 	if err := b.db.AddPendingPurchase(purchase); err != nil {
 		log.Printf("[PAYMENT] ❌ Ошибка сохранения платежа в БД: %v", err)
-		b.sendMessage(chatID, "❌ Ошибка при сохранении платежа в базу данных.")
+		b.sendMessage(chatID, i18n.T(lang, "purchase.save_failed"))
 		return
 	}
+	b.events.Emit(events.Event{Type: events.PaymentCreated, UserID: chatID, PaymentID: paymentResp.ID,
+		Package: packageType, Amount: price})
 
 	// Отправляем пользователю ссылку для оплаты
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonURL("💳 Оплатить", paymentResp.Confirmation.ConfirmationURL),
+			tgbotapi.NewInlineKeyboardButtonURL(i18n.T(lang, "purchase.pay_button"), paymentResp.Confirmation.ConfirmationURL),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔄 Проверить оплату", fmt.Sprintf("check_%s", paymentResp.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "purchase.check_button"), fmt.Sprintf("check_%s", paymentResp.ID)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❌ Отменить", fmt.Sprintf("cancel_%s", paymentResp.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "purchase.cancel_button"), fmt.Sprintf("cancel_%s", paymentResp.ID)),
 		),
 	)
 
-	msg := fmt.Sprintf(
-		"💎 *Покупка %d генераций*\n\n"+
-			"💰 Сумма: *%d руб.*\n"+
-			"🎯 Количество: *%d генераций*\n\n"+
-			"📋 *Для оплаты:*\n"+
-			"1. Нажмите кнопку '💳 Оплатить'\n"+
-			"2. Оплатите через ЮKassa\n"+
-			"3. После оплаты нажмите '🔄 Проверить оплату'\n\n"+
-			"⌛️ *Ссылка действительна 30 минут*\n"+
-			"🆔 *ID платежа:* `%s`",
-		count, price, count, paymentResp.ID)
+	msg := i18n.T(lang, "purchase.text", count, price, count, paymentResp.ID)
 
 	message := tgbotapi.NewMessage(chatID, msg)
 	message.ParseMode = "Markdown"
@@ -1317,96 +2953,61 @@ func (b *Bot) handlePurchase(chatID int64, packageType string) {
 	if _, err := b.api.Send(message); err != nil {
 		log.Printf("[PAYMENT] ❌ Ошибка отправки сообщения: %v", err)
 	}
+}
 
-	// Запускаем проверку статуса платежа в фоне
-	go b.checkPaymentStatus(chatID, paymentResp.ID)
+// reportPaymentCreditError отправляет в сервис отчетов сбой зачисления оплаченных генераций
+func reportPaymentCreditError(userID int64, paymentID string, err error) {
+	reporting.Capture(reporting.Event{
+		Level:     reporting.LevelError,
+		Operation: "payment_credit",
+		Message:   err.Error(),
+		UserID:    userID,
+		Tags:      map[string]string{"payment_id": paymentID},
+	})
 }
 
 // Обработчик проверки платежа
 func (b *Bot) handleCheckPayment(callback *tgbotapi.CallbackQuery) {
 	paymentID := strings.TrimPrefix(callback.Data, "check_")
 	userID := callback.Message.Chat.ID
+	lang := b.lang(userID)
 
 	// Проверяем статус платежа
 	paymentResp, err := b.yooMoney.CheckPayment(paymentID)
 	if err != nil {
 		log.Printf("[PAYMENT] ❌ Ошибка проверки платежа %s: %v", paymentID, err)
-		b.sendMessage(userID, "❌ Ошибка при проверке платежа. Попробуйте позже.")
+		b.sendMessage(userID, i18n.T(lang, "payment.check_failed"))
 		return
 	}
 
 	switch paymentResp.Status {
 	case "succeeded":
-		// Обновляем статус в базе
-		b.db.UpdatePurchaseStatus(paymentID, "succeeded")
-
-		// Получаем данные из метаданных
-		packageType := paymentResp.Metadata["package_type"]
-		count := paymentResp.Metadata["count"]
-
-		var packageCode string
-		var generationCount int
-
-		// Извлекаем значения из метаданных
-		if pkg, ok := packageType.(string); ok {
-			packageCode = strings.TrimPrefix(pkg, "buy_")
-		} else {
-			packageCode = "10" // fallback
-		}
-
-		if cnt, ok := count.(float64); ok {
-			generationCount = int(cnt)
-		} else if cnt, ok := count.(int); ok {
-			generationCount = cnt
-		} else {
-			generationCount = 10 // fallback
-		}
-
-		// Определяем цену по пакету
-		var price int
-		switch packageCode {
-		case "10":
-			price = 99
-		case "25":
-			price = 199
-		case "100":
-			price = 499
-		default:
-			price = 99
-		}
-
-		// Добавляем покупку в базу
-		if err := b.db.AddPurchase(userID, packageCode, price); err != nil {
-			log.Printf("[PAYMENT] ❌ Ошибка зачисления генераций: %v", err)
-			b.sendMessage(userID, "❌ Ошибка при зачислении генераций. Обратитесь к нам с помощью команды /feedback.")
+		purchase, credited, err := b.completePayment(paymentID)
+		if err != nil {
+			b.sendMessage(userID, i18n.T(lang, "payment.credit_failed"))
 			return
 		}
 
-		user := b.db.GetUser(userID)
-
-		// Редактируем сообщение
-		b.editMessage(callback.Message.Chat.ID, callback.Message.MessageID,
-			fmt.Sprintf("✅ *Оплата успешна!*\n\n"+
-				"✨ Добавлено генераций: *%d*\n"+
-				"💰 Сумма: *%d руб.*\n"+
-				"🎯 Теперь доступно: *%d*\n\n"+
-				"Теперь вы можете использовать /generate для создания постов!",
-				generationCount, price, user.AvailableGenerations))
+		// Платеж уже зачислила фоновая проверка или предыдущее нажатие кнопки
+		if credited {
+			user := b.db.GetUser(purchase.UserID)
+			b.editMessage(callback.Message.Chat.ID, callback.Message.MessageID,
+				i18n.T(lang, "payment.succeeded_details", packageGenerations(purchase.PackageType), purchase.Price, user.AvailableGenerations))
+		}
 
 		// Отправляем подтверждение
-		b.sendMessage(userID, "🎉 Оплата прошла успешно! Генерации зачислены на ваш счет. (если генерации не начислились отпраьте сообщение в /feedback и мы начислим их как можно скорее (желательно оставьте свой телеграмм user name для связи))")
+		b.sendMessage(userID, i18n.T(lang, "payment.succeeded"))
 
 	case "pending":
-		b.sendMessage(userID, "⏳ Платеж еще не прошел. Попробуйте проверить позже.")
+		b.sendMessage(userID, i18n.T(lang, "payment.pending"))
 
 	case "canceled":
 		b.db.UpdatePurchaseStatus(paymentID, "canceled")
-		b.editMessage(callback.Message.Chat.ID, callback.Message.MessageID,
-			"❌ Платеж отменен. Если у вас есть вопросы, обратитесь за помощью (команда /feedback).")
+		b.editMessage(callback.Message.Chat.ID, callback.Message.MessageID, i18n.T(lang, "payment.canceled_details"))
 
 	default:
 		log.Printf("[PAYMENT] Неизвестный статус платежа %s: %s", paymentID, paymentResp.Status)
-		b.sendMessage(userID, "⚠️ Неизвестный статус платежа: "+paymentResp.Status)
+		b.sendMessage(userID, i18n.T(lang, "payment.unknown_status", paymentResp.Status))
 	}
 }
 
@@ -1415,104 +3016,31 @@ func (b *Bot) handleCancelPayment(callback *tgbotapi.CallbackQuery) {
 	paymentID := strings.TrimPrefix(callback.Data, "cancel_")
 	userID := callback.Message.Chat.ID
 
-	// Обновляем статус в базе
-	b.db.UpdatePurchaseStatus(paymentID, "canceled")
+	// Платеж остается на проверке: если его все же оплатят, генерации зачислятся
+	b.db.CancelPendingPurchase(paymentID)
 
 	// Редактируем сообщение
-	b.editMessage(callback.Message.Chat.ID, callback.Message.MessageID,
-		"❌ Платеж отменен. Вы можете начать заново с помощью команды /buy")
-
-	b.sendMessage(userID, "Платеж отменен. Если вам нужна помощь, используйте /help")
-}
-
-// Периодическая проверка статуса платежей
-func (b *Bot) checkPaymentStatus(chatID int64, paymentID string) {
-	// Ждем 30 секунд перед первой проверкой
-	time.Sleep(30 * time.Second)
-
-	for i := 0; i < 10; i++ { // Проверяем 10 раз с интервалом
-		paymentResp, err := b.yooMoney.CheckPayment(paymentID)
-		if err != nil {
-			log.Printf("[PAYMENT] ❌ Ошибка проверки статуса платежа %s: %v", paymentID, err)
-			time.Sleep(30 * time.Second)
-			continue
-		}
-
-		if paymentResp.Status == "succeeded" {
-			// Получаем данные из метаданных
-			packageType := paymentResp.Metadata["package_type"]
-			count := paymentResp.Metadata["count"]
-
-			var packageCode string
-			var generationCount int
+	b.editMessage(callback.Message.Chat.ID, callback.Message.MessageID, b.t(userID, "payment.cancel_details"))
 
-			// Извлекаем значения из метаданных
-			if pkg, ok := packageType.(string); ok {
-				packageCode = strings.TrimPrefix(pkg, "buy_")
-			} else {
-				packageCode = "10" // fallback
-			}
-
-			if cnt, ok := count.(float64); ok {
-				generationCount = int(cnt)
-			} else if cnt, ok := count.(int); ok {
-				generationCount = cnt
-			} else {
-				generationCount = 10 // fallback
-			}
-
-			// Определяем цену по пакету
-			var price int
-			switch packageCode {
-			case "10":
-				price = 99
-			case "25":
-				price = 199
-			case "100":
-				price = 499
-			default:
-				price = 99
-			}
-
-			// Автоматически зачисляем генерации
-			if err := b.db.AddPurchase(chatID, packageCode, price); err == nil {
-				b.sendMessage(chatID,
-					fmt.Sprintf("✅ Платеж прошел успешно! Зачислено %d генераций.", generationCount))
-				b.db.UpdatePurchaseStatus(paymentID, "succeeded")
-			} else {
-				log.Printf("[PAYMENT] ❌ Ошибка автоматического зачисления генераций: %v", err)
-			}
-			return
-		} else if paymentResp.Status == "canceled" {
-			b.db.UpdatePurchaseStatus(paymentID, "canceled")
-			return
-		}
-
-		// Ждем 30 секунд перед следующей проверкой
-		time.Sleep(30 * time.Second)
-	}
-
-	// Если платеж все еще в ожидании, напоминаем
-	b.sendMessage(chatID,
-		"⏳ Ваш платеж все еще в ожидании. Вы можете проверить статус вручную, нажав кнопку '🔄 Проверить оплату' в сообщении о покупке.")
+	b.sendMessage(userID, b.t(userID, "payment.canceled"))
 }
 
-func (b *Bot) createBuyMenu() tgbotapi.InlineKeyboardMarkup {
+func (b *Bot) createBuyMenu(lang string) tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("10 генераций - 99р", "buy_10"),
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "buy.button", 10, 99), "buy_10"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("25 генераций - 199р", "buy_25"),
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "buy.button", 25, 199), "buy_25"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("100 генераций - 499р", "buy_100"),
+			tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "buy.button", 100, 499), "buy_100"),
 		),
 	)
 }
 
 func (b *Bot) sendRatingRequest(chatID int64, topic string) {
-	text := "⭐️ Оцените качество генерации:"
+	text := b.t(chatID, "rating.request")
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -1530,15 +3058,7 @@ func (b *Bot) sendRatingRequest(chatID int64, topic string) {
 }
 
 func (b *Bot) sendFeedbackReminder(chatID int64) {
-	text := `💬 *Небольшая просьба!*
-
-Вы уже использовали несколько генераций. Пожалуйста, помогите нам стать лучше!
-
-Если у вас есть минутка, оставьте отзыв о работе бота командой /feedback
-
-Ваше мнение очень важно для нас! 🙏`
-
-	b.sendMessageWithMarkdown(chatID, text)
+	b.sendMessageWithMarkdown(chatID, b.t(chatID, "feedback.reminder"))
 }
 
 // Функция для отправки сообщений с Markdown
@@ -1556,6 +3076,35 @@ func (b *Bot) sendMessageWithMarkdown(chatID int64, text string) tgbotapi.Messag
 	return message
 }
 
+// sendRichText отправляет текст с разметкой сущностями и кнопками keyboard (nil — без
+// кнопок). Если Telegram не принял сущности, отправляет текст без разметки.
+func (b *Bot) sendRichText(chatID int64, text richtext.Text, keyboard *tgbotapi.InlineKeyboardMarkup) tgbotapi.Message {
+	msg := tgbotapi.NewMessage(chatID, text.String())
+	msg.Entities = text.Entities()
+	msg.DisableWebPagePreview = true
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+
+	message, err := b.api.Send(msg)
+	if err != nil && msg.Entities != nil {
+		log.Printf("[ERROR] Ошибка отправки сообщения с разметкой: %v", err)
+		msg.Entities = nil
+		message, err = b.api.Send(msg)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Ошибка отправки сообщения в чат %d: %v", chatID, err)
+		return tgbotapi.Message{}
+	}
+	log.Printf("[MESSAGE] Отправлено сообщение с разметкой в чат %d, ID: %d", chatID, message.MessageID)
+	return message
+}
+
+// richT сообщение key на языке lang, разметка которого передается сущностями, а не Markdown
+func richT(lang, key string, args ...any) richtext.Text {
+	return richtext.Sprintf(i18n.T(lang, key), args...)
+}
+
 func (b *Bot) sendMessage(chatID int64, text string) tgbotapi.Message {
 	msg := tgbotapi.NewMessage(chatID, text)
 	msg.ParseMode = ""
@@ -1590,11 +3139,52 @@ func (b *Bot) editMessage(chatID int64, messageID int, text string) {
 	msg.DisableWebPagePreview = true
 
 	_, err := b.api.Send(msg)
-	if err != nil {
+	if err != nil && !isMessageNotModified(err) {
 		log.Printf("[ERROR] Ошибка редактирования сообщения %d в чате %d: %v", messageID, chatID, err)
 	}
 }
 
+// streamProgress показывает в сообщении прогресса накапливающийся текст поста.
+// Сообщение редактируется не чаще streamEditInterval, чтобы не упираться в лимиты Telegram.
+// Возвращаемый канал закрывается, когда partial закрыт и последнее изменение отправлено.
+func (b *Bot) streamProgress(progress *progressMessage, header string, partial <-chan string) <-chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(streamEditInterval)
+		defer ticker.Stop()
+
+		latest, shown := "", ""
+		for {
+			select {
+			case text, ok := <-partial:
+				if !ok {
+					return
+				}
+				latest = text
+			case <-ticker.C:
+				if latest != shown {
+					progress.update(header + "\n\n" + tailRunes(latest, streamPreviewLength) + " ▌")
+					shown = latest
+				}
+			}
+		}
+	}()
+
+	return done
+}
+
+// tailRunes возвращает последние limit символов текста
+func tailRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return "…" + string(runes[len(runes)-limit:])
+}
+
 func (b *Bot) deleteMessage(chatID int64, messageID int) {
 	msg := tgbotapi.NewDeleteMessage(chatID, messageID)
 	_, err := b.api.Send(msg)
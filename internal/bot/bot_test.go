@@ -0,0 +1,1222 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/generator"
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/news"
+	"AIGenerator/internal/payment"
+	"AIGenerator/internal/reporting"
+	"AIGenerator/internal/selftest"
+	"AIGenerator/internal/testutil"
+	"AIGenerator/internal/texts"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// testAdminChatID чат администратора тестового бота
+	testAdminChatID = 999
+	// testAdminPassword пароль админских команд тестового бота
+	testAdminPassword = "secret"
+)
+
+// newTestBot создает бота поверх FakeTelegram и пустой базы во временном каталоге:
+// файлы базы пишутся относительно рабочего каталога. Новости и модели не подключены.
+func newTestBot(t *testing.T, configure ...func(*Config)) (*Bot, *testutil.FakeTelegram) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	return reopenTestBot(t, configure...)
+}
+
+// reopenTestBot создает бота над базой в текущем каталоге, как после перезапуска
+func reopenTestBot(t *testing.T, configure ...func(*Config)) (*Bot, *testutil.FakeTelegram) {
+	t.Helper()
+	config := DefaultConfig()
+	config.AdminChatID = testAdminChatID
+	config.AdminPassword = testAdminPassword
+	config.ReportHour = -1
+	config.GenerationWorkers = 1
+	config.ShutdownTimeout = 5 * time.Second
+	for _, fn := range configure {
+		fn(&config)
+	}
+
+	db := database.NewDatabase(database.Config{File: "users.json", StatisticsPassword: testAdminPassword, FreeTrialGenerations: 3})
+	if err := db.Load(); err != nil {
+		t.Fatal(err)
+	}
+	fake := testutil.NewFakeTelegram(100)
+	aggregator := news.NewNewsAggregator(news.Config{CacheTTL: time.Minute})
+	return NewWithSender(config, fake, tgbotapi.User{ID: 1000, UserName: "test_bot"}, aggregator, nil, nil, db, nil), fake
+}
+
+// fakeGPT модель-заглушка: пишет пост post, остальные методы ai.TextGenerator
+// в тестах бота не вызываются
+type fakeGPT struct {
+	ai.TextGenerator
+	check   ai.TopicCheck
+	post    ai.Post
+	postErr error
+	refusal bool
+	// written сколько постов написано
+	written atomic.Int32
+}
+
+func newFakeGPT() *fakeGPT {
+	return &fakeGPT{
+		check: ai.TopicCheck{Allowed: true},
+		post:  ai.Post{Title: "Центробанк сохранил ставку", Body: "Банк России оставил ставку на уровне 21%.", Structured: true},
+	}
+}
+
+func (f *fakeGPT) CheckTopic(ctx context.Context, keywords string) ai.TopicCheck {
+	return f.check
+}
+
+func (f *fakeGPT) RerankArticles(ctx context.Context, query string, candidates []ai.ArticleInfo) (ai.Rerank, error) {
+	return ai.Rerank{}, errors.New("не используется")
+}
+
+func (f *fakeGPT) GeneratePostStream(ctx context.Context, keywords string, article ai.ArticleInfo, partial chan<- string) (ai.Post, error) {
+	if partial != nil {
+		close(partial)
+	}
+	f.written.Add(1)
+	return f.post, f.postErr
+}
+
+func (f *fakeGPT) GeneratePostFromURLStream(ctx context.Context, title, content string, partial chan<- string) (ai.Post, error) {
+	return f.GeneratePostStream(ctx, title, ai.ArticleInfo{}, partial)
+}
+
+func (f *fakeGPT) IsRefusal(ctx context.Context, text string) bool {
+	return f.refusal
+}
+
+// fakeNews поиск новостей, возвращающий заданные статьи
+type fakeNews struct {
+	articles []news.Article
+	err      error
+}
+
+func (f *fakeNews) FindRelevantArticles(ctx context.Context, keywords string, maxArticles int, opts news.SearchOptions) ([]news.Article, news.SearchDiagnostics, error) {
+	return f.articles, news.SearchDiagnostics{Fetched: len(f.articles), TopScore: 50}, f.err
+}
+
+// useGenerator подключает к боту генерацию с моделью gpt и поиском newsSearch
+func useGenerator(b *Bot, gpt *fakeGPT, newsSearch generator.NewsSearcher) {
+	b.gptClient = gpt
+	b.generator = generator.New(newsSearch, generator.PageFetcherFunc(b.fetchPage), gpt, b.db)
+}
+
+// runBot запускает бота и возвращает функцию, которая останавливает его и ждет,
+// пока Start вернется
+func runBot(t *testing.T, b *Bot) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		b.Start(ctx)
+	}()
+
+	var stopped bool
+	stop = func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("Start не вернулся после отмены контекста")
+		}
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// waitFor ждет, пока condition станет истинным, не дольше двух секунд
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("не дождались: %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// sentText есть ли среди сообщений и правок в чат chatID текст, содержащий want
+func sentText(fake *testutil.FakeTelegram, chatID int64, want string) bool {
+	for _, sent := range fake.SentTo(chatID) {
+		if strings.Contains(sent.Text, want) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandUpdate обновление с командой или текстом text от пользователя chatID
+func commandUpdate(chatID int64, text string) tgbotapi.Update {
+	msg := &tgbotapi.Message{
+		Chat: &tgbotapi.Chat{ID: chatID},
+		From: &tgbotapi.User{ID: chatID, LanguageCode: "ru"},
+		Text: text,
+		Date: int(time.Now().Unix()),
+	}
+	if strings.HasPrefix(text, "/") {
+		command := strings.IndexByte(text+" ", ' ')
+		msg.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: command}}
+	}
+	return tgbotapi.Update{Message: msg}
+}
+
+// callbackUpdate обновление с нажатием кнопки data пользователем chatID
+func callbackUpdate(chatID int64, messageID int, data string) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:      "cb",
+		From:    &tgbotapi.User{ID: chatID},
+		Message: &tgbotapi.Message{MessageID: messageID, Chat: &tgbotapi.Chat{ID: chatID}},
+		Data:    data,
+	}}
+}
+
+func TestShutdownDrainsSlowHandler(t *testing.T) {
+	b, _ := newTestBot(t)
+	stop := runBot(t, b)
+
+	started, finished := make(chan struct{}), make(chan struct{})
+	b.safeGo("slow", 1, func() {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		b.db.AddGeneration(1, "медленная тема", "Источник", "req-1")
+		close(finished)
+	})
+	<-started
+
+	stop()
+	select {
+	case <-finished:
+	default:
+		t.Fatal("Start вернулся раньше, чем обработчик завершился")
+	}
+
+	// Запись обработчика сохранена на диск при завершении
+	reloaded := database.NewDatabase(database.Config{File: "users.json"})
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	generations, _, _ := reloaded.History()
+	if len(generations) != 1 || generations[0].Keywords != "медленная тема" {
+		t.Errorf("после перезапуска генерации: %+v", generations)
+	}
+}
+
+func TestShutdownGivesUpAfterTimeout(t *testing.T) {
+	b, _ := newTestBot(t, func(config *Config) { config.ShutdownTimeout = 50 * time.Millisecond })
+	stop := runBot(t, b)
+
+	release := make(chan struct{})
+	defer close(release)
+	b.safeGo("stuck", 0, func() { <-release })
+	b.db.AddGeneration(1, "тема", "Источник", "")
+
+	started := time.Now()
+	stop()
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Errorf("завершение ждало зависший обработчик %v", elapsed)
+	}
+	// База сохраняется и без зависшего обработчика
+	reloaded := database.NewDatabase(database.Config{File: "users.json"})
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if generations, _, _ := reloaded.History(); len(generations) != 1 {
+		t.Errorf("после перезапуска генераций %d", len(generations))
+	}
+}
+
+func TestPanicNotifiesAndLaterUpdatesRun(t *testing.T) {
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	// Две одинаковые паники в очереди чата, затем обычная команда
+	for i := 0; i < 2; i++ {
+		b.dispatch(1, "callback", func() { panic("nil map") })
+	}
+	fake.Feed(commandUpdate(1, "/nosuchcommand"))
+	fake.Feed(commandUpdate(2, "просто текст"))
+
+	waitFor(t, "ответ на команду после паники", func() bool {
+		return fake.LastText(1) == i18n.T("ru", "command.unknown")
+	})
+	waitFor(t, "ответ другому чату", func() bool {
+		return fake.LastText(2) == i18n.T("ru", "message.not_command")
+	})
+	waitFor(t, "уведомление администратора", func() bool { return len(fake.SentTo(testAdminChatID)) > 0 })
+
+	sent := fake.SentTo(1)
+	if len(sent) != 3 || sent[0].Text != i18n.T("ru", "error.internal") || sent[1].Text != sent[0].Text {
+		t.Errorf("сообщения пользователю: %+v", sent)
+	}
+	if b.Panics() != 2 {
+		t.Errorf("паник %d, ожидалось 2", b.Panics())
+	}
+
+	// Одна и та же паника сообщается администратору один раз
+	time.Sleep(100 * time.Millisecond)
+	admin := fake.SentTo(testAdminChatID)
+	if len(admin) != 1 || !strings.Contains(admin[0].Text, "Паника в обработчике callback (чат 1): nil map") ||
+		!strings.Contains(admin[0].Text, "Стек ") {
+		t.Errorf("уведомления администратору: %+v", admin)
+	}
+}
+
+func TestPanicInBackgroundHandler(t *testing.T) {
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	b.safeGo("reports", 0, func() { panic("отчет") })
+	waitFor(t, "уведомление администратора", func() bool { return len(fake.SentTo(testAdminChatID)) == 1 })
+
+	// Фоновой работе некому извиняться: пишется только администратору
+	if sent := fake.Sent(); len(sent) != 1 {
+		t.Errorf("отправлено %+v", sent)
+	}
+	if text := fake.LastText(testAdminChatID); !strings.Contains(text, "Паника в обработчике reports (чат 0): отчет") {
+		t.Errorf("уведомление %q", text)
+	}
+}
+
+func TestStackHash(t *testing.T) {
+	first := []byte("goroutine 7 [running]:\nmain.handler(0xc000010000, 0x1)\n\t/app/bot.go:10 +0x1d\nmain.main()\n")
+	second := []byte("goroutine 42 [running]:\nmain.handler(0xc0000aa000, 0x2)\n\t/app/bot.go:10 +0x2f\nmain.main()\n")
+	other := []byte("goroutine 7 [running]:\nmain.other(0xc000010000)\n\t/app/bot.go:20 +0x1d\nmain.main()\n")
+
+	// Номер горутины, аргументы и смещения не влияют на хэш
+	if stackHash(first) != stackHash(second) {
+		t.Error("хэши одного стека различаются")
+	}
+	if stackHash(first) == stackHash(other) {
+		t.Error("хэши разных стеков совпадают")
+	}
+	if len(stackHash(first)) != 8 {
+		t.Errorf("хэш %q", stackHash(first))
+	}
+}
+
+// sentryEvent поля события, которые проверяют тесты
+type sentryEvent struct {
+	Level   string            `json:"level"`
+	Message map[string]string `json:"message"`
+	Tags    map[string]string `json:"tags"`
+	User    map[string]string `json:"user"`
+	Extra   map[string]string `json:"extra"`
+}
+
+// useFakeSentry включает отправку ошибок в поддельный сервис на время теста.
+// Возвращаемая функция дожидается отправки накопленных событий и возвращает их.
+func useFakeSentry(t *testing.T) func() []sentryEvent {
+	t.Helper()
+	var (
+		mu       sync.Mutex
+		received []sentryEvent
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+		var event sentryEvent
+		if err := json.Unmarshal([]byte(lines[len(lines)-1]), &event); err != nil {
+			t.Errorf("событие не JSON: %q", body)
+		}
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	config := reporting.DefaultConfig()
+	config.DSN = strings.Replace(server.URL, "http://", "http://key@", 1) + "/1"
+	if err := reporting.Configure(config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { reporting.Configure(reporting.DefaultConfig()) })
+
+	return func() []sentryEvent {
+		if !reporting.Flush(5 * time.Second) {
+			t.Fatal("события не отправлены")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]sentryEvent(nil), received...)
+	}
+}
+
+func TestPanicReported(t *testing.T) {
+	events := useFakeSentry(t)
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	b.dispatch(7, "callback", func() { panic("nil map") })
+	waitFor(t, "извинение", func() bool { return fake.LastText(7) == i18n.T("ru", "error.internal") })
+
+	received := events()
+	if len(received) != 1 {
+		t.Fatalf("событий %d, ожидалось 1", len(received))
+	}
+	event := received[0]
+	if event.Level != reporting.LevelFatal || event.Tags["operation"] != "panic" || event.Tags["handler"] != "callback" ||
+		event.Tags["stack_hash"] == "" || event.User["id"] != "7" {
+		t.Errorf("событие %+v", event)
+	}
+	if !strings.Contains(event.Message["formatted"], "nil map") || !strings.Contains(event.Extra["stack"], "goroutine") {
+		t.Errorf("сообщение %q, стек %q", event.Message["formatted"], event.Extra["stack"])
+	}
+}
+
+func TestInterfaceLanguageDetectedAndSwitched(t *testing.T) {
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	start := commandUpdate(1, "/start")
+	start.Message.From.LanguageCode = "en-GB"
+	fake.Feed(start)
+	fake.Feed(commandUpdate(1, "/nosuchcommand"))
+	waitFor(t, "ответ на английском", func() bool { return fake.LastText(1) == i18n.T("en", "command.unknown") })
+
+	// Кнопка выбора языка правит сообщение и меняет язык следующих ответов
+	fake.Feed(commandUpdate(1, "/language"))
+	waitFor(t, "выбор языка", func() bool { return fake.LastText(1) == i18n.T("en", "language.choose") })
+	choose := fake.SentTo(1)[len(fake.SentTo(1))-1]
+	if choose.Keyboard == nil || len(choose.Keyboard.InlineKeyboard[0]) != len(i18n.Languages()) {
+		t.Fatalf("клавиатура выбора языка: %+v", choose.Keyboard)
+	}
+	fake.Feed(callbackUpdate(1, choose.MessageID, languageCallbackPrefix+"ru"))
+	waitFor(t, "подтверждение по-русски", func() bool {
+		return fake.LastText(1) == i18n.T("ru", "language.changed", "🇷🇺 Русский")
+	})
+
+	fake.Feed(commandUpdate(1, "/language de"))
+	waitFor(t, "ошибка на русском", func() bool {
+		return fake.LastText(1) == i18n.T("ru", "language.unknown", "de", i18n.Codes())
+	})
+	if got := b.db.GetSettings(1).InterfaceLanguage; got != "ru" {
+		t.Errorf("язык в настройках %q", got)
+	}
+}
+
+func TestDescribeNoNews(t *testing.T) {
+	tests := []struct {
+		name string
+		diag news.SearchDiagnostics
+		key  string
+	}{
+		{"источники недоступны", news.SearchDiagnostics{SourcesTotal: 3, SourcesFailed: 3}, "no_news.sources_down"},
+		{"в источниках пусто", news.SearchDiagnostics{SourcesTotal: 3}, "no_news.empty"},
+		{"все статьи о запрещенных темах", news.SearchDiagnostics{Fetched: 4, ContentFiltered: 4}, "no_news.forbidden"},
+		{"запрещенные темы после фильтра периода", news.SearchDiagnostics{Fetched: 6, OutOfWindow: 2, ContentFiltered: 4}, "no_news.forbidden"},
+		{"все исключены пользователем", news.SearchDiagnostics{Fetched: 4, ContentFiltered: 1, Excluded: 3}, "no_news.excluded"},
+		{"есть ближайшая статья", news.SearchDiagnostics{Fetched: 4, BestRejectedScore: 12, BestRejectedTitle: "Рынок жилья"}, "no_news.closest"},
+		{"ничего не подошло", news.SearchDiagnostics{Fetched: 4}, "no_news.default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := i18n.T("ru", tt.key)
+			if tt.key == "no_news.closest" {
+				want = i18n.T("ru", tt.key, tt.diag.BestRejectedTitle)
+			}
+			if got := describeNoNews("ru", tt.diag); got != want {
+				t.Errorf("describeNoNews = %q, ожидалось %q", got, want)
+			}
+		})
+	}
+}
+
+func TestStreamProgressThrottlesEdits(t *testing.T) {
+	fake := testutil.NewFakeTelegram(0)
+	progress := newProgressMessage(fake, 1, 0, 0)
+	progress.update("Шаг 3/3")
+
+	partial := make(chan string)
+	done := (&Bot{}).streamProgress(progress, "Пишу пост", partial)
+	for _, text := range []string{"⚡️ Центр", "⚡️ Центробанк сохранил", "⚡️ Центробанк сохранил ставку"} {
+		partial <- text
+	}
+
+	// До первого тика сообщение не правится, затем показывается только последний текст
+	if sent := fake.SentTo(1); len(sent) != 1 {
+		t.Fatalf("до интервала отправлено %d сообщений", len(sent))
+	}
+	time.Sleep(streamEditInterval + 200*time.Millisecond)
+
+	sent := fake.SentTo(1)
+	if len(sent) != 2 {
+		t.Fatalf("после интервала отправлено %d сообщений, ожидалась одна правка", len(sent))
+	}
+	edit := sent[1]
+	if edit.MessageID != sent[0].MessageID || !strings.HasPrefix(edit.Text, "Пишу пост\n\n⚡️ Центробанк сохранил ставку") {
+		t.Errorf("правка = %+v", edit)
+	}
+
+	close(partial)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("streamProgress не завершился после закрытия partial")
+	}
+}
+
+func TestTailRunes(t *testing.T) {
+	if got := tailRunes("Короткий", 10); got != "Короткий" {
+		t.Errorf("tailRunes = %q", got)
+	}
+	if got := tailRunes("Центробанк", 4); got != "…банк" {
+		t.Errorf("tailRunes = %q", got)
+	}
+}
+
+func TestReplyPostText(t *testing.T) {
+	const botID = 100
+	bot := &tgbotapi.User{ID: botID}
+	user := &tgbotapi.User{ID: 1}
+
+	tests := []struct {
+		name  string
+		reply *tgbotapi.Message
+		want  string
+	}{
+		{"нет ответа", nil, ""},
+		{"ответ на сообщение пользователя", &tgbotapi.Message{From: user, Text: "Мой текст"}, ""},
+		{"сообщение без отправителя", &tgbotapi.Message{Text: "Пост"}, ""},
+		{"пост бота", &tgbotapi.Message{From: bot, Text: "Пост бота"}, "Пост бота"},
+		{
+			"разметка восстанавливается",
+			&tgbotapi.Message{From: bot, Text: "Заголовок\n\nТекст", Entities: []tgbotapi.MessageEntity{{Type: "bold", Offset: 0, Length: 9}}},
+			"*Заголовок*\n\nТекст",
+		},
+		{
+			"подпись к фото",
+			&tgbotapi.Message{From: bot, Caption: "⚡️ Пост с фото", CaptionEntities: []tgbotapi.MessageEntity{{Type: "italic", Offset: 3, Length: 4}}},
+			"⚡️ _Пост_ с фото",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := replyPostText(tt.reply, botID); got != tt.want {
+				t.Errorf("replyPostText = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractLanguageFlag(t *testing.T) {
+	tests := []struct {
+		args, code, rest string
+	}{
+		{"курс рубля", "", "курс рубля"},
+		{"-lang=en курс рубля", "en", "курс рубля"},
+		{"курс -LANG=KK  рубля", "kk", "курс рубля"},
+		{"-lang= курс", "", "курс"},
+	}
+	for _, tt := range tests {
+		code, rest := extractLanguageFlag(tt.args)
+		if code != tt.code || rest != tt.rest {
+			t.Errorf("extractLanguageFlag(%q) = %q, %q; ожидалось %q, %q", tt.args, code, rest, tt.code, tt.rest)
+		}
+	}
+}
+
+func TestParseSearchFlags(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		args     string
+		maxAge   time.Duration
+		minScore float64
+		sources  string
+		rest     string
+	}{
+		{"курс рубля", 0, 0, "", "курс рубля"},
+		{"-period=today выборы", day, 0, "", "выборы"},
+		{"-ПЕРИОД=Неделя выборы", 7 * day, 0, "", "выборы"},
+		{"-period=3d -period=12h выборы", 12 * time.Hour, 0, "", "выборы"},
+		{"-source=РБК -source=ТАСС -minscore=70 ставка", 0, 70, "РБК,ТАСС", "ставка"},
+		// Слова без значения — исключения из запроса, а не период
+		{"-week -неделя -3d сериал", 0, 0, "", "-week -неделя -3d сериал"},
+		{"-period=3d -today выборы", 3 * day, 0, "", "-today выборы"},
+		// Неверное значение не съедает запрос
+		{"-period=месяц выборы", 0, 0, "", "-period=месяц выборы"},
+		{"-minscore=101 выборы", 0, 0, "", "-minscore=101 выборы"},
+		{"выборы -period=today", 0, 0, "", "выборы -period=today"},
+	}
+	for _, tt := range tests {
+		opts, rest := parseSearchFlags(tt.args)
+		if opts.MaxAge != tt.maxAge || opts.MinScore != tt.minScore || strings.Join(opts.SourceFilter, ",") != tt.sources || rest != tt.rest {
+			t.Errorf("parseSearchFlags(%q) = %+v, %q", tt.args, opts, rest)
+		}
+	}
+}
+
+func TestGenerateHashtagsRespectsLanguage(t *testing.T) {
+	article := news.Article{Tags: []string{"Экономика", "Новости", ""}}
+
+	if got := (&Bot{}).generateHashtags(article, ai.LanguageOrDefault("ru")); got != "#новости #интересное #экономика" {
+		t.Errorf("хештеги ru = %q", got)
+	}
+	// Теги русскоязычной статьи не попадают в пост на другом языке
+	if got := (&Bot{}).generateHashtags(article, ai.LanguageOrDefault("en")); got != "#news #trending" {
+		t.Errorf("хештеги en = %q", got)
+	}
+}
+
+func TestGenerateFlow(t *testing.T) {
+	article := news.Article{Title: "Ставка ЦБ", URL: "https://example.com/rate", Source: "РБК", Summary: "Банк России сохранил ставку"}
+
+	tests := []struct {
+		name        string
+		command     string
+		balance     int
+		configure   func(gpt *fakeGPT, search *fakeNews)
+		want        string
+		wantBalance int
+		wantWritten int32
+	}{
+		{
+			name:    "пост доставлен",
+			command: "/generate ставка цб",
+			balance: 3,
+			want:    i18n.T("ru", "rating.request"), wantBalance: 2, wantWritten: 1,
+		},
+		{
+			name:    "без темы",
+			command: "/generate",
+			balance: 3,
+			want:    i18n.T("ru", "generate.usage"), wantBalance: 3,
+		},
+		{
+			name:    "нет генераций",
+			command: "/generate ставка цб",
+			want:    i18n.T("ru", "generations.exhausted"),
+		},
+		{
+			name:    "тема отклонена",
+			command: "/generate ставка цб",
+			balance: 3,
+			configure: func(gpt *fakeGPT, search *fakeNews) {
+				gpt.check = ai.TopicCheck{Category: "политика", Reason: "запрещенная тема"}
+			},
+			want: i18n.T("ru", "generate.topic_rejected", "ставка цб", "запрещенная тема"), wantBalance: 3,
+		},
+		{
+			name:    "нет новостей",
+			command: "/generate ставка цб",
+			balance: 3,
+			configure: func(gpt *fakeGPT, search *fakeNews) {
+				search.articles = nil
+			},
+			want: i18n.T("ru", "generate.no_news", "ставка цб", describeNoNews("ru", news.SearchDiagnostics{TopScore: 50})), wantBalance: 3,
+		},
+		{
+			name:    "ошибка поиска",
+			command: "/generate ставка цб",
+			balance: 3,
+			configure: func(gpt *fakeGPT, search *fakeNews) {
+				search.err = errors.New("источники недоступны")
+			},
+			want: i18n.T("ru", "generate.failed", "ставка цб", i18n.T("ru", "reason.search_failed")), wantBalance: 3,
+		},
+		{
+			name:    "ошибка модели",
+			command: "/generate ставка цб",
+			balance: 3,
+			configure: func(gpt *fakeGPT, search *fakeNews) {
+				gpt.postErr = errors.New("503")
+			},
+			want: i18n.T("ru", "generate.failed", "ставка цб", aiFailureReason("ru", errors.New("503"))), wantBalance: 3, wantWritten: 1,
+		},
+		{
+			name:    "отказ модели",
+			command: "/generate ставка цб",
+			balance: 3,
+			configure: func(gpt *fakeGPT, search *fakeNews) {
+				gpt.refusal = true
+			},
+			want: i18n.T("ru", "generate.refused", "ставка цб"), wantBalance: 3, wantWritten: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			gpt, search := newFakeGPT(), &fakeNews{articles: []news.Article{article}}
+			if tt.configure != nil {
+				tt.configure(gpt, search)
+			}
+			useGenerator(b, gpt, search)
+			if _, err := b.db.AdjustGenerations(1, tt.balance, testAdminChatID, "тест", true); err != nil {
+				t.Fatal(err)
+			}
+			runBot(t, b)
+
+			fake.Feed(commandUpdate(1, tt.command))
+			waitFor(t, tt.want, func() bool { return sentText(fake, 1, tt.want) })
+
+			if user := b.db.GetUser(1); user.AvailableGenerations != tt.wantBalance || user.ReservedGenerations != 0 {
+				t.Errorf("доступно %d, в резерве %d; ожидалось %d, 0", user.AvailableGenerations, user.ReservedGenerations, tt.wantBalance)
+			}
+			if written := gpt.written.Load(); written != tt.wantWritten {
+				t.Errorf("написано постов %d, ожидалось %d", written, tt.wantWritten)
+			}
+		})
+	}
+}
+
+func TestGenerateFlowDeliversPost(t *testing.T) {
+	b, fake := newTestBot(t)
+	useGenerator(b, newFakeGPT(), &fakeNews{articles: []news.Article{{Title: "Ставка ЦБ", URL: "https://example.com/rate", Source: "РБК"}}})
+	runBot(t, b)
+
+	fake.Feed(commandUpdate(1, "/generate ставка цб"))
+	waitFor(t, "просьба об оценке", func() bool { return fake.LastText(1) == i18n.T("ru", "rating.request") })
+
+	var post, rating *testutil.Sent
+	for _, sent := range fake.SentTo(1) {
+		switch {
+		case strings.HasPrefix(sent.Text, "⚡️ Центробанк сохранил ставку"):
+			post = &sent
+		case sent.Text == i18n.T("ru", "rating.request"):
+			rating = &sent
+		}
+	}
+	if post == nil || post.Keyboard == nil || post.Keyboard.InlineKeyboard[0][0].CallbackData == nil ||
+		*post.Keyboard.InlineKeyboard[0][0].CallbackData != expandCallback {
+		t.Fatalf("пост с кнопкой «Расширить»: %+v", post)
+	}
+	if rating == nil || len(rating.Keyboard.InlineKeyboard[0]) != 5 || *rating.Keyboard.InlineKeyboard[0][4].CallbackData != "rate_5_ставка цб" {
+		t.Errorf("кнопки оценки: %+v", rating)
+	}
+	if generations, _, _ := b.db.History(); len(generations) != 1 || generations[0].Source != "РБК" {
+		t.Errorf("журнал генераций: %+v", generations)
+	}
+}
+
+func TestTestGenFlow(t *testing.T) {
+	article := news.Article{Title: "Ставка ЦБ", URL: "https://example.com/rate", Source: "РБК"}
+
+	tests := []struct {
+		name      string
+		chatID    int64
+		command   string
+		configure func(gpt *fakeGPT)
+		want      string
+		// wantOutcome причина в журнале тестовой генерации; пустая — генерация не записывается
+		wantOutcome string
+		wantWritten int32
+	}{
+		{name: "без аргументов", chatID: testAdminChatID, command: "/testgen " + testAdminPassword, want: "🔐 Использование:\n/testgen"},
+		{name: "обычный пользователь", chatID: 1, command: "/testgen wrong 1 ставка цб", want: "❌ Неверный пароль"},
+		{name: "неверный chatid", chatID: testAdminChatID, command: "/testgen " + testAdminPassword + " abc ставка цб", want: "❌ Неверный chatid"},
+		{name: "ссылка", chatID: testAdminChatID, command: "/testgen " + testAdminPassword + " 1 https://example.com/news",
+			want: "❌ Тестовая генерация работает только по теме"},
+		{name: "пост", chatID: testAdminChatID, command: "/testgen " + testAdminPassword + " 1 ставка цб",
+			want: dryRunLabel, wantOutcome: "РБК", wantWritten: 1},
+		{name: "тема отклонена", chatID: testAdminChatID, command: "/testgen " + testAdminPassword + " 1 ставка цб",
+			configure: func(gpt *fakeGPT) {
+				gpt.check = ai.TopicCheck{Category: "политика", Reason: "запрещенная тема"}
+			},
+			want:        i18n.T("ru", "generate.topic_rejected", "ставка цб", "запрещенная тема"),
+			wantOutcome: "rejected: политика: запрещенная тема"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			gpt := newFakeGPT()
+			if tt.configure != nil {
+				tt.configure(gpt)
+			}
+			useGenerator(b, gpt, &fakeNews{articles: []news.Article{article}})
+			for _, userID := range []int64{1, testAdminChatID} {
+				if _, err := b.db.AdjustGenerations(userID, 2, testAdminChatID, "тест", true); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stop := runBot(t, b)
+
+			fake.Feed(commandUpdate(tt.chatID, tt.command))
+			waitFor(t, tt.want, func() bool { return sentText(fake, tt.chatID, tt.want) })
+			// Остановка дожидается конца генерации
+			stop()
+
+			// Тестовая генерация не трогает ни баланс, ни счетчики
+			for _, userID := range []int64{1, testAdminChatID} {
+				user := b.db.GetUser(userID)
+				if user.AvailableGenerations != 2 || user.ReservedGenerations != 0 || user.GenerationsCount != 0 {
+					t.Errorf("пользователь %d: доступно %d, в резерве %d, счетчик %d", userID,
+						user.AvailableGenerations, user.ReservedGenerations, user.GenerationsCount)
+				}
+			}
+			if tt.chatID == testAdminChatID && len(fake.SentTo(1)) != 0 {
+				t.Errorf("проверяемому пользователю отправлено %+v", fake.SentTo(1))
+			}
+			if written := gpt.written.Load(); written != tt.wantWritten {
+				t.Errorf("написано постов %d, ожидалось %d", written, tt.wantWritten)
+			}
+
+			generations, _, _ := b.db.History()
+			switch {
+			case tt.wantOutcome == "" && len(generations) != 0:
+				t.Errorf("журнал генераций: %+v", generations)
+			case tt.wantOutcome != "" && (len(generations) != 1 || generations[0].UserID != 1 ||
+				generations[0].Outcome != database.OutcomeDryRun || generations[0].Reason != tt.wantOutcome):
+				t.Errorf("журнал генераций: %+v", generations)
+			}
+		})
+	}
+}
+
+// fakeYooKassa API ЮKassa: создает платежи pay-1, pay-2… и отвечает на проверку
+// статусом из statuses
+type fakeYooKassa struct {
+	mu       sync.Mutex
+	created  int
+	statuses map[string]string
+	// createStatus код ответа на создание платежа; 0 — 200
+	createStatus int
+}
+
+// newFakeYooKassa запускает API ЮKassa и возвращает клиент к нему
+func newFakeYooKassa(t *testing.T) (*fakeYooKassa, *payment.YooMoneyClient) {
+	t.Helper()
+	f := &fakeYooKassa{statuses: make(map[string]string)}
+	server := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(server.Close)
+
+	client, err := payment.NewYooMoneyClient(payment.Config{ShopID: "shop", SecretKey: "key", BaseURL: server.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, client
+}
+
+func (f *fakeYooKassa) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var response payment.PaymentResponse
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/payments":
+		if f.createStatus != 0 {
+			w.WriteHeader(f.createStatus)
+			fmt.Fprint(w, `{"type": "error", "description": "Недостаточно прав"}`)
+			return
+		}
+		f.created++
+		response.ID = fmt.Sprintf("pay-%d", f.created)
+		response.Status = "pending"
+		response.Confirmation.ConfirmationURL = "https://yookassa.example/" + response.ID
+		f.statuses[response.ID] = "pending"
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/payments/"):
+		response.ID = strings.TrimPrefix(r.URL.Path, "/payments/")
+		status, ok := f.statuses[response.ID]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		response.Status = status
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (f *fakeYooKassa) setStatus(paymentID, status string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses[paymentID] = status
+}
+
+func TestBuyFlow(t *testing.T) {
+	tests := []struct {
+		name string
+		// status статус платежа pay-1 в ЮKassa перед нажатием кнопки; пустой — кнопка не нажимается
+		status       string
+		callback     string
+		createStatus int
+		want         string
+		wantBalance  int
+		// wantPending платеж pay-1 еще ждет оплаты
+		wantPending bool
+	}{
+		{name: "платеж создан", callback: "buy_10", want: "pay-1", wantBalance: 3, wantPending: true},
+		{name: "неизвестный пакет", callback: "buy_7", want: i18n.T("ru", "purchase.unknown_package"), wantBalance: 3},
+		{name: "ЮKassa отказала", callback: "buy_10", createStatus: http.StatusForbidden,
+			want: i18n.T("ru", "purchase.create_failed", "ошибка ЮKassa: Недостаточно прав"), wantBalance: 3},
+		{name: "оплачен", callback: "buy_25", status: "succeeded", want: i18n.T("ru", "payment.succeeded"), wantBalance: 28},
+		{name: "еще не оплачен", callback: "buy_10", status: "pending", want: i18n.T("ru", "payment.pending"), wantBalance: 3, wantPending: true},
+		{name: "отменен в ЮKassa", callback: "buy_10", status: "canceled", want: i18n.T("ru", "payment.canceled_details"), wantBalance: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			yooKassa, client := newFakeYooKassa(t)
+			yooKassa.createStatus = tt.createStatus
+			b.yooMoney = client
+			if _, err := b.db.AdjustGenerations(1, 3, testAdminChatID, "", true); err != nil {
+				t.Fatal(err)
+			}
+			runBot(t, b)
+
+			fake.Feed(commandUpdate(1, "/buy"))
+			waitFor(t, "меню покупки", func() bool { return len(fake.SentTo(1)) == 1 })
+			if menu := fake.SentTo(1)[0]; menu.Keyboard == nil || len(menu.Keyboard.InlineKeyboard) != 3 {
+				t.Fatalf("меню покупки: %+v", menu)
+			}
+
+			fake.Feed(callbackUpdate(1, 1, tt.callback))
+			if tt.status != "" {
+				waitFor(t, "ссылка на оплату", func() bool { return sentText(fake, 1, "pay-1") })
+				yooKassa.setStatus("pay-1", tt.status)
+				fake.Feed(callbackUpdate(1, 2, "check_pay-1"))
+			}
+			waitFor(t, tt.want, func() bool { return sentText(fake, 1, tt.want) })
+
+			if balance := b.db.GetUser(1).AvailableGenerations; balance != tt.wantBalance {
+				t.Errorf("доступно %d, ожидалось %d", balance, tt.wantBalance)
+			}
+			purchase := b.db.GetPendingPurchase("pay-1")
+			if pending := purchase != nil && purchase.Status == "pending"; pending != tt.wantPending {
+				t.Errorf("платеж ждет оплаты: %t, ожидалось %t", pending, tt.wantPending)
+			}
+		})
+	}
+}
+
+func TestBuyFlowCancel(t *testing.T) {
+	b, fake := newTestBot(t)
+	_, client := newFakeYooKassa(t)
+	b.yooMoney = client
+	runBot(t, b)
+
+	fake.Feed(callbackUpdate(1, 1, "buy_100"))
+	waitFor(t, "ссылка на оплату", func() bool { return sentText(fake, 1, "pay-1") })
+	payment := fake.SentTo(1)[0]
+	if payment.Keyboard == nil || len(payment.Keyboard.InlineKeyboard) != 3 ||
+		*payment.Keyboard.InlineKeyboard[0][0].URL != "https://yookassa.example/pay-1" ||
+		*payment.Keyboard.InlineKeyboard[2][0].CallbackData != "cancel_pay-1" {
+		t.Fatalf("кнопки платежа: %+v", payment.Keyboard)
+	}
+
+	fake.Feed(callbackUpdate(1, payment.MessageID, "cancel_pay-1"))
+	waitFor(t, "отмена", func() bool { return fake.LastText(1) == i18n.T("ru", "payment.canceled") })
+	if purchase := b.db.GetPendingPurchase("pay-1"); purchase != nil && purchase.Status != "canceled" {
+		t.Errorf("платеж после отмены: %+v", purchase)
+	}
+}
+
+func TestBuyUnavailableWithoutPayments(t *testing.T) {
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	fake.Feed(commandUpdate(1, "/buy"))
+	fake.Feed(callbackUpdate(1, 1, "buy_10"))
+	waitFor(t, "ответ на кнопку", func() bool { return len(fake.SentTo(1)) == 2 })
+	if sent := fake.SentTo(1); sent[0].Text != i18n.T("ru", "buy.unavailable") || sent[1].Text != i18n.T("ru", "purchase.unavailable") {
+		t.Errorf("сообщения: %q, %q", sent[0].Text, sent[1].Text)
+	}
+}
+
+func TestPaymentCreditErrorReported(t *testing.T) {
+	events := useFakeSentry(t)
+	b, fake := newTestBot(t)
+	yooKassa, client := newFakeYooKassa(t)
+	b.yooMoney = client
+	runBot(t, b)
+
+	fake.Feed(callbackUpdate(1, 1, "buy_10"))
+	waitFor(t, "ссылка на оплату", func() bool { return sentText(fake, 1, "pay-1") })
+	yooKassa.setStatus("pay-1", "succeeded")
+
+	// Каталог на месте временного файла: база не может сохранить зачисление
+	if err := os.Mkdir("users.json.tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.completePayment("pay-1"); err == nil {
+		t.Fatal("зачисление сохранено")
+	}
+
+	received := events()
+	if len(received) != 1 {
+		t.Fatalf("событий %d, ожидалось 1", len(received))
+	}
+	if event := received[0]; event.Level != reporting.LevelError || event.Tags["operation"] != "payment_credit" ||
+		event.Tags["payment_id"] != "pay-1" || event.User["id"] != "1" || !strings.Contains(event.Message["formatted"], "временного файла") {
+		t.Errorf("событие %+v", event)
+	}
+}
+
+func TestRatingFlow(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantRating  int
+		wantWeight  bool
+		wantThanked bool
+	}{
+		{"оценка поста", "rate_5_ставка цб", 5, true, true},
+		{"низкая оценка", "rate_1_ставка цб", 1, true, true},
+		{"оценка вне шкалы", "rate_9_ставка цб", 0, false, false},
+		{"испорченные данные", "rate_5", 0, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			b.db.AddGeneration(1, "ставка цб", "РБК", "req-1")
+			runBot(t, b)
+
+			update := callbackUpdate(1, 7, tt.data)
+			update.CallbackQuery.From.UserName = "ivan"
+			fake.Feed(update)
+			// Следующая команда отвечает после обработки кнопки: очередь чата общая
+			fake.Feed(commandUpdate(1, "/nosuchcommand"))
+			waitFor(t, "ответ на команду", func() bool { return fake.LastText(1) == i18n.T("ru", "command.unknown") })
+
+			_, _, ratings := b.db.History()
+			if tt.wantRating == 0 {
+				if len(ratings) != 0 {
+					t.Errorf("записаны оценки %+v", ratings)
+				}
+			} else if len(ratings) != 1 || ratings[0].Rating != tt.wantRating || ratings[0].Topic != "ставка цб" {
+				t.Errorf("оценки %+v", ratings)
+			}
+			if changed := b.newsAggregator.SourceWeight("РБК") != 1; changed != tt.wantWeight {
+				t.Errorf("вес источника изменен: %t", changed)
+			}
+			if thanked := sentText(fake, 1, i18n.T("ru", "rating.thanks", tt.wantRating)); thanked != tt.wantThanked {
+				t.Errorf("благодарность отправлена: %t", thanked)
+			}
+			if tt.wantThanked {
+				waitFor(t, "уведомление администратора", func() bool { return sentText(fake, testAdminChatID, "@ivan") })
+				if edit := fake.SentTo(1)[0]; edit.MessageID != 7 || edit.Text != i18n.T("ru", "rating.thanks_edit") {
+					t.Errorf("правка сообщения с оценкой: %+v", edit)
+				}
+			}
+		})
+	}
+}
+
+func TestAdminFlow(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    string
+		// wantBalance баланс пользователя 2 после команды
+		wantBalance int
+		// wantUserText сообщение пользователю 2; пустое — ему ничего не пишется
+		wantUserText string
+	}{
+		{"статистика без пароля", "/statistics", "🔐 Введите пароль", 3, ""},
+		{"статистика с неверным паролем", "/statistics wrong", "❌ Неверный пароль", 3, ""},
+		{"статистика", "/statistics " + testAdminPassword, "📊 СТАТИСТИКА БОТА", 3, ""},
+		{"начисление без аргументов", "/addgenerations " + testAdminPassword, "🔐 Использование", 3, ""},
+		{"начисление с неверным паролем", "/addgenerations wrong 2 5", "❌ Неверный пароль", 3, ""},
+		{"начисление", "/addgenerations " + testAdminPassword + " 2 5 компенсация", "✅ Пользователю 2 успешно добавлено 5 генераций", 8,
+			i18n.T("ru", "generations.added", 5, 8, 0)},
+		{"начисление сверх предела", "/addgenerations " + testAdminPassword + " 2 100000", "❌ Неверное количество генераций", 3, ""},
+		{"списание", "/removegenerations " + testAdminPassword + " 2 2", "✅ У пользователя 2 списано 2 генераций", 1,
+			i18n.T("ru", "generations.removed", 2, 1)},
+		{"неизвестный пользователь", "/addgenerations " + testAdminPassword + " 3 5", "❌ Пользователь 3 не найден", 3, ""},
+		{"состояние без проверок", "/status " + testAdminPassword, "❌ Проверки состояния не настроены", 3, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			if _, err := b.db.AdjustGenerations(2, 3, testAdminChatID, "", true); err != nil {
+				t.Fatal(err)
+			}
+			runBot(t, b)
+
+			fake.Feed(commandUpdate(testAdminChatID, tt.command))
+			waitFor(t, tt.want, func() bool { return sentText(fake, testAdminChatID, tt.want) })
+
+			if balance := b.db.GetUser(2).AvailableGenerations; balance != tt.wantBalance {
+				t.Errorf("баланс пользователя %d, ожидался %d", balance, tt.wantBalance)
+			}
+			if got := fake.LastText(2); got != tt.wantUserText {
+				t.Errorf("пользователю отправлено %q, ожидалось %q", got, tt.wantUserText)
+			}
+		})
+	}
+}
+
+func TestSelfTestCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		suite   bool
+		want    string
+	}{
+		{"без пароля", "/selftest", true, "🔐 Использование:\n/selftest пароль"},
+		{"неверный пароль", "/selftest wrong", true, "❌ Неверный пароль"},
+		{"не настроена", "/selftest " + testAdminPassword, false, "❌ Самопроверка не настроена"},
+		{"отчет", "/selftest " + testAdminPassword, true, "🧪 Самопроверка: пройдено 1 из 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			if tt.suite {
+				suite := selftest.NewSuite(time.Second)
+				suite.Add("database", true, func(ctx context.Context) (string, error) { return "", nil })
+				suite.Add("ai", true, func(ctx context.Context) (string, error) { return "", errors.New("неверный ключ") })
+				b.SetSelfTest(suite)
+			}
+			runBot(t, b)
+
+			fake.Feed(commandUpdate(testAdminChatID, tt.command))
+			waitFor(t, tt.want, func() bool { return sentText(fake, testAdminChatID, tt.want) })
+		})
+	}
+}
+
+func TestSelfTestCommandEditsProgress(t *testing.T) {
+	b, fake := newTestBot(t)
+	suite := selftest.NewSuite(time.Second)
+	suite.Add("news", true, func(ctx context.Context) (string, error) { return "2 из 2 источников", nil })
+	b.SetSelfTest(suite)
+	runBot(t, b)
+
+	fake.Feed(commandUpdate(testAdminChatID, "/selftest "+testAdminPassword))
+	waitFor(t, "отчет", func() bool { return len(fake.SentTo(testAdminChatID)) == 2 })
+
+	// Отчет заменяет сообщение о ходе проверки, а не приходит отдельным
+	sent := fake.SentTo(testAdminChatID)
+	if sent[0].Text != "🧪 Проверяю зависимости..." || sent[1].MessageID != sent[0].MessageID ||
+		!strings.Contains(sent[1].Text, "✅ news") || !strings.Contains(sent[1].Text, ": 2 из 2 источников") {
+		t.Errorf("сообщения: %+v", sent)
+	}
+}
+
+func TestFindLogsCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    string
+		// wantDocument результат отправлен файлом
+		wantDocument bool
+	}{
+		{"без аргументов", "/findlogs " + testAdminPassword, "🔐 Использование:\n/findlogs", false},
+		{"неверный пароль", "/findlogs wrong 42", "❌ Неверный пароль", false},
+		{"неверное число часов", "/findlogs " + testAdminPassword + " 42 1000", "❌ Неверное число часов", false},
+		{"ничего не найдено", "/findlogs " + testAdminPassword + " 777", "📭 За 24 ч. ничего не найдено по «777»", false},
+		{"сообщением", "/findlogs " + testAdminPassword + " 42 12", "🔎 «42» за 12 ч.: 3 строк\n\nзапрос 42: 0", false},
+		{"ключевое слово из нескольких слов", "/findlogs " + testAdminPassword + " платеж завис", "🔎 «платеж завис» за 24 ч.: 20 строк", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t, func(config *Config) { config.LogFile = "logs.txt" })
+			var lines []string
+			for i := range 3 {
+				lines = append(lines, fmt.Sprintf("запрос 42: %d", i))
+			}
+			for i := range 20 {
+				lines = append(lines, fmt.Sprintf("платеж завис у пользователя %d", 100+i))
+			}
+			if err := os.WriteFile("logs.txt", []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			runBot(t, b)
+
+			fake.Feed(commandUpdate(testAdminChatID, tt.command))
+			waitFor(t, tt.want, func() bool { return sentText(fake, testAdminChatID, tt.want) })
+			last := fake.SentTo(testAdminChatID)
+			_, document := last[len(last)-1].Config.(tgbotapi.DocumentConfig)
+			if document != tt.wantDocument {
+				t.Errorf("результат файлом: %t, ожидалось %t", document, tt.wantDocument)
+			}
+		})
+	}
+}
+
+func TestStartAndHelpUseLiveData(t *testing.T) {
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	fake.Feed(commandUpdate(1, "/start"))
+	waitFor(t, "приветствие", func() bool {
+		return sentText(fake, 1, "🎯 Для всех новых пользователей 3 бесплатных генераций!")
+	})
+	fake.Feed(commandUpdate(1, "/help"))
+	waitFor(t, "справка", func() bool {
+		return sentText(fake, 1, "• 10 генераций - 99 руб\n• 25 генераций - 199 руб\n• 100 генераций - 499 руб")
+	})
+}
+
+func TestReloadTextsCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := texts.Configure(texts.Config{Dir: dir}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { texts.Configure(texts.Config{}) })
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	tests := []struct {
+		name     string
+		template string
+		command  string
+		want     string
+		wantHelp string
+	}{
+		{"без пароля", "", "/reloadtexts", "🔐 Использование:\n/reloadtexts пароль", ""},
+		{"неверный пароль", "Новая справка", "/reloadtexts wrong", "❌ Неверный пароль", ""},
+		{"перезагрузка", "Новая справка: {{.FreeGenerations}}", "/reloadtexts " + testAdminPassword,
+			"✅ Тексты /start и /help перезагружены", "Новая справка: 3"},
+		// Шаблон с ошибкой не заменяет работающий
+		{"ошибка в шаблоне", "{{.Unknown}}", "/reloadtexts " + testAdminPassword,
+			"❌ Тексты не обновлены, используются прежние", "Новая справка: 3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.template != "" {
+				if err := os.WriteFile(filepath.Join(dir, "help_ru.tmpl"), []byte(tt.template), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			fake.Reset()
+			fake.Feed(commandUpdate(testAdminChatID, tt.command))
+			waitFor(t, tt.want, func() bool { return sentText(fake, testAdminChatID, tt.want) })
+
+			if tt.wantHelp != "" {
+				fake.Feed(commandUpdate(1, "/help"))
+				waitFor(t, "справка", func() bool { return fake.LastText(1) == tt.wantHelp })
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package bot
+
+import (
+	"context"
+	"strings"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/richtext"
+)
+
+// maxCaptionLength ограничение Telegram на длину подписи к фото
+const maxCaptionLength = 1024
+
+// citationModes способы указать источник в порядке переключения в /settings
+var citationModes = []string{database.CitationMetadata, database.CitationInline, database.CitationNone}
+
+type citationKey struct{}
+
+// withCitation задает способ указать источник для генерации. Для ссылки в посте
+// промпт просит текст покороче, чтобы строка с источником уложилась в длину поста.
+func withCitation(ctx context.Context, mode string) context.Context {
+	ctx = context.WithValue(ctx, citationKey{}, mode)
+	if mode == database.CitationInline {
+		ctx = ai.WithInlineSource(ctx)
+	}
+	return ctx
+}
+
+// citationFrom возвращает способ указать источник; по умолчанию — в метаданных
+func citationFrom(ctx context.Context) string {
+	if mode, ok := ctx.Value(citationKey{}).(string); ok {
+		return mode
+	}
+	return database.CitationMetadata
+}
+
+// nextCitation возвращает следующий по кругу способ указать источник
+func nextCitation(mode string) string {
+	current := database.Settings{Citation: mode}.CitationMode()
+	for i, citation := range citationModes {
+		if citation == current {
+			return citationModes[(i+1)%len(citationModes)]
+		}
+	}
+	return database.CitationMetadata
+}
+
+// sourceLine строка со ссылкой «Источник» для конца поста; пустая, если источник
+// указывается не в посте или у поста нет ссылки
+func sourceLine(ctx context.Context, lang, url string) richtext.Text {
+	url = strings.TrimSpace(url)
+	if url == "" || citationFrom(ctx) != database.CitationInline {
+		return richtext.Text{}
+	}
+	// Ссылку с пробелом Telegram не примет как адрес сущности
+	return richtext.Sprintf(i18n.T(lang, "post.source_link"), strings.ReplaceAll(url, " ", "%20"))
+}
+
+// withSourceLine добавляет к тексту поста строку с источником. Если вместе они
+// длиннее limit единиц UTF-16, сокращается текст поста, чтобы ссылка не обрезалась.
+func withSourceLine(text, line richtext.Text, limit int) richtext.Text {
+	if line.IsEmpty() {
+		return text
+	}
+	line = richtext.Plain("\n\n").Append(line)
+	if room := limit - line.Len(); text.Len() > room {
+		text = text.Truncate(room)
+	}
+	return text.Append(line)
+}
+
+// sourceInMetadata указывается ли источник в сообщении с метаданными
+func sourceInMetadata(ctx context.Context) bool {
+	return citationFrom(ctx) == database.CitationMetadata
+}
@@ -0,0 +1,165 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/generator"
+	"AIGenerator/internal/news"
+	"AIGenerator/internal/richtext"
+	"AIGenerator/internal/testutil"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// linksTo сообщения в чат 1, в которых есть ссылка на url, и их тексты
+func linksTo(fake *testutil.FakeTelegram, url string) []string {
+	var result []string
+	for _, sent := range fake.SentTo(1) {
+		var entities []tgbotapi.MessageEntity
+		switch config := sent.Config.(type) {
+		case tgbotapi.MessageConfig:
+			entities = config.Entities
+		case tgbotapi.PhotoConfig:
+			entities = config.CaptionEntities
+		}
+		for _, entity := range entities {
+			if entity.Type == richtext.EntityTextLink && entity.URL == url {
+				result = append(result, sent.Text)
+			}
+		}
+	}
+	return result
+}
+
+func TestSourceLine(t *testing.T) {
+	tests := []struct {
+		name, mode, url string
+		wantText        string
+		wantURL         string
+	}{
+		{"ссылка в посте", database.CitationInline, "https://example.com/rate", "📰 Источник", "https://example.com/rate"},
+		{"пробел в ссылке", database.CitationInline, " https://example.com/a b ", "📰 Источник", "https://example.com/a%20b"},
+		{"ссылка в метаданных", database.CitationMetadata, "https://example.com/rate", "", ""},
+		{"без источника", database.CitationNone, "https://example.com/rate", "", ""},
+		{"пост без ссылки", database.CitationInline, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := sourceLine(withCitation(context.Background(), tt.mode), "ru", tt.url)
+			if line.String() != tt.wantText {
+				t.Errorf("строка %q, ожидалась %q", line.String(), tt.wantText)
+			}
+			entities := line.Entities()
+			if tt.wantURL == "" {
+				if len(entities) != 0 {
+					t.Errorf("лишние сущности: %+v", entities)
+				}
+				return
+			}
+			if len(entities) != 1 || entities[0].URL != tt.wantURL || entities[0].Offset != 3 || entities[0].Length != 8 {
+				t.Errorf("сущности %+v", entities)
+			}
+		})
+	}
+}
+
+func TestWithSourceLine(t *testing.T) {
+	line := richtext.Link("Источник", "https://example.com/rate")
+	post := richtext.Concat(richtext.Bold("Заголовок"), richtext.Plain("\n\n"+strings.Repeat("Текст поста. ", 20)))
+
+	// Короткий пост не сокращается
+	if got := withSourceLine(post, line, maxSendMessageLength); got.String() != post.String()+"\n\nИсточник" {
+		t.Errorf("короткий пост: %q", got.String())
+	}
+	// Без строки источника пост не меняется
+	if got := withSourceLine(post, richtext.Text{}, 10); got.String() != post.String() {
+		t.Errorf("пост без источника: %q", got.String())
+	}
+
+	// Длинный пост сокращается так, что ссылка остается целой в пределах limit
+	got := withSourceLine(post, line, 100)
+	if got.Len() > 100 || !strings.HasSuffix(got.String(), "\n\nИсточник") {
+		t.Fatalf("длинный пост (%d): %q", got.Len(), got.String())
+	}
+	entities := got.Entities()
+	link := entities[len(entities)-1]
+	if link.URL != "https://example.com/rate" || link.Offset+link.Length != got.Len() {
+		t.Errorf("ссылка %+v в тексте длиной %d", link, got.Len())
+	}
+}
+
+func TestNextCitation(t *testing.T) {
+	tests := []struct{ mode, want string }{
+		{"", database.CitationInline},
+		{database.CitationMetadata, database.CitationInline},
+		{database.CitationInline, database.CitationNone},
+		{database.CitationNone, database.CitationMetadata},
+		{"unknown", database.CitationInline},
+	}
+	for _, tt := range tests {
+		if got := nextCitation(tt.mode); got != tt.want {
+			t.Errorf("nextCitation(%q) = %q, ожидалось %q", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestCitationModesRenderPost(t *testing.T) {
+	const articleURL = "https://example.com/rate"
+	tests := []struct {
+		name string
+		mode string
+		// command запрос на генерацию: по ключевым словам или по ссылке
+		command string
+		// wantPost ссылка в самом посте, wantMetadata — в сообщении с метаданными
+		wantPost     bool
+		wantMetadata bool
+	}{
+		{"метаданные", database.CitationMetadata, "/generate ставка цб", false, true},
+		{"в посте", database.CitationInline, "/generate ставка цб", true, false},
+		{"без источника", database.CitationNone, "/generate ставка цб", false, false},
+		{"метаданные по ссылке", database.CitationMetadata, "/generate " + articleURL, false, true},
+		{"в посте по ссылке", database.CitationInline, "/generate " + articleURL, true, false},
+		{"без источника по ссылке", database.CitationNone, "/generate " + articleURL, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			gpt := newFakeGPT()
+			useGenerator(b, gpt, &fakeNews{articles: []news.Article{{Title: "Ставка ЦБ", URL: articleURL, Source: "РБК"}}})
+			b.generator = generator.New(&fakeNews{articles: []news.Article{{Title: "Ставка ЦБ", URL: articleURL, Source: "РБК"}}},
+				generator.PageFetcherFunc(func(ctx context.Context, url string) (generator.Page, error) {
+					return generator.Page{Title: "Ставка ЦБ", Content: strings.Repeat("Банк России сохранил ставку. ", 20)}, nil
+				}), gpt, b.db)
+			b.db.UpdateSettings(1, func(settings *database.Settings) { settings.Citation = tt.mode })
+			runBot(t, b)
+
+			fake.Feed(commandUpdate(1, tt.command))
+			waitFor(t, "пост", func() bool { return balance(b, 1) == testTrialGenerations-1 })
+			waitFor(t, "метаданные", func() bool { return sentText(fake, 1, "Осталось генераций") })
+
+			var inPost, inMetadata bool
+			for _, text := range linksTo(fake, articleURL) {
+				switch {
+				case strings.HasPrefix(text, "⚡️ Центробанк сохранил ставку") && strings.HasSuffix(text, "\n\n📰 Источник"):
+					inPost = true
+				case strings.Contains(text, "Метаданные"):
+					inMetadata = true
+				default:
+					t.Errorf("ссылка на источник в сообщении %q", text)
+				}
+			}
+			if inPost != tt.wantPost || inMetadata != tt.wantMetadata {
+				t.Errorf("ссылка в посте %t, в метаданных %t; ожидалось %t, %t", inPost, inMetadata, tt.wantPost, tt.wantMetadata)
+			}
+			// Без ссылки в метаданных в них не остается и строки об источнике
+			for _, text := range sentTexts(fake, 1) {
+				if !tt.wantMetadata && strings.Contains(text, "Метаданные") && strings.Contains(text, "Источник") {
+					t.Errorf("источник в метаданных: %q", text)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// generationDedupWindow сколько после завершения генерации такой же запрос
+	// получает ссылку на готовый пост вместо новой генерации
+	generationDedupWindow = 30 * time.Second
+	// generationDedupSize сколько последних запросов помнить
+	generationDedupSize = 1000
+)
+
+// recentGeneration недавний запрос на генерацию
+type recentGeneration struct {
+	key     string
+	running bool
+	// finished когда генерация закончилась; resultID сообщение с готовым постом
+	finished time.Time
+	resultID int
+}
+
+// recentGenerations недавние запросы на генерацию. Нетерпеливые пользователи отправляют
+// /generate по несколько раз подряд; одинаковый запрос из того же чата, пока первый
+// выполняется или только что закончился, не запускает новую генерацию и не списывает
+// баланс повторно. Размер ограничен, завершенные запросы забываются через window.
+type recentGenerations struct {
+	mu      sync.Mutex
+	window  time.Duration
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // от новых запросов к старым
+}
+
+func newRecentGenerations(window time.Duration, size int) *recentGenerations {
+	return &recentGenerations{
+		window:  window,
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// generationDedupKey ключ запроса: чат, язык поста и запрос без лишних пробелов.
+// Регистр ключевых слов не важен, а в ссылке путь может зависеть от регистра.
+func generationDedupKey(chatID int64, language, args string, isURL bool) string {
+	args = strings.Join(strings.Fields(args), " ")
+	if !isURL {
+		args = strings.ToLower(args)
+	}
+	return fmt.Sprintf("%d\x00%s\x00%s", chatID, language, args)
+}
+
+// begin отмечает начало генерации по ключу. Если такой же запрос выполняется или
+// закончился меньше window назад, возвращает его и false.
+func (r *recentGenerations) begin(key string, now time.Time) (recentGeneration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expire(now)
+	if element, ok := r.entries[key]; ok {
+		return *element.Value.(*recentGeneration), false
+	}
+
+	r.entries[key] = r.order.PushFront(&recentGeneration{key: key, running: true})
+	for r.order.Len() > r.size {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.entries, oldest.Value.(*recentGeneration).key)
+	}
+	return recentGeneration{}, true
+}
+
+// delivered запоминает сообщение с постом, отправленным генерацией
+func (r *recentGenerations) delivered(key string, messageID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if element, ok := r.entries[key]; ok {
+		element.Value.(*recentGeneration).resultID = messageID
+	}
+}
+
+// finish отмечает конец генерации. Генерация без поста забывается сразу: повторить
+// неудачный запрос можно без ожидания.
+func (r *recentGenerations) finish(key string, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	element, ok := r.entries[key]
+	if !ok {
+		return
+	}
+	entry := element.Value.(*recentGeneration)
+	if entry.resultID == 0 {
+		r.order.Remove(element)
+		delete(r.entries, key)
+		return
+	}
+	entry.running = false
+	entry.finished = now
+}
+
+// forget забывает запрос, который так и не начал выполняться
+func (r *recentGenerations) forget(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if element, ok := r.entries[key]; ok {
+		r.order.Remove(element)
+		delete(r.entries, key)
+	}
+}
+
+// expire удаляет завершенные запросы старше window
+func (r *recentGenerations) expire(now time.Time) {
+	for element := r.order.Back(); element != nil; {
+		prev := element.Prev()
+		if entry := element.Value.(*recentGeneration); !entry.running && now.Sub(entry.finished) >= r.window {
+			r.order.Remove(element)
+			delete(r.entries, entry.key)
+		}
+		element = prev
+	}
+}
+
+type dedupKey struct{}
+
+// withDedupKey передает генерации ключ ее запроса, чтобы sendPost запомнил готовый пост
+func withDedupKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, dedupKey{}, key)
+}
+
+// rememberDelivered запоминает отправленный пост для повторных запросов той же генерации
+func (b *Bot) rememberDelivered(ctx context.Context, messageID int) {
+	if key, ok := ctx.Value(dedupKey{}).(string); ok && messageID != 0 {
+		b.recent.delivered(key, messageID)
+	}
+}
+
+// answerDuplicate отвечает на повтор запроса: генерация еще идет или пост уже готов
+func (b *Bot) answerDuplicate(chatID int64, previous recentGeneration) {
+	log.Printf("[GENERATE] Повторный запрос от %d, новая генерация не запускается", chatID)
+	if previous.running {
+		b.sendMessage(chatID, b.t(chatID, "generate.duplicate_running"))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, b.t(chatID, "generate.duplicate_done"))
+	msg.ReplyToMessageID = previous.resultID
+	msg.AllowSendingWithoutReply = true
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("[ERROR] Ошибка отправки сообщения в чат %d: %v", chatID, err)
+	}
+}
@@ -0,0 +1,155 @@
+package bot
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/news"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestGenerationDedupKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		// isURL запрос — ссылка
+		isURL bool
+		same  bool
+	}{
+		{"те же ключевые слова", "ставка цб", "ставка цб", false, true},
+		{"регистр и пробелы", "Ставка  ЦБ ", "ставка цб", false, true},
+		{"другие ключевые слова", "ставка цб", "курс рубля", false, false},
+		{"порядок слов", "ставка цб", "цб ставка", false, false},
+		// В ссылке путь может зависеть от регистра
+		{"регистр ссылки", "https://example.com/News", "https://example.com/news", true, false},
+		{"та же ссылка", "https://example.com/news", " https://example.com/news", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := generationDedupKey(1, "ru", tt.a, tt.isURL)
+			if same := a == generationDedupKey(1, "ru", tt.b, tt.isURL); same != tt.same {
+				t.Errorf("ключи совпадают: %t, ожидалось %t", same, tt.same)
+			}
+		})
+	}
+	// Тот же запрос из другого чата или на другом языке — другой запрос
+	key := generationDedupKey(1, "ru", "ставка цб", false)
+	if key == generationDedupKey(2, "ru", "ставка цб", false) || key == generationDedupKey(1, "en", "ставка цб", false) {
+		t.Error("ключ не зависит от чата или языка")
+	}
+}
+
+func TestRecentGenerationsExpire(t *testing.T) {
+	recent := newRecentGenerations(30*time.Second, 10)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	if _, ok := recent.begin("a", now); !ok {
+		t.Fatal("первый запрос не начался")
+	}
+	// Выполняющийся запрос не забывается, сколько бы он ни шел
+	if previous, ok := recent.begin("a", now.Add(time.Hour)); ok || !previous.running {
+		t.Fatalf("повтор выполняющегося запроса: %+v, %t", previous, ok)
+	}
+	recent.delivered("a", 42)
+	finished := now.Add(time.Hour)
+	recent.finish("a", finished)
+
+	if previous, ok := recent.begin("a", finished.Add(29*time.Second)); ok || previous.running || previous.resultID != 42 {
+		t.Errorf("повтор в окне: %+v, %t", previous, ok)
+	}
+	if _, ok := recent.begin("a", finished.Add(30*time.Second)); !ok {
+		t.Error("запрос не забыт по истечении окна")
+	}
+
+	// Неудачный запрос без поста можно повторить сразу
+	recent.begin("b", now)
+	recent.finish("b", now)
+	if _, ok := recent.begin("b", now); !ok {
+		t.Error("неудачный запрос не забыт")
+	}
+	// Запрос, не попавший в очередь, тоже
+	recent.begin("c", now)
+	recent.forget("c")
+	if _, ok := recent.begin("c", now); !ok {
+		t.Error("не начавшийся запрос не забыт")
+	}
+}
+
+func TestRecentGenerationsBounded(t *testing.T) {
+	recent := newRecentGenerations(time.Minute, 3)
+	now := time.Now()
+	for _, key := range []string{"a", "b", "c", "d"} {
+		recent.begin(key, now)
+	}
+	// Самый старый запрос вытеснен, остальные помнятся
+	if recent.order.Len() != 3 || len(recent.entries) != 3 {
+		t.Fatalf("запомнено %d запросов", recent.order.Len())
+	}
+	if _, ok := recent.begin("a", now); !ok {
+		t.Error("вытесненный запрос помнится")
+	}
+	if _, ok := recent.begin("d", now); ok {
+		t.Error("свежий запрос забыт")
+	}
+}
+
+// blockingNews поиск новостей, который ждет release
+type blockingNews struct {
+	fakeNews
+	started chan struct{}
+	release chan struct{}
+}
+
+func (f *blockingNews) FindRelevantArticles(ctx context.Context, keywords string, maxArticles int, opts news.SearchOptions) ([]news.Article, news.SearchDiagnostics, error) {
+	f.started <- struct{}{}
+	<-f.release
+	return f.fakeNews.FindRelevantArticles(ctx, keywords, maxArticles, opts)
+}
+
+func TestDuplicateGenerateRequests(t *testing.T) {
+	b, fake := newTestBot(t)
+	gpt := newFakeGPT()
+	search := &blockingNews{
+		fakeNews: fakeNews{articles: []news.Article{{Title: "Ставка ЦБ", URL: "https://example.com/rate", Source: "РБК"}}},
+		started:  make(chan struct{}, 2),
+		release:  make(chan struct{}),
+	}
+	useGenerator(b, gpt, search)
+	runBot(t, b)
+
+	fake.Feed(commandUpdate(1, "/generate ставка ЦБ"))
+	<-search.started
+
+	// Тот же запрос, пока первый выполняется
+	fake.Feed(commandUpdate(1, "/generate  Ставка цб"))
+	waitFor(t, "ответ на повтор", func() bool { return sentText(fake, 1, i18n.T("ru", "generate.duplicate_running")) })
+	// Другой запрос ставится в очередь как обычно
+	fake.Feed(commandUpdate(1, "/generate курс рубля"))
+
+	close(search.release)
+	waitFor(t, "две генерации", func() bool { return balance(b, 1) == testTrialGenerations-2 })
+	if written := gpt.written.Load(); written != 2 {
+		t.Errorf("написано постов: %d, ожидалось 2", written)
+	}
+
+	// Повтор только что законченного запроса получает ссылку на готовый пост
+	key := generationDedupKey(1, "ru", "ставка цб", false)
+	waitFor(t, "конец генерации", func() bool {
+		previous, ok := b.recent.begin(key, time.Now())
+		return !ok && !previous.running
+	})
+	fake.Feed(commandUpdate(1, "/generate ставка цб"))
+	done := i18n.T("ru", "generate.duplicate_done")
+	waitFor(t, "ссылка на пост", func() bool { return sentText(fake, 1, done) })
+	for _, sent := range fake.SentTo(1) {
+		if reply, ok := sent.Config.(tgbotapi.MessageConfig); ok && sent.Text == done && reply.ReplyToMessageID == 0 {
+			t.Error("ответ не ссылается на готовый пост")
+		}
+	}
+	if got := balance(b, 1); got != testTrialGenerations-2 {
+		t.Errorf("доступно %d, ожидалось %d", got, testTrialGenerations-2)
+	}
+}
@@ -0,0 +1,127 @@
+package bot
+
+import (
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// chatSendLocks число блокировок отправки; чаты распределяются по ним по остатку от деления
+const chatSendLocks = 64
+
+// chatTask обработчик обновления, name — для журнала паник
+type chatTask struct {
+	name string
+	run  func()
+}
+
+// chatDispatcher обрабатывает обновления одного чата по очереди, а разных чатов —
+// параллельно. Обработчик чата запускается при первом обновлении и завершается,
+// когда очередь чата опустела, поэтому молчащие чаты ничего не занимают.
+type chatDispatcher struct {
+	mu sync.Mutex
+	// queues очереди чатов, у которых сейчас есть обработчик
+	queues map[int64][]chatTask
+}
+
+func newChatDispatcher() *chatDispatcher {
+	return &chatDispatcher{queues: make(map[int64][]chatTask)}
+}
+
+// dispatch ставит обработчик обновления в очередь чата. Так ответы на /generate и
+// /balance, отправленные подряд, приходят в том же порядке, а проверка ожидания отзыва
+// видит результат предыдущей команды.
+func (b *Bot) dispatch(chatID int64, name string, run func()) {
+	d := b.dispatcher
+	d.mu.Lock()
+	queue, running := d.queues[chatID]
+	d.queues[chatID] = append(queue, chatTask{name: name, run: run})
+	d.mu.Unlock()
+
+	if !running {
+		b.safeGo("chat", chatID, func() { b.runChat(chatID) })
+	}
+}
+
+// runChat выполняет очередь чата, пока она не опустеет
+func (b *Bot) runChat(chatID int64) {
+	d := b.dispatcher
+	for {
+		d.mu.Lock()
+		queue := d.queues[chatID]
+		if len(queue) == 0 {
+			delete(d.queues, chatID)
+			d.mu.Unlock()
+			return
+		}
+		task := queue[0]
+		queue[0] = chatTask{}
+		d.queues[chatID] = queue[1:]
+		d.mu.Unlock()
+
+		b.runChatTask(chatID, task)
+	}
+}
+
+// runChatTask выполняет один обработчик; паника не останавливает очередь чата
+func (b *Bot) runChatTask(chatID int64, task chatTask) {
+	defer b.recoverPanic(task.name, chatID)
+	task.run()
+}
+
+// orderedSender не дает отправлять в один чат одновременно: сообщения и правки
+// из обработчиков команд и из генерации уходят в Telegram в порядке вызова, а не
+// в том, в котором завершились параллельные запросы
+type orderedSender struct {
+	TelegramSender
+	locks [chatSendLocks]sync.Mutex
+}
+
+func newOrderedSender(api TelegramSender) *orderedSender {
+	return &orderedSender{TelegramSender: api}
+}
+
+func (s *orderedSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if lock := s.lock(c); lock != nil {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	return s.TelegramSender.Send(c)
+}
+
+func (s *orderedSender) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	if lock := s.lock(c); lock != nil {
+		lock.Lock()
+		defer lock.Unlock()
+	}
+	return s.TelegramSender.Request(c)
+}
+
+// lock возвращает блокировку чата запроса или nil для запросов без чата (ответ на callback)
+func (s *orderedSender) lock(c tgbotapi.Chattable) *sync.Mutex {
+	var chatID int64
+	switch config := c.(type) {
+	case tgbotapi.MessageConfig:
+		chatID = config.ChatID
+	case tgbotapi.PhotoConfig:
+		chatID = config.ChatID
+	case tgbotapi.DocumentConfig:
+		chatID = config.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		chatID = config.ChatID
+	case tgbotapi.EditMessageCaptionConfig:
+		chatID = config.ChatID
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		chatID = config.ChatID
+	case tgbotapi.DeleteMessageConfig:
+		chatID = config.ChatID
+	case tgbotapi.ChatActionConfig:
+		chatID = config.ChatID
+	default:
+		return nil
+	}
+	if chatID == 0 {
+		return nil
+	}
+	return &s.locks[uint64(chatID)%chatSendLocks]
+}
@@ -0,0 +1,182 @@
+package bot
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/testutil"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sentTexts тексты сообщений в чат chatID в порядке отправки
+func sentTexts(fake *testutil.FakeTelegram, chatID int64) []string {
+	var result []string
+	for _, sent := range fake.SentTo(chatID) {
+		result = append(result, sent.Text)
+	}
+	return result
+}
+
+func TestRepliesInUpdateOrder(t *testing.T) {
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	// Команды с разными ответами вперемешку, подряд и без пауз
+	var want []string
+	for i := 0; i < 30; i++ {
+		switch i % 3 {
+		case 0:
+			fake.Feed(commandUpdate(1, "/balance"))
+			want = append(want, i18n.T("ru", "balance.text", 3, "", 0))
+		case 1:
+			fake.Feed(commandUpdate(1, "/nosuchcommand"))
+			want = append(want, i18n.T("ru", "command.unknown"))
+		case 2:
+			fake.Feed(commandUpdate(1, "просто текст"))
+			want = append(want, i18n.T("ru", "message.not_command"))
+		}
+	}
+	waitFor(t, "все ответы", func() bool { return len(fake.SentTo(1)) == len(want) })
+
+	if got := sentTexts(fake, 1); !slices.Equal(got, want) {
+		t.Errorf("ответы не по порядку обновлений:\n%q", got)
+	}
+}
+
+func TestDispatchOrdersChatAndRunsChatsConcurrently(t *testing.T) {
+	b, fake := newTestBot(t)
+
+	// Задачи чата 1 завершаются за разное время, но выполняются по очереди
+	var running, maxRunning atomic.Int32
+	for i := 0; i < 20; i++ {
+		b.dispatch(1, "test", func() {
+			if current := running.Add(1); current > maxRunning.Load() {
+				maxRunning.Store(current)
+			}
+			time.Sleep(time.Duration(20-i) * time.Millisecond / 4)
+			b.sendMessage(1, strconv.Itoa(i))
+			running.Add(-1)
+		})
+	}
+
+	// Чат 2 не ждет, пока опустеет очередь чата 1
+	done := make(chan struct{})
+	b.dispatch(2, "test", func() { close(done) })
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("чат 2 ждал очереди чата 1")
+	}
+	if len(fake.SentTo(1)) == 20 {
+		t.Error("очередь чата 1 выполнилась раньше чата 2")
+	}
+
+	waitFor(t, "очередь чата 1", func() bool { return len(fake.SentTo(1)) == 20 })
+	for i, text := range sentTexts(fake, 1) {
+		if text != strconv.Itoa(i) {
+			t.Fatalf("ответ %d: %q", i, text)
+		}
+	}
+	if got := maxRunning.Load(); got != 1 {
+		t.Errorf("одновременно выполнялось %d задач чата", got)
+	}
+
+	// Обработчик опустевшей очереди завершается и ничего не занимает
+	waitFor(t, "завершение обработчиков", func() bool {
+		b.dispatcher.mu.Lock()
+		defer b.dispatcher.mu.Unlock()
+		return len(b.dispatcher.queues) == 0
+	})
+}
+
+func TestDispatchPanicKeepsChatQueue(t *testing.T) {
+	b, fake := newTestBot(t)
+
+	b.dispatch(1, "boom", func() { panic("сбой") })
+	b.dispatch(1, "next", func() { b.sendMessage(1, "после паники") })
+
+	waitFor(t, "следующая задача", func() bool { return fake.LastText(1) == "после паники" })
+	if got := sentTexts(fake, 1); len(got) != 2 || got[0] != i18n.T("ru", "error.internal") {
+		t.Errorf("сообщения: %q", got)
+	}
+}
+
+// slowSender заглушка отправки, которая считает одновременные отправки в каждый чат
+type slowSender struct {
+	*testutil.FakeTelegram
+	mu      sync.Mutex
+	inside  map[int64]int
+	maxChat int
+	maxAll  int
+}
+
+func (s *slowSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	chatID := describeChat(c)
+	s.mu.Lock()
+	s.inside[chatID]++
+	s.maxChat = max(s.maxChat, s.inside[chatID])
+	total := 0
+	for _, n := range s.inside {
+		total += n
+	}
+	s.maxAll = max(s.maxAll, total)
+	s.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	s.mu.Lock()
+	s.inside[chatID]--
+	s.mu.Unlock()
+	return s.FakeTelegram.Send(c)
+}
+
+// describeChat чат сообщения или правки
+func describeChat(c tgbotapi.Chattable) int64 {
+	switch config := c.(type) {
+	case tgbotapi.MessageConfig:
+		return config.ChatID
+	case tgbotapi.EditMessageTextConfig:
+		return config.ChatID
+	}
+	return 0
+}
+
+func TestOrderedSenderSerializesChat(t *testing.T) {
+	inner := &slowSender{FakeTelegram: testutil.NewFakeTelegram(1), inside: make(map[int64]int)}
+	sender := newOrderedSender(inner)
+
+	// Сообщения и правки из обработчика и из генерации идут в один чат одновременно;
+	// чат chatSendLocks+1 делит блокировку с чатом 1, чат 2 — нет
+	var wg sync.WaitGroup
+	for _, chatID := range []int64{1, 2, chatSendLocks + 1} {
+		for i := 0; i < 5; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				sender.Send(tgbotapi.NewMessage(chatID, fmt.Sprint(i)))
+			}()
+			go func() {
+				defer wg.Done()
+				sender.Send(tgbotapi.NewEditMessageText(chatID, 1, fmt.Sprint(i)))
+			}()
+		}
+	}
+	wg.Wait()
+
+	if inner.maxChat != 1 {
+		t.Errorf("одновременно в один чат отправлялось %d запросов", inner.maxChat)
+	}
+	if inner.maxAll < 2 {
+		t.Error("разные чаты отправлялись по очереди")
+	}
+	if sent := len(inner.Sent()); sent != 30 {
+		t.Errorf("отправлено %d из 30", sent)
+	}
+}
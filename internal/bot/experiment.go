@@ -0,0 +1,184 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// runningExperiment идущий эксперимент с загруженными шаблонами вариантов
+type runningExperiment struct {
+	name     string
+	variants map[string]ai.PromptVariant
+}
+
+// loadExperiment загружает шаблоны вариантов эксперимента
+func loadExperiment(experiment database.Experiment) (*runningExperiment, error) {
+	running := &runningExperiment{name: experiment.Name, variants: make(map[string]ai.PromptVariant, 2)}
+	for variant, path := range map[string]string{database.VariantA: experiment.TemplateA, database.VariantB: experiment.TemplateB} {
+		prompt, err := ai.LoadPromptVariant(variant, path)
+		if err != nil {
+			return nil, fmt.Errorf("вариант %s: %w", variant, err)
+		}
+		running.variants[variant] = prompt
+	}
+	return running, nil
+}
+
+// restoreExperiment после перезапуска продолжает эксперимент, сохраненный в базе. Если
+// шаблоны не загрузились, генерации идут с обычным промптом и в эксперимент не попадают.
+func (b *Bot) restoreExperiment() {
+	experiment := b.db.Experiment()
+	if !experiment.Active() {
+		return
+	}
+	running, err := loadExperiment(experiment)
+	if err != nil {
+		log.Printf("[EXPERIMENT] ❌ Эксперимент %s не восстановлен: %v", experiment.Name, err)
+		b.notifyAdmin(fmt.Sprintf("⚠️ Эксперимент %s не восстановлен после перезапуска, генерации идут с обычным промптом:\n%v",
+			experiment.Name, err), false, "experiment_restore:"+experiment.Name)
+		return
+	}
+	b.setExperiment(running)
+	log.Printf("[EXPERIMENT] Эксперимент %s продолжается", experiment.Name)
+}
+
+// setExperiment задает идущий эксперимент; nil — эксперимента нет
+func (b *Bot) setExperiment(running *runningExperiment) {
+	b.experimentMu.Lock()
+	defer b.experimentMu.Unlock()
+	b.experiment = running
+}
+
+// experimentAssignment эксперимент и вариант генерации
+type experimentAssignment struct {
+	experiment, variant string
+}
+
+type experimentKey struct{}
+
+// withExperiment назначает генерации пользователя вариант идущего эксперимента
+func (b *Bot) withExperiment(ctx context.Context, userID int64) context.Context {
+	b.experimentMu.RLock()
+	running := b.experiment
+	b.experimentMu.RUnlock()
+	if running == nil {
+		return ctx
+	}
+
+	variant := database.ExperimentVariant(running.name, userID)
+	ctx = ai.WithPromptVariant(ctx, running.variants[variant])
+	return context.WithValue(ctx, experimentKey{}, experimentAssignment{experiment: running.name, variant: variant})
+}
+
+// recordVariant отмечает записи журнала генерации requestID вариантом эксперимента из ctx
+func (b *Bot) recordVariant(ctx context.Context, requestID string) {
+	if assignment, ok := ctx.Value(experimentKey{}).(experimentAssignment); ok {
+		b.db.SetGenerationVariant(requestID, assignment.experiment, assignment.variant)
+	}
+}
+
+// experimentUsage подсказка по /experiment
+const experimentUsage = "🔐 Использование:\n" +
+	"/experiment start пароль имя шаблонA шаблонB\n" +
+	"/experiment stop пароль\n" +
+	"/experiment report пароль [имя]\n\n" +
+	"Шаблоны заменяют системный промпт поста по ключевым словам (post_system); " +
+	"относительные пути ищутся в PROMPTS_DIR"
+
+// handleExperiment управляет экспериментом с промптами: /experiment start|stop|report пароль ...
+func (b *Bot) handleExperiment(msg *tgbotapi.Message) {
+	parts := strings.Fields(msg.CommandArguments())
+	if len(parts) < 2 {
+		b.sendMessage(msg.Chat.ID, experimentUsage)
+		return
+	}
+
+	if parts[1] != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	switch args := parts[2:]; parts[0] {
+	case "start":
+		if len(args) != 3 {
+			b.sendMessage(msg.Chat.ID, experimentUsage)
+			return
+		}
+		b.startExperiment(msg.Chat.ID, database.Experiment{Name: args[0], TemplateA: args[1], TemplateB: args[2], StartedAt: time.Now()})
+	case "stop":
+		experiment, err := b.db.StopExperiment(time.Now())
+		if err != nil {
+			b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		b.setExperiment(nil)
+		log.Printf("[EXPERIMENT] Эксперимент %s остановлен, генерации идут с обычным промптом", experiment.Name)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("⏹ Эксперимент %s остановлен, все генерации идут с обычным промптом\n\n%s",
+			experiment.Name, b.formatExperimentReport(experiment.Name)))
+	case "report":
+		name := b.db.Experiment().Name
+		if len(args) > 0 {
+			name = args[0]
+		}
+		if name == "" {
+			b.sendMessage(msg.Chat.ID, "📭 Экспериментов еще не было")
+			return
+		}
+		b.sendMessage(msg.Chat.ID, b.formatExperimentReport(name))
+	default:
+		b.sendMessage(msg.Chat.ID, experimentUsage)
+	}
+}
+
+// startExperiment проверяет шаблоны вариантов и запускает эксперимент
+func (b *Bot) startExperiment(chatID int64, experiment database.Experiment) {
+	running, err := loadExperiment(experiment)
+	if err != nil {
+		log.Printf("[EXPERIMENT] ❌ Шаблоны эксперимента %s не загружены: %v", experiment.Name, err)
+		b.sendMessage(chatID, fmt.Sprintf("❌ Эксперимент не запущен:\n%v", err))
+		return
+	}
+	if err := b.db.StartExperiment(experiment); err != nil {
+		b.sendMessage(chatID, fmt.Sprintf("❌ Эксперимент не запущен: %v", err))
+		return
+	}
+	b.setExperiment(running)
+
+	log.Printf("[EXPERIMENT] ✅ Эксперимент %s запущен", experiment.Name)
+	b.sendMessage(chatID, fmt.Sprintf("🧪 Эксперимент %s запущен\nA: %s\nB: %s\n\n"+
+		"Пользователи распределяются по вариантам поровну и остаются в своем варианте до остановки",
+		experiment.Name, experiment.TemplateA, experiment.TemplateB))
+}
+
+// formatExperimentReport показатели вариантов эксперимента name
+func (b *Bot) formatExperimentReport(name string) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "🧪 Эксперимент %s", name)
+	if experiment := b.db.Experiment(); experiment.Name == name {
+		if experiment.Active() {
+			fmt.Fprintf(&text, " (идет с %s)", experiment.StartedAt.Format("02.01.2006 15:04"))
+		} else {
+			fmt.Fprintf(&text, " (%s — %s)", experiment.StartedAt.Format("02.01.2006 15:04"), experiment.StoppedAt.Format("02.01.2006 15:04"))
+		}
+	}
+
+	for _, report := range b.db.ExperimentReport(name) {
+		fmt.Fprintf(&text, "\n\nВариант %s — генераций: %d\n", report.Variant, report.Generations)
+		fmt.Fprintf(&text, "✅ Успешных: %.1f%% (%d)\n", report.SuccessRate()*100, report.Succeeded)
+		fmt.Fprintf(&text, "🙅 Отказов модели: %.1f%% (%d)\n", report.RefusalRate()*100, report.Refused)
+		if report.Ratings > 0 {
+			fmt.Fprintf(&text, "⭐️ Средняя оценка: %.2f (%d)", report.AverageRating, report.Ratings)
+		} else {
+			text.WriteString("⭐️ Оценок нет")
+		}
+	}
+	return text.String()
+}
@@ -0,0 +1,115 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/i18n"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// followUpWindow сколько после отправки поста обычное сообщение-вопрос считается
+	// вопросом о его статье
+	followUpWindow = 15 * time.Minute
+	// maxFollowUpQuestions сколько вопросов можно задать об одной статье: ответы бесплатны
+	maxFollowUpQuestions = 5
+	// maxFollowUpLength вопросы длиннее — скорее текст для /rewrite, чем вопрос
+	maxFollowUpLength = 300
+)
+
+// questionStarts начала вопросов, которые пишут без вопросительного знака
+var questionStarts = []string{
+	"когда", "где", "кто", "что", "чем", "почему", "зачем", "как", "сколько", "какой", "какая",
+	"какое", "какие", "чей", "откуда", "куда", "правда ли", "дай ссылку", "ссылк", "источник", "оригинал",
+	"when", "where", "who", "what", "why", "how", "which", "is it", "link", "source", "original",
+}
+
+// looksLikeQuestion похож ли текст на вопрос: со знаком вопроса или начинается как вопрос
+func looksLikeQuestion(text string) bool {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" || len([]rune(text)) > maxFollowUpLength {
+		return false
+	}
+	if strings.Contains(text, "?") {
+		return true
+	}
+	for _, start := range questionStarts {
+		if strings.HasPrefix(text, start) {
+			return true
+		}
+	}
+	return false
+}
+
+// followUpPost пост, о статье которого можно спросить в момент now: отправлен не раньше
+// followUpWindow, написан по статье и лимит вопросов не исчерпан. Вызывается под postsMu.
+func (b *Bot) followUpPost(chatID int64, now time.Time) (*deliveredPost, bool) {
+	delivered, ok := b.posts[chatID]
+	if !ok || delivered.article.URL == "" || now.Sub(delivered.delivered) > followUpWindow ||
+		delivered.questions >= maxFollowUpQuestions {
+		return nil, false
+	}
+	return delivered, true
+}
+
+// isFollowUpQuestion сообщение — вопрос о статье недавно отправленного поста
+func (b *Bot) isFollowUpQuestion(msg *tgbotapi.Message) bool {
+	if !looksLikeQuestion(msg.Text) {
+		return false
+	}
+	b.postsMu.Lock()
+	defer b.postsMu.Unlock()
+	_, ok := b.followUpPost(msg.Chat.ID, time.Now())
+	return ok
+}
+
+// handleFollowUpQuestion отвечает на вопрос о статье последнего поста строго по ее тексту.
+// Генерация не списывается: это уточнение к уже оплаченному посту.
+func (b *Bot) handleFollowUpQuestion(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	lang := b.lang(chatID)
+
+	b.postsMu.Lock()
+	delivered, ok := b.followUpPost(chatID, time.Now())
+	if ok {
+		delivered.questions++
+	}
+	b.postsMu.Unlock()
+	if !ok {
+		b.sendMessage(chatID, i18n.T(lang, "message.not_command"))
+		return
+	}
+
+	if ai.CircuitOpen() {
+		b.sendMessage(chatID, i18n.T(lang, "ai.circuit_open"))
+		return
+	}
+
+	log.Printf("[FOLLOWUP] Вопрос от %d о статье %s", chatID, delivered.article.URL)
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.GenerationTimeout)
+	defer cancel()
+	ctx = ai.WithModelTier(ctx, ai.TierLite)
+	ctx = ai.WithAuditUser(ctx, chatID)
+	ctx = ai.WithAuditArticle(ctx, delivered.article.URL)
+
+	answer, err := b.gptClient.AnswerQuestion(ctx, msg.Text, delivered.article)
+	if err != nil {
+		log.Printf("[FOLLOWUP] ❌ Ошибка ответа на вопрос %d: %v", chatID, err)
+		reportAIError("followup", chatID, err)
+		b.sendMessage(chatID, i18n.T(lang, "followup.failed"))
+		return
+	}
+
+	reply := tgbotapi.NewMessage(chatID, i18n.T(lang, "followup.answer", answer))
+	reply.ReplyToMessageID = msg.MessageID
+	reply.AllowSendingWithoutReply = true
+	reply.DisableWebPagePreview = true
+	if _, err := b.api.Send(reply); err != nil {
+		log.Printf("[ERROR] Ошибка отправки сообщения в чат %d: %v", chatID, err)
+	}
+}
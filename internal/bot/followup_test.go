@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/news"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// answeringGPT модель, отвечающая на вопросы о статье
+type answeringGPT struct {
+	*fakeGPT
+	mu       sync.Mutex
+	articles []ai.SourceArticle
+}
+
+func (f *answeringGPT) AnswerQuestion(ctx context.Context, question string, article ai.SourceArticle) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.articles = append(f.articles, article)
+	return "Ставку сохранили 25 октября.", nil
+}
+
+func TestLooksLikeQuestion(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{"а когда это случилось?", true},
+		{"Когда это было", true},
+		{"дай ссылку на оригинал", true},
+		{"Where did this happen", true},
+		{"спасибо, отличный пост", false},
+		{"   ", false},
+		// Длинный текст скорее для /rewrite, чем вопрос
+		{string(make([]rune, maxFollowUpLength)) + "?", false},
+	}
+	for _, tt := range tests {
+		if got := looksLikeQuestion(tt.text); got != tt.want {
+			t.Errorf("looksLikeQuestion(%.30q) = %t, ожидалось %t", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFollowUpQuestion(t *testing.T) {
+	answer := i18n.T("ru", "followup.answer", "Ставку сохранили 25 октября.")
+	tests := []struct {
+		name string
+		// prepare меняет отправленный пост перед вопросом
+		prepare  func(delivered *deliveredPost)
+		question string
+		want     string
+	}{
+		{"вопрос сразу после поста", nil, "а когда это случилось?", answer},
+		{"в конце окна", func(delivered *deliveredPost) {
+			delivered.delivered = time.Now().Add(-followUpWindow + time.Minute)
+		}, "дай ссылку на оригинал", answer},
+		// Через 15 минут вопрос уже не относится к посту
+		{"окно истекло", func(delivered *deliveredPost) {
+			delivered.delivered = time.Now().Add(-followUpWindow - time.Second)
+		}, "а когда это случилось?", i18n.T("ru", "message.not_command")},
+		{"лимит вопросов", func(delivered *deliveredPost) {
+			delivered.questions = maxFollowUpQuestions
+		}, "а когда это случилось?", i18n.T("ru", "message.not_command")},
+		{"пост без статьи", func(delivered *deliveredPost) {
+			delivered.article = ai.SourceArticle{Content: "Текст пользователя"}
+		}, "а когда это случилось?", i18n.T("ru", "message.not_command")},
+		{"не вопрос", nil, "спасибо", i18n.T("ru", "message.not_command")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			gpt := &answeringGPT{fakeGPT: newFakeGPT()}
+			article := news.Article{Title: "Ставка ЦБ", URL: "https://example.com/rate", Source: "РБК"}
+			useGenerator(b, gpt.fakeGPT, &fakeNews{articles: []news.Article{article}})
+			b.gptClient = gpt
+			runBot(t, b)
+
+			fake.Feed(commandUpdate(1, "/generate ставка цб"))
+			waitFor(t, "пост", func() bool { return balance(b, 1) == testTrialGenerations-1 })
+			b.postsMu.Lock()
+			if tt.prepare != nil {
+				tt.prepare(b.posts[1])
+			}
+			b.postsMu.Unlock()
+
+			question := commandUpdate(1, tt.question)
+			question.Message.MessageID = 42
+			fake.Feed(question)
+			waitFor(t, "ответ", func() bool { return sentText(fake, 1, tt.want) })
+			if tt.want != answer {
+				if sentText(fake, 1, answer) {
+					t.Error("ответ модели на сообщение вне окна вопросов")
+				}
+				return
+			}
+
+			sent := fake.SentTo(1)
+			reply := sent[len(sent)-1]
+			// Ответ по статье поста, без списания генерации
+			if gpt.articles[0].URL != article.URL {
+				t.Errorf("вопрос задан по статье %+v", gpt.articles[0])
+			}
+			if balance(b, 1) != testTrialGenerations-1 {
+				t.Errorf("за ответ списана генерация: доступно %d", balance(b, 1))
+			}
+			if config, _ := reply.Config.(tgbotapi.MessageConfig); config.ReplyToMessageID != 42 {
+				t.Error("ответ не привязан к вопросу")
+			}
+		})
+	}
+}
+
+func TestFollowUpQuestionLimit(t *testing.T) {
+	b, fake := newTestBot(t)
+	gpt := &answeringGPT{fakeGPT: newFakeGPT()}
+	useGenerator(b, gpt.fakeGPT, &fakeNews{articles: []news.Article{{Title: "Ставка ЦБ", URL: "https://example.com/rate"}}})
+	b.gptClient = gpt
+	runBot(t, b)
+
+	fake.Feed(commandUpdate(1, "/generate ставка цб"))
+	waitFor(t, "пост", func() bool { return balance(b, 1) == testTrialGenerations-1 })
+	waitGenerations(t, b)
+	for i := 0; i <= maxFollowUpQuestions; i++ {
+		fake.Feed(commandUpdate(1, "когда это было?"))
+	}
+	waitFor(t, "ответы", func() bool { return sentText(fake, 1, i18n.T("ru", "message.not_command")) })
+	waitFor(t, "все ответы модели", func() bool {
+		gpt.mu.Lock()
+		defer gpt.mu.Unlock()
+		return len(gpt.articles) == maxFollowUpQuestions
+	})
+
+	// Новый пост открывает новое окно вопросов
+	fake.Feed(commandUpdate(1, "/generate ставка цб снова"))
+	waitFor(t, "второй пост", func() bool { return balance(b, 1) == testTrialGenerations-2 })
+	fake.Feed(commandUpdate(1, "когда это было?"))
+	waitFor(t, "ответ о новом посте", func() bool {
+		gpt.mu.Lock()
+		defer gpt.mu.Unlock()
+		return len(gpt.articles) == maxFollowUpQuestions+1
+	})
+}
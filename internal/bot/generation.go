@@ -0,0 +1,162 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/events"
+	"AIGenerator/internal/generator"
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/news"
+)
+
+// generationRequest чем генерация по запросу отличается от генерации по ссылке
+// в сообщениях пользователю и журнале
+type generationRequest struct {
+	// kind префикс текстов (generate или generate_url) и операция в отчетах об ошибках
+	kind string
+	// subject тема или сокращенная ссылка в сообщениях
+	subject string
+	// topic тема в журнале генераций
+	topic string
+	// firstStep первый шаг в сообщении прогресса
+	firstStep string
+	// stepAI шаг перед написанием поста; found — сколько нашлось статей
+	stepAI func(found int) string
+	// fetchFailed итог, когда не удалось найти новости или загрузить страницу
+	fetchFailed string
+}
+
+// generationProgress показывает ход генерации правками сообщения прогресса и отмечает
+// этапы в хронологии генерации. Реализует generator.Progress.
+type generationProgress struct {
+	b       *Bot
+	ctx     context.Context
+	userID  int64
+	lang    string
+	request generationRequest
+	trace   *generationTrace
+
+	// message сообщение прогресса; nil, пока не начался поиск новостей или загрузка страницы
+	message *progressMessage
+	found   int
+	// finish завершает учет генерации; nil, пока генерация не зарезервирована
+	finish func(outcome string)
+	// streamDone закрывается, когда показан ход написания поста
+	streamDone <-chan struct{}
+}
+
+func (b *Bot) newGenerationProgress(ctx context.Context, userID int64, request generationRequest) *generationProgress {
+	return &generationProgress{b: b, ctx: ctx, userID: userID, lang: b.lang(userID), request: request, trace: traceFrom(ctx)}
+}
+
+// Stage отмечает этап в хронологии и показывает шаг пользователю. Учет генерации
+// начинается после резерва, с первого этапа после проверки баланса.
+func (p *generationProgress) Stage(stage generator.Stage) {
+	p.trace.stage(string(stage))
+	if stage == generator.StageBalance {
+		return
+	}
+	if p.finish == nil {
+		p.finish = p.b.trackGeneration(p.ctx, p.userID, p.request.topic)
+	}
+
+	switch stage {
+	case generator.StageFetch:
+		p.message = p.b.startProgress(p.ctx, p.userID, p.request.firstStep)
+		p.message.update(i18n.T(p.lang, p.request.kind+".step_analyze", p.request.subject))
+	case generator.StageAI:
+		p.message.update(p.request.stepAI(p.found))
+	}
+}
+
+// Searched отдает этапу ранжирования время поиска сверх загрузки статей
+func (p *generationProgress) Searched(found int, elapsed time.Duration, diag news.SearchDiagnostics) {
+	p.found = found
+	p.trace.split(stageRank, elapsed-diag.FetchDuration)
+}
+
+// Writing показывает пост по мере написания
+func (p *generationProgress) Writing() chan<- string {
+	partial := make(chan string, 1)
+	p.streamDone = p.b.streamProgress(p.message, i18n.T(p.lang, p.request.kind+".step_writing", p.request.subject), partial)
+	return partial
+}
+
+// wait ждет, пока покажется ход написания поста: итог генерации должен прийти после него
+func (p *generationProgress) wait() {
+	if p.streamDone != nil {
+		<-p.streamDone
+	}
+}
+
+// finished завершает учет генерации с исходом outcome
+func (p *generationProgress) finished(outcome string) {
+	if p.finish != nil {
+		p.finish(outcome)
+	}
+}
+
+// generationFailed сообщает пользователю, почему генерация не удалась, и записывает
+// в журнал отклоненные темы и отказы модели. Возвращает исход генерации для статистики.
+func (b *Bot) generationFailed(ctx context.Context, progress *generationProgress, err error) string {
+	userID, lang, request := progress.userID, progress.lang, progress.request
+	requestID := traceFrom(ctx).ID()
+	dry, isDryRun := dryRunFrom(ctx)
+
+	var (
+		queryErr *generator.QueryError
+		rejected *generator.TopicRejectedError
+		noNews   *generator.NoNewsError
+		refused  *generator.RefusedError
+		stageErr *generator.StageError
+	)
+	switch {
+	case errors.As(err, &queryErr):
+		log.Printf("[GENERATE] ❌ Ошибка разбора запроса от %d: %v", userID, queryErr.Err)
+		b.sendMessage(userID, i18n.T(lang, "generate.query_error", queryErr.Err))
+	case errors.Is(err, generator.ErrNoGenerations):
+		b.sendMessage(userID, i18n.T(lang, "generations.exhausted"))
+	case errors.As(err, &rejected):
+		check := rejected.Check
+		if isDryRun {
+			b.db.AddGenerationOutcome(dry.target, request.topic, database.OutcomeDryRun,
+				database.OutcomeRejected+": "+check.Category+": "+check.Reason, requestID)
+		} else {
+			b.db.AddGenerationOutcome(userID, request.topic, database.OutcomeRejected, check.Category+": "+check.Reason, requestID)
+		}
+		b.sendMessage(userID, i18n.T(lang, "generate.topic_rejected", request.subject, check.Reason))
+		return events.OutcomeRejected
+	case errors.As(err, &stageErr) && ctx.Err() != nil:
+		b.failGeneration(ctx, progress.message, i18n.T(lang, "ai.deadline_exceeded"))
+	case errors.Is(err, news.ErrNoArticlesInWindow):
+		progress.message.finish(i18n.T(lang, "generate.no_news_in_window", request.subject))
+	case errors.As(err, &noNews):
+		progress.message.finish(i18n.T(lang, "generate.no_news", request.subject, describeNoNews(lang, noNews.Diagnostics)))
+	case errors.As(err, &stageErr) && stageErr.Stage == generator.StageFetch:
+		b.failGeneration(ctx, progress.message, request.fetchFailed)
+	case errors.As(err, &refused):
+		if !isDryRun {
+			b.db.AddGenerationOutcome(userID, request.topic, database.OutcomeRefused, refused.Reason, requestID)
+		}
+		progress.message.finish(i18n.T(lang, request.kind+".refused", request.subject))
+	case errors.Is(err, generator.ErrEmptyPost):
+		b.failGeneration(ctx, progress.message, i18n.T(lang, request.kind+".failed", request.subject, i18n.T(lang, "reason.empty_post")))
+	case errors.Is(err, ai.ErrCircuitOpen):
+		progress.message.finish(i18n.T(lang, "ai.circuit_open"))
+	default:
+		reportAIError(request.kind, userID, err)
+		b.failGeneration(ctx, progress.message, i18n.T(lang, request.kind+".failed", request.subject, aiFailureReason(lang, err)))
+	}
+	return events.OutcomeFailed
+}
+
+// fetchPage загружает страницу по ссылке пользователя для генерации
+func (b *Bot) fetchPage(ctx context.Context, url string) (generator.Page, error) {
+	title, content, image, err := b.fetchWebContent(ctx, url)
+	return generator.Page{Title: title, Content: content, ImageURL: image}, err
+}
@@ -0,0 +1,270 @@
+package bot
+
+import (
+	"context"
+	"encoding/base64"
+	"log"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/richtext"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// inlineRecentPosts сколько последних постов пользователя предлагает inline-режим
+	inlineRecentPosts = 10
+	// minInlineTopic с какой длины запрос считается темой нового поста, а не фильтром
+	minInlineTopic = 3
+	// inlinePostPrefix запрос кнопки «Вставить в чат»: за префиксом номер поста в кэше
+	inlinePostPrefix = "post:"
+	// inlineGeneratePayload параметр /start, с которым inline-режим открывает генерацию;
+	// за префиксом тема в base64
+	inlineGeneratePayload = "gen_"
+	// inlineBuyPayload параметр /start, с которым inline-режим открывает покупку генераций
+	inlineBuyPayload = "buy"
+	// maxStartPayload ограничение Telegram на длину параметра /start
+	maxStartPayload = 64
+	// inlineTitleLength и inlineDescriptionLength сколько символов поста видно в списке результатов
+	inlineTitleLength       = 64
+	inlineDescriptionLength = 120
+)
+
+// recentPost пост из кэша inline-режима
+type recentPost struct {
+	id string
+	// title и description видны в списке результатов, text вставляется в чат
+	title       string
+	description string
+	text        richtext.Text
+}
+
+// recentPosts последние отправленные посты каждого пользователя для inline-режима.
+// Кэш живет в памяти: после перезапуска в inline-режиме видны только новые посты.
+type recentPosts struct {
+	mu     sync.Mutex
+	limit  int
+	byUser map[int64][]recentPost // от новых постов к старым
+}
+
+func newRecentPosts(limit int) *recentPosts {
+	return &recentPosts{limit: limit, byUser: make(map[int64][]recentPost)}
+}
+
+// add запоминает пост, вытесняя самый старый сверх limit
+func (r *recentPosts) add(userID int64, post recentPost) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	posts := append([]recentPost{post}, r.byUser[userID]...)
+	if len(posts) > r.limit {
+		posts = posts[:r.limit]
+	}
+	r.byUser[userID] = posts
+}
+
+// update заменяет текст поста id, например после «Расширить»
+func (r *recentPosts) update(userID int64, id string, text richtext.Text) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.byUser[userID] {
+		if r.byUser[userID][i].id == id {
+			r.byUser[userID][i].text = text
+		}
+	}
+}
+
+// list посты пользователя от новых к старым
+func (r *recentPosts) list(userID int64) []recentPost {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]recentPost(nil), r.byUser[userID]...)
+}
+
+// forget удаляет посты пользователя
+func (r *recentPosts) forget(userID int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.byUser, userID)
+}
+
+// newRecentPost пост для кэша. В списке результатов виден заголовок поста и начало текста;
+// у поста без заголовка заголовком служит первая строка.
+func newRecentPost(id string, post ai.Post, text richtext.Text) recentPost {
+	title, body := post.Heading(), richtext.Markers(post.Body).String()
+	if title == "" {
+		title, body, _ = strings.Cut(strings.TrimSpace(body), "\n")
+	}
+	return recentPost{
+		id:          id,
+		title:       shorten(title, inlineTitleLength),
+		description: shorten(strings.Join(strings.Fields(body), " "), inlineDescriptionLength),
+		text:        text,
+	}
+}
+
+// shorten сокращает строку до limit символов, обозначая обрезку многоточием
+func shorten(s string, limit int) string {
+	if utf8.RuneCountInString(s) <= limit {
+		return s
+	}
+	return string([]rune(s)[:limit-1]) + "…"
+}
+
+type inlineOriginKey struct{}
+
+// withInlineOrigin помечает генерацию, заказанную из inline-режима: под постом
+// появляется кнопка «Вставить в чат»
+func withInlineOrigin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, inlineOriginKey{}, true)
+}
+
+// fromInline заказана ли генерация из inline-режима
+func fromInline(ctx context.Context) bool {
+	inline, _ := ctx.Value(inlineOriginKey{}).(bool)
+	return inline
+}
+
+// inlinePostQuery запрос inline-режима, который находит пост id
+func inlinePostQuery(id string) string {
+	return inlinePostPrefix + id
+}
+
+// handleInlineQuery отвечает на запрос @бот в любом чате. Ответ должен прийти быстро,
+// поэтому генерация здесь не выполняется: результаты — последние посты пользователя,
+// а кнопка над ними открывает чат с ботом и запускает генерацию по теме запроса.
+// Пост приходит в чат с ботом с кнопкой «Вставить в чат».
+func (b *Bot) handleInlineQuery(query *tgbotapi.InlineQuery) {
+	userID := query.From.ID
+	lang := b.lang(userID)
+	if lang == "" {
+		lang = i18n.Detect(query.From.LanguageCode)
+	}
+
+	answer := b.inlineAnswer(userID, lang, strings.TrimSpace(query.Query))
+	answer.InlineQueryID = query.ID
+	if _, err := b.api.Request(answer); err != nil {
+		log.Printf("[INLINE] ❌ Ошибка ответа на inline-запрос %d: %v", userID, err)
+	}
+}
+
+// inlineAnswer собирает ответ на inline-запрос text пользователя userID. Короткий
+// запрос показывает все последние посты, запрос «post:номер» — один пост, а тема
+// от minInlineTopic символов — подходящие посты и предложение сгенерировать новый,
+// если у пользователя есть генерации.
+func (b *Bot) inlineAnswer(userID int64, lang, text string) tgbotapi.InlineConfig {
+	answer := tgbotapi.InlineConfig{IsPersonal: true, Results: []interface{}{}}
+
+	posts := b.inlinePosts.list(userID)
+	if id, ok := strings.CutPrefix(text, inlinePostPrefix); ok {
+		for _, post := range posts {
+			if post.id == id {
+				answer.Results = append(answer.Results, inlineResult(post))
+			}
+		}
+		return answer
+	}
+
+	topic := utf8.RuneCountInString(text) >= minInlineTopic
+	for _, post := range posts {
+		if !topic || strings.Contains(strings.ToLower(post.text.String()), strings.ToLower(text)) {
+			answer.Results = append(answer.Results, inlineResult(post))
+		}
+	}
+
+	switch {
+	case !topic:
+		answer.SwitchPMText = i18n.T(lang, "inline.hint")
+		answer.SwitchPMParameter = "inline"
+	case b.db.GetUser(userID).AvailableGenerations <= 0:
+		answer.SwitchPMText = i18n.T(lang, "inline.no_generations")
+		answer.SwitchPMParameter = inlineBuyPayload
+	default:
+		answer.SwitchPMText = i18n.T(lang, "inline.generate", shorten(text, 32))
+		answer.SwitchPMParameter = inlineGenerateParameter(text)
+	}
+	return answer
+}
+
+// inlineResult результат inline-режима: пост вставляется в чат текстом с разметкой
+func inlineResult(post recentPost) tgbotapi.InlineQueryResultArticle {
+	text := post.text.Truncate(maxSendMessageLength)
+	result := tgbotapi.NewInlineQueryResultArticle(post.id, post.title, text.String())
+	result.Description = post.description
+	result.InputMessageContent = tgbotapi.InputTextMessageContent{
+		Text:                  text.String(),
+		Entities:              text.Entities(),
+		DisableWebPagePreview: true,
+	}
+	return result
+}
+
+// inlineGenerateParameter параметр /start с темой нового поста. Параметр ограничен
+// 64 символами из латиницы, цифр, _ и -, поэтому тема кодируется в base64 и при
+// необходимости сокращается.
+func inlineGenerateParameter(topic string) string {
+	runes := []rune(topic)
+	for base64.RawURLEncoding.EncodedLen(len(string(runes))) > maxStartPayload-len(inlineGeneratePayload) {
+		runes = runes[:len(runes)-1]
+	}
+	return inlineGeneratePayload + base64.RawURLEncoding.EncodeToString([]byte(strings.TrimSpace(string(runes))))
+}
+
+// handleInlineStart выполняет /start, которым inline-режим открыл чат с ботом.
+// Генерация платная, а ссылку с параметром может прислать кто угодно, поэтому тема
+// сначала показывается с кнопкой «Сгенерировать», и генерацию запускает только она.
+// Возвращает false, если параметра inline-режима нет и нужно обычное приветствие.
+func (b *Bot) handleInlineStart(msg *tgbotapi.Message) bool {
+	payload := msg.CommandArguments()
+	if payload == inlineBuyPayload {
+		b.handleBuy(msg)
+		return true
+	}
+	if !strings.HasPrefix(payload, inlineGeneratePayload) {
+		return false
+	}
+	keywords, ok := inlineTopic(payload)
+	if !ok {
+		log.Printf("[INLINE] ⚠️ Неверный параметр генерации от %d: %q", msg.Chat.ID, payload)
+		return false
+	}
+
+	// Параметр /start не длиннее 64 байт, поэтому целиком помещается в данные кнопки
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.t(msg.Chat.ID, "inline.confirm_button"), payload),
+	))
+	b.sendMessageWithKeyboard(msg.Chat.ID, b.t(msg.Chat.ID, "inline.confirm", keywords), keyboard)
+	return true
+}
+
+// handleInlineGenerateCallback запускает генерацию, подтвержденную кнопкой «Сгенерировать»
+func (b *Bot) handleInlineGenerateCallback(callback *tgbotapi.CallbackQuery) {
+	userID, messageID := callback.Message.Chat.ID, callback.Message.MessageID
+	keywords, ok := inlineTopic(callback.Data)
+	if !ok {
+		log.Printf("[INLINE] ⚠️ Неверные данные кнопки генерации от %d: %q", userID, callback.Data)
+		return
+	}
+
+	log.Printf("[INLINE] Пользователь %d заказал пост из inline-режима: %s", userID, keywords)
+	b.editMessage(userID, messageID, b.t(userID, "inline.generating", keywords))
+	b.startGeneration(callback.Message, keywords, true)
+}
+
+// inlineTopic тема из параметра генерации inline-режима
+func inlineTopic(payload string) (string, bool) {
+	encoded, ok := strings.CutPrefix(payload, inlineGeneratePayload)
+	if !ok {
+		return "", false
+	}
+	topic, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !utf8.Valid(topic) || strings.TrimSpace(string(topic)) == "" {
+		return "", false
+	}
+	return strings.TrimSpace(string(topic)), true
+}
@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/news"
+	"AIGenerator/internal/richtext"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// inlinePost пост кэша inline-режима с текстом text
+func inlinePost(id, text string) recentPost {
+	return newRecentPost(id, ai.Post{Body: text}, richtext.Plain(text))
+}
+
+// resultIDs номера постов в ответе на inline-запрос
+func resultIDs(answer tgbotapi.InlineConfig) []string {
+	var ids []string
+	for _, result := range answer.Results {
+		ids = append(ids, result.(tgbotapi.InlineQueryResultArticle).ID)
+	}
+	return ids
+}
+
+func TestRecentPosts(t *testing.T) {
+	posts := newRecentPosts(3)
+	for i := 1; i <= 5; i++ {
+		posts.add(1, inlinePost(fmt.Sprint(i), fmt.Sprintf("Пост %d", i)))
+	}
+	posts.add(2, inlinePost("other", "Чужой пост"))
+
+	// Сверх предела вытесняются самые старые, список — от новых к старым
+	var ids []string
+	for _, post := range posts.list(1) {
+		ids = append(ids, post.id)
+	}
+	if strings.Join(ids, ",") != "5,4,3" {
+		t.Errorf("посты %v, ожидались 5,4,3", ids)
+	}
+
+	posts.update(1, "4", richtext.Plain("Расширенный пост"))
+	if got := posts.list(1)[1].text.String(); got != "Расширенный пост" {
+		t.Errorf("после правки %q", got)
+	}
+	// Изменение списка не меняет кэш
+	posts.list(1)[0].id = "изменен"
+	if posts.list(1)[0].id != "5" {
+		t.Error("list отдал кэш без копирования")
+	}
+
+	posts.forget(1)
+	if len(posts.list(1)) != 0 || len(posts.list(2)) != 1 {
+		t.Errorf("после forget: у 1 %d постов, у 2 %d", len(posts.list(1)), len(posts.list(2)))
+	}
+}
+
+func TestNewRecentPost(t *testing.T) {
+	tests := []struct {
+		name            string
+		post            ai.Post
+		wantTitle       string
+		wantDescription string
+	}{
+		{"заголовок", ai.Post{Title: "Ставка ЦБ", Body: "Банк России\nоставил *ставку*", Structured: true},
+			"⚡️ Ставка ЦБ", "Банк России оставил ставку"},
+		// Без заголовка заголовком служит первая строка, разметка модели снимается
+		{"первая строка", ai.Post{Body: "*Курс рубля*\nРубль укрепился\n\nк доллару"}, "Курс рубля", "Рубль укрепился к доллару"},
+		{"длинный текст", ai.Post{Body: strings.Repeat("а", 70) + "\n" + strings.Repeat("б ", 100)},
+			strings.Repeat("а", inlineTitleLength-1) + "…", strings.Repeat("б ", inlineDescriptionLength/2-1) + "б…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post := newRecentPost("1", tt.post, richtext.Plain(tt.post.Body))
+			if post.title != tt.wantTitle {
+				t.Errorf("заголовок %q, ожидался %q", post.title, tt.wantTitle)
+			}
+			if post.description != tt.wantDescription {
+				t.Errorf("описание %q, ожидалось %q", post.description, tt.wantDescription)
+			}
+		})
+	}
+}
+
+func TestInlineGenerateParameter(t *testing.T) {
+	for _, topic := range []string{
+		"ставка цб",
+		strings.Repeat("нейросети ", 10),
+		strings.Repeat("🚀", 30),
+		"AI " + strings.Repeat("я", 40),
+	} {
+		parameter := inlineGenerateParameter(topic)
+		if len(parameter) > maxStartPayload {
+			t.Errorf("%q: параметр длиной %d байт", topic, len(parameter))
+		}
+		// Тема сокращается по целым символам
+		decoded, ok := inlineTopic(parameter)
+		if !ok || !utf8.ValidString(decoded) || !strings.HasPrefix(topic, decoded) {
+			t.Errorf("%q: из параметра %q получена тема %q", topic, parameter, decoded)
+		}
+	}
+	if got, _ := inlineTopic(inlineGenerateParameter("ставка цб")); got != "ставка цб" {
+		t.Errorf("короткая тема: %q", got)
+	}
+
+	encode := func(s string) string { return inlineGeneratePayload + base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	for _, payload := range []string{"buy", "gen_", "gen_!!!", encode("   "), encode("\xff\xfe")} {
+		if topic, ok := inlineTopic(payload); ok {
+			t.Errorf("%q принят как тема %q", payload, topic)
+		}
+	}
+}
+
+func TestInlineAnswer(t *testing.T) {
+	b, _ := newTestBot(t)
+	if _, err := b.db.AdjustGenerations(1, 2, testAdminChatID, "", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.db.AdjustGenerations(2, 0, testAdminChatID, "", true); err != nil {
+		t.Fatal(err)
+	}
+	b.inlinePosts.add(1, newRecentPost("rate", ai.Post{Title: "Ставка ЦБ", Body: "Банк России оставил ставку", Structured: true},
+		richtext.Concat(richtext.Bold("Ставка ЦБ"), richtext.Plain("\n\nБанк России оставил ставку"))))
+	b.inlinePosts.add(1, inlinePost("btc", "Биткоин вырос"))
+	b.inlinePosts.add(2, inlinePost("other", "Ставка ФРС"))
+
+	tests := []struct {
+		name, query string
+		userID      int64
+		wantIDs     string
+		// wantParameter параметр /start кнопки над результатами; пустой — кнопки нет
+		wantParameter string
+	}{
+		{"пустой запрос", "", 1, "btc,rate", "inline"},
+		{"короткий запрос не фильтрует", "ст", 1, "btc,rate", "inline"},
+		{"тема", "СТАВКА", 1, "rate", inlineGenerateParameter("СТАВКА")},
+		{"тема без постов", "нейросети", 1, "", inlineGenerateParameter("нейросети")},
+		{"нет генераций", "ставка", 2, "other", inlineBuyPayload},
+		{"пост по номеру", inlinePostQuery("rate"), 1, "rate", ""},
+		// Чужой пост по номеру не находится
+		{"чужой пост", inlinePostQuery("other"), 1, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answer := b.inlineAnswer(tt.userID, "ru", tt.query)
+			if got := strings.Join(resultIDs(answer), ","); got != tt.wantIDs {
+				t.Errorf("результаты %q, ожидались %q", got, tt.wantIDs)
+			}
+			if answer.SwitchPMParameter != tt.wantParameter {
+				t.Errorf("параметр /start %q, ожидался %q", answer.SwitchPMParameter, tt.wantParameter)
+			}
+			if !answer.IsPersonal || answer.Results == nil {
+				t.Error("ответ не личный или без списка результатов")
+			}
+		})
+	}
+
+	// Пост вставляется текстом с сущностями, в списке виден заголовок и начало текста
+	result := b.inlineAnswer(1, "ru", inlinePostQuery("rate")).Results[0].(tgbotapi.InlineQueryResultArticle)
+	content := result.InputMessageContent.(tgbotapi.InputTextMessageContent)
+	if result.Title != "⚡️ Ставка ЦБ" || result.Description != "Банк России оставил ставку" ||
+		content.Text != "Ставка ЦБ\n\nБанк России оставил ставку" || len(content.Entities) != 1 || content.Entities[0].Type != richtext.EntityBold {
+		t.Errorf("результат: %+v, текст %+v", result, content)
+	}
+}
+
+func TestInlineStartRequiresConfirmation(t *testing.T) {
+	b, fake := newTestBot(t)
+	gpt := newFakeGPT()
+	useGenerator(b, gpt, &fakeNews{articles: []news.Article{{Title: "Ставка ЦБ", URL: "https://example.com/rate", Source: "РБК"}}})
+	runBot(t, b)
+
+	// Ссылка с параметром только показывает тему и кнопку
+	fake.Feed(commandUpdate(1, "/start "+inlineGenerateParameter("ставка цб")))
+	waitFor(t, "подтверждение", func() bool { return sentText(fake, 1, "ставка цб") })
+	confirm := fake.SentTo(1)[len(fake.SentTo(1))-1]
+	if confirm.Keyboard == nil || confirm.Keyboard.InlineKeyboard[0][0].CallbackData == nil {
+		t.Fatalf("нет кнопки подтверждения: %+v", confirm)
+	}
+	waitGenerations(t, b)
+	before := balance(b, 1)
+	if gpt.written.Load() != 0 || before != testTrialGenerations {
+		t.Fatalf("генерация без подтверждения: постов %d, баланс %d", gpt.written.Load(), before)
+	}
+
+	fake.Feed(callbackUpdate(1, confirm.MessageID, *confirm.Keyboard.InlineKeyboard[0][0].CallbackData))
+	waitFor(t, "пост", func() bool { return gpt.written.Load() == 1 && len(b.inlinePosts.list(1)) == 1 })
+	waitGenerations(t, b)
+	if got := balance(b, 1); got != before-1 {
+		t.Errorf("баланс %d, ожидался %d", got, before-1)
+	}
+
+	// Под постом кнопка «Вставить в чат», и ее запрос находит этот пост
+	id := b.inlinePosts.list(1)[0].id
+	var insert *string
+	for _, sent := range fake.SentTo(1) {
+		if sent.Keyboard != nil {
+			for _, row := range sent.Keyboard.InlineKeyboard {
+				if row[0].SwitchInlineQuery != nil {
+					insert = row[0].SwitchInlineQuery
+				}
+			}
+		}
+	}
+	if insert == nil || *insert != inlinePostQuery(id) {
+		t.Fatalf("кнопка вставки: %v, ожидался запрос %q", insert, inlinePostQuery(id))
+	}
+	if ids := resultIDs(b.inlineAnswer(1, "ru", *insert)); len(ids) != 1 || ids[0] != id {
+		t.Errorf("по запросу кнопки найдено %v", ids)
+	}
+}
@@ -0,0 +1,218 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"AIGenerator/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// maxManualAdjustment сколько генераций можно начислить или списать одной командой
+	maxManualAdjustment = 1000
+	// createUserFlag разрешает /addgenerations завести пользователя, которого нет в базе
+	createUserFlag = "--create"
+	// userInfoAdjustments сколько последних ручных изменений баланса показывает /userinfo
+	userInfoAdjustments = 10
+	// reportAdjustments сколько ручных изменений баланса перечисляет недельный отчет
+	reportAdjustments = 20
+)
+
+// handleAddGenerationsCommand начисляет генерации пользователю:
+// /addgenerations пароль chatid количество [--create] [причина]
+func (b *Bot) handleAddGenerationsCommand(msg *tgbotapi.Message) {
+	b.handleAdjustGenerations(msg, "addgenerations", 1)
+}
+
+// handleRemoveGenerationsCommand списывает генерации пользователя, но не ниже нуля:
+// /removegenerations пароль chatid количество [причина]
+func (b *Bot) handleRemoveGenerationsCommand(msg *tgbotapi.Message) {
+	b.handleAdjustGenerations(msg, "removegenerations", -1)
+}
+
+// handleAdjustGenerations изменяет баланс на количество из команды со знаком sign.
+// Изменение записывается в журнал баланса с чатом администратора и причиной.
+func (b *Bot) handleAdjustGenerations(msg *tgbotapi.Message, command string, sign int) {
+	usage := fmt.Sprintf("/%s пароль chatid количество [причина]", command)
+	if sign > 0 {
+		usage = fmt.Sprintf("/%s пароль chatid количество [%s] [причина]\n\n"+
+			"%s — завести пользователя, который еще не писал боту", command, createUserFlag, createUserFlag)
+	}
+
+	parts := strings.Fields(msg.CommandArguments())
+	if len(parts) < 3 {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n"+usage)
+		return
+	}
+
+	if parts[0] != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный chatid. Должен быть числом.")
+		return
+	}
+
+	count, err := strconv.Atoi(parts[2])
+	if err != nil || count <= 0 || count > maxManualAdjustment {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Неверное количество генераций. Должно быть от 1 до %d.", maxManualAdjustment))
+		return
+	}
+
+	rest := parts[3:]
+	create := sign > 0 && len(rest) > 0 && rest[0] == createUserFlag
+	if create {
+		rest = rest[1:]
+	}
+	reason := strings.Join(rest, " ")
+
+	adjustment, err := b.db.AdjustGenerations(chatID, sign*count, msg.Chat.ID, reason, create)
+	if errors.Is(err, database.ErrUnknownUser) {
+		hint := ""
+		if sign > 0 {
+			hint = fmt.Sprintf("\nЕсли chatid верный, добавьте %s", createUserFlag)
+		}
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Пользователь %d не найден: он еще не писал боту.%s", chatID, hint))
+		return
+	}
+	if err != nil {
+		log.Printf("[COMMAND] ❌ Ошибка изменения баланса %d: %v", chatID, err)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Ошибка изменения баланса: %v", err))
+		return
+	}
+
+	if sign < 0 {
+		if adjustment.Delta == 0 {
+			b.sendMessage(msg.Chat.ID, fmt.Sprintf("ℹ️ У пользователя %d нет генераций, списывать нечего", chatID))
+			return
+		}
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ У пользователя %d списано %d генераций.\nТеперь у него доступно: %d генераций",
+			chatID, -adjustment.Delta, adjustment.Balance))
+		b.sendMessage(chatID, b.t(chatID, "generations.removed", -adjustment.Delta, adjustment.Balance))
+		return
+	}
+
+	created := ""
+	if adjustment.Created {
+		created = "\n🆕 Пользователь заведен"
+	}
+	b.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ Пользователю %d успешно добавлено %d генераций.\n"+
+		"Теперь у него доступно: %d генераций%s", chatID, adjustment.Delta, adjustment.Balance, created))
+
+	user := b.db.GetUser(chatID)
+	b.sendMessage(chatID, b.t(chatID, "generations.added", adjustment.Delta, user.AvailableGenerations, user.TotalGenerations))
+}
+
+// handleUserInfo показывает администратору состояние пользователя и ручные изменения
+// его баланса: /userinfo пароль chatid
+func (b *Bot) handleUserInfo(msg *tgbotapi.Message) {
+	parts := strings.Fields(msg.CommandArguments())
+	if len(parts) != 2 {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/userinfo пароль chatid")
+		return
+	}
+
+	if parts[0] != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный chatid. Должен быть числом.")
+		return
+	}
+
+	if !b.db.HasUser(chatID) {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Пользователь %d не найден", chatID))
+		return
+	}
+	b.sendMessage(msg.Chat.ID, formatUserInfo(b.db.GetUser(chatID), b.db.UserBalanceAdjustments(chatID, userInfoAdjustments), time.Now()))
+}
+
+// formatUserInfo оформляет ответ /userinfo
+func formatUserInfo(user *database.User, adjustments []database.BalanceAdjustment, now time.Time) string {
+	var text strings.Builder
+	fmt.Fprintf(&text, "👤 Пользователь %d", user.UserID)
+	if user.Username != "" {
+		fmt.Fprintf(&text, " (@%s)", user.Username)
+	}
+	fmt.Fprintf(&text, "\n📅 В боте с %s\n", user.CreatedAt.Format("02.01.2006 15:04"))
+	fmt.Fprintf(&text, "💎 Доступно: %d генераций (пробных при создании: %d), использовано всего: %d\n",
+		user.AvailableGenerations, user.TrialGenerations, user.TotalGenerations)
+	if user.IsPremium(now) {
+		fmt.Fprintf(&text, "⭐️ Премиум до %s\n", user.PremiumUntil.Format("02.01.2006 15:04"))
+	}
+	if user.LastGenerate.IsZero() {
+		text.WriteString("🔄 Генераций еще не было\n")
+	} else {
+		fmt.Fprintf(&text, "🔄 Последняя генерация: %s\n", user.LastGenerate.Format("02.01.2006 15:04"))
+	}
+	if user.Blocked {
+		text.WriteString("🚫 Заблокировал бота\n")
+	}
+
+	if len(adjustments) == 0 {
+		text.WriteString("\n✍️ Ручных изменений баланса не было")
+		return text.String()
+	}
+	fmt.Fprintf(&text, "\n✍️ Ручные изменения баланса (последние %d):\n", len(adjustments))
+	for _, adjustment := range adjustments {
+		text.WriteString(formatAdjustment(adjustment, false))
+		text.WriteString("\n")
+	}
+	return strings.TrimRight(text.String(), "\n")
+}
+
+// formatAdjustment строка ручного изменения баланса; withUser добавляет пользователя
+func formatAdjustment(adjustment database.BalanceAdjustment, withUser bool) string {
+	line := fmt.Sprintf("• %s %+d → %d", adjustment.Timestamp.Format("02.01 15:04"), adjustment.Delta, adjustment.Balance)
+	if withUser {
+		line += fmt.Sprintf(", пользователь %d", adjustment.UserID)
+	}
+	line += fmt.Sprintf(", админ %d", adjustment.AdminChatID)
+	if adjustment.Created {
+		line += ", заведен"
+	}
+	if adjustment.Reason != "" {
+		line += ": " + adjustment.Reason
+	}
+	return line
+}
+
+// writeAdjustments дописывает в недельный отчет ручные изменения баланса за неделю
+func writeAdjustments(text *strings.Builder, adjustments []database.BalanceAdjustment) {
+	if len(adjustments) == 0 {
+		text.WriteString("✍️ Ручных изменений баланса не было\n")
+		return
+	}
+
+	credited, debited := 0, 0
+	for _, adjustment := range adjustments {
+		if adjustment.Delta > 0 {
+			credited += adjustment.Delta
+		} else {
+			debited -= adjustment.Delta
+		}
+	}
+	fmt.Fprintf(text, "✍️ Ручных изменений баланса: %d (начислено %d, списано %d)\n", len(adjustments), credited, debited)
+
+	shown := adjustments
+	if len(shown) > reportAdjustments {
+		shown = shown[len(shown)-reportAdjustments:]
+		fmt.Fprintf(text, "Последние %d:\n", reportAdjustments)
+	}
+	for _, adjustment := range shown {
+		text.WriteString(formatAdjustment(adjustment, true))
+		text.WriteString("\n")
+	}
+}
@@ -0,0 +1,132 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/i18n"
+)
+
+func TestAdjustGenerationsCommandAudit(t *testing.T) {
+	b, fake := newTestBot(t)
+	if _, err := b.db.AdjustGenerations(2, 0, testAdminChatID, "", true); err != nil {
+		t.Fatal(err)
+	}
+	runBot(t, b)
+
+	tests := []struct {
+		command string
+		want    string
+		// wantUserText сообщение пользователю; пустое — ему ничего не пишется
+		userID       int64
+		wantUserText string
+	}{
+		// С --create пользователь заводится, флаг не попадает в причину
+		{"/addgenerations " + testAdminPassword + " 5 10 --create оплата переводом",
+			"✅ Пользователю 5 успешно добавлено 10 генераций.\nТеперь у него доступно: 10 генераций\n🆕 Пользователь заведен",
+			5, i18n.T("ru", "generations.added", 10, 10, 0)},
+		{"/removegenerations " + testAdminPassword + " 5 15 возврат платежа", "✅ У пользователя 5 списано 10 генераций.\nТеперь у него доступно: 0 генераций",
+			5, i18n.T("ru", "generations.removed", 10, 0)},
+		// У списания нет флага --create
+		{"/removegenerations " + testAdminPassword + " 6 1 --create", "❌ Пользователь 6 не найден: он еще не писал боту.", 6, ""},
+		{"/removegenerations " + testAdminPassword + " 2 1", "ℹ️ У пользователя 2 нет генераций, списывать нечего", 2, ""},
+		{"/removegenerations " + testAdminPassword + " 2 0", "❌ Неверное количество генераций", 2, ""},
+		{"/addgenerations " + testAdminPassword + " два 1", "❌ Неверный chatid", 2, ""},
+	}
+	for _, tt := range tests {
+		fake.Reset()
+		fake.Feed(commandUpdate(testAdminChatID, tt.command))
+		waitFor(t, tt.command, func() bool { return len(fake.SentTo(testAdminChatID)) > 0 })
+		if text := fake.LastText(testAdminChatID); !strings.HasPrefix(text, tt.want) {
+			t.Errorf("%s: %q, ожидалось %q", tt.command, text, tt.want)
+		}
+		if text := fake.LastText(tt.userID); text != tt.wantUserText {
+			t.Errorf("%s: пользователю %q, ожидалось %q", tt.command, text, tt.wantUserText)
+		}
+	}
+	if b.db.HasUser(6) {
+		t.Error("списание завело пользователя")
+	}
+
+	// Журнал хранит, кто и почему изменил баланс, и показывается в /userinfo
+	adjustments := b.db.UserBalanceAdjustments(5, 10)
+	if len(adjustments) != 2 ||
+		adjustments[0].Delta != -10 || adjustments[0].Reason != "возврат платежа" || adjustments[0].AdminChatID != testAdminChatID ||
+		adjustments[1].Delta != 10 || adjustments[1].Reason != "оплата переводом" || !adjustments[1].Created {
+		t.Fatalf("журнал: %+v", adjustments)
+	}
+	fake.Reset()
+	fake.Feed(commandUpdate(testAdminChatID, "/userinfo "+testAdminPassword+" 5"))
+	waitFor(t, "userinfo", func() bool { return len(fake.SentTo(testAdminChatID)) > 0 })
+	for _, want := range []string{"✍️ Ручные изменения баланса (последние 2):", "-10 → 0, админ 999: возврат платежа",
+		"+10 → 10, админ 999, заведен: оплата переводом"} {
+		if !sentText(fake, testAdminChatID, want) {
+			t.Errorf("нет %q в /userinfo:\n%s", want, fake.LastText(testAdminChatID))
+		}
+	}
+}
+
+func TestFormatUserInfo(t *testing.T) {
+	at := time.Date(2026, 10, 12, 9, 30, 0, 0, time.UTC)
+	user := &database.User{UserID: 5, Username: "reader", AvailableGenerations: 10, TrialGenerations: 3, TotalGenerations: 4,
+		CreatedAt: at.AddDate(0, -1, 0), LastGenerate: at, Blocked: true}
+	adjustments := []database.BalanceAdjustment{
+		{UserID: 5, Delta: -2, Balance: 10, AdminChatID: 999, Timestamp: at.Add(time.Hour)},
+		{UserID: 5, Delta: 12, Balance: 12, AdminChatID: 998, Reason: "компенсация", Created: true, Timestamp: at},
+	}
+
+	want := `👤 Пользователь 5 (@reader)
+📅 В боте с 12.09.2026 09:30
+💎 Доступно: 10 генераций (пробных при создании: 3), использовано всего: 4
+🔄 Последняя генерация: 12.10.2026 09:30
+🚫 Заблокировал бота
+
+✍️ Ручные изменения баланса (последние 2):
+• 12.10 10:30 -2 → 10, админ 999
+• 12.10 09:30 +12 → 12, админ 998, заведен: компенсация`
+	if got := formatUserInfo(user, adjustments, at); got != want {
+		t.Errorf("userinfo:\n%s\n\nожидалось:\n%s", got, want)
+	}
+	if got := formatUserInfo(&database.User{UserID: 6}, nil, at); !strings.HasSuffix(got, "🔄 Генераций еще не было\n\n✍️ Ручных изменений баланса не было") {
+		t.Errorf("userinfo без изменений:\n%s", got)
+	}
+}
+
+func TestWriteAdjustments(t *testing.T) {
+	at := time.Date(2026, 10, 12, 9, 30, 0, 0, time.UTC)
+	var text strings.Builder
+	writeAdjustments(&text, []database.BalanceAdjustment{
+		{UserID: 5, Delta: 10, Balance: 10, AdminChatID: 999, Reason: "оплата переводом", Timestamp: at},
+		{UserID: 6, Delta: -3, Balance: 0, AdminChatID: 999, Timestamp: at.Add(time.Hour)},
+	})
+	want := `✍️ Ручных изменений баланса: 2 (начислено 10, списано 3)
+• 12.10 09:30 +10 → 10, пользователь 5, админ 999: оплата переводом
+• 12.10 10:30 -3 → 0, пользователь 6, админ 999
+`
+	if got := text.String(); got != want {
+		t.Errorf("изменения:\n%s\nожидались:\n%s", got, want)
+	}
+
+	// В отчете только последние reportAdjustments изменений, итоги — по всем
+	var adjustments []database.BalanceAdjustment
+	for i := range reportAdjustments + 5 {
+		adjustments = append(adjustments, database.BalanceAdjustment{UserID: int64(i + 1), Delta: 1, Balance: 1, Timestamp: at})
+	}
+	text.Reset()
+	writeAdjustments(&text, adjustments)
+	got := text.String()
+	if !strings.HasPrefix(got, fmt.Sprintf("✍️ Ручных изменений баланса: %d (начислено %d, списано 0)\nПоследние %d:\n",
+		reportAdjustments+5, reportAdjustments+5, reportAdjustments)) ||
+		strings.Count(got, "\n• ") != reportAdjustments || strings.Contains(got, "пользователь 5,") || !strings.Contains(got, "пользователь 6,") {
+		t.Errorf("изменения сверх предела:\n%s", got)
+	}
+
+	text.Reset()
+	writeAdjustments(&text, nil)
+	if got := text.String(); got != "✍️ Ручных изменений баланса не было\n" {
+		t.Errorf("без изменений: %q", got)
+	}
+}
@@ -0,0 +1,18 @@
+package bot
+
+import (
+	"os"
+	"testing"
+
+	"AIGenerator/internal/httpx"
+)
+
+func TestMain(m *testing.M) {
+	// Сайты новостей в тестах работают на одном хосте: без паузы между запросами
+	// к домену тесты картинок не ждут лишние полсекунды на каждое скачивание
+	config := httpx.DefaultConfig()
+	config.DomainInterval = 0
+	httpx.Configure(config)
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"AIGenerator/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// outboxPollInterval как часто проверяются уведомления, ждущие повторной попытки
+	outboxPollInterval = 5 * time.Second
+	// outboxBaseDelay и outboxMaxDelay пауза после первой неудачной попытки и ее предел:
+	// пауза удваивается с каждой попыткой
+	outboxBaseDelay = 5 * time.Second
+	outboxMaxDelay  = time.Hour
+	// outboxSendInterval пауза между отправками: Telegram ограничивает рассылку
+	// примерно 30 сообщениями в секунду
+	outboxSendInterval = 50 * time.Millisecond
+)
+
+// notifyAdmin ставит уведомление администратору в очередь: оно уходит фоновой отправкой
+// и переживает сбои Telegram и перезапуск бота. dedupKey делает уведомление
+// идемпотентным: повтор с тем же ключом за сутки не отправляется; пустой — без проверки.
+func (b *Bot) notifyAdmin(text string, markdown bool, dedupKey string) {
+	if b.adminChatID == 0 {
+		return
+	}
+	b.enqueueOutbox(database.OutboxMessage{
+		ChatID:   b.adminChatID,
+		Text:     text,
+		Markdown: markdown,
+		DedupKey: dedupKey,
+	})
+}
+
+// enqueueOutbox ставит сообщение в очередь и будит отправку. Возвращает false,
+// если сообщение с тем же DedupKey уже в очереди или отправлено.
+func (b *Bot) enqueueOutbox(message database.OutboxMessage) bool {
+	queued, err := b.db.EnqueueOutbox(message, time.Now())
+	if err != nil {
+		log.Printf("[OUTBOX] ❌ Ошибка сохранения очереди уведомлений: %v", err)
+	}
+	if !queued {
+		log.Printf("[OUTBOX] Уведомление %s уже в очереди или отправлено, пропускаю", message.DedupKey)
+		return false
+	}
+
+	select {
+	case b.outboxWake <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// runOutbox отправляет уведомления из очереди: сразу после постановки и повторно
+// по истечении паузы. Неотправленные к остановке уведомления остаются в базе
+// и уходят после запуска.
+func (b *Bot) runOutbox(ctx context.Context) {
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		b.deliverOutbox(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.stopping:
+			return
+		case <-b.outboxWake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverOutbox отправляет уведомления, время попытки которых наступило, не чаще
+// outboxSendInterval. Сообщение пользователю, заблокировавшему бота, не повторяется,
+// а пользователь отмечается заблокированным — одним сохранением базы на весь проход.
+func (b *Bot) deliverOutbox(now time.Time) {
+	var blocked []int64
+	defer func() { b.markBlocked(blocked, nil) }()
+
+	for i, message := range b.db.DueOutbox(now) {
+		if i > 0 {
+			select {
+			case <-b.stopping:
+				return
+			case <-time.After(outboxSendInterval):
+			}
+		}
+
+		err := b.sendOutboxMessage(message)
+		if err != nil && message.ChatID != b.adminChatID && isBotBlocked(err) {
+			log.Printf("[OUTBOX] ⚠️ Пользователь %d заблокировал бота, уведомление %d отменено", message.ChatID, message.ID)
+			blocked = append(blocked, message.ChatID)
+			if err := b.db.DropOutbox(message.ID, err, time.Now()); err != nil {
+				log.Printf("[OUTBOX] ❌ Ошибка сохранения очереди уведомлений: %v", err)
+			}
+			continue
+		}
+		if err == nil {
+			if err := b.db.MarkOutboxSent(message.ID, time.Now()); err != nil {
+				log.Printf("[OUTBOX] ❌ Ошибка сохранения очереди уведомлений: %v", err)
+			}
+			continue
+		}
+
+		delay := outboxDelay(message.Attempts + 1)
+		log.Printf("[OUTBOX] ⚠️ Уведомление %d не отправлено (попытка %d), повтор через %v: %v",
+			message.ID, message.Attempts+1, delay, err)
+		if err := b.db.MarkOutboxFailed(message.ID, err, time.Now().Add(delay)); err != nil {
+			log.Printf("[OUTBOX] ❌ Ошибка сохранения очереди уведомлений: %v", err)
+		}
+	}
+}
+
+// sendOutboxMessage отправляет уведомление. Если Telegram не принял разметку Markdown,
+// текст отправляется без нее, как в sendMessageWithMarkdown.
+func (b *Bot) sendOutboxMessage(message database.OutboxMessage) error {
+	msg := tgbotapi.NewMessage(message.ChatID, message.Text)
+	msg.DisableWebPagePreview = true
+	if message.Markdown {
+		msg.ParseMode = tgbotapi.ModeMarkdown
+		if _, err := b.api.Send(msg); err == nil {
+			return nil
+		}
+		msg.ParseMode = ""
+	}
+	_, err := b.api.Send(msg)
+	return err
+}
+
+// outboxDelay пауза перед попыткой attempt+1: удваивается от outboxBaseDelay до outboxMaxDelay
+func outboxDelay(attempt int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempt && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, outboxMaxDelay)
+}
+
+// isBotBlocked ответ Telegram на сообщение пользователю, который заблокировал бота
+// или удалил аккаунт
+func isBotBlocked(err error) bool {
+	var apiErr *tgbotapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden
+}
+
+// markBlocked одним сохранением базы отмечает пользователей, заблокировавших бота,
+// и снимает отметку с тех, кому сообщение снова дошло
+func (b *Bot) markBlocked(blocked, delivered []int64) {
+	if len(blocked) == 0 && len(delivered) == 0 {
+		return
+	}
+	err := b.db.WithBatch(func(tx *database.Batch) {
+		for _, userID := range blocked {
+			tx.SetBlocked(userID, true)
+		}
+		for _, userID := range delivered {
+			tx.SetBlocked(userID, false)
+		}
+	})
+	if err != nil {
+		log.Printf("[DB] ❌ Ошибка сохранения отметок о блокировке бота: %v", err)
+	}
+}
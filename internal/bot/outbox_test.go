@@ -0,0 +1,143 @@
+package bot
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/database"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func TestOutboxRetriesWithBackoff(t *testing.T) {
+	b, fake := newTestBot(t)
+	fake.FailNext(testAdminChatID, errors.New("timeout"), errors.New("timeout"))
+
+	b.notifyAdmin("новый отзыв", false, "")
+	now := time.Now()
+	b.deliverOutbox(now)
+	if sent := fake.SentTo(testAdminChatID); len(sent) != 0 {
+		t.Fatalf("отправлено при сбое: %+v", sent)
+	}
+
+	// До истечения паузы попытки нет, потом пауза удваивается
+	b.deliverOutbox(now.Add(outboxBaseDelay / 2))
+	due := b.db.DueOutbox(now.Add(time.Hour))
+	if len(due) != 1 || due[0].Attempts != 1 {
+		t.Fatalf("очередь: %+v", due)
+	}
+	b.deliverOutbox(due[0].NextAttempt)
+	due = b.db.DueOutbox(now.Add(time.Hour))
+	if len(due) != 1 || due[0].Attempts != 2 || due[0].NextAttempt.Sub(now) < 2*outboxBaseDelay {
+		t.Fatalf("вторая попытка: %+v", due)
+	}
+
+	b.deliverOutbox(due[0].NextAttempt)
+	if text := fake.LastText(testAdminChatID); text != "новый отзыв" {
+		t.Errorf("доставлено %q", text)
+	}
+	if pending := b.db.PendingOutbox(); pending != 0 {
+		t.Errorf("неотправленных %d", pending)
+	}
+}
+
+func TestOutboxMarkdownFallback(t *testing.T) {
+	b, fake := newTestBot(t)
+	// Telegram не принял разметку: текст уходит без нее
+	fake.FailNext(testAdminChatID, errors.New("can't parse entities"))
+
+	b.notifyAdmin("*оплата* 100_руб", true, "")
+	b.deliverOutbox(time.Now())
+
+	sent := fake.SentTo(testAdminChatID)
+	if len(sent) != 1 || sent[0].Config.(tgbotapi.MessageConfig).ParseMode != "" {
+		t.Errorf("отправлено %+v", sent)
+	}
+	if pending := b.db.PendingOutbox(); pending != 0 {
+		t.Errorf("неотправленных %d", pending)
+	}
+}
+
+func TestOutboxReplayAfterRestart(t *testing.T) {
+	b, fake := newTestBot(t)
+	fake.FailFor(testAdminChatID, errors.New("telegram недоступен"))
+
+	// Бот остановился, не успев отправить уведомления: одно не дошло, другое не отправлялось
+	b.notifyAdmin("платеж зачислен", false, "")
+	b.deliverOutbox(time.Now())
+	b.notifyAdmin("паника в обработчике", false, "panic:abc")
+	if pending := b.db.PendingOutbox(); pending != 2 {
+		t.Fatalf("неотправленных %d", pending)
+	}
+
+	// После перезапуска уведомление уходит, повтор с тем же ключом не ставится
+	restarted, restartedFake := reopenTestBot(t)
+	runBot(t, restarted)
+	waitFor(t, "доставка после перезапуска", func() bool {
+		return restartedFake.LastText(testAdminChatID) == "паника в обработчике"
+	})
+	restarted.notifyAdmin("паника в обработчике", false, "panic:abc")
+
+	// Не дошедшее уведомление ждет своей паузы и тоже уходит
+	restarted.deliverOutbox(time.Now().Add(outboxBaseDelay))
+	sent := restartedFake.SentTo(testAdminChatID)
+	if len(sent) != 2 || sent[1].Text != "платеж зачислен" {
+		t.Errorf("отправлено %+v", sent)
+	}
+}
+
+func TestOutboxAtLeastOnceWithDedup(t *testing.T) {
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	// Одно и то же событие сообщается несколько раз, например из повторной проверки платежа
+	for range 3 {
+		b.notifyAdmin("платеж pay-1 зачислен", false, "payment:pay-1")
+	}
+	b.notifyAdmin("другой платеж", false, "payment:pay-2")
+	waitFor(t, "уведомления", func() bool { return len(fake.SentTo(testAdminChatID)) == 2 })
+	time.Sleep(50 * time.Millisecond)
+
+	sent := fake.SentTo(testAdminChatID)
+	if len(sent) != 2 || sent[0].Text != "платеж pay-1 зачислен" || sent[1].Text != "другой платеж" {
+		t.Errorf("отправлено %+v", sent)
+	}
+}
+
+func TestOutboxDropsBlockedUser(t *testing.T) {
+	b, fake := newTestBot(t)
+	if _, err := b.db.AdjustGenerations(5, 1, testAdminChatID, "тест", true); err != nil {
+		t.Fatal(err)
+	}
+	fake.FailNext(5, &tgbotapi.Error{Code: http.StatusForbidden, Message: "Forbidden: bot was blocked by the user"})
+
+	b.enqueueOutbox(database.OutboxMessage{ChatID: 5, Text: "сводка за неделю"})
+	b.deliverOutbox(time.Now())
+
+	// Сообщение заблокировавшему бота не повторяется, пользователь отмечен
+	if pending := b.db.PendingOutbox(); pending != 0 {
+		t.Errorf("неотправленных %d", pending)
+	}
+	if !b.db.GetUser(5).Blocked {
+		t.Error("пользователь не отмечен заблокировавшим бота")
+	}
+}
+
+func TestOutboxDelay(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, outboxBaseDelay},
+		{2, 2 * outboxBaseDelay},
+		{4, 8 * outboxBaseDelay},
+		{20, outboxMaxDelay},
+	}
+	for _, tt := range tests {
+		if got := outboxDelay(tt.attempt); got != tt.want {
+			t.Errorf("outboxDelay(%d) = %v, ожидалось %v", tt.attempt, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/events"
+	"AIGenerator/internal/payment"
+)
+
+const (
+	// paymentPollInterval как часто проверяется, не пора ли проверить ожидающие платежи
+	paymentPollInterval = 10 * time.Second
+	// paymentFirstCheck через сколько после создания платеж проверяется впервые;
+	// пауза между проверками удваивается до paymentMaxCheckDelay
+	paymentFirstCheck    = 30 * time.Second
+	paymentMaxCheckDelay = 10 * time.Minute
+	// paymentReminderAfter через сколько бот напоминает о неоплаченном платеже
+	paymentReminderAfter = 5 * time.Minute
+	// paymentMaxAge сколько отслеживается неоплаченный платеж; ЮKassa отменяет
+	// неподтвержденные платежи раньше
+	paymentMaxAge = 24 * time.Hour
+)
+
+// paymentChecker проверка статуса платежа; реализуется payment.YooMoneyClient
+type paymentChecker interface {
+	CheckPayment(paymentID string) (*payment.PaymentResponse, error)
+}
+
+// runPaymentPoller проверяет ожидающие платежи из базы и зачисляет оплаченные.
+// Платежи хранятся в базе, поэтому после перезапуска проверка продолжается с того же места.
+func (b *Bot) runPaymentPoller(ctx context.Context) {
+	if b.yooMoney == nil {
+		return
+	}
+
+	ticker := time.NewTicker(paymentPollInterval)
+	defer ticker.Stop()
+
+	for {
+		b.pollPayments(b.yooMoney, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollPayments проверяет платежи, время проверки которых наступило
+func (b *Bot) pollPayments(checker paymentChecker, now time.Time) {
+	for _, purchase := range b.db.DuePendingPurchases(now) {
+		select {
+		case <-b.stopping:
+			return
+		default:
+		}
+		b.pollPayment(checker, purchase, now)
+	}
+}
+
+// pollPayment проверяет один платеж: оплаченный зачисляет, отмененный в ЮKassa
+// и просроченный перестает отслеживать, остальные откладывает до следующей проверки
+func (b *Bot) pollPayment(checker paymentChecker, purchase database.Purchase, now time.Time) {
+	paymentID, userID := purchase.PaymentID, purchase.UserID
+
+	paymentResp, err := checker.CheckPayment(paymentID)
+	if err != nil {
+		log.Printf("[PAYMENT] ❌ Ошибка проверки статуса платежа %s: %v", paymentID, err)
+		b.schedulePaymentCheck(purchase, now, false)
+		return
+	}
+
+	switch paymentResp.Status {
+	case "succeeded":
+		completed, credited, err := b.completePayment(paymentID)
+		if err == nil && credited {
+			b.sendMessage(userID, b.t(userID, "payment.credited", packageGenerations(completed.PackageType)))
+		}
+	case "canceled":
+		b.db.UpdatePurchaseStatus(paymentID, "canceled")
+	default:
+		if now.Sub(purchase.CreatedAt) > paymentMaxAge {
+			log.Printf("[PAYMENT] Платеж %s не оплачен за %v, больше не проверяю", paymentID, paymentMaxAge)
+			b.db.UpdatePurchaseStatus(paymentID, "expired")
+			return
+		}
+		// Пользователю, отменившему платеж в боте, о нем не напоминаем
+		remind := purchase.Status == "pending" && !purchase.Reminded && now.Sub(purchase.CreatedAt) >= paymentReminderAfter
+		if remind {
+			b.sendMessage(userID, b.t(userID, "payment.still_pending"))
+		}
+		b.schedulePaymentCheck(purchase, now, remind)
+	}
+}
+
+// schedulePaymentCheck откладывает следующую проверку платежа с удвоением паузы
+func (b *Bot) schedulePaymentCheck(purchase database.Purchase, now time.Time, reminded bool) {
+	delay := paymentFirstCheck
+	for i := 0; i < purchase.Checks && delay < paymentMaxCheckDelay; i++ {
+		delay *= 2
+	}
+	if err := b.db.SchedulePurchaseCheck(purchase.PaymentID, now.Add(min(delay, paymentMaxCheckDelay)), reminded); err != nil {
+		log.Printf("[PAYMENT] ❌ Ошибка сохранения ожидающего платежа %s: %v", purchase.PaymentID, err)
+	}
+}
+
+// completePayment зачисляет оплаченный платеж. credited false, если платеж уже был
+// зачислен раньше: кнопкой проверки или фоновой проверкой.
+func (b *Bot) completePayment(paymentID string) (database.Purchase, bool, error) {
+	purchase, credited, err := b.db.CompletePendingPurchase(paymentID)
+	if credited {
+		b.events.Emit(events.Event{Type: events.PaymentSucceeded, UserID: purchase.UserID, PaymentID: paymentID,
+			Package: purchase.PackageType, Amount: purchase.Price})
+	}
+	if err != nil {
+		log.Printf("[PAYMENT] ❌ Ошибка зачисления генераций за платеж %s: %v", paymentID, err)
+		reportPaymentCreditError(purchase.UserID, paymentID, err)
+	}
+	return purchase, credited, err
+}
+
+// packageGenerations число генераций в пакете по его коду: "10", "25", "100"
+func packageGenerations(code string) int {
+	count, err := strconv.Atoi(code)
+	if err != nil {
+		return 10
+	}
+	return count
+}
@@ -0,0 +1,214 @@
+package bot
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/i18n"
+)
+
+// addPending записывает ожидающий платеж paymentID пользователя 1 на пакет из 10 генераций,
+// созданный в created, и заводит его в заглушке ЮKassa
+func addPending(t *testing.T, b *Bot, yooKassa *fakeYooKassa, paymentID string, created time.Time) {
+	t.Helper()
+	yooKassa.setStatus(paymentID, "pending")
+	if err := b.db.AddPendingPurchase(&database.Purchase{
+		PaymentID:   paymentID,
+		UserID:      1,
+		PackageType: "10",
+		Price:       99,
+		Status:      "pending",
+		CreatedAt:   created,
+		UpdatedAt:   created,
+	}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testTrialGenerations пробные генерации нового пользователя тестового бота
+const testTrialGenerations = 3
+
+// balance доступные генерации пользователя
+func balance(b *Bot, userID int64) int {
+	return b.db.GetUser(userID).AvailableGenerations
+}
+
+func TestPendingPurchaseCreditedAfterRestart(t *testing.T) {
+	b, _ := newTestBot(t)
+	yooKassa, client := newFakeYooKassa(t)
+	now := time.Now()
+	addPending(t, b, yooKassa, "pay-1", now)
+	b.pollPayments(client, now)
+
+	// Платеж оплачен, пока бот был остановлен; после запуска проверка продолжается из базы
+	yooKassa.setStatus("pay-1", "succeeded")
+	restarted, fake := reopenTestBot(t)
+	if due := restarted.db.DuePendingPurchases(now.Add(time.Hour)); len(due) != 1 || due[0].Checks != 1 {
+		t.Fatalf("ожидающие после перезапуска: %+v", due)
+	}
+	restarted.pollPayments(client, now.Add(time.Hour))
+
+	if got := balance(restarted, 1); got != testTrialGenerations+10 {
+		t.Errorf("доступно %d, ожидалось %d", got, testTrialGenerations+10)
+	}
+	if text := fake.LastText(1); text != i18n.T("ru", "payment.credited", 10) {
+		t.Errorf("сообщение пользователю: %q", text)
+	}
+	if restarted.db.GetPendingPurchase("pay-1") != nil {
+		t.Error("зачисленный платеж остался в ожидающих")
+	}
+}
+
+func TestPurchaseCompletesExactlyOnce(t *testing.T) {
+	b, fake := newTestBot(t)
+	yooKassa, client := newFakeYooKassa(t)
+	b.yooMoney = client
+	addPending(t, b, yooKassa, "pay-1", time.Now())
+	yooKassa.setStatus("pay-1", "succeeded")
+
+	// Кнопка нажата дважды, а фоновая проверка идет одновременно с ними
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			b.handleCheckPayment(callbackUpdate(1, 5, "check_pay-1").CallbackQuery)
+		}()
+		go func() {
+			defer wg.Done()
+			b.pollPayments(client, time.Now())
+		}()
+	}
+	wg.Wait()
+
+	// Опоздавшее нажатие после зачисления
+	b.handleCheckPayment(callbackUpdate(1, 5, "check_pay-1").CallbackQuery)
+
+	if got := balance(b, 1); got != testTrialGenerations+10 {
+		t.Errorf("доступно %d, ожидалось %d", got, testTrialGenerations+10)
+	}
+	credited := 0
+	for _, sent := range fake.SentTo(1) {
+		if sent.Text == i18n.T("ru", "payment.credited", 10) ||
+			sent.Text == i18n.T("ru", "payment.succeeded_details", 10, 99, testTrialGenerations+10) {
+			credited++
+		}
+	}
+	if credited != 1 {
+		t.Errorf("сообщений о зачислении %d, ожидалось 1", credited)
+	}
+}
+
+func TestPollPayment(t *testing.T) {
+	created := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		status string
+		// at время проверки
+		at time.Time
+		// wantTracked платеж остался в ожидающих
+		wantTracked bool
+		wantNext    time.Time
+		wantText    string
+	}{
+		{"еще не оплачен", "pending", created.Add(time.Minute), true, created.Add(time.Minute + paymentFirstCheck), ""},
+		{"напоминание", "pending", created.Add(paymentReminderAfter), true,
+			created.Add(paymentReminderAfter + paymentFirstCheck), i18n.T("ru", "payment.still_pending")},
+		{"отменен в ЮKassa", "canceled", created.Add(time.Minute), false, time.Time{}, ""},
+		{"просрочен", "pending", created.Add(paymentMaxAge + time.Minute), false, time.Time{}, ""},
+		{"оплачен", "succeeded", created.Add(time.Minute), false, time.Time{}, i18n.T("ru", "payment.credited", 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			yooKassa, client := newFakeYooKassa(t)
+			addPending(t, b, yooKassa, "pay-1", created)
+			yooKassa.setStatus("pay-1", tt.status)
+
+			b.pollPayments(client, tt.at)
+
+			purchase := b.db.GetPendingPurchase("pay-1")
+			if tracked := purchase != nil; tracked != tt.wantTracked {
+				t.Fatalf("платеж отслеживается: %t, ожидалось %t", tracked, tt.wantTracked)
+			}
+			if tt.wantTracked && !purchase.NextCheck.Equal(tt.wantNext) {
+				t.Errorf("следующая проверка %v, ожидалась %v", purchase.NextCheck, tt.wantNext)
+			}
+			if text := fake.LastText(1); text != tt.wantText {
+				t.Errorf("сообщение пользователю %q, ожидалось %q", text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestPollPaymentBackoff(t *testing.T) {
+	b, fake := newTestBot(t)
+	yooKassa, client := newFakeYooKassa(t)
+	now := time.Now()
+	addPending(t, b, yooKassa, "pay-1", now)
+
+	// Пауза удваивается до предела, напоминание отправляется один раз
+	var delays []time.Duration
+	for range 7 {
+		b.pollPayments(client, now)
+		next := b.db.GetPendingPurchase("pay-1").NextCheck
+		delays = append(delays, next.Sub(now))
+		now = next
+	}
+	want := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute,
+		paymentMaxCheckDelay, paymentMaxCheckDelay}
+	for i := range want {
+		if delays[i] != want[i] {
+			t.Fatalf("паузы %v, ожидались %v", delays, want)
+		}
+	}
+	if sent := fake.SentTo(1); len(sent) != 1 || sent[0].Text != i18n.T("ru", "payment.still_pending") {
+		t.Errorf("сообщения: %+v", sent)
+	}
+
+	// До времени проверки ЮKassa не спрашивают
+	if due := b.db.DuePendingPurchases(now.Add(-time.Second)); len(due) != 0 {
+		t.Errorf("до времени проверки: %+v", due)
+	}
+}
+
+func TestPurchaseCanceledInBot(t *testing.T) {
+	tests := []struct {
+		name string
+		// status статус в ЮKassa после отмены в боте
+		status      string
+		wantBalance int
+		wantTracked bool
+	}{
+		// Деньги списаны — генерации зачисляются, несмотря на отмену
+		{"все же оплачен", "succeeded", testTrialGenerations + 10, false},
+		{"отменен в ЮKassa", "canceled", testTrialGenerations, false},
+		// Пока ЮKassa не завершила платеж, он проверяется дальше, но без напоминаний
+		{"еще не завершен", "pending", testTrialGenerations, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			yooKassa, client := newFakeYooKassa(t)
+			created := time.Now()
+			addPending(t, b, yooKassa, "pay-1", created)
+
+			b.handleCancelPayment(callbackUpdate(1, 5, "cancel_pay-1").CallbackQuery)
+			fake.Reset()
+			yooKassa.setStatus("pay-1", tt.status)
+			b.pollPayments(client, created.Add(paymentReminderAfter))
+
+			if got := balance(b, 1); got != tt.wantBalance {
+				t.Errorf("доступно %d, ожидалось %d", got, tt.wantBalance)
+			}
+			if tracked := b.db.GetPendingPurchase("pay-1") != nil; tracked != tt.wantTracked {
+				t.Errorf("платеж отслеживается: %t, ожидалось %t", tracked, tt.wantTracked)
+			}
+			if sentText(fake, 1, i18n.T("ru", "payment.still_pending")) {
+				t.Error("напоминание об отмененном платеже")
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"AIGenerator/internal/httpx"
+	"AIGenerator/internal/richtext"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// defaultPhotoRetryMaxDelay сколько бот готов ждать перед повторной отправкой фото
+	defaultPhotoRetryMaxDelay = 10 * time.Second
+	// photoRetryDelay пауза перед повтором, если Telegram не назвал свою
+	photoRetryDelay = time.Second
+	// maxPhotoDownloadSize наибольшая картинка, которую бот скачивает, чтобы загрузить сам
+	maxPhotoDownloadSize = 10 << 20
+	// photoDownloadTimeout лимит времени на скачивание картинки
+	photoDownloadTimeout = 20 * time.Second
+)
+
+// Этапы отправки картинки поста по ссылке: каким из них пост дошел до пользователя
+const (
+	photoByURL    = "url"
+	photoRetried  = "retry"
+	photoUploaded = "upload"
+	photoTextOnly = "text"
+)
+
+// photoClient HTTP-клиент для скачивания картинок новостей
+var photoClient = httpx.NewClient(photoDownloadTimeout)
+
+// sendPostPhoto отправляет пост с картинкой по ссылке. Telegram сам скачивает картинку
+// и иногда не может этого сделать или просит подождать, поэтому при ошибке отправка
+// повторяется один раз через названную им паузу, если она не длиннее PhotoRetryMaxDelay,
+// а затем бот скачивает картинку сам и загружает ее байтами. Возвращает этап, на котором
+// фото дошло; photoTextOnly — все этапы не удались и пост нужно отправить текстом.
+func (b *Bot) sendPostPhoto(chatID int64, imageURL string, caption richtext.Text, keyboard tgbotapi.InlineKeyboardMarkup) (tgbotapi.Message, string) {
+	message, err := b.sendPhotoWithCaption(chatID, imageURL, caption, keyboard)
+	if err == nil {
+		return message, photoByURL
+	}
+
+	if delay, ok := b.photoRetryDelay(err); ok {
+		log.Printf("[GENERATE] ⚠️ Фото не отправлено (%v), повтор через %s", err, delay)
+		if !b.waitRetry(delay) {
+			return tgbotapi.Message{}, photoTextOnly
+		}
+		if message, err = b.sendPhotoWithCaption(chatID, imageURL, caption, keyboard); err == nil {
+			return message, photoRetried
+		}
+	}
+
+	image, name, err := downloadPhoto(imageURL)
+	if err != nil {
+		log.Printf("[GENERATE] ⚠️ Не удалось скачать картинку %s: %v", imageURL, err)
+		return tgbotapi.Message{}, photoTextOnly
+	}
+	if message, err = b.sendPhotoFile(chatID, tgbotapi.FileBytes{Name: name, Bytes: image}, caption, keyboard); err != nil {
+		log.Printf("[GENERATE] ⚠️ Не удалось загрузить картинку %s в Telegram: %v", imageURL, err)
+		return tgbotapi.Message{}, photoTextOnly
+	}
+	return message, photoUploaded
+}
+
+// photoRetryDelay пауза перед повторной отправкой фото после ошибки err: названная
+// Telegram в ответе 429 или photoRetryDelay. false — повтор не нужен: ждать дольше
+// PhotoRetryMaxDelay хуже, чем сразу загрузить картинку самим.
+func (b *Bot) photoRetryDelay(err error) (time.Duration, bool) {
+	delay := photoRetryDelay
+	var apiErr *tgbotapi.Error
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		delay = time.Duration(apiErr.RetryAfter) * time.Second
+	}
+	return delay, delay <= b.config.PhotoRetryMaxDelay
+}
+
+// waitRetry ждет delay; false — бот завершается и повтор не нужен
+func (b *Bot) waitRetry(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-b.stopping:
+		return false
+	}
+}
+
+// downloadPhoto скачивает картинку, чтобы загрузить ее в Telegram самим. Принимается
+// только ответ с типом image/* не больше maxPhotoDownloadSize. Возвращает картинку
+// и имя файла с расширением по ее типу.
+func downloadPhoto(imageURL string) ([]byte, string, error) {
+	req, err := http.NewRequest(http.MethodGet, imageURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := photoClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("статус код: %d", resp.StatusCode)
+	}
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, "", fmt.Errorf("не картинка: %q", resp.Header.Get("Content-Type"))
+	}
+	if resp.ContentLength > maxPhotoDownloadSize {
+		return nil, "", fmt.Errorf("картинка больше %d МБ: %d байт", maxPhotoDownloadSize>>20, resp.ContentLength)
+	}
+
+	image, err := io.ReadAll(io.LimitReader(resp.Body, maxPhotoDownloadSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(image) > maxPhotoDownloadSize {
+		return nil, "", fmt.Errorf("картинка больше %d МБ", maxPhotoDownloadSize>>20)
+	}
+	return image, "image" + photoExtension(contentType), nil
+}
+
+// photoExtension расширение файла картинки типа contentType
+func photoExtension(contentType string) string {
+	switch contentType {
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".jpg"
+}
@@ -0,0 +1,197 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/richtext"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// testImage содержимое картинки новости
+var testImage = []byte("\x89PNG картинка новости")
+
+// imageServer сайт новости: отдает картинку handler и считает скачивания
+func imageServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var downloads atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, &downloads
+}
+
+// serveImage отдает body с типом contentType
+func serveImage(contentType string, body []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write(body)
+	}
+}
+
+// retryAfter ответ Telegram 429 с паузой seconds
+func retryAfter(seconds int) error {
+	return &tgbotapi.Error{Code: http.StatusTooManyRequests, Message: "Too Many Requests",
+		ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: seconds}}
+}
+
+func TestSendPostPhotoStages(t *testing.T) {
+	failedURL := errors.New("Bad Request: failed to get HTTP URL content")
+	tests := []struct {
+		name string
+		// maxDelay PhotoRetryMaxDelay; 0 — без повтора
+		maxDelay time.Duration
+		// failures ошибки отправок фото по порядку
+		failures      []error
+		handler       http.HandlerFunc
+		wantStage     string
+		wantDownloads int32
+	}{
+		{"по ссылке", defaultPhotoRetryMaxDelay, nil, serveImage("image/png", testImage), photoByURL, 0},
+		{"повтор через паузу Telegram", defaultPhotoRetryMaxDelay, []error{retryAfter(1)},
+			serveImage("image/png", testImage), photoRetried, 0},
+		// Ждать дольше предела хуже, чем сразу загрузить картинку самим
+		{"пауза длиннее предела", defaultPhotoRetryMaxDelay, []error{retryAfter(30)},
+			serveImage("image/png", testImage), photoUploaded, 1},
+		{"повтор не удался", defaultPhotoRetryMaxDelay, []error{failedURL, failedURL},
+			serveImage("image/png", testImage), photoUploaded, 1},
+		{"не картинка", 0, []error{failedURL}, serveImage("text/html; charset=utf-8", []byte("<html>")), photoTextOnly, 1},
+		{"картинка не скачалась", 0, []error{failedURL}, func(w http.ResponseWriter, r *http.Request) {
+			http.NotFound(w, r)
+		}, photoTextOnly, 1},
+		{"загрузка не удалась", 0, []error{failedURL, errors.New("Request Entity Too Large")},
+			serveImage("image/png", testImage), photoTextOnly, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t, func(config *Config) { config.PhotoRetryMaxDelay = tt.maxDelay })
+			server, downloads := imageServer(t, tt.handler)
+			fake.FailNext(1, tt.failures...)
+			keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Расширить", expandCallback)))
+
+			message, stage := b.sendPostPhoto(1, server.URL+"/news.png", richtext.Bold("Ставка ЦБ"), keyboard)
+			if stage != tt.wantStage {
+				t.Errorf("этап %q, ожидался %q", stage, tt.wantStage)
+			}
+			if got := downloads.Load(); got != tt.wantDownloads {
+				t.Errorf("скачиваний %d, ожидалось %d", got, tt.wantDownloads)
+			}
+
+			sent := fake.SentTo(1)
+			if tt.wantStage == photoTextOnly {
+				if len(sent) != 0 || message.MessageID != 0 {
+					t.Errorf("фото отправлено: %+v", sent)
+				}
+				return
+			}
+			if len(sent) != 1 || message.MessageID != sent[0].MessageID {
+				t.Fatalf("отправлено %d сообщений, номер %d", len(sent), message.MessageID)
+			}
+			photo := sent[0].Config.(tgbotapi.PhotoConfig)
+			if photo.Caption != "Ставка ЦБ" || len(photo.CaptionEntities) != 1 || sent[0].Keyboard == nil {
+				t.Errorf("подпись %q, сущности %+v, клавиатура %v", photo.Caption, photo.CaptionEntities, sent[0].Keyboard)
+			}
+			// Скачанная картинка загружается байтами с расширением по ее типу
+			if tt.wantStage == photoUploaded {
+				file, ok := photo.File.(tgbotapi.FileBytes)
+				if !ok || file.Name != "image.png" || !bytes.Equal(file.Bytes, testImage) {
+					t.Errorf("загружен файл %+v", photo.File)
+				}
+			} else if file, ok := photo.File.(tgbotapi.FileURL); !ok || string(file) != server.URL+"/news.png" {
+				t.Errorf("фото по ссылке %+v", photo.File)
+			}
+		})
+	}
+}
+
+func TestDownloadPhoto(t *testing.T) {
+	oversized := bytes.Repeat([]byte{0}, maxPhotoDownloadSize+1)
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantName string
+		wantErr  string
+	}{
+		{"png", serveImage("image/png", testImage), "image.png", ""},
+		{"тип с параметрами", serveImage("image/webp; charset=binary", testImage), "image.webp", ""},
+		{"неизвестный тип картинки", serveImage("image/bmp", testImage), "image.jpg", ""},
+		{"не картинка", serveImage("text/html", []byte("<html>")), "", "не картинка"},
+		{"без типа", serveImage("", testImage), "", "не картинка"},
+		{"ошибка сайта", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}, "", "статус код: 403"},
+		// Размер из заголовка проверяется до скачивания
+		{"объявлено больше 10 МБ", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("Content-Length", strconv.Itoa(len(oversized)))
+			w.Write(oversized)
+		}, "", "картинка больше 10 МБ: "},
+		// Без размера в заголовке скачивается не больше предела
+		{"больше 10 МБ без размера", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/jpeg")
+			for i := 0; i < len(oversized); i += 1 << 20 {
+				w.Write(oversized[i:min(i+1<<20, len(oversized))])
+				w.(http.Flusher).Flush()
+			}
+		}, "", "картинка больше 10 МБ"},
+		{"ровно 10 МБ", serveImage("image/jpeg", oversized[:maxPhotoDownloadSize]), "image.jpg", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, _ := imageServer(t, tt.handler)
+			image, name, err := downloadPhoto(server.URL + "/news.jpg")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ошибка %v, ожидалась %q", err, tt.wantErr)
+				}
+				if image != nil {
+					t.Errorf("вместе с ошибкой скачано %d байт", len(image))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name != tt.wantName || len(image) == 0 {
+				t.Errorf("имя %q, %d байт", name, len(image))
+			}
+		})
+	}
+}
+
+func TestSendPostFallsBackToText(t *testing.T) {
+	b, fake := newTestBot(t, func(config *Config) { config.PhotoRetryMaxDelay = 0 })
+	server, downloads := imageServer(t, serveImage("image/png", testImage))
+	// Telegram не принимает картинку ни по ссылке, ни байтами
+	fake.FailNext(1, errors.New("failed to get HTTP URL content"), errors.New("IMAGE_PROCESS_FAILED"))
+
+	post := newFakeGPT().post
+	messageID := b.sendPost(context.Background(), 1, server.URL+"/news.png", post, ai.SourceArticle{})
+	if messageID == 0 {
+		t.Fatal("пост не отправлен")
+	}
+	if downloads.Load() != 1 {
+		t.Errorf("скачиваний %d", downloads.Load())
+	}
+
+	sent := fake.SentTo(1)
+	if len(sent) != 1 || sent[0].MessageID != messageID {
+		t.Fatalf("отправлено: %+v", sent)
+	}
+	if _, ok := sent[0].Config.(tgbotapi.MessageConfig); !ok || !strings.Contains(sent[0].Text, post.Body) || sent[0].Keyboard == nil {
+		t.Errorf("пост отправлен не текстом с кнопками: %+v", sent[0])
+	}
+}
@@ -0,0 +1,105 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// premiumSweepInterval как часто снимается закончившийся премиум
+	premiumSweepInterval = 24 * time.Hour
+	// maxPremiumDays на сколько дней можно продлить премиум одной командой
+	maxPremiumDays = 366
+)
+
+// handlePremium показывает преимущества премиума и его срок у пользователя
+func (b *Bot) handlePremium(msg *tgbotapi.Message) {
+	user := b.db.GetUser(msg.Chat.ID)
+	if user.IsPremium(time.Now()) {
+		b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "premium.active", user.PremiumUntil.Format("02.01.2006 15:04")))
+		return
+	}
+	b.sendMessage(msg.Chat.ID, b.t(msg.Chat.ID, "premium.inactive"))
+}
+
+// handleSetPremium продлевает или отменяет премиум: /setpremium пароль chatid дней
+func (b *Bot) handleSetPremium(msg *tgbotapi.Message) {
+	parts := strings.Fields(msg.CommandArguments())
+	if len(parts) != 3 {
+		b.sendMessage(msg.Chat.ID, "🔐 Использование:\n/setpremium пароль chatid дней\n\n"+
+			"Премиум продлевается на указанное число дней, 0 — отменить премиум")
+		return
+	}
+
+	if parts[0] != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+
+	chatID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный chatid. Должен быть числом.")
+		return
+	}
+
+	days, err := strconv.Atoi(parts[2])
+	if err != nil || days < 0 || days > maxPremiumDays {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Неверное число дней. Должно быть от 0 до %d.", maxPremiumDays))
+		return
+	}
+
+	until, err := b.db.SetPremium(chatID, days)
+	if err != nil {
+		log.Printf("[PREMIUM] ❌ Ошибка изменения премиума %d: %v", chatID, err)
+		b.sendMessage(msg.Chat.ID, "❌ Ошибка сохранения премиума")
+		return
+	}
+
+	if days == 0 {
+		log.Printf("[PREMIUM] Премиум пользователя %d отменен", chatID)
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ Премиум пользователя %d отменен", chatID))
+		return
+	}
+	log.Printf("[PREMIUM] Премиум пользователя %d продлен до %s", chatID, until.Format("02.01.2006 15:04"))
+	b.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ Премиум пользователя %d действует до %s",
+		chatID, until.Format("02.01.2006 15:04")))
+	b.sendMessage(chatID, b.t(chatID, "premium.active", until.Format("02.01.2006 15:04")))
+}
+
+// runPremiumSweep раз в сутки снимает закончившийся премиум и сообщает об этом
+// пользователям. Премиум проверяется по дате и без этого, обход нужен для уведомления.
+func (b *Bot) runPremiumSweep(ctx context.Context) {
+	ticker := time.NewTicker(premiumSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		b.sweepPremium(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sweepPremium снимает премиум, закончившийся к now
+func (b *Bot) sweepPremium(now time.Time) {
+	expired, err := b.db.ExpirePremium(now)
+	if err != nil {
+		log.Printf("[PREMIUM] ❌ Ошибка сохранения после снятия премиума: %v", err)
+	}
+	for _, userID := range expired {
+		b.sendMessage(userID, b.t(userID, "premium.expired"))
+	}
+	if len(expired) > 0 {
+		log.Printf("[PREMIUM] Закончился премиум у %d пользователей", len(expired))
+	}
+}
@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/i18n"
+)
+
+// tierGPT модель-заглушка, у которой можно выбрать модель по умолчанию
+type tierGPT struct {
+	*fakeGPT
+	tier ai.ModelTier
+}
+
+func (g *tierGPT) DefaultTier() ai.ModelTier        { return g.tier }
+func (g *tierGPT) SetDefaultTier(tier ai.ModelTier) { g.tier = tier }
+
+func TestPremiumCommand(t *testing.T) {
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	fake.Feed(commandUpdate(1, "/premium"))
+	waitFor(t, "описание премиума", func() bool { return fake.LastText(1) == i18n.T("ru", "premium.inactive") })
+
+	until, err := b.db.SetPremium(1, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.Feed(commandUpdate(1, "/premium"))
+	want := i18n.T("ru", "premium.active", until.Format("02.01.2006 15:04"))
+	waitFor(t, "срок премиума", func() bool { return fake.LastText(1) == want })
+}
+
+func TestSetPremiumCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    string
+		// wantPremium премиум пользователя 2 после команды
+		wantPremium bool
+	}{
+		{"без аргументов", "/setpremium " + testAdminPassword, "🔐 Использование:\n/setpremium", false},
+		{"неверный пароль", "/setpremium wrong 2 30", "❌ Неверный пароль", false},
+		{"неверный chatid", "/setpremium " + testAdminPassword + " abc 30", "❌ Неверный chatid", false},
+		{"слишком долго", "/setpremium " + testAdminPassword + " 2 400", "❌ Неверное число дней", false},
+		{"продление", "/setpremium " + testAdminPassword + " 2 30", "✅ Премиум пользователя 2 действует до", true},
+		{"отмена", "/setpremium " + testAdminPassword + " 2 0", "✅ Премиум пользователя 2 отменен", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			runBot(t, b)
+
+			fake.Feed(commandUpdate(testAdminChatID, tt.command))
+			waitFor(t, tt.want, func() bool { return sentText(fake, testAdminChatID, tt.want) })
+			if premium := b.db.IsPremium(2); premium != tt.wantPremium {
+				t.Errorf("премиум %t, ожидалось %t", premium, tt.wantPremium)
+			}
+			// О продлении пользователю сообщается, об ошибках и отмене — нет
+			if notified := strings.HasPrefix(fake.LastText(2), "💎 Премиум активен до"); notified != tt.wantPremium {
+				t.Errorf("уведомление пользователя: %q", fake.LastText(2))
+			}
+		})
+	}
+}
+
+func TestSweepPremium(t *testing.T) {
+	b, fake := newTestBot(t)
+	for _, userID := range []int64{1, 2} {
+		if _, err := b.db.SetPremium(userID, 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := b.db.SetPremium(3, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	// Через двое суток закончился премиум у первых двух
+	b.sweepPremium(time.Now().Add(48 * time.Hour))
+	for _, userID := range []int64{1, 2} {
+		if text := fake.LastText(userID); text != i18n.T("ru", "premium.expired") {
+			t.Errorf("пользователю %d отправлено %q", userID, text)
+		}
+		if !b.db.GetUser(userID).PremiumUntil.IsZero() {
+			t.Errorf("премиум пользователя %d не снят", userID)
+		}
+	}
+	if sent := fake.SentTo(3); len(sent) != 0 || !b.db.IsPremium(3) {
+		t.Errorf("действующий премиум: %+v", sent)
+	}
+
+	// Повторный обход не уведомляет снова
+	b.sweepPremium(time.Now().Add(48 * time.Hour))
+	if sent := fake.SentTo(1); len(sent) != 1 {
+		t.Errorf("уведомлений %d", len(sent))
+	}
+}
+
+func TestPremiumQueuePriority(t *testing.T) {
+	b, fake, _ := queueBot(t, 1, 10)
+	if _, err := b.db.SetPremium(3, 30); err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan int64, 10)
+	release := make(chan struct{})
+	defer close(release)
+
+	b.enqueueGeneration(1, blockingJob(started, 1, release))
+	<-started
+	b.enqueueGeneration(2, blockingJob(started, 2, release))
+	b.enqueueGeneration(3, blockingJob(started, 3, release))
+
+	// Премиум-пользователь встает перед обычным
+	if text := fake.LastText(3); text != i18n.T("ru", "generate.queued", 1) {
+		t.Errorf("позиция премиум-пользователя: %q", text)
+	}
+	release <- struct{}{}
+	if next := <-started; next != 3 {
+		t.Errorf("после первой началась генерация %d, ожидалась 3", next)
+	}
+}
+
+func TestPremiumModelTier(t *testing.T) {
+	b, _ := newTestBot(t)
+	if _, err := b.db.SetPremium(1, 30); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Без выбора модели у клиента контекст не меняется ни у кого
+	b.gptClient = newFakeGPT()
+	if b.withUserTier(ctx, 1) != ctx {
+		t.Error("модель выбрана у клиента без выбора модели")
+	}
+
+	b.gptClient = &tierGPT{fakeGPT: newFakeGPT(), tier: ai.TierLite}
+	if b.withUserTier(ctx, 1) == ctx {
+		t.Error("премиум-пользователь не получил полную модель")
+	}
+	if b.withUserTier(ctx, 2) != ctx {
+		t.Error("обычный пользователь получил полную модель")
+	}
+}
+
+func TestPremiumSkipsFeedbackReminders(t *testing.T) {
+	b, _ := newTestBot(t)
+	for _, userID := range []int64{1, 2} {
+		if _, err := b.db.AdjustGenerations(userID, 10, testAdminChatID, "тест", true); err != nil {
+			t.Fatal(err)
+		}
+		for range 3 {
+			b.db.IncrementGenerationsCount(userID)
+		}
+	}
+	if _, err := b.db.SetPremium(2, 30); err != nil {
+		t.Fatal(err)
+	}
+
+	if !b.db.ShouldRemindFeedback(1) {
+		t.Error("обычному пользователю не напоминается об отзыве")
+	}
+	if b.db.ShouldRemindFeedback(2) {
+		t.Error("премиум-пользователю напоминается об отзыве")
+	}
+}
@@ -0,0 +1,224 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/generator"
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/richtext"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// previewTTL сколько ждет подтверждения пост, показанный в предпросмотре
+	previewTTL = 15 * time.Minute
+	// previewVisibleShare какая доля текста поста видна в предпросмотре
+	previewVisibleShare = 0.4
+
+	previewCallbackPrefix = "preview_"
+	previewConfirm        = "confirm"
+	previewDecline        = "decline"
+)
+
+// readyPost пост, прошедший генерацию и проверки: осталось отправить его и списать генерацию
+type readyPost struct {
+	// ctx контекст генерации. Нужны только его значения — язык, способ указать источник,
+	// ключ повтора запроса: к подтверждению предпросмотра дедлайн уже истечет.
+	ctx       context.Context
+	userID    int64
+	requestID string
+	post      ai.Post
+	imageURL  string
+	article   ai.SourceArticle
+	// topic тема для журнала генераций, source — источник новости для него
+	topic  string
+	source string
+	// ratingTopic тема в кнопках оценки
+	ratingTopic string
+	// metadata текст сообщения с метаданными при оставшихся generations генерациях
+	metadata func(generations int) richtext.Text
+	// done итог в сообщении прогресса при успехе
+	done string
+	// reservation генерация, зарезервированная под пост; nil, если пост ничего не стоит
+	reservation *generator.Reservation
+}
+
+// deliverReadyPost отправляет пост и списывает зарезервированную под него генерацию,
+// затем отправляет метаданные и кнопки оценки. Если пост не дошел до пользователя,
+// резерв остается у вызывающего: обработчик генерации его возвращает, а предпросмотр
+// держит до повторной попытки. progress и trace nil, когда пост отправляется
+// по подтверждению предпросмотра.
+func (b *Bot) deliverReadyPost(ready *readyPost, progress *progressMessage, trace *generationTrace) bool {
+	userID, lang := ready.userID, b.lang(ready.userID)
+
+	trace.stage(stageDelivery)
+	if b.sendPost(ready.ctx, userID, ready.imageURL, ready.post, ready.article) == 0 {
+		log.Printf("[GENERATE] ❌ Пост не доставлен пользователю %d, генерация не списывается", userID)
+		if progress != nil {
+			b.failGeneration(ready.ctx, progress, i18n.T(lang, "generate.delivery_failed"))
+		}
+		return false
+	}
+
+	trace.stage(stageCharge)
+	ready.reservation.Commit()
+
+	b.db.AddGeneration(userID, ready.topic, ready.source, ready.requestID)
+	b.recordVariant(ready.ctx, ready.requestID)
+	// Увеличиваем счетчик генераций для напоминания об отзыве
+	b.db.IncrementGenerationsCount(userID)
+	if progress != nil {
+		progress.finish(ready.done)
+	}
+
+	b.sendRichText(userID, ready.metadata(b.db.GetUser(userID).AvailableGenerations), nil)
+	b.sendRatingRequest(userID, ready.ratingTopic)
+	if b.db.ShouldRemindFeedback(userID) {
+		b.sendFeedbackReminder(userID)
+	}
+	return true
+}
+
+// pendingPreview пост в предпросмотре, ожидающий решения пользователя. Полный текст
+// хранится только здесь: пользователь видит его часть.
+type pendingPreview struct {
+	id        string
+	ready     *readyPost
+	messageID int
+	expires   time.Time
+	timer     *time.Timer
+}
+
+// previewWanted показывать ли пользователю пост сначала в предпросмотре
+func (b *Bot) previewWanted(userID int64) bool {
+	return b.db.GetSettings(userID).PreviewBeforeCharge
+}
+
+// hasPreview ждет ли решения пост в предпросмотре. Пока ждет, новая генерация
+// не начинается: иначе можно было бы смотреть предпросмотры без списаний.
+func (b *Bot) hasPreview(userID int64) bool {
+	b.previewsMu.Lock()
+	defer b.previewsMu.Unlock()
+	_, ok := b.previews[userID]
+	return ok
+}
+
+// offerPreview показывает часть поста с кнопками «Забрать» и «Отказаться». Генерация
+// не списывается, пока пользователь не заберет пост, но до его решения остается
+// в резерве: отказ и истечение срока ее возвращают.
+func (b *Bot) offerPreview(ready *readyPost, progress *progressMessage) bool {
+	userID, lang := ready.userID, b.lang(ready.userID)
+	id := newRequestID()
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "preview.confirm_button"), previewCallbackPrefix+previewConfirm+"_"+id),
+		tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "preview.decline_button"), previewCallbackPrefix+previewDecline+"_"+id),
+	))
+
+	progress.finish(i18n.T(lang, "preview.ready"))
+	message := b.sendRichText(userID, previewText(postMessageText(lang, ready.post)), &keyboard)
+	if message.MessageID == 0 {
+		return false
+	}
+
+	b.holdPreview(&pendingPreview{id: id, ready: ready, messageID: message.MessageID, expires: time.Now().Add(previewTTL)})
+	log.Printf("[PREVIEW] Пост для %d показан в предпросмотре, ждет решения до %s", userID, time.Now().Add(previewTTL).Format("15:04:05"))
+	return true
+}
+
+// holdPreview запоминает предпросмотр и заводит таймер его истечения
+func (b *Bot) holdPreview(preview *pendingPreview) {
+	b.previewsMu.Lock()
+	defer b.previewsMu.Unlock()
+	userID := preview.ready.userID
+	preview.timer = time.AfterFunc(time.Until(preview.expires), func() { b.expirePreview(userID, preview.id) })
+	b.previews[userID] = preview
+}
+
+// takePreview забирает предпросмотр id пользователя. Забирается один раз: повторное
+// нажатие кнопки не спишет генерацию второй раз.
+func (b *Bot) takePreview(userID int64, id string) (*pendingPreview, bool) {
+	b.previewsMu.Lock()
+	defer b.previewsMu.Unlock()
+
+	preview, ok := b.previews[userID]
+	if !ok || preview.id != id {
+		return nil, false
+	}
+	delete(b.previews, userID)
+	preview.timer.Stop()
+	return preview, true
+}
+
+// dropPreview забывает предпросмотр пользователя без записи в журнал
+func (b *Bot) dropPreview(userID int64) {
+	b.previewsMu.Lock()
+	defer b.previewsMu.Unlock()
+	if preview, ok := b.previews[userID]; ok {
+		preview.timer.Stop()
+		delete(b.previews, userID)
+		preview.ready.reservation.Release()
+	}
+}
+
+// expirePreview снимает предпросмотр, который не подтвердили за previewTTL
+func (b *Bot) expirePreview(userID int64, id string) {
+	preview, ok := b.takePreview(userID, id)
+	if !ok {
+		return
+	}
+	log.Printf("[PREVIEW] Предпросмотр для %d истек, генерация возвращается", userID)
+	preview.ready.reservation.Release()
+	b.db.AddGenerationOutcome(userID, preview.ready.topic, database.OutcomePreviewExpired, "", preview.ready.requestID)
+	b.recordVariant(preview.ready.ctx, preview.ready.requestID)
+	b.editMessage(userID, preview.messageID, b.t(userID, "preview.expired"))
+}
+
+// handlePreviewCallback отдает пост по подтверждению или отказывается от него
+func (b *Bot) handlePreviewCallback(callback *tgbotapi.CallbackQuery) {
+	userID, messageID := callback.Message.Chat.ID, callback.Message.MessageID
+	action, id, _ := strings.Cut(strings.TrimPrefix(callback.Data, previewCallbackPrefix), "_")
+
+	preview, ok := b.takePreview(userID, id)
+	if !ok {
+		b.editMessage(userID, messageID, b.t(userID, "preview.stale"))
+		return
+	}
+
+	if action != previewConfirm {
+		log.Printf("[PREVIEW] Пользователь %d отказался от поста", userID)
+		preview.ready.reservation.Release()
+		b.db.AddGenerationOutcome(userID, preview.ready.topic, database.OutcomeDeclined, "", preview.ready.requestID)
+		b.recordVariant(preview.ready.ctx, preview.ready.requestID)
+		b.editMessage(userID, messageID, b.t(userID, "preview.declined"))
+		return
+	}
+
+	if !b.deliverReadyPost(preview.ready, nil, nil) {
+		// Генерация не списана и остается в резерве: пост можно забрать еще раз, пока не истек срок
+		b.holdPreview(preview)
+		b.sendMessage(userID, b.t(userID, "preview.delivery_failed"))
+		return
+	}
+	log.Printf("[PREVIEW] ✅ Пользователь %d забрал пост", userID)
+	b.deleteMessage(userID, messageID)
+}
+
+// previewText часть поста для предпросмотра: первые previewVisibleShare текста до границы
+// слова, а остальное скрыто блоками ▓ с сохранением пробелов и переносов
+func previewText(text richtext.Text) richtext.Text {
+	visible := text.Cut(int(float64(text.Len()) * previewVisibleShare))
+	hidden := []rune(strings.TrimSpace(strings.TrimPrefix(text.String(), visible.String())))
+	for i, r := range hidden {
+		if !unicode.IsSpace(r) {
+			hidden[i] = '▓'
+		}
+	}
+	return visible.Append(richtext.Plain("… " + string(hidden)))
+}
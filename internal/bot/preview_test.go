@@ -0,0 +1,192 @@
+package bot
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/news"
+	"AIGenerator/internal/richtext"
+	"AIGenerator/internal/testutil"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// previewBody текст поста заглушки модели: в предпросмотре он скрыт
+const previewBody = "Банк России оставил ставку на уровне 21%."
+
+func TestPreviewText(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         richtext.Text
+		want         string
+		wantEntities []tgbotapi.MessageEntity
+	}{
+		{"по границе слова", richtext.Plain("Биткоин вырос на десять процентов за сутки"),
+			"Биткоин вырос на… ▓▓▓▓▓▓ ▓▓▓▓▓▓▓▓▓ ▓▓ ▓▓▓▓▓", nil},
+		// Разметка видимой части сохраняется
+		{"заголовок", richtext.Concat(richtext.Bold("Ставка ЦБ"), richtext.Plain("\n\nРегулятор сохранил ставку")),
+			"Ставка ЦБ… ▓▓▓▓▓▓▓▓▓ ▓▓▓▓▓▓▓▓ ▓▓▓▓▓▓", []tgbotapi.MessageEntity{{Type: "bold", Offset: 0, Length: 9}}},
+		// Переносы строк в скрытой части остаются на месте
+		{"абзацы", richtext.Plain("Один два три четыре пять.\n\nШесть семь"),
+			"Один два три… ▓▓▓▓▓▓ ▓▓▓▓▓\n\n▓▓▓▓▓ ▓▓▓▓", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := previewText(tt.text)
+			if got.String() != tt.want {
+				t.Errorf("предпросмотр %q, ожидался %q", got.String(), tt.want)
+			}
+			if !slices.Equal(got.Entities(), tt.wantEntities) {
+				t.Errorf("сущности %+v, ожидались %+v", got.Entities(), tt.wantEntities)
+			}
+		})
+	}
+}
+
+// startPreview запускает бота с предпросмотром у пользователя 1 и ждет, пока его пост
+// окажется в предпросмотре. Возвращает код предпросмотра и сообщение с ним.
+func startPreview(t *testing.T) (*Bot, *testutil.FakeTelegram, string, int) {
+	t.Helper()
+	b, fake := newTestBot(t)
+	article := news.Article{Title: "Ставка ЦБ", URL: "https://example.com/rate", Source: "РБК"}
+	useGenerator(b, newFakeGPT(), &fakeNews{articles: []news.Article{article}})
+	if _, err := b.db.UpdateSettings(1, func(settings *database.Settings) { settings.PreviewBeforeCharge = true }); err != nil {
+		t.Fatal(err)
+	}
+	runBot(t, b)
+
+	fake.Feed(commandUpdate(1, "/generate ставка цб"))
+	waitFor(t, "предпросмотр", func() bool { return b.hasPreview(1) })
+	waitGenerations(t, b)
+
+	b.previewsMu.Lock()
+	defer b.previewsMu.Unlock()
+	preview := b.previews[1]
+	return b, fake, preview.id, preview.messageID
+}
+
+// postsDelivered сколько раз пользователю 1 отправлен полный пост
+func postsDelivered(fake *testutil.FakeTelegram) int {
+	delivered := 0
+	for _, sent := range fake.SentTo(1) {
+		if _, message := sent.Config.(tgbotapi.MessageConfig); message && strings.Contains(sent.Text, previewBody) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// wantBalance проверяет доступные, зарезервированные и списанные генерации пользователя 1
+func wantBalance(t *testing.T, b *Bot, available, reserved, total int) {
+	t.Helper()
+	user := b.db.GetUser(1)
+	if user.AvailableGenerations != available || user.ReservedGenerations != reserved || user.TotalGenerations != total {
+		t.Errorf("доступно %d, в резерве %d, списано %d; ожидалось %d, %d, %d",
+			user.AvailableGenerations, user.ReservedGenerations, user.TotalGenerations, available, reserved, total)
+	}
+}
+
+// generationOutcomes исходы генераций в журнале
+func generationOutcomes(b *Bot) []string {
+	generations, _, _ := b.db.History()
+	var outcomes []string
+	for _, generation := range generations {
+		outcomes = append(outcomes, generation.Outcome)
+	}
+	return outcomes
+}
+
+func TestPreviewHoldsPostUntilConfirmed(t *testing.T) {
+	b, fake, id, messageID := startPreview(t)
+
+	// Пока пост в предпросмотре, его текст есть только на сервере, а генерация в резерве
+	if delivered := postsDelivered(fake); delivered != 0 {
+		t.Fatalf("полный пост отправлен до подтверждения: %d", delivered)
+	}
+	if !sentText(fake, 1, "▓") {
+		t.Error("нет предпросмотра со скрытой частью")
+	}
+	wantBalance(t, b, testTrialGenerations-1, 1, 0)
+
+	// Второе нажатие «Забрать» не отправляет пост и не списывает генерацию еще раз
+	confirm := previewCallbackPrefix + previewConfirm + "_" + id
+	fake.Feed(callbackUpdate(1, messageID, confirm))
+	fake.Feed(callbackUpdate(1, messageID, confirm))
+	waitFor(t, "повторное нажатие", func() bool { return sentText(fake, 1, i18n.T("ru", "preview.stale")) })
+
+	if delivered := postsDelivered(fake); delivered != 1 {
+		t.Errorf("пост отправлен %d раз", delivered)
+	}
+	wantBalance(t, b, testTrialGenerations-1, 0, 1)
+	if outcomes := generationOutcomes(b); !slices.Equal(outcomes, []string{database.OutcomeSuccess}) {
+		t.Errorf("журнал генераций: %q", outcomes)
+	}
+	if b.hasPreview(1) {
+		t.Error("предпросмотр остался после подтверждения")
+	}
+}
+
+func TestPreviewDeliveryFailureKeepsReservation(t *testing.T) {
+	b, fake, id, messageID := startPreview(t)
+
+	// Пост не ушел ни с разметкой, ни без нее: генерация не списана, предпросмотр снова ждет
+	fake.FailNext(1, errors.New("Bad Gateway"), errors.New("Bad Gateway"))
+	confirm := previewCallbackPrefix + previewConfirm + "_" + id
+	fake.Feed(callbackUpdate(1, messageID, confirm))
+	waitFor(t, "сообщение о сбое", func() bool { return sentText(fake, 1, i18n.T("ru", "preview.delivery_failed")) })
+
+	if !b.hasPreview(1) {
+		t.Fatal("предпросмотр не вернулся после сбоя отправки")
+	}
+	wantBalance(t, b, testTrialGenerations-1, 1, 0)
+	if outcomes := generationOutcomes(b); len(outcomes) != 0 {
+		t.Errorf("журнал генераций после сбоя: %q", outcomes)
+	}
+
+	// Повторное нажатие отправляет пост и списывает генерацию
+	fake.Feed(callbackUpdate(1, messageID, confirm))
+	waitFor(t, "пост", func() bool { return postsDelivered(fake) == 1 })
+	waitFor(t, "списание", func() bool { return b.db.GetUser(1).TotalGenerations == 1 })
+	wantBalance(t, b, testTrialGenerations-1, 0, 1)
+}
+
+func TestPreviewReleasesReservation(t *testing.T) {
+	tests := []struct {
+		name        string
+		settle      func(b *Bot, fake *testutil.FakeTelegram, id string, messageID int)
+		wantText    string
+		wantOutcome string
+	}{
+		{"отказ", func(b *Bot, fake *testutil.FakeTelegram, id string, messageID int) {
+			fake.Feed(callbackUpdate(1, messageID, previewCallbackPrefix+previewDecline+"_"+id))
+		}, i18n.T("ru", "preview.declined"), database.OutcomeDeclined},
+		// Таймер предпросмотра сработал
+		{"истечение срока", func(b *Bot, fake *testutil.FakeTelegram, id string, messageID int) {
+			b.expirePreview(1, id)
+		}, i18n.T("ru", "preview.expired"), database.OutcomePreviewExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake, id, messageID := startPreview(t)
+
+			tt.settle(b, fake, id, messageID)
+			waitFor(t, "итог предпросмотра", func() bool { return sentText(fake, 1, tt.wantText) })
+			wantBalance(t, b, testTrialGenerations, 0, 0)
+			if outcomes := generationOutcomes(b); !slices.Equal(outcomes, []string{tt.wantOutcome}) {
+				t.Errorf("журнал генераций: %q", outcomes)
+			}
+
+			// Забрать пост после отказа или истечения срока нельзя
+			fake.Feed(callbackUpdate(1, messageID, previewCallbackPrefix+previewConfirm+"_"+id))
+			waitFor(t, "устаревшее нажатие", func() bool { return sentText(fake, 1, i18n.T("ru", "preview.stale")) })
+			if delivered := postsDelivered(fake); delivered != 0 {
+				t.Errorf("пост отправлен %d раз", delivered)
+			}
+			wantBalance(t, b, testTrialGenerations, 0, 0)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/i18n"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	deleteDataCallbackPrefix = "deletedata_"
+	deleteDataConfirm        = deleteDataCallbackPrefix + "confirm"
+	deleteDataCancel         = deleteDataCallbackPrefix + "cancel"
+	// deleteDataWait сколько ждать завершения прерванных генераций пользователя
+	deleteDataWait = 30 * time.Second
+)
+
+// handleDeleteMyData спрашивает подтверждение удаления данных пользователя
+func (b *Bot) handleDeleteMyData(msg *tgbotapi.Message) {
+	userID := msg.Chat.ID
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(b.t(userID, "deletedata.confirm_button"), deleteDataConfirm),
+		tgbotapi.NewInlineKeyboardButtonData(b.t(userID, "deletedata.cancel_button"), deleteDataCancel),
+	))
+	b.sendMessageWithKeyboard(userID, b.t(userID, "deletedata.prompt"), keyboard)
+}
+
+// handleDeleteDataCallback удаляет данные пользователя после подтверждения
+func (b *Bot) handleDeleteDataCallback(callback *tgbotapi.CallbackQuery) {
+	userID, messageID := callback.Message.Chat.ID, callback.Message.MessageID
+	// Язык интерфейса хранится в настройках, которые сейчас будут удалены
+	lang := b.lang(userID)
+
+	if callback.Data != deleteDataConfirm {
+		b.editMessage(userID, messageID, i18n.T(lang, "deletedata.canceled"))
+		return
+	}
+
+	if _, err := b.deleteUserData(userID); err != nil {
+		b.editMessage(userID, messageID, i18n.T(lang, "deletedata.failed"))
+		return
+	}
+	b.editMessage(userID, messageID, i18n.T(lang, "deletedata.done"))
+}
+
+// deleteUserData удаляет данные пользователя из базы, журнала событий и журнала запросов к модели.
+// Сначала прерываются его генерации, чтобы ни одна не записала данные после удаления;
+// новые не начнутся, пока идет удаление: обновления чата обрабатываются по очереди.
+func (b *Bot) deleteUserData(userID int64) (database.UserErasure, error) {
+	if !b.cancelGenerations(userID, deleteDataWait) {
+		log.Printf("[PRIVACY] ⚠️ Генерация %d не завершилась за %v, удаление отложено", userID, deleteDataWait)
+		return database.UserErasure{}, fmt.Errorf("генерация пользователя %d не завершилась", userID)
+	}
+
+	erasure, err := b.db.DeleteUserData(userID)
+	if err != nil {
+		log.Printf("[PRIVACY] ❌ Ошибка удаления данных пользователя %d: %v", userID, err)
+		return erasure, err
+	}
+
+	b.postsMu.Lock()
+	delete(b.posts, userID)
+	b.postsMu.Unlock()
+	b.inlinePosts.forget(userID)
+	b.dropPreview(userID)
+
+	anonymized, err := b.events.Anonymize(userID)
+	if err != nil {
+		log.Printf("[PRIVACY] ❌ Ошибка обезличивания событий пользователя %d: %v", userID, err)
+	}
+	audited, err := ai.AnonymizeAuditUser(userID)
+	if err != nil {
+		log.Printf("[PRIVACY] ❌ Ошибка обезличивания журнала запросов пользователя %d: %v", userID, err)
+	}
+
+	log.Printf("[PRIVACY] Удалены данные пользователя %d: %+v, обезличено событий: %d, записей журнала запросов: %d",
+		userID, erasure, anonymized, audited)
+	b.notifyAdmin(fmt.Sprintf("🗑 Пользователь %d удалил свои данные (/deletemydata)\n\n"+
+		"Запись пользователя: %s\n"+
+		"Генераций удалено: %d\n"+
+		"Оценок удалено: %d\n"+
+		"Покупок обезличено: %d\n"+
+		"Неоплаченных платежей отменено: %d\n"+
+		"Ручных изменений баланса обезличено: %d\n"+
+		"Событий обезличено: %d\n"+
+		"Записей журнала запросов обезличено: %d",
+		userID, foundText(erasure.Found), erasure.Generations, erasure.Ratings,
+		erasure.Purchases, erasure.CanceledPayments, erasure.Adjustments, anonymized, audited), false, "")
+	return erasure, nil
+}
+
+// foundText была ли запись пользователя, для уведомления администратору
+func foundText(found bool) string {
+	if found {
+		return "удалена"
+	}
+	return "не было"
+}
@@ -0,0 +1,171 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/calendar"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/events"
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/news"
+)
+
+// useAudit включает журнал запросов к модели в каталоге dir и записывает в него по запросу
+// каждого пользователя из users
+func useAudit(t *testing.T, dir string, users ...int64) {
+	t.Helper()
+	config := ai.DefaultAIConfig()
+	config.Audit.Enabled, config.Audit.Dir, config.Audit.Responses = true, dir, true
+	if err := ai.Configure(config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ai.Configure(ai.DefaultAIConfig()) })
+
+	var lines []byte
+	for _, userID := range users {
+		line, _ := json.Marshal(ai.AuditEntry{Time: time.Now(), UserID: userID, Type: ai.RequestPost,
+			Article: "https://example.com/rate", Response: fmt.Sprintf("Пост для %d", userID), Usage: ai.Usage{TotalTokens: 100}})
+		lines = append(append(lines, line...), '\n')
+	}
+	path := filepath.Join(dir, "ai_audit-"+time.Now().Format("2006-01-02")+"-01.jsonl")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, lines, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// allTime период, в который попадает все, что записал тест
+func allTime() map[string]calendar.Period {
+	return map[string]calendar.Period{"all": {From: time.Now().Add(-time.Hour), To: time.Now().Add(time.Hour)}}
+}
+
+func TestDeleteMyDataErasesEveryStore(t *testing.T) {
+	b, fake := newTestBot(t)
+	pipeline := events.New(events.Config{File: "events.jsonl"})
+	if err := pipeline.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pipeline.Close(time.Second) })
+	b.SetEvents(pipeline)
+	useAudit(t, "audit", 1, 2)
+	article := news.Article{Title: "Ставка ЦБ", URL: "https://example.com/rate", Source: "РБК"}
+	useGenerator(b, newFakeGPT(), &fakeNews{articles: []news.Article{article}})
+	runBot(t, b)
+
+	// У обоих пользователей есть посты, оценки, покупки и ручные начисления
+	for _, userID := range []int64{1, 2} {
+		fake.Feed(commandUpdate(userID, "/generate ставка цб"))
+		waitFor(t, "генерация", func() bool { return balance(b, userID) == testTrialGenerations-1 })
+		waitGenerations(t, b)
+		b.db.AddRating(userID, 5, "ставка цб")
+		b.db.AddPurchase(userID, "10", 99)
+		b.db.AdjustGenerations(userID, 1, testAdminChatID, "компенсация", false)
+	}
+	yooKassa, _ := newFakeYooKassa(t)
+	addPending(t, b, yooKassa, "pay-1", time.Now())
+	// Второй пост пользователя 1 ждет решения в предпросмотре
+	b.db.UpdateSettings(1, func(settings *database.Settings) { settings.PreviewBeforeCharge = true })
+	fake.Feed(commandUpdate(1, "/generate курс рубля"))
+	waitFor(t, "предпросмотр", func() bool { return b.hasPreview(1) })
+
+	statsBefore := b.db.GetStatistics(testAdminPassword, allTime())
+	otherBalance := balance(b, 2)
+	_, purchasesBefore, _ := b.db.History()
+
+	fake.Feed(commandUpdate(1, "/deletemydata"))
+	waitFor(t, "вопрос об удалении", func() bool { return sentText(fake, 1, i18n.T("ru", "deletedata.prompt")) })
+	prompt := fake.SentTo(1)[len(fake.SentTo(1))-1]
+	fake.Feed(callbackUpdate(1, prompt.MessageID, deleteDataConfirm))
+	waitFor(t, "удаление", func() bool { return sentText(fake, 1, i18n.T("ru", "deletedata.done")) })
+	waitFor(t, "уведомление администратору", func() bool {
+		return sentText(fake, testAdminChatID, "🗑 Пользователь 1 удалил свои данные")
+	})
+
+	// База
+	for _, userID := range b.db.GetAllUsers() {
+		if userID == 1 {
+			t.Error("пользователь остался в базе")
+		}
+	}
+	if settings := b.db.GetSettings(1); settings.PreviewBeforeCharge {
+		t.Error("остались настройки пользователя")
+	}
+	generations, purchases, ratings := b.db.History()
+	for _, generation := range generations {
+		if generation.UserID == 1 {
+			t.Errorf("осталась генерация: %+v", generation)
+		}
+	}
+	for _, rating := range ratings {
+		if rating.UserID == 1 {
+			t.Errorf("осталась оценка: %+v", rating)
+		}
+	}
+	for _, purchase := range purchases {
+		if purchase.UserID == 1 {
+			t.Errorf("покупка не обезличена: %+v", purchase)
+		}
+	}
+	if b.db.GetPendingPurchase("pay-1") != nil {
+		t.Error("неоплаченный платеж все еще проверяется")
+	}
+	if adjustments := b.db.UserBalanceAdjustments(1, 10); len(adjustments) != 0 {
+		t.Errorf("остались ручные начисления: %+v", adjustments)
+	}
+
+	// Память бота
+	b.postsMu.Lock()
+	_, hasPost := b.posts[1]
+	b.postsMu.Unlock()
+	if hasPost || len(b.inlinePosts.list(1)) != 0 || b.hasPreview(1) {
+		t.Errorf("остались посты в памяти: последний %t, inline %d, предпросмотр %t", hasPost, len(b.inlinePosts.list(1)), b.hasPreview(1))
+	}
+
+	// Журналы событий и запросов к модели
+	pipeline.Close(time.Second)
+	data, _ := os.ReadFile("events.jsonl")
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var event events.Event
+		if json.Unmarshal(line, &event) == nil && event.UserID == 1 {
+			t.Errorf("событие не обезличено: %s", line)
+		}
+	}
+	if entries, err := ai.RecentAuditEntries(1, 10); err != nil || len(entries) != 0 {
+		t.Errorf("журнал запросов к модели: %+v, %v", entries, err)
+	}
+
+	// Данные другого пользователя не тронуты
+	b.postsMu.Lock()
+	_, hasOtherPost := b.posts[2]
+	b.postsMu.Unlock()
+	if !hasOtherPost || len(b.inlinePosts.list(2)) != 1 || balance(b, 2) != otherBalance {
+		t.Errorf("пострадали данные пользователя 2: пост %t, inline %d, баланс %d", hasOtherPost, len(b.inlinePosts.list(2)), balance(b, 2))
+	}
+	if entries, _ := ai.RecentAuditEntries(2, 10); len(entries) != 1 || entries[0].Response != "Пост для 2" {
+		t.Errorf("журнал запросов пользователя 2: %+v", entries)
+	}
+
+	// Выручка и число покупок в статистике не меняются, пользователей становится меньше
+	statsAfter := b.db.GetStatistics(testAdminPassword, allTime())
+	before, after := statsBefore["all"].(map[string]interface{}), statsAfter["all"].(map[string]interface{})
+	for _, key := range []string{"purchases_10", "revenue_10", "total_revenue"} {
+		if before[key] != after[key] {
+			t.Errorf("%s: %v до удаления, %v после", key, before[key], after[key])
+		}
+	}
+	if len(purchases) != len(purchasesBefore) {
+		t.Errorf("покупок %d, до удаления %d", len(purchases), len(purchasesBefore))
+	}
+	if statsAfter["total_users"] != statsBefore["total_users"].(int)-1 {
+		t.Errorf("пользователей %v, до удаления %v", statsAfter["total_users"], statsBefore["total_users"])
+	}
+}
@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// progressEditInterval минимальный интервал между правками сообщения прогресса
+const progressEditInterval = time.Second
+
+// progressMessage сообщение с ходом генерации. Правки идут по одной и не чаще interval:
+// промежуточный шаг, пришедший раньше, показывается по таймеру, а более новый его заменяет.
+// Если сообщения нет (отправка не удалась) или его нельзя править (удалено пользователем),
+// следующий шаг отправляется новым сообщением. Правка тем же текстом не отправляется,
+// а ответ Telegram «message is not modified» не считается ошибкой.
+type progressMessage struct {
+	api      TelegramSender
+	chatID   int64
+	interval time.Duration
+
+	mu sync.Mutex
+	// messageID сообщение прогресса; 0 — сообщения еще нет
+	messageID int
+	shown     string
+	lastEdit  time.Time
+	// pending шаг, ждущий своей правки по timer
+	pending  string
+	timer    *time.Timer
+	finished bool
+}
+
+// newProgressMessage сообщение прогресса в чате chatID; messageID — уже отправленное
+// сообщение, которое нужно править, или 0
+func newProgressMessage(api TelegramSender, chatID int64, messageID int, interval time.Duration) *progressMessage {
+	return &progressMessage{api: api, chatID: chatID, messageID: messageID, interval: interval}
+}
+
+// startProgress показывает первый шаг генерации: правит сообщение с позицией в очереди,
+// если генерация ждала, иначе отправляет новое
+func (b *Bot) startProgress(ctx context.Context, chatID int64, text string) *progressMessage {
+	status, _ := ctx.Value(queueStatusKey{}).(tgbotapi.Message)
+	progress := newProgressMessage(b.api, chatID, status.MessageID, progressEditInterval)
+	progress.mu.Lock()
+	progress.show(text)
+	progress.mu.Unlock()
+	return progress
+}
+
+// update показывает промежуточный шаг. Если прошлая правка была недавно, шаг покажется
+// по таймеру, если его не сменит следующий.
+func (p *progressMessage) update(text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+
+	wait := p.interval - time.Since(p.lastEdit)
+	if wait <= 0 && p.timer == nil {
+		p.show(text)
+		return
+	}
+	p.pending = text
+	if p.timer == nil {
+		p.timer = time.AfterFunc(wait, p.flush)
+	}
+}
+
+// flush показывает отложенный шаг
+func (p *progressMessage) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.timer = nil
+	if p.finished || p.pending == "" {
+		return
+	}
+	text := p.pending
+	p.pending = ""
+	p.show(text)
+}
+
+// finish показывает итог генерации. Отложенный шаг отбрасывается, последующие
+// обновления игнорируются; при недавней правке итог ждет конца интервала.
+func (p *progressMessage) finish(text string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.finished {
+		return
+	}
+	p.finished = true
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.pending = ""
+
+	if wait := p.interval - time.Since(p.lastEdit); wait > 0 {
+		time.Sleep(wait)
+	}
+	p.show(text)
+}
+
+// show правит сообщение прогресса или отправляет новое. Вызывается под p.mu.
+func (p *progressMessage) show(text string) {
+	if p.messageID != 0 && text == p.shown {
+		return
+	}
+	p.lastEdit = time.Now()
+
+	if p.messageID != 0 {
+		edit := tgbotapi.NewEditMessageText(p.chatID, p.messageID, text)
+		edit.DisableWebPagePreview = true
+		_, err := p.api.Send(edit)
+		switch {
+		case err == nil, isMessageNotModified(err):
+			p.shown = text
+			return
+		case !isMessageGone(err):
+			log.Printf("[ERROR] Ошибка редактирования сообщения %d в чате %d: %v", p.messageID, p.chatID, err)
+			return
+		}
+		log.Printf("[GENERATE] ⚠️ Сообщение прогресса %d в чате %d недоступно, отправляю новое", p.messageID, p.chatID)
+	}
+
+	msg := tgbotapi.NewMessage(p.chatID, text)
+	msg.DisableWebPagePreview = true
+	message, err := p.api.Send(msg)
+	if err != nil {
+		// Следующий шаг попробует отправить сообщение снова
+		log.Printf("[ERROR] Ошибка отправки сообщения в чат %d: %v", p.chatID, err)
+		p.messageID = 0
+		return
+	}
+	p.messageID, p.shown = message.MessageID, text
+}
+
+// isMessageNotModified ответ Telegram на правку тем же текстом
+func isMessageNotModified(err error) bool {
+	return strings.Contains(err.Error(), "message is not modified")
+}
+
+// isMessageGone ответ Telegram на правку удаленного или слишком старого сообщения
+func isMessageGone(err error) bool {
+	text := err.Error()
+	return strings.Contains(text, "message to edit not found") || strings.Contains(text, "message can't be edited")
+}
@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/testutil"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// progressLog отправки в чат 1 в виде «send N: текст» и «edit N: текст»
+func progressLog(fake *testutil.FakeTelegram) []string {
+	var result []string
+	for _, sent := range fake.SentTo(1) {
+		method := "send"
+		if _, ok := sent.Config.(tgbotapi.EditMessageTextConfig); ok {
+			method = "edit"
+		}
+		result = append(result, fmt.Sprintf("%s %d: %s", method, sent.MessageID, sent.Text))
+	}
+	return result
+}
+
+func TestProgressFailureModes(t *testing.T) {
+	tests := []struct {
+		name string
+		// messageID сообщение с позицией в очереди; 0 — его нет
+		messageID int
+		// fail ошибка первой отправки в чат
+		fail      error
+		want      []string
+		wantShown string
+	}{
+		{"правка тем же текстом", 7, errors.New("Bad Request: message is not modified"),
+			[]string{"edit 7: Шаг 2"}, "Шаг 2"},
+		// Следующие шаги правят новое сообщение, а не недоступное
+		{"сообщение удалено", 7, errors.New("Bad Request: message to edit not found"),
+			[]string{"send 1: Шаг 1", "edit 1: Шаг 2"}, "Шаг 2"},
+		{"сообщение слишком старое", 7, errors.New("Bad Request: message can't be edited"),
+			[]string{"send 1: Шаг 1", "edit 1: Шаг 2"}, "Шаг 2"},
+		// Прочие ошибки правки не плодят новых сообщений
+		{"сбой правки", 7, errors.New("Too Many Requests: retry after 1"),
+			[]string{"edit 7: Шаг 2"}, "Шаг 2"},
+		// Начальное сообщение не дошло: следующий шаг отправляется заново
+		{"сообщения нет", 0, errors.New("Internal Server Error"),
+			[]string{"send 1: Шаг 2"}, "Шаг 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := testutil.NewFakeTelegram(0)
+			fake.FailNext(1, tt.fail)
+			progress := newProgressMessage(fake, 1, tt.messageID, 0)
+			progress.update("Шаг 1")
+			progress.update("Шаг 2")
+			// Тот же текст не правится повторно
+			progress.update("Шаг 2")
+
+			if got := progressLog(fake); !slices.Equal(got, tt.want) {
+				t.Errorf("отправлено %q, ожидалось %q", got, tt.want)
+			}
+			if progress.shown != tt.wantShown {
+				t.Errorf("показано %q, ожидалось %q", progress.shown, tt.wantShown)
+			}
+		})
+	}
+}
+
+func TestProgressThrottle(t *testing.T) {
+	fake := testutil.NewFakeTelegram(0)
+	interval := 100 * time.Millisecond
+	progress := newProgressMessage(fake, 1, 7, interval)
+
+	// Первый шаг показывается сразу, следующие ждут интервала, и из них остается последний
+	progress.update("Шаг 1")
+	progress.update("Шаг 2")
+	progress.update("Шаг 3")
+	if got := progressLog(fake); !slices.Equal(got, []string{"edit 7: Шаг 1"}) {
+		t.Fatalf("до интервала: %q", got)
+	}
+	waitFor(t, "отложенный шаг", func() bool { return len(fake.SentTo(1)) == 2 })
+	if sent := fake.SentTo(1); sent[1].Text != "Шаг 3" {
+		t.Errorf("после интервала: %q", progressLog(fake))
+	}
+
+	// Итог отбрасывает отложенный шаг и ждет конца интервала
+	progress.update("Шаг 4")
+	started := time.Now()
+	progress.finish("Готово")
+	if elapsed := time.Since(started); elapsed < interval/2 {
+		t.Errorf("итог показан через %v после правки", elapsed)
+	}
+	progress.update("Шаг 5")
+	time.Sleep(2 * interval)
+
+	want := []string{"edit 7: Шаг 1", "edit 7: Шаг 3", "edit 7: Готово"}
+	if got := progressLog(fake); !slices.Equal(got, want) {
+		t.Errorf("отправлено %q, ожидалось %q", got, want)
+	}
+}
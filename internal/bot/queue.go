@@ -0,0 +1,341 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// defaultGenerationWorkers сколько генераций выполняется одновременно
+	defaultGenerationWorkers = 4
+	// defaultGenerationQueueSize сколько генераций может ждать своей очереди
+	defaultGenerationQueueSize = 50
+)
+
+var (
+	errQueueFull     = errors.New("очередь генераций заполнена")
+	errAlreadyQueued = errors.New("генерация пользователя уже ждет в очереди")
+	errQueueClosed   = errors.New("очередь генераций закрыта")
+)
+
+// generationJob генерация, ожидающая свободного обработчика
+type generationJob struct {
+	userID   int64
+	enqueued time.Time
+	// priority задача премиум-пользователя: встает перед обычными ожидающими задачами
+	priority bool
+	// run выполняет генерацию; ctx прерывается при удалении данных пользователя,
+	// status — сообщение с позицией в очереди, в которое генерация пишет свой ход,
+	// или пустое сообщение, если задача не ждала
+	run    func(ctx context.Context, status tgbotapi.Message)
+	ctx    context.Context
+	cancel context.CancelFunc
+	// done закрывается, когда задача выполнена
+	done chan struct{}
+
+	// status и started защищены generationQueue.mu
+	status  tgbotapi.Message
+	started bool
+}
+
+// queuedJob сообщение ожидающей задачи и ее новая позиция в очереди
+type queuedJob struct {
+	userID    int64
+	messageID int
+	position  int
+}
+
+// QueueStats состояние очереди генераций для диагностики
+type QueueStats struct {
+	Workers   int   `json:"workers"`
+	Running   int   `json:"running"`
+	Depth     int   `json:"depth"`
+	Capacity  int   `json:"capacity"`
+	Processed int64 `json:"processed"`
+	Rejected  int64 `json:"rejected"`
+	// AverageWaitMs и MaxWaitMs время ожидания в очереди до начала генерации
+	AverageWaitMs int64 `json:"average_wait_ms"`
+	MaxWaitMs     int64 `json:"max_wait_ms"`
+}
+
+// generationQueue очередь генераций: одновременно выполняется не больше workers,
+// ожидающие обслуживаются по порядку (премиум-пользователи — раньше остальных),
+// у пользователя не больше одной ожидающей задачи
+type generationQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	pending  []*generationJob
+	waiting  map[int64]bool
+	active   map[*generationJob]bool // выполняющиеся задачи
+	workers  int
+	capacity int
+	running  int
+	closed   bool
+
+	processed int64
+	rejected  int64
+	totalWait time.Duration
+	maxWait   time.Duration
+}
+
+func newGenerationQueue(workers, capacity int) *generationQueue {
+	q := &generationQueue{
+		waiting:  make(map[int64]bool),
+		active:   make(map[*generationJob]bool),
+		workers:  workers,
+		capacity: capacity,
+	}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push ставит задачу в очередь и возвращает ее позицию: 0 — задача сразу попадет
+// к свободному обработчику
+func (q *generationQueue) push(job *generationJob) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	switch {
+	case q.closed:
+		return 0, errQueueClosed
+	case q.waiting[job.userID]:
+		q.rejected++
+		return 0, errAlreadyQueued
+	case len(q.pending) >= q.capacity:
+		q.rejected++
+		return 0, errQueueFull
+	}
+
+	index := len(q.pending)
+	if job.priority {
+		index = 0
+		for index < len(q.pending) && q.pending[index].priority {
+			index++
+		}
+	}
+	position := q.position(index)
+	q.pending = slices.Insert(q.pending, index, job)
+	q.waiting[job.userID] = true
+	q.cond.Signal()
+	return position, nil
+}
+
+// setStatus запоминает сообщение с позицией. Возвращает false, если задача уже началась:
+// тогда сообщение больше не нужно.
+func (q *generationQueue) setStatus(job *generationJob, status tgbotapi.Message) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job.started {
+		return false
+	}
+	job.status = status
+	return true
+}
+
+// next ждет задачу для обработчика. Возвращает ожидающие задачи, чья позиция сдвинулась,
+// и false, когда очередь закрыта и пуста.
+func (q *generationQueue) next() (*generationJob, []queuedJob, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.pending) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.pending) == 0 {
+		return nil, nil, false
+	}
+
+	job := q.pending[0]
+	q.pending = q.pending[1:]
+	delete(q.waiting, job.userID)
+	job.started = true
+	q.active[job] = true
+	q.running++
+
+	wait := time.Since(job.enqueued)
+	q.processed++
+	q.totalWait += wait
+	q.maxWait = max(q.maxWait, wait)
+
+	moved := make([]queuedJob, 0, len(q.pending))
+	for i, waiting := range q.pending {
+		if position := q.position(i); waiting.status.MessageID != 0 && position > 0 {
+			moved = append(moved, queuedJob{userID: waiting.userID, messageID: waiting.status.MessageID, position: position})
+		}
+	}
+	return job, moved, true
+}
+
+// position позиция ожидающей задачи с индексом index: сколько задач, включая ее,
+// должно начаться, прежде чем начнется она; 0 — ее возьмет свободный обработчик
+func (q *generationQueue) position(index int) int {
+	return max(0, index+1+q.running-q.workers)
+}
+
+// done отмечает, что обработчик закончил задачу
+func (q *generationQueue) done(job *generationJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.running--
+	delete(q.active, job)
+	job.cancel()
+	close(job.done)
+}
+
+// cancelUser убирает из очереди ожидающую задачу пользователя и прерывает выполняющиеся.
+// Возвращает убранную задачу или nil и задачи, завершения которых нужно дождаться.
+func (q *generationQueue) cancelUser(userID int64) (*generationJob, []*generationJob) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var removed *generationJob
+	if index := slices.IndexFunc(q.pending, func(job *generationJob) bool { return job.userID == userID }); index >= 0 {
+		removed = q.pending[index]
+		q.pending = slices.Delete(q.pending, index, index+1)
+		delete(q.waiting, userID)
+		// Задача больше не начнется: сообщение с позицией, отправленное позже, удалит
+		// enqueueGeneration, а отправленное раньше уже есть в status
+		removed.started = true
+		removed.cancel()
+	}
+
+	var running []*generationJob
+	for job := range q.active {
+		if job.userID == userID {
+			job.cancel()
+			running = append(running, job)
+		}
+	}
+	return removed, running
+}
+
+// close перестает принимать задачи; обработчики доделывают уже принятые и завершаются
+func (q *generationQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.cond.Broadcast()
+}
+
+// stats возвращает состояние очереди
+func (q *generationQueue) stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := QueueStats{
+		Workers:   q.workers,
+		Running:   q.running,
+		Depth:     len(q.pending),
+		Capacity:  q.capacity,
+		Processed: q.processed,
+		Rejected:  q.rejected,
+		MaxWaitMs: q.maxWait.Milliseconds(),
+	}
+	if q.processed > 0 {
+		stats.AverageWaitMs = (q.totalWait / time.Duration(q.processed)).Milliseconds()
+	}
+	return stats
+}
+
+// QueueStats возвращает состояние очереди генераций
+func (b *Bot) QueueStats() QueueStats {
+	return b.queue.stats()
+}
+
+// enqueueGeneration ставит генерацию в очередь. Если свободного обработчика нет,
+// пользователь видит свою позицию; при переполненной очереди генерация отклоняется.
+// Возвращает false, если генерация не принята.
+func (b *Bot) enqueueGeneration(userID int64, run func(ctx context.Context, status tgbotapi.Message)) bool {
+	job := &generationJob{userID: userID, enqueued: time.Now(), priority: b.db.IsPremium(userID), run: run,
+		done: make(chan struct{})}
+	job.ctx, job.cancel = context.WithCancel(context.Background())
+	position, err := b.queue.push(job)
+	if err != nil {
+		job.cancel()
+	}
+	switch {
+	case errors.Is(err, errQueueFull):
+		log.Printf("[QUEUE] ⚠️ Очередь заполнена, генерация %d отклонена", userID)
+		b.sendMessage(userID, b.t(userID, "generate.queue_full"))
+		return false
+	case errors.Is(err, errAlreadyQueued):
+		b.sendMessage(userID, b.t(userID, "generate.already_queued"))
+		return false
+	case err != nil:
+		b.sendMessage(userID, b.t(userID, "error.internal"))
+		return false
+	}
+	if position == 0 {
+		return true
+	}
+
+	log.Printf("[QUEUE] Генерация %d в очереди на позиции %d", userID, position)
+	status := b.sendMessage(userID, b.t(userID, "generate.queued", position))
+	if status.MessageID != 0 && !b.queue.setStatus(job, status) {
+		// Задача успела начаться: генерация уже отправила свое сообщение
+		b.api.Request(tgbotapi.NewDeleteMessage(userID, status.MessageID))
+	}
+	return true
+}
+
+// generationWorker выполняет генерации из очереди, пока она не закрыта и не пуста
+func (b *Bot) generationWorker() {
+	for {
+		job, moved, ok := b.queue.next()
+		if !ok {
+			return
+		}
+		for _, waiting := range moved {
+			b.editMessage(waiting.userID, waiting.messageID, b.t(waiting.userID, "generate.queued", waiting.position))
+		}
+		b.runGeneration(job)
+	}
+}
+
+// runGeneration выполняет одну генерацию; паника не останавливает обработчик
+func (b *Bot) runGeneration(job *generationJob) {
+	defer b.queue.done(job)
+	defer b.recoverPanic("generate", job.userID)
+	// Генерация может закончиться, не дойдя до первого шага (нет генераций, тема отклонена):
+	// сообщение о позиции не должно остаться висеть
+	if job.status.MessageID != 0 {
+		b.editMessage(job.userID, job.status.MessageID, b.t(job.userID, "generate.queue_started"))
+	}
+	job.run(job.ctx, job.status)
+}
+
+// cancelGenerations прерывает генерации пользователя: ожидающая убирается из очереди,
+// выполняющиеся получают отмену контекста. Ждет их завершения не дольше timeout;
+// false, если не дождался.
+func (b *Bot) cancelGenerations(userID int64, timeout time.Duration) bool {
+	removed, running := b.queue.cancelUser(userID)
+	if removed != nil {
+		log.Printf("[QUEUE] Генерация %d убрана из очереди", userID)
+		if removed.status.MessageID != 0 {
+			b.api.Request(tgbotapi.NewDeleteMessage(userID, removed.status.MessageID))
+		}
+	}
+
+	deadline := time.After(timeout)
+	for _, job := range running {
+		select {
+		case <-job.done:
+		case <-deadline:
+			return false
+		}
+	}
+	return true
+}
+
+type queueStatusKey struct{}
+
+// withQueueStatus передает генерации сообщение с позицией в очереди
+func withQueueStatus(ctx context.Context, status tgbotapi.Message) context.Context {
+	return context.WithValue(ctx, queueStatusKey{}, status)
+}
@@ -0,0 +1,275 @@
+package bot
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/testutil"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// queueBot запускает бота с workers обработчиками генераций и очередью на capacity задач
+func queueBot(t *testing.T, workers, capacity int) (*Bot, *testutil.FakeTelegram, func()) {
+	t.Helper()
+	b, fake := newTestBot(t, func(config *Config) {
+		config.GenerationWorkers = workers
+		config.GenerationQueueSize = capacity
+	})
+	return b, fake, runBot(t, b)
+}
+
+// blockingJob генерация-заглушка: отмечает начало в started и ждет release
+func blockingJob(started chan<- int64, userID int64, release <-chan struct{}) func(context.Context, tgbotapi.Message) {
+	return func(ctx context.Context, status tgbotapi.Message) {
+		started <- userID
+		<-release
+	}
+}
+
+// waitGenerations ждет, пока очередь генераций опустеет и выполняющиеся генерации закончатся
+func waitGenerations(t *testing.T, b *Bot) {
+	t.Helper()
+	waitFor(t, "конец генераций", func() bool {
+		b.queue.mu.Lock()
+		defer b.queue.mu.Unlock()
+		return len(b.queue.pending) == 0 && b.queue.running == 0
+	})
+}
+
+func TestQueueConcurrencyCap(t *testing.T) {
+	b, _, _ := queueBot(t, 3, 20)
+
+	var running, maxRunning, finished atomic.Int32
+	release := make(chan struct{})
+	job := func(ctx context.Context, status tgbotapi.Message) {
+		current := running.Add(1)
+		for {
+			seen := maxRunning.Load()
+			if current <= seen || maxRunning.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		<-release
+		running.Add(-1)
+		finished.Add(1)
+	}
+
+	// Пятнадцать пользователей одновременно
+	var wg sync.WaitGroup
+	for userID := int64(1); userID <= 15; userID++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !b.enqueueGeneration(userID, job) {
+				t.Errorf("генерация %d не принята", userID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	waitFor(t, "заняты все обработчики", func() bool { return running.Load() == 3 })
+	if stats := b.QueueStats(); stats.Running != 3 || stats.Depth != 12 {
+		t.Errorf("очередь: %+v", stats)
+	}
+	close(release)
+
+	waitFor(t, "все генерации", func() bool { return finished.Load() == 15 })
+	if got := maxRunning.Load(); got != 3 {
+		t.Errorf("одновременно выполнялось %d генераций, ожидалось 3", got)
+	}
+	if stats := b.QueueStats(); stats.Processed != 15 || stats.Depth != 0 || stats.Rejected != 0 {
+		t.Errorf("очередь после пачки: %+v", stats)
+	}
+}
+
+func TestQueueFairness(t *testing.T) {
+	b, fake, _ := queueBot(t, 1, 10)
+	started := make(chan int64, 10)
+	release := make(chan struct{})
+
+	b.enqueueGeneration(1, blockingJob(started, 1, release))
+	if first := <-started; first != 1 {
+		t.Fatalf("первой началась генерация %d", first)
+	}
+
+	// Свободного обработчика нет: пользователи видят свою позицию
+	b.enqueueGeneration(2, blockingJob(started, 2, release))
+	b.enqueueGeneration(3, blockingJob(started, 3, release))
+	if text := fake.LastText(2); text != i18n.T("ru", "generate.queued", 1) {
+		t.Errorf("позиция пользователя 2: %q", text)
+	}
+	if text := fake.LastText(3); text != i18n.T("ru", "generate.queued", 2) {
+		t.Errorf("позиция пользователя 3: %q", text)
+	}
+	// Первый, кому не пришлось ждать, сообщения о позиции не получает
+	if sent := fake.SentTo(1); len(sent) != 0 {
+		t.Errorf("пользователю 1 отправлено %+v", sent)
+	}
+
+	// У пользователя не больше одной ожидающей генерации
+	if b.enqueueGeneration(2, blockingJob(started, 2, release)) {
+		t.Fatal("вторая генерация пользователя 2 принята")
+	}
+	if text := fake.LastText(2); text != i18n.T("ru", "generate.already_queued") {
+		t.Errorf("ответ на повтор: %q", text)
+	}
+
+	// Очередь обслуживается по порядку, позиции ожидающих обновляются
+	release <- struct{}{}
+	if next := <-started; next != 2 {
+		t.Fatalf("второй началась генерация %d", next)
+	}
+	waitFor(t, "новая позиция пользователя 3", func() bool {
+		return fake.LastText(3) == i18n.T("ru", "generate.queued", 1)
+	})
+	if text := fake.LastText(2); text != i18n.T("ru", "generate.queue_started") {
+		t.Errorf("сообщение о позиции пользователя 2: %q", text)
+	}
+
+	release <- struct{}{}
+	if next := <-started; next != 3 {
+		t.Fatalf("третьей началась генерация %d", next)
+	}
+	close(release)
+
+	// После начала генерации пользователь снова может встать в очередь
+	if !b.enqueueGeneration(2, func(ctx context.Context, status tgbotapi.Message) {}) {
+		t.Error("новая генерация пользователя 2 не принята")
+	}
+}
+
+func TestQueueFullRejects(t *testing.T) {
+	b, fake, _ := queueBot(t, 1, 2)
+	started := make(chan int64, 10)
+	release := make(chan struct{})
+	defer close(release)
+
+	b.enqueueGeneration(1, blockingJob(started, 1, release))
+	<-started
+	for userID := int64(2); userID <= 3; userID++ {
+		if !b.enqueueGeneration(userID, blockingJob(started, userID, release)) {
+			t.Fatalf("генерация %d не принята", userID)
+		}
+	}
+
+	if b.enqueueGeneration(4, blockingJob(started, 4, release)) {
+		t.Fatal("генерация сверх емкости очереди принята")
+	}
+	if text := fake.LastText(4); text != i18n.T("ru", "generate.queue_full") {
+		t.Errorf("ответ при заполненной очереди: %q", text)
+	}
+	if stats := b.QueueStats(); stats.Running != 1 || stats.Depth != 2 || stats.Capacity != 2 || stats.Rejected != 1 {
+		t.Errorf("очередь: %+v", stats)
+	}
+}
+
+func TestQueueDrainsOnShutdown(t *testing.T) {
+	b, _, stop := queueBot(t, 1, 10)
+
+	var finished atomic.Int32
+	for userID := int64(1); userID <= 3; userID++ {
+		b.enqueueGeneration(userID, func(ctx context.Context, status tgbotapi.Message) {
+			time.Sleep(50 * time.Millisecond)
+			finished.Add(1)
+		})
+	}
+	stop()
+
+	// Принятые генерации доделываются до завершения бота
+	if got := finished.Load(); got != 3 {
+		t.Errorf("до завершения выполнено %d генераций из 3", got)
+	}
+	if b.enqueueGeneration(4, func(ctx context.Context, status tgbotapi.Message) {}) {
+		t.Error("закрытая очередь приняла генерацию")
+	}
+}
+
+func TestQueueWaitMetrics(t *testing.T) {
+	b, _, _ := queueBot(t, 1, 10)
+	started := make(chan int64, 10)
+	release := make(chan struct{})
+
+	b.enqueueGeneration(1, blockingJob(started, 1, release))
+	<-started
+	b.enqueueGeneration(2, blockingJob(started, 2, release))
+	time.Sleep(100 * time.Millisecond)
+	release <- struct{}{}
+	<-started
+	close(release)
+
+	stats := b.QueueStats()
+	if stats.Processed != 2 || stats.MaxWaitMs < 100 || stats.AverageWaitMs < 40 || stats.AverageWaitMs > stats.MaxWaitMs {
+		t.Errorf("время ожидания: %+v", stats)
+	}
+}
+
+func TestQueuePriority(t *testing.T) {
+	q := newGenerationQueue(1, 10)
+	push := func(userID int64, priority bool) int {
+		job := &generationJob{userID: userID, priority: priority, enqueued: time.Now(), done: make(chan struct{})}
+		job.ctx, job.cancel = context.WithCancel(context.Background())
+		position, err := q.push(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return position
+	}
+
+	// Премиум-пользователи встают перед обычными, но за другими премиум
+	var positions []int
+	for _, job := range []struct {
+		userID   int64
+		priority bool
+	}{{1, false}, {2, false}, {10, true}, {3, false}, {11, true}} {
+		positions = append(positions, push(job.userID, job.priority))
+	}
+	if want := []int{0, 1, 0, 3, 1}; !slices.Equal(positions, want) {
+		t.Errorf("позиции %v, ожидались %v", positions, want)
+	}
+
+	var order []int64
+	for range 5 {
+		job, _, _ := q.next()
+		order = append(order, job.userID)
+		q.done(job)
+	}
+	if want := []int64{10, 11, 1, 2, 3}; !slices.Equal(order, want) {
+		t.Errorf("порядок %v, ожидался %v", order, want)
+	}
+}
+
+func TestQueueCancelUser(t *testing.T) {
+	q := newGenerationQueue(1, 10)
+	newJob := func(userID int64) *generationJob {
+		job := &generationJob{userID: userID, enqueued: time.Now(), done: make(chan struct{})}
+		job.ctx, job.cancel = context.WithCancel(context.Background())
+		if _, err := q.push(job); err != nil {
+			t.Fatal(err)
+		}
+		return job
+	}
+
+	running := newJob(1)
+	q.next()
+	waiting := newJob(1)
+	other := newJob(2)
+
+	removed, active := q.cancelUser(1)
+	if removed != waiting || len(active) != 1 || active[0] != running {
+		t.Fatalf("отменены %v, %v", removed, active)
+	}
+	if waiting.ctx.Err() == nil || running.ctx.Err() == nil || other.ctx.Err() != nil {
+		t.Error("контексты задач отменены неверно")
+	}
+	// Освободившееся место пользователя снова доступно, очередь других не тронута
+	newJob(1)
+	if stats := q.stats(); stats.Depth != 2 {
+		t.Errorf("в очереди %d задач, ожидалось 2", stats.Depth)
+	}
+}
@@ -0,0 +1,231 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/calendar"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/news"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// defaultReportHour час ежедневного отчета администратору
+	defaultReportHour = 9
+	// reportCheckInterval как часто планировщик проверяет, не пора ли отправить отчет
+	reportCheckInterval = time.Minute
+	// reportTopTopics и weeklyTopTopics сколько тем показывать в ежедневном и недельном отчете
+	reportTopTopics = 3
+	weeklyTopTopics = 10
+)
+
+// Виды отчетов администратору; по понедельникам вместо ежедневного уходит недельный
+const (
+	reportDaily  = "daily"
+	reportWeekly = "weekly"
+)
+
+// reportPeriod данные отчета за один период
+type reportPeriod struct {
+	stats database.PeriodReport
+	ai    ai.AuditSummary
+}
+
+// operationsReport данные отчета администратору
+type operationsReport struct {
+	day reportPeriod
+	// week и previousWeek заполнены только в недельном отчете
+	week, previousWeek *reportPeriod
+	// adjustments ручные изменения баланса за прошлую неделю, только в недельном отчете
+	adjustments []database.BalanceAdjustment
+	// aiErr почему нет данных о запросах к AI; nil, если журнал прочитан
+	aiErr       error
+	quarantined []news.SourceStatus
+}
+
+// runReports раз в сутки в час ReportHour отправляет администратору отчет, а по
+// понедельникам ставит в очередь сводки активным пользователям.
+// Отметка об отправке хранится в базе, поэтому перезапуск не приводит к повтору,
+// а отчет, пропущенный из-за остановки, уходит после запуска.
+func (b *Bot) runReports(ctx context.Context) {
+	ticker := time.NewTicker(reportCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		b.sendDueReport(time.Now())
+		b.sendDueSummaries(time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.stopping:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reportLocation часовой пояс календарных периодов статистики и отчетов
+func (b *Bot) reportLocation() *time.Location {
+	if b.config.ReportLocation == nil {
+		return time.Local
+	}
+	return b.config.ReportLocation
+}
+
+// sendDueReport отправляет отчет, если его час наступил, а сегодня он еще не уходил.
+// Час и сутки отчета считаются в поясе отчетов.
+func (b *Bot) sendDueReport(now time.Time) {
+	now = now.In(b.reportLocation())
+	if b.adminChatID == 0 || now.Hour() < b.config.ReportHour {
+		return
+	}
+	kind := reportDaily
+	if now.Weekday() == time.Monday {
+		kind = reportWeekly
+	}
+	day := now.Format("2006-01-02")
+	if b.db.LastReport(kind) == day {
+		return
+	}
+
+	msg := tgbotapi.NewMessage(b.adminChatID, formatReport(b.collectReport(kind, now)))
+	msg.DisableWebPagePreview = true
+	if _, err := b.api.Send(msg); err != nil {
+		log.Printf("[REPORT] ❌ Ошибка отправки отчета: %v", err)
+		return
+	}
+	if err := b.db.MarkReportSent(kind, day); err != nil {
+		log.Printf("[REPORT] ❌ Ошибка сохранения отметки об отчете: %v", err)
+	}
+	log.Printf("[REPORT] ✅ Отправлен отчет %s за %s", kind, day)
+}
+
+// collectReport собирает данные отчета kind, отправляемого в now: за вчерашние сутки,
+// а в недельном отчете — еще и за прошлую календарную неделю. Периоды те же, что
+// «вчера» и недели в /statistics, поэтому цифры совпадают.
+func (b *Bot) collectReport(kind string, now time.Time) operationsReport {
+	var report operationsReport
+	location := b.reportLocation()
+	period := func(p calendar.Period, topics int) reportPeriod {
+		result := reportPeriod{stats: b.db.GetPeriodReport(p.From, p.To, topics)}
+		if report.aiErr == nil {
+			result.ai, report.aiErr = ai.SummarizeAudit(p.From, p.To)
+		}
+		return result
+	}
+
+	report.day = period(calendar.Yesterday(now, location), reportTopTopics)
+	if kind == reportWeekly {
+		lastWeek := calendar.LastWeek(now, location)
+		week := period(lastWeek, weeklyTopTopics)
+		report.week = &week
+
+		before := lastWeek.Shift(-7)
+		previous := reportPeriod{stats: b.db.GetPeriodReport(before.From, before.To, 0)}
+		if report.aiErr == nil {
+			previous.ai, _ = ai.SummarizeAudit(before.From, before.To)
+		}
+		report.previousWeek = &previous
+		report.adjustments = b.db.BalanceAdjustments(lastWeek.From, lastWeek.To)
+	}
+
+	if b.newsAggregator != nil {
+		for _, status := range b.newsAggregator.SourceStatuses() {
+			if status.Quarantined() {
+				report.quarantined = append(report.quarantined, status)
+			}
+		}
+	}
+	return report
+}
+
+// formatReport оформляет отчет для администратора
+func formatReport(report operationsReport) string {
+	var text strings.Builder
+
+	fmt.Fprintf(&text, "📋 Отчет за %s\n\n", report.day.stats.From.Format("02.01.2006"))
+	writeReportPeriod(&text, report.day, nil, report.aiErr)
+
+	if report.week != nil {
+		// Конец периода не входит в него: последний день недели — накануне
+		fmt.Fprintf(&text, "\n📅 За неделю %s–%s (в скобках — изменение к прошлой неделе)\n\n",
+			report.week.stats.From.Format("02.01"), report.week.stats.To.AddDate(0, 0, -1).Format("02.01"))
+		writeReportPeriod(&text, *report.week, report.previousWeek, report.aiErr)
+		writeAdjustments(&text, report.adjustments)
+	}
+
+	text.WriteString("\n")
+	if len(report.quarantined) == 0 {
+		text.WriteString("📡 Все источники новостей работают")
+	} else {
+		fmt.Fprintf(&text, "⛔ В карантине %d источников:\n", len(report.quarantined))
+		for _, status := range report.quarantined {
+			fmt.Fprintf(&text, "• %s до %s\n", status.Name, status.QuarantinedUntil.Format("02.01 15:04"))
+		}
+	}
+
+	return strings.TrimRight(text.String(), "\n")
+}
+
+// writeReportPeriod дописывает показатели периода; previous добавляет изменение к прошлому периоду
+func writeReportPeriod(text *strings.Builder, period reportPeriod, previous *reportPeriod, aiErr error) {
+	stats := period.stats
+	delta := func(current, before int) string {
+		if previous == nil {
+			return ""
+		}
+		return fmt.Sprintf(" (%+d)", current-before)
+	}
+	var prev database.PeriodReport
+	var prevAI ai.AuditSummary
+	if previous != nil {
+		prev, prevAI = previous.stats, previous.ai
+	}
+
+	fmt.Fprintf(text, "👥 Новых пользователей: %d%s, всего %d\n",
+		stats.NewUsers, delta(stats.NewUsers, prev.NewUsers), stats.TotalUsers)
+	fmt.Fprintf(text, "🔄 Генераций: ✅ %d%s · ⛔ отклонено тем %d", stats.Generations,
+		delta(stats.Generations, prev.Generations), stats.Rejected)
+	if aiErr == nil {
+		fmt.Fprintf(text, " · ❌ ошибок AI %d", period.ai.Failed)
+	}
+	text.WriteString("\n")
+
+	fmt.Fprintf(text, "💵 Выручка: %d руб.%s", stats.TotalRevenue, delta(stats.TotalRevenue, prev.TotalRevenue))
+	var packages []string
+	for _, code := range []string{"10", "25", "100"} {
+		if count := stats.Purchases[code]; count > 0 {
+			packages = append(packages, fmt.Sprintf("%s×%d — %d руб.", code, count, stats.Revenue[code]))
+		}
+	}
+	if len(packages) > 0 {
+		fmt.Fprintf(text, " (%s)", strings.Join(packages, ", "))
+	}
+	text.WriteString("\n")
+
+	if stats.Ratings > 0 {
+		fmt.Fprintf(text, "⭐ Средняя оценка: %.1f из 5 (%d оценок)\n", stats.AverageRating, stats.Ratings)
+	} else {
+		text.WriteString("⭐ Оценок не было\n")
+	}
+
+	if aiErr != nil {
+		fmt.Fprintf(text, "🤖 Расход AI неизвестен: %v\n", aiErr)
+	} else {
+		fmt.Fprintf(text, "🤖 AI: %d запросов, %d токенов%s, %.2f руб.\n", period.ai.Requests,
+			period.ai.Usage.TotalTokens, delta(period.ai.Usage.TotalTokens, prevAI.Usage.TotalTokens), period.ai.Cost)
+	}
+
+	if len(stats.TopTopics) > 0 {
+		text.WriteString("🎯 Темы:\n")
+		for i, topic := range stats.TopTopics {
+			fmt.Fprintf(text, "%d. %s — %d\n", i+1, topic.Topic, topic.Count)
+		}
+	}
+}
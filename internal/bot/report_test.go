@@ -0,0 +1,200 @@
+package bot
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/news"
+)
+
+// syntheticPeriod данные отчета за сутки 12.10.2026
+func syntheticPeriod() reportPeriod {
+	from := time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+	return reportPeriod{
+		stats: database.PeriodReport{
+			From:          from,
+			To:            from.AddDate(0, 0, 1),
+			TotalUsers:    120,
+			NewUsers:      4,
+			Generations:   30,
+			Rejected:      2,
+			Purchases:     map[string]int{"10": 2, "100": 1},
+			Revenue:       map[string]int{"10": 200, "100": 1500},
+			TotalRevenue:  1700,
+			Ratings:       5,
+			AverageRating: 4.4,
+			TopTopics: []database.TopicCount{
+				{Topic: "ставка цб", Count: 10}, {Topic: "биткоин", Count: 5}, {Topic: "выборы", Count: 2},
+			},
+		},
+		ai: ai.AuditSummary{Requests: 40, Failed: 1, Usage: ai.Usage{TotalTokens: 52000}, Cost: 12.5},
+	}
+}
+
+func TestFormatDailyReport(t *testing.T) {
+	want := `📋 Отчет за 12.10.2026
+
+👥 Новых пользователей: 4, всего 120
+🔄 Генераций: ✅ 30 · ⛔ отклонено тем 2 · ❌ ошибок AI 1
+💵 Выручка: 1700 руб. (10×2 — 200 руб., 100×1 — 1500 руб.)
+⭐ Средняя оценка: 4.4 из 5 (5 оценок)
+🤖 AI: 40 запросов, 52000 токенов, 12.50 руб.
+🎯 Темы:
+1. ставка цб — 10
+2. биткоин — 5
+3. выборы — 2
+
+📡 Все источники новостей работают`
+
+	if got := formatReport(operationsReport{day: syntheticPeriod()}); got != want {
+		t.Errorf("отчет:\n%s\n\nожидался:\n%s", got, want)
+	}
+}
+
+func TestFormatReport(t *testing.T) {
+	quiet := syntheticPeriod()
+	quiet.stats.Purchases, quiet.stats.Revenue, quiet.stats.TotalRevenue = nil, nil, 0
+	quiet.stats.Ratings, quiet.stats.AverageRating, quiet.stats.TopTopics = 0, 0, nil
+
+	week := syntheticPeriod()
+	week.stats.From = time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	week.stats.To = week.stats.From.AddDate(0, 0, 7)
+	week.stats.Generations = 200
+	week.ai.Usage.TotalTokens = 300000
+	previousWeek := syntheticPeriod()
+	previousWeek.stats.Generations = 180
+	previousWeek.ai.Usage.TotalTokens = 350000
+
+	tests := []struct {
+		name    string
+		report  operationsReport
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "тихий день",
+			report:  operationsReport{day: quiet},
+			want:    []string{"💵 Выручка: 0 руб.\n", "⭐ Оценок не было"},
+			notWant: []string{"🎯 Темы", "(+", "📅"},
+		},
+		{
+			name:    "журнал AI выключен",
+			report:  operationsReport{day: syntheticPeriod(), aiErr: errors.New("журнал запросов выключен")},
+			want:    []string{"🤖 Расход AI неизвестен: журнал запросов выключен"},
+			notWant: []string{"ошибок AI", "токенов"},
+		},
+		{
+			name: "источники в карантине",
+			report: operationsReport{day: syntheticPeriod(), quarantined: []news.SourceStatus{
+				{Name: "РБК", QuarantinedUntil: time.Date(2026, 10, 16, 18, 30, 0, 0, time.UTC)},
+				{Name: "ТАСС", QuarantinedUntil: time.Date(2026, 10, 17, 9, 0, 0, 0, time.UTC)},
+			}},
+			want:    []string{"⛔ В карантине 2 источников:\n• РБК до 16.10 18:30\n• ТАСС до 17.10 09:00"},
+			notWant: []string{"Все источники"},
+		},
+		{
+			name: "недельный",
+			report: operationsReport{day: syntheticPeriod(), week: &week, previousWeek: &previousWeek,
+				adjustments: []database.BalanceAdjustment{
+					{UserID: 1, Delta: 10, Balance: 13, Reason: "компенсация", Timestamp: week.stats.From.Add(time.Hour)},
+					{UserID: 2, Delta: -3, Balance: 0, Reason: "возврат", Timestamp: week.stats.From.Add(2 * time.Hour)},
+				}},
+			want: []string{
+				"📅 За неделю 05.10–11.10 (в скобках — изменение к прошлой неделе)",
+				"👥 Новых пользователей: 4 (+0), всего 120",
+				"🔄 Генераций: ✅ 200 (+20)",
+				"300000 токенов (-50000)",
+				"💵 Выручка: 1700 руб. (+0)",
+				"✍️ Ручных изменений баланса: 2 (начислено 10, списано 3)",
+			},
+		},
+		{
+			name:   "недельный без ручных изменений",
+			report: operationsReport{day: syntheticPeriod(), week: &week, previousWeek: &previousWeek},
+			want:   []string{"✍️ Ручных изменений баланса не было"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatReport(tt.report)
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("в отчете нет %q:\n%s", want, got)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(got, notWant) {
+					t.Errorf("в отчете лишнее %q:\n%s", notWant, got)
+				}
+			}
+			// Суточная часть в начале отчета не меняется
+			if !strings.HasPrefix(got, "📋 Отчет за 12.10.2026\n\n👥 Новых пользователей: 4, всего 120\n") {
+				t.Errorf("начало отчета:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestSendDueReportOncePerDay(t *testing.T) {
+	b, fake := newTestBot(t, func(config *Config) {
+		config.ReportHour = 9
+		config.ReportLocation = time.UTC
+	})
+	tuesday := time.Date(2026, 10, 13, 8, 59, 0, 0, time.UTC)
+
+	// До часа отчета ничего не уходит
+	b.sendDueReport(tuesday)
+	if sent := fake.SentTo(testAdminChatID); len(sent) != 0 {
+		t.Fatalf("отчет до часа отправки: %+v", sent)
+	}
+
+	b.sendDueReport(tuesday.Add(time.Minute))
+	b.sendDueReport(tuesday.Add(2 * time.Hour))
+	sent := fake.SentTo(testAdminChatID)
+	if len(sent) != 1 || !strings.HasPrefix(sent[0].Text, "📋 Отчет за 12.10.2026") || strings.Contains(sent[0].Text, "📅") {
+		t.Fatalf("отчеты: %+v", sent)
+	}
+
+	// После перезапуска отметка читается из базы, и отчет не повторяется
+	b.db = database.NewDatabase(database.Config{File: "users.json"})
+	if err := b.db.Load(); err != nil {
+		t.Fatal(err)
+	}
+	b.sendDueReport(tuesday.Add(time.Hour))
+	if sent := fake.SentTo(testAdminChatID); len(sent) != 1 {
+		t.Errorf("отчет повторен после перезапуска: %d", len(sent))
+	}
+
+	// На следующий день отчет снова уходит, в понедельник — недельный
+	b.sendDueReport(tuesday.AddDate(0, 0, 1).Add(time.Hour))
+	b.sendDueReport(tuesday.AddDate(0, 0, 6).Add(time.Hour))
+	sent = fake.SentTo(testAdminChatID)
+	if len(sent) != 3 || !strings.HasPrefix(sent[1].Text, "📋 Отчет за 13.10.2026") ||
+		!strings.Contains(sent[2].Text, "📅 За неделю 12.10–18.10") {
+		t.Errorf("отчеты: %+v", sent)
+	}
+}
+
+func TestSendDueReportRetriesAfterFailure(t *testing.T) {
+	b, fake := newTestBot(t, func(config *Config) {
+		config.ReportHour = 9
+		config.ReportLocation = time.UTC
+	})
+	now := time.Date(2026, 10, 13, 10, 0, 0, 0, time.UTC)
+
+	// Неотправленный отчет не отмечается и уходит при следующей проверке
+	fake.FailNext(testAdminChatID, errors.New("Bad Gateway"))
+	b.sendDueReport(now)
+	b.sendDueReport(now.Add(reportCheckInterval))
+	if sent := fake.SentTo(testAdminChatID); len(sent) != 1 {
+		t.Errorf("отчетов %d, ожидался 1", len(sent))
+	}
+	if day := b.db.LastReport(reportDaily); day != "2026-10-13" {
+		t.Errorf("отметка %q", day)
+	}
+}
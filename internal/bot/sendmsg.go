@@ -0,0 +1,243 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// broadcastConfirmTTL сколько ждет подтверждения подготовленная рассылка
+	broadcastConfirmTTL = 10 * time.Minute
+	// maxSendMessageLength ограничение Telegram на длину текста сообщения
+	maxSendMessageLength = 4096
+
+	sendMessageCallbackPrefix = "sendmsg_"
+	sendMessageConfirm        = "send"
+	sendMessageCancel         = "cancel"
+)
+
+// sendMessageUsage подсказка по /sendmsg
+const sendMessageUsage = "🔐 Использование:\n" +
+	"/sendmsg пароль all текст - отправить всем (с предпросмотром и подтверждением)\n" +
+	"/sendmsg пароль user chatid текст - отправить конкретному пользователю"
+
+// sendMessageCommand разобранная команда /sendmsg
+type sendMessageCommand struct {
+	password string
+	// all рассылка всем пользователям; иначе сообщение пользователю chatID
+	all    bool
+	chatID int64
+	text   string
+}
+
+// parseSendMessageArgs разбирает аргументы /sendmsg. Получатель задается явно словом
+// all или user: раньше рассылка, начинавшаяся с числа, уходила одному пользователю
+// с таким chat ID. Переносы строк в тексте сохраняются.
+func parseSendMessageArgs(args string) (sendMessageCommand, error) {
+	var command sendMessageCommand
+	command.password, args = cutField(args)
+	target, rest := cutField(args)
+	if command.password == "" || target == "" {
+		return command, errors.New("недостаточно аргументов")
+	}
+
+	switch strings.ToLower(target) {
+	case "all":
+		command.all = true
+	case "user":
+		var chatID string
+		chatID, rest = cutField(rest)
+		if chatID == "" {
+			return command, errors.New("не указан chatid")
+		}
+		parsed, err := strconv.ParseInt(chatID, 10, 64)
+		if err != nil {
+			return command, fmt.Errorf("неверный chatid %q: должен быть числом", chatID)
+		}
+		command.chatID = parsed
+	default:
+		return command, fmt.Errorf("неизвестный получатель %q: укажите all или user chatid", target)
+	}
+
+	command.text = strings.TrimSpace(rest)
+	if command.text == "" {
+		return command, errors.New("пустой текст сообщения")
+	}
+	if utf8.RuneCountInString(command.text) > maxSendMessageLength {
+		return command, fmt.Errorf("текст длиннее %d символов", maxSendMessageLength)
+	}
+	return command, nil
+}
+
+// cutField отделяет первое слово строки от остатка
+func cutField(s string) (string, string) {
+	s = strings.TrimLeftFunc(s, unicode.IsSpace)
+	end := strings.IndexFunc(s, unicode.IsSpace)
+	if end < 0 {
+		return s, ""
+	}
+	return s[:end], s[end:]
+}
+
+// pendingBroadcast рассылка, ожидающая подтверждения администратора
+type pendingBroadcast struct {
+	chatID  int64
+	text    string
+	created time.Time
+}
+
+// handleSendMessageCommand отправляет сообщение пользователю или готовит рассылку всем:
+// рассылка уходит только после подтверждения кнопкой под предпросмотром
+func (b *Bot) handleSendMessageCommand(msg *tgbotapi.Message) {
+	args := msg.CommandArguments()
+	if strings.TrimSpace(args) == "" {
+		b.sendMessage(msg.Chat.ID, sendMessageUsage)
+		return
+	}
+
+	command, err := parseSendMessageArgs(args)
+	if command.password != "" && command.password != b.config.AdminPassword {
+		b.sendMessage(msg.Chat.ID, "❌ Неверный пароль")
+		return
+	}
+	if err != nil {
+		b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ %s\n\n%s", err, sendMessageUsage))
+		return
+	}
+
+	if !command.all {
+		if err := b.sendMessageToUser(command.chatID, command.text); err != nil {
+			b.sendMessage(msg.Chat.ID, fmt.Sprintf("❌ Ошибка отправки пользователю %d: %v", command.chatID, err))
+		} else {
+			b.sendMessage(msg.Chat.ID, fmt.Sprintf("✅ Сообщение успешно отправлено пользователю %d", command.chatID))
+		}
+		return
+	}
+
+	id := b.prepareBroadcast(msg.Chat.ID, command.text)
+	preview := fmt.Sprintf("📣 Рассылка всем пользователям: %d получателей\n\n%s\n\nОтправить?",
+		len(b.db.GetAllUsers()), command.text)
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("✅ Отправить", sendMessageCallbackPrefix+sendMessageConfirm+"_"+id),
+		tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", sendMessageCallbackPrefix+sendMessageCancel+"_"+id),
+	))
+	if b.sendMessageWithKeyboard(msg.Chat.ID, preview, keyboard).MessageID == 0 {
+		// Предпросмотр не дошел: подтвердить рассылку все равно нечем
+		b.takeBroadcast(id, msg.Chat.ID)
+	}
+}
+
+// prepareBroadcast запоминает рассылку до подтверждения и возвращает ее код
+func (b *Bot) prepareBroadcast(chatID int64, text string) string {
+	b.broadcastsMu.Lock()
+	defer b.broadcastsMu.Unlock()
+
+	now := time.Now()
+	for id, broadcast := range b.broadcasts {
+		if now.Sub(broadcast.created) > broadcastConfirmTTL {
+			delete(b.broadcasts, id)
+		}
+	}
+	id := newRequestID()
+	b.broadcasts[id] = &pendingBroadcast{chatID: chatID, text: text, created: now}
+	return id
+}
+
+// takeBroadcast забирает рассылку с кодом id, подготовленную в чате chatID.
+// Рассылка забирается один раз: повторное нажатие кнопки ее не повторит.
+func (b *Bot) takeBroadcast(id string, chatID int64) (*pendingBroadcast, bool) {
+	b.broadcastsMu.Lock()
+	defer b.broadcastsMu.Unlock()
+
+	broadcast, ok := b.broadcasts[id]
+	if !ok || broadcast.chatID != chatID {
+		return nil, false
+	}
+	delete(b.broadcasts, id)
+	if time.Since(broadcast.created) > broadcastConfirmTTL {
+		return nil, false
+	}
+	return broadcast, true
+}
+
+// handleSendMessageCallback подтверждает или отменяет подготовленную рассылку
+func (b *Bot) handleSendMessageCallback(callback *tgbotapi.CallbackQuery) {
+	chatID, messageID := callback.Message.Chat.ID, callback.Message.MessageID
+	action, id, _ := strings.Cut(strings.TrimPrefix(callback.Data, sendMessageCallbackPrefix), "_")
+
+	broadcast, ok := b.takeBroadcast(id, chatID)
+	if !ok {
+		b.editMessage(chatID, messageID, "⌛ Рассылка уже отправлена, отменена или устарела. Подготовьте ее заново: /sendmsg")
+		return
+	}
+
+	if action != sendMessageConfirm {
+		log.Printf("[SENDMSG] Рассылка %s отменена администратором %d", id, chatID)
+		b.editMessage(chatID, messageID, "❌ Рассылка отменена")
+		return
+	}
+
+	users := b.db.GetAllUsers()
+	log.Printf("[SENDMSG] Рассылка %s подтверждена администратором %d: %d получателей", id, chatID, len(users))
+	b.editMessage(chatID, messageID, fmt.Sprintf("🔄 Начинаю рассылку сообщения для %d пользователей...", len(users)))
+	// Рассылка идет с паузами и не должна задерживать очередь чата администратора
+	b.safeGo("broadcast", chatID, func() { b.runBroadcast(chatID, users, broadcast.text) })
+}
+
+// runBroadcast отправляет текст всем пользователям и присылает администратору итог.
+// Кто заблокировал бота, а кто снова принимает сообщения, отмечается в базе одним
+// сохранением после рассылки, а не на каждого получателя.
+func (b *Bot) runBroadcast(adminChatID int64, users []int64, text string) {
+	successCount := 0
+	failCount := 0
+	var blocked, delivered []int64
+	defer func() { b.markBlocked(blocked, delivered) }()
+
+	for i, userID := range users {
+		select {
+		case <-b.stopping:
+			b.sendMessage(adminChatID, fmt.Sprintf("⚠️ Рассылка прервана остановкой бота: отправлено %d из %d", successCount, len(users)))
+			return
+		default:
+		}
+
+		if err := b.sendMessageToUser(userID, text); err != nil {
+			failCount++
+			log.Printf("[SENDMSG] ❌ Ошибка отправки пользователю %d: %v", userID, err)
+			if isBotBlocked(err) {
+				blocked = append(blocked, userID)
+			}
+		} else {
+			successCount++
+			delivered = append(delivered, userID)
+		}
+
+		if i%10 == 0 && i > 0 {
+			time.Sleep(1 * time.Second)
+		}
+	}
+
+	report := fmt.Sprintf("✅ Рассылка завершена!\n\n"+
+		"📊 Статистика:\n"+
+		"👥 Всего пользователей: %d\n"+
+		"✅ Успешно отправлено: %d\n"+
+		"❌ Ошибок: %d",
+		len(users), successCount, failCount)
+
+	b.sendMessage(adminChatID, report)
+}
+
+// sendMessageToUser отправляет сообщение конкретному пользователю
+func (b *Bot) sendMessageToUser(chatID int64, message string) error {
+	msg := tgbotapi.NewMessage(chatID, message)
+	_, err := b.api.Send(msg)
+	return err
+}
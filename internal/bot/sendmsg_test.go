@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/testutil"
+)
+
+func TestParseSendMessageArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    string
+		want    sendMessageCommand
+		wantErr bool
+	}{
+		// Раньше такая рассылка уходила одному пользователю с chat ID 5
+		{"рассылка с числа", "secret all 5 причин попробовать бота",
+			sendMessageCommand{password: "secret", all: true, text: "5 причин попробовать бота"}, false},
+		{"старый синтаксис с числом", "secret 5 причин попробовать бота", sendMessageCommand{password: "secret"}, true},
+		{"старый синтаксис с текстом", "secret Всем привет", sendMessageCommand{password: "secret"}, true},
+		{"пользователю", "secret user 5 привет", sendMessageCommand{password: "secret", chatID: 5, text: "привет"}, false},
+		{"пользователю с отрицательным chatid", "secret user -100123 привет",
+			sendMessageCommand{password: "secret", chatID: -100123, text: "привет"}, false},
+		{"пользователю текст с числа", "secret user 5 10 генераций в подарок",
+			sendMessageCommand{password: "secret", chatID: 5, text: "10 генераций в подарок"}, false},
+		{"chatid не число", "secret user пять привет", sendMessageCommand{password: "secret"}, true},
+		{"без текста пользователю", "secret user 5", sendMessageCommand{password: "secret", chatID: 5}, true},
+		{"без chatid", "secret user", sendMessageCommand{password: "secret"}, true},
+		{"без текста рассылки", "secret all  ", sendMessageCommand{password: "secret", all: true}, true},
+		{"без получателя", "secret", sendMessageCommand{password: "secret"}, true},
+		{"регистр получателя", "secret ALL текст", sendMessageCommand{password: "secret", all: true, text: "текст"}, false},
+		{"переносы строк", "secret all\nстрока 1\n\nстрока 2\n",
+			sendMessageCommand{password: "secret", all: true, text: "строка 1\n\nстрока 2"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSendMessageArgs(tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v, ожидалась: %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("разобрано %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendMessageLengthLimit(t *testing.T) {
+	// Предел считается в символах, а не в байтах: кириллица занимает по два байта
+	longest := strings.Repeat("я", maxSendMessageLength)
+	if command, err := parseSendMessageArgs("secret all " + longest); err != nil || command.text != longest {
+		t.Errorf("текст длиной в предел: %v", err)
+	}
+	if _, err := parseSendMessageArgs("secret user 5 " + longest + "я"); err == nil {
+		t.Error("текст длиннее предела принят")
+	}
+	// Пробелы вокруг текста в длину не входят
+	if _, err := parseSendMessageArgs("secret all " + longest + "\n\n"); err != nil {
+		t.Errorf("текст с завершающими пробелами: %v", err)
+	}
+}
+
+func TestSendMessageCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		// wantReply ответ администратору
+		wantReply string
+		// wantDelivered кому дошел текст
+		wantDelivered []int64
+	}{
+		{"пользователю", "secret user 2 Привет", "✅ Сообщение успешно отправлено пользователю 2", []int64{2}},
+		// Рассылка, начинающаяся с числа, больше не уходит пользователю 2
+		{"старый синтаксис", "secret 2 Привет", "неизвестный получатель \"2\"", nil},
+		{"неверный пароль", "wrong user 2 Привет", "❌ Неверный пароль", nil},
+		{"рассылка ждет подтверждения", "secret all 2 причины попробовать", "📣 Рассылка всем пользователям", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			for _, userID := range []int64{1, 2} {
+				b.db.AdjustGenerations(userID, 1, 0, "", true)
+			}
+
+			b.handleSendMessageCommand(commandUpdate(testAdminChatID, "/sendmsg "+tt.args).Message)
+
+			if !sentText(fake, testAdminChatID, tt.wantReply) {
+				t.Errorf("ответ администратору %q, ожидался %q", fake.LastText(testAdminChatID), tt.wantReply)
+			}
+			for _, userID := range []int64{1, 2} {
+				want := false
+				for _, id := range tt.wantDelivered {
+					want = want || id == userID
+				}
+				if got := len(fake.SentTo(userID)) > 0; got != want {
+					t.Errorf("пользователю %d отправлено: %t, ожидалось %t", userID, got, want)
+				}
+			}
+		})
+	}
+}
+
+// broadcastPreview готовит рассылку text и возвращает номер предпросмотра и данные
+// кнопок подтверждения и отмены
+func broadcastPreview(t *testing.T, b *Bot, fake *testutil.FakeTelegram, text string) (int, string, string) {
+	t.Helper()
+	b.handleSendMessageCommand(commandUpdate(testAdminChatID, "/sendmsg secret all "+text).Message)
+	sent := fake.SentTo(testAdminChatID)
+	preview := sent[len(sent)-1]
+	if preview.Keyboard == nil {
+		t.Fatalf("предпросмотр без кнопок: %q", preview.Text)
+	}
+	buttons := preview.Keyboard.InlineKeyboard[0]
+	return preview.MessageID, *buttons[0].CallbackData, *buttons[1].CallbackData
+}
+
+func TestBroadcastConfirmation(t *testing.T) {
+	tests := []struct {
+		name string
+		// press нажимает кнопки под предпросмотром
+		press         func(b *Bot, messageID int, confirm, cancel string)
+		wantDelivered bool
+		wantEdit      string
+	}{
+		{"подтверждение", func(b *Bot, messageID int, confirm, cancel string) {
+			b.handleSendMessageCallback(callbackUpdate(testAdminChatID, messageID, confirm).CallbackQuery)
+		}, true, "🔄 Начинаю рассылку сообщения для 2 пользователей..."},
+		{"отмена", func(b *Bot, messageID int, confirm, cancel string) {
+			b.handleSendMessageCallback(callbackUpdate(testAdminChatID, messageID, cancel).CallbackQuery)
+		}, false, "❌ Рассылка отменена"},
+		// Повторное нажатие не повторяет рассылку
+		{"подтверждение дважды", func(b *Bot, messageID int, confirm, cancel string) {
+			b.handleSendMessageCallback(callbackUpdate(testAdminChatID, messageID, confirm).CallbackQuery)
+			b.handleSendMessageCallback(callbackUpdate(testAdminChatID, messageID, confirm).CallbackQuery)
+		}, true, "⌛ Рассылка уже отправлена"},
+		{"отмена после подтверждения", func(b *Bot, messageID int, confirm, cancel string) {
+			b.handleSendMessageCallback(callbackUpdate(testAdminChatID, messageID, confirm).CallbackQuery)
+			b.handleSendMessageCallback(callbackUpdate(testAdminChatID, messageID, cancel).CallbackQuery)
+		}, true, "⌛ Рассылка уже отправлена"},
+		{"подтверждение устаревшей", func(b *Bot, messageID int, confirm, cancel string) {
+			b.broadcastsMu.Lock()
+			for _, broadcast := range b.broadcasts {
+				broadcast.created = broadcast.created.Add(-broadcastConfirmTTL - time.Second)
+			}
+			b.broadcastsMu.Unlock()
+			b.handleSendMessageCallback(callbackUpdate(testAdminChatID, messageID, confirm).CallbackQuery)
+		}, false, "⌛ Рассылка уже отправлена, отменена или устарела"},
+		// Кнопку из чужого чата не принимают
+		{"подтверждение из другого чата", func(b *Bot, messageID int, confirm, cancel string) {
+			b.handleSendMessageCallback(callbackUpdate(1, messageID, confirm).CallbackQuery)
+		}, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, fake := newTestBot(t)
+			for _, userID := range []int64{1, 2} {
+				b.db.AdjustGenerations(userID, 1, 0, "", true)
+			}
+			messageID, confirm, cancel := broadcastPreview(t, b, fake, "5 причин попробовать бота")
+			if preview := fake.LastText(testAdminChatID); !strings.Contains(preview, "2 получателей\n\n5 причин попробовать бота") {
+				t.Errorf("предпросмотр: %q", preview)
+			}
+			if len(fake.SentTo(1))+len(fake.SentTo(2)) != 0 {
+				t.Fatal("рассылка ушла до подтверждения")
+			}
+
+			tt.press(b, messageID, confirm, cancel)
+
+			if tt.wantDelivered {
+				waitFor(t, "итог рассылки", func() bool { return sentText(fake, testAdminChatID, "✅ Рассылка завершена!") })
+			}
+			for _, userID := range []int64{1, 2} {
+				if got := sentTexts(fake, userID); tt.wantDelivered != slices.Equal(got, []string{"5 причин попробовать бота"}) {
+					t.Errorf("пользователю %d отправлено %q", userID, got)
+				}
+			}
+			if tt.wantEdit != "" && !sentText(fake, testAdminChatID, tt.wantEdit) {
+				t.Errorf("сообщения администратору: %q", sentTexts(fake, testAdminChatID))
+			}
+		})
+	}
+}
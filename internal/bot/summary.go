@@ -0,0 +1,103 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"AIGenerator/internal/calendar"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/i18n"
+)
+
+const (
+	// reportUserSummary отметка о еженедельных сводках пользователям среди отметок отчетов
+	reportUserSummary = "user_summary"
+	// summaryMinPosts сколько постов за неделю нужно получить, чтобы пришла сводка
+	summaryMinPosts = 3
+	// summaryTopics сколько самых частых тем показывать в сводке
+	summaryTopics = 3
+	// summaryLowBalance при таком остатке генераций сводка предлагает пополнить баланс
+	summaryLowBalance = 3
+	// maxWeeklySummaries сколько сводок ставится в очередь за неделю: очередь общая
+	// с уведомлениями администратору и не должна ими переполняться
+	maxWeeklySummaries = 500
+)
+
+// sendDueSummaries по понедельникам в час отчета ставит в очередь сводки активности
+// за прошлую календарную неделю. Отметка хранится вместе с отметками отчетов, поэтому
+// перезапуск не приводит к повтору; сводки одной недели к тому же идемпотентны в очереди.
+func (b *Bot) sendDueSummaries(now time.Time) {
+	if !b.config.WeeklySummaries {
+		return
+	}
+	location := b.reportLocation()
+	now = now.In(location)
+	if now.Weekday() != time.Monday || now.Hour() < b.config.ReportHour {
+		return
+	}
+	day := now.Format("2006-01-02")
+	if b.db.LastReport(reportUserSummary) == day {
+		return
+	}
+
+	week := calendar.LastWeek(now, location)
+	recipients := b.db.SummaryRecipients(week.From, week.To, summaryMinPosts, summaryTopics)
+	if len(recipients) > maxWeeklySummaries {
+		log.Printf("[SUMMARY] ⚠️ Сводка положена %d пользователям, отправляю %d самым активным",
+			len(recipients), maxWeeklySummaries)
+		recipients = recipients[:maxWeeklySummaries]
+	}
+
+	queued := 0
+	for _, activity := range recipients {
+		if b.enqueueOutbox(database.OutboxMessage{
+			ChatID:   activity.UserID,
+			Text:     formatWeeklySummary(activity),
+			DedupKey: fmt.Sprintf("weekly_summary:%d:%s", activity.UserID, day),
+		}) {
+			queued++
+		}
+	}
+
+	if err := b.db.MarkReportSent(reportUserSummary, day); err != nil {
+		log.Printf("[SUMMARY] ❌ Ошибка сохранения отметки о сводках: %v", err)
+	}
+	log.Printf("[SUMMARY] ✅ В очередь поставлено сводок за неделю с %s: %d", week.From.Format("2006-01-02"), queued)
+}
+
+// formatWeeklySummary текст сводки активности на языке пользователя. Текст без разметки:
+// темы — это запросы пользователя как есть. При низком балансе без премиума добавляется
+// предложение пополнить его.
+func formatWeeklySummary(activity database.UserActivity) string {
+	lang := activity.Language
+
+	var text strings.Builder
+	text.WriteString(i18n.T(lang, "summary.header"))
+	text.WriteString("\n\n")
+	text.WriteString(i18n.T(lang, "summary.posts", activity.Posts))
+	if len(activity.Topics) > 0 {
+		text.WriteString("\n")
+		text.WriteString(i18n.T(lang, "summary.topics", strings.Join(activity.Topics, ", ")))
+	}
+	if activity.Ratings > 0 {
+		text.WriteString("\n")
+		text.WriteString(i18n.T(lang, "summary.rating", activity.AverageRating, activity.Ratings))
+	}
+
+	text.WriteString("\n")
+	if activity.Premium {
+		text.WriteString(i18n.T(lang, "summary.premium"))
+	} else {
+		text.WriteString(i18n.T(lang, "summary.balance", activity.AvailableGenerations))
+		if activity.AvailableGenerations <= summaryLowBalance {
+			text.WriteString("\n\n")
+			text.WriteString(i18n.T(lang, "summary.low_balance"))
+		}
+	}
+
+	text.WriteString("\n\n")
+	text.WriteString(i18n.T(lang, "summary.opt_out"))
+	return text.String()
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/calendar"
+	"AIGenerator/internal/database"
+)
+
+func TestFormatWeeklySummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		activity database.UserActivity
+		want     string
+	}{
+		{
+			name: "баланс на исходе",
+			activity: database.UserActivity{
+				UserID: 1, Language: "ru", Posts: 5, Topics: []string{"ставка цб", "биткоин", "нефть"},
+				Ratings: 3, AverageRating: 13.0 / 3, AvailableGenerations: summaryLowBalance,
+			},
+			want: `📬 Ваша неделя с ботом
+
+📝 Постов создано: 5
+🔥 Частые темы: ставка цб, биткоин, нефть
+⭐ Средняя оценка постов: 4.3 (оценок: 3)
+✨ Осталось генераций: 3
+
+Генерации скоро закончатся — пополнить баланс можно командой /buy
+
+Отключить сводку: /settings`,
+		},
+		{
+			name: "баланса хватает",
+			activity: database.UserActivity{
+				UserID: 1, Language: "ru", Posts: 3, Topics: []string{"<b>нефть</b>"},
+				AvailableGenerations: summaryLowBalance + 1,
+			},
+			want: `📬 Ваша неделя с ботом
+
+📝 Постов создано: 3
+🔥 Частые темы: <b>нефть</b>
+✨ Осталось генераций: 4
+
+Отключить сводку: /settings`,
+		},
+		{
+			// С премиумом остаток не показывается и пополнить баланс не предлагается
+			name:     "премиум",
+			activity: database.UserActivity{UserID: 1, Language: "en", Posts: 3, Premium: true},
+			want: `📬 Your week with the bot
+
+📝 Posts created: 3
+💎 Premium is active
+
+Turn off this summary: /settings`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatWeeklySummary(tt.activity); got != tt.want {
+				t.Errorf("сводка:\n%s\n\nожидалась:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+// summaryMonday понедельник после текущей недели в час отчета: генерации, записанные
+// в журнал сейчас, попадают в сводку за прошлую для него неделю
+func summaryMonday() time.Time {
+	return calendar.WeekStart(time.Now(), time.UTC).AddDate(0, 0, 7).Add(9 * time.Hour)
+}
+
+// addPosts создает пользователя userID и записывает ему posts успешных генераций
+func addPosts(t *testing.T, b *Bot, userID int64, posts int, update func(*database.Settings)) {
+	t.Helper()
+	if update == nil {
+		update = func(*database.Settings) {}
+	}
+	if _, err := b.db.UpdateSettings(userID, update); err != nil {
+		t.Fatal(err)
+	}
+	for range posts {
+		b.db.AddGeneration(userID, "ставка цб", "РБК", "")
+	}
+}
+
+func TestSendDueSummaries(t *testing.T) {
+	b, _ := newTestBot(t, func(config *Config) {
+		config.WeeklySummaries = true
+		config.ReportHour = 9
+		config.ReportLocation = time.UTC
+	})
+	addPosts(t, b, 1, summaryMinPosts, nil)
+	addPosts(t, b, 2, summaryMinPosts-1, nil)
+	addPosts(t, b, 3, summaryMinPosts+1, func(settings *database.Settings) { settings.WeeklySummaryOff = true })
+	monday := summaryMonday()
+
+	// Не в понедельник и до часа отчета сводки не собираются
+	b.sendDueSummaries(monday.Add(-time.Hour))
+	b.sendDueSummaries(monday.Add(-time.Minute))
+	if pending := b.db.PendingOutbox(); pending != 0 {
+		t.Fatalf("сводок до срока: %d", pending)
+	}
+
+	b.sendDueSummaries(monday)
+	b.sendDueSummaries(monday.Add(time.Hour))
+	queued := b.db.DueOutbox(monday.Add(time.Hour))
+	day := monday.Format("2006-01-02")
+	if len(queued) != 1 || queued[0].ChatID != 1 || queued[0].DedupKey != fmt.Sprintf("weekly_summary:1:%s", day) {
+		t.Fatalf("очередь: %+v", queued)
+	}
+	want := formatWeeklySummary(database.UserActivity{
+		UserID: 1, Posts: summaryMinPosts, Topics: []string{"ставка цб"}, AvailableGenerations: testTrialGenerations,
+	})
+	if queued[0].Text != want {
+		t.Errorf("сводка:\n%s\n\nожидалась:\n%s", queued[0].Text, want)
+	}
+	if last := b.db.LastReport(reportUserSummary); last != day {
+		t.Errorf("отметка %q", last)
+	}
+
+	// Отметка переживает перезапуск: сводка за неделю не повторяется
+	restarted, _ := reopenTestBot(t, func(config *Config) {
+		config.WeeklySummaries = true
+		config.ReportHour = 9
+		config.ReportLocation = time.UTC
+	})
+	restarted.sendDueSummaries(monday.Add(2 * time.Hour))
+	if pending := restarted.db.PendingOutbox(); pending != 1 {
+		t.Errorf("сводок после перезапуска: %d", pending)
+	}
+}
+
+func TestSendDueSummariesDisabled(t *testing.T) {
+	b, _ := newTestBot(t, func(config *Config) {
+		config.WeeklySummaries = false
+		config.ReportHour = 9
+		config.ReportLocation = time.UTC
+	})
+	addPosts(t, b, 1, summaryMinPosts, nil)
+
+	b.sendDueSummaries(summaryMonday())
+	if pending := b.db.PendingOutbox(); pending != 0 || b.db.LastReport(reportUserSummary) != "" {
+		t.Errorf("выключенные сводки: в очереди %d", pending)
+	}
+}
+
+func TestSendDueSummariesCap(t *testing.T) {
+	b, _ := newTestBot(t, func(config *Config) {
+		config.WeeklySummaries = true
+		config.ReportHour = 9
+		config.ReportLocation = time.UTC
+	})
+	// Сводка положена на одного пользователя больше предела; самый активный — последний
+	for userID := int64(1); userID <= maxWeeklySummaries; userID++ {
+		addPosts(t, b, userID, summaryMinPosts, nil)
+	}
+	addPosts(t, b, maxWeeklySummaries+1, summaryMinPosts+1, nil)
+
+	monday := summaryMonday()
+	b.sendDueSummaries(monday)
+	queued := b.db.DueOutbox(monday)
+	if len(queued) != maxWeeklySummaries {
+		t.Fatalf("в очереди %d сводок, ожидалось %d", len(queued), maxWeeklySummaries)
+	}
+	chats := make(map[int64]bool)
+	for _, message := range queued {
+		chats[message.ChatID] = true
+	}
+	if !chats[maxWeeklySummaries+1] || chats[maxWeeklySummaries] {
+		t.Error("за пределом остался не самый неактивный пользователь")
+	}
+}
@@ -0,0 +1,208 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// Этапы генерации в хронологии
+const (
+	stageQueue      = "queue"
+	stageBalance    = "balance"
+	stageModeration = "moderation"
+	stageFetch      = "fetch"
+	stageRank       = "rank"
+	stageSelect     = "select"
+	stageAI         = "ai"
+	stageCharge     = "charge"
+	stageDelivery   = "delivery"
+)
+
+// traceStages этапы в порядке прохождения: в этом порядке они показываются в /statistics
+var traceStages = []string{stageQueue, stageBalance, stageModeration, stageFetch, stageRank,
+	stageSelect, stageAI, stageCharge, stageDelivery}
+
+// traceStage этап генерации: когда начался и сколько длился
+type traceStage struct {
+	name     string
+	started  time.Time
+	duration time.Duration
+}
+
+// generationTrace хронология одной генерации. Генерация сама отмечает начало каждого этапа,
+// а по завершении хронология пишется в лог одной записью. Методы безопасны для nil:
+// тогда генерация идет без хронологии.
+type generationTrace struct {
+	id      string
+	userID  int64
+	kind    string
+	started time.Time
+	stages  []traceStage
+	// open начат ли последний этап и еще не закончен
+	open bool
+	// photoStage каким этапом отправки дошла картинка поста по ссылке; пусто — ее не было
+	photoStage string
+}
+
+// newGenerationTrace начинает хронологию генерации, поставленной в очередь в queued:
+// первый этап — ожидание в очереди
+func newGenerationTrace(userID int64, kind string, queued time.Time) *generationTrace {
+	trace := &generationTrace{id: newRequestID(), userID: userID, kind: kind, started: queued}
+	trace.stages = append(trace.stages, traceStage{name: stageQueue, started: queued})
+	trace.open = true
+	return trace
+}
+
+// newRequestID короткий случайный код запроса, который пользователь может назвать в поддержке
+func newRequestID() string {
+	id := make([]byte, 4)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// ID код запроса; пустой, если хронологии нет
+func (t *generationTrace) ID() string {
+	if t == nil {
+		return ""
+	}
+	return t.id
+}
+
+// stage заканчивает текущий этап и начинает этап name
+func (t *generationTrace) stage(name string) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	t.close(now)
+	t.stages = append(t.stages, traceStage{name: name, started: now})
+	t.open = true
+}
+
+// split отдает последние tail текущего этапа этапу name, который становится текущим.
+// Нужен, когда один вызов проходит несколько этапов и сам сообщает их длительность.
+func (t *generationTrace) split(name string, tail time.Duration) {
+	if t == nil || !t.open {
+		return
+	}
+	now := time.Now()
+	current := &t.stages[len(t.stages)-1]
+	started := now.Add(-min(max(tail, 0), now.Sub(current.started)))
+	current.duration = started.Sub(current.started)
+	t.stages = append(t.stages, traceStage{name: name, started: started})
+}
+
+// photo запоминает, каким этапом отправки дошла картинка поста: photoByURL, photoRetried,
+// photoUploaded или photoTextOnly
+func (t *generationTrace) photo(stage string) {
+	if t == nil {
+		return
+	}
+	t.photoStage = stage
+}
+
+// close заканчивает текущий этап
+func (t *generationTrace) close(now time.Time) {
+	if !t.open {
+		return
+	}
+	current := &t.stages[len(t.stages)-1]
+	current.duration = now.Sub(current.started)
+	t.open = false
+}
+
+// total длительность генерации от постановки в очередь до завершения
+func (t *generationTrace) total() time.Duration {
+	if t == nil || len(t.stages) == 0 {
+		return 0
+	}
+	last := t.stages[len(t.stages)-1]
+	return last.started.Add(last.duration).Sub(t.started)
+}
+
+// stageMillis длительности этапов в миллисекундах; повторный этап суммируется
+func (t *generationTrace) stageMillis() map[string]int64 {
+	if t == nil {
+		return nil
+	}
+	stages := make(map[string]int64, len(t.stages))
+	for _, stage := range t.stages {
+		stages[stage.name] += stage.duration.Milliseconds()
+	}
+	return stages
+}
+
+// traceRecord запись хронологии в логе
+type traceRecord struct {
+	RequestID string             `json:"request_id"`
+	UserID    int64              `json:"user_id"`
+	Kind      string             `json:"kind"`
+	Outcome   string             `json:"outcome"`
+	TotalMs   int64              `json:"total_ms"`
+	Stages    []traceStageRecord `json:"stages"`
+	// Photo этап отправки картинки поста по ссылке
+	Photo string `json:"photo,omitempty"`
+}
+
+// traceStageRecord этап в записи хронологии: начало отсчитывается от постановки в очередь
+type traceStageRecord struct {
+	Name       string `json:"name"`
+	StartMs    int64  `json:"start_ms"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// finish заканчивает последний этап и пишет хронологию в лог одной записью
+func (t *generationTrace) finish(outcome string) {
+	if t == nil {
+		return
+	}
+	t.close(time.Now())
+
+	record := traceRecord{
+		RequestID: t.id,
+		UserID:    t.userID,
+		Kind:      t.kind,
+		Outcome:   outcome,
+		TotalMs:   t.total().Milliseconds(),
+		Photo:     t.photoStage,
+	}
+	for _, stage := range t.stages {
+		record.Stages = append(record.Stages, traceStageRecord{
+			Name:       stage.name,
+			StartMs:    stage.started.Sub(t.started).Milliseconds(),
+			DurationMs: stage.duration.Milliseconds(),
+		})
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("[TRACE] ❌ Ошибка кодирования хронологии %s: %v", t.id, err)
+		return
+	}
+	log.Printf("[TRACE] %s", data)
+}
+
+type traceKey struct{}
+
+// withTrace передает генерации ее хронологию
+func withTrace(ctx context.Context, trace *generationTrace) context.Context {
+	return context.WithValue(ctx, traceKey{}, trace)
+}
+
+// traceFrom возвращает хронологию генерации или nil
+func traceFrom(ctx context.Context) *generationTrace {
+	trace, _ := ctx.Value(traceKey{}).(*generationTrace)
+	return trace
+}
+
+// failGeneration показывает в сообщении прогресса ошибку генерации с кодом запроса:
+// по нему в логе находится хронология, если пользователь сообщит о проблеме
+func (b *Bot) failGeneration(ctx context.Context, progress *progressMessage, text string) {
+	if id := traceFrom(ctx).ID(); id != "" {
+		text += "\n\n" + b.t(progress.chatID, "generate.request_id", id)
+	}
+	progress.finish(text)
+}
@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/news"
+)
+
+// lockedBuffer буфер лога, в который пишут несколько горутин
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// captureTraces перехватывает лог на время теста и возвращает функцию, разбирающую
+// записанные хронологии генераций
+func captureTraces(t *testing.T) func() []traceRecord {
+	t.Helper()
+	output := &lockedBuffer{}
+	saved := log.Writer()
+	log.SetOutput(output)
+	t.Cleanup(func() { log.SetOutput(saved) })
+
+	return func() []traceRecord {
+		var records []traceRecord
+		for _, line := range strings.Split(output.String(), "\n") {
+			_, data, ok := strings.Cut(line, "[TRACE] ")
+			if !ok {
+				continue
+			}
+			var record traceRecord
+			if err := json.Unmarshal([]byte(data), &record); err != nil {
+				t.Fatalf("запись хронологии не JSON: %q", data)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+}
+
+// stageNames этапы записи хронологии по порядку
+func stageNames(record traceRecord) []string {
+	var names []string
+	for _, stage := range record.Stages {
+		names = append(names, stage.Name)
+	}
+	return names
+}
+
+func TestTraceStages(t *testing.T) {
+	queued := time.Now().Add(-time.Second)
+	trace := newGenerationTrace(1, "keywords", queued)
+	trace.stage(stageBalance)
+	trace.stage(stageFetch)
+	time.Sleep(20 * time.Millisecond)
+	// Последние 10 мс поиска пришлись на ранжирование
+	trace.split(stageRank, 10*time.Millisecond)
+	trace.stage(stageAI)
+	trace.close(time.Now())
+
+	if len(trace.ID()) != 8 {
+		t.Errorf("код запроса %q", trace.ID())
+	}
+	names := make([]string, 0, len(trace.stages))
+	for _, stage := range trace.stages {
+		names = append(names, stage.name)
+	}
+	if want := []string{stageQueue, stageBalance, stageFetch, stageRank, stageAI}; !slices.Equal(names, want) {
+		t.Fatalf("этапы %v, ожидались %v", names, want)
+	}
+
+	millis := trace.stageMillis()
+	if millis[stageQueue] < 1000 || millis[stageFetch] < 5 || millis[stageRank] < 10 || millis[stageRank] > 15 {
+		t.Errorf("длительности этапов: %v", millis)
+	}
+	// Этапы идут встык: общая длительность — сумма этапов
+	var sum time.Duration
+	for _, stage := range trace.stages {
+		sum += stage.duration
+	}
+	if total := trace.total(); total != sum {
+		t.Errorf("всего %v, сумма этапов %v", total, sum)
+	}
+}
+
+func TestTraceSplitLongerThanStage(t *testing.T) {
+	trace := newGenerationTrace(1, "keywords", time.Now())
+	trace.stage(stageFetch)
+	// Хвост длиннее этапа не уходит в прошлое
+	trace.split(stageRank, time.Hour)
+	if fetch := trace.stages[1]; fetch.duration < 0 || trace.stages[2].started.Before(fetch.started) {
+		t.Errorf("этапы: %+v", trace.stages)
+	}
+}
+
+func TestNilTrace(t *testing.T) {
+	var trace *generationTrace
+	trace.stage(stageAI)
+	trace.split(stageRank, time.Second)
+	trace.photo(photoTextOnly)
+	trace.finish("success")
+	if trace.ID() != "" || trace.total() != 0 || trace.stageMillis() != nil {
+		t.Error("nil-хронология")
+	}
+}
+
+func TestTraceCapturesStages(t *testing.T) {
+	article := news.Article{Title: "Ставка ЦБ", URL: "https://example.com/rate", Source: "РБК"}
+	tests := []struct {
+		name        string
+		configure   func(gpt *fakeGPT, search *fakeNews)
+		wantOutcome string
+		wantStages  []string
+		// wantRequestID код запроса показан пользователю
+		wantRequestID bool
+	}{
+		{"успех", nil, "success",
+			[]string{stageQueue, stageBalance, stageModeration, stageFetch, stageRank, stageSelect, stageAI, stageDelivery, stageCharge}, false},
+		{"нет новостей", func(gpt *fakeGPT, search *fakeNews) { search.articles = nil }, "failed",
+			[]string{stageQueue, stageBalance, stageModeration, stageFetch, stageRank}, false},
+		{"ошибка модели", func(gpt *fakeGPT, search *fakeNews) { gpt.postErr = errors.New("503") }, "failed",
+			[]string{stageQueue, stageBalance, stageModeration, stageFetch, stageRank, stageSelect, stageAI}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			traces := captureTraces(t)
+			b, fake := newTestBot(t)
+			gpt, search := newFakeGPT(), &fakeNews{articles: []news.Article{article}}
+			if tt.configure != nil {
+				tt.configure(gpt, search)
+			}
+			useGenerator(b, gpt, search)
+			runBot(t, b)
+
+			fake.Feed(commandUpdate(1, "/generate ставка цб"))
+			waitFor(t, "хронология", func() bool { return len(traces()) == 1 })
+
+			record := traces()[0]
+			if record.Outcome != tt.wantOutcome || record.UserID != 1 || record.Kind != "keywords" {
+				t.Errorf("запись: %+v", record)
+			}
+			if names := stageNames(record); !slices.Equal(names, tt.wantStages) {
+				t.Errorf("этапы %v, ожидались %v", names, tt.wantStages)
+			}
+			var sum int64
+			for _, stage := range record.Stages {
+				sum += stage.DurationMs
+			}
+			if record.TotalMs < sum {
+				t.Errorf("всего %d мс меньше суммы этапов %d мс", record.TotalMs, sum)
+			}
+
+			// Длительность успешной генерации попадает в журнал генераций
+			if tt.wantOutcome == "success" {
+				generations, _, _ := b.db.History()
+				if len(generations) != 1 || generations[0].RequestID != record.RequestID || generations[0].DurationMs != record.TotalMs {
+					t.Errorf("журнал генераций: %+v, хронология %s", generations, record.RequestID)
+				}
+			}
+			requestID := i18n.T("ru", "generate.request_id", record.RequestID)
+			waitFor(t, "итог генерации", func() bool { return len(fake.SentTo(1)) > 0 })
+			if shown := sentText(fake, 1, requestID); shown != tt.wantRequestID {
+				t.Errorf("код запроса показан: %t, ожидалось %t", shown, tt.wantRequestID)
+			}
+		})
+	}
+}
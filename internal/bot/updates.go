@@ -0,0 +1,90 @@
+package bot
+
+import (
+	"context"
+	"log"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// offsetFlushInterval как часто сохраняется смещение после обновлений без побочных эффектов
+const offsetFlushInterval = 5 * time.Second
+
+// readOnlyCommands команды, повтор которых после перезапуска безвреден: смещение после них
+// сохраняется с задержкой. Перед остальными обновлениями (генерации, платежи, оценки,
+// отзывы, рассылки) оно сохраняется сразу, чтобы повторно доставленное обновление
+// не выполнилось дважды.
+var readOnlyCommands = map[string]bool{
+	"start":        true,
+	"help":         true,
+	"balance":      true,
+	"premium":      true,
+	"statistics":   true,
+	"payments":     true,
+	"trends":       true,
+	"sourcestatus": true,
+	"ailog":        true,
+	"logs":         true,
+	"findlogs":     true,
+	"status":       true,
+}
+
+// updateOffset возвращает смещение, с которого запрашивать обновления: следующее
+// за последним обработанным до перезапуска
+func (b *Bot) updateOffset() int {
+	if last := b.db.LastUpdateID(); last > 0 {
+		return last + 1
+	}
+	return 0
+}
+
+// readOnlyUpdate безвреден ли повтор обновления: inline-запрос только показывает
+// готовые посты, а /start с параметром inline-режима может открыть покупку
+func readOnlyUpdate(update tgbotapi.Update) bool {
+	if update.InlineQuery != nil {
+		return true
+	}
+	if update.Message == nil || !update.Message.IsCommand() {
+		return false
+	}
+	command := update.Message.Command()
+	if command == "start" && update.Message.CommandArguments() != "" {
+		return false
+	}
+	return readOnlyCommands[command]
+}
+
+// acceptUpdate отмечает обновление обработанным и возвращает false, если оно уже
+// обрабатывалось: после сбоя Telegram повторяет обновления с последнего подтвержденного
+// смещения.
+func (b *Bot) acceptUpdate(update tgbotapi.Update) bool {
+	accepted, err := b.db.AcceptUpdate(update.UpdateID, !readOnlyUpdate(update))
+	if err != nil {
+		log.Printf("[BOT] ❌ Ошибка сохранения смещения обновлений: %v", err)
+	}
+	if !accepted {
+		log.Printf("[BOT] ⚠️ Обновление %d уже обработано, пропускаю", update.UpdateID)
+	}
+	return accepted
+}
+
+// runOffsetFlush периодически сохраняет смещение обновлений; при завершении его
+// сохраняет Database.Close
+func (b *Bot) runOffsetFlush(ctx context.Context) {
+	ticker := time.NewTicker(offsetFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.stopping:
+			return
+		case <-ticker.C:
+			if err := b.db.FlushUpdateOffset(); err != nil {
+				log.Printf("[BOT] ❌ Ошибка сохранения смещения обновлений: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package bot
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"AIGenerator/internal/news"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// savedOffset последнее обновление, записанное в файл смещения; 0 — файла нет
+func savedOffset(t *testing.T) int {
+	t.Helper()
+	data, err := os.ReadFile("updates.json")
+	if os.IsNotExist(err) {
+		return 0
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var offset struct {
+		LastUpdateID int `json:"last_update_id"`
+	}
+	if err := json.Unmarshal(data, &offset); err != nil {
+		t.Fatal(err)
+	}
+	return offset.LastUpdateID
+}
+
+func TestReplayedUpdateAfterRestartRunsOnce(t *testing.T) {
+	articles := []news.Article{{Title: "Ставка ЦБ", URL: "https://example.com/rate", Source: "РБК"}}
+	b, fake := newTestBot(t)
+	gpt := newFakeGPT()
+	useGenerator(b, gpt, &fakeNews{articles: articles})
+	if _, err := b.db.AdjustGenerations(1, 3, testAdminChatID, "тест", true); err != nil {
+		t.Fatal(err)
+	}
+	stop := runBot(t, b)
+
+	generate := commandUpdate(1, "/generate ставка цб")
+	generate.UpdateID = 41
+	fake.Feed(generate)
+	waitFor(t, "генерация", func() bool { return gpt.written.Load() == 1 })
+	stop()
+
+	// После перезапуска бот продолжает со следующего обновления, а повторно
+	// доставленное обновление не выполняется
+	restarted, restartedFake := reopenTestBot(t)
+	restartedGPT := newFakeGPT()
+	useGenerator(restarted, restartedGPT, &fakeNews{articles: articles})
+	runBot(t, restarted)
+	waitFor(t, "запрос обновлений", func() bool { return restartedFake.Offset() == 42 })
+
+	restartedFake.Feed(generate)
+	next := commandUpdate(1, "/balance")
+	next.UpdateID = 42
+	restartedFake.Feed(next)
+	waitFor(t, "ответ на следующее обновление", func() bool { return len(restartedFake.SentTo(1)) > 0 })
+
+	if written := restartedGPT.written.Load(); written != 0 {
+		t.Errorf("повторное обновление выполнено: написано %d постов", written)
+	}
+	if balance := restarted.db.GetUser(1).AvailableGenerations; balance != 2 {
+		t.Errorf("доступно %d, ожидалось 2", balance)
+	}
+}
+
+func TestOffsetPersistence(t *testing.T) {
+	b, fake := newTestBot(t)
+	runBot(t, b)
+
+	// После команд только для чтения смещение сохраняется с задержкой
+	fake.Feed(commandUpdate(1, "/help"))
+	fake.Feed(commandUpdate(1, "/balance"))
+	waitFor(t, "ответы", func() bool { return len(fake.SentTo(1)) == 2 })
+	if saved := savedOffset(t); saved != 0 {
+		t.Errorf("смещение сохранено после команд для чтения: %d", saved)
+	}
+	if last := b.db.LastUpdateID(); last != 2 {
+		t.Errorf("принято обновление %d, ожидалось 2", last)
+	}
+
+	// Перед обновлением с побочными эффектами смещение сохраняется сразу
+	fake.Feed(callbackUpdate(1, 1, "rate_5_ставка цб"))
+	waitFor(t, "смещение", func() bool { return savedOffset(t) == 3 })
+
+	// Отложенное смещение дописывается при сохранении
+	fake.Feed(commandUpdate(1, "/help"))
+	waitFor(t, "ответ", func() bool { return b.db.LastUpdateID() == 4 })
+	if err := b.db.FlushUpdateOffset(); err != nil {
+		t.Fatal(err)
+	}
+	if saved := savedOffset(t); saved != 4 {
+		t.Errorf("сохранено смещение %d, ожидалось 4", saved)
+	}
+}
+
+func TestReadOnlyUpdate(t *testing.T) {
+	tests := []struct {
+		name   string
+		update tgbotapi.Update
+		want   bool
+	}{
+		{"справка", commandUpdate(1, "/help"), true},
+		{"баланс", commandUpdate(1, "/balance"), true},
+		{"статистика", commandUpdate(1, "/statistics secret"), true},
+		{"start", commandUpdate(1, "/start"), true},
+		// Параметр /start из inline-режима может открыть покупку
+		{"start с параметром", commandUpdate(1, "/start gen_0YHRgtCw0LLQutCw"), false},
+		{"генерация", commandUpdate(1, "/generate ставка цб"), false},
+		{"покупка", commandUpdate(1, "/buy"), false},
+		{"текст", commandUpdate(1, "просто текст"), false},
+		{"кнопка", callbackUpdate(1, 1, "rate_5_ставка цб"), false},
+		{"inline-запрос", tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{Query: "пост"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := readOnlyUpdate(tt.update); got != tt.want {
+				t.Errorf("readOnlyUpdate = %t, ожидалось %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package calendar
+
+import "time"
+
+// DefaultLocation часовой пояс отчетов, если REPORT_TIMEZONE не задана
+const DefaultLocation = "Europe/Moscow"
+
+// Period промежуток [From, To)
+type Period struct {
+	From time.Time
+	To   time.Time
+}
+
+// Contains проверяет, что t попадает в период
+func (p Period) Contains(t time.Time) bool {
+	return !t.Before(p.From) && t.Before(p.To)
+}
+
+// Shift сдвигает период на days календарных дней
+func (p Period) Shift(days int) Period {
+	return Period{From: p.From.AddDate(0, 0, days), To: p.To.AddDate(0, 0, days)}
+}
+
+// DayStart начало суток, в которые попадает t, в поясе loc
+func DayStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// WeekStart начало недели (понедельник), в которую попадает t, в поясе loc
+func WeekStart(t time.Time, loc *time.Location) time.Time {
+	day := DayStart(t, loc)
+	// Неделя начинается с понедельника: воскресенье — ее седьмой день
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// MonthStart начало месяца, в который попадает t, в поясе loc
+func MonthStart(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+// Today сегодняшние сутки до момента now
+func Today(now time.Time, loc *time.Location) Period {
+	return Period{From: DayStart(now, loc), To: now}
+}
+
+// Yesterday вчерашние сутки целиком
+func Yesterday(now time.Time, loc *time.Location) Period {
+	today := DayStart(now, loc)
+	return Period{From: today.AddDate(0, 0, -1), To: today}
+}
+
+// ThisWeek текущая неделя с понедельника до момента now
+func ThisWeek(now time.Time, loc *time.Location) Period {
+	return Period{From: WeekStart(now, loc), To: now}
+}
+
+// LastWeek прошлая неделя целиком, с понедельника по воскресенье
+func LastWeek(now time.Time, loc *time.Location) Period {
+	week := WeekStart(now, loc)
+	return Period{From: week.AddDate(0, 0, -7), To: week}
+}
+
+// ThisMonth текущий месяц с первого числа до момента now
+func ThisMonth(now time.Time, loc *time.Location) Period {
+	return Period{From: MonthStart(now, loc), To: now}
+}
@@ -0,0 +1,109 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// mustLocation часовой пояс name
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestPeriods(t *testing.T) {
+	moscow := mustLocation(t, DefaultLocation)
+	berlin := mustLocation(t, "Europe/Berlin")
+	at := func(loc *time.Location, year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, loc)
+	}
+	tests := []struct {
+		name   string
+		period func(now time.Time, loc *time.Location) Period
+		loc    *time.Location
+		now    time.Time
+		want   Period
+	}{
+		// По UTC еще 28 февраля, в Москве уже 1 марта
+		{"сегодня после полуночи по Москве", Today, moscow, at(time.UTC, 2026, 2, 28, 22, 30),
+			Period{at(moscow, 2026, 3, 1, 0, 0), at(time.UTC, 2026, 2, 28, 22, 30)}},
+		{"вчера на границе месяца", Yesterday, moscow, at(moscow, 2026, 3, 1, 1, 30),
+			Period{at(moscow, 2026, 2, 28, 0, 0), at(moscow, 2026, 3, 1, 0, 0)}},
+		{"вчера на границе года", Yesterday, moscow, at(moscow, 2026, 1, 1, 10, 0),
+			Period{at(moscow, 2025, 12, 31, 0, 0), at(moscow, 2026, 1, 1, 0, 0)}},
+		{"вчера в високосный год", Yesterday, moscow, at(moscow, 2028, 3, 1, 10, 0),
+			Period{at(moscow, 2028, 2, 29, 0, 0), at(moscow, 2028, 3, 1, 0, 0)}},
+		{"неделя в воскресенье", ThisWeek, moscow, at(moscow, 2026, 3, 1, 23, 59),
+			Period{at(moscow, 2026, 2, 23, 0, 0), at(moscow, 2026, 3, 1, 23, 59)}},
+		{"неделя в понедельник", ThisWeek, moscow, at(moscow, 2026, 10, 12, 0, 0),
+			Period{at(moscow, 2026, 10, 12, 0, 0), at(moscow, 2026, 10, 12, 0, 0)}},
+		{"неделя через границу года", ThisWeek, moscow, at(moscow, 2026, 1, 1, 10, 0),
+			Period{at(moscow, 2025, 12, 29, 0, 0), at(moscow, 2026, 1, 1, 10, 0)}},
+		{"прошлая неделя", LastWeek, moscow, at(moscow, 2026, 10, 16, 12, 0),
+			Period{at(moscow, 2026, 10, 5, 0, 0), at(moscow, 2026, 10, 12, 0, 0)}},
+		{"месяц первого числа", ThisMonth, moscow, at(moscow, 2026, 3, 1, 0, 0),
+			Period{at(moscow, 2026, 3, 1, 0, 0), at(moscow, 2026, 3, 1, 0, 0)}},
+		{"месяц по UTC предыдущий", ThisMonth, moscow, at(time.UTC, 2026, 3, 31, 21, 0),
+			Period{at(moscow, 2026, 4, 1, 0, 0), at(time.UTC, 2026, 3, 31, 21, 0)}},
+		// В поясе с переходом на летнее время сутки перехода короче 24 часов
+		{"вчера в день перехода на летнее время", Yesterday, berlin, at(berlin, 2026, 3, 30, 12, 0),
+			Period{at(berlin, 2026, 3, 29, 0, 0), at(berlin, 2026, 3, 30, 0, 0)}},
+		{"неделя с переходом на зимнее время", LastWeek, berlin, at(berlin, 2026, 10, 26, 12, 0),
+			Period{at(berlin, 2026, 10, 19, 0, 0), at(berlin, 2026, 10, 26, 0, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.period(tt.now, tt.loc)
+			if !got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) {
+				t.Errorf("период [%v, %v), ожидался [%v, %v)", got.From, got.To, tt.want.From, tt.want.To)
+			}
+			if got.From.Location() != tt.loc {
+				t.Errorf("начало периода в поясе %v", got.From.Location())
+			}
+		})
+	}
+}
+
+func TestDayLengthAcrossDST(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	tests := []struct {
+		day  time.Time
+		want time.Duration
+	}{
+		{time.Date(2026, 3, 29, 12, 0, 0, 0, berlin), 23 * time.Hour},
+		{time.Date(2026, 10, 25, 12, 0, 0, 0, berlin), 25 * time.Hour},
+		{time.Date(2026, 10, 16, 12, 0, 0, 0, berlin), 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		// Сутки — от полуночи до полуночи, а не 24 часа
+		day := Yesterday(tt.day.AddDate(0, 0, 1), berlin)
+		if got := day.To.Sub(day.From); got != tt.want {
+			t.Errorf("%s: сутки %v, ожидалось %v", tt.day.Format("2006-01-02"), got, tt.want)
+		}
+		if !day.Contains(tt.day) {
+			t.Errorf("%s: сутки не содержат свой полдень", tt.day.Format("2006-01-02"))
+		}
+	}
+}
+
+func TestPeriodContainsAndShift(t *testing.T) {
+	moscow := mustLocation(t, DefaultLocation)
+	day := Yesterday(time.Date(2026, 3, 1, 10, 0, 0, 0, moscow), moscow)
+
+	if !day.Contains(day.From) || day.Contains(day.To) || !day.Contains(day.To.Add(-time.Nanosecond)) {
+		t.Error("период включает начало и не включает конец")
+	}
+	// Сдвиг на календарные дни переходит через конец месяца
+	next := day.Shift(1)
+	if want := time.Date(2026, 3, 1, 0, 0, 0, 0, moscow); !next.From.Equal(want) || !next.To.Equal(want.AddDate(0, 0, 1)) {
+		t.Errorf("сдвинутый период [%v, %v)", next.From, next.To)
+	}
+	if previous := day.Shift(-28); !previous.From.Equal(time.Date(2026, 1, 31, 0, 0, 0, 0, moscow)) {
+		t.Errorf("период месяцем раньше начинается %v", previous.From)
+	}
+}
@@ -0,0 +1,439 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	// База часовых поясов встроена: в контейнере без tzdata REPORT_TIMEZONE иначе не загрузится
+	_ "time/tzdata"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/bot"
+	"AIGenerator/internal/calendar"
+	"AIGenerator/internal/database"
+	"AIGenerator/internal/diagnostics"
+	"AIGenerator/internal/events"
+	"AIGenerator/internal/health"
+	"AIGenerator/internal/httpx"
+	"AIGenerator/internal/logging"
+	"AIGenerator/internal/news"
+	"AIGenerator/internal/payment"
+	"AIGenerator/internal/reporting"
+	"AIGenerator/internal/selftest"
+	"AIGenerator/internal/texts"
+)
+
+const (
+	// defaultStatisticsPassword пароль админских команд, если STATISTICS_PASSWORD не задан
+	defaultStatisticsPassword = "admin123"
+	// usersFile файл пользователей
+	usersFile = "users.json"
+)
+
+// Config все настройки приложения. Переменные окружения читаются только здесь,
+// остальные пакеты получают свою часть через конструкторы.
+type Config struct {
+	// DataDir каталог для кэша новостей и журнала запросов к модели
+	DataDir string
+
+	Bot       bot.Config
+	AI        ai.AIConfig
+	Database  database.Config
+	Payment   payment.Config
+	News      news.Config
+	HTTP      httpx.Config
+	Logging   logging.Config
+	Health    health.Config
+	Debug     diagnostics.Config
+	Reporting reporting.Config
+	SelfTest  selftest.Config
+	Events    events.Config
+	Texts     texts.Config
+}
+
+// PaymentEnabled сообщает, заданы ли ключи ЮKassa
+func (c Config) PaymentEnabled() bool {
+	return c.Payment.ShopID != "" && c.Payment.SecretKey != ""
+}
+
+// Secrets возвращает ключи и токены, которые не должны попадать в лог
+func (c Config) Secrets() []string {
+	return []string{c.Bot.Token, c.AI.Yandex.APIKey, c.AI.OpenAI.APIKey, c.Payment.SecretKey, c.Reporting.DSN,
+		c.Debug.BasicAuthPassword}
+}
+
+// Load читает настройки из окружения и проверяет их. Возвращает ошибку со всеми
+// отсутствующими и некорректными значениями сразу.
+func Load() (Config, error) {
+	var l loader
+
+	config := Config{
+		DataDir: l.string("DATA_DIR", "."),
+	}
+
+	// Бот
+	config.Bot = bot.DefaultConfig()
+	config.Bot.Token = l.required("TELEGRAM_BOT_TOKEN")
+	config.Bot.AdminChatID = l.int64("ADMIN_CHAT_ID", 0)
+	config.Bot.AdminPassword = l.string("STATISTICS_PASSWORD", defaultStatisticsPassword)
+	config.Bot.GenerationTimeout = l.duration("GENERATION_TIMEOUT", config.Bot.GenerationTimeout)
+	config.Bot.ExpandChargeGeneration = l.bool("EXPAND_CHARGE_GENERATION", false)
+	config.Bot.HeadlinesCost = l.float("HEADLINES_GENERATION_COST", config.Bot.HeadlinesCost, 0, 1)
+	config.Bot.ShutdownTimeout = l.duration("SHUTDOWN_TIMEOUT", config.Bot.ShutdownTimeout)
+	config.Bot.ReportHour = l.int("DAILY_REPORT_HOUR", config.Bot.ReportHour, -1, 23)
+	config.Bot.ReportLocation = l.location("REPORT_TIMEZONE", calendar.DefaultLocation)
+	config.Bot.WeeklySummaries = l.bool("WEEKLY_USER_SUMMARY", config.Bot.WeeklySummaries)
+	config.Bot.GenerationWorkers = l.int("GENERATION_WORKERS", config.Bot.GenerationWorkers, 1, 100)
+	config.Bot.GenerationQueueSize = l.int("GENERATION_QUEUE_SIZE", config.Bot.GenerationQueueSize, 1, 10000)
+	config.Bot.PhotoRetryMaxDelay = l.durationOrZero("PHOTO_RETRY_MAX_DELAY", config.Bot.PhotoRetryMaxDelay)
+
+	config.Database = database.Config{
+		File:                 usersFile,
+		StatisticsPassword:   config.Bot.AdminPassword,
+		FreeTrialGenerations: l.int("FREE_TRIAL_GENERATIONS", database.DefaultFreeTrialGenerations, 0, 1000),
+	}
+
+	config.AI = l.ai(config.DataDir)
+
+	// ЮKassa необязательна, но ключи задаются парой
+	config.Payment = payment.Config{
+		ShopID:    l.string("YOOMONEY_SHOP_ID", ""),
+		SecretKey: l.string("YOOMONEY_SECRET_KEY", ""),
+		ReturnURL: l.string("YOOMONEY_RETURN_URL", ""),
+	}
+	if (config.Payment.ShopID == "") != (config.Payment.SecretKey == "") {
+		l.fail(fmt.Errorf("YOOMONEY_SHOP_ID и YOOMONEY_SECRET_KEY задаются вместе"))
+	}
+
+	// Новости
+	config.News = news.DefaultConfig()
+	config.News.CacheTTL = l.duration("NEWS_CACHE_TTL", config.News.CacheTTL)
+	config.News.SemanticWeight = l.float("NEWS_SEMANTIC_WEIGHT", config.News.SemanticWeight, 0, 1)
+	config.News.PrefetchInterval = l.duration("NEWS_PREFETCH_INTERVAL", 0)
+
+	// Исходящие запросы
+	config.HTTP = httpx.DefaultConfig()
+	config.HTTP.UserAgent = l.string("HTTP_USER_AGENT", config.HTTP.UserAgent)
+	config.HTTP.DomainConcurrency = l.int("HTTP_DOMAIN_CONCURRENCY", config.HTTP.DomainConcurrency, 1, 100)
+	config.HTTP.DomainInterval = l.durationOrZero("HTTP_DOMAIN_MIN_INTERVAL", config.HTTP.DomainInterval)
+	config.HTTP.RespectRobots = l.bool("HTTP_RESPECT_ROBOTS", false)
+	config.HTTP.NewsProxy = l.proxy("HTTP_PROXY_NEWS")
+	config.HTTP.AIProxy = l.proxy("HTTP_PROXY_AI")
+	config.HTTP.OutboundProxy = l.proxy("OUTBOUND_PROXY")
+	config.HTTP.AIUseOutboundProxy = l.bool("AI_USE_OUTBOUND_PROXY", false)
+
+	// Логирование
+	config.Logging = logging.DefaultConfig()
+	config.Logging.Level = l.level("LOG_LEVEL", config.Logging.Level)
+	config.Logging.Format = l.oneOf("LOG_FORMAT", config.Logging.Format, logging.FormatJSON, logging.FormatText)
+	config.Logging.File = l.string("LOG_FILE", config.Logging.File)
+	config.Logging.MaxSizeMB = l.int("LOG_MAX_SIZE_MB", config.Logging.MaxSizeMB, 0, 10240)
+	config.Logging.MaxBackups = l.int("LOG_MAX_BACKUPS", config.Logging.MaxBackups, 0, 1000)
+	config.Logging.MaxAgeDays = l.int("LOG_MAX_AGE_DAYS", config.Logging.MaxAgeDays, 0, 3650)
+	config.Bot.LogFile = config.Logging.File
+
+	// Проверки состояния
+	config.Health = health.DefaultConfig()
+	config.Health.Port = l.int("HEALTH_PORT", 0, 0, 65535)
+	config.Health.ProbeInterval = l.duration("HEALTH_PROBE_INTERVAL", config.Health.ProbeInterval)
+	if config.Health.ProbeInterval < health.MinProbeInterval {
+		l.fail(fmt.Errorf("HEALTH_PROBE_INTERVAL: не меньше %v", health.MinProbeInterval))
+	}
+	config.Health.MaxUpdateAge = l.durationOrZero("HEALTH_MAX_UPDATE_AGE", 0)
+
+	// Отладка
+	config.Debug = diagnostics.DefaultConfig()
+	config.Debug.Enabled = l.bool("DEBUG_ENDPOINTS", false)
+	config.Debug.Addr = l.string("DEBUG_ADDR", config.Debug.Addr)
+	config.Debug.GoroutineWarn = l.int("GOROUTINE_WARN_THRESHOLD", config.Debug.GoroutineWarn, 0, 1000000)
+	config.Debug.LocalOnly = l.bool("DEBUG_LOCAL_ONLY", false)
+	config.Debug.BasicAuthUser = l.string("DEBUG_BASIC_AUTH_USER", "debug")
+	config.Debug.BasicAuthPassword = l.string("DEBUG_BASIC_AUTH_PASSWORD", "")
+
+	// Отправка ошибок
+	config.Reporting = reporting.DefaultConfig()
+	config.Reporting.DSN = l.string("SENTRY_DSN", "")
+	if config.Reporting.DSN != "" {
+		if _, err := reporting.ParseDSN(config.Reporting.DSN); err != nil {
+			l.fail(fmt.Errorf("SENTRY_DSN: %w", err))
+		}
+	}
+	config.Reporting.Environment = l.string("SENTRY_ENVIRONMENT", config.Reporting.Environment)
+	config.Reporting.MinLevel = l.oneOf("SENTRY_MIN_LEVEL", config.Reporting.MinLevel, reporting.Levels()...)
+
+	// Самопроверка зависимостей при запуске
+	config.SelfTest = selftest.DefaultConfig()
+	config.SelfTest.Mode = l.oneOf("SELFTEST_MODE", config.SelfTest.Mode, selftest.Modes()...)
+	config.SelfTest.Timeout = l.duration("SELFTEST_TIMEOUT", config.SelfTest.Timeout)
+
+	// Журнал событий для статистики
+	config.Events = events.DefaultConfig()
+	config.Events.File = filepath.Join(config.DataDir, config.Events.File)
+	config.Events.BufferSize = l.int("EVENTS_BUFFER_SIZE", config.Events.BufferSize, 10, 100000)
+
+	// Тексты /start и /help
+	config.Texts.Dir = l.string("TEXTS_DIR", "")
+
+	if len(l.errs) > 0 {
+		return Config{}, fmt.Errorf("некорректная конфигурация: %w", errors.Join(l.errs...))
+	}
+	return config, nil
+}
+
+// ai читает настройки AI
+func (l *loader) ai(dataDir string) ai.AIConfig {
+	config := ai.DefaultAIConfig()
+
+	// Общие значения относятся к постам и применяются до значений функций
+	l.params(&config.Post, "AI")
+	config.Rewrite = config.Post
+
+	l.params(&config.Post, "AI_POST")
+	l.params(&config.Rewrite, "AI_REWRITE")
+	l.params(&config.Translate, "AI_TRANSLATE")
+	l.params(&config.Analysis, "AI_ANALYSIS")
+	l.params(&config.Expand, "AI_EXPAND")
+	l.params(&config.Answer, "AI_ANSWER")
+	config.Timeout = time.Duration(l.int("AI_TIMEOUT_SECONDS", int(config.Timeout/time.Second), 5, 600)) * time.Second
+
+	config.Provider = strings.ToLower(l.string("AI_PROVIDER", config.Provider))
+	switch config.Provider {
+	case ai.ProviderYandex:
+		config.Yandex.APIKey = l.required("YANDEX_GPT_API_KEY")
+		config.Yandex.FolderID = l.required("YANDEX_FOLDER_ID")
+	case ai.ProviderOpenAI:
+		config.OpenAI.BaseURL = l.required("AI_BASE_URL")
+		config.OpenAI.Model = l.required("AI_MODEL")
+		config.OpenAI.APIKey = l.string("AI_API_KEY", "")
+	default:
+		l.fail(fmt.Errorf("AI_PROVIDER=%q: ожидается %s или %s", config.Provider, ai.ProviderYandex, ai.ProviderOpenAI))
+	}
+
+	if value := l.string("YANDEX_GPT_MODEL", ""); value != "" {
+		tier, err := ai.ParseModelTier(value)
+		if err != nil {
+			l.fail(fmt.Errorf("YANDEX_GPT_MODEL: %w", err))
+		} else {
+			config.Yandex.Model = tier
+		}
+	}
+	if value := l.string("YANDEX_GPT_CONTEXT_TOKENS", ""); value != "" {
+		sizes, err := ai.ParseContextTokens(value)
+		if err != nil {
+			l.fail(fmt.Errorf("YANDEX_GPT_CONTEXT_TOKENS: %w", err))
+		}
+		for tier, tokens := range sizes {
+			config.Yandex.ContextTokens[tier] = tokens
+		}
+	}
+	config.Yandex.Protocol = l.oneOf("YANDEX_GPT_PROTOCOL", config.Yandex.Protocol, "native", "openai")
+	config.Yandex.ArtURL = l.string("YANDEX_ART_URL", config.Yandex.ArtURL)
+	config.Yandex.OperationsURL = l.string("YANDEX_OPERATIONS_URL", config.Yandex.OperationsURL)
+	config.Yandex.EmbeddingURL = l.string("YANDEX_EMBEDDING_URL", config.Yandex.EmbeddingURL)
+	config.OpenAI.ContextTokens = l.int("AI_CONTEXT_TOKENS", config.OpenAI.ContextTokens, 1, 10_000_000)
+	config.EmbeddingModel = l.string("EMBEDDING_MODEL", "")
+
+	config.Cache.Size = l.int("AI_CACHE_SIZE", config.Cache.Size, 0, 100_000)
+	config.Cache.TTL = l.duration("AI_CACHE_TTL", config.Cache.TTL)
+	config.Cache.ChargeUser = l.bool("AI_CACHE_CHARGE_USER", config.Cache.ChargeUser)
+
+	config.Breaker.Threshold = l.int("AI_BREAKER_THRESHOLD", config.Breaker.Threshold, 1, 1000)
+	config.Breaker.Cooldown = l.duration("AI_BREAKER_COOLDOWN", config.Breaker.Cooldown)
+
+	config.Audit.Enabled = l.bool("AI_AUDIT_LOG", config.Audit.Enabled)
+	config.Audit.Dir = dataDir
+	config.Audit.MaxSizeMB = l.int("AI_AUDIT_MAX_SIZE_MB", config.Audit.MaxSizeMB, 1, 10_000)
+	config.Audit.KeepDays = l.int("AI_AUDIT_KEEP_DAYS", config.Audit.KeepDays, 1, 3650)
+	config.Audit.Responses = l.bool("AI_AUDIT_RESPONSES", config.Audit.Responses)
+
+	config.PromptsDir = l.string("PROMPTS_DIR", "")
+	config.ModerationRulesFile = l.string("MODERATION_RULES_FILE", "")
+	config.ModerationAICheck = l.bool("MODERATION_AI_CHECK", config.ModerationAICheck)
+	config.BrandSafetyFile = l.string("BRAND_SAFETY_FILE", "")
+	config.BrandSafetyMode = l.oneOf("BRAND_SAFETY_MODE", config.BrandSafetyMode,
+		ai.SafetyModeRegenerate, ai.SafetyModeWarn, ai.SafetyModeOff)
+	config.BrandSafetyAICheck = l.bool("BRAND_SAFETY_AI_CHECK", config.BrandSafetyAICheck)
+
+	return config
+}
+
+// loader читает переменные окружения и накапливает ошибки
+type loader struct {
+	errs []error
+}
+
+func (l *loader) fail(err error) {
+	l.errs = append(l.errs, err)
+}
+
+// lookup возвращает значение переменной без пробелов по краям
+func (l *loader) lookup(name string) string {
+	return strings.TrimSpace(os.Getenv(name))
+}
+
+// string возвращает значение переменной или fallback, если она не задана
+func (l *loader) string(name, fallback string) string {
+	if value := l.lookup(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// required возвращает значение обязательной переменной
+func (l *loader) required(name string) string {
+	value := l.lookup(name)
+	if value == "" {
+		l.fail(fmt.Errorf("%s не установлен", name))
+	}
+	return value
+}
+
+// bool разбирает true/false
+func (l *loader) bool(name string, fallback bool) bool {
+	value := l.lookup(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		l.fail(fmt.Errorf("%s=%q: ожидается true или false", name, value))
+		return fallback
+	}
+	return parsed
+}
+
+// int разбирает целое число в диапазоне [min, max]
+func (l *loader) int(name string, fallback, min, max int) int {
+	value := l.lookup(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < min || parsed > max {
+		l.fail(fmt.Errorf("%s=%q: ожидается целое число от %d до %d", name, value, min, max))
+		return fallback
+	}
+	return parsed
+}
+
+// int64 разбирает целое число, например ID чата
+func (l *loader) int64(name string, fallback int64) int64 {
+	value := l.lookup(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		l.fail(fmt.Errorf("%s=%q: ожидается целое число", name, value))
+		return fallback
+	}
+	return parsed
+}
+
+// float разбирает число в диапазоне [min, max]
+func (l *loader) float(name string, fallback, min, max float64) float64 {
+	value := l.lookup(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil || parsed < min || parsed > max {
+		l.fail(fmt.Errorf("%s=%q: ожидается число от %g до %g", name, value, min, max))
+		return fallback
+	}
+	return parsed
+}
+
+// duration разбирает положительную длительность вида 90s, 5m, 1h
+func (l *loader) duration(name string, fallback time.Duration) time.Duration {
+	value := l.lookup(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed <= 0 {
+		l.fail(fmt.Errorf("%s=%q: ожидается положительная длительность, например 90s или 5m", name, value))
+		return fallback
+	}
+	return parsed
+}
+
+// durationOrZero разбирает неотрицательную длительность
+func (l *loader) durationOrZero(name string, fallback time.Duration) time.Duration {
+	value := l.lookup(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		l.fail(fmt.Errorf("%s=%q: ожидается длительность, например 500ms", name, value))
+		return fallback
+	}
+	return parsed
+}
+
+// oneOf возвращает значение из списка допустимых (без учета регистра)
+func (l *loader) oneOf(name, fallback string, allowed ...string) string {
+	value := strings.ToLower(l.lookup(name))
+	if value == "" {
+		return fallback
+	}
+	for _, option := range allowed {
+		if value == option {
+			return value
+		}
+	}
+	l.fail(fmt.Errorf("%s=%q: ожидается одно из: %s", name, value, strings.Join(allowed, ", ")))
+	return fallback
+}
+
+// location загружает часовой пояс по имени из базы IANA, например Europe/Moscow
+func (l *loader) location(name, fallback string) *time.Location {
+	value := l.string(name, fallback)
+	location, err := time.LoadLocation(value)
+	if err != nil {
+		l.fail(fmt.Errorf("%s=%q: ожидается часовой пояс, например Europe/Moscow", name, value))
+		return time.Local
+	}
+	return location
+}
+
+// level разбирает уровень логирования
+func (l *loader) level(name string, fallback slog.Level) slog.Level {
+	value := l.lookup(name)
+	if value == "" {
+		return fallback
+	}
+	level, err := logging.ParseLevel(value)
+	if err != nil {
+		l.fail(fmt.Errorf("%s=%q: ожидается одно из: debug, info, warn, error", name, value))
+		return fallback
+	}
+	return level
+}
+
+// proxy разбирает адрес прокси; адрес не попадает в ошибку, в нем может быть пароль
+func (l *loader) proxy(name string) *url.URL {
+	value := l.lookup(name)
+	if value == "" {
+		return nil
+	}
+	proxyURL, err := httpx.ParseProxyURL(value)
+	if err != nil {
+		l.fail(fmt.Errorf("%s: %w", name, err))
+		return nil
+	}
+	return proxyURL
+}
+
+// params переопределяет params значениями <prefix>_TEMPERATURE и <prefix>_MAX_TOKENS
+func (l *loader) params(params *ai.GenerationParams, prefix string) {
+	params.Temperature = l.float(prefix+"_TEMPERATURE", params.Temperature, 0, 1)
+	params.MaxTokens = l.int(prefix+"_MAX_TOKENS", params.MaxTokens, 1, 8000)
+}
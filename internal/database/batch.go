@@ -0,0 +1,58 @@
+package database
+
+import "log"
+
+// Batch изменения пользователей внутри WithBatch. Все они выполняются под одной
+// блокировкой, а база сохраняется один раз после них: рассылка или обход тысяч
+// пользователей не переписывает файлы базы на каждого.
+type Batch struct {
+	db *Database
+	// changed хотя бы одно изменение действительно что-то поменяло
+	changed bool
+}
+
+// WithBatch применяет изменения fn под одной блокировкой и сохраняет базу один раз,
+// если что-то изменилось. fn не должна вызывать методы Database: блокировка уже взята.
+func (db *Database) WithBatch(fn func(tx *Batch)) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx := &Batch{db: db}
+	fn(tx)
+	if !tx.changed {
+		return nil
+	}
+	return db.save()
+}
+
+// UpdateUser изменяет существующего пользователя; fn сообщает, изменила ли она его.
+// Неизвестный пользователь не создается. Возвращает, был ли пользователь изменен.
+func (tx *Batch) UpdateUser(userID int64, fn func(user *User) bool) bool {
+	user, exists := tx.db.users[userID]
+	if !exists || !fn(user) {
+		return false
+	}
+	tx.changed = true
+	return true
+}
+
+// EachUser вызывает fn для каждого пользователя; fn сообщает, изменила ли она его
+func (tx *Batch) EachUser(fn func(userID int64, user *User) bool) {
+	for userID, user := range tx.db.users {
+		if fn(userID, user) {
+			tx.changed = true
+		}
+	}
+}
+
+// SetBlocked отмечает, что пользователь заблокировал бота или разблокировал его
+func (tx *Batch) SetBlocked(userID int64, blocked bool) bool {
+	return tx.UpdateUser(userID, func(user *User) bool {
+		if user.Blocked == blocked {
+			return false
+		}
+		user.Blocked = blocked
+		log.Printf("[DB] Пользователь %d: бот заблокирован = %v", userID, blocked)
+		return true
+	})
+}
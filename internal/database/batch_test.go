@@ -0,0 +1,105 @@
+package database
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestWithBatchSavesOnce(t *testing.T) {
+	db := newTestDatabase(t)
+	for userID := int64(1); userID <= 3; userID++ {
+		db.users[userID] = &User{UserID: userID}
+	}
+	saves := func() int { return db.Counts()["saves"] }
+
+	before := saves()
+	if err := db.WithBatch(func(tx *Batch) {
+		for userID := int64(1); userID <= 3; userID++ {
+			tx.SetBlocked(userID, true)
+		}
+		// Неизвестный пользователь не заводится
+		tx.SetBlocked(4, true)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got := saves() - before; got != 1 {
+		t.Errorf("сохранений за пакет: %d", got)
+	}
+	if !db.IsBlocked(1) || !db.IsBlocked(3) || db.HasUser(4) {
+		t.Error("изменения пакета не применены")
+	}
+
+	// Пакет без изменений базу не переписывает
+	before = saves()
+	if err := db.WithBatch(func(tx *Batch) { tx.SetBlocked(1, true) }); err != nil {
+		t.Fatal(err)
+	}
+	if got := saves() - before; got != 0 {
+		t.Errorf("сохранений за пакет без изменений: %d", got)
+	}
+
+	// Изменения пакета записаны на диск
+	reopened := NewDatabase(Config{File: "users.json"})
+	if err := reopened.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !reopened.IsBlocked(2) {
+		t.Error("после перезапуска отметка потеряна")
+	}
+}
+
+func TestExpirePremiumSavesOnce(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	for userID := int64(1); userID <= 100; userID++ {
+		db.users[userID] = &User{UserID: userID, PremiumUntil: now.Add(time.Duration(userID-50) * time.Hour)}
+	}
+
+	before := db.Counts()["saves"]
+	expired, err := db.ExpirePremium(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 50 {
+		t.Errorf("премиум снят у %d, ожидалось 50", len(expired))
+	}
+	if got := db.Counts()["saves"] - before; got != 1 {
+		t.Errorf("сохранений за обход: %d", got)
+	}
+}
+
+// BenchmarkBatchSweep обход 5000 пользователей, как после рассылки: каждый обход
+// меняет всех пользователей и записывает базу один раз, а не на каждого
+func BenchmarkBatchSweep(b *testing.B) {
+	const users = 5000
+	b.Chdir(b.TempDir())
+	saved := log.Writer()
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(saved) })
+
+	db := NewDatabase(Config{File: "users.json"})
+	for userID := int64(1); userID <= users; userID++ {
+		db.users[userID] = &User{UserID: userID}
+	}
+
+	before := db.Counts()["saves"]
+	blocked := true
+	for b.Loop() {
+		if err := db.WithBatch(func(tx *Batch) {
+			for userID := int64(1); userID <= users; userID++ {
+				tx.SetBlocked(userID, blocked)
+			}
+		}); err != nil {
+			b.Fatal(err)
+		}
+		blocked = !blocked
+	}
+
+	saves := db.Counts()["saves"] - before
+	if saves != b.N {
+		b.Fatalf("за %d обходов %d пользователей база записана %d раз", b.N, users, saves)
+	}
+	b.ReportMetric(float64(saves)/float64(b.N), "saves/op")
+}
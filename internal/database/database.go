@@ -5,21 +5,96 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
+
+	"AIGenerator/internal/calendar"
 )
 
 type User struct {
-	UserID               int64     `json:"user_id"`
-	Username             string    `json:"username"`
-	AvailableGenerations int       `json:"available_generations"`
-	TotalGenerations     int       `json:"total_generations"`
+	UserID               int64  `json:"user_id"`
+	Username             string `json:"username"`
+	AvailableGenerations int    `json:"available_generations"`
+	TotalGenerations     int    `json:"total_generations"`
+	// ReservedGenerations генерации, зарезервированные под идущие генерации и посты
+	// в предпросмотре: уже не доступны, но еще не списаны
+	ReservedGenerations  int       `json:"reserved_generations,omitempty"`
 	CreatedAt            time.Time `json:"created_at"`
 	LastGenerate         time.Time `json:"last_generate"`
 	PendingFeedback      bool      `json:"pending_feedback,omitempty"`
 	GenerationsCount     int       `json:"generations_count,omitempty"`
 	LastFeedbackReminder time.Time `json:"last_feedback_reminder,omitempty"`
+	PendingRewrite       bool      `json:"pending_rewrite,omitempty"`
+	Settings             Settings  `json:"settings"`
+	// PartialGeneration накопленная доля генерации от дешевых операций (0–1)
+	PartialGeneration float64 `json:"partial_generation,omitempty"`
+	// PremiumUntil до какого момента действует премиум; нулевое — премиума нет
+	PremiumUntil time.Time `json:"premium_until,omitempty"`
+	// TrialGenerations сколько бесплатных генераций пользователь получил при создании
+	TrialGenerations int `json:"trial_generations,omitempty"`
+	// Blocked пользователь заблокировал бота: Telegram не принимает для него сообщения.
+	// Сбрасывается, когда пользователь снова пишет боту.
+	Blocked bool `json:"blocked,omitempty"`
+}
+
+const (
+	// DefaultFreeTrialGenerations сколько бесплатных генераций получает новый пользователь,
+	// если FREE_TRIAL_GENERATIONS не задана
+	DefaultFreeTrialGenerations = 10
+	// PremiumPackage пакет, покупка которого дает премиум
+	PremiumPackage = "100"
+	// PremiumPeriod на сколько дается премиум за покупку пакета PremiumPackage
+	PremiumPeriod = 30 * 24 * time.Hour
+)
+
+// IsPremium сообщает, действует ли премиум в момент now
+func (u *User) IsPremium(now time.Time) bool {
+	return u.PremiumUntil.After(now)
+}
+
+// Settings пользовательские настройки генерации, меняются командой /settings
+type Settings struct {
+	// GenerateImages рисовать иллюстрацию, если у новости нет картинки
+	GenerateImages bool `json:"generate_images,omitempty"`
+	// Language код языка постов; пустой — язык по умолчанию
+	Language string `json:"language,omitempty"`
+	// SmartSelection выбирать новость с помощью AI среди лучших кандидатов
+	SmartSelection bool `json:"smart_selection,omitempty"`
+	// InterfaceLanguage код языка сообщений бота, меняется командой /language;
+	// пустой — язык еще не выбран
+	InterfaceLanguage string `json:"interface_language,omitempty"`
+	// Citation как указывать источник поста: CitationMetadata, CitationInline или CitationNone;
+	// пустой — CitationMetadata
+	Citation string `json:"citation,omitempty"`
+	// WeeklySummaryOff не присылать еженедельную сводку активности
+	WeeklySummaryOff bool `json:"weekly_summary_off,omitempty"`
+	// PreviewBeforeCharge сначала показывать часть поста и списывать генерацию, только
+	// когда пользователь заберет пост
+	PreviewBeforeCharge bool `json:"preview_before_charge,omitempty"`
+}
+
+// Способы указать источник поста
+const (
+	// CitationMetadata ссылка в отдельном сообщении с метаданными
+	CitationMetadata = "metadata"
+	// CitationInline ссылка «Источник» последней строкой поста
+	CitationInline = "inline"
+	// CitationNone источник не указывается
+	CitationNone = "none"
+)
+
+// CitationMode способ указать источник с учетом значения по умолчанию
+func (s Settings) CitationMode() string {
+	switch s.Citation {
+	case CitationInline, CitationNone:
+		return s.Citation
+	default:
+		return CitationMetadata
+	}
 }
 
 type Purchase struct {
@@ -27,15 +102,89 @@ type Purchase struct {
 	UserID      int64     `json:"user_id"`
 	PackageType string    `json:"package_type"`
 	Price       int       `json:"price"`
-	Status      string    `json:"status"` // pending, succeeded, canceled
+	Status      string    `json:"status"` // pending, succeeded, canceled, expired
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+	// NextCheck когда проверить статус ожидающего платежа в ЮKassa, Checks — сколько раз
+	// он уже проверялся, Reminded — напомнил ли бот о неоплаченном платеже
+	NextCheck time.Time `json:"next_check,omitempty"`
+	Checks    int       `json:"checks,omitempty"`
+	Reminded  bool      `json:"reminded,omitempty"`
 }
 
 type Generation struct {
 	UserID    int64     `json:"user_id"`
 	Keywords  string    `json:"keywords"`
+	Source    string    `json:"source,omitempty"`
+	Outcome   string    `json:"outcome,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
 	Timestamp time.Time `json:"timestamp"`
+	// RequestID код запроса генерации из сообщений пользователю и лога
+	RequestID string `json:"request_id,omitempty"`
+	// DurationMs длительность генерации от постановки в очередь до отправки поста
+	DurationMs int64 `json:"duration_ms,omitempty"`
+	// Experiment и Variant эксперимент с промптами и вариант, которым написан пост
+	Experiment string `json:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+}
+
+// Исходы генерации в журнале генераций
+const (
+	OutcomeSuccess  = "success"
+	OutcomeRejected = "rejected"
+	// OutcomeRewrite пост из текста пользователя (/rewrite)
+	OutcomeRewrite = "rewrite"
+	// OutcomeDryRun тестовая генерация администратора (/testgen): баланс не списывался,
+	// в статистике генераций не учитывается
+	OutcomeDryRun = "dry_run"
+	// OutcomeDeclined пользователь отказался от поста в предпросмотре, генерация не списана
+	OutcomeDeclined = "declined"
+	// OutcomePreviewExpired пост в предпросмотре не забрали вовремя, генерация не списана
+	OutcomePreviewExpired = "preview_expired"
+	// OutcomeRefused модель отказалась писать пост
+	OutcomeRefused = "refused"
+)
+
+// Succeeded сообщает, что генерация завершилась постом.
+// У старых записей исход не заполнен: тогда в журнал попадали только успешные генерации.
+func (g Generation) Succeeded() bool {
+	return g.Outcome == "" || g.Outcome == OutcomeSuccess || g.Outcome == OutcomeRewrite
+}
+
+// Rating оценка пользователем сгенерированного поста
+type Rating struct {
+	UserID    int64     `json:"user_id"`
+	Rating    int       `json:"rating"`
+	Topic     string    `json:"topic"`
+	Source    string    `json:"source,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Experiment и Variant эксперимент и вариант оцененного поста
+	Experiment string `json:"experiment,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+}
+
+const (
+	// minSourceWeight и maxSourceWeight границы веса источника
+	minSourceWeight = 0.5
+	maxSourceWeight = 1.5
+	// sourceWeightAlpha коэффициент сглаживания скользящего среднего оценок
+	sourceWeightAlpha = 0.2
+)
+
+// reportsFile отметки об отправленных администратору отчетах
+const reportsFile = "reports.json"
+
+// updatesFile последнее обработанное обновление Telegram
+const updatesFile = "updates.json"
+
+// Config настройки базы данных
+type Config struct {
+	// File файл пользователей
+	File string
+	// StatisticsPassword пароль админских команд
+	StatisticsPassword string
+	// FreeTrialGenerations сколько бесплатных генераций получает новый пользователь
+	FreeTrialGenerations int
 }
 
 type Database struct {
@@ -43,17 +192,50 @@ type Database struct {
 	purchases        []Purchase
 	pendingPurchases map[string]*Purchase
 	generations      []Generation
-	file             string
-	mu               sync.RWMutex
+	ratings          []Rating
+	sourceWeights    map[string]float64
+	// ledger ручные изменения баланса администратором
+	ledger []BalanceAdjustment
+	// reports день последней отправки отчета администратору по виду отчета
+	reports       map[string]string
+	file          string
+	statsPassword string
+	mu            sync.RWMutex
+
+	// lastUpdateID последнее принятое обновление Telegram; offsetDirty — оно еще не сохранено.
+	// Защищены offsetMu: смещение меняется на каждое обновление и не должно ждать mu.
+	lastUpdateID int
+	offsetDirty  bool
+	offsetMu     sync.Mutex
+
+	// outbox очередь уведомлений администратору, защищена outboxMu
+	outbox   outboxState
+	outboxMu sync.Mutex
+
+	// experiment последний эксперимент с промптами, защищен mu
+	experiment Experiment
+	// saves сколько раз база записана на диск с запуска, защищен mu
+	saves int
+
+	// freeTrial бесплатные генерации нового пользователя
+	freeTrial int
+	// migrations примененные миграции данных: имя и время применения
+	migrations map[string]time.Time
 }
 
-func NewDatabase(filename string) *Database {
+func NewDatabase(config Config) *Database {
 	db := &Database{
 		users:            make(map[int64]*User),
 		purchases:        make([]Purchase, 0),
 		pendingPurchases: make(map[string]*Purchase),
 		generations:      make([]Generation, 0),
-		file:             filename,
+		ratings:          make([]Rating, 0),
+		ledger:           make([]BalanceAdjustment, 0),
+		sourceWeights:    make(map[string]float64),
+		reports:          make(map[string]string),
+		file:             config.File,
+		statsPassword:    config.StatisticsPassword,
+		freeTrial:        config.FreeTrialGenerations,
 	}
 
 	// Загружаем ожидающие покупки при создании
@@ -62,10 +244,104 @@ func NewDatabase(filename string) *Database {
 	return db
 }
 
+// CheckWritable проверяет, что в каталог базы можно записать файл
+func (db *Database) CheckWritable() error {
+	probe, err := os.CreateTemp(filepath.Dir(db.file), ".write-check-*")
+	if err != nil {
+		return fmt.Errorf("каталог базы недоступен для записи: %w", err)
+	}
+	name := probe.Name()
+	_, err = probe.WriteString("ok")
+	probe.Close()
+	os.Remove(name)
+	if err != nil {
+		return fmt.Errorf("ошибка записи в каталог базы: %w", err)
+	}
+	return nil
+}
+
+// CheckRoundTrip записывает в каталог базы пробный файл и читает его обратно:
+// находит не только запрет записи, но и переполненный или испорченный диск
+func (db *Database) CheckRoundTrip() error {
+	probe, err := os.CreateTemp(filepath.Dir(db.file), ".roundtrip-check-*")
+	if err != nil {
+		return fmt.Errorf("каталог базы недоступен для записи: %w", err)
+	}
+	name := probe.Name()
+	defer os.Remove(name)
+
+	want := fmt.Sprintf("roundtrip %d", time.Now().UnixNano())
+	_, err = probe.WriteString(want)
+	if closeErr := probe.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка записи в каталог базы: %w", err)
+	}
+
+	got, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("ошибка чтения из каталога базы: %w", err)
+	}
+	if string(got) != want {
+		return fmt.Errorf("прочитано не то, что записано")
+	}
+	return nil
+}
+
+// Close сохраняет все данные на диск. Вызывается при завершении, после того как
+// обработчики остановлены: записи, начатые до этого, уже учтены под mu.
+func (db *Database) Close() error {
+	if err := db.FlushUpdateOffset(); err != nil {
+		log.Printf("[DB] ❌ Ошибка сохранения смещения обновлений: %v", err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.save()
+}
+
+// Counts возвращает размеры коллекций базы для диагностики
+func (db *Database) Counts() map[string]int {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return map[string]int{
+		"users":             len(db.users),
+		"purchases":         len(db.purchases),
+		"pending_purchases": len(db.pendingPurchases),
+		"generations":       len(db.generations),
+		"ratings":           len(db.ratings),
+		"outbox_pending":    db.PendingOutbox(),
+		"saves":             db.saves,
+	}
+}
+
+// Load читает базу из файлов и применяет к ней миграции данных
 func (db *Database) Load() error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
+	if err := db.loadFiles(); err != nil {
+		return err
+	}
+	if err := db.releaseReservations(); err != nil {
+		return err
+	}
+	return db.migrate()
+}
+
+// loadFiles читает файлы базы. Вызывается под mu.
+func (db *Database) loadFiles() error {
+	// Отметки об отчетах не зависят от пользователей: без них после перезапуска
+	// отчет ушел бы повторно
+	reportData, err := os.ReadFile(reportsFile)
+	if err == nil && len(reportData) > 0 {
+		json.Unmarshal(reportData, &db.reports)
+	}
+	db.loadUpdateOffset()
+	db.loadOutbox()
+	db.loadExperiment()
+
 	data, err := os.ReadFile(db.file)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -94,6 +370,23 @@ func (db *Database) Load() error {
 		json.Unmarshal(generationData, &db.generations)
 	}
 
+	// Загружаем журнал ручных изменений баланса
+	ledgerData, err := os.ReadFile(ledgerFile)
+	if err == nil && len(ledgerData) > 0 {
+		json.Unmarshal(ledgerData, &db.ledger)
+	}
+
+	// Загружаем оценки и веса источников
+	ratingData, err := os.ReadFile("ratings.json")
+	if err == nil && len(ratingData) > 0 {
+		json.Unmarshal(ratingData, &db.ratings)
+	}
+
+	weightData, err := os.ReadFile("source_weights.json")
+	if err == nil && len(weightData) > 0 {
+		json.Unmarshal(weightData, &db.sourceWeights)
+	}
+
 	return nil
 }
 
@@ -121,6 +414,8 @@ func (db *Database) loadPendingPurchases() error {
 }
 
 func (db *Database) save() error {
+	db.saves++
+
 	// Сохраняем пользователей
 	userData, err := json.MarshalIndent(db.users, "", "  ")
 	if err != nil {
@@ -163,6 +458,41 @@ func (db *Database) save() error {
 		return fmt.Errorf("ошибка записи файла истории генераций: %w", err)
 	}
 
+	// Сохраняем журнал ручных изменений баланса
+	ledgerData, err := json.MarshalIndent(db.ledger, "", "  ")
+	if err != nil {
+		log.Printf("[DB] ❌ Ошибка маршалинга журнала баланса: %v", err)
+		return fmt.Errorf("ошибка маршалинга журнала баланса: %w", err)
+	}
+
+	if err := os.WriteFile(ledgerFile, ledgerData, 0644); err != nil {
+		log.Printf("[DB] ❌ Ошибка записи файла журнала баланса: %v", err)
+		return fmt.Errorf("ошибка записи файла журнала баланса: %w", err)
+	}
+
+	// Сохраняем оценки и веса источников
+	ratingData, err := json.MarshalIndent(db.ratings, "", "  ")
+	if err != nil {
+		log.Printf("[DB] ❌ Ошибка маршалинга оценок: %v", err)
+		return fmt.Errorf("ошибка маршалинга оценок: %w", err)
+	}
+
+	if err := os.WriteFile("ratings.json", ratingData, 0644); err != nil {
+		log.Printf("[DB] ❌ Ошибка записи файла оценок: %v", err)
+		return fmt.Errorf("ошибка записи файла оценок: %w", err)
+	}
+
+	weightData, err := json.MarshalIndent(db.sourceWeights, "", "  ")
+	if err != nil {
+		log.Printf("[DB] ❌ Ошибка маршалинга весов источников: %v", err)
+		return fmt.Errorf("ошибка маршалинга весов источников: %w", err)
+	}
+
+	if err := os.WriteFile("source_weights.json", weightData, 0644); err != nil {
+		log.Printf("[DB] ❌ Ошибка записи файла весов источников: %v", err)
+		return fmt.Errorf("ошибка записи файла весов источников: %w", err)
+	}
+
 	// Сохраняем ожидающие покупки
 	if err := db.savePendingPurchases(); err != nil {
 		return err
@@ -205,6 +535,9 @@ func (db *Database) GetPendingPurchase(paymentID string) *Purchase {
 	return db.pendingPurchases[paymentID]
 }
 
+// UpdatePurchaseStatus записывает окончательный статус платежа. Успешный переносится
+// в историю покупок; отмененный в ЮKassa и просроченный больше не отслеживаются
+// и удаляются из ожидающих.
 func (db *Database) UpdatePurchaseStatus(paymentID, status string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
@@ -217,10 +550,14 @@ func (db *Database) UpdatePurchaseStatus(paymentID, status string) error {
 	purchase.Status = status
 	purchase.UpdatedAt = time.Now()
 
-	// Если покупка завершена успешно, перемещаем ее в основную историю
-	if status == "succeeded" {
+	switch status {
+	case "succeeded":
+		// Если покупка завершена успешно, перемещаем ее в основную историю
 		db.purchases = append(db.purchases, *purchase)
 		delete(db.pendingPurchases, paymentID)
+	case "canceled", "expired":
+		delete(db.pendingPurchases, paymentID)
+		log.Printf("[DB] Платеж %s пользователя %d больше не отслеживается: %s", paymentID, purchase.UserID, status)
 	}
 
 	// Сохраняем оба файла
@@ -244,17 +581,142 @@ func (db *Database) GetUserPurchases(userID int64) []*Purchase {
 	return userPurchases
 }
 
-func (db *Database) AddGeneration(userID int64, keywords string) {
+func (db *Database) AddGeneration(userID int64, keywords, source, requestID string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.generations = append(db.generations, Generation{
+		UserID:    userID,
+		Keywords:  keywords,
+		Source:    source,
+		Outcome:   OutcomeSuccess,
+		Timestamp: time.Now(),
+		RequestID: requestID,
+	})
+}
+
+// AddGenerationOutcome записывает в журнал генерацию с указанным исходом
+func (db *Database) AddGenerationOutcome(userID int64, keywords, outcome, reason, requestID string) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
 	db.generations = append(db.generations, Generation{
 		UserID:    userID,
 		Keywords:  keywords,
+		Outcome:   outcome,
+		Reason:    reason,
 		Timestamp: time.Now(),
+		RequestID: requestID,
 	})
 }
 
+// SetGenerationDuration записывает длительность генерации с кодом запроса requestID.
+// Запись в журнал появляется раньше, чем пост доставлен, поэтому длительность
+// дописывается после завершения генерации.
+func (db *Database) SetGenerationDuration(requestID string, duration time.Duration) {
+	if requestID == "" {
+		return
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i := len(db.generations) - 1; i >= 0; i-- {
+		if db.generations[i].RequestID == requestID {
+			db.generations[i].DurationMs = duration.Milliseconds()
+			return
+		}
+	}
+}
+
+// newUser создает пользователя с пробными генерациями, не добавляя его в базу
+func (db *Database) newUser(userID int64) *User {
+	return &User{
+		UserID:               userID,
+		AvailableGenerations: db.freeTrial,
+		TrialGenerations:     db.freeTrial,
+		CreatedAt:            time.Now(),
+	}
+}
+
+// userForUpdate возвращает пользователя для изменения, создавая его при первом действии.
+// Вызывается под mu.
+func (db *Database) userForUpdate(userID int64) *User {
+	user, exists := db.users[userID]
+	if !exists {
+		log.Printf("[DB] Создаю нового пользователя %d", userID)
+		user = db.newUser(userID)
+		db.users[userID] = user
+	}
+	return user
+}
+
+// FreeTrialGenerations сколько бесплатных генераций получает новый пользователь
+func (db *Database) FreeTrialGenerations() int {
+	return db.freeTrial
+}
+
+// lastGeneration возвращает последнюю успешную генерацию пользователя
+func (db *Database) lastGeneration(userID int64) (Generation, bool) {
+	for i := len(db.generations) - 1; i >= 0; i-- {
+		if db.generations[i].UserID == userID && db.generations[i].Succeeded() {
+			return db.generations[i], true
+		}
+	}
+	return Generation{}, false
+}
+
+// AddRating сохраняет оценку поста и обновляет вес источника новости,
+// на основе которой был сгенерирован последний пост пользователя.
+// Оценка относится к эксперименту и варианту этого поста.
+// Возвращает источник и его новый вес (пустой источник, если он неизвестен).
+func (db *Database) AddRating(userID int64, rating int, topic string) (string, float64, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	last, _ := db.lastGeneration(userID)
+	source := last.Source
+	db.ratings = append(db.ratings, Rating{
+		UserID:     userID,
+		Rating:     rating,
+		Topic:      topic,
+		Source:     source,
+		Timestamp:  time.Now(),
+		Experiment: last.Experiment,
+		Variant:    last.Variant,
+	})
+
+	var weight float64
+	if source != "" {
+		// Оценка 1..5 переводится в целевой вес 0.5..1.5
+		target := minSourceWeight + float64(rating-1)*(maxSourceWeight-minSourceWeight)/4
+		current, exists := db.sourceWeights[source]
+		if !exists {
+			current = 1.0
+		}
+		weight = (1-sourceWeightAlpha)*current + sourceWeightAlpha*target
+		weight = max(minSourceWeight, min(maxSourceWeight, weight))
+		db.sourceWeights[source] = weight
+		log.Printf("[DB] Вес источника %s: %.3f → %.3f (оценка %d)", source, current, weight, rating)
+	}
+
+	if err := db.save(); err != nil {
+		return source, weight, err
+	}
+	return source, weight, nil
+}
+
+// GetSourceWeights возвращает веса источников, рассчитанные по оценкам
+func (db *Database) GetSourceWeights() map[string]float64 {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	weights := make(map[string]float64, len(db.sourceWeights))
+	for source, weight := range db.sourceWeights {
+		weights[source] = weight
+	}
+	return weights
+}
+
 func (db *Database) GetUser(userID int64) *User {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
@@ -264,23 +726,24 @@ func (db *Database) GetUser(userID int64) *User {
 			UserID:               user.UserID,
 			Username:             user.Username,
 			AvailableGenerations: user.AvailableGenerations,
+			ReservedGenerations:  user.ReservedGenerations,
 			TotalGenerations:     user.TotalGenerations,
 			CreatedAt:            user.CreatedAt,
 			LastGenerate:         user.LastGenerate,
 			PendingFeedback:      user.PendingFeedback,
 			GenerationsCount:     user.GenerationsCount,
 			LastFeedbackReminder: user.LastFeedbackReminder,
+			PendingRewrite:       user.PendingRewrite,
+			Settings:             user.Settings,
+			PartialGeneration:    user.PartialGeneration,
+			PremiumUntil:         user.PremiumUntil,
+			TrialGenerations:     user.TrialGenerations,
+			Blocked:              user.Blocked,
 		}
 	}
 
 	// Возвращаем нового пользователя, но не сохраняем его в базу до первого действия
-	return &User{
-		UserID:               userID,
-		AvailableGenerations: 10,
-		TotalGenerations:     0,
-		CreatedAt:            time.Now(),
-		GenerationsCount:     0,
-	}
+	return db.newUser(userID)
 }
 
 func (db *Database) GetAllUsers() []int64 {
@@ -302,45 +765,36 @@ func (db *Database) UpdateUser(user *User) error {
 	return db.save()
 }
 
-func (db *Database) UseGeneration(userID int64) (bool, error) {
+// UseGenerationFraction списывает долю генерации (0–1). Доли накапливаются, и целая
+// генерация снимается с баланса, когда их сумма доходит до единицы. Для списания нужна
+// хотя бы одна доступная генерация.
+func (db *Database) UseGenerationFraction(userID int64, fraction float64) (bool, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	log.Printf("[DB] UseGeneration для пользователя %d", userID)
-
-	user, exists := db.users[userID]
-	if !exists {
-		log.Printf("[DB] Создаю нового пользователя %d", userID)
-		user = &User{
-			UserID:               userID,
-			AvailableGenerations: 10,
-			TotalGenerations:     0,
-			CreatedAt:            time.Now(),
-			GenerationsCount:     0,
-		}
-		db.users[userID] = user
-	}
-
-	log.Printf("[DB] Пользователь %d: доступно %d генераций", userID, user.AvailableGenerations)
+	user := db.userForUpdate(userID)
 
 	if user.AvailableGenerations <= 0 {
 		log.Printf("[DB] У пользователя %d нет доступных генераций", userID)
 		return false, nil
 	}
 
-	user.AvailableGenerations--
-	user.TotalGenerations++
+	user.PartialGeneration += fraction
+	// Погрешность сложения дробей не должна откладывать списание: 0.1 * 10 < 1
+	if user.PartialGeneration >= 1-1e-9 {
+		user.PartialGeneration = max(0, user.PartialGeneration-1)
+		user.AvailableGenerations--
+		user.TotalGenerations++
+	}
 	user.LastGenerate = time.Now()
 
-	log.Printf("[DB] После списания: доступно %d, всего использовано %d",
-		user.AvailableGenerations, user.TotalGenerations)
+	log.Printf("[DB] Списано %.2f генерации у %d: доступно %d, накоплено %.2f",
+		fraction, userID, user.AvailableGenerations, user.PartialGeneration)
 
 	if err := db.save(); err != nil {
 		log.Printf("[DB] ❌ Ошибка сохранения: %v", err)
 		return false, err
 	}
-
-	log.Printf("[DB] ✅ Генерация успешно использована для пользователя %d", userID)
 	return true, nil
 }
 
@@ -374,22 +828,63 @@ func (db *Database) SetPendingFeedback(userID int64, pending bool) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	user, exists := db.users[userID]
-	if !exists {
-		user = &User{
-			UserID:               userID,
-			AvailableGenerations: 10,
-			TotalGenerations:     0,
-			CreatedAt:            time.Now(),
-			GenerationsCount:     0,
-		}
-		db.users[userID] = user
-	}
+	user := db.userForUpdate(userID)
 
 	user.PendingFeedback = pending
+	if pending {
+		user.PendingRewrite = false
+	}
 	db.save()
 }
 
+// SetPendingRewrite отмечает, что бот ждет от пользователя текст для /rewrite.
+// Ожидание текста и ожидание отзыва взаимоисключающие.
+func (db *Database) SetPendingRewrite(userID int64, pending bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := db.userForUpdate(userID)
+
+	user.PendingRewrite = pending
+	if pending {
+		user.PendingFeedback = false
+	}
+	db.save()
+}
+
+// IsUserPendingRewrite сообщает, что бот ждет от пользователя текст для /rewrite
+func (db *Database) IsUserPendingRewrite(userID int64) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if user, exists := db.users[userID]; exists {
+		return user.PendingRewrite
+	}
+	return false
+}
+
+// GetSettings возвращает настройки пользователя
+func (db *Database) GetSettings(userID int64) Settings {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if user, exists := db.users[userID]; exists {
+		return user.Settings
+	}
+	return Settings{}
+}
+
+// UpdateSettings изменяет настройки пользователя функцией update и сохраняет их
+func (db *Database) UpdateSettings(userID int64, update func(*Settings)) (Settings, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := db.userForUpdate(userID)
+
+	update(&user.Settings)
+	return user.Settings, db.save()
+}
+
 func (db *Database) IsUserPendingFeedback(userID int64) bool {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
@@ -407,7 +902,7 @@ func (db *Database) ShouldRemindFeedback(userID int64) bool {
 	defer db.mu.RUnlock()
 
 	user, exists := db.users[userID]
-	if !exists {
+	if !exists || user.IsPremium(time.Now()) {
 		return false
 	}
 
@@ -441,19 +936,24 @@ func (db *Database) AddPurchase(userID int64, packageType string, price int) err
 		UpdatedAt:   time.Now(),
 	})
 
-	// Получаем или создаем пользователя
-	user, exists := db.users[userID]
-	if !exists {
-		user = &User{
-			UserID:               userID,
-			AvailableGenerations: 10,
-			TotalGenerations:     0,
-			CreatedAt:            time.Now(),
-			GenerationsCount:     0,
-		}
-		db.users[userID] = user
+	db.creditPackage(userID, packageType)
+
+	// Сохраняем изменения
+	if err := db.save(); err != nil {
+		log.Printf("[DB] ❌ Ошибка сохранения покупки: %v", err)
+		return err
 	}
 
+	log.Printf("[DB] ✅ Покупка успешно добавлена для пользователя %d", userID)
+	return nil
+}
+
+// creditPackage начисляет пользователю генерации пакета и премиум за пакет PremiumPackage.
+// Вызывается под mu.
+func (db *Database) creditPackage(userID int64, packageType string) {
+	// Получаем или создаем пользователя
+	user := db.userForUpdate(userID)
+
 	// Добавляем генерации в зависимости от пакета
 	var generations int
 	switch packageType {
@@ -471,46 +971,154 @@ func (db *Database) AddPurchase(userID int64, packageType string, price int) err
 	log.Printf("[DB] Пользователю %d добавлено %d генераций, теперь доступно %d",
 		userID, generations, user.AvailableGenerations)
 
-	// Сохраняем изменения
+	if packageType == PremiumPackage {
+		user.PremiumUntil = extendPremium(user.PremiumUntil, PremiumPeriod)
+		log.Printf("[DB] Пользователю %d выдан премиум до %s", userID, user.PremiumUntil.Format("02.01.2006 15:04"))
+	}
+}
+
+// CompletePendingPurchase зачисляет оплаченный платеж из ожидающих и переносит его
+// в историю покупок. Повторный вызов для того же платежа ничего не делает и возвращает
+// false: платеж могут одновременно подтвердить кнопка и фоновая проверка.
+func (db *Database) CompletePendingPurchase(paymentID string) (Purchase, bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	pending, exists := db.pendingPurchases[paymentID]
+	if !exists || pending.Status == "succeeded" {
+		return Purchase{}, false, nil
+	}
+	// Покупка уже записана, но бот остановился до сохранения ожидающих платежей
+	for _, purchase := range db.purchases {
+		if purchase.PaymentID == paymentID {
+			delete(db.pendingPurchases, paymentID)
+			return Purchase{}, false, db.savePendingPurchases()
+		}
+	}
+
+	// Платеж, отмененный в боте, но оплаченный в ЮKassa, тоже зачисляется: деньги списаны
+	purchase := *pending
+	purchase.PackageType = strings.TrimPrefix(purchase.PackageType, "buy_")
+	purchase.Status = "succeeded"
+	purchase.UpdatedAt = time.Now()
+	purchase.NextCheck = time.Time{}
+
+	db.creditPackage(purchase.UserID, purchase.PackageType)
+	db.purchases = append(db.purchases, purchase)
+	delete(db.pendingPurchases, paymentID)
+
+	// Сначала пользователи и покупки: если бот остановится между записями,
+	// платеж останется ожидающим, но повторно зачислен не будет
 	if err := db.save(); err != nil {
 		log.Printf("[DB] ❌ Ошибка сохранения покупки: %v", err)
-		return err
+		return purchase, true, err
 	}
-
-	log.Printf("[DB] ✅ Покупка успешно добавлена для пользователя %d", userID)
-	return nil
+	if err := db.savePendingPurchases(); err != nil {
+		log.Printf("[DB] ❌ Ошибка сохранения ожидающих платежей: %v", err)
+		return purchase, true, err
+	}
+	log.Printf("[DB] ✅ Платеж %s зачислен пользователю %d", paymentID, purchase.UserID)
+	return purchase, true, nil
 }
 
-func (db *Database) AddGenerations(userID int64, count int) error {
+// CancelPendingPurchase отмечает платеж отмененным пользователем в боте. В ЮKassa
+// платеж при этом не отменяется, поэтому он проверяется дальше, пока ЮKassa его
+// не завершит: оплаченный все равно зачисляется, деньги списаны.
+func (db *Database) CancelPendingPurchase(paymentID string) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	log.Printf("[DB] Добавление %d генераций пользователю %d", count, userID)
-
-	user, exists := db.users[userID]
+	purchase, exists := db.pendingPurchases[paymentID]
 	if !exists {
-		// Создаем нового пользователя, если его нет
-		user = &User{
-			UserID:               userID,
-			AvailableGenerations: 10 + count, // 10 бесплатных + добавленные
-			TotalGenerations:     0,
-			CreatedAt:            time.Now(),
-			GenerationsCount:     0,
+		return fmt.Errorf("покупка не найдена")
+	}
+	purchase.Status = "canceled"
+	purchase.UpdatedAt = time.Now()
+	return db.savePendingPurchases()
+}
+
+// DuePendingPurchases возвращает копии ожидающих платежей, время проверки которых наступило.
+// Отмененные в боте тоже проверяются: ЮKassa еще может их провести.
+func (db *Database) DuePendingPurchases(now time.Time) []Purchase {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var due []Purchase
+	for _, purchase := range db.pendingPurchases {
+		if (purchase.Status == "pending" || purchase.Status == "canceled") && !purchase.NextCheck.After(now) {
+			due = append(due, *purchase)
 		}
-		db.users[userID] = user
-	} else {
-		user.AvailableGenerations += count
 	}
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+	return due
+}
+
+// SchedulePurchaseCheck откладывает следующую проверку ожидающего платежа до next
+// и отмечает напоминание пользователю
+func (db *Database) SchedulePurchaseCheck(paymentID string, next time.Time, reminded bool) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	purchase, exists := db.pendingPurchases[paymentID]
+	if !exists {
+		return nil
+	}
+	purchase.Checks++
+	purchase.NextCheck = next
+	purchase.Reminded = purchase.Reminded || reminded
+	return db.savePendingPurchases()
+}
 
-	log.Printf("[DB] Теперь у пользователя %d доступно %d генераций",
-		userID, user.AvailableGenerations)
+// extendPremium продлевает премиум на period от его окончания или от текущего момента,
+// если премиум уже закончился
+func extendPremium(until time.Time, period time.Duration) time.Time {
+	if now := time.Now(); until.Before(now) {
+		until = now
+	}
+	return until.Add(period)
+}
 
+// IsPremium сообщает, действует ли премиум у пользователя
+func (db *Database) IsPremium(userID int64) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	user, exists := db.users[userID]
+	return exists && user.IsPremium(time.Now())
+}
+
+// SetPremium продлевает премиум пользователя на days дней, создавая пользователя при
+// необходимости; days = 0 отменяет премиум. Возвращает новую дату окончания.
+func (db *Database) SetPremium(userID int64, days int) (time.Time, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := db.userForUpdate(userID)
+
+	if days == 0 {
+		user.PremiumUntil = time.Time{}
+	} else {
+		user.PremiumUntil = extendPremium(user.PremiumUntil, time.Duration(days)*24*time.Hour)
+	}
 	if err := db.save(); err != nil {
-		log.Printf("[DB] ❌ Ошибка сохранения: %v", err)
-		return err
+		return time.Time{}, err
 	}
+	return user.PremiumUntil, nil
+}
 
-	return nil
+// ExpirePremium снимает премиум, закончившийся к now, и возвращает этих пользователей
+func (db *Database) ExpirePremium(now time.Time) ([]int64, error) {
+	var expired []int64
+	err := db.WithBatch(func(tx *Batch) {
+		tx.EachUser(func(userID int64, user *User) bool {
+			if user.PremiumUntil.IsZero() || user.IsPremium(now) {
+				return false
+			}
+			user.PremiumUntil = time.Time{}
+			expired = append(expired, userID)
+			return true
+		})
+	})
+	return expired, err
 }
 
 func (db *Database) GetPricing() map[string]int {
@@ -521,31 +1129,23 @@ func (db *Database) GetPricing() map[string]int {
 	}
 }
 
-// Исправленная функция статистики
-func (db *Database) GetStatistics(password string) map[string]interface{} {
+// GetStatistics возвращает показатели за каждый из periods под его ключом
+// и общие счетчики; nil, если пароль неверный
+func (db *Database) GetStatistics(password string, periods map[string]calendar.Period) map[string]interface{} {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
-	adminPassword := os.Getenv("STATISTICS_PASSWORD")
-	if adminPassword == "" {
-		adminPassword = "admin123"
-	}
-
-	if password != adminPassword {
+	if password != db.statsPassword {
 		return nil
 	}
 
-	now := time.Now()
-	dayAgo := now.Add(-24 * time.Hour)
-	monthAgo := now.Add(-30 * 24 * time.Hour)
-
 	stats := map[string]interface{}{
-		"all_time":          db.calcPeriodStats(time.Time{}, now),
-		"last_month":        db.calcPeriodStats(monthAgo, now),
-		"last_24h":          db.calcPeriodStats(dayAgo, now),
 		"total_users":       len(db.users),
 		"pending_purchases": len(db.pendingPurchases),
 	}
+	for key, period := range periods {
+		stats[key] = db.calcPeriodStats(period.From, period.To)
+	}
 
 	return stats
 }
@@ -555,6 +1155,7 @@ func (db *Database) calcPeriodStats(from, to time.Time) map[string]interface{} {
 		"users":         0,
 		"new_users":     0,
 		"generations":   0,
+		"rejected":      0,
 		"purchases_10":  0,
 		"purchases_25":  0,
 		"purchases_100": 0,
@@ -598,7 +1199,12 @@ func (db *Database) calcPeriodStats(from, to time.Time) map[string]interface{} {
 	// Подсчет генераций
 	for _, generation := range db.generations {
 		if generation.Timestamp.After(from) && (to.IsZero() || generation.Timestamp.Before(to)) {
-			stats["generations"] = stats["generations"].(int) + 1
+			switch {
+			case generation.Succeeded():
+				stats["generations"] = stats["generations"].(int) + 1
+			case generation.Outcome == OutcomeRejected:
+				stats["rejected"] = stats["rejected"].(int) + 1
+			}
 		}
 	}
 
@@ -609,6 +1215,190 @@ func (db *Database) calcPeriodStats(from, to time.Time) map[string]interface{} {
 	return stats
 }
 
+// TopicCount тема и число генераций по ней
+type TopicCount struct {
+	Topic string
+	Count int
+}
+
+// PeriodReport сводка за период для отчета администратору
+type PeriodReport struct {
+	From, To   time.Time
+	TotalUsers int
+	NewUsers   int
+	// Generations успешные генерации, Rejected — отклоненные проверкой темы
+	Generations int
+	Rejected    int
+	// Purchases и Revenue число успешных покупок и выручка по пакетам: 10, 25, 100
+	Purchases    map[string]int
+	Revenue      map[string]int
+	TotalRevenue int
+	Ratings      int
+	// AverageRating средняя оценка постов; 0, если оценок не было
+	AverageRating float64
+	// TopTopics самые частые темы генераций, по убыванию
+	TopTopics []TopicCount
+}
+
+// GetPeriodReport собирает сводку за период [from, to) с topics самыми частыми темами
+func (db *Database) GetPeriodReport(from, to time.Time, topics int) PeriodReport {
+	db.mu.RLock()
+	stats := db.calcPeriodStats(from, to)
+	report := PeriodReport{
+		From:         from,
+		To:           to,
+		TotalUsers:   stats["users"].(int),
+		NewUsers:     stats["new_users"].(int),
+		Generations:  stats["generations"].(int),
+		Rejected:     stats["rejected"].(int),
+		Purchases:    make(map[string]int),
+		Revenue:      make(map[string]int),
+		TotalRevenue: stats["total_revenue"].(int),
+	}
+	for _, code := range []string{"10", "25", "100"} {
+		report.Purchases[code] = stats["purchases_"+code].(int)
+		report.Revenue[code] = stats["revenue_"+code].(int)
+	}
+
+	sum := 0
+	for _, rating := range db.ratings {
+		if !rating.Timestamp.Before(from) && rating.Timestamp.Before(to) {
+			report.Ratings++
+			sum += rating.Rating
+		}
+	}
+	if report.Ratings > 0 {
+		report.AverageRating = float64(sum) / float64(report.Ratings)
+	}
+	db.mu.RUnlock()
+
+	for topic, count := range db.GetTopGenerationTopics(from, to, topics) {
+		report.TopTopics = append(report.TopTopics, TopicCount{Topic: topic, Count: count})
+	}
+	sort.Slice(report.TopTopics, func(i, j int) bool {
+		if report.TopTopics[i].Count != report.TopTopics[j].Count {
+			return report.TopTopics[i].Count > report.TopTopics[j].Count
+		}
+		return report.TopTopics[i].Topic < report.TopTopics[j].Topic
+	})
+	if len(report.TopTopics) > topics {
+		report.TopTopics = report.TopTopics[:topics]
+	}
+	return report
+}
+
+// LastReport возвращает день последней отправки отчета kind (2006-01-02) или пустую строку
+func (db *Database) LastReport(kind string) string {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.reports[kind]
+}
+
+// MarkReportSent запоминает, что отчет kind за день day отправлен. Отметка сохраняется
+// сразу, чтобы после перезапуска отчет не ушел второй раз.
+func (db *Database) MarkReportSent(kind, day string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.reports[kind] = day
+	data, err := json.MarshalIndent(db.reports, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга отметок отчетов: %w", err)
+	}
+
+	tempFile := reportsFile + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи временного файла: %w", err)
+	}
+	if err := os.Rename(tempFile, reportsFile); err != nil {
+		return fmt.Errorf("ошибка переименования файла: %w", err)
+	}
+	return nil
+}
+
+// updateOffset содержимое updatesFile
+type updateOffset struct {
+	LastUpdateID int `json:"last_update_id"`
+}
+
+// loadUpdateOffset читает последнее обработанное обновление
+func (db *Database) loadUpdateOffset() {
+	data, err := os.ReadFile(updatesFile)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	var offset updateOffset
+	if err := json.Unmarshal(data, &offset); err != nil {
+		log.Printf("[DB] ⚠️ Ошибка чтения %s: %v", updatesFile, err)
+		return
+	}
+	db.offsetMu.Lock()
+	db.lastUpdateID = offset.LastUpdateID
+	db.offsetMu.Unlock()
+}
+
+// LastUpdateID возвращает последнее обработанное обновление Telegram; 0 — обновлений не было
+func (db *Database) LastUpdateID() int {
+	db.offsetMu.Lock()
+	defer db.offsetMu.Unlock()
+	return db.lastUpdateID
+}
+
+// AcceptUpdate отмечает обновление updateID принятым. Возвращает false, если оно уже было
+// принято: Telegram повторяет обновления, подтверждение которых не дошло до него перед
+// остановкой. С persist смещение сохраняется сразу, иначе — при FlushUpdateOffset.
+// Ошибка сохранения не отменяет принятия.
+func (db *Database) AcceptUpdate(updateID int, persist bool) (bool, error) {
+	db.offsetMu.Lock()
+	defer db.offsetMu.Unlock()
+
+	if updateID <= db.lastUpdateID {
+		return false, nil
+	}
+	db.lastUpdateID = updateID
+	db.offsetDirty = true
+	if !persist {
+		return true, nil
+	}
+	return true, db.saveUpdateOffset()
+}
+
+// FlushUpdateOffset сохраняет смещение, если оно изменилось
+func (db *Database) FlushUpdateOffset() error {
+	db.offsetMu.Lock()
+	defer db.offsetMu.Unlock()
+	if !db.offsetDirty {
+		return nil
+	}
+	return db.saveUpdateOffset()
+}
+
+// saveUpdateOffset записывает смещение. Вызывается под offsetMu.
+func (db *Database) saveUpdateOffset() error {
+	data, err := json.Marshal(updateOffset{LastUpdateID: db.lastUpdateID})
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга смещения обновлений: %w", err)
+	}
+
+	tempFile := updatesFile + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи временного файла: %w", err)
+	}
+	if err := os.Rename(tempFile, updatesFile); err != nil {
+		return fmt.Errorf("ошибка переименования файла: %w", err)
+	}
+	db.offsetDirty = false
+	return nil
+}
+
+// History возвращает копии журналов генераций, покупок и оценок, например для переноса
+// в журнал событий
+func (db *Database) History() ([]Generation, []Purchase, []Rating) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return slices.Clone(db.generations), slices.Clone(db.purchases), slices.Clone(db.ratings)
+}
+
 func (db *Database) GetTopGenerationTopics(from, to time.Time, limit int) map[string]int {
 	db.mu.RLock()
 	defer db.mu.RUnlock()
@@ -616,7 +1406,7 @@ func (db *Database) GetTopGenerationTopics(from, to time.Time, limit int) map[st
 	topics := make(map[string]int)
 
 	for _, generation := range db.generations {
-		if generation.Timestamp.After(from) && (to.IsZero() || generation.Timestamp.Before(to)) {
+		if generation.Succeeded() && generation.Timestamp.After(from) && (to.IsZero() || generation.Timestamp.Before(to)) {
 			// Очищаем ключевые слова и приводим к нижнему регистру
 			keywords := strings.ToLower(strings.TrimSpace(generation.Keywords))
 			if keywords != "" {
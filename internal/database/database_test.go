@@ -0,0 +1,224 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDatabase создает пустую базу в отдельном каталоге: файлы базы
+// пишутся относительно рабочего каталога
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	return NewDatabase(Config{File: "users.json", StatisticsPassword: "secret", FreeTrialGenerations: 10})
+}
+
+func TestRatingsMoveSourceWeight(t *testing.T) {
+	db := newTestDatabase(t)
+
+	for i := 0; i < 10; i++ {
+		db.AddGeneration(1, "тема", "Плохой источник", "")
+		if _, _, err := db.AddRating(1, 1, "тема"); err != nil {
+			t.Fatal(err)
+		}
+		db.AddGeneration(2, "тема", "Хороший источник", "")
+		if _, _, err := db.AddRating(2, 5, "тема"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	weights := db.GetSourceWeights()
+	bad, good := weights["Плохой источник"], weights["Хороший источник"]
+	if bad >= 0.6 || bad < minSourceWeight {
+		t.Errorf("вес плохого источника %.3f, ожидалось около %.1f", bad, minSourceWeight)
+	}
+	if good <= 1.4 || good > maxSourceWeight {
+		t.Errorf("вес хорошего источника %.3f, ожидалось около %.1f", good, maxSourceWeight)
+	}
+}
+
+func TestRatingMovesWeightGradually(t *testing.T) {
+	db := newTestDatabase(t)
+	db.AddGeneration(1, "тема", "Источник", "")
+
+	source, weight, err := db.AddRating(1, 1, "тема")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != "Источник" {
+		t.Fatalf("источник = %q", source)
+	}
+	// Одна оценка сдвигает вес на долю sourceWeightAlpha от разницы с целевым
+	want := (1-sourceWeightAlpha)*1.0 + sourceWeightAlpha*minSourceWeight
+	if weight != want {
+		t.Errorf("вес = %.3f, ожидалось %.3f", weight, want)
+	}
+}
+
+func TestRatingWithoutGenerationKeepsWeights(t *testing.T) {
+	db := newTestDatabase(t)
+	source, _, err := db.AddRating(1, 1, "тема")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source != "" || len(db.GetSourceWeights()) != 0 {
+		t.Errorf("оценка без генерации изменила веса: %q, %v", source, db.GetSourceWeights())
+	}
+}
+
+func TestSourceWeightsSurviveReload(t *testing.T) {
+	db := newTestDatabase(t)
+	db.AddGeneration(1, "тема", "Источник", "")
+	if _, _, err := db.AddRating(1, 5, "тема"); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := NewDatabase(Config{File: "users.json", FreeTrialGenerations: 10})
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := reloaded.GetSourceWeights()["Источник"], db.GetSourceWeights()["Источник"]; got != want {
+		t.Errorf("вес после перезагрузки %.3f, ожидалось %.3f", got, want)
+	}
+}
+
+func TestCheckRoundTrip(t *testing.T) {
+	db := newTestDatabase(t)
+	if err := db.CheckRoundTrip(); err != nil {
+		t.Fatal(err)
+	}
+	// Пробный файл не остается в каталоге базы
+	if entries, _ := os.ReadDir("."); len(entries) != 0 {
+		t.Errorf("в каталоге базы остались файлы: %v", entries)
+	}
+
+	db.file = filepath.Join("missing", "users.json")
+	if err := db.CheckRoundTrip(); err == nil {
+		t.Error("недоступный каталог прошел проверку")
+	}
+}
+
+func TestPremiumPackageGrantsPremium(t *testing.T) {
+	db := newTestDatabase(t)
+
+	if err := db.AddPurchase(1, "25", 199); err != nil {
+		t.Fatal(err)
+	}
+	if db.IsPremium(1) {
+		t.Fatal("премиум за пакет 25")
+	}
+
+	// Повторная покупка продлевает премиум от текущей даты окончания
+	for i := 1; i <= 2; i++ {
+		if err := db.AddPurchase(1, PremiumPackage, 499); err != nil {
+			t.Fatal(err)
+		}
+		want := time.Now().Add(time.Duration(i) * PremiumPeriod)
+		if until := db.GetUser(1).PremiumUntil; until.Before(want.Add(-time.Minute)) || until.After(want) {
+			t.Errorf("покупка %d: премиум до %v, ожидалось %v", i, until, want)
+		}
+	}
+
+	reloaded := NewDatabase(Config{File: "users.json"})
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.IsPremium(1) || !reloaded.GetUser(1).PremiumUntil.Equal(db.GetUser(1).PremiumUntil) {
+		t.Errorf("премиум после перезагрузки: %v", reloaded.GetUser(1).PremiumUntil)
+	}
+}
+
+func TestSetPremium(t *testing.T) {
+	db := newTestDatabase(t)
+
+	first, err := db.SetPremium(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := db.SetPremium(1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := second.Sub(first); got != 5*24*time.Hour {
+		t.Errorf("продлено на %v, ожидалось 5 дней", got)
+	}
+
+	if until, err := db.SetPremium(1, 0); err != nil || !until.IsZero() || db.IsPremium(1) {
+		t.Errorf("отмена премиума: %v, %v", until, err)
+	}
+}
+
+func TestExpirePremium(t *testing.T) {
+	db := newTestDatabase(t)
+	for _, userID := range []int64{1, 2} {
+		if _, err := db.SetPremium(userID, int(userID)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.AdjustGenerations(3, 5, 0, "тест", true); err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := db.ExpirePremium(time.Now().Add(36 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Пользователь без премиума не считается закончившим его
+	if len(expired) != 1 || expired[0] != 1 {
+		t.Errorf("снят премиум у %v, ожидалось [1]", expired)
+	}
+	if !db.GetUser(1).PremiumUntil.IsZero() || db.GetUser(2).PremiumUntil.IsZero() {
+		t.Error("даты премиума после обхода")
+	}
+	if again, _ := db.ExpirePremium(time.Now().Add(36 * time.Hour)); len(again) != 0 {
+		t.Errorf("повторный обход снял премиум у %v", again)
+	}
+}
+
+func TestNewUserPaths(t *testing.T) {
+	tests := []struct {
+		name   string
+		create func(db *Database) error
+		// wantAvailable доступные генерации после действия
+		wantAvailable int
+		wantTrial     int
+	}{
+		{"отзыв", func(db *Database) error { db.SetPendingFeedback(1, true); return nil }, 3, 3},
+		{"ожидание текста", func(db *Database) error { db.SetPendingRewrite(1, true); return nil }, 3, 3},
+		{"резерв генерации", func(db *Database) error { _, err := db.ReserveGeneration(1); return err }, 2, 3},
+		{"доля генерации", func(db *Database) error { _, err := db.UseGenerationFraction(1, 1); return err }, 2, 3},
+		{"покупка", func(db *Database) error { return db.AddPurchase(1, "10", 99) }, 13, 3},
+		// Начисление администратором не добавляет пробные генерации к начисленным
+		{"начисление администратором", func(db *Database) error {
+			_, err := db.AdjustGenerations(1, 5, 999, "компенсация", true)
+			return err
+		}, 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			db := NewDatabase(Config{File: "users.json", FreeTrialGenerations: 3})
+			if err := db.Load(); err != nil {
+				t.Fatal(err)
+			}
+			// Просмотр пользователя не заводит его в базе
+			if user := db.GetUser(1); user.AvailableGenerations != 3 || len(db.GetAllUsers()) != 0 {
+				t.Fatalf("просмотр нового пользователя: %+v, в базе %d", user, len(db.GetAllUsers()))
+			}
+
+			if err := tt.create(db); err != nil {
+				t.Fatal(err)
+			}
+			user := db.GetUser(1)
+			if user.AvailableGenerations != tt.wantAvailable || user.TrialGenerations != tt.wantTrial {
+				t.Errorf("доступно %d, пробных %d; ожидалось %d, %d",
+					user.AvailableGenerations, user.TrialGenerations, tt.wantAvailable, tt.wantTrial)
+			}
+			if len(db.GetAllUsers()) != 1 {
+				t.Errorf("пользователей в базе: %d", len(db.GetAllUsers()))
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// experimentFile последний эксперимент с промптами
+const experimentFile = "experiment.json"
+
+// Варианты эксперимента
+const (
+	VariantA = "A"
+	VariantB = "B"
+)
+
+// Experiment эксперимент с двумя вариантами системного промпта поста
+type Experiment struct {
+	Name string `json:"name"`
+	// TemplateA и TemplateB файлы шаблонов вариантов
+	TemplateA string    `json:"template_a"`
+	TemplateB string    `json:"template_b"`
+	StartedAt time.Time `json:"started_at"`
+	// StoppedAt время остановки; нулевое — эксперимент идет
+	StoppedAt time.Time `json:"stopped_at,omitempty"`
+}
+
+// Active идет ли эксперимент
+func (e Experiment) Active() bool {
+	return e.Name != "" && e.StoppedAt.IsZero()
+}
+
+// ExperimentVariant вариант эксперимента name для пользователя. Зависит только от имени
+// эксперимента и пользователя: пользователь остается в своем варианте после перезапуска,
+// а в новом эксперименте распределяется заново.
+func ExperimentVariant(name string, userID int64) string {
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	hash.Write([]byte{0})
+	hash.Write([]byte(strconv.FormatInt(userID, 10)))
+	// Младший бит FNV зависит только от четности байтов и при смене имени эксперимента
+	// меняется у всех пользователей сразу; старший перемешан со всем входом
+	if hash.Sum32()>>31 == 0 {
+		return VariantA
+	}
+	return VariantB
+}
+
+// loadExperiment читает последний эксперимент
+func (db *Database) loadExperiment() {
+	data, err := os.ReadFile(experimentFile)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	var experiment Experiment
+	if err := json.Unmarshal(data, &experiment); err != nil {
+		log.Printf("[DB] ⚠️ Ошибка чтения %s: %v", experimentFile, err)
+		return
+	}
+	db.experiment = experiment
+}
+
+// saveExperiment сохраняет эксперимент. Вызывается под mu.
+func (db *Database) saveExperiment() error {
+	data, err := json.MarshalIndent(db.experiment, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга эксперимента: %w", err)
+	}
+
+	tempFile := experimentFile + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи временного файла: %w", err)
+	}
+	if err := os.Rename(tempFile, experimentFile); err != nil {
+		return fmt.Errorf("ошибка переименования файла: %w", err)
+	}
+	return nil
+}
+
+// Experiment возвращает последний эксперимент: идущий или остановленный
+func (db *Database) Experiment() Experiment {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.experiment
+}
+
+// StartExperiment запускает эксперимент. Одновременно идет только один эксперимент.
+func (db *Database) StartExperiment(experiment Experiment) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.experiment.Active() {
+		return fmt.Errorf("эксперимент %s уже идет", db.experiment.Name)
+	}
+	experiment.StoppedAt = time.Time{}
+	db.experiment = experiment
+	log.Printf("[DB] Эксперимент %s запущен: A=%s, B=%s", experiment.Name, experiment.TemplateA, experiment.TemplateB)
+	return db.saveExperiment()
+}
+
+// StopExperiment останавливает идущий эксперимент и возвращает его
+func (db *Database) StopExperiment(now time.Time) (Experiment, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if !db.experiment.Active() {
+		return Experiment{}, fmt.Errorf("эксперимент не запущен")
+	}
+	db.experiment.StoppedAt = now
+	log.Printf("[DB] Эксперимент %s остановлен", db.experiment.Name)
+	return db.experiment, db.saveExperiment()
+}
+
+// SetGenerationVariant отмечает записи журнала с кодом запроса requestID экспериментом
+// и вариантом. Как и длительность, отметка дописывается после записи исхода.
+func (db *Database) SetGenerationVariant(requestID, experiment, variant string) {
+	if requestID == "" || experiment == "" {
+		return
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for i := len(db.generations) - 1; i >= 0; i-- {
+		if db.generations[i].RequestID == requestID {
+			db.generations[i].Experiment = experiment
+			db.generations[i].Variant = variant
+		}
+	}
+}
+
+// VariantReport показатели варианта эксперимента
+type VariantReport struct {
+	Variant string
+	// Generations генерации варианта, дошедшие до модели: успешные, отказы модели
+	// и посты, от которых отказались в предпросмотре
+	Generations int
+	Succeeded   int
+	Refused     int
+	// Ratings сколько постов варианта оценено, AverageRating — средняя оценка
+	Ratings       int
+	AverageRating float64
+}
+
+// SuccessRate доля успешных генераций
+func (r VariantReport) SuccessRate() float64 {
+	return share(r.Succeeded, r.Generations)
+}
+
+// RefusalRate доля отказов модели
+func (r VariantReport) RefusalRate() float64 {
+	return share(r.Refused, r.Generations)
+}
+
+// share доля part от total; 0 при пустом total
+func share(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+// ExperimentReport показатели вариантов A и B эксперимента name по журналу генераций и оценкам
+func (db *Database) ExperimentReport(name string) []VariantReport {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	reports := []VariantReport{{Variant: VariantA}, {Variant: VariantB}}
+	variant := func(value string) *VariantReport {
+		for i := range reports {
+			if reports[i].Variant == value {
+				return &reports[i]
+			}
+		}
+		return nil
+	}
+
+	for _, generation := range db.generations {
+		report := variant(generation.Variant)
+		if generation.Experiment != name || report == nil {
+			continue
+		}
+		report.Generations++
+		switch {
+		case generation.Succeeded():
+			report.Succeeded++
+		case generation.Outcome == OutcomeRefused:
+			report.Refused++
+		}
+	}
+
+	sums := make(map[string]int)
+	for _, rating := range db.ratings {
+		report := variant(rating.Variant)
+		if rating.Experiment != name || report == nil {
+			continue
+		}
+		report.Ratings++
+		sums[rating.Variant] += rating.Rating
+	}
+	for i := range reports {
+		if reports[i].Ratings > 0 {
+			reports[i].AverageRating = float64(sums[reports[i].Variant]) / float64(reports[i].Ratings)
+		}
+	}
+	return reports
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestExperimentVariantStable(t *testing.T) {
+	counts := make(map[string]int)
+	moved := 0
+	for userID := int64(1); userID <= 1000; userID++ {
+		variant := ExperimentVariant("краткость", userID)
+		if variant != VariantA && variant != VariantB {
+			t.Fatalf("вариант %q", variant)
+		}
+		// Вариант зависит только от эксперимента и пользователя
+		if again := ExperimentVariant("краткость", userID); again != variant {
+			t.Fatalf("пользователь %d: %s, затем %s", userID, variant, again)
+		}
+		counts[variant]++
+		if ExperimentVariant("тон", userID) != variant {
+			moved++
+		}
+	}
+
+	// Пользователи делятся примерно поровну, а в новом эксперименте распределяются заново
+	if counts[VariantA] < 400 || counts[VariantB] < 400 {
+		t.Errorf("распределение по вариантам: %v", counts)
+	}
+	if moved < 300 || moved > 700 {
+		t.Errorf("в другом эксперименте вариант сменили %d из 1000", moved)
+	}
+}
+
+func TestExperimentStartStop(t *testing.T) {
+	db := newTestDatabase(t)
+	started := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	experiment := Experiment{Name: "краткость", TemplateA: "a.tmpl", TemplateB: "b.tmpl", StartedAt: started}
+
+	if _, err := db.StopExperiment(started); err == nil {
+		t.Error("остановлен незапущенный эксперимент")
+	}
+	if err := db.StartExperiment(experiment); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.StartExperiment(Experiment{Name: "тон"}); err == nil {
+		t.Error("запущен второй эксперимент одновременно с первым")
+	}
+
+	// Идущий эксперимент переживает перезапуск
+	reopened := NewDatabase(Config{File: "users.json"})
+	if err := reopened.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if got := reopened.Experiment(); !got.Active() || got.Name != "краткость" || got.TemplateB != "b.tmpl" {
+		t.Fatalf("эксперимент после перезапуска: %+v", got)
+	}
+
+	stopped, err := reopened.StopExperiment(started.Add(time.Hour))
+	if err != nil || stopped.Active() || !stopped.StoppedAt.Equal(started.Add(time.Hour)) {
+		t.Fatalf("остановка: %+v, %v", stopped, err)
+	}
+	// После остановки можно запустить следующий
+	if err := reopened.StartExperiment(Experiment{Name: "тон", StartedAt: started.Add(2 * time.Hour)}); err != nil {
+		t.Errorf("следующий эксперимент: %v", err)
+	}
+}
+
+func TestRatingLinkedToVariant(t *testing.T) {
+	db := newTestDatabase(t)
+	db.AddGeneration(1, "ставка цб", "РБК", "req-1")
+	db.SetGenerationVariant("req-1", "краткость", VariantB)
+	// Отказ модели не оценивается: оценка относится к последнему посту
+	db.AddGenerationOutcome(1, "ставка цб", OutcomeRefused, "", "req-2")
+	db.SetGenerationVariant("req-2", "краткость", VariantA)
+
+	if _, _, err := db.AddRating(1, 4, "ставка цб"); err != nil {
+		t.Fatal(err)
+	}
+	if rating := db.ratings[len(db.ratings)-1]; rating.Experiment != "краткость" || rating.Variant != VariantB {
+		t.Errorf("оценка: %+v", rating)
+	}
+
+	// Без кода запроса или эксперимента отметки нет
+	db.AddGeneration(2, "нефть", "РБК", "")
+	db.SetGenerationVariant("", "краткость", VariantA)
+	db.SetGenerationVariant("req-1", "", VariantA)
+	for _, generation := range db.generations {
+		if generation.UserID == 2 && generation.Experiment != "" || generation.RequestID == "req-1" && generation.Variant != VariantB {
+			t.Errorf("запись журнала: %+v", generation)
+		}
+	}
+}
+
+func TestExperimentReport(t *testing.T) {
+	db := newTestDatabase(t)
+	generation := func(experiment, variant, outcome string) {
+		db.generations = append(db.generations, Generation{UserID: 1, Outcome: outcome, Experiment: experiment, Variant: variant})
+	}
+	rating := func(experiment, variant string, value int) {
+		db.ratings = append(db.ratings, Rating{UserID: 1, Rating: value, Experiment: experiment, Variant: variant})
+	}
+
+	// A: три успешных, один отказ модели, один отказ в предпросмотре
+	for _, outcome := range []string{OutcomeSuccess, OutcomeSuccess, OutcomeRewrite, OutcomeRefused, OutcomeDeclined} {
+		generation("краткость", VariantA, outcome)
+	}
+	rating("краткость", VariantA, 5)
+	rating("краткость", VariantA, 4)
+	rating("краткость", VariantA, 4)
+	// B: два отказа модели
+	generation("краткость", VariantB, OutcomeRefused)
+	generation("краткость", VariantB, OutcomeRefused)
+	// Другие эксперименты, генерации вне экспериментов и неизвестные варианты не учитываются
+	generation("тон", VariantA, OutcomeSuccess)
+	generation("", "", OutcomeSuccess)
+	generation("краткость", "C", OutcomeSuccess)
+	rating("тон", VariantB, 1)
+	rating("краткость", "", 1)
+
+	reports := db.ExperimentReport("краткость")
+	if len(reports) != 2 || reports[0].Variant != VariantA || reports[1].Variant != VariantB {
+		t.Fatalf("варианты: %+v", reports)
+	}
+	a, b := reports[0], reports[1]
+	if a.Generations != 5 || a.Succeeded != 3 || a.Refused != 1 || a.Ratings != 3 {
+		t.Errorf("вариант A: %+v", a)
+	}
+	if b.Generations != 2 || b.Succeeded != 0 || b.Refused != 2 || b.Ratings != 0 || b.AverageRating != 0 {
+		t.Errorf("вариант B: %+v", b)
+	}
+
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"успешных A", a.SuccessRate(), 0.6},
+		{"отказов A", a.RefusalRate(), 0.2},
+		{"оценка A", a.AverageRating, 13.0 / 3},
+		{"успешных B", b.SuccessRate(), 0},
+		{"отказов B", b.RefusalRate(), 1},
+		// Без генераций доли нулевые, а не NaN
+		{"пустой вариант", VariantReport{}.SuccessRate(), 0},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%s: %v, ожидалось %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
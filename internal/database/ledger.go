@@ -0,0 +1,125 @@
+package database
+
+import (
+	"errors"
+	"log"
+	"time"
+)
+
+// ledgerFile журнал ручных изменений баланса администратором
+const ledgerFile = "balance_ledger.json"
+
+// ErrUnknownUser пользователя нет в базе: он ни разу не писал боту
+var ErrUnknownUser = errors.New("пользователь не найден")
+
+// BalanceAdjustment ручное изменение баланса генераций администратором
+type BalanceAdjustment struct {
+	UserID int64 `json:"user_id"`
+	// Delta на сколько изменился баланс: начисление больше нуля, списание меньше.
+	// Списание не опускает баланс ниже нуля, поэтому может быть меньше запрошенного.
+	Delta int `json:"delta"`
+	// Balance баланс после изменения
+	Balance int `json:"balance"`
+	// AdminChatID чат администратора, выполнившего изменение; 0 — из командной строки
+	AdminChatID int64  `json:"admin_chat_id"`
+	Reason      string `json:"reason,omitempty"`
+	// Created пользователь заведен этим начислением
+	Created   bool      `json:"created,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// AdjustGenerations изменяет баланс пользователя на delta генераций и записывает изменение
+// в журнал вместе с администратором и причиной. Неизвестный пользователь создается только
+// при create, иначе возвращается ErrUnknownUser: опечатка в chatID не должна заводить
+// пользователя с начисленным балансом. Созданный так пользователь не получает пробных
+// генераций поверх начисленных. Баланс не опускается ниже нуля; если он не изменился,
+// запись в журнал не добавляется.
+func (db *Database) AdjustGenerations(userID int64, delta int, adminChatID int64, reason string, create bool) (BalanceAdjustment, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, exists := db.users[userID]
+	if !exists && !create {
+		return BalanceAdjustment{}, ErrUnknownUser
+	}
+	if !exists {
+		user = &User{UserID: userID, CreatedAt: time.Now()}
+		db.users[userID] = user
+	}
+
+	before := user.AvailableGenerations
+	user.AvailableGenerations = max(0, before+delta)
+	adjustment := BalanceAdjustment{
+		UserID:      userID,
+		Delta:       user.AvailableGenerations - before,
+		Balance:     user.AvailableGenerations,
+		AdminChatID: adminChatID,
+		Reason:      reason,
+		Created:     !exists,
+		Timestamp:   time.Now(),
+	}
+	if adjustment.Delta == 0 && exists {
+		return adjustment, nil
+	}
+
+	db.ledger = append(db.ledger, adjustment)
+	log.Printf("[DB] Администратор %d изменил баланс пользователя %d на %+d: теперь %d генераций",
+		adminChatID, userID, adjustment.Delta, adjustment.Balance)
+
+	if err := db.save(); err != nil {
+		log.Printf("[DB] ❌ Ошибка сохранения: %v", err)
+		return adjustment, err
+	}
+	return adjustment, nil
+}
+
+// BalanceAdjustments ручные изменения баланса за [from, to), от старых к новым
+func (db *Database) BalanceAdjustments(from, to time.Time) []BalanceAdjustment {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var adjustments []BalanceAdjustment
+	for _, adjustment := range db.ledger {
+		if !adjustment.Timestamp.Before(from) && adjustment.Timestamp.Before(to) {
+			adjustments = append(adjustments, adjustment)
+		}
+	}
+	return adjustments
+}
+
+// UserBalanceAdjustments последние limit ручных изменений баланса пользователя, от новых к старым
+func (db *Database) UserBalanceAdjustments(userID int64, limit int) []BalanceAdjustment {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	var adjustments []BalanceAdjustment
+	for i := len(db.ledger) - 1; i >= 0 && len(adjustments) < limit; i-- {
+		if db.ledger[i].UserID == userID {
+			adjustments = append(adjustments, db.ledger[i])
+		}
+	}
+	return adjustments
+}
+
+// HasUser есть ли пользователь в базе
+func (db *Database) HasUser(userID int64) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	_, exists := db.users[userID]
+	return exists
+}
+
+// unlinkLedger отвязывает записи журнала изменений баланса от пользователя: суммы и
+// администратор остаются для учета, причина удаляется вместе с пользователем.
+// Вызывается под mu.
+func (db *Database) unlinkLedger(userID int64) int {
+	unlinked := 0
+	for i := range db.ledger {
+		if db.ledger[i].UserID == userID {
+			db.ledger[i].UserID = 0
+			db.ledger[i].Reason = ""
+			unlinked++
+		}
+	}
+	return unlinked
+}
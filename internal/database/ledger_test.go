@@ -0,0 +1,96 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdjustGenerations(t *testing.T) {
+	tests := []struct {
+		name string
+		// balance баланс существующего пользователя 1
+		balance int
+		userID  int64
+		delta   int
+		create  bool
+		wantErr error
+		// wantDelta и wantBalance изменение и баланс в записи журнала
+		wantDelta, wantBalance int
+		wantCreated            bool
+		wantLogged             bool
+	}{
+		{"начисление", 3, 1, 5, false, nil, 5, 8, false, true},
+		{"опечатка в chatid", 3, 2, 5, false, ErrUnknownUser, 0, 0, false, false},
+		// Заведенный начислением пользователь получает ровно начисленное, без пробных
+		{"новый пользователь", 3, 2, 5, true, nil, 5, 5, true, true},
+		{"списание", 3, 1, -2, false, nil, -2, 1, false, true},
+		// Списать больше баланса нельзя: в журнал попадает фактическое списание
+		{"списание больше баланса", 3, 1, -5, false, nil, -3, 0, false, true},
+		{"списание с нулевого баланса", 0, 1, -5, false, nil, 0, 0, false, false},
+		{"списание у неизвестного", 3, 2, -1, false, ErrUnknownUser, 0, 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDatabase(t)
+			db.users[1] = &User{UserID: 1, AvailableGenerations: tt.balance}
+
+			adjustment, err := db.AdjustGenerations(tt.userID, tt.delta, 999, "компенсация", tt.create)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ошибка %v, ожидалась %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if db.HasUser(tt.userID) {
+					t.Error("пользователь заведен без --create")
+				}
+			} else if adjustment.Delta != tt.wantDelta || adjustment.Balance != tt.wantBalance || adjustment.Created != tt.wantCreated {
+				t.Errorf("изменение: %+v", adjustment)
+			}
+			if tt.wantErr == nil && db.GetUser(tt.userID).AvailableGenerations != tt.wantBalance {
+				t.Errorf("баланс %d, ожидался %d", db.GetUser(tt.userID).AvailableGenerations, tt.wantBalance)
+			}
+
+			logged := db.UserBalanceAdjustments(tt.userID, 10)
+			if (len(logged) == 1) != tt.wantLogged || len(logged) > 1 {
+				t.Fatalf("журнал: %+v", logged)
+			}
+			if tt.wantLogged && (logged[0].AdminChatID != 999 || logged[0].Reason != "компенсация" || logged[0].Delta != tt.wantDelta) {
+				t.Errorf("запись журнала: %+v", logged[0])
+			}
+		})
+	}
+}
+
+func TestBalanceLedger(t *testing.T) {
+	db := newTestDatabase(t)
+	for i, delta := range []int{5, -2, 10} {
+		if _, err := db.AdjustGenerations(1, delta, 999, "", i == 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.AdjustGenerations(2, 7, 998, "бонус", true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Журнал переживает перезапуск
+	reopened := NewDatabase(Config{File: "users.json"})
+	if err := reopened.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Изменения пользователя — от новых к старым, не больше limit
+	latest := reopened.UserBalanceAdjustments(1, 2)
+	if len(latest) != 2 || latest[0].Delta != 10 || latest[0].Balance != 13 || latest[1].Delta != -2 {
+		t.Errorf("последние изменения: %+v", latest)
+	}
+
+	// За период — все пользователи, от старых к новым; конец периода не входит
+	now := time.Now()
+	all := reopened.BalanceAdjustments(now.Add(-time.Hour), now.Add(time.Hour))
+	if len(all) != 4 || all[0].Delta != 5 || !all[0].Created || all[3].UserID != 2 || all[3].AdminChatID != 998 {
+		t.Errorf("изменения за период: %+v", all)
+	}
+	if before := reopened.BalanceAdjustments(now.Add(-time.Hour), all[0].Timestamp); len(before) != 0 {
+		t.Errorf("изменения до периода: %+v", before)
+	}
+}
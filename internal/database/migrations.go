@@ -0,0 +1,92 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// migrationsFile примененные миграции данных
+const migrationsFile = "migrations.json"
+
+// legacyFreeTrialGenerations пробные генерации, которые получали пользователи
+// до появления FREE_TRIAL_GENERATIONS
+const legacyFreeTrialGenerations = 10
+
+// migration одноразовое изменение сохраненных данных. Применяется один раз:
+// после этого его имя записывается в migrationsFile.
+type migration struct {
+	name  string
+	apply func(db *Database)
+}
+
+// migrations миграции в порядке применения
+var migrations = []migration{
+	// Пробные генерации теперь запоминаются у пользователя. Существующим пользователям
+	// записываются прежние 10 один раз, чтобы смена FREE_TRIAL_GENERATIONS не меняла
+	// задним числом сведения о том, что они получили.
+	{name: "trial_generations", apply: func(db *Database) {
+		for _, user := range db.users {
+			if user.TrialGenerations == 0 {
+				user.TrialGenerations = legacyFreeTrialGenerations
+			}
+		}
+	}},
+}
+
+// migrate применяет миграции, которые еще не применялись. На пустой базе миграции
+// только отмечаются: данных, которые нужно менять, в ней нет. Вызывается под mu.
+func (db *Database) migrate() error {
+	db.migrations = make(map[string]time.Time)
+	data, err := os.ReadFile(migrationsFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("ошибка чтения %s: %w", migrationsFile, err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &db.migrations); err != nil {
+			return fmt.Errorf("ошибка разбора %s: %w", migrationsFile, err)
+		}
+	}
+
+	applied := false
+	for _, m := range migrations {
+		if _, done := db.migrations[m.name]; done {
+			continue
+		}
+		m.apply(db)
+		db.migrations[m.name] = time.Now()
+		applied = true
+		log.Printf("[DB] ✅ Применена миграция %s", m.name)
+	}
+	if !applied {
+		return nil
+	}
+
+	// Сначала данные, потом отметка: если запись данных не удалась, миграция
+	// применится снова при следующем запуске
+	if len(db.users) > 0 {
+		if err := db.save(); err != nil {
+			return fmt.Errorf("ошибка сохранения базы после миграции: %w", err)
+		}
+	}
+	return db.saveMigrations()
+}
+
+// saveMigrations записывает примененные миграции. Вызывается под mu.
+func (db *Database) saveMigrations() error {
+	data, err := json.MarshalIndent(db.migrations, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга миграций: %w", err)
+	}
+
+	tempFile := migrationsFile + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи временного файла: %w", err)
+	}
+	if err := os.Rename(tempFile, migrationsFile); err != nil {
+		return fmt.Errorf("ошибка переименования файла: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"os"
+	"testing"
+)
+
+func TestTrialMigration(t *testing.T) {
+	t.Chdir(t.TempDir())
+	// Пользователь сохранен до того, как пробные генерации стали запоминаться
+	if err := os.WriteFile("users.json", []byte(`{"1": {"user_id": 1, "available_generations": 4}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db := NewDatabase(Config{File: "users.json", FreeTrialGenerations: 3})
+	if err := db.Load(); err != nil {
+		t.Fatal(err)
+	}
+	user := db.GetUser(1)
+	if user.TrialGenerations != legacyFreeTrialGenerations || user.AvailableGenerations != 4 {
+		t.Errorf("после миграции: пробных %d, доступно %d", user.TrialGenerations, user.AvailableGenerations)
+	}
+	// Новый размер пробного периода действует только на новых пользователей
+	if user := db.GetUser(2); user.TrialGenerations != 3 || user.AvailableGenerations != 3 {
+		t.Errorf("новый пользователь: %+v", user)
+	}
+
+	// Примененная миграция не повторяется, даже если данные снова подходят под нее
+	if err := os.WriteFile("users.json", []byte(`{"1": {"user_id": 1, "available_generations": 4}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewDatabase(Config{File: "users.json", FreeTrialGenerations: 5})
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if user := reloaded.GetUser(1); user.TrialGenerations != 0 || user.AvailableGenerations != 4 {
+		t.Errorf("миграция применена повторно: %+v", user)
+	}
+}
+
+func TestMigrationsMarkedOnEmptyDatabase(t *testing.T) {
+	t.Chdir(t.TempDir())
+	db := NewDatabase(Config{File: "users.json", FreeTrialGenerations: 3})
+	if err := db.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(migrationsFile); err != nil {
+		t.Fatalf("миграции не отмечены: %v", err)
+	}
+	// Пустая база не записывается ради миграций
+	if _, err := os.Stat("users.json"); !os.IsNotExist(err) {
+		t.Errorf("файл базы: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+// outboxFile очередь уведомлений администратору и сводок пользователям
+const outboxFile = "outbox.json"
+
+const (
+	// OutboxDedupWindow сколько хранится отправленное уведомление: повтор с тем же
+	// ключом в это время не ставится в очередь
+	OutboxDedupWindow = 24 * time.Hour
+	// outboxMaxPending сколько неотправленных уведомлений хранится; при переполнении
+	// отбрасываются самые старые
+	outboxMaxPending = 1000
+)
+
+// OutboxMessage уведомление в очереди на отправку
+type OutboxMessage struct {
+	ID     int64  `json:"id"`
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+	// Markdown отправлять с разметкой Markdown
+	Markdown bool `json:"markdown,omitempty"`
+	// DedupKey ключ идемпотентного уведомления; пустой — уведомления не сравниваются
+	DedupKey  string    `json:"dedup_key,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	// Attempts неудачные попытки отправки, NextAttempt — когда пробовать снова
+	Attempts    int       `json:"attempts,omitempty"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+	// SentAt время отправки или отказа от нее; нулевое — уведомление еще в очереди
+	SentAt time.Time `json:"sent_at,omitempty"`
+}
+
+// outboxState содержимое outboxFile
+type outboxState struct {
+	LastID   int64           `json:"last_id"`
+	Messages []OutboxMessage `json:"messages"`
+}
+
+// loadOutbox читает очередь уведомлений: неотправленные до перезапуска уйдут после него
+func (db *Database) loadOutbox() {
+	data, err := os.ReadFile(outboxFile)
+	if err != nil || len(data) == 0 {
+		return
+	}
+	var state outboxState
+	if err := json.Unmarshal(data, &state); err != nil {
+		log.Printf("[DB] ⚠️ Ошибка чтения %s: %v", outboxFile, err)
+		return
+	}
+	db.outboxMu.Lock()
+	db.outbox = state
+	db.outboxMu.Unlock()
+}
+
+// EnqueueOutbox ставит уведомление в очередь. Возвращает false, если уведомление
+// с тем же DedupKey уже ждет отправки или отправлено за OutboxDedupWindow.
+func (db *Database) EnqueueOutbox(message OutboxMessage, now time.Time) (bool, error) {
+	db.outboxMu.Lock()
+	defer db.outboxMu.Unlock()
+
+	db.pruneOutbox(now)
+	if message.DedupKey != "" {
+		for _, queued := range db.outbox.Messages {
+			if queued.DedupKey == message.DedupKey {
+				return false, nil
+			}
+		}
+	}
+
+	db.outbox.LastID++
+	message.ID = db.outbox.LastID
+	message.CreatedAt = now
+	message.NextAttempt = now
+	message.Attempts, message.LastError, message.SentAt = 0, "", time.Time{}
+	db.outbox.Messages = append(db.outbox.Messages, message)
+	return true, db.saveOutbox()
+}
+
+// DueOutbox возвращает неотправленные уведомления, время попытки которых наступило,
+// в порядке постановки в очередь
+func (db *Database) DueOutbox(now time.Time) []OutboxMessage {
+	db.outboxMu.Lock()
+	defer db.outboxMu.Unlock()
+
+	var due []OutboxMessage
+	for _, message := range db.outbox.Messages {
+		if message.SentAt.IsZero() && !message.NextAttempt.After(now) {
+			due = append(due, message)
+		}
+	}
+	return due
+}
+
+// PendingOutbox число неотправленных уведомлений
+func (db *Database) PendingOutbox() int {
+	db.outboxMu.Lock()
+	defer db.outboxMu.Unlock()
+
+	pending := 0
+	for _, message := range db.outbox.Messages {
+		if message.SentAt.IsZero() {
+			pending++
+		}
+	}
+	return pending
+}
+
+// MarkOutboxSent отмечает уведомление отправленным
+func (db *Database) MarkOutboxSent(id int64, now time.Time) error {
+	db.outboxMu.Lock()
+	defer db.outboxMu.Unlock()
+
+	message := db.findOutbox(id)
+	if message == nil {
+		return nil
+	}
+	message.SentAt = now
+	message.LastError = ""
+	return db.saveOutbox()
+}
+
+// MarkOutboxFailed записывает неудачную попытку и время следующей
+func (db *Database) MarkOutboxFailed(id int64, sendErr error, next time.Time) error {
+	db.outboxMu.Lock()
+	defer db.outboxMu.Unlock()
+
+	message := db.findOutbox(id)
+	if message == nil {
+		return nil
+	}
+	message.Attempts++
+	message.LastError = sendErr.Error()
+	message.NextAttempt = next
+	return db.saveOutbox()
+}
+
+// DropOutbox убирает уведомление из очереди без отправки: повторять ее бесполезно
+func (db *Database) DropOutbox(id int64, sendErr error, now time.Time) error {
+	db.outboxMu.Lock()
+	defer db.outboxMu.Unlock()
+
+	message := db.findOutbox(id)
+	if message == nil {
+		return nil
+	}
+	message.Attempts++
+	message.LastError = sendErr.Error()
+	message.SentAt = now
+	return db.saveOutbox()
+}
+
+// findOutbox ищет уведомление по номеру. Вызывается под outboxMu.
+func (db *Database) findOutbox(id int64) *OutboxMessage {
+	for i := range db.outbox.Messages {
+		if db.outbox.Messages[i].ID == id {
+			return &db.outbox.Messages[i]
+		}
+	}
+	return nil
+}
+
+// pruneOutbox удаляет отправленные уведомления старше OutboxDedupWindow и самые старые
+// неотправленные сверх outboxMaxPending. Вызывается под outboxMu.
+func (db *Database) pruneOutbox(now time.Time) {
+	kept := db.outbox.Messages[:0]
+	for _, message := range db.outbox.Messages {
+		if message.SentAt.IsZero() || now.Sub(message.SentAt) < OutboxDedupWindow {
+			kept = append(kept, message)
+		}
+	}
+	db.outbox.Messages = kept
+
+	var pending []int
+	for i, message := range db.outbox.Messages {
+		if message.SentAt.IsZero() {
+			pending = append(pending, i)
+		}
+	}
+	// Уведомления хранятся в порядке постановки, первые неотправленные — самые старые
+	if excess := len(pending) - outboxMaxPending + 1; excess > 0 {
+		drop := make(map[int]bool, excess)
+		for _, index := range pending[:excess] {
+			drop[index] = true
+		}
+		kept := db.outbox.Messages[:0]
+		for i, message := range db.outbox.Messages {
+			if !drop[i] {
+				kept = append(kept, message)
+			}
+		}
+		db.outbox.Messages = kept
+		log.Printf("[DB] ⚠️ Очередь уведомлений переполнена, отброшено самых старых: %d", excess)
+	}
+}
+
+// saveOutbox записывает очередь уведомлений. Вызывается под outboxMu.
+func (db *Database) saveOutbox() error {
+	data, err := json.MarshalIndent(db.outbox, "", "  ")
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга очереди уведомлений: %w", err)
+	}
+
+	tempFile := outboxFile + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи временного файла: %w", err)
+	}
+	if err := os.Rename(tempFile, outboxFile); err != nil {
+		return fmt.Errorf("ошибка переименования файла: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOutboxRetryAndSent(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	for _, text := range []string{"первое", "второе"} {
+		if queued, err := db.EnqueueOutbox(OutboxMessage{ChatID: 999, Text: text}, now); !queued || err != nil {
+			t.Fatalf("%s: %t, %v", text, queued, err)
+		}
+	}
+	due := db.DueOutbox(now)
+	if len(due) != 2 || due[0].Text != "первое" || due[1].Text != "второе" || due[0].ID >= due[1].ID {
+		t.Fatalf("очередь: %+v", due)
+	}
+
+	// После неудачи уведомление ждет своего времени, остальные уходят
+	if err := db.MarkOutboxFailed(due[0].ID, errors.New("timeout"), now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.MarkOutboxSent(due[1].ID, now); err != nil {
+		t.Fatal(err)
+	}
+	if due := db.DueOutbox(now.Add(30 * time.Second)); len(due) != 0 {
+		t.Errorf("до повтора в очереди %+v", due)
+	}
+	due = db.DueOutbox(now.Add(time.Minute))
+	if len(due) != 1 || due[0].Attempts != 1 || due[0].LastError != "timeout" {
+		t.Errorf("повтор: %+v", due)
+	}
+	if pending := db.PendingOutbox(); pending != 1 {
+		t.Errorf("неотправленных %d", pending)
+	}
+
+	// Отказ от отправки убирает уведомление из очереди
+	if err := db.DropOutbox(due[0].ID, errors.New("forbidden"), now); err != nil {
+		t.Fatal(err)
+	}
+	if pending := db.PendingOutbox(); pending != 0 {
+		t.Errorf("неотправленных после отказа %d", pending)
+	}
+}
+
+func TestOutboxDedupKey(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	alert := OutboxMessage{ChatID: 999, Text: "платеж завис", DedupKey: "payment:1"}
+
+	enqueue := func(at time.Time) bool {
+		t.Helper()
+		queued, err := db.EnqueueOutbox(alert, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return queued
+	}
+
+	if !enqueue(now) {
+		t.Fatal("первое уведомление не принято")
+	}
+	// Повтор, пока уведомление ждет отправки
+	if enqueue(now) {
+		t.Error("повтор в очереди принят")
+	}
+	if err := db.MarkOutboxSent(db.DueOutbox(now)[0].ID, now); err != nil {
+		t.Fatal(err)
+	}
+	// Повтор отправленного в пределах окна
+	if enqueue(now.Add(OutboxDedupWindow - time.Minute)) {
+		t.Error("повтор отправленного принят")
+	}
+	// После окна ключ снова свободен
+	if !enqueue(now.Add(OutboxDedupWindow + time.Minute)) {
+		t.Error("уведомление после окна не принято")
+	}
+	// Уведомления без ключа не сравниваются
+	for range 2 {
+		if queued, _ := db.EnqueueOutbox(OutboxMessage{ChatID: 999, Text: "платеж завис"}, now); !queued {
+			t.Error("уведомление без ключа не принято")
+		}
+	}
+}
+
+func TestOutboxSurvivesReload(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Now()
+	db.EnqueueOutbox(OutboxMessage{ChatID: 999, Text: "отправлено"}, now)
+	db.EnqueueOutbox(OutboxMessage{ChatID: 999, Text: "ждет", Markdown: true, DedupKey: "panic:1"}, now)
+	db.MarkOutboxSent(db.DueOutbox(now)[0].ID, now)
+
+	reloaded := NewDatabase(Config{File: "users.json"})
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	due := reloaded.DueOutbox(now)
+	if len(due) != 1 || due[0].Text != "ждет" || !due[0].Markdown {
+		t.Fatalf("после перезагрузки: %+v", due)
+	}
+	// Ключи и номера продолжаются после перезагрузки
+	if queued, _ := reloaded.EnqueueOutbox(OutboxMessage{ChatID: 999, DedupKey: "panic:1"}, now); queued {
+		t.Error("повтор после перезагрузки принят")
+	}
+	reloaded.EnqueueOutbox(OutboxMessage{ChatID: 999, Text: "новое"}, now)
+	if due := reloaded.DueOutbox(now); len(due) != 2 || due[1].ID != 3 {
+		t.Errorf("номер нового уведомления: %+v", due)
+	}
+}
+
+func TestOutboxDropsOldestOnOverflow(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Now()
+	for i := 0; i < outboxMaxPending+5; i++ {
+		if _, err := db.EnqueueOutbox(OutboxMessage{ChatID: 999}, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	due := db.DueOutbox(now)
+	if len(due) != outboxMaxPending || due[0].ID != 6 {
+		t.Errorf("в очереди %d, первое %d", len(due), due[0].ID)
+	}
+}
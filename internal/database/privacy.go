@@ -0,0 +1,68 @@
+package database
+
+import (
+	"log"
+	"slices"
+)
+
+// UserErasure что удалено по просьбе пользователя
+type UserErasure struct {
+	// Found была ли у пользователя запись
+	Found       bool
+	Generations int
+	Ratings     int
+	// Purchases покупки, отвязанные от пользователя: суммы остаются для учета выручки
+	Purchases int
+	// CanceledPayments неоплаченные платежи, которые больше не проверяются в ЮKassa
+	CanceledPayments int
+	// Adjustments ручные изменения баланса, отвязанные от пользователя
+	Adjustments int
+}
+
+// DeleteUserData удаляет данные пользователя: запись с настройками и состоянием отзыва,
+// журнал генераций и оценки. Покупки и ручные изменения баланса остаются для учета, но без
+// связи с пользователем; его неоплаченные платежи больше не проверяются, иначе оплата
+// зачислилась бы уже несуществующему пользователю. Все хранилища меняются под одной блокировкой.
+func (db *Database) DeleteUserData(userID int64) (UserErasure, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	var erasure UserErasure
+	if _, ok := db.users[userID]; ok {
+		erasure.Found = true
+		delete(db.users, userID)
+	}
+
+	before := len(db.generations)
+	db.generations = slices.DeleteFunc(db.generations, func(g Generation) bool { return g.UserID == userID })
+	erasure.Generations = before - len(db.generations)
+
+	before = len(db.ratings)
+	db.ratings = slices.DeleteFunc(db.ratings, func(r Rating) bool { return r.UserID == userID })
+	erasure.Ratings = before - len(db.ratings)
+
+	for i := range db.purchases {
+		if db.purchases[i].UserID == userID {
+			db.purchases[i].UserID = 0
+			erasure.Purchases++
+		}
+	}
+
+	erasure.Adjustments = db.unlinkLedger(userID)
+
+	for paymentID, purchase := range db.pendingPurchases {
+		if purchase.UserID == userID {
+			delete(db.pendingPurchases, paymentID)
+			erasure.CanceledPayments++
+		}
+	}
+
+	log.Printf("[DB] Удалены данные пользователя %d: %+v", userID, erasure)
+	if err := db.save(); err != nil {
+		return erasure, err
+	}
+	if erasure.CanceledPayments == 0 {
+		return erasure, nil
+	}
+	return erasure, db.savePendingPurchases()
+}
@@ -0,0 +1,86 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// ReserveGeneration резервирует генерацию в начале генерации: она переходит из доступных
+// в зарезервированные, и параллельный запрос того же пользователя ее уже не получит.
+// Возвращает false, если доступных генераций нет.
+func (db *Database) ReserveGeneration(userID int64) (bool, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user := db.userForUpdate(userID)
+	if user.AvailableGenerations <= 0 {
+		log.Printf("[DB] У пользователя %d нет доступных генераций", userID)
+		return false, nil
+	}
+
+	user.AvailableGenerations--
+	user.ReservedGenerations++
+	log.Printf("[DB] Генерация зарезервирована для %d: доступно %d, в резерве %d",
+		userID, user.AvailableGenerations, user.ReservedGenerations)
+
+	if err := db.save(); err != nil {
+		log.Printf("[DB] ❌ Ошибка сохранения: %v", err)
+		return true, err
+	}
+	return true, nil
+}
+
+// CommitReservation списывает зарезервированную генерацию: пост отправлен пользователю
+func (db *Database) CommitReservation(userID int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, exists := db.users[userID]
+	if !exists || user.ReservedGenerations <= 0 {
+		return fmt.Errorf("у пользователя %d нет зарезервированных генераций", userID)
+	}
+
+	user.ReservedGenerations--
+	user.TotalGenerations++
+	user.LastGenerate = time.Now()
+	log.Printf("[DB] ✅ Генерация списана у %d: доступно %d, всего использовано %d",
+		userID, user.AvailableGenerations, user.TotalGenerations)
+	return db.save()
+}
+
+// ReleaseReservation возвращает зарезервированную генерацию в доступные: генерация
+// не удалась, отменена или пост из кэша ничего не стоит. Для удаленного пользователя
+// ничего не делает.
+func (db *Database) ReleaseReservation(userID int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	user, exists := db.users[userID]
+	if !exists || user.ReservedGenerations <= 0 {
+		return nil
+	}
+
+	user.ReservedGenerations--
+	user.AvailableGenerations++
+	log.Printf("[DB] Резерв генерации возвращен пользователю %d: доступно %d", userID, user.AvailableGenerations)
+	return db.save()
+}
+
+// releaseReservations возвращает в доступные резервы, оставшиеся от генераций, которые
+// прервал перезапуск: после него ни одна генерация не идет. Вызывается под mu при загрузке.
+func (db *Database) releaseReservations() error {
+	released := 0
+	for _, user := range db.users {
+		if user.ReservedGenerations > 0 {
+			released += user.ReservedGenerations
+			user.AvailableGenerations += user.ReservedGenerations
+			user.ReservedGenerations = 0
+		}
+	}
+	if released == 0 {
+		return nil
+	}
+	log.Printf("[DB] Возвращено %d генераций из резерва прерванных генераций", released)
+	return db.save()
+}
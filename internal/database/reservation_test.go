@@ -0,0 +1,160 @@
+package database
+
+import (
+	"io"
+	"log"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestReservationsNeverExceedBalance(t *testing.T) {
+	const (
+		balance  = 5
+		workers  = 40
+		attempts = 20
+	)
+	db := newTestDatabase(t)
+	saved := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(saved) })
+	db.users[1] = &User{UserID: 1, AvailableGenerations: balance}
+
+	// inFlight не больше, чем генераций в резерве: растет после резерва и уменьшается
+	// до списания или возврата
+	var inFlight, committed atomic.Int32
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	checked := make(chan struct{})
+	go func() {
+		defer close(checked)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			user := db.GetUser(1)
+			if user.AvailableGenerations < 0 || user.AvailableGenerations+user.ReservedGenerations+user.TotalGenerations != balance {
+				t.Errorf("баланс разошелся: доступно %d, в резерве %d, списано %d",
+					user.AvailableGenerations, user.ReservedGenerations, user.TotalGenerations)
+				return
+			}
+			runtime.Gosched()
+		}
+	}()
+
+	for worker := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for attempt := range attempts {
+				reserved, err := db.ReserveGeneration(1)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if !reserved {
+					continue
+				}
+				if n := inFlight.Add(1); n > balance {
+					t.Errorf("одновременно в работе %d генераций при балансе %d", n, balance)
+				}
+				runtime.Gosched()
+				inFlight.Add(-1)
+
+				// Часть генераций доставлена, остальные не удались
+				if (worker+attempt)%7 == 0 {
+					if err := db.CommitReservation(1); err != nil {
+						t.Error(err)
+					}
+					committed.Add(1)
+				} else if err := db.ReleaseReservation(1); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-checked
+
+	user := db.GetUser(1)
+	if committed.Load() > balance || int(committed.Load()) != user.TotalGenerations ||
+		user.ReservedGenerations != 0 || user.AvailableGenerations != balance-user.TotalGenerations {
+		t.Errorf("списано %d: доступно %d, в резерве %d, всего %d",
+			committed.Load(), user.AvailableGenerations, user.ReservedGenerations, user.TotalGenerations)
+	}
+}
+
+func TestSettleWithoutReservation(t *testing.T) {
+	db := newTestDatabase(t)
+	db.users[1] = &User{UserID: 1, AvailableGenerations: 2}
+
+	// Без резерва возврат ничего не меняет, а списание отказывает
+	if err := db.ReleaseReservation(1); err != nil {
+		t.Errorf("возврат без резерва: %v", err)
+	}
+	if err := db.CommitReservation(1); err == nil {
+		t.Error("списание без резерва прошло")
+	}
+	if err := db.ReleaseReservation(2); err != nil || db.HasUser(2) {
+		t.Errorf("возврат у неизвестного пользователя: %v, заведен %t", err, db.HasUser(2))
+	}
+	if user := db.GetUser(1); user.AvailableGenerations != 2 || user.TotalGenerations != 0 {
+		t.Errorf("баланс изменился: доступно %d, всего %d", user.AvailableGenerations, user.TotalGenerations)
+	}
+
+	// Резерв возвращается один раз
+	if reserved, err := db.ReserveGeneration(1); !reserved || err != nil {
+		t.Fatalf("резерв: %t, %v", reserved, err)
+	}
+	for range 2 {
+		if err := db.ReleaseReservation(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if user := db.GetUser(1); user.AvailableGenerations != 2 || user.ReservedGenerations != 0 {
+		t.Errorf("после двойного возврата: доступно %d, в резерве %d", user.AvailableGenerations, user.ReservedGenerations)
+	}
+}
+
+func TestLoadReleasesReservations(t *testing.T) {
+	db := newTestDatabase(t)
+	db.users[1] = &User{UserID: 1, AvailableGenerations: 3}
+	db.users[2] = &User{UserID: 2, AvailableGenerations: 1}
+	for _, userID := range []int64{1, 1, 2} {
+		if reserved, err := db.ReserveGeneration(userID); !reserved || err != nil {
+			t.Fatalf("резерв %d: %t, %v", userID, reserved, err)
+		}
+	}
+	if err := db.CommitReservation(1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Перезапуск прервал генерации: их резервы возвращаются, списанная остается списанной
+	reopened := NewDatabase(Config{File: "users.json"})
+	if err := reopened.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		userID                   int64
+		wantAvailable, wantTotal int
+	}{{1, 2, 1}, {2, 1, 0}} {
+		user := reopened.GetUser(tt.userID)
+		if user.AvailableGenerations != tt.wantAvailable || user.ReservedGenerations != 0 || user.TotalGenerations != tt.wantTotal {
+			t.Errorf("пользователь %d: доступно %d, в резерве %d, всего %d", tt.userID,
+				user.AvailableGenerations, user.ReservedGenerations, user.TotalGenerations)
+		}
+	}
+
+	// Возврат записан на диск
+	again := NewDatabase(Config{File: "users.json"})
+	if err := again.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if user := again.GetUser(1); user.AvailableGenerations != 2 || user.ReservedGenerations != 0 {
+		t.Errorf("после второго перезапуска: доступно %d, в резерве %d", user.AvailableGenerations, user.ReservedGenerations)
+	}
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// urlTopicPrefix так журнал генераций подписывает посты по ссылке: это не тема запроса
+const urlTopicPrefix = "ссылка:"
+
+// UserActivity активность пользователя за период для еженедельной сводки
+type UserActivity struct {
+	UserID int64
+	// Posts сколько постов получено за период
+	Posts int
+	// Topics самые частые темы запросов, от частых к редким
+	Topics []string
+	// Ratings сколько оценок поставлено, AverageRating — их среднее
+	Ratings       int
+	AverageRating float64
+	// AvailableGenerations и Premium состояние баланса на момент сбора сводки
+	AvailableGenerations int
+	Premium              bool
+	// Language язык сообщений бота пользователя
+	Language string
+}
+
+// SummaryRecipients собирает активность за [from, to) пользователей, которым положена
+// сводка: получивших не меньше minPosts постов, не отключивших сводку и не заблокировавших
+// бота. Самые активные идут первыми; у каждого не больше topics тем.
+func (db *Database) SummaryRecipients(from, to time.Time, minPosts, topics int) []UserActivity {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	inPeriod := func(t time.Time) bool { return !t.Before(from) && t.Before(to) }
+	posts := make(map[int64]int)
+	topicCounts := make(map[int64]map[string]int)
+	for _, generation := range db.generations {
+		if !generation.Succeeded() || !inPeriod(generation.Timestamp) {
+			continue
+		}
+		posts[generation.UserID]++
+
+		topic := strings.ToLower(strings.TrimSpace(generation.Keywords))
+		if generation.Outcome == OutcomeRewrite || topic == "" || strings.HasPrefix(topic, urlTopicPrefix) {
+			continue
+		}
+		if topicCounts[generation.UserID] == nil {
+			topicCounts[generation.UserID] = make(map[string]int)
+		}
+		topicCounts[generation.UserID][topic]++
+	}
+
+	ratings := make(map[int64][]int)
+	for _, rating := range db.ratings {
+		if inPeriod(rating.Timestamp) {
+			ratings[rating.UserID] = append(ratings[rating.UserID], rating.Rating)
+		}
+	}
+
+	now := time.Now()
+	var recipients []UserActivity
+	for userID, count := range posts {
+		user, exists := db.users[userID]
+		if !exists || !summaryEligible(user, count, minPosts) {
+			continue
+		}
+		activity := UserActivity{
+			UserID:               userID,
+			Posts:                count,
+			Topics:               topTopics(topicCounts[userID], topics),
+			Ratings:              len(ratings[userID]),
+			AvailableGenerations: user.AvailableGenerations,
+			Premium:              user.IsPremium(now),
+			Language:             user.Settings.InterfaceLanguage,
+		}
+		if activity.Ratings > 0 {
+			sum := 0
+			for _, rating := range ratings[userID] {
+				sum += rating
+			}
+			activity.AverageRating = float64(sum) / float64(activity.Ratings)
+		}
+		recipients = append(recipients, activity)
+	}
+
+	slices.SortFunc(recipients, func(a, b UserActivity) int {
+		if c := cmp.Compare(b.Posts, a.Posts); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.UserID, b.UserID)
+	})
+	return recipients
+}
+
+// summaryEligible положена ли сводка пользователю, получившему posts постов за неделю
+func summaryEligible(user *User, posts, minPosts int) bool {
+	return posts >= minPosts && !user.Settings.WeeklySummaryOff && !user.Blocked
+}
+
+// topTopics limit самых частых тем; при равной частоте — по алфавиту
+func topTopics(counts map[string]int, limit int) []string {
+	topics := make([]string, 0, len(counts))
+	for topic := range counts {
+		topics = append(topics, topic)
+	}
+	slices.SortFunc(topics, func(a, b string) int {
+		if c := cmp.Compare(counts[b], counts[a]); c != 0 {
+			return c
+		}
+		return strings.Compare(a, b)
+	})
+	if len(topics) > limit {
+		topics = topics[:limit]
+	}
+	return topics
+}
+
+// SetBlocked отмечает, что пользователь заблокировал бота или разблокировал его.
+// Неизвестный пользователь не создается.
+func (db *Database) SetBlocked(userID int64, blocked bool) error {
+	return db.WithBatch(func(tx *Batch) { tx.SetBlocked(userID, blocked) })
+}
+
+// IsBlocked сообщает, что пользователь заблокировал бота
+func (db *Database) IsBlocked(userID int64) bool {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	user, exists := db.users[userID]
+	return exists && user.Blocked
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSummaryRecipients(t *testing.T) {
+	db := newTestDatabase(t)
+	from := time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	generate := func(userID int64, keywords, outcome string, at time.Time) {
+		db.generations = append(db.generations, Generation{UserID: userID, Keywords: keywords, Outcome: outcome, Timestamp: at})
+	}
+	rate := func(userID int64, rating int, at time.Time) {
+		db.ratings = append(db.ratings, Rating{UserID: userID, Rating: rating, Timestamp: at})
+	}
+	settings := func(userID int64, update func(*Settings)) {
+		if _, err := db.UpdateSettings(userID, update); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Пользователь 1: четыре поста, темы по частоте, ссылка и рерайт темами не считаются
+	settings(1, func(s *Settings) { s.InterfaceLanguage = "en" })
+	generate(1, "Биткоин", OutcomeSuccess, from)
+	generate(1, " биткоин ", OutcomeSuccess, from.Add(time.Hour))
+	generate(1, "ссылка: https://example.com/a", OutcomeSuccess, from.Add(2*time.Hour))
+	generate(1, "текст пользователя", OutcomeRewrite, from.Add(3*time.Hour))
+	rate(1, 5, from.Add(time.Hour))
+	rate(1, 4, from.Add(2*time.Hour))
+	rate(1, 1, from.Add(-time.Hour))
+
+	// Пользователь 2: ровно три поста; старая запись без исхода — успешная
+	settings(2, func(*Settings) {})
+	generate(2, "ставка цб", "", from.Add(time.Hour))
+	generate(2, "нефть", OutcomeSuccess, from.Add(2*time.Hour))
+	generate(2, "золото", OutcomeSuccess, to.Add(-time.Nanosecond))
+
+	// Пользователь 3: третий пост не удался, четвертый — за пределами недели
+	settings(3, func(*Settings) {})
+	generate(3, "тема", OutcomeSuccess, from)
+	generate(3, "тема", OutcomeSuccess, from)
+	generate(3, "тема", OutcomeRefused, from)
+	generate(3, "тема", OutcomeDryRun, from)
+	generate(3, "тема", OutcomeSuccess, to)
+	generate(3, "тема", OutcomeSuccess, from.Add(-time.Nanosecond))
+
+	// Пользователь 4 отключил сводку, пользователь 5 заблокировал бота
+	settings(4, func(s *Settings) { s.WeeklySummaryOff = true })
+	settings(5, func(*Settings) {})
+	if err := db.SetBlocked(5, true); err != nil {
+		t.Fatal(err)
+	}
+	for _, userID := range []int64{4, 5} {
+		for range 5 {
+			generate(userID, "тема", OutcomeSuccess, from)
+		}
+	}
+
+	// Пользователя 6 нет в базе
+	for range 3 {
+		generate(6, "тема", OutcomeSuccess, from)
+	}
+
+	recipients := db.SummaryRecipients(from, to, 3, 2)
+	var ids []int64
+	for _, activity := range recipients {
+		ids = append(ids, activity.UserID)
+	}
+	if !slices.Equal(ids, []int64{1, 2}) {
+		t.Fatalf("получатели %v, ожидались [1 2]", ids)
+	}
+
+	first := recipients[0]
+	if first.Posts != 4 || first.Language != "en" || first.Ratings != 2 || first.AverageRating != 4.5 ||
+		first.AvailableGenerations != 10 || first.Premium {
+		t.Errorf("активность пользователя 1: %+v", first)
+	}
+	if !slices.Equal(first.Topics, []string{"биткоин"}) {
+		t.Errorf("темы пользователя 1: %q", first.Topics)
+	}
+	// При равной частоте темы идут по алфавиту и обрезаются до лимита
+	second := recipients[1]
+	if second.Posts != 3 || second.Ratings != 0 || second.AverageRating != 0 || !slices.Equal(second.Topics, []string{"золото", "нефть"}) {
+		t.Errorf("активность пользователя 2: %+v", second)
+	}
+}
+
+func TestSummaryRecipientsOrder(t *testing.T) {
+	db := newTestDatabase(t)
+	now := time.Date(2026, 10, 6, 12, 0, 0, 0, time.UTC)
+	for userID, posts := range map[int64]int{1: 3, 2: 5, 3: 3, 4: 4} {
+		if _, err := db.UpdateSettings(userID, func(*Settings) {}); err != nil {
+			t.Fatal(err)
+		}
+		for range posts {
+			db.generations = append(db.generations, Generation{UserID: userID, Keywords: "тема", Outcome: OutcomeSuccess, Timestamp: now})
+		}
+	}
+
+	// Самые активные первыми, при равенстве — по коду пользователя
+	var ids []int64
+	for _, activity := range db.SummaryRecipients(now.Add(-time.Hour), now.Add(time.Hour), 3, 3) {
+		ids = append(ids, activity.UserID)
+	}
+	if !slices.Equal(ids, []int64{2, 4, 1, 3}) {
+		t.Errorf("порядок %v, ожидался [2 4 1 3]", ids)
+	}
+}
@@ -0,0 +1,130 @@
+package diagnostics
+
+import (
+	"context"
+	"expvar"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"AIGenerator/internal/middleware"
+)
+
+const (
+	// defaultAddr адрес по умолчанию: только локальные подключения
+	defaultAddr = "127.0.0.1:6060"
+	// defaultGoroutineWarn число горутин, после которого пишется предупреждение
+	defaultGoroutineWarn = 1000
+	// goroutineCheckInterval как часто проверяется число горутин
+	goroutineCheckInterval = time.Minute
+)
+
+// Config настройки отладочных endpoints
+type Config struct {
+	// Enabled включает /debug/pprof и /debug/vars
+	Enabled bool
+	// Addr адрес отладочного сервера
+	Addr string
+	// GoroutineWarn порог числа горутин для предупреждения в лог; 0 — не проверять
+	GoroutineWarn int
+	// LocalOnly принимать запросы только с loopback-адресов, даже если Addr слушает
+	// внешний интерфейс
+	LocalOnly bool
+	// BasicAuthUser и BasicAuthPassword включают HTTP Basic; пустой пароль — без авторизации
+	BasicAuthUser     string
+	BasicAuthPassword string
+}
+
+// DefaultConfig возвращает настройки по умолчанию: endpoints выключены
+func DefaultConfig() Config {
+	return Config{Addr: defaultAddr, GoroutineWarn: defaultGoroutineWarn}
+}
+
+// publishOnce expvar не позволяет опубликовать одно имя дважды
+var publishOnce sync.Once
+
+// publishRuntime добавляет в /debug/vars число горутин и состояние кучи
+func publishRuntime() {
+	publishOnce.Do(func() {
+		expvar.Publish("goroutines", expvar.Func(func() any {
+			return runtime.NumGoroutine()
+		}))
+		expvar.Publish("heap", expvar.Func(func() any {
+			var stats runtime.MemStats
+			runtime.ReadMemStats(&stats)
+			return map[string]uint64{
+				"alloc_bytes":    stats.HeapAlloc,
+				"inuse_bytes":    stats.HeapInuse,
+				"objects":        stats.HeapObjects,
+				"sys_bytes":      stats.Sys,
+				"gc_cycles":      uint64(stats.NumGC),
+				"pause_total_ns": stats.PauseTotalNs,
+			}
+		}))
+	})
+}
+
+// Publish добавляет в /debug/vars значение, которое вычисляется при каждом запросе
+func Publish(name string, value func() any) {
+	expvar.Publish(name, expvar.Func(value))
+}
+
+// NewServer создает сервер с /debug/pprof/ и /debug/vars. Обработчики регистрируются
+// в собственном mux, а не в http.DefaultServeMux, чтобы они не попали на другие серверы.
+// Доступ можно ограничить локальными адресами и паролем, см. Config.
+// Если endpoints выключены, возвращает nil.
+func NewServer(config Config) *http.Server {
+	if !config.Enabled {
+		return nil
+	}
+	publishRuntime()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	var handler http.Handler = mux
+	if config.BasicAuthPassword != "" {
+		handler = middleware.BasicAuth("debug", config.BasicAuthUser, config.BasicAuthPassword)(handler)
+	}
+	if config.LocalOnly {
+		handler = middleware.LocalOnly()(handler)
+	}
+
+	return &http.Server{
+		Addr:              config.Addr,
+		Handler:           middleware.Standard("debug", handler),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+// WatchGoroutines раз в минуту сравнивает число горутин с порогом и пишет
+// предупреждение, если он превышен: так видны утечки горутин. Останавливается с ctx.
+func WatchGoroutines(ctx context.Context, threshold int) {
+	if threshold <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(goroutineCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if count := runtime.NumGoroutine(); count > threshold {
+					log.Printf("[DIAGNOSTICS] ⚠️ Горутин %d, порог %d: возможна утечка", count, threshold)
+				}
+			}
+		}
+	}()
+}
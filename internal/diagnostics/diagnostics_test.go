@@ -0,0 +1,103 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"AIGenerator/internal/health"
+)
+
+// get выполняет GET path на handler с адреса remoteAddr
+func get(handler http.Handler, path, remoteAddr string, configure ...func(*http.Request)) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	request.RemoteAddr = remoteAddr
+	for _, fn := range configure {
+		fn(request)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestDebugEndpointsAbsentWhenDisabled(t *testing.T) {
+	if server := NewServer(DefaultConfig()); server != nil {
+		t.Fatalf("сервер отладки создан при выключенном флаге: %s", server.Addr)
+	}
+
+	// net/http/pprof при импорте регистрируется в http.DefaultServeMux: остальные
+	// серверы бота пользуются своими mux, и отладка на них недоступна
+	handler := health.NewServer(0, health.NewChecker()).Handler
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/goroutine", "/debug/vars"} {
+		if code := get(handler, path, "127.0.0.1:1234").Code; code != http.StatusNotFound {
+			t.Errorf("%s на сервере проверок: код %d", path, code)
+		}
+	}
+}
+
+func TestDebugEndpointsWhenEnabled(t *testing.T) {
+	config := DefaultConfig()
+	config.Enabled = true
+	Publish("test_cache_size", func() any { return 42 })
+
+	handler := NewServer(config).Handler
+	// Повторное создание сервера не публикует переменные заново
+	NewServer(config)
+
+	if code := get(handler, "/debug/pprof/", "127.0.0.1:1234").Code; code != http.StatusOK {
+		t.Errorf("/debug/pprof/: код %d", code)
+	}
+
+	recorder := get(handler, "/debug/vars", "127.0.0.1:1234")
+	var vars map[string]any
+	if err := json.Unmarshal(recorder.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("/debug/vars не JSON: %v", err)
+	}
+	if goroutines, ok := vars["goroutines"].(float64); !ok || goroutines < 1 {
+		t.Errorf("goroutines = %v", vars["goroutines"])
+	}
+	if heap, ok := vars["heap"].(map[string]any); !ok || heap["alloc_bytes"] == nil {
+		t.Errorf("heap = %v", vars["heap"])
+	}
+	if vars["test_cache_size"] != float64(42) {
+		t.Errorf("test_cache_size = %v", vars["test_cache_size"])
+	}
+}
+
+func TestDebugEndpointsAccess(t *testing.T) {
+	withAuth := func(user, password string) func(*http.Request) {
+		return func(r *http.Request) { r.SetBasicAuth(user, password) }
+	}
+
+	tests := []struct {
+		name       string
+		localOnly  bool
+		password   string
+		remoteAddr string
+		auth       []func(*http.Request)
+		wantStatus int
+	}{
+		{"без ограничений", false, "", "192.0.2.1:1234", nil, http.StatusOK},
+		{"локальный адрес", true, "", "127.0.0.1:1234", nil, http.StatusOK},
+		{"внешний адрес", true, "", "192.0.2.1:1234", nil, http.StatusForbidden},
+		{"без пароля", false, "debug-secret", "127.0.0.1:1234", nil, http.StatusUnauthorized},
+		{"неверный пароль", false, "debug-secret", "127.0.0.1:1234", []func(*http.Request){withAuth("debug", "wrong")}, http.StatusUnauthorized},
+		{"верный пароль", false, "debug-secret", "127.0.0.1:1234", []func(*http.Request){withAuth("debug", "debug-secret")}, http.StatusOK},
+		{"пароль с внешнего адреса", true, "debug-secret", "192.0.2.1:1234", []func(*http.Request){withAuth("debug", "debug-secret")}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Enabled = true
+			config.LocalOnly = tt.localOnly
+			config.BasicAuthUser = "debug"
+			config.BasicAuthPassword = tt.password
+
+			if code := get(NewServer(config).Handler, "/debug/vars", tt.remoteAddr, tt.auth...).Code; code != tt.wantStatus {
+				t.Errorf("код %d, ожидался %d", code, tt.wantStatus)
+			}
+		})
+	}
+}
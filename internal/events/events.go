@@ -0,0 +1,497 @@
+package events
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultFile файл событий в DATA_DIR
+	DefaultFile = "events.jsonl"
+	// defaultBufferSize сколько событий может ждать записи
+	defaultBufferSize = 1000
+)
+
+// Type вид события
+type Type string
+
+// Виды событий
+const (
+	CommandReceived    Type = "command_received"
+	GenerationStarted  Type = "generation_started"
+	GenerationFinished Type = "generation_finished"
+	PaymentCreated     Type = "payment_created"
+	PaymentSucceeded   Type = "payment_succeeded"
+	RatingGiven        Type = "rating_given"
+	FeedbackLeft       Type = "feedback_left"
+)
+
+// Исходы генерации в GenerationFinished; совпадают с исходами журнала генераций
+const (
+	OutcomeSuccess  = "success"
+	OutcomeRejected = "rejected"
+	OutcomeFailed   = "failed"
+	OutcomeRewrite  = "rewrite"
+	OutcomeDryRun   = "dry_run"
+	// OutcomePreview пост показан в предпросмотре и ждет решения пользователя
+	OutcomePreview = "preview"
+)
+
+// Event событие аналитики. Заполняются только поля, относящиеся к виду события.
+type Event struct {
+	Type   Type      `json:"type"`
+	Time   time.Time `json:"time"`
+	UserID int64     `json:"user_id,omitempty"`
+	// Command команда без косой черты (CommandReceived)
+	Command string `json:"command,omitempty"`
+	// Topic тема генерации или оцененного поста
+	Topic   string `json:"topic,omitempty"`
+	Outcome string `json:"outcome,omitempty"`
+	// PaymentID, Package и Amount платеж, пакет генераций и сумма в рублях
+	PaymentID string `json:"payment_id,omitempty"`
+	Package   string `json:"package,omitempty"`
+	Amount    int    `json:"amount,omitempty"`
+	Rating    int    `json:"rating,omitempty"`
+	// RequestID, DurationMs и StagesMs код запроса генерации, ее длительность
+	// и длительность этапов в миллисекундах (GenerationFinished)
+	RequestID  string           `json:"request_id,omitempty"`
+	DurationMs int64            `json:"duration_ms,omitempty"`
+	StagesMs   map[string]int64 `json:"stages_ms,omitempty"`
+}
+
+// Config настройки журнала событий
+type Config struct {
+	// File файл событий: по строке JSON на событие, только дописывается
+	File string
+	// BufferSize сколько событий может ждать записи; лишние отбрасываются
+	BufferSize int
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() Config {
+	return Config{File: DefaultFile, BufferSize: defaultBufferSize}
+}
+
+// Counters показатели за период
+type Counters struct {
+	// Commands число команд по имени
+	Commands           map[string]int
+	GenerationsStarted int
+	// Generations успешные генерации, включая рерайт; тестовые не учитываются
+	Generations int
+	Rejected    int
+	Failed      int
+	// PaymentsCreated созданные платежи, Purchases и Revenue — успешные покупки и выручка по пакетам
+	PaymentsCreated int
+	Purchases       map[string]int
+	Revenue         map[string]int
+	TotalRevenue    int
+	Ratings         int
+	RatingSum       int
+	Feedback        int
+	// Durations длительности завершенных генераций в миллисекундах по этапам;
+	// общая длительность — под ключом TotalStage
+	Durations map[string][]int64
+}
+
+// TotalStage ключ общей длительности генерации в Counters.Durations
+const TotalStage = "total"
+
+func newCounters() *Counters {
+	return &Counters{
+		Commands:  make(map[string]int),
+		Purchases: make(map[string]int),
+		Revenue:   make(map[string]int),
+		Durations: make(map[string][]int64),
+	}
+}
+
+// Percentile длительность этапа stage, которую не превысили p процентов генераций;
+// false, если генераций с этим этапом не было
+func (c Counters) Percentile(stage string, p float64) (time.Duration, bool) {
+	samples := c.Durations[stage]
+	if len(samples) == 0 {
+		return 0, false
+	}
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	index := int(float64(len(sorted)-1) * p / 100)
+	return time.Duration(sorted[index]) * time.Millisecond, true
+}
+
+// AverageRating средняя оценка; 0, если оценок не было
+func (c Counters) AverageRating() float64 {
+	if c.Ratings == 0 {
+		return 0
+	}
+	return float64(c.RatingSum) / float64(c.Ratings)
+}
+
+// apply учитывает событие
+func (c *Counters) apply(event Event) {
+	switch event.Type {
+	case CommandReceived:
+		c.Commands[event.Command]++
+	case GenerationStarted:
+		c.GenerationsStarted++
+	case GenerationFinished:
+		switch event.Outcome {
+		case OutcomeSuccess, OutcomeRewrite:
+			c.Generations++
+		case OutcomeRejected:
+			c.Rejected++
+		case OutcomeFailed:
+			c.Failed++
+		}
+		if event.DurationMs > 0 {
+			c.Durations[TotalStage] = append(c.Durations[TotalStage], event.DurationMs)
+		}
+		for stage, ms := range event.StagesMs {
+			c.Durations[stage] = append(c.Durations[stage], ms)
+		}
+	case PaymentCreated:
+		c.PaymentsCreated++
+	case PaymentSucceeded:
+		c.Purchases[event.Package]++
+		c.Revenue[event.Package] += event.Amount
+		c.TotalRevenue += event.Amount
+	case RatingGiven:
+		c.Ratings++
+		c.RatingSum += event.Rating
+	case FeedbackLeft:
+		c.Feedback++
+	}
+}
+
+// add прибавляет показатели other
+func (c *Counters) add(other *Counters) {
+	for name, count := range other.Commands {
+		c.Commands[name] += count
+	}
+	c.GenerationsStarted += other.GenerationsStarted
+	c.Generations += other.Generations
+	c.Rejected += other.Rejected
+	c.Failed += other.Failed
+	c.PaymentsCreated += other.PaymentsCreated
+	for code, count := range other.Purchases {
+		c.Purchases[code] += count
+	}
+	for code, amount := range other.Revenue {
+		c.Revenue[code] += amount
+	}
+	c.TotalRevenue += other.TotalRevenue
+	c.Ratings += other.Ratings
+	c.RatingSum += other.RatingSum
+	c.Feedback += other.Feedback
+	for stage, samples := range other.Durations {
+		c.Durations[stage] = append(c.Durations[stage], samples...)
+	}
+}
+
+// Pipeline журнал событий. Обработчики отправляют события через Emit, не дожидаясь записи;
+// один потребитель дописывает их в файл по порядку и обновляет показатели по часам,
+// которые читает статистика. Методы безопасны для nil: тогда события не учитываются.
+type Pipeline struct {
+	config Config
+	queue  chan Event
+	done   chan struct{}
+
+	// closeMu не дает отправить событие в закрытую очередь
+	closeMu sync.RWMutex
+	closed  bool
+	dropped atomic.Int64
+
+	// mu защищает показатели и файл
+	mu     sync.RWMutex
+	hours  map[time.Time]*Counters
+	loaded int
+	file   *os.File
+	writer *bufio.Writer
+}
+
+// New создает журнал событий. Перед отправкой событий его нужно открыть через Open.
+func New(config Config) *Pipeline {
+	if config.BufferSize <= 0 {
+		config.BufferSize = defaultBufferSize
+	}
+	return &Pipeline{
+		config: config,
+		queue:  make(chan Event, config.BufferSize),
+		done:   make(chan struct{}),
+		hours:  make(map[time.Time]*Counters),
+	}
+}
+
+// Open восстанавливает показатели из файла событий, открывает его для дописывания
+// и запускает потребителя
+func (p *Pipeline) Open() error {
+	if err := p.replay(); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(p.config.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла событий: %w", err)
+	}
+	p.file = file
+	p.writer = bufio.NewWriter(file)
+
+	go p.run()
+	return nil
+}
+
+// replay читает накопленные события
+func (p *Pipeline) replay() error {
+	file, err := os.Open(p.config.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка открытия файла событий: %w", err)
+	}
+	defer file.Close()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event Event
+		// Оборванная при сбое строка не должна мешать чтению остальных
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		p.apply(event)
+		p.loaded++
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ошибка чтения файла событий: %w", err)
+	}
+	return nil
+}
+
+// Loaded сколько событий прочитано из файла при открытии
+func (p *Pipeline) Loaded() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.loaded
+}
+
+// Import записывает события прошлых периодов, например перенесенные из базы при первом
+// запуске. В отличие от Emit пишет сразу и возвращает ошибку записи.
+func (p *Pipeline) Import(events []Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, event := range events {
+		if err := p.write(event); err != nil {
+			return err
+		}
+		p.apply(event)
+	}
+	if err := p.writer.Flush(); err != nil {
+		return fmt.Errorf("ошибка записи событий: %w", err)
+	}
+	return nil
+}
+
+// Emit ставит событие в очередь. Не блокирует: при переполненной очереди событие
+// отбрасывается и учитывается в Dropped.
+func (p *Pipeline) Emit(event Event) {
+	if p == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	p.closeMu.RLock()
+	defer p.closeMu.RUnlock()
+	if p.closed {
+		return
+	}
+	select {
+	case p.queue <- event:
+	default:
+		if p.dropped.Add(1) == 1 {
+			log.Printf("[EVENTS] ⚠️ Очередь событий переполнена, события отбрасываются")
+		}
+	}
+}
+
+// Dropped сколько событий отброшено из-за переполненной очереди
+func (p *Pipeline) Dropped() int64 {
+	if p == nil {
+		return 0
+	}
+	return p.dropped.Load()
+}
+
+// run записывает события по порядку. Буфер сбрасывается в файл, когда очередь опустела.
+func (p *Pipeline) run() {
+	defer close(p.done)
+	for event := range p.queue {
+		p.mu.Lock()
+		if err := p.write(event); err != nil {
+			log.Printf("[EVENTS] ❌ %v", err)
+		}
+		p.apply(event)
+		if len(p.queue) == 0 {
+			if err := p.writer.Flush(); err != nil {
+				log.Printf("[EVENTS] ❌ Ошибка записи событий: %v", err)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// write дописывает событие в буфер файла. Вызывается под mu.
+func (p *Pipeline) write(event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("ошибка кодирования события: %w", err)
+	}
+	data = append(data, '\n')
+	if _, err := p.writer.Write(data); err != nil {
+		return fmt.Errorf("ошибка записи события: %w", err)
+	}
+	return nil
+}
+
+// apply учитывает событие в показателях его часа. Вызывается под mu.
+func (p *Pipeline) apply(event Event) {
+	hour := event.Time.UTC().Truncate(time.Hour)
+	counters, ok := p.hours[hour]
+	if !ok {
+		counters = newCounters()
+		p.hours[hour] = counters
+	}
+	counters.apply(event)
+}
+
+// Stats возвращает показатели за [from, to) с точностью до часа; нулевой from — с начала
+func (p *Pipeline) Stats(from, to time.Time) Counters {
+	total := newCounters()
+	if p == nil {
+		return *total
+	}
+	from = from.UTC().Truncate(time.Hour)
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for hour, counters := range p.hours {
+		if !hour.Before(from) && hour.Before(to) {
+			total.add(counters)
+		}
+	}
+	return *total
+}
+
+// Close перестает принимать события, дописывает очередь и закрывает файл.
+// Ждет не дольше timeout; возвращает false, если не успел.
+func (p *Pipeline) Close(timeout time.Duration) bool {
+	if p == nil {
+		return true
+	}
+	p.closeMu.Lock()
+	if p.closed {
+		p.closeMu.Unlock()
+		return true
+	}
+	p.closed = true
+	close(p.queue)
+	p.closeMu.Unlock()
+
+	p.mu.RLock()
+	opened := p.file != nil
+	p.mu.RUnlock()
+	if !opened {
+		return true
+	}
+
+	select {
+	case <-p.done:
+	case <-time.After(timeout):
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.writer.Flush(); err != nil {
+		log.Printf("[EVENTS] ❌ Ошибка записи событий: %v", err)
+	}
+	if err := p.file.Close(); err != nil {
+		log.Printf("[EVENTS] ❌ Ошибка закрытия файла событий: %v", err)
+	}
+	if dropped := p.dropped.Load(); dropped > 0 {
+		log.Printf("[EVENTS] ⚠️ Отброшено событий из-за переполненной очереди: %d", dropped)
+	}
+	return true
+}
+
+// Anonymize убирает из файла событий пользователя userID и темы его генераций.
+// Показатели не меняются: в них нет ни пользователей, ни тем. Возвращает число
+// измененных событий.
+func (p *Pipeline) Anonymize(userID int64) (int, error) {
+	if p == nil {
+		return 0, nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.file == nil {
+		return 0, nil
+	}
+	if err := p.writer.Flush(); err != nil {
+		return 0, fmt.Errorf("ошибка записи событий: %w", err)
+	}
+
+	data, err := os.ReadFile(p.config.File)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка чтения файла событий: %w", err)
+	}
+	var rewritten []byte
+	changed := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		var event Event
+		if json.Unmarshal(line, &event) != nil || event.UserID != userID {
+			rewritten = append(rewritten, line...)
+			continue
+		}
+		event.UserID, event.Topic = 0, ""
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			return 0, fmt.Errorf("ошибка кодирования события: %w", err)
+		}
+		rewritten = append(append(rewritten, encoded...), '\n')
+		changed++
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+
+	// Файл подменяется целиком, затем дописывание продолжается в новый файл
+	temp := p.config.File + ".tmp"
+	if err := os.WriteFile(temp, rewritten, 0o644); err != nil {
+		return 0, fmt.Errorf("ошибка записи файла событий: %w", err)
+	}
+	if err := os.Rename(temp, p.config.File); err != nil {
+		return 0, fmt.Errorf("ошибка замены файла событий: %w", err)
+	}
+	file, err := os.OpenFile(p.config.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return changed, fmt.Errorf("ошибка открытия файла событий: %w", err)
+	}
+	p.file.Close()
+	p.file = file
+	p.writer = bufio.NewWriter(file)
+	return changed, nil
+}
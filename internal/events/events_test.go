@@ -0,0 +1,252 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// openPipeline открывает журнал событий во временном каталоге
+func openPipeline(t *testing.T, bufferSize int) (*Pipeline, string) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), DefaultFile)
+	p := New(Config{File: file, BufferSize: bufferSize})
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close(time.Second) })
+	return p, file
+}
+
+// readEvents читает события из файла
+func readEvents(t *testing.T, file string) []Event {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("строка не JSON: %q", scanner.Text())
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestEventsWrittenInOrder(t *testing.T) {
+	p, file := openPipeline(t, 1000)
+
+	for i := 0; i < 500; i++ {
+		p.Emit(Event{Type: CommandReceived, UserID: int64(i), Command: fmt.Sprintf("cmd%d", i)})
+	}
+	if !p.Close(time.Second) {
+		t.Fatal("журнал не дописан")
+	}
+
+	events := readEvents(t, file)
+	if len(events) != 500 {
+		t.Fatalf("записано %d событий из 500", len(events))
+	}
+	for i, event := range events {
+		if event.UserID != int64(i) || event.Command != fmt.Sprintf("cmd%d", i) || event.Time.IsZero() {
+			t.Fatalf("событие %d: %+v", i, event)
+		}
+	}
+	if dropped := p.Dropped(); dropped != 0 {
+		t.Errorf("отброшено %d", dropped)
+	}
+}
+
+func TestEmitDropsOnOverflow(t *testing.T) {
+	file := filepath.Join(t.TempDir(), DefaultFile)
+	// Потребитель еще не запущен: очередь на два события заполняется сразу
+	p := New(Config{File: file, BufferSize: 2})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 5; i++ {
+			p.Emit(Event{Type: RatingGiven, Rating: i + 1})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Emit заблокировал обработчик")
+	}
+	if dropped := p.Dropped(); dropped != 3 {
+		t.Errorf("отброшено %d, ожидалось 3", dropped)
+	}
+
+	// Принятые события записываются в порядке отправки
+	if err := p.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Close(time.Second) {
+		t.Fatal("журнал не дописан")
+	}
+	events := readEvents(t, file)
+	if len(events) != 2 || events[0].Rating != 1 || events[1].Rating != 2 {
+		t.Errorf("записаны %+v", events)
+	}
+}
+
+func TestEmitAfterClose(t *testing.T) {
+	p, file := openPipeline(t, 10)
+	p.Emit(Event{Type: FeedbackLeft, UserID: 1})
+	p.Close(time.Second)
+
+	// События после закрытия молча отбрасываются, повторное закрытие безопасно
+	p.Emit(Event{Type: FeedbackLeft, UserID: 2})
+	if !p.Close(time.Second) {
+		t.Error("повторное закрытие")
+	}
+	if events := readEvents(t, file); len(events) != 1 {
+		t.Errorf("записаны %+v", events)
+	}
+}
+
+func TestNilPipeline(t *testing.T) {
+	var p *Pipeline
+	p.Emit(Event{Type: CommandReceived})
+	if p.Dropped() != 0 || p.Loaded() != 0 || !p.Close(time.Second) {
+		t.Error("nil-журнал")
+	}
+	if stats := p.Stats(time.Time{}, time.Now()); stats.Generations != 0 || stats.Commands == nil {
+		t.Errorf("показатели nil-журнала: %+v", stats)
+	}
+	if changed, err := p.Anonymize(1); changed != 0 || err != nil {
+		t.Errorf("Anonymize: %d, %v", changed, err)
+	}
+}
+
+func TestStatsAggregatesByHour(t *testing.T) {
+	p, file := openPipeline(t, 100)
+	base := time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+
+	emitted := []Event{
+		{Type: CommandReceived, Time: base, Command: "generate"},
+		{Type: GenerationStarted, Time: base},
+		{Type: GenerationFinished, Time: base.Add(5 * time.Minute), Outcome: OutcomeSuccess, DurationMs: 4000,
+			StagesMs: map[string]int64{"search": 1000, "ai": 3000}},
+		{Type: GenerationFinished, Time: base.Add(10 * time.Minute), Outcome: OutcomeRejected},
+		{Type: GenerationFinished, Time: base.Add(15 * time.Minute), Outcome: OutcomeDryRun},
+		{Type: PaymentCreated, Time: base.Add(time.Hour)},
+		{Type: PaymentSucceeded, Time: base.Add(time.Hour), Package: "25", Amount: 400},
+		{Type: RatingGiven, Time: base.Add(2 * time.Hour), Rating: 5},
+		{Type: RatingGiven, Time: base.Add(2 * time.Hour), Rating: 2},
+	}
+	for _, event := range emitted {
+		p.Emit(event)
+	}
+	p.Close(time.Second)
+
+	tests := []struct {
+		name             string
+		from, to         time.Time
+		wantGenerations  int
+		wantRevenue      int
+		wantRatings      int
+		wantCommandCount int
+	}{
+		{"все время", time.Time{}, base.Add(24 * time.Hour), 1, 400, 2, 1},
+		{"первый час", base, base.Add(time.Hour), 1, 0, 0, 1},
+		{"второй час", base.Add(time.Hour), base.Add(2 * time.Hour), 0, 400, 0, 0},
+		// Начало периода округляется до часа
+		{"с середины первого часа", base.Add(30 * time.Minute), base.Add(3 * time.Hour), 1, 400, 2, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := p.Stats(tt.from, tt.to)
+			if stats.Generations != tt.wantGenerations || stats.TotalRevenue != tt.wantRevenue ||
+				stats.Ratings != tt.wantRatings || stats.Commands["generate"] != tt.wantCommandCount {
+				t.Errorf("показатели %+v", stats)
+			}
+		})
+	}
+
+	stats := p.Stats(time.Time{}, base.Add(24*time.Hour))
+	// Тестовые генерации не считаются ни успешными, ни неудачными
+	if stats.GenerationsStarted != 1 || stats.Rejected != 1 || stats.Failed != 0 {
+		t.Errorf("исходы генераций: %+v", stats)
+	}
+	if stats.Purchases["25"] != 1 || stats.Revenue["25"] != 400 || stats.PaymentsCreated != 1 || stats.AverageRating() != 3.5 {
+		t.Errorf("покупки и оценки: %+v", stats)
+	}
+
+	// После перезапуска показатели восстанавливаются из файла, испорченная строка пропускается
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"type": "rating_gi`)
+	f.Close()
+
+	reopened := New(Config{File: file})
+	if err := reopened.Open(); err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close(time.Second)
+	if reopened.Loaded() != len(emitted) {
+		t.Errorf("прочитано %d событий из %d", reopened.Loaded(), len(emitted))
+	}
+	if restored := reopened.Stats(time.Time{}, base.Add(24*time.Hour)); restored.Generations != 1 || restored.TotalRevenue != 400 ||
+		restored.Ratings != 2 {
+		t.Errorf("показатели после перезапуска: %+v", restored)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	counters := newCounters()
+	for _, ms := range []int64{900, 100, 500, 300, 700} {
+		counters.apply(Event{Type: GenerationFinished, Outcome: OutcomeSuccess, DurationMs: ms, StagesMs: map[string]int64{"ai": ms / 2}})
+	}
+
+	tests := []struct {
+		stage string
+		p     float64
+		want  time.Duration
+	}{
+		{TotalStage, 0, 100 * time.Millisecond},
+		{TotalStage, 50, 500 * time.Millisecond},
+		{TotalStage, 100, 900 * time.Millisecond},
+		{"ai", 50, 250 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got, ok := counters.Percentile(tt.stage, tt.p); !ok || got != tt.want {
+			t.Errorf("Percentile(%s, %v) = %v, %t; ожидалось %v", tt.stage, tt.p, got, ok, tt.want)
+		}
+	}
+	if _, ok := counters.Percentile("search", 50); ok {
+		t.Error("процентиль этапа без генераций")
+	}
+}
+
+func TestImport(t *testing.T) {
+	p, file := openPipeline(t, 10)
+	past := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if err := p.Import([]Event{
+		{Type: PaymentSucceeded, Time: past, Package: "10", Amount: 199},
+		{Type: GenerationFinished, Time: past, Outcome: OutcomeSuccess},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// Импорт пишется сразу, не дожидаясь закрытия
+	if events := readEvents(t, file); len(events) != 2 {
+		t.Errorf("записано %d событий", len(events))
+	}
+	if stats := p.Stats(past, past.Add(time.Hour)); stats.TotalRevenue != 199 || stats.Generations != 1 {
+		t.Errorf("показатели %+v", stats)
+	}
+}
@@ -0,0 +1,433 @@
+// Package generator готовит посты независимо от интерфейса, через который их заказали:
+// резервирует генерацию, проверяет тему, ищет новости или загружает страницу по ссылке,
+// выбирает статью и пишет пост моделью. Доставка поста остается за вызывающим: после нее
+// он списывает генерацию (Reservation.Commit), а если пост не дошел — возвращает ее
+// (Reservation.Release). Ход генерации сообщается через Progress.
+package generator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/news"
+)
+
+const (
+	// searchArticles сколько статей ищется для обычного выбора
+	searchArticles = 5
+	// rerankCandidates сколько лучших статей предлагается модели для выбора
+	rerankCandidates = 8
+	// rerankSkipScore релевантность, при которой выбор эвристики не перепроверяется
+	rerankSkipScore = 85.0
+	// defaultPageTitle заголовок страницы, у которой его нет
+	defaultPageTitle = "Новость с сайта"
+)
+
+// Stage этап генерации
+type Stage string
+
+// Этапы генерации в порядке прохождения
+const (
+	StageBalance    Stage = "balance"
+	StageModeration Stage = "moderation"
+	StageFetch      Stage = "fetch"
+	StageSelect     Stage = "select"
+	StageAI         Stage = "ai"
+)
+
+// NewsSearcher ищет статьи по запросу. Реализуется *news.NewsAggregator.
+type NewsSearcher interface {
+	FindRelevantArticles(ctx context.Context, keywords string, maxArticles int, opts news.SearchOptions) ([]news.Article, news.SearchDiagnostics, error)
+}
+
+// Page страница, загруженная по ссылке пользователя
+type Page struct {
+	Title    string
+	Content  string
+	ImageURL string
+}
+
+// PageFetcher загружает страницу по ссылке
+type PageFetcher interface {
+	FetchPage(ctx context.Context, url string) (Page, error)
+}
+
+// PageFetcherFunc функция, загружающая страницу, как PageFetcher
+type PageFetcherFunc func(ctx context.Context, url string) (Page, error)
+
+// FetchPage вызывает f
+func (f PageFetcherFunc) FetchPage(ctx context.Context, url string) (Page, error) {
+	return f(ctx, url)
+}
+
+// Writer методы модели, которыми пользуется генерация. Реализуется ai.TextGenerator.
+type Writer interface {
+	CheckTopic(ctx context.Context, keywords string) ai.TopicCheck
+	RerankArticles(ctx context.Context, query string, candidates []ai.ArticleInfo) (ai.Rerank, error)
+	GeneratePostStream(ctx context.Context, keywords string, article ai.ArticleInfo, partial chan<- string) (ai.Post, error)
+	GeneratePostFromURLStream(ctx context.Context, title, content string, partial chan<- string) (ai.Post, error)
+	IsRefusal(ctx context.Context, text string) bool
+}
+
+// Store баланс генераций пользователей. Реализуется *database.Database.
+type Store interface {
+	ReserveGeneration(userID int64) (bool, error)
+	CommitReservation(userID int64) error
+	ReleaseReservation(userID int64) error
+}
+
+// Progress получает ход генерации; бот показывает его правками сообщения прогресса.
+// Методы вызываются из горутины генерации по порядку.
+type Progress interface {
+	// Stage сообщает о начале этапа. Этапы после StageBalance начинаются, только
+	// если генерация зарезервирована.
+	Stage(stage Stage)
+	// Searched сообщает итог поиска новостей: сколько статей нашлось и сколько длился поиск
+	Searched(found int, elapsed time.Duration, diag news.SearchDiagnostics)
+	// Writing вызывается перед написанием поста и возвращает канал, в который модель
+	// пишет начало поста по мере готовности; модель закрывает его, когда пост готов.
+	// nil — ход написания не нужен.
+	Writing() chan<- string
+}
+
+// Options параметры генерации
+type Options struct {
+	// Search период и источники поиска новостей
+	Search news.SearchOptions
+	// SmartSelection статью выбирает модель из нескольких лучших кандидатов
+	SmartSelection bool
+	// DryRun тестовая генерация: ничего не резервирует и не списывает
+	DryRun bool
+	// Progress получает ход генерации; nil — ход никому не нужен
+	Progress Progress
+}
+
+// Result готовый пост
+type Result struct {
+	Post ai.Post
+	// Article статья, по которой написан пост; у поста по ссылке — загруженная страница
+	Article news.Article
+	// ImageURL картинка для поста: картинка статьи или главная картинка страницы
+	ImageURL string
+	// Found сколько подходящих статей нашлось; у поста по ссылке 0
+	Found int
+	// Diagnostics диагностика поиска новостей
+	Diagnostics news.SearchDiagnostics
+	// Cost сколько генераций стоит пост: 0 — тестовая генерация или пост из кэша
+	Cost int
+	// Reservation генерация, зарезервированная под пост: ее нужно списать после доставки
+	// или вернуть. nil, если пост ничего не стоит.
+	Reservation *Reservation
+}
+
+// ErrNoGenerations у пользователя нет доступных генераций
+var ErrNoGenerations = errors.New("нет доступных генераций")
+
+// ErrEmptyPost модель вернула пустой пост
+var ErrEmptyPost = errors.New("получен пустой пост")
+
+// QueryError запрос не разбирается: генерация не начиналась
+type QueryError struct {
+	Err error
+}
+
+func (e *QueryError) Error() string {
+	return "ошибка разбора запроса: " + e.Err.Error()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// TopicRejectedError тема отклонена модерацией
+type TopicRejectedError struct {
+	Check ai.TopicCheck
+}
+
+func (e *TopicRejectedError) Error() string {
+	return fmt.Sprintf("тема отклонена (%s): %s", e.Check.Category, e.Check.Reason)
+}
+
+// NoNewsError по запросу не нашлось подходящих статей
+type NoNewsError struct {
+	Diagnostics news.SearchDiagnostics
+}
+
+func (e *NoNewsError) Error() string {
+	return "не найдено новостей по запросу"
+}
+
+// RefusedError модель отказалась писать пост
+type RefusedError struct {
+	Reason string
+}
+
+func (e *RefusedError) Error() string {
+	return "модель отказалась писать пост: " + e.Reason
+}
+
+// StageError ошибка поиска новостей или загрузки страницы (StageFetch) либо модели
+// (StageAI). Если истек контекст генерации, Err — ошибка контекста.
+type StageError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *StageError) Error() string {
+	return fmt.Sprintf("этап %s: %v", e.Stage, e.Err)
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Service генерация постов
+type Service struct {
+	news   NewsSearcher
+	pages  PageFetcher
+	writer Writer
+	store  Store
+}
+
+// New создает генерацию постов
+func New(searcher NewsSearcher, pages PageFetcher, writer Writer, store Store) *Service {
+	return &Service{news: searcher, pages: pages, writer: writer, store: store}
+}
+
+// GenerateFromKeywords пишет пост по новости, найденной по запросу keywords.
+// При ошибке зарезервированная генерация уже возвращена.
+func (s *Service) GenerateFromKeywords(ctx context.Context, userID int64, keywords string, opts Options) (result *Result, err error) {
+	progress := progressOf(opts)
+	progress.Stage(StageBalance)
+
+	// Проверяем синтаксис запроса до любых затрат
+	if _, err := news.ParseQuery(keywords); err != nil {
+		return nil, &QueryError{Err: err}
+	}
+
+	reservation, err := s.reserve(userID, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			reservation.Release()
+		}
+	}()
+
+	// Проверяем тему до поиска новостей, чтобы не тратить время на запрещенные темы
+	progress.Stage(StageModeration)
+	if check := s.writer.CheckTopic(ctx, keywords); !check.Allowed {
+		log.Printf("[GENERATE] ⛔ Тема отклонена для %d (%s): %s", userID, check.Category, keywords)
+		return nil, &TopicRejectedError{Check: check}
+	}
+
+	progress.Stage(StageFetch)
+	log.Printf("[GENERATE] Шаг 2/3: Поиск новостей...")
+	// Для выбора с помощью AI берем больше кандидатов
+	maxArticles := searchArticles
+	if opts.SmartSelection {
+		maxArticles = rerankCandidates
+	}
+	searchStarted := time.Now()
+	articles, diag, err := s.news.FindRelevantArticles(ctx, keywords, maxArticles, opts.Search)
+	progress.Searched(len(articles), time.Since(searchStarted), diag)
+	log.Printf("[GENERATE] Диагностика поиска для %d: %+v", userID, diag)
+	if ctx.Err() != nil {
+		log.Printf("[GENERATE] ⏱ Превышен лимит времени при поиске новостей: %s", keywords)
+		return nil, &StageError{Stage: StageFetch, Err: ctx.Err()}
+	}
+	if err != nil {
+		log.Printf("[GENERATE] ❌ Ошибка при поиске новостей: %v", err)
+		return nil, &StageError{Stage: StageFetch, Err: err}
+	}
+	log.Printf("[GENERATE] Найдено %d статей", len(articles))
+	if len(articles) == 0 {
+		log.Printf("[GENERATE] ❌ Не найдено новостей по запросу: %s", keywords)
+		return nil, &NoNewsError{Diagnostics: diag}
+	}
+
+	progress.Stage(StageSelect)
+	selected := s.selectArticle(ctx, keywords, articles, diag.TopScore, opts.SmartSelection)
+	log.Printf("[GENERATE] Шаг 3/3: Выбрана статья: %s", selected.Title)
+
+	progress.Stage(StageAI)
+	log.Printf("[GENERATE] Генерация поста через AI...")
+	ctx = ai.WithAuditArticle(ctx, selected.URL)
+	post, err := s.writer.GeneratePostStream(ctx, keywords, ai.ArticleInfo{
+		Title:    selected.Title,
+		Summary:  selected.Summary,
+		URL:      selected.URL,
+		Source:   selected.Source,
+		ImageURL: selected.ImageURL,
+	}, progress.Writing())
+	if err := s.checkPost(ctx, post, err, keywords); err != nil {
+		return nil, err
+	}
+
+	result = &Result{
+		Post:        post,
+		Article:     selected,
+		ImageURL:    selected.ImageURL,
+		Found:       len(articles),
+		Diagnostics: diag,
+	}
+	s.settle(userID, result, reservation)
+	return result, nil
+}
+
+// GenerateFromURL пишет пост по странице url. При ошибке зарезервированная генерация
+// уже возвращена.
+func (s *Service) GenerateFromURL(ctx context.Context, userID int64, url string, opts Options) (result *Result, err error) {
+	progress := progressOf(opts)
+	progress.Stage(StageBalance)
+
+	reservation, err := s.reserve(userID, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			reservation.Release()
+		}
+	}()
+
+	progress.Stage(StageFetch)
+	page, err := s.pages.FetchPage(ctx, url)
+	if err != nil && ctx.Err() != nil {
+		log.Printf("[GENERATE] ⏱ Превышен лимит времени при загрузке страницы: %s", url)
+		return nil, &StageError{Stage: StageFetch, Err: ctx.Err()}
+	}
+	if err != nil {
+		log.Printf("[GENERATE] ❌ Ошибка получения содержимого: %v", err)
+		return nil, &StageError{Stage: StageFetch, Err: err}
+	}
+	if page.Title == "" {
+		page.Title = defaultPageTitle
+	}
+
+	progress.Stage(StageAI)
+	log.Printf("[GENERATE] Генерация поста через AI...")
+	post, err := s.writer.GeneratePostFromURLStream(ai.WithAuditArticle(ctx, url), page.Title, page.Content, progress.Writing())
+	if err := s.checkPost(ctx, post, err, url); err != nil {
+		return nil, err
+	}
+
+	result = &Result{
+		Post:     post,
+		Article:  news.Article{Title: page.Title, URL: url, Content: page.Content, ImageURL: page.ImageURL},
+		ImageURL: page.ImageURL,
+	}
+	s.settle(userID, result, reservation)
+	return result, nil
+}
+
+// reserve резервирует генерацию до поиска новостей и работы модели: параллельный запрос
+// того же пользователя не сможет потратить ее второй раз. Тестовая генерация ничего
+// не резервирует.
+func (s *Service) reserve(userID int64, opts Options) (*Reservation, error) {
+	if opts.DryRun {
+		return nil, nil
+	}
+	reservation, ok := Reserve(s.store, userID)
+	if !ok {
+		return nil, ErrNoGenerations
+	}
+	return reservation, nil
+}
+
+// checkPost проверяет ответ модели на запрос topic: ошибку, отказ и пустой пост
+func (s *Service) checkPost(ctx context.Context, post ai.Post, err error, topic string) error {
+	if err != nil && ctx.Err() != nil {
+		log.Printf("[GENERATE] ⏱ Превышен лимит времени при генерации поста: %s", topic)
+		return &StageError{Stage: StageAI, Err: ctx.Err()}
+	}
+	if err != nil {
+		log.Printf("[GENERATE] ❌ Ошибка генерации поста для %s: %v", topic, err)
+		return &StageError{Stage: StageAI, Err: err}
+	}
+
+	// Модель сама сообщает об отказе; фразы-маркеры остаются запасной проверкой
+	if post.Refused || s.writer.IsRefusal(ctx, post.Text()) {
+		if post.RefusalReason != "" {
+			log.Printf("[GENERATE] Причина отказа: %s", post.RefusalReason)
+		}
+		log.Printf("[GENERATE] ❌ GPT отказался генерировать пост для %s", topic)
+		return &RefusedError{Reason: post.RefusalReason}
+	}
+
+	if strings.TrimSpace(post.Text()) == "" {
+		log.Printf("[GENERATE] ❌ Получен пустой пост")
+		return ErrEmptyPost
+	}
+
+	log.Printf("[GENERATE] Пост сгенерирован, длина: %d символов", len(post.Text()))
+	return nil
+}
+
+// settle назначает готовому посту цену. Пост из кэша ничего не стоит: его резерв
+// возвращается сразу.
+func (s *Service) settle(userID int64, result *Result, reservation *Reservation) {
+	if reservation == nil {
+		return
+	}
+	if FreePost(result.Post) {
+		log.Printf("[GENERATE] Пост для %d взят из кэша, генерация не списывается", userID)
+		reservation.Release()
+		return
+	}
+	result.Cost, result.Reservation = 1, reservation
+}
+
+// FreePost пост из кэша, за который генерация не списывается
+func FreePost(post ai.Post) bool {
+	return post.Cached && !ai.ChargeCachedPosts()
+}
+
+// selectArticle выбирает статью для поста. С включенным умным выбором статью выбирает модель;
+// при ошибке модели, а также когда лучшая статья и так очень релевантна, работает эвристика.
+func (s *Service) selectArticle(ctx context.Context, keywords string, articles []news.Article, topScore float64, smart bool) news.Article {
+	if smart && len(articles) > 1 && topScore < rerankSkipScore {
+		candidates := make([]ai.ArticleInfo, len(articles))
+		for i, article := range articles {
+			candidates[i] = ai.ArticleInfo{Title: article.Title, Summary: article.Summary}
+		}
+
+		choice, err := s.writer.RerankArticles(ctx, keywords, candidates)
+		if err == nil {
+			log.Printf("[GENERATE] AI выбрал статью %d: %s (%s)", choice.Index+1, articles[choice.Index].Title, choice.Reason)
+			return articles[choice.Index]
+		}
+		log.Printf("[GENERATE] ⚠️ Выбор статьи через AI не удался, используем эвристику: %v", err)
+	}
+
+	// Выбираем статью с изображением, если есть
+	for _, article := range articles {
+		if article.ImageURL != "" {
+			return article
+		}
+	}
+
+	// Если нет статьи с изображением, берем первую
+	return articles[0]
+}
+
+// progressOf получатель хода генерации; без него ход никуда не сообщается
+func progressOf(opts Options) Progress {
+	if opts.Progress == nil {
+		return noProgress{}
+	}
+	return opts.Progress
+}
+
+// noProgress ход генерации, который никому не нужен
+type noProgress struct{}
+
+func (noProgress) Stage(Stage)                                         {}
+func (noProgress) Searched(int, time.Duration, news.SearchDiagnostics) {}
+func (noProgress) Writing() chan<- string                              { return nil }
@@ -0,0 +1,346 @@
+package generator
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"AIGenerator/internal/ai"
+	"AIGenerator/internal/news"
+)
+
+// fakeWriter модель с заранее заданными ответами
+type fakeWriter struct {
+	check     ai.TopicCheck
+	rerank    ai.Rerank
+	rerankErr error
+	post      ai.Post
+	postErr   error
+	refusal   bool
+
+	reranked   int
+	candidates []ai.ArticleInfo
+	written    int
+	article    ai.ArticleInfo
+}
+
+func (w *fakeWriter) CheckTopic(ctx context.Context, keywords string) ai.TopicCheck {
+	return w.check
+}
+
+func (w *fakeWriter) RerankArticles(ctx context.Context, query string, candidates []ai.ArticleInfo) (ai.Rerank, error) {
+	w.reranked++
+	w.candidates = candidates
+	return w.rerank, w.rerankErr
+}
+
+func (w *fakeWriter) GeneratePostStream(ctx context.Context, keywords string, article ai.ArticleInfo, partial chan<- string) (ai.Post, error) {
+	w.written++
+	w.article = article
+	if partial != nil {
+		close(partial)
+	}
+	return w.post, w.postErr
+}
+
+func (w *fakeWriter) GeneratePostFromURLStream(ctx context.Context, title, content string, partial chan<- string) (ai.Post, error) {
+	w.written++
+	w.article = ai.ArticleInfo{Title: title}
+	if partial != nil {
+		close(partial)
+	}
+	return w.post, w.postErr
+}
+
+func (w *fakeWriter) IsRefusal(ctx context.Context, text string) bool {
+	return w.refusal
+}
+
+var candidateArticles = []news.Article{
+	{Title: "Без картинки", Summary: "Первая"},
+	{Title: "С картинкой", Summary: "Вторая", ImageURL: "https://example.com/2.jpg"},
+	{Title: "Про ставку", Summary: "Третья"},
+}
+
+func TestSelectArticle(t *testing.T) {
+	tests := []struct {
+		name        string
+		writer      *fakeWriter
+		articles    []news.Article
+		topScore    float64
+		smart       bool
+		want        string
+		wantReranks int
+	}{
+		{"выбор модели", &fakeWriter{rerank: ai.Rerank{Index: 2}}, candidateArticles, 40, true, "Про ставку", 1},
+		{"ошибка модели: статья с картинкой", &fakeWriter{rerankErr: errors.New("таймаут")}, candidateArticles, 40, true, "С картинкой", 1},
+		{"умный выбор выключен", &fakeWriter{rerank: ai.Rerank{Index: 2}}, candidateArticles, 40, false, "С картинкой", 0},
+		{"лучшая статья и так релевантна", &fakeWriter{rerank: ai.Rerank{Index: 2}}, candidateArticles, rerankSkipScore, true, "С картинкой", 0},
+		{"один кандидат", &fakeWriter{rerank: ai.Rerank{Index: 0}}, candidateArticles[:1], 40, true, "Без картинки", 0},
+		{"ошибка модели, картинок нет: первая статья", &fakeWriter{rerankErr: errors.New("не JSON")}, []news.Article{candidateArticles[0], candidateArticles[2]}, 40, true, "Без картинки", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(nil, nil, tt.writer, nil)
+			got := s.selectArticle(context.Background(), "ставка", tt.articles, tt.topScore, tt.smart)
+			if got.Title != tt.want {
+				t.Errorf("выбрана %q, ожидалась %q", got.Title, tt.want)
+			}
+			if tt.writer.reranked != tt.wantReranks {
+				t.Errorf("запросов выбора %d, ожидалось %d", tt.writer.reranked, tt.wantReranks)
+			}
+		})
+	}
+}
+
+func TestSelectArticleSendsCandidates(t *testing.T) {
+	writer := &fakeWriter{rerank: ai.Rerank{Index: 1}}
+	New(nil, nil, writer, nil).selectArticle(context.Background(), "ставка", candidateArticles, 0, true)
+
+	if len(writer.candidates) != len(candidateArticles) {
+		t.Fatalf("кандидатов %d", len(writer.candidates))
+	}
+	for i, candidate := range writer.candidates {
+		if candidate.Title != candidateArticles[i].Title || candidate.Summary != candidateArticles[i].Summary {
+			t.Errorf("кандидат %d = %+v", i, candidate)
+		}
+	}
+}
+
+// fakeSearch поиск новостей с заранее заданным ответом
+type fakeSearch struct {
+	articles []news.Article
+	err      error
+	searched int
+}
+
+func (s *fakeSearch) FindRelevantArticles(ctx context.Context, keywords string, maxArticles int, opts news.SearchOptions) ([]news.Article, news.SearchDiagnostics, error) {
+	s.searched++
+	return s.articles, news.SearchDiagnostics{Fetched: len(s.articles), TopScore: 50}, s.err
+}
+
+// stageRecorder записывает этапы генерации
+type stageRecorder struct {
+	noProgress
+	stages []string
+}
+
+func (r *stageRecorder) Stage(stage Stage) {
+	r.stages = append(r.stages, string(stage))
+}
+
+// errorAs является ли err ошибкой типа T
+func errorAs[T error](err error) bool {
+	var target T
+	return errors.As(err, &target)
+}
+
+// stageError является ли err ошибкой этапа stage, вызванной cause
+func stageError(stage Stage, cause error) func(error) bool {
+	return func(err error) bool {
+		var stageErr *StageError
+		return errors.As(err, &stageErr) && stageErr.Stage == stage && errors.Is(err, cause)
+	}
+}
+
+// writtenPost пост, который пишет модель-заглушка
+var writtenPost = ai.Post{Title: "Ставка ЦБ", Body: "Банк России оставил ставку на уровне 21%.", Structured: true}
+
+func TestGenerateFromKeywordsReservation(t *testing.T) {
+	searchFailed := errors.New("все источники недоступны")
+	modelFailed := errors.New("503 от модели")
+	allowed := ai.TopicCheck{Allowed: true}
+	tests := []struct {
+		name     string
+		keywords string
+		balance  int
+		writer   *fakeWriter
+		search   *fakeSearch
+		canceled bool
+		dryRun   bool
+		// wantErr проверяет ошибку; nil — пост готов
+		wantErr func(error) bool
+		// wantReserves сколько раз генерация резервировалась
+		wantReserves int
+		wantStages   string
+	}{
+		{"пост", "ставка цб", 1, &fakeWriter{check: allowed, post: writtenPost}, &fakeSearch{articles: candidateArticles},
+			false, false, nil, 1, "balance,moderation,fetch,select,ai"},
+		// Синтаксис запроса проверяется до резерва
+		{"ошибка запроса", `"ставка цб`, 1, &fakeWriter{check: allowed, post: writtenPost}, &fakeSearch{articles: candidateArticles},
+			false, false, errorAs[*QueryError], 0, "balance"},
+		{"нет генераций", "ставка цб", 0, &fakeWriter{check: allowed, post: writtenPost}, &fakeSearch{articles: candidateArticles},
+			false, false, func(err error) bool { return errors.Is(err, ErrNoGenerations) }, 1, "balance"},
+		{"тема отклонена", "ставка цб", 1, &fakeWriter{check: ai.TopicCheck{Category: "политика"}, post: writtenPost},
+			&fakeSearch{articles: candidateArticles}, false, false, errorAs[*TopicRejectedError], 1, "balance,moderation"},
+		{"ошибка поиска", "ставка цб", 1, &fakeWriter{check: allowed, post: writtenPost}, &fakeSearch{err: searchFailed},
+			false, false, stageError(StageFetch, searchFailed), 1, "balance,moderation,fetch"},
+		{"истек контекст", "ставка цб", 1, &fakeWriter{check: allowed, post: writtenPost}, &fakeSearch{articles: candidateArticles},
+			true, false, stageError(StageFetch, context.Canceled), 1, "balance,moderation,fetch"},
+		{"нет новостей", "ставка цб", 1, &fakeWriter{check: allowed, post: writtenPost}, &fakeSearch{},
+			false, false, errorAs[*NoNewsError], 1, "balance,moderation,fetch"},
+		{"ошибка модели", "ставка цб", 1, &fakeWriter{check: allowed, postErr: modelFailed}, &fakeSearch{articles: candidateArticles},
+			false, false, stageError(StageAI, modelFailed), 1, "balance,moderation,fetch,select,ai"},
+		{"отказ модели", "ставка цб", 1, &fakeWriter{check: allowed, post: ai.Post{Refused: true, RefusalReason: "запрещенная тема"}},
+			&fakeSearch{articles: candidateArticles}, false, false, errorAs[*RefusedError], 1, "balance,moderation,fetch,select,ai"},
+		// Модель не отметила отказ, но текст поста — отказ
+		{"отказ по тексту", "ставка цб", 1, &fakeWriter{check: allowed, post: ai.Post{Body: "Извините, не могу"}, refusal: true},
+			&fakeSearch{articles: candidateArticles}, false, false, errorAs[*RefusedError], 1, "balance,moderation,fetch,select,ai"},
+		{"пустой пост", "ставка цб", 1, &fakeWriter{check: allowed, post: ai.Post{Body: " \n "}}, &fakeSearch{articles: candidateArticles},
+			false, false, func(err error) bool { return errors.Is(err, ErrEmptyPost) }, 1, "balance,moderation,fetch,select,ai"},
+		// Тестовая генерация администратора ничего не резервирует
+		{"тестовая генерация", "ставка цб", 0, &fakeWriter{check: allowed, post: writtenPost}, &fakeSearch{articles: candidateArticles},
+			false, true, nil, 0, "balance,moderation,fetch,select,ai"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{available: tt.balance}
+			progress := &stageRecorder{}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+
+			s := New(tt.search, nil, tt.writer, store)
+			result, err := s.GenerateFromKeywords(ctx, 1, tt.keywords, Options{DryRun: tt.dryRun, Progress: progress})
+			if got := strings.Join(progress.stages, ","); got != tt.wantStages {
+				t.Errorf("этапы %s, ожидались %s", got, tt.wantStages)
+			}
+			if store.reserves != tt.wantReserves {
+				t.Errorf("резервов %d, ожидалось %d", store.reserves, tt.wantReserves)
+			}
+			checkSettled(t, store, tt.balance, result, err, tt.wantErr, tt.dryRun)
+		})
+	}
+}
+
+func TestGenerateFromURLReservation(t *testing.T) {
+	fetchFailed := errors.New("404")
+	modelFailed := errors.New("503 от модели")
+	page := Page{Title: "Ставка ЦБ", Content: "Банк России оставил ставку", ImageURL: "https://example.com/rate.jpg"}
+	tests := []struct {
+		name     string
+		balance  int
+		writer   *fakeWriter
+		page     Page
+		fetchErr error
+		canceled bool
+		wantErr  func(error) bool
+	}{
+		{"пост", 1, &fakeWriter{post: writtenPost}, page, nil, false, nil},
+		{"нет генераций", 0, &fakeWriter{post: writtenPost}, page, nil, false,
+			func(err error) bool { return errors.Is(err, ErrNoGenerations) }},
+		{"страница не загрузилась", 1, &fakeWriter{post: writtenPost}, Page{}, fetchFailed, false, stageError(StageFetch, fetchFailed)},
+		{"истек контекст", 1, &fakeWriter{post: writtenPost}, Page{}, fetchFailed, true, stageError(StageFetch, context.Canceled)},
+		{"ошибка модели", 1, &fakeWriter{postErr: modelFailed}, page, nil, false, stageError(StageAI, modelFailed)},
+		{"отказ модели", 1, &fakeWriter{post: ai.Post{Refused: true}}, page, nil, false, errorAs[*RefusedError]},
+		{"пустой пост", 1, &fakeWriter{post: ai.Post{}}, page, nil, false, func(err error) bool { return errors.Is(err, ErrEmptyPost) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{available: tt.balance}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.canceled {
+				cancel()
+			}
+			fetched := 0
+			pages := PageFetcherFunc(func(ctx context.Context, url string) (Page, error) {
+				fetched++
+				return tt.page, tt.fetchErr
+			})
+
+			s := New(nil, pages, tt.writer, store)
+			result, err := s.GenerateFromURL(ctx, 1, "https://example.com/rate", Options{})
+			if tt.balance == 0 && fetched != 0 {
+				t.Error("страница загружена без резерва")
+			}
+			checkSettled(t, store, tt.balance, result, err, tt.wantErr, false)
+			if err == nil && (result.ImageURL != page.ImageURL || result.Article.URL != "https://example.com/rate" || tt.writer.article.Title != page.Title) {
+				t.Errorf("результат %+v, модели передан %+v", result, tt.writer.article)
+			}
+		})
+	}
+
+	// Странице без заголовка назначается заголовок по умолчанию
+	writer := &fakeWriter{post: writtenPost}
+	s := New(nil, PageFetcherFunc(func(ctx context.Context, url string) (Page, error) {
+		return Page{Content: "Текст"}, nil
+	}), writer, &fakeStore{available: 1})
+	if result, err := s.GenerateFromURL(context.Background(), 1, "https://example.com/a", Options{}); err != nil ||
+		result.Article.Title != defaultPageTitle || writer.article.Title != defaultPageTitle {
+		t.Errorf("заголовок страницы без заголовка: %v, %+v", err, result)
+	}
+}
+
+// checkSettled проверяет исход генерации и судьбу резерва: при ошибке генерация уже
+// возвращена, готовый пост держит резерв до доставки, а Commit его списывает
+func checkSettled(t *testing.T, store *fakeStore, balance int, result *Result, err error, wantErr func(error) bool, dryRun bool) {
+	t.Helper()
+	if wantErr != nil {
+		if err == nil || !wantErr(err) {
+			t.Fatalf("ошибка %v (%T)", err, err)
+		}
+		if result != nil {
+			t.Errorf("вместе с ошибкой результат %+v", result)
+		}
+		if available, reserved, committed := store.balance(); available != balance || reserved != 0 || committed != 0 {
+			t.Errorf("после ошибки доступно %d, в резерве %d, списано %d", available, reserved, committed)
+		}
+		if store.commits != 0 {
+			t.Errorf("после ошибки списаний %d", store.commits)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dryRun {
+		if result.Cost != 0 || result.Reservation != nil || store.reserves != 0 {
+			t.Errorf("тестовая генерация: цена %d, резерв %v, резервов %d", result.Cost, result.Reservation, store.reserves)
+		}
+		return
+	}
+	if result.Cost != 1 || result.Reservation == nil {
+		t.Fatalf("цена %d, резерв %v", result.Cost, result.Reservation)
+	}
+	if available, reserved, _ := store.balance(); available != balance-1 || reserved != 1 || store.releases != 0 {
+		t.Errorf("до доставки доступно %d, в резерве %d, возвратов %d", available, reserved, store.releases)
+	}
+	// Пост доставлен: генерация списывается, отложенный возврат уже ничего не меняет
+	result.Reservation.Commit()
+	result.Reservation.Release()
+	if available, reserved, committed := store.balance(); available != balance-1 || reserved != 0 || committed != 1 {
+		t.Errorf("после доставки доступно %d, в резерве %d, списано %d", available, reserved, committed)
+	}
+}
+
+func TestGenerateCachedPostIsFree(t *testing.T) {
+	config := ai.DefaultAIConfig()
+	config.Cache.ChargeUser = false
+	if err := ai.Configure(config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ai.Configure(ai.DefaultAIConfig()) })
+
+	cached := writtenPost
+	cached.Cached = true
+	store := &fakeStore{available: 1}
+	s := New(&fakeSearch{articles: candidateArticles}, nil, &fakeWriter{check: ai.TopicCheck{Allowed: true}, post: cached}, store)
+	result, err := s.GenerateFromKeywords(context.Background(), 1, "ставка цб", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Пост из кэша ничего не стоит: резерв возвращен сразу
+	if result.Cost != 0 || result.Reservation != nil {
+		t.Errorf("цена %d, резерв %v", result.Cost, result.Reservation)
+	}
+	if available, reserved, _ := store.balance(); available != 1 || reserved != 0 || store.releases != 1 {
+		t.Errorf("доступно %d, в резерве %d, возвратов %d", available, reserved, store.releases)
+	}
+}
@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"log"
+	"sync"
+)
+
+// Reservation генерация, зарезервированная в начале генерации. Проверка баланса и
+// списание разделены минутой работы модели; пока пост готовится или ждет в предпросмотре,
+// зарезервированную генерацию не может занять параллельный запрос того же пользователя.
+// Она списывается, когда пост доставлен, и возвращается при любом другом исходе.
+// Методы nil-резерва ничего не делают: так выглядят генерации без списания.
+type Reservation struct {
+	store  Store
+	userID int64
+
+	mu      sync.Mutex
+	settled bool
+}
+
+// Reserve резервирует генерацию пользователя; false — доступных генераций нет
+func Reserve(store Store, userID int64) (*Reservation, bool) {
+	reserved, err := store.ReserveGeneration(userID)
+	if err != nil {
+		log.Printf("[DB] ❌ Ошибка сохранения резерва генерации %d: %v", userID, err)
+	}
+	if !reserved {
+		return nil, false
+	}
+	return &Reservation{store: store, userID: userID}, true
+}
+
+// Commit списывает генерацию, если она еще не возвращена
+func (r *Reservation) Commit() {
+	if !r.settle() {
+		return
+	}
+	if err := r.store.CommitReservation(r.userID); err != nil {
+		log.Printf("[DB] ❌ Ошибка списания генерации %d: %v", r.userID, err)
+	}
+}
+
+// Release возвращает генерацию, если она еще не списана. Повторный вызов ничего
+// не делает, поэтому Release можно отложить на все пути выхода из обработчика.
+func (r *Reservation) Release() {
+	if !r.settle() {
+		return
+	}
+	if err := r.store.ReleaseReservation(r.userID); err != nil {
+		log.Printf("[DB] ❌ Ошибка возврата резерва генерации %d: %v", r.userID, err)
+	}
+}
+
+// settle отмечает резерв решенным; false — он уже списан или возвращен
+func (r *Reservation) settle() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.settled {
+		return false
+	}
+	r.settled = true
+	return true
+}
@@ -0,0 +1,145 @@
+package generator
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+// fakeStore баланс одного пользователя с подсчетом вызовов
+type fakeStore struct {
+	mu         sync.Mutex
+	available  int
+	reserved   int
+	committed  int
+	reserveErr error
+
+	reserves, commits, releases int
+}
+
+func (s *fakeStore) ReserveGeneration(userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reserves++
+	if s.available <= 0 {
+		return false, nil
+	}
+	s.available--
+	s.reserved++
+	return true, s.reserveErr
+}
+
+func (s *fakeStore) CommitReservation(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commits++
+	if s.reserved <= 0 {
+		return errors.New("нет резерва")
+	}
+	s.reserved--
+	s.committed++
+	return nil
+}
+
+func (s *fakeStore) ReleaseReservation(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.releases++
+	if s.reserved > 0 {
+		s.reserved--
+		s.available++
+	}
+	return nil
+}
+
+// balance доступные, зарезервированные и списанные генерации
+func (s *fakeStore) balance() (available, reserved, committed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.available, s.reserved, s.committed
+}
+
+func TestReservationSettlesOnce(t *testing.T) {
+	tests := []struct {
+		name   string
+		settle func(r *Reservation)
+		// wantCommits и wantReleases сколько раз резерв дошел до базы
+		wantCommits, wantReleases int
+		wantAvailable             int
+	}{
+		{"двойное списание", func(r *Reservation) { r.Commit(); r.Commit() }, 1, 0, 1},
+		{"двойной возврат", func(r *Reservation) { r.Release(); r.Release() }, 0, 1, 2},
+		// Отложенный Release после доставки поста ничего не возвращает
+		{"возврат после списания", func(r *Reservation) { r.Commit(); r.Release() }, 1, 0, 1},
+		{"списание после возврата", func(r *Reservation) { r.Release(); r.Commit() }, 0, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{available: 2}
+			reservation, ok := Reserve(store, 1)
+			if !ok {
+				t.Fatal("резерв не получен")
+			}
+			tt.settle(reservation)
+			if store.commits != tt.wantCommits || store.releases != tt.wantReleases {
+				t.Errorf("списаний %d, возвратов %d, ожидалось %d и %d", store.commits, store.releases, tt.wantCommits, tt.wantReleases)
+			}
+			if available, reserved, _ := store.balance(); available != tt.wantAvailable || reserved != 0 {
+				t.Errorf("доступно %d, в резерве %d", available, reserved)
+			}
+		})
+	}
+}
+
+func TestReservationConcurrentSettle(t *testing.T) {
+	store := &fakeStore{available: 1}
+	reservation, ok := Reserve(store, 1)
+	if !ok {
+		t.Fatal("резерв не получен")
+	}
+
+	// Доставка и отмена гонятся: до базы доходит ровно одна из них
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				reservation.Commit()
+			} else {
+				reservation.Release()
+			}
+		}()
+	}
+	wg.Wait()
+	if store.commits+store.releases != 1 {
+		t.Errorf("списаний %d, возвратов %d", store.commits, store.releases)
+	}
+	if _, reserved, _ := store.balance(); reserved != 0 {
+		t.Errorf("в резерве осталось %d", reserved)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	// Без генераций резерва нет
+	empty := &fakeStore{}
+	if reservation, ok := Reserve(empty, 1); ok || reservation != nil {
+		t.Error("резерв без доступных генераций")
+	}
+
+	// Ошибка сохранения не отменяет резерв: он уже в памяти базы и должен быть решен
+	failing := &fakeStore{available: 1, reserveErr: errors.New("диск заполнен")}
+	reservation, ok := Reserve(failing, 1)
+	if !ok {
+		t.Fatal("резерв потерян из-за ошибки сохранения")
+	}
+	reservation.Release()
+	if available, reserved, _ := failing.balance(); available != 1 || reserved != 0 {
+		t.Errorf("доступно %d, в резерве %d", available, reserved)
+	}
+
+	// Методы nil-резерва ничего не делают
+	var none *Reservation
+	none.Commit()
+	none.Release()
+}
@@ -0,0 +1,172 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"AIGenerator/internal/middleware"
+)
+
+const (
+	// MinProbeInterval чаще этого проверки не обращаются к зависимостям
+	MinProbeInterval = 30 * time.Second
+	// checkTimeout предельное время одной проверки
+	checkTimeout = 10 * time.Second
+)
+
+// Config настройки проверок состояния
+type Config struct {
+	// Port порт HTTP-сервера с /healthz и /readyz; 0 — сервер не запускается
+	Port int
+	// ProbeInterval сколько хранится результат проверки зависимости
+	ProbeInterval time.Duration
+	// MaxUpdateAge сколько может не быть обновлений Telegram, прежде чем бот
+	// считается зависшим; 0 — возраст только показывается
+	MaxUpdateAge time.Duration
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() Config {
+	return Config{ProbeInterval: MinProbeInterval}
+}
+
+// Check проверяет одну зависимость. detail попадает в отчет и при успехе
+type Check func(ctx context.Context) (detail string, err error)
+
+// Result результат одной проверки
+type Result struct {
+	Name      string    `json:"name"`
+	OK        bool      `json:"ok"`
+	Detail    string    `json:"detail,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report результаты группы проверок
+type Report struct {
+	OK     bool     `json:"ok"`
+	Checks []Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker набор проверок: liveness — процесс жив и может работать,
+// readiness — внешние зависимости доступны
+type Checker struct {
+	liveness  []namedCheck
+	readiness []namedCheck
+}
+
+// NewChecker создает пустой набор проверок
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// AddLiveness добавляет проверку в /healthz
+func (c *Checker) AddLiveness(name string, check Check) {
+	c.liveness = append(c.liveness, namedCheck{name, check})
+}
+
+// AddReadiness добавляет проверку в /readyz
+func (c *Checker) AddReadiness(name string, check Check) {
+	c.readiness = append(c.readiness, namedCheck{name, check})
+}
+
+// Liveness выполняет проверки liveness
+func (c *Checker) Liveness(ctx context.Context) Report {
+	return run(ctx, c.liveness)
+}
+
+// Readiness выполняет проверки readiness
+func (c *Checker) Readiness(ctx context.Context) Report {
+	return run(ctx, c.readiness)
+}
+
+// run выполняет проверки параллельно, каждую со своим ограничением времени
+func run(ctx context.Context, checks []namedCheck) Report {
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+
+			detail, err := check.check(checkCtx)
+			results[i] = Result{Name: check.name, OK: err == nil, Detail: detail, CheckedAt: time.Now()}
+			if err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	report := Report{OK: true, Checks: results}
+	for _, result := range results {
+		report.OK = report.OK && result.OK
+	}
+	return report
+}
+
+// Cached оборачивает проверку так, что зависимость опрашивается не чаще раза в ttl;
+// в промежутке возвращается сохраненный результат. Одновременные вызовы ждут
+// одного обращения к зависимости.
+func Cached(check Check, ttl time.Duration) Check {
+	var (
+		mu        sync.Mutex
+		checkedAt time.Time
+		detail    string
+		lastErr   error
+	)
+	return func(ctx context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if !checkedAt.IsZero() && time.Since(checkedAt) < ttl {
+			return detail, lastErr
+		}
+		detail, lastErr = check(ctx)
+		checkedAt = time.Now()
+		return detail, lastErr
+	}
+}
+
+// NewServer создает HTTP-сервер с /healthz и /readyz: 200, если все проверки
+// группы прошли, иначе 503. Тело — Report в JSON. Запросы проходят через
+// middleware.Standard: лимиты размера и частоты, журнал, восстановление после паники.
+func NewServer(port int, checker *Checker) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", reportHandler(checker.Liveness))
+	mux.HandleFunc("GET /readyz", reportHandler(checker.Readiness))
+
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           middleware.Standard("health", mux),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
+
+// reportHandler отдает результат группы проверок
+func reportHandler(report func(context.Context) Report) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result := report(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		if !result.OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.Printf("[HEALTH] ⚠️ Ошибка записи ответа: %v", err)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCheck проверка-заглушка, которая считает обращения к зависимости
+type countingCheck struct {
+	calls  atomic.Int32
+	detail string
+	err    error
+	delay  time.Duration
+}
+
+func (c *countingCheck) check(ctx context.Context) (string, error) {
+	c.calls.Add(1)
+	if c.delay > 0 {
+		select {
+		case <-time.After(c.delay):
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	return c.detail, c.err
+}
+
+func TestCheckerReports(t *testing.T) {
+	tests := []struct {
+		name   string
+		checks []*countingCheck
+		wantOK bool
+	}{
+		{"пустой набор", nil, true},
+		{"все прошли", []*countingCheck{{detail: "ok"}, {detail: "12 мс"}}, true},
+		{"одна упала", []*countingCheck{{detail: "ok"}, {err: errors.New("таймаут")}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := NewChecker()
+			for i, check := range tt.checks {
+				checker.AddReadiness(string(rune('a'+i)), check.check)
+			}
+			report := checker.Readiness(context.Background())
+			if report.OK != tt.wantOK || len(report.Checks) != len(tt.checks) {
+				t.Fatalf("отчет %+v", report)
+			}
+			// Порядок результатов совпадает с порядком добавления
+			for i, result := range report.Checks {
+				check := tt.checks[i]
+				if result.Name != string(rune('a'+i)) || result.OK != (check.err == nil) || result.Detail != check.detail {
+					t.Errorf("результат %d: %+v", i, result)
+				}
+				if check.err != nil && result.Error != check.err.Error() {
+					t.Errorf("ошибка %q", result.Error)
+				}
+				if result.CheckedAt.IsZero() {
+					t.Error("нет времени проверки")
+				}
+			}
+			if liveness := checker.Liveness(context.Background()); !liveness.OK || len(liveness.Checks) != 0 {
+				t.Errorf("readiness попала в liveness: %+v", liveness)
+			}
+		})
+	}
+}
+
+func TestCheckerRunsChecksInParallel(t *testing.T) {
+	checker := NewChecker()
+	for _, name := range []string{"db", "telegram", "ai"} {
+		checker.AddReadiness(name, (&countingCheck{delay: 100 * time.Millisecond}).check)
+	}
+
+	started := time.Now()
+	if report := checker.Readiness(context.Background()); !report.OK {
+		t.Fatalf("отчет %+v", report)
+	}
+	if elapsed := time.Since(started); elapsed > 250*time.Millisecond {
+		t.Errorf("проверки выполнены последовательно: %v", elapsed)
+	}
+}
+
+func TestCheckerRespectsContext(t *testing.T) {
+	checker := NewChecker()
+	checker.AddReadiness("медленная", (&countingCheck{delay: time.Minute}).check)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	report := checker.Readiness(ctx)
+	if report.OK || report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("зависшая проверка: %+v", report)
+	}
+}
+
+func TestCachedProbesOncePerTTL(t *testing.T) {
+	fake := &countingCheck{detail: "ok"}
+	check := Cached(fake.check, time.Hour)
+
+	for i := 0; i < 5; i++ {
+		if detail, err := check(context.Background()); detail != "ok" || err != nil {
+			t.Fatalf("вызов %d: %q, %v", i, detail, err)
+		}
+	}
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Errorf("обращений к зависимости %d, ожидалось 1", calls)
+	}
+}
+
+func TestCachedCachesErrors(t *testing.T) {
+	fake := &countingCheck{err: errors.New("401")}
+	check := Cached(fake.check, time.Hour)
+
+	// Упавшая зависимость тоже не опрашивается на каждый запрос
+	for i := 0; i < 3; i++ {
+		if _, err := check(context.Background()); err == nil || err.Error() != "401" {
+			t.Fatalf("вызов %d: %v", i, err)
+		}
+	}
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Errorf("обращений к зависимости %d, ожидалось 1", calls)
+	}
+}
+
+func TestCachedRefreshesAfterTTL(t *testing.T) {
+	fake := &countingCheck{detail: "ok"}
+	check := Cached(fake.check, 20*time.Millisecond)
+
+	check(context.Background())
+	time.Sleep(40 * time.Millisecond)
+	check(context.Background())
+	if calls := fake.calls.Load(); calls != 2 {
+		t.Errorf("обращений к зависимости %d, ожидалось 2", calls)
+	}
+}
+
+func TestCachedConcurrentCallersShareProbe(t *testing.T) {
+	fake := &countingCheck{detail: "ok", delay: 50 * time.Millisecond}
+	check := Cached(fake.check, time.Hour)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if detail, err := check(context.Background()); detail != "ok" || err != nil {
+				t.Errorf("%q, %v", detail, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls := fake.calls.Load(); calls != 1 {
+		t.Errorf("обращений к зависимости %d, ожидалось 1", calls)
+	}
+}
+
+func TestServerEndpoints(t *testing.T) {
+	checker := NewChecker()
+	checker.AddLiveness("процесс", (&countingCheck{detail: "жив"}).check)
+	checker.AddReadiness("база", (&countingCheck{detail: "ok"}).check)
+	checker.AddReadiness("telegram", (&countingCheck{err: errors.New("недоступен")}).check)
+	handler := NewServer(0, checker).Handler
+
+	tests := []struct {
+		method     string
+		path       string
+		wantStatus int
+		wantOK     bool
+		wantChecks int
+	}{
+		{http.MethodGet, "/healthz", http.StatusOK, true, 1},
+		{http.MethodGet, "/readyz", http.StatusServiceUnavailable, false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("код %d, ожидался %d", recorder.Code, tt.wantStatus)
+			}
+			if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("Content-Type %q", contentType)
+			}
+			var report Report
+			if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+				t.Fatalf("тело не JSON: %q", recorder.Body.String())
+			}
+			if report.OK != tt.wantOK || len(report.Checks) != tt.wantChecks {
+				t.Errorf("отчет %+v", report)
+			}
+		})
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/healthz", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /healthz: код %d", recorder.Code)
+	}
+}
@@ -0,0 +1,304 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultUserAgent User-Agent для всех исходящих запросов
+	defaultUserAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36"
+	// defaultDomainConcurrency максимальное число одновременных запросов к одному домену
+	defaultDomainConcurrency = 2
+	// defaultDomainInterval минимальный интервал между запросами к одному домену
+	defaultDomainInterval = 500 * time.Millisecond
+	// robotsCacheTTL время жизни кэша robots.txt
+	robotsCacheTTL = time.Hour
+)
+
+// requestsPerDomain счетчик исходящих запросов по доменам
+var requestsPerDomain = expvar.NewMap("httpx_requests_per_domain")
+
+// Client исходящий HTTP-клиент с ограничением нагрузки на домены.
+// Ограничения общие для всех клиентов пакета.
+type Client struct {
+	httpClient *http.Client
+	proxyURL   *url.URL
+}
+
+// domainLimiter ограничивает параллельность и частоту запросов к домену
+type domainLimiter struct {
+	slots       chan struct{}
+	mu          sync.Mutex
+	lastRequest time.Time
+}
+
+// robotsRules правила robots.txt для User-agent: *
+type robotsRules struct {
+	disallow  []string
+	allow     []string
+	fetchedAt time.Time
+}
+
+// Config настройки исходящих запросов
+type Config struct {
+	// UserAgent заголовок User-Agent всех запросов
+	UserAgent string
+	// DomainConcurrency максимальное число одновременных запросов к одному домену
+	DomainConcurrency int
+	// DomainInterval минимальный интервал между запросами к одному домену
+	DomainInterval time.Duration
+	// RespectRobots соблюдать robots.txt при загрузке страниц
+	RespectRobots bool
+	// NewsProxy прокси для новостей; без него используется OutboundProxy
+	NewsProxy *url.URL
+	// AIProxy прокси для запросов к нейросети
+	AIProxy *url.URL
+	// OutboundProxy общий прокси исходящего трафика
+	OutboundProxy *url.URL
+	// AIUseOutboundProxy направлять запросы к нейросети через OutboundProxy, если AIProxy не задан
+	AIUseOutboundProxy bool
+}
+
+// DefaultConfig возвращает настройки по умолчанию: без прокси и без robots.txt
+func DefaultConfig() Config {
+	return Config{
+		UserAgent:         defaultUserAgent,
+		DomainConcurrency: defaultDomainConcurrency,
+		DomainInterval:    defaultDomainInterval,
+	}
+}
+
+// settings текущие настройки пакета, задаются Configure
+var settings = DefaultConfig()
+
+// Configure задает настройки пакета. Вызывается при запуске до первых запросов.
+func Configure(config Config) {
+	settings = config
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*domainLimiter)
+
+	robotsMu    sync.Mutex
+	robotsCache = make(map[string]*robotsRules)
+)
+
+// NewClient создает клиент для загрузки новостей с заданным таймаутом на запрос
+func NewClient(timeout time.Duration) *Client {
+	proxyURL := ProxyURL(PurposeNews)
+	return &Client{
+		httpClient: &http.Client{
+			Timeout:   timeout,
+			Transport: NewTransport(proxyURL),
+		},
+		proxyURL: proxyURL,
+	}
+}
+
+// Do выполняет запрос, соблюдая ограничения домена. Слот домена
+// освобождается после закрытия тела ответа.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	limiter := limiterFor(host)
+
+	if err := limiter.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", settings.UserAgent)
+	requestsPerDomain.Add(host, 1)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		limiter.release()
+		return nil, WrapProxyError(err, c.proxyURL)
+	}
+
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: limiter.release}
+	return resp, nil
+}
+
+// limiterFor возвращает ограничитель для домена
+func limiterFor(host string) *domainLimiter {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	limiter, exists := limiters[host]
+	if !exists {
+		limiter = &domainLimiter{slots: make(chan struct{}, settings.DomainConcurrency)}
+		limiters[host] = limiter
+	}
+	return limiter
+}
+
+// acquire занимает слот домена и выдерживает минимальный интервал между запросами
+func (l *domainLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	wait := settings.DomainInterval - time.Since(l.lastRequest)
+	if wait < 0 {
+		wait = 0
+	}
+	l.lastRequest = time.Now().Add(wait)
+	l.mu.Unlock()
+
+	if wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			l.release()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// release освобождает слот домена
+func (l *domainLimiter) release() {
+	<-l.slots
+}
+
+// releasingBody освобождает слот домена при закрытии тела ответа
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// AllowedByRobots проверяет, разрешает ли robots.txt сайта загрузку страницы.
+// Если проверка отключена (HTTP_RESPECT_ROBOTS) или robots.txt недоступен, загрузка разрешена.
+func (c *Client) AllowedByRobots(ctx context.Context, rawURL string) bool {
+	if !settings.RespectRobots {
+		return true
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return true
+	}
+
+	rules := c.robotsFor(ctx, parsed)
+	if rules == nil {
+		return true
+	}
+
+	path := parsed.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	// Побеждает самое длинное совпавшее правило, Allow при равенстве
+	longestDisallow, longestAllow := -1, -1
+	for _, prefix := range rules.disallow {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longestDisallow {
+			longestDisallow = len(prefix)
+		}
+	}
+	for _, prefix := range rules.allow {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longestAllow {
+			longestAllow = len(prefix)
+		}
+	}
+
+	return longestDisallow < 0 || longestAllow >= longestDisallow
+}
+
+// robotsFor загружает (или берет из кэша) правила robots.txt для хоста
+func (c *Client) robotsFor(ctx context.Context, target *url.URL) *robotsRules {
+	host := strings.ToLower(target.Host)
+
+	robotsMu.Lock()
+	cached, exists := robotsCache[host]
+	robotsMu.Unlock()
+	if exists && time.Since(cached.fetchedAt) < robotsCacheTTL {
+		return cached
+	}
+
+	robotsURL := fmt.Sprintf("%s://%s/robots.txt", target.Scheme, target.Host)
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
+	if err != nil {
+		return nil
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		log.Printf("[HTTP] ⚠️ Не удалось получить robots.txt для %s: %v", host, err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	rules := &robotsRules{fetchedAt: time.Now()}
+	if resp.StatusCode == http.StatusOK {
+		parseRobots(io.LimitReader(resp.Body, 512*1024), rules)
+	}
+
+	robotsMu.Lock()
+	robotsCache[host] = rules
+	robotsMu.Unlock()
+
+	return rules
+}
+
+// parseRobots разбирает правила robots.txt для User-agent: *
+func parseRobots(r io.Reader, rules *robotsRules) {
+	scanner := bufio.NewScanner(r)
+	applies := false
+	groupStarted := false
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			// Новая группа начинается после правил предыдущей
+			if groupStarted {
+				applies = false
+				groupStarted = false
+			}
+			if value == "*" {
+				applies = true
+			}
+		case "disallow":
+			groupStarted = true
+			if applies && value != "" {
+				rules.disallow = append(rules.disallow, value)
+			}
+		case "allow":
+			groupStarted = true
+			if applies && value != "" {
+				rules.allow = append(rules.allow, value)
+			}
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useSettings задает настройки пакета на время теста и сбрасывает ограничители доменов
+func useSettings(t *testing.T, config Config) {
+	t.Helper()
+	previous := settings
+	reset := func() {
+		limitersMu.Lock()
+		limiters = make(map[string]*domainLimiter)
+		limitersMu.Unlock()
+		robotsMu.Lock()
+		robotsCache = make(map[string]*robotsRules)
+		robotsMu.Unlock()
+	}
+	Configure(config)
+	reset()
+	t.Cleanup(func() {
+		Configure(previous)
+		reset()
+	})
+}
+
+func get(t *testing.T, client *Client, url string) {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Errorf("запрос %s: %v", url, err)
+		return
+	}
+	resp.Body.Close()
+}
+
+func TestConcurrentFetchesOfSameHostAreSerialized(t *testing.T) {
+	config := DefaultConfig()
+	config.DomainConcurrency = 1
+	config.DomainInterval = 0
+	useSettings(t, config)
+
+	var active, maxActive atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := active.Add(1)
+		for {
+			seen := maxActive.Load()
+			if current <= seen || maxActive.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		active.Add(-1)
+	}))
+	defer server.Close()
+
+	client := NewClient(5 * time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, client, server.URL)
+		}()
+	}
+	wg.Wait()
+
+	if got := maxActive.Load(); got != 1 {
+		t.Fatalf("одновременных запросов к домену: %d, ожидался 1", got)
+	}
+}
+
+func TestMinimumIntervalBetweenRequestsToDomain(t *testing.T) {
+	config := DefaultConfig()
+	config.DomainInterval = 100 * time.Millisecond
+	useSettings(t, config)
+
+	var mu sync.Mutex
+	var starts []time.Time
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		starts = append(starts, time.Now())
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	client := NewClient(5 * time.Second)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, client, server.URL)
+		}()
+	}
+	wg.Wait()
+
+	if len(starts) != 2 {
+		t.Fatalf("получено %d запросов", len(starts))
+	}
+	gap := starts[1].Sub(starts[0])
+	if gap < 0 {
+		gap = -gap
+	}
+	if gap < 90*time.Millisecond {
+		t.Fatalf("интервал между запросами %v меньше минимального", gap)
+	}
+}
+
+func TestDoSetsUserAgentAndCountsRequests(t *testing.T) {
+	config := DefaultConfig()
+	config.DomainInterval = 0
+	config.UserAgent = "test-agent"
+	useSettings(t, config)
+
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+	}))
+	defer server.Close()
+
+	before := requestCount("127.0.0.1")
+	get(t, NewClient(5*time.Second), server.URL)
+
+	if userAgent != "test-agent" {
+		t.Errorf("User-Agent = %q", userAgent)
+	}
+	if got := requestCount("127.0.0.1") - before; got != 1 {
+		t.Errorf("счетчик запросов вырос на %d, ожидалось 1", got)
+	}
+}
+
+func requestCount(host string) int64 {
+	value, ok := requestsPerDomain.Get(host).(interface{ Value() int64 })
+	if !ok {
+		return 0
+	}
+	return value.Value()
+}
+
+func TestAllowedByRobots(t *testing.T) {
+	config := DefaultConfig()
+	config.DomainInterval = 0
+	config.RespectRobots = true
+	useSettings(t, config)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.Write([]byte("User-agent: Googlebot\nDisallow: /\n\nUser-agent: *\nDisallow: /private\nAllow: /private/open\n"))
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(5 * time.Second)
+	tests := map[string]bool{
+		"/news/1":         true,
+		"/private/page":   false,
+		"/private/open/1": true,
+	}
+	for path, want := range tests {
+		if got := client.AllowedByRobots(context.Background(), server.URL+path); got != want {
+			t.Errorf("AllowedByRobots(%s) = %v, ожидалось %v", path, got, want)
+		}
+	}
+}
+
+func TestParseRobotsIgnoresOtherAgents(t *testing.T) {
+	rules := &robotsRules{}
+	parseRobots(strings.NewReader("User-agent: Yandex\nDisallow: /all\n# комментарий\nUser-agent: *\nDisallow: /tmp # временные\n"), rules)
+
+	if len(rules.disallow) != 1 || rules.disallow[0] != "/tmp" {
+		t.Fatalf("disallow = %v, ожидалось [/tmp]", rules.disallow)
+	}
+}
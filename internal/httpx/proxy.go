@@ -0,0 +1,78 @@
+package httpx
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Purpose назначение исходящего трафика для выбора прокси
+type Purpose int
+
+const (
+	// PurposeNews загрузка новостей и страниц по ссылкам
+	PurposeNews Purpose = iota
+	// PurposeAI запросы к API нейросети
+	PurposeAI
+)
+
+// ProxyURL возвращает прокси для указанного назначения трафика или nil.
+// Новости идут через NewsProxy, иначе через OutboundProxy.
+// AI идет через AIProxy, а через OutboundProxy — только при AIUseOutboundProxy.
+func ProxyURL(purpose Purpose) *url.URL {
+	var proxyURL *url.URL
+	switch purpose {
+	case PurposeNews:
+		proxyURL = settings.NewsProxy
+	case PurposeAI:
+		proxyURL = settings.AIProxy
+	}
+
+	if proxyURL == nil && (purpose == PurposeNews || settings.AIUseOutboundProxy) {
+		proxyURL = settings.OutboundProxy
+	}
+	return proxyURL
+}
+
+// ParseProxyURL разбирает адрес прокси; поддерживаются схемы http, https, socks5 и socks5h
+func ParseProxyURL(value string) (*url.URL, error) {
+	proxyURL, err := url.Parse(value)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("неверный адрес прокси")
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("неподдерживаемая схема прокси: %s", proxyURL.Scheme)
+	}
+	return proxyURL, nil
+}
+
+// NewTransport создает транспорт с прокси. Без прокси используются
+// стандартные переменные окружения HTTP_PROXY/HTTPS_PROXY.
+func NewTransport(proxyURL *url.URL) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	return transport
+}
+
+// NewHTTPClient создает обычный http.Client с прокси для указанного назначения
+func NewHTTPClient(purpose Purpose, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: NewTransport(ProxyURL(purpose)),
+	}
+}
+
+// WrapProxyError добавляет к ошибке соединения упоминание прокси, чтобы
+// оператор сразу видел, что проблема может быть в нем
+func WrapProxyError(err error, proxyURL *url.URL) error {
+	if err == nil || proxyURL == nil {
+		return err
+	}
+	return fmt.Errorf("ошибка соединения через прокси %s://%s: %w", proxyURL.Scheme, proxyURL.Host, err)
+}
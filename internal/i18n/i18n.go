@@ -0,0 +1,167 @@
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+//go:embed locales/*.json
+var locales embed.FS
+
+// DefaultLanguage язык сообщений по умолчанию; его каталог полный, в него уходят
+// ключи, которых нет в других каталогах
+const DefaultLanguage = "ru"
+
+// Language язык интерфейса бота
+type Language struct {
+	// Code код языка: ru, en
+	Code string
+	// Title название языка для пользователя
+	Title string
+}
+
+var languages = []Language{
+	{Code: "ru", Title: "🇷🇺 Русский"},
+	{Code: "en", Title: "🇬🇧 English"},
+}
+
+// russianSpeaking языки Telegram, пользователям которых по умолчанию показывается русский
+var russianSpeaking = []string{"ru", "uk", "be", "kk"}
+
+// catalogs каталоги сообщений по коду языка. Встроенные файлы проверяются при сборке
+// бинарника, поэтому ошибка разбора — ошибка программиста.
+var catalogs = mustLoadCatalogs()
+
+// missing ключи, о нехватке перевода которых уже написано в лог
+var missing sync.Map
+
+func mustLoadCatalogs() map[string]map[string]string {
+	result := make(map[string]map[string]string, len(languages))
+	for _, language := range languages {
+		data, err := locales.ReadFile("locales/" + language.Code + ".json")
+		if err != nil {
+			panic(fmt.Sprintf("i18n: нет каталога %s: %v", language.Code, err))
+		}
+		var catalog map[string]string
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic(fmt.Sprintf("i18n: ошибка разбора каталога %s: %v", language.Code, err))
+		}
+		result[language.Code] = catalog
+	}
+	return result
+}
+
+// Languages возвращает поддерживаемые языки интерфейса
+func Languages() []Language {
+	return languages
+}
+
+// Parse находит язык интерфейса по коду
+func Parse(code string) (Language, bool) {
+	code = strings.ToLower(strings.TrimSpace(code))
+	for _, language := range languages {
+		if language.Code == code {
+			return language, true
+		}
+	}
+	return Language{}, false
+}
+
+// Codes возвращает коды языков через запятую, для подсказок пользователю
+func Codes() string {
+	codes := make([]string, len(languages))
+	for i, language := range languages {
+		codes[i] = language.Code
+	}
+	return strings.Join(codes, ", ")
+}
+
+// Detect выбирает язык интерфейса по language_code из Telegram: русский для
+// русскоязычных стран, английский для остальных, русский, если код неизвестен
+func Detect(telegramCode string) string {
+	code, _, _ := strings.Cut(strings.ToLower(telegramCode), "-")
+	switch {
+	case code == "":
+		return DefaultLanguage
+	case slices.Contains(russianSpeaking, code):
+		return "ru"
+	default:
+		return "en"
+	}
+}
+
+// T возвращает сообщение key на языке lang, подставляя args по правилам fmt.
+// Если перевода нет, возвращается русское сообщение и один раз пишется предупреждение.
+func T(lang, key string, args ...any) string {
+	text, ok := catalogs[lang][key]
+	if !ok {
+		if lang != "" {
+			warnMissing(lang, key)
+		}
+		if text, ok = catalogs[DefaultLanguage][key]; !ok {
+			warnMissing(DefaultLanguage, key)
+			return key
+		}
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// warnMissing пишет в лог о нехватке перевода, один раз для каждого ключа
+func warnMissing(lang, key string) {
+	if _, loaded := missing.LoadOrStore(lang+"/"+key, struct{}{}); !loaded {
+		log.Printf("[I18N] ⚠️ Нет перевода %s для языка %s", key, lang)
+	}
+}
+
+// verbPattern глагол форматирования fmt: %d, %s, %.2f, %v
+var verbPattern = regexp.MustCompile(`%[-+# 0]*[0-9]*(?:\.[0-9]+)?[a-zA-Z%]`)
+
+// Validate сверяет каталоги с русским: в каждом должны быть все ключи русского каталога
+// с теми же подстановками в том же порядке, и не должно быть лишних ключей
+func Validate() error {
+	base := catalogs[DefaultLanguage]
+	var errs []error
+	for _, language := range languages {
+		if language.Code == DefaultLanguage {
+			continue
+		}
+		catalog := catalogs[language.Code]
+		for _, key := range sortedKeys(base) {
+			text, ok := catalog[key]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%s: нет ключа %s", language.Code, key))
+				continue
+			}
+			want, got := verbPattern.FindAllString(base[key], -1), verbPattern.FindAllString(text, -1)
+			if !slices.Equal(want, got) {
+				errs = append(errs, fmt.Errorf("%s: подстановки %s не совпадают: %v, в русском %v",
+					language.Code, key, got, want))
+			}
+		}
+		for _, key := range sortedKeys(catalog) {
+			if _, ok := base[key]; !ok {
+				errs = append(errs, fmt.Errorf("%s: лишний ключ %s", language.Code, key))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func sortedKeys(catalog map[string]string) []string {
+	keys := make([]string, 0, len(catalog))
+	for key := range catalog {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
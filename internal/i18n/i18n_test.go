@@ -0,0 +1,102 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+// useCatalogs подменяет каталоги сообщений на время теста
+func useCatalogs(t *testing.T, replacement map[string]map[string]string) {
+	t.Helper()
+	saved := catalogs
+	catalogs = replacement
+	t.Cleanup(func() { catalogs = saved })
+}
+
+func TestCatalogsMatch(t *testing.T) {
+	// Каждый ключ русского каталога есть в английском с теми же подстановками
+	if err := Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if len(catalogs[DefaultLanguage]) == 0 {
+		t.Fatal("русский каталог пуст")
+	}
+}
+
+func TestValidateFindsMismatch(t *testing.T) {
+	useCatalogs(t, map[string]map[string]string{
+		"ru": {"a": "Осталось %d из %d", "b": "Текст", "c": "Имя %s, баланс %.2f"},
+		"en": {"a": "Left %d", "c": "Balance %.2f, name %s", "d": "Extra"},
+	})
+
+	err := Validate()
+	if err == nil {
+		t.Fatal("расхождения не найдены")
+	}
+	for _, want := range []string{"подстановки a", "нет ключа b", "подстановки c", "лишний ключ d"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("в ошибке нет %q: %v", want, err)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	useCatalogs(t, map[string]map[string]string{
+		"ru": {"balance": "Баланс: %d", "only_ru": "Только по-русски", "plain": "100%"},
+		"en": {"balance": "Balance: %d", "plain": "100%"},
+	})
+
+	tests := []struct {
+		name string
+		lang string
+		key  string
+		args []any
+		want string
+	}{
+		{"английский", "en", "balance", []any{5}, "Balance: 5"},
+		{"русский", "ru", "balance", []any{5}, "Баланс: 5"},
+		{"язык не выбран", "", "balance", []any{5}, "Баланс: 5"},
+		{"нет перевода", "en", "only_ru", nil, "Только по-русски"},
+		{"неизвестный язык", "de", "balance", []any{1}, "Баланс: 1"},
+		{"нет ключа", "en", "missing.key", nil, "missing.key"},
+		// Без аргументов текст не проходит через fmt
+		{"процент без аргументов", "en", "plain", nil, "100%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := T(tt.lang, tt.key, tt.args...); got != tt.want {
+				t.Errorf("T(%q, %q) = %q, ожидалось %q", tt.lang, tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := map[string]string{
+		"":      "ru",
+		"ru":    "ru",
+		"uk":    "ru",
+		"kk":    "ru",
+		"en":    "en",
+		"en-US": "en",
+		"pt-br": "en",
+		"RU":    "ru",
+	}
+	for code, want := range tests {
+		if got := Detect(code); got != want {
+			t.Errorf("Detect(%q) = %q, ожидалось %q", code, got, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	if language, ok := Parse(" EN "); !ok || language.Code != "en" {
+		t.Errorf("Parse(EN) = %+v, %t", language, ok)
+	}
+	if _, ok := Parse("de"); ok {
+		t.Error("неподдерживаемый язык принят")
+	}
+	if got := Codes(); got != "ru, en" {
+		t.Errorf("Codes = %q", got)
+	}
+}
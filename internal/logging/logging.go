@@ -0,0 +1,240 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Имена полей, общие для всех компонентов
+const (
+	KeyComponent = "component"
+	KeyUserID    = "user_id"
+	KeyPaymentID = "payment_id"
+	KeyDuration  = "duration"
+	KeyError     = "error"
+)
+
+// Форматы вывода
+const (
+	// FormatJSON одна JSON-запись на строку, для сбора логов
+	FormatJSON = "json"
+	// FormatText key=value, удобнее читать в консоли при разработке
+	FormatText = "text"
+)
+
+// ConsoleLevel записи этого уровня и выше дублируются в консоль
+const ConsoleLevel = slog.LevelWarn
+
+// Config настройки логирования
+type Config struct {
+	Level  slog.Level
+	Format string
+
+	// File файл лога
+	File string
+	// MaxSizeMB размер, после которого файл ротируется; 0 — без ротации
+	MaxSizeMB int
+	// MaxBackups сколько сжатых старых файлов хранить; 0 — без ограничения
+	MaxBackups int
+	// MaxAgeDays сколько дней хранить старые файлы; 0 — без ограничения
+	MaxAgeDays int
+}
+
+// DefaultConfig возвращает настройки по умолчанию: уровень info, JSON
+func DefaultConfig() Config {
+	return Config{
+		Level:      slog.LevelInfo,
+		Format:     FormatJSON,
+		File:       "logs.txt",
+		MaxSizeMB:  50,
+		MaxBackups: 5,
+		MaxAgeDays: 30,
+	}
+}
+
+// Setup направляет slog и стандартный log в w, а записи уровня ConsoleLevel и выше
+// еще и в console (если не nil), чтобы их видели journald и docker logs.
+// Предупреждения, ошибки и записи о генерациях дополнительно попадают в Recent.
+// Записи log.Printf со старыми префиксами вида "[PAYMENT] ❌ ..." разбираются:
+// префикс становится полем component, а эмодзи ❌ и ⚠️ — уровнями error и warn.
+// Так пакеты можно переводить на slog постепенно.
+func Setup(w, console io.Writer, config Config) *slog.Logger {
+	handler := teeHandler{
+		newHandler(w, config.Format, config.Level),
+		recentHandler{next: newHandler(Recent, config.Format, config.Level)},
+	}
+	if console != nil {
+		handler = append(handler, newHandler(console, FormatText, max(config.Level, ConsoleLevel)))
+	}
+
+	logger := slog.New(handler)
+	slog.SetDefault(logger)
+
+	// После slog.SetDefault стандартный log пишет в тот же обработчик, но без разбора
+	// префиксов; заменяем вывод на совместимый
+	log.SetFlags(0)
+	log.SetOutput(&legacyWriter{logger: logger})
+	return logger
+}
+
+// newHandler создает обработчик в формате format
+func newHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: redactAttr}
+	if format == FormatText {
+		return slog.NewTextHandler(w, options)
+	}
+	return slog.NewJSONHandler(w, options)
+}
+
+// teeHandler передает запись всем обработчикам, уровень которых ее пропускает
+type teeHandler []slog.Handler
+
+func (t teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range t {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range t {
+		if handler.Enabled(ctx, record.Level) {
+			if err := handler.Handle(ctx, record.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (t teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	result := make(teeHandler, len(t))
+	for i, handler := range t {
+		result[i] = handler.WithAttrs(attrs)
+	}
+	return result
+}
+
+func (t teeHandler) WithGroup(name string) slog.Handler {
+	result := make(teeHandler, len(t))
+	for i, handler := range t {
+		result[i] = handler.WithGroup(name)
+	}
+	return result
+}
+
+// For возвращает логгер компонента. Вызывается при каждой записи, а не сохраняется
+// в переменную пакета: до Setup логгер по умолчанию еще не настроен.
+func For(component string) *slog.Logger {
+	return slog.Default().With(KeyComponent, component)
+}
+
+// legacyPrefix префикс старых записей: [GENERATE], [AI], [YOOMONEY]
+var legacyPrefix = regexp.MustCompile(`^\[([A-Z_]+)\]\s*`)
+
+// legacyWriter переводит строки стандартного log в записи slog
+type legacyWriter struct {
+	logger *slog.Logger
+}
+
+func (w *legacyWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(string(bytes.TrimRight(p, "\n")), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		component, level, message := parseLegacy(line)
+		if component != "" {
+			w.logger.Log(context.Background(), level, message, KeyComponent, component)
+		} else {
+			w.logger.Log(context.Background(), level, message)
+		}
+	}
+	return len(p), nil
+}
+
+// parseLegacy выделяет из строки компонент, уровень и сообщение
+func parseLegacy(line string) (component string, level slog.Level, message string) {
+	level = slog.LevelInfo
+	message = strings.TrimSpace(line)
+
+	if match := legacyPrefix.FindStringSubmatch(message); match != nil {
+		component = strings.ToLower(match[1])
+		message = message[len(match[0]):]
+	}
+
+	switch {
+	case component == "error" || component == "panic" || strings.HasPrefix(message, "❌"):
+		level = slog.LevelError
+	case strings.HasPrefix(message, "⚠️") || strings.HasPrefix(message, "⏱"):
+		level = slog.LevelWarn
+	}
+	return component, level, message
+}
+
+// ParseLevel разбирает уровень: debug, info, warn, error
+func ParseLevel(value string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(value))
+	return level, err
+}
+
+var (
+	secrets   []string
+	secretsMu sync.RWMutex
+)
+
+// AddSecrets запоминает значения, которые вырезаются из всех записей: ключи API, токены
+func AddSecrets(values ...string) {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	for _, value := range values {
+		// Короткие значения совпадали бы с обычным текстом
+		if len(value) >= 8 {
+			secrets = append(secrets, value)
+		}
+	}
+}
+
+// emailPattern адрес электронной почты
+var emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// Redact вырезает из текста секреты, добавленные AddSecrets, и скрывает адреса почты,
+// оставляя первую букву и домен: a***@example.com
+func Redact(text string) string {
+	secretsMu.RLock()
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, "***")
+	}
+	secretsMu.RUnlock()
+	return emailPattern.ReplaceAllString(text, "$1***@$2")
+}
+
+// sensitiveKeys поля, значения которых не пишутся никогда
+var sensitiveKeys = []string{"key", "secret", "token", "password", "authorization"}
+
+// redactAttr скрывает значения чувствительных полей и чистит строки через Redact
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, "***")
+		}
+	}
+	if attr.Value.Kind() == slog.KindString {
+		return slog.String(attr.Key, Redact(attr.Value.String()))
+	}
+	if err, ok := attr.Value.Any().(error); ok {
+		return slog.String(attr.Key, Redact(err.Error()))
+	}
+	return attr
+}
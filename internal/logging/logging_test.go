@@ -0,0 +1,236 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setupTest настраивает логирование в буферы на время теста и возвращает
+// основной вывод и консоль
+func setupTest(t *testing.T, config Config) (output, console *bytes.Buffer) {
+	t.Helper()
+	savedLogger, savedFlags, savedOutput := slog.Default(), log.Flags(), log.Writer()
+	savedRecent := Recent
+	Recent = NewRing(100)
+	t.Cleanup(func() {
+		slog.SetDefault(savedLogger)
+		log.SetFlags(savedFlags)
+		log.SetOutput(savedOutput)
+		Recent = savedRecent
+	})
+
+	output, console = &bytes.Buffer{}, &bytes.Buffer{}
+	Setup(output, console, config)
+	return output, console
+}
+
+// useSecrets подменяет список секретов на время теста
+func useSecrets(t *testing.T, values ...string) {
+	t.Helper()
+	secretsMu.Lock()
+	saved := secrets
+	secrets = nil
+	secretsMu.Unlock()
+	t.Cleanup(func() {
+		secretsMu.Lock()
+		secrets = saved
+		secretsMu.Unlock()
+	})
+	AddSecrets(values...)
+}
+
+// records разбирает JSON-записи из вывода
+func records(t *testing.T, output *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var result []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(output.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("строка не JSON: %q", line)
+		}
+		result = append(result, record)
+	}
+	return result
+}
+
+func TestComponentLoggerFields(t *testing.T) {
+	output, _ := setupTest(t, DefaultConfig())
+
+	For("payment").Info("платеж создан", KeyUserID, int64(42), KeyPaymentID, "pay-1", KeyDuration, 1500*time.Millisecond)
+	For("generate").Error("ошибка генерации", KeyUserID, int64(7), KeyError, errors.New("таймаут"))
+
+	got := records(t, output)
+	if len(got) != 2 {
+		t.Fatalf("записей %d", len(got))
+	}
+	payment := got[0]
+	if payment["level"] != "INFO" || payment["msg"] != "платеж создан" || payment[KeyComponent] != "payment" ||
+		payment[KeyUserID] != float64(42) || payment[KeyPaymentID] != "pay-1" || payment[KeyDuration] != float64(1500*time.Millisecond) {
+		t.Errorf("запись платежа: %v", payment)
+	}
+	if _, ok := payment["time"]; !ok {
+		t.Error("нет времени записи")
+	}
+	generate := got[1]
+	if generate["level"] != "ERROR" || generate[KeyComponent] != "generate" || generate[KeyError] != "таймаут" {
+		t.Errorf("запись ошибки: %v", generate)
+	}
+}
+
+func TestLegacyPrintfBecomesStructured(t *testing.T) {
+	output, _ := setupTest(t, DefaultConfig())
+
+	log.Printf("[PAYMENT] ❌ Ошибка создания платежа: %v", errors.New("401"))
+	log.Printf("[GENERATE] ⚠️ Выбор статьи не удался")
+	log.Printf("[GENERATE] ⏱ Превышен лимит времени")
+	log.Printf("[DB] Сохранено %d пользователей", 3)
+	log.Printf("Бот запущен")
+
+	want := []struct{ level, component, msg string }{
+		{"ERROR", "payment", "❌ Ошибка создания платежа: 401"},
+		{"WARN", "generate", "⚠️ Выбор статьи не удался"},
+		{"WARN", "generate", "⏱ Превышен лимит времени"},
+		{"INFO", "db", "Сохранено 3 пользователей"},
+		{"INFO", "", "Бот запущен"},
+	}
+	got := records(t, output)
+	if len(got) != len(want) {
+		t.Fatalf("записей %d, ожидалось %d", len(got), len(want))
+	}
+	for i, w := range want {
+		component, _ := got[i][KeyComponent].(string)
+		if got[i]["level"] != w.level || component != w.component || got[i]["msg"] != w.msg {
+			t.Errorf("запись %d = %v, ожидалось %+v", i, got[i], w)
+		}
+	}
+}
+
+func TestLevelAndConsoleTee(t *testing.T) {
+	config := DefaultConfig()
+	config.Level = slog.LevelDebug
+	output, console := setupTest(t, config)
+
+	For("bot").Debug("подробности")
+	For("bot").Info("обычная запись")
+	For("bot").Warn("предупреждение")
+	log.Printf("[DB] ❌ Ошибка сохранения")
+
+	if got := len(records(t, output)); got != 4 {
+		t.Errorf("в файле %d записей, ожидалось 4", got)
+	}
+	// В консоль попадают только предупреждения и ошибки, в текстовом формате
+	lines := strings.Split(strings.TrimSpace(console.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "level=WARN") || !strings.Contains(lines[1], "level=ERROR") ||
+		!strings.Contains(lines[1], "component=db") {
+		t.Errorf("консоль: %q", console.String())
+	}
+}
+
+func TestLevelFilters(t *testing.T) {
+	config := DefaultConfig()
+	config.Level = slog.LevelWarn
+	output, _ := setupTest(t, config)
+
+	For("bot").Info("не нужна")
+	log.Printf("[BOT] тоже не нужна")
+	For("bot").Warn("нужна")
+
+	got := records(t, output)
+	if len(got) != 1 || got[0]["msg"] != "нужна" {
+		t.Errorf("записи: %v", got)
+	}
+}
+
+func TestSecretsNeverLogged(t *testing.T) {
+	useSecrets(t, "AQVN-super-secret-key", "short")
+	output, _ := setupTest(t, DefaultConfig())
+
+	For("ai").Info("запрос с ключом AQVN-super-secret-key",
+		"api_key", "AQVN-super-secret-key",
+		"Authorization", "Bearer abc",
+		"bot_token", "123:abc",
+		"password", "admin123",
+		"email", "ivan.petrov@example.com",
+		"note", "short",
+		KeyError, errors.New("401 для AQVN-super-secret-key, чек на anna@mail.ru"),
+	)
+	log.Printf("[YOOMONEY] ⚠️ Ответ: ключ AQVN-super-secret-key отклонен")
+
+	text := output.String()
+	for _, leaked := range []string{"AQVN-super-secret-key", "Bearer abc", "123:abc", "admin123", "ivan.petrov@", "anna@"} {
+		if strings.Contains(text, leaked) {
+			t.Errorf("в лог попало %q", leaked)
+		}
+	}
+
+	got := records(t, output)
+	first := got[0]
+	if first["api_key"] != "***" || first["Authorization"] != "***" || first["email"] != "i***@example.com" {
+		t.Errorf("поля: %v", first)
+	}
+	if first["msg"] != "запрос с ключом ***" || first[KeyError] != "401 для ***, чек на a***@mail.ru" {
+		t.Errorf("сообщение %q, ошибка %q", first["msg"], first[KeyError])
+	}
+	if first["note"] != "short" {
+		t.Error("короткое значение вырезано из лога")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	useSecrets(t, "0123456789abcdef")
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"ключ 0123456789abcdef", "ключ ***"},
+		{"почта john.doe+bot@mail.example.org", "почта j***@mail.example.org"},
+		{"без секретов", "без секретов"},
+		{"собака @ в тексте", "собака @ в тексте"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.text); got != tt.want {
+			t.Errorf("Redact(%q) = %q, ожидалось %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseLegacy(t *testing.T) {
+	tests := []struct {
+		line      string
+		component string
+		level     slog.Level
+		message   string
+	}{
+		{"[GENERATE] Поиск новостей", "generate", slog.LevelInfo, "Поиск новостей"},
+		{"[YOOMONEY]❌ Ошибка", "yoomoney", slog.LevelError, "❌ Ошибка"},
+		{"[PANIC] goroutine 1", "panic", slog.LevelError, "goroutine 1"},
+		{"  ⚠️ Без префикса  ", "", slog.LevelWarn, "⚠️ Без префикса"},
+		{"[generate] строчные", "", slog.LevelInfo, "[generate] строчные"},
+	}
+	for _, tt := range tests {
+		component, level, message := parseLegacy(tt.line)
+		if component != tt.component || level != tt.level || message != tt.message {
+			t.Errorf("parseLegacy(%q) = %q, %v, %q", tt.line, component, level, message)
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for value, want := range map[string]slog.Level{"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "warn": slog.LevelWarn, "error": slog.LevelError} {
+		if got, err := ParseLevel(value); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v", value, got, err)
+		}
+	}
+	if _, err := ParseLevel("trace"); err == nil {
+		t.Error("неизвестный уровень принят")
+	}
+}
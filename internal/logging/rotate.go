@@ -0,0 +1,279 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat метка времени в имени старого файла: logs-2026-01-02T15-04-05.000.txt.gz
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// RotatingFile файл лога, который по достижении размера переименовывается и сжимается.
+// Хранятся не больше MaxBackups старых файлов и не старше MaxAgeDays дней.
+// Безопасен для одновременной записи.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	now        func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+
+	// cleanupMu не дает двум фоновым сжатиям обрабатывать одни и те же файлы
+	cleanupMu sync.Mutex
+	cleanupWG sync.WaitGroup
+}
+
+// OpenFile открывает файл лога из настроек, дописывая в конец существующего
+func OpenFile(config Config) (*RotatingFile, error) {
+	return openRotatingFile(config.File, int64(config.MaxSizeMB)<<20, config.MaxBackups,
+		time.Duration(config.MaxAgeDays)*24*time.Hour, time.Now)
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int, maxAge time.Duration, now func() time.Time) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups, maxAge: maxAge, now: now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	// Файлы, оставшиеся несжатыми после прошлого запуска
+	f.cleanupWG.Add(1)
+	go f.cleanup()
+	return f, nil
+}
+
+// Path возвращает путь к текущему файлу
+func (f *RotatingFile) Path() string {
+	return f.path
+}
+
+// Write дописывает p в файл. Если запись не помещается, файл сначала ротируется;
+// одна запись не разбивается между файлами.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// Close закрывает файл и дожидается фонового сжатия
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	var err error
+	if f.file != nil {
+		err = f.file.Close()
+		f.file = nil
+	}
+	f.mu.Unlock()
+
+	f.cleanupWG.Wait()
+	return err
+}
+
+// open открывает текущий файл. Вызывается под mu.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("ошибка открытия лога %s: %w", f.path, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("ошибка чтения размера лога %s: %w", f.path, err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate переименовывает текущий файл и открывает новый. Сжатие и удаление старых
+// файлов идут в фоне, чтобы не задерживать запись. Вызывается под mu.
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("ошибка закрытия лога: %w", err)
+	}
+	f.file = nil
+
+	if err := os.Rename(f.path, f.nextBackupPath()); err != nil {
+		// Продолжаем писать в прежний файл, чтобы не потерять записи
+		if openErr := f.open(); openErr != nil {
+			return openErr
+		}
+		return fmt.Errorf("ошибка ротации лога: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+
+	f.cleanupWG.Add(1)
+	go f.cleanup()
+	return nil
+}
+
+// backupPath имя старого файла: logs.txt → logs-<время>.txt
+func (f *RotatingFile) backupPath(at time.Time) string {
+	ext := filepath.Ext(f.path)
+	base := strings.TrimSuffix(f.path, ext)
+	return base + "-" + at.Format(backupTimeFormat) + ext
+}
+
+// nextBackupPath имя для очередного старого файла. Если за миллисекунду файл
+// ротируется дважды, метка сдвигается, чтобы не затереть предыдущий.
+func (f *RotatingFile) nextBackupPath() string {
+	at := f.now()
+	for {
+		path := f.backupPath(at)
+		if !exists(path) && !exists(path+".gz") {
+			return path
+		}
+		at = at.Add(time.Millisecond)
+	}
+}
+
+// exists сообщает, есть ли файл
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// backups возвращает старые файлы, сжатые и нет, от старых к новым
+func (f *RotatingFile) backups() []string {
+	return backupFiles(f.path)
+}
+
+// backupFiles возвращает старые файлы лога path, сжатые и нет, от старых к новым
+func backupFiles(path string) []string {
+	ext := filepath.Ext(path)
+	pattern := strings.TrimSuffix(path, ext) + "-*" + ext
+	plain, _ := filepath.Glob(pattern)
+	compressed, _ := filepath.Glob(pattern + ".gz")
+
+	files := append(plain, compressed...)
+	// Метка времени в имени сортируется так же, как время
+	slices.SortFunc(files, func(a, b string) int {
+		return strings.Compare(strings.TrimSuffix(a, ".gz"), strings.TrimSuffix(b, ".gz"))
+	})
+	return files
+}
+
+// cleanup сжимает старые файлы и удаляет лишние
+func (f *RotatingFile) cleanup() {
+	defer f.cleanupWG.Done()
+	f.cleanupMu.Lock()
+	defer f.cleanupMu.Unlock()
+
+	files := f.backups()
+	for i, path := range files {
+		if strings.HasSuffix(path, ".gz") {
+			continue
+		}
+		if err := compressFile(path); err != nil {
+			log.Printf("[LOGGING] ⚠️ Не удалось сжать %s: %v", path, err)
+			continue
+		}
+		files[i] = path + ".gz"
+	}
+
+	cutoff := f.now().Add(-f.maxAge)
+	for i, path := range files {
+		expired := f.maxAge > 0 && modTime(path).Before(cutoff)
+		excess := f.maxBackups > 0 && i < len(files)-f.maxBackups
+		if !expired && !excess {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("[LOGGING] ⚠️ Не удалось удалить старый лог %s: %v", path, err)
+		}
+	}
+}
+
+// modTime время изменения файла или нулевое время, если файл недоступен
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// compressFile сжимает файл в path.gz и удаляет исходный
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	writer := gzip.NewWriter(target)
+	if _, err := io.Copy(writer, source); err != nil {
+		writer.Close()
+		target.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		target.Close()
+		os.Remove(path + ".gz")
+		return err
+	}
+	if err := target.Close(); err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	source.Close()
+	return os.Remove(path)
+}
+
+// Tail возвращает последние maxBytes байт файла, начиная с целой строки
+func Tail(path string, maxBytes int64) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка открытия лога: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения размера лога: %w", err)
+	}
+
+	offset := max(info.Size()-maxBytes, 0)
+	data := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(data, offset); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("ошибка чтения лога: %w", err)
+	}
+
+	if offset > 0 {
+		if newline := bytes.IndexByte(data, '\n'); newline >= 0 {
+			data = data[newline+1:]
+		}
+	}
+	return data, nil
+}
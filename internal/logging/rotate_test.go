@@ -0,0 +1,238 @@
+package logging
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// openTestFile открывает ротируемый лог во временном каталоге со временем clock
+func openTestFile(t *testing.T, maxSize int64, maxBackups int, maxAge time.Duration, clock func() time.Time) *RotatingFile {
+	t.Helper()
+	f, err := openRotatingFile(filepath.Join(t.TempDir(), "logs.txt"), maxSize, maxBackups, maxAge, clock)
+	if err != nil {
+		t.Fatalf("openRotatingFile: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// steppingClock время, которое сдвигается на секунду при каждом обращении
+func steppingClock() func() time.Time {
+	var mu sync.Mutex
+	current := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	return func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		current = current.Add(time.Second)
+		return current
+	}
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotatingFileRollsOverAtMaxSize(t *testing.T) {
+	f := openTestFile(t, 100, 0, 0, steppingClock())
+	line := strings.Repeat("a", 39) + "\n"
+
+	// Две строки по 40 байт помещаются, третья уже нет
+	for i := 0; i < 2; i++ {
+		if _, err := f.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if backups := f.backups(); len(backups) != 0 {
+		t.Fatalf("ротация до превышения размера: %v", backups)
+	}
+
+	if _, err := f.Write([]byte(line)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups := f.backups()
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".txt.gz") {
+		t.Fatalf("старые файлы: %v", backups)
+	}
+	if !strings.Contains(filepath.Base(backups[0]), "logs-2026-01-02T15-04-06.000") {
+		t.Errorf("имя старого файла %s", backups[0])
+	}
+	if got := readGzip(t, backups[0]); got != line+line {
+		t.Errorf("в старом файле %q", got)
+	}
+	if current, _ := os.ReadFile(f.Path()); string(current) != line {
+		t.Errorf("в текущем файле %q", current)
+	}
+}
+
+func TestRotatingFileKeepsOversizedRecordWhole(t *testing.T) {
+	f := openTestFile(t, 10, 0, 0, steppingClock())
+	record := strings.Repeat("б", 20) + "\n"
+
+	// Запись больше предела не разбивается и не ротирует пустой файл
+	if _, err := f.Write([]byte(record)); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if backups := f.backups(); len(backups) != 0 {
+		t.Errorf("пустой файл ротирован: %v", backups)
+	}
+	if current, _ := os.ReadFile(f.Path()); string(current) != record {
+		t.Errorf("в файле %q", current)
+	}
+}
+
+func TestRotatingFileAppendsAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs.txt")
+	if err := os.WriteFile(path, []byte(strings.Repeat("x", 90)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Размер существующего файла учитывается: первая же запись ротирует его
+	f, err := openRotatingFile(path, 100, 0, 0, steppingClock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("новая запись\n"))
+	f.Close()
+
+	if backups := f.backups(); len(backups) != 1 {
+		t.Errorf("старые файлы: %v", backups)
+	}
+}
+
+func TestRotatingFileRemovesExcessBackups(t *testing.T) {
+	f := openTestFile(t, 10, 2, 0, steppingClock())
+	for i := 0; i < 5; i++ {
+		f.Write([]byte(fmt.Sprintf("запись %d\n", i)))
+	}
+	f.Close()
+
+	backups := f.backups()
+	if len(backups) != 2 {
+		t.Fatalf("старые файлы: %v", backups)
+	}
+	// Остаются самые новые
+	if got := readGzip(t, backups[1]); got != "запись 3\n" {
+		t.Errorf("последний старый файл: %q", got)
+	}
+}
+
+func TestRotatingFileRemovesExpiredBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs.txt")
+	old := filepath.Join(dir, "logs-2025-01-01T00-00-00.000.txt.gz")
+	fresh := filepath.Join(dir, "logs-2026-01-01T00-00-00.000.txt.gz")
+	for _, name := range []string{old, fresh} {
+		if err := os.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	os.Chtimes(old, now.Add(-48*time.Hour), now.Add(-48*time.Hour))
+
+	f, err := openRotatingFile(path, 0, 0, 24*time.Hour, time.Now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	if exists(old) || !exists(fresh) {
+		t.Errorf("старый файл есть: %t, свежий есть: %t", exists(old), exists(fresh))
+	}
+}
+
+func TestRotatingFileConcurrentWrites(t *testing.T) {
+	f := openTestFile(t, 500, 0, 0, time.Now)
+
+	const writers, records = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < records; i++ {
+				fmt.Fprintf(f, "writer=%d record=%03d %s\n", w, i, strings.Repeat("z", 20))
+			}
+		}()
+	}
+	wg.Wait()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Ни одна запись не потеряна и не разорвана между файлами
+	var all bytes.Buffer
+	for _, backup := range f.backups() {
+		all.WriteString(readGzip(t, backup))
+	}
+	current, _ := os.ReadFile(f.Path())
+	all.Write(current)
+
+	lines := strings.Split(strings.TrimSpace(all.String()), "\n")
+	if len(lines) != writers*records {
+		t.Fatalf("строк %d, ожидалось %d", len(lines), writers*records)
+	}
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "writer=") || !strings.HasSuffix(line, strings.Repeat("z", 20)) {
+			t.Fatalf("разорванная строка %q", line)
+		}
+	}
+	if len(f.backups()) < 2 {
+		t.Errorf("старых файлов %d: ротация не сработала", len(f.backups()))
+	}
+}
+
+func TestRotatingFileWriteAfterClose(t *testing.T) {
+	f := openTestFile(t, 0, 0, 0, time.Now)
+	f.Close()
+	if _, err := f.Write([]byte("поздно\n")); err == nil {
+		t.Error("запись в закрытый файл без ошибки")
+	}
+}
+
+func TestTail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.txt")
+	os.WriteFile(path, []byte("первая строка\nвторая\nтретья\n"), 0o644)
+
+	got, err := Tail(path, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Начало обрезанной строки отбрасывается
+	if string(got) != "третья\n" {
+		t.Errorf("Tail = %q", got)
+	}
+	if got, _ := Tail(path, 1000); !strings.HasPrefix(string(got), "первая") {
+		t.Errorf("Tail всего файла = %q", got)
+	}
+	if _, err := Tail(filepath.Join(t.TempDir(), "нет.txt"), 10); err == nil {
+		t.Error("нет ошибки для отсутствующего файла")
+	}
+}
@@ -0,0 +1,228 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecentSize сколько последних записей хранит Recent
+const RecentSize = 10000
+
+// recentComponents компоненты, записи которых попадают в Recent на любом уровне:
+// по ним видно, чем закончились генерации
+var recentComponents = map[string]bool{
+	"generate": true,
+	"rewrite":  true,
+	"testgen":  true,
+}
+
+// Ring кольцевой буфер последних строк лога. Реализует io.Writer: каждая строка
+// запоминается вместе со временем записи.
+type Ring struct {
+	mu    sync.Mutex
+	lines []ringLine
+	next  int
+	full  bool
+}
+
+type ringLine struct {
+	at   time.Time
+	text string
+}
+
+// NewRing создает буфер на size строк
+func NewRing(size int) *Ring {
+	return &Ring{lines: make([]ringLine, size)}
+}
+
+// Recent буфер предупреждений, ошибок и записей о генерациях, который заполняет Setup
+var Recent = NewRing(RecentSize)
+
+func (r *Ring) Write(p []byte) (int, error) {
+	now := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, line := range strings.Split(string(bytes.TrimRight(p, "\n")), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		r.lines[r.next] = ringLine{at: now, text: line}
+		r.next = (r.next + 1) % len(r.lines)
+		r.full = r.full || r.next == 0
+	}
+	return len(p), nil
+}
+
+// Lines возвращает строки, записанные не раньше since, от старых к новым
+func (r *Ring) Lines(since time.Time) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ordered := r.lines[:r.next]
+	if r.full {
+		ordered = append(append([]ringLine(nil), r.lines[r.next:]...), r.lines[:r.next]...)
+	}
+	var result []string
+	for _, line := range ordered {
+		if !line.at.Before(since) {
+			result = append(result, line.text)
+		}
+	}
+	return result
+}
+
+// recentHandler пропускает в буфер записи уровня warn и выше и записи компонентов
+// из recentComponents
+type recentHandler struct {
+	next      slog.Handler
+	component string
+}
+
+func (h recentHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h recentHandler) Handle(ctx context.Context, record slog.Record) error {
+	keep := record.Level >= slog.LevelWarn || recentComponents[h.component]
+	if !keep {
+		record.Attrs(func(attr slog.Attr) bool {
+			if attr.Key == KeyComponent && recentComponents[attr.Value.String()] {
+				keep = true
+				return false
+			}
+			return true
+		})
+	}
+	if !keep {
+		return nil
+	}
+	return h.next.Handle(ctx, record)
+}
+
+func (h recentHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	component := h.component
+	for _, attr := range attrs {
+		if attr.Key == KeyComponent {
+			component = attr.Value.String()
+		}
+	}
+	return recentHandler{next: h.next.WithAttrs(attrs), component: component}
+}
+
+func (h recentHandler) WithGroup(name string) slog.Handler {
+	return recentHandler{next: h.next.WithGroup(name), component: h.component}
+}
+
+// lineTime время в начале строки лога в формате JSON или text
+var lineTime = regexp.MustCompile(`^(?:\{"time":"|time=)([^" ]+)`)
+
+// parseLineTime возвращает время записи или false, если его не удалось разобрать
+func parseLineTime(line string) (time.Time, bool) {
+	match := lineTime.FindStringSubmatch(line)
+	if match == nil {
+		return time.Time{}, false
+	}
+	at, err := time.Parse(time.RFC3339Nano, match[1])
+	return at, err == nil
+}
+
+// Search ищет строки, содержащие query без учета регистра, записанные не раньше since:
+// в файле лога path и его старых файлах, затем в Recent — там есть записи, которые
+// не попали в файл (лог без файла). Возвращает не больше limit последних строк,
+// очищенных Redact. При отмене ctx возвращает найденное и ошибку контекста.
+func Search(ctx context.Context, path, query string, since time.Time, limit int) ([]string, error) {
+	query = strings.ToLower(query)
+	matches := func(line string) bool {
+		if !strings.Contains(strings.ToLower(line), query) {
+			return false
+		}
+		at, ok := parseLineTime(line)
+		return !ok || !at.Before(since)
+	}
+
+	var result []string
+	seen := make(map[string]bool)
+	if path != "" {
+		files := append(backupFiles(path), path)
+		for _, file := range files {
+			// Файл, измененный до начала периода, не может содержать новых записей
+			if modTime(file).Before(since) {
+				continue
+			}
+			if err := searchFile(ctx, file, matches, func(line string) {
+				result = append(result, line)
+				seen[line] = true
+			}); err != nil {
+				return tail(result, limit), err
+			}
+		}
+	}
+
+	for _, line := range Recent.Lines(since) {
+		if !seen[line] && matches(line) {
+			result = append(result, line)
+		}
+	}
+
+	result = tail(result, limit)
+	for i, line := range result {
+		result[i] = Redact(line)
+	}
+	return result, nil
+}
+
+// searchFile передает found строки файла, для которых matches возвращает true.
+// Сжатые старые файлы читаются через gzip.
+func searchFile(ctx context.Context, path string, matches func(string) bool, found func(string)) error {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка открытия лога %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("ошибка чтения сжатого лога %s: %w", path, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for lines := 0; scanner.Scan(); lines++ {
+		if lines%1000 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if line := scanner.Text(); matches(line) {
+			found(line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("ошибка чтения лога %s: %w", path, err)
+	}
+	return nil
+}
+
+// tail возвращает не больше limit последних строк
+func tail(lines []string, limit int) []string {
+	if limit > 0 && len(lines) > limit {
+		return lines[len(lines)-limit:]
+	}
+	return lines
+}
@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useRecent подменяет Recent пустым буфером на size строк
+func useRecent(t *testing.T, size int) {
+	t.Helper()
+	saved := Recent
+	Recent = NewRing(size)
+	t.Cleanup(func() { Recent = saved })
+}
+
+// logLine строка лога в формате JSON, записанная в at
+func logLine(at time.Time, level, component, message string) string {
+	return fmt.Sprintf(`{"time":"%s","level":"%s","msg":"%s","component":"%s"}`,
+		at.Format(time.RFC3339Nano), level, message, component)
+}
+
+// writeLog записывает строки в файл лога и выставляет время изменения modified
+func writeLog(t *testing.T, path string, modified time.Time, lines ...string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRingKeepsLastLines(t *testing.T) {
+	ring := NewRing(3)
+	before := time.Now()
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(ring, "строка %d\n", i)
+	}
+	// Пустые строки не занимают места, несколько строк за одну запись разбиваются
+	ring.Write([]byte("\n\n"))
+
+	if got, want := ring.Lines(before), []string{"строка 3", "строка 4", "строка 5"}; !slices.Equal(got, want) {
+		t.Errorf("Lines = %q, ожидалось %q", got, want)
+	}
+	ring.Write([]byte("строка 6\nстрока 7"))
+	if got, want := ring.Lines(before), []string{"строка 5", "строка 6", "строка 7"}; !slices.Equal(got, want) {
+		t.Errorf("после переполнения Lines = %q, ожидалось %q", got, want)
+	}
+	if got := ring.Lines(time.Now().Add(time.Minute)); len(got) != 0 {
+		t.Errorf("строки из будущего: %q", got)
+	}
+}
+
+func TestRecentKeepsWarningsAndGenerations(t *testing.T) {
+	setupTest(t, Config{Format: FormatJSON, Level: slog.LevelDebug})
+
+	For("payment").Info("платеж создан")
+	For("payment").Warn("платеж завис")
+	For("generate").Info("пост готов")
+	slog.Info("генерация", KeyComponent, "rewrite")
+	slog.Error("ошибка без компонента")
+
+	lines := Recent.Lines(time.Time{})
+	if len(lines) != 4 {
+		t.Fatalf("в буфере %d строк: %q", len(lines), lines)
+	}
+	for i, want := range []string{"платеж завис", "пост готов", "генерация", "ошибка без компонента"} {
+		if !strings.Contains(lines[i], want) {
+			t.Errorf("строка %d: %q, ожидалось %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	useRecent(t, 100)
+	useSecrets(t, "sk-live-1234567890")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "logs.txt")
+	now := time.Now()
+
+	// Старый файл целиком старше периода поиска и не читается
+	writeLog(t, filepath.Join(dir, "logs-2026-01-01T00-00-00.000.txt"), now.Add(-72*time.Hour),
+		logLine(now.Add(-72*time.Hour), "ERROR", "generate", "пользователь 42: старая ошибка"))
+	backup := filepath.Join(dir, "logs-2026-01-02T00-00-00.000.txt")
+	writeLog(t, backup, now.Add(-2*time.Hour),
+		logLine(now.Add(-30*time.Hour), "ERROR", "generate", "пользователь 42: вне периода"),
+		logLine(now.Add(-3*time.Hour), "WARN", "payment", "пользователь 42: платеж завис"),
+		logLine(now.Add(-3*time.Hour), "WARN", "payment", "пользователь 7: платеж завис"))
+	if err := compressFile(backup); err != nil {
+		t.Fatal(err)
+	}
+	writeLog(t, path, now,
+		logLine(now.Add(-time.Hour), "ERROR", "generate", "пользователь 42: ключ sk-live-1234567890 отклонен"),
+		"пользователь 42: строка без времени",
+		logLine(now.Add(-time.Hour), "INFO", "generate", "ПОЛЬЗОВАТЕЛЬ 42: a.ivanov@example.com"))
+	// Строка из файла, попавшая и в буфер, не повторяется; строка только из буфера находится
+	fmt.Fprintln(Recent, logLine(now.Add(-time.Hour), "ERROR", "generate", "пользователь 42: ключ sk-live-1234567890 отклонен"))
+	fmt.Fprintln(Recent, logLine(now, "WARN", "bot", "пользователь 42: только в памяти"))
+
+	lines, err := Search(context.Background(), path, "пользователь 42", now.Add(-24*time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"платеж завис", "***", "строка без времени", "a***@example.com", "только в памяти"}
+	if len(lines) != len(want) {
+		t.Fatalf("найдено %d строк: %q", len(lines), lines)
+	}
+	for i := range want {
+		if !strings.Contains(lines[i], want[i]) {
+			t.Errorf("строка %d: %q, ожидалось %q", i, lines[i], want[i])
+		}
+		if strings.Contains(lines[i], "sk-live") || strings.Contains(lines[i], "ivanov") {
+			t.Errorf("строка %d не очищена: %q", i, lines[i])
+		}
+	}
+
+	// Ограничение оставляет последние строки
+	limited, err := Search(context.Background(), path, "пользователь 42", now.Add(-24*time.Hour), 2)
+	if err != nil || len(limited) != 2 || !strings.Contains(limited[1], "только в памяти") {
+		t.Errorf("последние 2 строки: %q, %v", limited, err)
+	}
+}
+
+func TestSearchWithoutFile(t *testing.T) {
+	useRecent(t, 10)
+	fmt.Fprintln(Recent, logLine(time.Now(), "WARN", "bot", "chat 42 завис"))
+	fmt.Fprintln(Recent, logLine(time.Now(), "WARN", "bot", "chat 7 завис"))
+
+	lines, err := Search(context.Background(), "", "CHAT 42", time.Now().Add(-time.Hour), 10)
+	if err != nil || len(lines) != 1 || !strings.Contains(lines[0], "chat 42") {
+		t.Errorf("Search = %q, %v", lines, err)
+	}
+}
+
+func TestSearchCanceled(t *testing.T) {
+	useRecent(t, 10)
+	path := filepath.Join(t.TempDir(), "logs.txt")
+	lines := make([]string, 5000)
+	for i := range lines {
+		lines[i] = logLine(time.Now(), "WARN", "bot", "совпадение")
+	}
+	writeLog(t, path, time.Now(), lines...)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Search(ctx, path, "совпадение", time.Time{}, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("ошибка отмененного поиска: %v", err)
+	}
+}
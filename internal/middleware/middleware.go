@@ -0,0 +1,271 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"AIGenerator/internal/reporting"
+)
+
+const (
+	// DefaultMaxBodyBytes предельный размер тела входящего запроса
+	DefaultMaxBodyBytes = 1 << 20
+	// DefaultRatePerSecond и DefaultRateBurst сколько запросов принимается с одного IP:
+	// в среднем в секунду и подряд
+	DefaultRatePerSecond = 10
+	DefaultRateBurst     = 20
+	// limiterIdleTTL через сколько без запросов счетчик IP удаляется
+	limiterIdleTTL = 10 * time.Minute
+)
+
+// Middleware оборачивает обработчик
+type Middleware func(http.Handler) http.Handler
+
+// Chain оборачивает handler в middlewares; первый из них выполняется первым
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// Standard общая обвязка входящих HTTP-серверов: восстановление после паники,
+// журнал запросов, ограничение частоты по IP и размера тела
+func Standard(name string, handler http.Handler) http.Handler {
+	return Chain(handler,
+		Recover(name),
+		AccessLog(name),
+		RateLimit(DefaultRatePerSecond, DefaultRateBurst),
+		MaxBody(DefaultMaxBodyBytes),
+	)
+}
+
+// statusWriter запоминает код ответа и размер тела
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += n
+	return n, err
+}
+
+// Unwrap дает http.ResponseController доступ к Flush исходного ResponseWriter:
+// без него не работают потоковые ответы pprof
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// AccessLog пишет в лог каждый запрос: метод, путь, код ответа, размер, время и IP
+func AccessLog(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(recorder, r)
+
+			status := recorder.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			log.Printf("[HTTP] %s %s %s %d %dB %v ip=%s", name, r.Method, r.URL.Path, status,
+				recorder.bytes, time.Since(start).Round(time.Millisecond), ClientIP(r))
+		})
+	}
+}
+
+// Recover перехватывает панику обработчика и отвечает 500, не останавливая процесс
+func Recover(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer func() {
+				p := recover()
+				if p == nil {
+					return
+				}
+				// http.ErrAbortHandler обрывает ответ намеренно, его обрабатывает сам сервер
+				if p == http.ErrAbortHandler {
+					panic(p)
+				}
+				stack := debug.Stack()
+				log.Printf("[PANIC] ❌ Паника в HTTP-обработчике %s %s: %v\n%s", name, r.URL.Path, p, stack)
+				reporting.Capture(reporting.Event{
+					Level:     reporting.LevelError,
+					Operation: "http_panic",
+					Message:   fmt.Sprintf("паника в HTTP-обработчике %s %s: %v", name, r.URL.Path, p),
+					Tags:      map[string]string{"server": name, "path": r.URL.Path},
+					Stack:     string(stack),
+				})
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MaxBody отклоняет запросы с телом больше limit байт: заявленный размер проверяется
+// сразу, а чтение тела без Content-Length обрывается на limit
+func MaxBody(limit int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSecret пропускает только запросы, в заголовке header которых передан secret.
+// Сравнение за постоянное время, чтобы секрет нельзя было подобрать по задержке ответа.
+// Пустой secret отклоняет все запросы.
+func RequireSecret(header, secret string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !SecretEqual(r.Header.Get(header), secret) {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// BasicAuth требует HTTP Basic с заданными именем и паролем
+func BasicAuth(realm, user, password string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotUser, gotPassword, ok := r.BasicAuth()
+			// Оба сравнения выполняются всегда, чтобы время ответа не выдавало верное имя
+			userOK := SecretEqual(gotUser, user)
+			passwordOK := SecretEqual(gotPassword, password)
+			if !ok || !userOK || !passwordOK {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", realm))
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// SecretEqual сравнивает значение с секретом за постоянное время. Пустой секрет
+// не совпадает ни с чем.
+func SecretEqual(got, secret string) bool {
+	if secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(secret)) == 1
+}
+
+// LocalOnly пропускает только запросы с loopback-адресов
+func LocalOnly() Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := net.ParseIP(ClientIP(r))
+			if ip == nil || !ip.IsLoopback() {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ClientIP адрес клиента из соединения. X-Forwarded-For не учитывается:
+// его может подставить сам клиент.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// RateLimit ограничивает частоту запросов с одного IP: в среднем perSecond в секунду,
+// подряд не больше burst. Лишние запросы получают 429.
+func RateLimit(perSecond float64, burst int) Middleware {
+	limiter := newLimiter(perSecond, burst)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !limiter.allow(ClientIP(r), time.Now()) {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limiter token bucket на каждый IP
+type limiter struct {
+	mu        sync.Mutex
+	perSecond float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(perSecond float64, burst int) *limiter {
+	return &limiter{
+		perSecond: perSecond,
+		burst:     float64(max(burst, 1)),
+		buckets:   make(map[string]*bucket),
+	}
+}
+
+// allow списывает токен ip и возвращает false, если токенов нет
+func (l *limiter) allow(ip string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Счетчики давно не обращавшихся IP удаляются, чтобы карта не росла бесконечно
+	if now.Sub(l.lastSweep) > limiterIdleTTL {
+		for key, b := range l.buckets {
+			if now.Sub(b.last) > limiterIdleTTL {
+				delete(l.buckets, key)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.perSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echo отвечает телом запроса; ошибка чтения тела превращается в 413
+var echo = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.Write(body)
+})
+
+// serve выполняет запрос через handler с адреса remoteAddr
+func serve(handler http.Handler, r *http.Request, remoteAddr string) *httptest.ResponseRecorder {
+	if remoteAddr != "" {
+		r.RemoteAddr = remoteAddr
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, r)
+	return recorder
+}
+
+// useLog перехватывает стандартный лог на время теста
+func useLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var output bytes.Buffer
+	savedOutput, savedFlags := log.Writer(), log.Flags()
+	log.SetOutput(&output)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(savedOutput)
+		log.SetFlags(savedFlags)
+	})
+	return &output
+}
+
+func TestMaxBody(t *testing.T) {
+	handler := MaxBody(10)
+	tests := []struct {
+		name string
+		body string
+		// chunked тело без Content-Length
+		chunked    bool
+		wantStatus int
+	}{
+		{"в пределах", "0123456789", false, http.StatusOK},
+		{"заявлено больше", "0123456789A", false, http.StatusRequestEntityTooLarge},
+		{"без длины больше", "0123456789A", true, http.StatusRequestEntityTooLarge},
+		{"без длины в пределах", "0123", true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tt.body))
+			if tt.chunked {
+				r.ContentLength = -1
+			}
+			got := serve(handler(echo), r, "")
+			if got.Code != tt.wantStatus {
+				t.Errorf("код %d, ожидался %d", got.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && got.Body.String() != tt.body {
+				t.Errorf("тело %q", got.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireSecret(t *testing.T) {
+	tests := []struct {
+		name       string
+		secret     string
+		header     string
+		wantStatus int
+	}{
+		{"верный секрет", "s3cret", "s3cret", http.StatusOK},
+		{"неверный секрет", "s3cret", "s3creT", http.StatusUnauthorized},
+		{"префикс секрета", "s3cret", "s3c", http.StatusUnauthorized},
+		{"без заголовка", "s3cret", "", http.StatusUnauthorized},
+		// Ненастроенный секрет не открывает доступ пустому заголовку
+		{"секрет не задан", "", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader("ok"))
+			if tt.header != "" {
+				r.Header.Set("X-Telegram-Bot-Api-Secret-Token", tt.header)
+			}
+			got := serve(RequireSecret("X-Telegram-Bot-Api-Secret-Token", tt.secret)(echo), r, "")
+			if got.Code != tt.wantStatus {
+				t.Errorf("код %d, ожидался %d", got.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	handler := BasicAuth("metrics", "admin", "pa55word")(echo)
+	tests := []struct {
+		name           string
+		user, password string
+		set            bool
+		wantStatus     int
+	}{
+		{"верные данные", "admin", "pa55word", true, http.StatusOK},
+		{"неверный пароль", "admin", "wrong", true, http.StatusUnauthorized},
+		{"неверное имя", "root", "pa55word", true, http.StatusUnauthorized},
+		{"без авторизации", "", "", false, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.set {
+				r.SetBasicAuth(tt.user, tt.password)
+			}
+			got := serve(handler, r, "")
+			if got.Code != tt.wantStatus {
+				t.Errorf("код %d, ожидался %d", got.Code, tt.wantStatus)
+			}
+			if challenge := got.Header().Get("WWW-Authenticate"); (tt.wantStatus == http.StatusUnauthorized) != (challenge == `Basic realm="metrics"`) {
+				t.Errorf("WWW-Authenticate: %q", challenge)
+			}
+		})
+	}
+}
+
+func TestLocalOnly(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		wantStatus int
+	}{
+		{"127.0.0.1:5000", http.StatusOK},
+		{"[::1]:5000", http.StatusOK},
+		{"203.0.113.7:5000", http.StatusForbidden},
+		{"мусор", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)
+		// Заголовок прокси не делает запрос локальным
+		r.Header.Set("X-Forwarded-For", "127.0.0.1")
+		if got := serve(LocalOnly()(echo), r, tt.remoteAddr); got.Code != tt.wantStatus {
+			t.Errorf("%s: код %d, ожидался %d", tt.remoteAddr, got.Code, tt.wantStatus)
+		}
+	}
+}
+
+func TestRateLimitBurstFromOneIP(t *testing.T) {
+	handler := RateLimit(1, 3)(echo)
+	request := func(remoteAddr string) int {
+		return serve(handler, httptest.NewRequest(http.MethodGet, "/health", nil), remoteAddr).Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := request("198.51.100.1:1000"); code != http.StatusOK {
+			t.Fatalf("запрос %d из пачки: код %d", i+1, code)
+		}
+	}
+	// Порт другой, адрес тот же: лимит общий
+	over := serve(handler, httptest.NewRequest(http.MethodGet, "/health", nil), "198.51.100.1:2000")
+	if over.Code != http.StatusTooManyRequests || over.Header().Get("Retry-After") != "1" {
+		t.Errorf("запрос сверх пачки: код %d, Retry-After %q", over.Code, over.Header().Get("Retry-After"))
+	}
+	// Другой IP не страдает от чужой пачки
+	if code := request("198.51.100.2:1000"); code != http.StatusOK {
+		t.Errorf("другой IP: код %d", code)
+	}
+}
+
+func TestLimiterRefill(t *testing.T) {
+	l := newLimiter(2, 2)
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	if !l.allow("a", now) || !l.allow("a", now) || l.allow("a", now) {
+		t.Fatal("пачка из двух запросов")
+	}
+	// За полсекунды набегает один токен, но не больше burst
+	if !l.allow("a", now.Add(500*time.Millisecond)) || l.allow("a", now.Add(500*time.Millisecond)) {
+		t.Error("пополнение за полсекунды")
+	}
+	later := now.Add(time.Hour)
+	if !l.allow("a", later) || !l.allow("a", later) || l.allow("a", later) {
+		t.Error("после простоя токенов больше burst")
+	}
+
+	// Счетчики давно не обращавшихся IP удаляются
+	l.allow("b", later)
+	l.allow("c", later.Add(2*limiterIdleTTL))
+	if _, ok := l.buckets["b"]; ok || len(l.buckets) != 1 {
+		t.Errorf("счетчики после очистки: %v", l.buckets)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	output := useLog(t)
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic("сбой") })
+
+	got := serve(Recover("payments")(panicking), httptest.NewRequest(http.MethodPost, "/yookassa", nil), "")
+	if got.Code != http.StatusInternalServerError {
+		t.Errorf("код %d, ожидался 500", got.Code)
+	}
+	if !strings.Contains(output.String(), "Паника в HTTP-обработчике payments /yookassa: сбой") {
+		t.Errorf("лог: %q", output.String())
+	}
+
+	// Намеренный обрыв ответа передается серверу
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("паника %v, ожидалась http.ErrAbortHandler", p)
+		}
+	}()
+	aborting := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { panic(http.ErrAbortHandler) })
+	serve(Recover("payments")(aborting), httptest.NewRequest(http.MethodGet, "/", nil), "")
+}
+
+func TestAccessLog(t *testing.T) {
+	output := useLog(t)
+	created := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("12345"))
+	})
+
+	serve(AccessLog("health")(created), httptest.NewRequest(http.MethodPut, "/item", nil), "192.0.2.9:4000")
+	serve(AccessLog("health")(echo), httptest.NewRequest(http.MethodGet, "/live", nil), "192.0.2.9:4000")
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], "[HTTP] health PUT /item 201 5B ") || !strings.HasSuffix(lines[0], " ip=192.0.2.9") ||
+		!strings.HasPrefix(lines[1], "[HTTP] health GET /live 200 0B ") {
+		t.Errorf("журнал запросов: %q", lines)
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	serve(Chain(echo, mark("первый"), mark("второй")), httptest.NewRequest(http.MethodGet, "/", nil), "")
+	if strings.Join(order, ",") != "первый,второй" {
+		t.Errorf("порядок %v", order)
+	}
+}
+
+func TestStandardRejectsOversizedBody(t *testing.T) {
+	useLog(t)
+	body := strings.NewReader(strings.Repeat("x", DefaultMaxBodyBytes+1))
+	got := serve(Standard("payments", echo), httptest.NewRequest(http.MethodPost, "/yookassa", body), "")
+	if got.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("код %d, ожидался 413", got.Code)
+	}
+}
@@ -1,12 +1,24 @@
 package news
 
 import (
+	"context"
+	"errors"
 	"log"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+// defaultCacheTTL время, в течение которого статьи источника считаются свежими
+const defaultCacheTTL = 10 * time.Minute
+
+// maxArticleAge статьи старше этого возраста не используются
+const maxArticleAge = 7 * 24 * time.Hour
+
+// maxPrefetchStagger максимальная пауза между запросами к источникам при прогреве кэша
+const maxPrefetchStagger = 5 * time.Second
+
 // Синонимы для расширения поиска
 var synonyms = map[string][]string{
 	// Технологии
@@ -37,13 +49,61 @@ var synonyms = map[string][]string{
 
 // NewsAggregator управляет сбором и фильтрацией новостей
 type NewsAggregator struct {
-	sources []NewsSource
+	sources  []NewsSource
+	cache    map[string]*sourceCache
+	health   map[string]*sourceHealth
+	weights  map[string]float64
+	cacheTTL time.Duration
+	mu       sync.RWMutex
+
+	// semanticWeight вес смысловой близости, с которым включается семантический поиск
+	semanticWeight float64
+
+	// embeddings векторы для семантического поиска; nil, если он выключен
+	embeddings *embeddingIndex
+
+	prefetchCancel context.CancelFunc
+	prefetchDone   chan struct{}
+
+	// after возвращает канал, срабатывающий через заданное время; подменяется в тестах
+	after func(time.Duration) <-chan time.Time
+}
+
+// sourceCache хранит последние статьи, полученные из источника
+type sourceCache struct {
+	articles   []Article
+	fetchedAt  time.Time
+	validators CacheValidators
+}
+
+// Config настройки агрегатора новостей
+type Config struct {
+	// CacheTTL сколько статьи источника считаются свежими
+	CacheTTL time.Duration
+	// SemanticWeight доля смысловой близости в оценке совпадения с запросом (0–1)
+	SemanticWeight float64
+	// PrefetchInterval период фонового обновления кэша; 0 — без фонового обновления
+	PrefetchInterval time.Duration
+}
+
+// DefaultConfig возвращает настройки агрегатора по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		CacheTTL:       defaultCacheTTL,
+		SemanticWeight: defaultSemanticWeight,
+	}
 }
 
 // NewNewsAggregator создает новый агрегатор новостей
-func NewNewsAggregator() *NewsAggregator {
+func NewNewsAggregator(config Config) *NewsAggregator {
 	return &NewsAggregator{
-		sources: make([]NewsSource, 0),
+		sources:        make([]NewsSource, 0),
+		cache:          make(map[string]*sourceCache),
+		health:         make(map[string]*sourceHealth),
+		weights:        make(map[string]float64),
+		cacheTTL:       config.CacheTTL,
+		semanticWeight: config.SemanticWeight,
+		after:          time.After,
 	}
 }
 
@@ -56,36 +116,112 @@ func (na *NewsAggregator) AddDefaultSources() {
 	log.Printf("[NEWS] Добавлено %d источников новостей", len(defaultSources))
 }
 
-// FindRelevantArticles находит релевантные статьи по ключевым словам
-func (na *NewsAggregator) FindRelevantArticles(keywords string, maxArticles int) ([]Article, error) {
-	log.Printf("[NEWS] Поиск новостей по теме: %s", keywords)
+// SearchDiagnostics объясняет результат поиска: сколько статей отсеяно на каждом этапе
+type SearchDiagnostics struct {
+	SourcesTotal      int
+	SourcesFailed     int
+	Fetched           int
+	OtherSources      int
+	OutOfWindow       int
+	ContentFiltered   int
+	Excluded          int
+	Scored            int
+	BestRejectedScore float64
+	BestRejectedTitle string
+	// TopScore релевантность лучшей найденной статьи
+	TopScore float64
+	// FetchDuration сколько заняла загрузка статей из источников; остальное время
+	// поиска — фильтрация и ранжирование
+	FetchDuration time.Duration
+}
 
-	// Получаем все статьи из всех источников
-	allArticles, err := na.FetchAllArticles()
+// SearchOptions параметры поиска статей
+type SearchOptions struct {
+	// MaxAge максимальный возраст статьи (0 — по умолчанию 7 дней)
+	MaxAge time.Duration
+	// MinScore минимальная релевантность статьи (0 — любая положительная)
+	MinScore float64
+	// SourceFilter ограничивает поиск указанными источниками (пусто — все)
+	SourceFilter []string
+}
+
+// ErrNoArticlesInWindow означает, что статьи есть, но ни одна не попала в выбранный период
+var ErrNoArticlesInWindow = errors.New("за выбранный период новостей нет")
+
+// maxAge возвращает эффективный максимальный возраст статьи
+func (o SearchOptions) maxAge() time.Duration {
+	if o.MaxAge <= 0 || o.MaxAge > maxArticleAge {
+		return maxArticleAge
+	}
+	return o.MaxAge
+}
+
+// FindRelevantArticles находит релевантные статьи по ключевым словам.
+// Вместе с результатом возвращает диагностику, объясняющую, куда делись статьи.
+func (na *NewsAggregator) FindRelevantArticles(ctx context.Context, keywords string, maxArticles int, opts SearchOptions) ([]Article, SearchDiagnostics, error) {
+	log.Printf("[NEWS] Поиск новостей по теме: %s (период: %v)", keywords, opts.maxAge())
+
+	diag := SearchDiagnostics{SourcesTotal: len(na.sources)}
+
+	query, err := ParseQuery(keywords)
 	if err != nil {
-		log.Printf("[NEWS] Ошибка получения статей: %v", err)
-		return nil, err
+		log.Printf("[NEWS] Ошибка разбора запроса: %v", err)
+		return nil, diag, err
+	}
+
+	// Получаем все статьи из всех источников
+	fetchStarted := time.Now()
+	allArticles, failedSources := na.fetchAll(ctx)
+	diag.FetchDuration = time.Since(fetchStarted)
+	diag.SourcesFailed = failedSources
+	diag.Fetched = len(allArticles)
+
+	// Время на запрос истекло: не выдаем частичный результат
+	if err := ctx.Err(); err != nil {
+		log.Printf("[NEWS] ⚠️ Поиск прерван: %v", err)
+		return nil, diag, err
 	}
 
 	log.Printf("[NEWS] Получено %d статей", len(allArticles))
 
 	if len(allArticles) == 0 {
 		log.Printf("[NEWS] ⚠️ Не получено ни одной статьи")
-		return []Article{}, nil
+		return []Article{}, diag, nil
 	}
 
-	// Фильтруем военные темы
-	articles := na.FilterOutMilitaryTopics(allArticles)
+	// Оставляем статьи из выбранных источников и периода
+	allArticles = filterBySource(allArticles, opts.SourceFilter)
+	diag.OtherSources = diag.Fetched - len(allArticles)
+	windowed := filterByAge(allArticles, opts.maxAge())
+	diag.OutOfWindow = len(allArticles) - len(windowed)
+	if len(windowed) == 0 && len(allArticles) > 0 {
+		log.Printf("[NEWS] Нет статей за период %v", opts.maxAge())
+		return nil, diag, ErrNoArticlesInWindow
+	}
+
+	// Фильтруем военные темы и исключенные пользователем слова
+	articles := na.FilterOutMilitaryTopics(windowed)
+	diag.ContentFiltered = len(windowed) - len(articles)
+	beforeExclusion := len(articles)
+	articles = na.filterExcluded(articles, query.Excluded)
+	diag.Excluded = beforeExclusion - len(articles)
 	log.Printf("[NEWS] После фильтрации осталось %d статей", len(articles))
 
 	if len(articles) == 0 {
 		log.Printf("[NEWS] Нет статей после фильтрации")
-		return []Article{}, nil
+		return []Article{}, diag, nil
 	}
 
-	// Расширяем ключевые слова синонимами
-	expandedKeywords := na.expandKeywords(keywords)
-	log.Printf("[NEWS] Расширенные ключевые слова: %v", expandedKeywords)
+	// Расширяем условия запроса синонимами
+	expandedQuery := na.expandKeywords(query)
+	log.Printf("[NEWS] Расширенные условия запроса: %v", expandedQuery)
+
+	// Смысловая близость находит статьи, в которых тема названа другими словами
+	var similarities []float64
+	index := na.semanticIndex()
+	if index != nil {
+		similarities = index.similarities(ctx, strings.Join(query.Terms(), " "), articles)
+	}
 
 	// Создаем структуру для сортировки
 	type scoredArticle struct {
@@ -96,21 +232,29 @@ func (na *NewsAggregator) FindRelevantArticles(keywords string, maxArticles int)
 	var scoredArticles []scoredArticle
 
 	// Оцениваем каждую статью
-	for _, article := range articles {
-		score := na.calculateRelevance(article, expandedKeywords)
-		if score > 0 {
+	for i, article := range articles {
+		match := keywordMatch(article, expandedQuery)
+		if similarities != nil {
+			match = blendMatch(match, similarities[i], index.weight)
+		}
+		score := na.calculateRelevance(article, match, opts.maxAge()) * na.SourceWeight(article.Source)
+		if score > 0 && score >= opts.MinScore {
 			scoredArticles = append(scoredArticles, scoredArticle{
 				article: article,
 				score:   score,
 			})
+		} else if score > diag.BestRejectedScore {
+			diag.BestRejectedScore = score
+			diag.BestRejectedTitle = article.Title
 		}
 	}
+	diag.Scored = len(scoredArticles)
 
 	log.Printf("[NEWS] Найдено %d статей с релевантностью > 0", len(scoredArticles))
 
 	if len(scoredArticles) == 0 {
 		log.Printf("[NEWS] Нет релевантных статей")
-		return []Article{}, nil
+		return []Article{}, diag, nil
 	}
 
 	// Сортируем по релевантности
@@ -118,6 +262,8 @@ func (na *NewsAggregator) FindRelevantArticles(keywords string, maxArticles int)
 		return scoredArticles[i].score > scoredArticles[j].score
 	})
 
+	diag.TopScore = scoredArticles[0].score
+
 	// Берем топ статей
 	var result []Article
 	for i := 0; i < len(scoredArticles) && i < maxArticles; i++ {
@@ -127,47 +273,180 @@ func (na *NewsAggregator) FindRelevantArticles(keywords string, maxArticles int)
 	}
 
 	log.Printf("[NEWS] Найдено %d релевантных статей по теме: %s", len(result), keywords)
-	return result, nil
+	return result, diag, nil
 }
 
-// expandKeywords расширяет ключевые слова синонимами
-func (na *NewsAggregator) expandKeywords(keywords string) []string {
-	keywords = strings.ToLower(strings.TrimSpace(keywords))
-	words := strings.Fields(keywords)
+// SetSourceWeight задает вес источника, рассчитанный по оценкам пользователей
+func (na *NewsAggregator) SetSourceWeight(sourceName string, weight float64) {
+	na.mu.Lock()
+	defer na.mu.Unlock()
+	na.weights[sourceName] = weight
+}
 
-	expanded := make([]string, 0, len(words)*2)
-	seen := make(map[string]bool)
+// SetSourceWeights задает веса нескольких источников
+func (na *NewsAggregator) SetSourceWeights(weights map[string]float64) {
+	na.mu.Lock()
+	defer na.mu.Unlock()
+	for sourceName, weight := range weights {
+		na.weights[sourceName] = weight
+	}
+}
 
-	for _, word := range words {
-		// Добавляем оригинальное слово
-		if !seen[word] {
-			expanded = append(expanded, word)
-			seen[word] = true
+// SourceWeight возвращает множитель релевантности для статей источника.
+// Вес из настроек источника имеет приоритет над рассчитанным по оценкам.
+func (na *NewsAggregator) SourceWeight(sourceName string) float64 {
+	for _, source := range na.sources {
+		if weighted, ok := source.(WeightedSource); ok && source.GetName() == sourceName && weighted.GetWeight() > 0 {
+			return weighted.GetWeight()
 		}
+	}
+
+	na.mu.RLock()
+	defer na.mu.RUnlock()
+	if weight, exists := na.weights[sourceName]; exists {
+		return weight
+	}
+	return 1.0
+}
+
+// expandKeywords расширяет каждое условие запроса синонимами
+func (na *NewsAggregator) expandKeywords(query *Query) [][]string {
+	expanded := make([][]string, 0, len(query.Clauses))
 
-		// Добавляем синонимы
-		if syns, ok := synonyms[word]; ok {
-			for _, syn := range syns {
-				if !seen[syn] {
-					expanded = append(expanded, syn)
-					seen[syn] = true
+	for _, clause := range query.Clauses {
+		alternatives := make([]string, 0, len(clause.Alternatives)*2)
+		seen := make(map[string]bool)
+
+		for _, term := range clause.Alternatives {
+			// Добавляем оригинальное слово или фразу
+			if !seen[term] {
+				alternatives = append(alternatives, term)
+				seen[term] = true
+			}
+
+			// Добавляем синонимы
+			if syns, ok := synonyms[term]; ok {
+				for _, syn := range syns {
+					syn = strings.ToLower(syn)
+					if !seen[syn] {
+						alternatives = append(alternatives, syn)
+						seen[syn] = true
+					}
 				}
 			}
 		}
+
+		expanded = append(expanded, alternatives)
 	}
 
 	return expanded
 }
 
-// FetchAllArticles собирает статьи со всех источников
-func (na *NewsAggregator) FetchAllArticles() ([]Article, error) {
+// filterBySource оставляет статьи только из указанных источников
+func filterBySource(articles []Article, sources []string) []Article {
+	if len(sources) == 0 {
+		return articles
+	}
+
+	allowed := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		allowed[strings.ToLower(source)] = true
+	}
+
+	var filtered []Article
+	for _, article := range articles {
+		if allowed[strings.ToLower(article.Source)] {
+			filtered = append(filtered, article)
+		}
+	}
+	return filtered
+}
+
+// filterByAge оставляет статьи не старше maxAge. Статьи с неизвестной датой
+// сохраняются только для периода по умолчанию.
+func filterByAge(articles []Article, maxAge time.Duration) []Article {
+	var filtered []Article
+	for _, article := range articles {
+		if article.PublishedAt.IsZero() {
+			if maxAge >= maxArticleAge {
+				filtered = append(filtered, article)
+			}
+			continue
+		}
+		if time.Since(article.PublishedAt) <= maxAge {
+			filtered = append(filtered, article)
+		}
+	}
+	return filtered
+}
+
+// filterExcluded убирает статьи, содержащие исключенные пользователем слова
+func (na *NewsAggregator) filterExcluded(articles []Article, excluded []string) []Article {
+	if len(excluded) == 0 {
+		return articles
+	}
+
+	var filtered []Article
+	for _, article := range articles {
+		text := strings.ToLower(article.Title + " " + article.Summary)
+		keep := true
+		for _, term := range excluded {
+			if strings.Contains(text, term) {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			filtered = append(filtered, article)
+		}
+	}
+	return filtered
+}
+
+// FetchAllArticles собирает статьи со всех источников, используя кэш для свежих данных
+func (na *NewsAggregator) FetchAllArticles(ctx context.Context) ([]Article, error) {
+	articles, _ := na.fetchAll(ctx)
+	return articles, ctx.Err()
+}
+
+// fetchAll собирает статьи со всех источников и возвращает число источников с ошибками.
+// После отмены ctx оставшиеся источники не опрашиваются.
+func (na *NewsAggregator) fetchAll(ctx context.Context) ([]Article, int) {
 	var allArticles []Article
+	failedSources := 0
 
 	for _, source := range na.sources {
+		if ctx.Err() != nil {
+			log.Printf("[NEWS] ⚠️ Сбор статей прерван: %v", ctx.Err())
+			break
+		}
+
+		if cached, ok := na.cachedArticles(source.GetName()); ok {
+			log.Printf("[NEWS] Используем кэш для %s: %d статей", source.GetName(), len(cached))
+			allArticles = append(allArticles, cached...)
+			continue
+		}
+
+		if na.isQuarantined(source.GetName()) {
+			stale := na.staleArticles(source.GetName())
+			if len(stale) == 0 {
+				failedSources++
+			}
+			log.Printf("[NEWS] Источник %s в карантине, используем кэш: %d статей", source.GetName(), len(stale))
+			allArticles = append(allArticles, stale...)
+			continue
+		}
+
 		log.Printf("[NEWS] Получение статей из %s", source.GetName())
-		articles, err := source.FetchArticles()
+		articles, _, err := na.refreshSource(ctx, source)
 		if err != nil {
+			failedSources++
 			log.Printf("[NEWS] ❌ Ошибка получения статей из %s: %v", source.GetName(), err)
+			// Если есть устаревший кэш, используем его вместо пустого результата
+			if stale := na.staleArticles(source.GetName()); len(stale) > 0 {
+				log.Printf("[NEWS] Используем устаревший кэш для %s: %d статей", source.GetName(), len(stale))
+				allArticles = append(allArticles, stale...)
+			}
 			continue
 		}
 		log.Printf("[NEWS] Получено %d статей из %s", len(articles), source.GetName())
@@ -175,29 +454,241 @@ func (na *NewsAggregator) FetchAllArticles() ([]Article, error) {
 	}
 
 	log.Printf("[NEWS] Итого собрано %d статей", len(allArticles))
-	return allArticles, nil
+	return allArticles, failedSources
 }
 
-// calculateRelevance вычисляет релевантность статьи (0-100)
-func (na *NewsAggregator) calculateRelevance(article Article, keywords []string) float64 {
-	score := 0.0
-	text := strings.ToLower(article.Title + " " + article.Summary)
+// cachedArticles возвращает статьи источника из кэша, если они еще свежие
+func (na *NewsAggregator) cachedArticles(sourceName string) ([]Article, bool) {
+	na.mu.RLock()
+	defer na.mu.RUnlock()
 
-	// 1. Совпадение ключевых слов (60%)
-	keywordScore := 0.0
-	for _, keyword := range keywords {
-		if strings.Contains(text, keyword) {
-			keywordScore += 1.0
+	entry, exists := na.cache[sourceName]
+	if !exists || time.Since(entry.fetchedAt) > na.cacheTTL {
+		return nil, false
+	}
+	return entry.articles, true
+}
+
+// staleArticles возвращает статьи источника из кэша независимо от их возраста
+func (na *NewsAggregator) staleArticles(sourceName string) []Article {
+	na.mu.RLock()
+	defer na.mu.RUnlock()
+
+	if entry, exists := na.cache[sourceName]; exists {
+		return entry.articles
+	}
+	return nil
+}
+
+// refreshSource загружает статьи из источника, обновляет кэш и состояние источника.
+// Возвращает загруженные статьи и количество статей, которых не было в кэше.
+func (na *NewsAggregator) refreshSource(ctx context.Context, source NewsSource) ([]Article, int, error) {
+	articles, newCount, err := na.fetchSource(ctx, source)
+	if err != nil {
+		// Отмена запроса вызывающей стороной не говорит о проблемах источника
+		if ctx.Err() == nil {
+			na.recordFailure(source.GetName(), err)
 		}
+		return nil, 0, err
 	}
-	if len(keywords) > 0 {
-		keywordScore = (keywordScore / float64(len(keywords))) * 60.0
+	na.recordSuccess(source.GetName())
+
+	// Векторы строятся сразу после загрузки, чтобы поиск не ждал эмбеддингов
+	if index := na.semanticIndex(); index != nil && newCount > 0 {
+		if err := index.embedArticles(ctx, articles); err != nil {
+			log.Printf("[NEWS] ⚠️ Не удалось построить векторы статей %s: %v", source.GetName(), err)
+		}
 	}
-	score += keywordScore
+	return articles, newCount, nil
+}
+
+// fetchSource загружает статьи из источника с учетом условных запросов и обновляет кэш
+func (na *NewsAggregator) fetchSource(ctx context.Context, source NewsSource) ([]Article, int, error) {
+	var articles []Article
+	var validators CacheValidators
+	var err error
+
+	if conditional, ok := source.(ConditionalSource); ok {
+		na.mu.RLock()
+		var previous CacheValidators
+		if entry, exists := na.cache[source.GetName()]; exists && len(entry.articles) > 0 {
+			previous = entry.validators
+		}
+		na.mu.RUnlock()
+
+		articles, validators, err = conditional.FetchArticlesConditional(ctx, previous)
+		if errors.Is(err, ErrNotModified) {
+			na.mu.Lock()
+			defer na.mu.Unlock()
+			entry, exists := na.cache[source.GetName()]
+			if !exists {
+				return nil, 0, err
+			}
+			entry.fetchedAt = time.Now()
+			return entry.articles, 0, nil
+		}
+	} else {
+		articles, err = source.FetchArticles(ctx)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	na.mu.Lock()
+	defer na.mu.Unlock()
+
+	known := make(map[string]bool)
+	if entry, exists := na.cache[source.GetName()]; exists {
+		for _, article := range entry.articles {
+			known[article.URL] = true
+		}
+	}
+
+	newCount := 0
+	for _, article := range articles {
+		if !known[article.URL] {
+			newCount++
+		}
+	}
+
+	na.cache[source.GetName()] = &sourceCache{
+		articles:   articles,
+		fetchedAt:  time.Now(),
+		validators: validators,
+	}
+
+	return articles, newCount, nil
+}
+
+// StartPrefetch запускает фоновое обновление всех источников с заданным интервалом,
+// чтобы запросы пользователей обслуживались из прогретого кэша
+func (na *NewsAggregator) StartPrefetch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	na.mu.Lock()
+	if na.prefetchCancel != nil {
+		na.mu.Unlock()
+		return
+	}
+	// Кэш должен жить дольше интервала, иначе запрос между циклами пойдет в сеть
+	if na.cacheTTL < 2*interval {
+		na.cacheTTL = 2 * interval
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	na.prefetchCancel = cancel
+	na.prefetchDone = make(chan struct{})
+	done := na.prefetchDone
+	na.mu.Unlock()
+
+	log.Printf("[NEWS] Запуск фонового обновления кэша, интервал: %v", interval)
+
+	go func() {
+		defer close(done)
+
+		for {
+			na.prefetchCycle(ctx, interval)
+
+			select {
+			case <-ctx.Done():
+				log.Println("[NEWS] Фоновое обновление кэша остановлено")
+				return
+			case <-na.after(interval):
+			}
+		}
+	}()
+}
+
+// StopPrefetch останавливает фоновое обновление и дожидается завершения текущего цикла
+func (na *NewsAggregator) StopPrefetch() {
+	na.mu.Lock()
+	cancel := na.prefetchCancel
+	done := na.prefetchDone
+	na.prefetchCancel = nil
+	na.prefetchDone = nil
+	na.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+// prefetchCycle обновляет все источники по очереди с паузой между запросами
+func (na *NewsAggregator) prefetchCycle(ctx context.Context, interval time.Duration) {
+	if len(na.sources) == 0 {
+		return
+	}
+
+	stagger := interval / time.Duration(2*len(na.sources))
+	if stagger > maxPrefetchStagger {
+		stagger = maxPrefetchStagger
+	}
+
+	okCount, failCount, skipped, newArticles := 0, 0, 0, 0
+	for i, source := range na.sources {
+		if na.isQuarantined(source.GetName()) {
+			skipped++
+			continue
+		}
+
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-na.after(stagger):
+			}
+		}
+
+		_, newCount, err := na.refreshSource(ctx, source)
+		if err != nil {
+			failCount++
+			log.Printf("[NEWS] ❌ Ошибка фонового обновления %s: %v", source.GetName(), err)
+			continue
+		}
+		okCount++
+		newArticles += newCount
+	}
+
+	log.Printf("[NEWS] Прогрев кэша завершен: источников ок %d, с ошибками %d, в карантине %d, новых статей %d",
+		okCount, failCount, skipped, newArticles)
+}
+
+// keywordMatch возвращает долю выполненных условий запроса (0-1).
+// clauses — условия запроса, каждое из которых выполнено при совпадении любой альтернативы.
+func keywordMatch(article Article, clauses [][]string) float64 {
+	if len(clauses) == 0 {
+		return 0
+	}
+
+	text := strings.ToLower(article.Title + " " + article.Summary)
+	matched := 0.0
+	for _, alternatives := range clauses {
+		for _, term := range alternatives {
+			if strings.Contains(text, term) {
+				matched += 1.0
+				break
+			}
+		}
+	}
+	return matched / float64(len(clauses))
+}
+
+// calculateRelevance вычисляет релевантность статьи (0-100).
+// match — совпадение с запросом (0-1): доля условий или ее смесь со смысловой близостью.
+// maxAge задает период поиска: пороги свежести масштабируются относительно 7 дней.
+func (na *NewsAggregator) calculateRelevance(article Article, match float64, maxAge time.Duration) float64 {
+	score := 0.0
+
+	// 1. Совпадение с запросом (60%)
+	score += match * 60.0
 
 	// 2. Свежесть (30%)
 	if !article.PublishedAt.IsZero() {
-		hoursSincePublished := time.Since(article.PublishedAt).Hours()
+		scale := float64(maxAge) / float64(maxArticleAge)
+		hoursSincePublished := time.Since(article.PublishedAt).Hours() / scale
 		if hoursSincePublished < 6 {
 			score += 30.0
 		} else if hoursSincePublished < 12 {
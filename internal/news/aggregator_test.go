@@ -0,0 +1,410 @@
+package news
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSource источник с заранее заданными статьями, считающий обращения
+type fakeSource struct {
+	name     string
+	articles []Article
+	err      error
+
+	mu      sync.Mutex
+	fetches int
+	fetched chan struct{}
+}
+
+func newFakeSource(name string, articles ...Article) *fakeSource {
+	return &fakeSource{name: name, articles: articles, fetched: make(chan struct{}, 100)}
+}
+
+func (s *fakeSource) FetchArticles(ctx context.Context) ([]Article, error) {
+	s.mu.Lock()
+	s.fetches++
+	s.mu.Unlock()
+	s.fetched <- struct{}{}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.articles, nil
+}
+
+func (s *fakeSource) GetName() string { return s.name }
+
+func (s *fakeSource) fetchCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fetches
+}
+
+// fakeClock выдает таймеры, которые срабатывают только по команде теста
+type fakeClock struct {
+	mu      sync.Mutex
+	waits   []time.Duration
+	timers  []chan time.Time
+	created chan struct{}
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{created: make(chan struct{}, 100)}
+}
+
+func (c *fakeClock) after(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waits = append(c.waits, d)
+	c.timers = append(c.timers, ch)
+	c.created <- struct{}{}
+	return ch
+}
+
+// fireNext дожидается очередного таймера и срабатывает его, возвращая заданную паузу
+func (c *fakeClock) fireNext(t *testing.T) time.Duration {
+	t.Helper()
+	select {
+	case <-c.created:
+	case <-time.After(time.Second):
+		t.Fatal("таймер не был создан")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	last := len(c.timers) - 1
+	c.timers[last] <- time.Now()
+	return c.waits[last]
+}
+
+func waitFetch(t *testing.T, source *fakeSource) {
+	t.Helper()
+	select {
+	case <-source.fetched:
+	case <-time.After(time.Second):
+		t.Fatalf("источник %s не был опрошен", source.name)
+	}
+}
+
+func newTestAggregator(sources ...NewsSource) *NewsAggregator {
+	na := NewNewsAggregator(Config{CacheTTL: time.Minute})
+	na.sources = append(na.sources, sources...)
+	return na
+}
+
+func TestPrefetchRefreshesSourcesOnEachTick(t *testing.T) {
+	first := newFakeSource("first", Article{Title: "A", URL: "https://a"})
+	second := newFakeSource("second", Article{Title: "B", URL: "https://b"})
+	na := newTestAggregator(first, second)
+	clock := newFakeClock()
+	na.after = clock.after
+
+	na.StartPrefetch(context.Background(), 8*time.Second)
+	defer na.StopPrefetch()
+
+	// Первый цикл начинается сразу, второй источник опрашивается после паузы
+	waitFetch(t, first)
+	if stagger := clock.fireNext(t); stagger != 2*time.Second {
+		t.Fatalf("пауза между источниками = %v, ожидалось 2s", stagger)
+	}
+	waitFetch(t, second)
+
+	// Следующий цикл начинается только после срабатывания интервала
+	if interval := clock.fireNext(t); interval != 8*time.Second {
+		t.Fatalf("интервал обновления = %v, ожидалось 8s", interval)
+	}
+	waitFetch(t, first)
+	clock.fireNext(t)
+	waitFetch(t, second)
+
+	if got := na.CachedArticles(); got != 2 {
+		t.Fatalf("в кэше %d статей, ожидалось 2", got)
+	}
+}
+
+func TestPrefetchStaggerIsCapped(t *testing.T) {
+	first := newFakeSource("first")
+	second := newFakeSource("second")
+	na := newTestAggregator(first, second)
+	clock := newFakeClock()
+	na.after = clock.after
+
+	na.StartPrefetch(context.Background(), time.Hour)
+	defer na.StopPrefetch()
+
+	waitFetch(t, first)
+	if stagger := clock.fireNext(t); stagger != maxPrefetchStagger {
+		t.Fatalf("пауза между источниками = %v, ожидалось %v", stagger, maxPrefetchStagger)
+	}
+	waitFetch(t, second)
+}
+
+func TestPrefetchSkipsQuarantinedSources(t *testing.T) {
+	broken := newFakeSource("broken")
+	broken.err = errors.New("502")
+	na := newTestAggregator(broken)
+	for i := 0; i < quarantineThreshold; i++ {
+		na.recordFailure("broken", broken.err)
+	}
+	clock := newFakeClock()
+	na.after = clock.after
+
+	na.StartPrefetch(context.Background(), time.Minute)
+	defer na.StopPrefetch()
+
+	clock.fireNext(t)
+	if got := broken.fetchCount(); got != 0 {
+		t.Fatalf("источник в карантине опрошен %d раз", got)
+	}
+}
+
+func TestStopPrefetchWaitsForLoop(t *testing.T) {
+	source := newFakeSource("only")
+	na := newTestAggregator(source)
+	clock := newFakeClock()
+	na.after = clock.after
+
+	na.StartPrefetch(context.Background(), time.Minute)
+	waitFetch(t, source)
+
+	stopped := make(chan struct{})
+	go func() {
+		na.StopPrefetch()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("StopPrefetch не дождался остановки цикла")
+	}
+
+	// Повторная остановка и запуск после остановки допустимы
+	na.StopPrefetch()
+	na.StartPrefetch(context.Background(), time.Minute)
+	waitFetch(t, source)
+	na.StopPrefetch()
+}
+
+func TestPrefetchStopsOnContextCancel(t *testing.T) {
+	source := newFakeSource("only")
+	na := newTestAggregator(source)
+	na.after = newFakeClock().after
+
+	ctx, cancel := context.WithCancel(context.Background())
+	na.StartPrefetch(ctx, time.Minute)
+	waitFetch(t, source)
+	cancel()
+
+	na.mu.RLock()
+	done := na.prefetchDone
+	na.mu.RUnlock()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("цикл не остановился после отмены контекста")
+	}
+}
+
+func TestPrefetchExtendsCacheTTL(t *testing.T) {
+	na := newTestAggregator()
+	na.after = newFakeClock().after
+	na.StartPrefetch(context.Background(), time.Hour)
+	defer na.StopPrefetch()
+
+	if na.cacheTTL < 2*time.Hour {
+		t.Fatalf("TTL кэша %v меньше двух интервалов обновления", na.cacheTTL)
+	}
+}
+
+func TestFindRelevantArticlesDiagnostics(t *testing.T) {
+	fresh := time.Now().Add(-time.Hour)
+	brokenSource := func() *fakeSource {
+		source := newFakeSource("broken")
+		source.err = errors.New("502")
+		return source
+	}
+
+	tests := []struct {
+		name    string
+		sources []NewsSource
+		query   string
+		found   int
+		check   func(t *testing.T, diag SearchDiagnostics)
+	}{
+		{
+			name:    "источники недоступны",
+			sources: []NewsSource{brokenSource()},
+			query:   "биткоин",
+			check: func(t *testing.T, diag SearchDiagnostics) {
+				if diag.Fetched != 0 || diag.SourcesFailed != 1 || diag.SourcesTotal != 1 {
+					t.Errorf("diag = %+v", diag)
+				}
+			},
+		},
+		{
+			name: "все статьи о запрещенных темах",
+			sources: []NewsSource{newFakeSource("s",
+				Article{Title: "Обстрел города", URL: "u1", PublishedAt: fresh},
+				Article{Title: "Танковое сражение", URL: "u2", PublishedAt: fresh},
+			)},
+			query: "город",
+			check: func(t *testing.T, diag SearchDiagnostics) {
+				if diag.Fetched != 2 || diag.ContentFiltered != 2 || diag.Scored != 0 {
+					t.Errorf("diag = %+v", diag)
+				}
+			},
+		},
+		{
+			name: "все статьи исключены пользователем",
+			sources: []NewsSource{newFakeSource("s",
+				Article{Title: "Новый телефон Samsung", URL: "u1", PublishedAt: fresh},
+			)},
+			query: "телефон -samsung",
+			check: func(t *testing.T, diag SearchDiagnostics) {
+				if diag.Excluded != 1 || diag.Scored != 0 {
+					t.Errorf("diag = %+v", diag)
+				}
+			},
+		},
+		{
+			name: "статьи ниже порога релевантности",
+			sources: []NewsSource{newFakeSource("s",
+				Article{Title: "Дождь", URL: "u1"},
+			)},
+			query: "биткоин",
+			check: func(t *testing.T, diag SearchDiagnostics) {
+				if diag.Scored != 0 || diag.BestRejectedTitle != "" {
+					t.Errorf("diag = %+v", diag)
+				}
+			},
+		},
+		{
+			name: "найдена статья и часть источников упала",
+			sources: []NewsSource{
+				brokenSource(),
+				newFakeSource("s",
+					Article{Title: "Биткоин обновил максимум", URL: "u1", PublishedAt: fresh},
+					Article{Title: "Погода на выходные", URL: "u2", PublishedAt: fresh},
+				),
+			},
+			query: "биткоин",
+			found: 2,
+			check: func(t *testing.T, diag SearchDiagnostics) {
+				if diag.SourcesFailed != 1 || diag.Fetched != 2 || diag.Scored != 2 || diag.TopScore <= 60 {
+					t.Errorf("diag = %+v", diag)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			na := newTestAggregator(tt.sources...)
+			articles, diag, err := na.FindRelevantArticles(context.Background(), tt.query, 5, SearchOptions{})
+			if err != nil {
+				t.Fatalf("FindRelevantArticles: %v", err)
+			}
+			if len(articles) != tt.found {
+				t.Errorf("найдено %d статей, ожидалось %d", len(articles), tt.found)
+			}
+			tt.check(t, diag)
+		})
+	}
+}
+
+func TestFindRelevantArticlesReportsBestRejected(t *testing.T) {
+	na := newTestAggregator(newFakeSource("s",
+		Article{Title: "Биткоин и эфириум дорожают", URL: "u1", PublishedAt: time.Now()},
+	))
+
+	// Статья совпадает с одним условием из двух, но не дотягивает до MinScore
+	articles, diag, err := na.FindRelevantArticles(context.Background(), "биткоин регулирование", 5, SearchOptions{MinScore: 90})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 0 {
+		t.Fatalf("найдено %d статей", len(articles))
+	}
+	if diag.BestRejectedTitle != "Биткоин и эфириум дорожают" || diag.BestRejectedScore <= 0 {
+		t.Errorf("diag = %+v", diag)
+	}
+}
+
+// weightedFakeSource источник с весом, заданным администратором в настройках
+type weightedFakeSource struct {
+	*fakeSource
+	weight float64
+}
+
+func (s weightedFakeSource) GetWeight() float64 { return s.weight }
+
+func TestSourceWeightsDemoteBadlyRatedSource(t *testing.T) {
+	published := time.Now().Add(-time.Hour)
+	bad := newFakeSource("Плохой", Article{Title: "Биткоин обновил исторический максимум", URL: "bad", Source: "Плохой", PublishedAt: published})
+	good := newFakeSource("Хороший", Article{Title: "Биткоин обновил исторический максимум", URL: "good", Source: "Хороший", PublishedAt: published})
+	na := newTestAggregator(bad, good)
+
+	// Статьи совпадают с запросом одинаково, различаются только веса источников
+	na.SetSourceWeights(map[string]float64{"Плохой": 0.55, "Хороший": 1.3})
+
+	articles, _, err := na.FindRelevantArticles(context.Background(), "биткоин", 2, SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 2 || articles[0].Source != "Хороший" {
+		t.Fatalf("порядок статей: %+v", articles)
+	}
+}
+
+func TestConfiguredWeightOverridesRatings(t *testing.T) {
+	source := weightedFakeSource{fakeSource: newFakeSource("Ручной"), weight: 1.2}
+	na := newTestAggregator(source)
+	na.SetSourceWeight("Ручной", 0.5)
+
+	if got := na.SourceWeight("Ручной"); got != 1.2 {
+		t.Errorf("вес = %v, ожидался вес из настроек 1.2", got)
+	}
+	if got := na.SourceWeight("Неизвестный"); got != 1.0 {
+		t.Errorf("вес неизвестного источника = %v, ожидалось 1.0", got)
+	}
+}
+
+// blockingSource источник, который отвечает только после отмены контекста
+type blockingSource struct {
+	name    string
+	started chan struct{}
+}
+
+func (s *blockingSource) FetchArticles(ctx context.Context) ([]Article, error) {
+	close(s.started)
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (s *blockingSource) GetName() string { return s.name }
+
+func TestFindRelevantArticlesStopsOnDeadline(t *testing.T) {
+	slow := &blockingSource{name: "slow", started: make(chan struct{})}
+	next := newFakeSource("next", Article{Title: "Биткоин", URL: "u"})
+	na := newTestAggregator(slow, next)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	articles, _, err := na.FindRelevantArticles(ctx, "биткоин", 5, SearchOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ошибка = %v, ожидалось превышение срока", err)
+	}
+	if articles != nil {
+		t.Errorf("после отмены возвращены статьи: %+v", articles)
+	}
+	if got := next.fetchCount(); got != 0 {
+		t.Errorf("после отмены опрошен следующий источник (%d раз)", got)
+	}
+	// Отмена вызывающей стороной не должна портить репутацию источника
+	if na.isQuarantined("slow") || na.SourceStatuses()[1].ConsecutiveFailures != 0 {
+		t.Errorf("отмена учтена как ошибка источника: %+v", na.SourceStatuses())
+	}
+}
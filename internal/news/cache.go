@@ -0,0 +1,150 @@
+package news
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheFileName имя файла кэша статей в каталоге данных
+const cacheFileName = "news_cache.json"
+
+// cacheFile формат файла кэша статей на диске
+type cacheFile struct {
+	SavedAt time.Time                 `json:"saved_at"`
+	Sources map[string]cacheFileEntry `json:"sources"`
+}
+
+// cacheFileEntry кэш одного источника на диске
+type cacheFileEntry struct {
+	Articles   []Article       `json:"articles"`
+	FetchedAt  time.Time       `json:"fetched_at"`
+	Validators CacheValidators `json:"validators"`
+}
+
+// CacheFilePath возвращает путь к файлу кэша статей в каталоге данных
+func CacheFilePath(dataDir string) string {
+	return filepath.Join(dataDir, cacheFileName)
+}
+
+// SaveCache сохраняет кэш статей в файл
+func (na *NewsAggregator) SaveCache(path string) error {
+	na.mu.RLock()
+	file := cacheFile{
+		SavedAt: time.Now(),
+		Sources: make(map[string]cacheFileEntry, len(na.cache)),
+	}
+	for name, entry := range na.cache {
+		file.Sources[name] = cacheFileEntry{
+			Articles:   entry.articles,
+			FetchedAt:  entry.fetchedAt,
+			Validators: entry.validators,
+		}
+	}
+	na.mu.RUnlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга кэша: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("ошибка создания каталога кэша: %w", err)
+	}
+
+	tempFile := path + ".tmp"
+	if err := os.WriteFile(tempFile, data, 0644); err != nil {
+		return fmt.Errorf("ошибка записи временного файла кэша: %w", err)
+	}
+
+	if err := os.Rename(tempFile, path); err != nil {
+		return fmt.Errorf("ошибка переименования файла кэша: %w", err)
+	}
+
+	log.Printf("[NEWS] Кэш статей сохранен: %d источников", len(file.Sources))
+	return nil
+}
+
+// LoadCache загружает кэш статей из файла, отбрасывая устаревшие записи.
+// Отсутствующий или поврежденный файл не считается ошибкой.
+func (na *NewsAggregator) LoadCache(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[NEWS] ⚠️ Не удалось прочитать кэш статей: %v", err)
+		}
+		return
+	}
+
+	var file cacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("[NEWS] ⚠️ Файл кэша статей поврежден, игнорирую: %v", err)
+		return
+	}
+
+	na.mu.Lock()
+	defer na.mu.Unlock()
+
+	loadedSources, loadedArticles := 0, 0
+	for name, entry := range file.Sources {
+		if time.Since(entry.FetchedAt) > maxArticleAge {
+			continue
+		}
+
+		var articles []Article
+		for _, article := range entry.Articles {
+			if !article.PublishedAt.IsZero() && time.Since(article.PublishedAt) > maxArticleAge {
+				continue
+			}
+			articles = append(articles, article)
+		}
+		if len(articles) == 0 {
+			continue
+		}
+
+		na.cache[name] = &sourceCache{
+			articles:   articles,
+			fetchedAt:  entry.FetchedAt,
+			validators: entry.Validators,
+		}
+		loadedSources++
+		loadedArticles += len(articles)
+	}
+
+	log.Printf("[NEWS] Кэш статей загружен: %d источников, %d статей", loadedSources, loadedArticles)
+}
+
+// StartCacheAutosave периодически сохраняет кэш статей до отмены контекста
+func (na *NewsAggregator) StartCacheAutosave(ctx context.Context, path string, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := na.SaveCache(path); err != nil {
+					log.Printf("[NEWS] ❌ Ошибка сохранения кэша статей: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// CachedArticles возвращает число статей в кэше всех источников
+func (na *NewsAggregator) CachedArticles() int {
+	na.mu.RLock()
+	defer na.mu.RUnlock()
+
+	count := 0
+	for _, entry := range na.cache {
+		count += len(entry.articles)
+	}
+	return count
+}
@@ -0,0 +1,120 @@
+package news
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCacheRoundTripPreservesArticles(t *testing.T) {
+	path := CacheFilePath(t.TempDir())
+	published := time.Now().Add(-time.Hour).Truncate(time.Second)
+	fetched := time.Now().Add(-time.Minute).Truncate(time.Second)
+
+	saved := newTestAggregator()
+	saved.cache["lenta"] = &sourceCache{
+		articles: []Article{{
+			Title:       "Запуск спутника",
+			URL:         "https://lenta.ru/1",
+			Summary:     "Кратко",
+			PublishedAt: published,
+			Source:      "lenta",
+			ImageURL:    "https://lenta.ru/1.jpg",
+		}},
+		fetchedAt:  fetched,
+		validators: CacheValidators{ETag: `"abc"`, LastModified: "Mon, 02 Jan 2006 15:04:05 GMT"},
+	}
+	if err := saved.SaveCache(path); err != nil {
+		t.Fatalf("SaveCache: %v", err)
+	}
+
+	loaded := newTestAggregator()
+	loaded.LoadCache(path)
+
+	entry, ok := loaded.cache["lenta"]
+	if !ok {
+		t.Fatal("источник не загрузился из кэша")
+	}
+	if !entry.fetchedAt.Equal(fetched) {
+		t.Errorf("fetchedAt = %v, ожидалось %v", entry.fetchedAt, fetched)
+	}
+	if entry.validators != saved.cache["lenta"].validators {
+		t.Errorf("validators = %+v, ожидалось %+v", entry.validators, saved.cache["lenta"].validators)
+	}
+	if len(entry.articles) != 1 {
+		t.Fatalf("загружено %d статей, ожидалась 1", len(entry.articles))
+	}
+	got := entry.articles[0]
+	want := saved.cache["lenta"].articles[0]
+	if got.Title != want.Title || got.URL != want.URL || got.ImageURL != want.ImageURL || !got.PublishedAt.Equal(want.PublishedAt) {
+		t.Errorf("статья = %+v, ожидалось %+v", got, want)
+	}
+}
+
+func TestLoadCacheDropsStaleEntries(t *testing.T) {
+	path := CacheFilePath(t.TempDir())
+	old := time.Now().Add(-maxArticleAge - time.Hour)
+
+	saved := newTestAggregator()
+	saved.cache["stale-source"] = &sourceCache{
+		articles:  []Article{{Title: "Старое", URL: "https://a/1", PublishedAt: time.Now()}},
+		fetchedAt: old,
+	}
+	saved.cache["mixed"] = &sourceCache{
+		articles: []Article{
+			{Title: "Старая статья", URL: "https://b/1", PublishedAt: old},
+			{Title: "Свежая статья", URL: "https://b/2", PublishedAt: time.Now()},
+			{Title: "Без даты", URL: "https://b/3"},
+		},
+		fetchedAt: time.Now(),
+	}
+	saved.cache["only-old"] = &sourceCache{
+		articles:  []Article{{Title: "Старая", URL: "https://c/1", PublishedAt: old}},
+		fetchedAt: time.Now(),
+	}
+	if err := saved.SaveCache(path); err != nil {
+		t.Fatalf("SaveCache: %v", err)
+	}
+
+	loaded := newTestAggregator()
+	loaded.LoadCache(path)
+
+	if _, ok := loaded.cache["stale-source"]; ok {
+		t.Error("источник с устаревшей загрузкой не должен загружаться")
+	}
+	if _, ok := loaded.cache["only-old"]; ok {
+		t.Error("источник только со старыми статьями не должен загружаться")
+	}
+	mixed, ok := loaded.cache["mixed"]
+	if !ok {
+		t.Fatal("источник со свежими статьями не загрузился")
+	}
+	if len(mixed.articles) != 2 {
+		t.Fatalf("загружено %d статей, ожидалось 2 (свежая и без даты)", len(mixed.articles))
+	}
+	for _, article := range mixed.articles {
+		if article.Title == "Старая статья" {
+			t.Error("старая статья не была отброшена")
+		}
+	}
+}
+
+func TestLoadCacheIgnoresMissingAndCorruptFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	na := newTestAggregator()
+	na.LoadCache(filepath.Join(dir, "missing.json"))
+	if na.CachedArticles() != 0 {
+		t.Fatal("отсутствующий файл не должен ничего загружать")
+	}
+
+	corrupt := filepath.Join(dir, "corrupt.json")
+	if err := os.WriteFile(corrupt, []byte(`{"sources": {"x": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	na.LoadCache(corrupt)
+	if na.CachedArticles() != 0 {
+		t.Fatal("поврежденный файл не должен ничего загружать")
+	}
+}
@@ -0,0 +1,213 @@
+package news
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSemanticWeight доля смысловой близости в оценке совпадения с запросом
+	defaultSemanticWeight = 0.6
+	// semanticFloor косинусная близость, ниже которой тексты считаются несвязанными:
+	// у эмбеддингов даже случайные тексты редко дают близость около нуля
+	semanticFloor = 0.3
+	// maxArticleEmbeddings сколько векторов статей хранится; при превышении удаляются
+	// векторы, которые дольше всего не использовались
+	maxArticleEmbeddings = 5000
+	// maxQueryEmbeddings сколько векторов запросов хранится
+	maxQueryEmbeddings = 500
+)
+
+// Embedder строит векторы текстов для семантического поиска
+type Embedder interface {
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error)
+	EmbedQuery(ctx context.Context, text string) ([]float32, error)
+}
+
+// embeddingIndex векторы статей и запросов по хешу текста
+type embeddingIndex struct {
+	embedder Embedder
+	weight   float64
+
+	mu       sync.Mutex
+	articles map[string]*articleEmbedding
+	queries  map[string][]float32
+}
+
+// articleEmbedding вектор статьи и время последнего использования
+type articleEmbedding struct {
+	vector []float32
+	used   time.Time
+}
+
+// SetEmbedder включает семантический поиск. Близость запроса и статьи смешивается
+// с совпадением ключевых слов с весом Config.SemanticWeight.
+func (na *NewsAggregator) SetEmbedder(embedder Embedder) {
+	na.mu.Lock()
+	defer na.mu.Unlock()
+	weight := na.semanticWeight
+	na.embeddings = &embeddingIndex{
+		embedder: embedder,
+		weight:   weight,
+		articles: make(map[string]*articleEmbedding),
+		queries:  make(map[string][]float32),
+	}
+	log.Printf("[NEWS] Семантический поиск включен, вес близости %.2f", weight)
+}
+
+// semanticIndex возвращает индекс векторов или nil, если семантический поиск выключен
+func (na *NewsAggregator) semanticIndex() *embeddingIndex {
+	na.mu.RLock()
+	defer na.mu.RUnlock()
+	return na.embeddings
+}
+
+// articleEmbeddingText текст статьи, по которому строится вектор
+func articleEmbeddingText(article Article) string {
+	return article.Title + "\n" + article.Summary
+}
+
+// textHash ключ вектора: одинаковый текст не эмбеддится повторно
+func textHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:16])
+}
+
+// embedArticles строит векторы статей, которых еще нет в индексе, одним пакетом
+func (idx *embeddingIndex) embedArticles(ctx context.Context, articles []Article) error {
+	idx.mu.Lock()
+	var hashes, texts []string
+	seen := make(map[string]bool)
+	for _, article := range articles {
+		text := articleEmbeddingText(article)
+		hash := textHash(text)
+		if _, ok := idx.articles[hash]; ok || seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		hashes = append(hashes, hash)
+		texts = append(texts, text)
+	}
+	idx.mu.Unlock()
+
+	if len(texts) == 0 {
+		return nil
+	}
+
+	vectors, err := idx.embedder.EmbedDocuments(ctx, texts)
+	if err != nil {
+		return err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	now := time.Now()
+	for i, hash := range hashes {
+		idx.articles[hash] = &articleEmbedding{vector: vectors[i], used: now}
+	}
+	if len(idx.articles) > maxArticleEmbeddings {
+		idx.prune(maxArticleEmbeddings * 3 / 4)
+	}
+	return nil
+}
+
+// prune оставляет keep векторов, которые использовались последними; вызывается под mu
+func (idx *embeddingIndex) prune(keep int) {
+	hashes := make([]string, 0, len(idx.articles))
+	for hash := range idx.articles {
+		hashes = append(hashes, hash)
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return idx.articles[hashes[i]].used.After(idx.articles[hashes[j]].used)
+	})
+
+	before := len(idx.articles)
+	for _, hash := range hashes[keep:] {
+		delete(idx.articles, hash)
+	}
+	log.Printf("[NEWS] Индекс векторов очищен: %d → %d", before, len(idx.articles))
+}
+
+// queryEmbedding возвращает вектор запроса, строя его при первом обращении
+func (idx *embeddingIndex) queryEmbedding(ctx context.Context, query string) ([]float32, error) {
+	hash := textHash(query)
+	idx.mu.Lock()
+	vector, ok := idx.queries[hash]
+	idx.mu.Unlock()
+	if ok {
+		return vector, nil
+	}
+
+	vector, err := idx.embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if len(idx.queries) >= maxQueryEmbeddings {
+		idx.queries = make(map[string][]float32)
+	}
+	idx.queries[hash] = vector
+	return vector, nil
+}
+
+// similarities возвращает близость запроса к статьям (0–1) по индексу в articles.
+// При ошибке эмбеддингов возвращает nil: поиск работает только по ключевым словам.
+func (idx *embeddingIndex) similarities(ctx context.Context, query string, articles []Article) []float64 {
+	if err := idx.embedArticles(ctx, articles); err != nil {
+		log.Printf("[NEWS] ⚠️ Не удалось построить векторы статей, ищем по ключевым словам: %v", err)
+		return nil
+	}
+	queryVector, err := idx.queryEmbedding(ctx, query)
+	if err != nil {
+		log.Printf("[NEWS] ⚠️ Не удалось построить вектор запроса, ищем по ключевым словам: %v", err)
+		return nil
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	now := time.Now()
+	result := make([]float64, len(articles))
+	for i, article := range articles {
+		if embedding, ok := idx.articles[textHash(articleEmbeddingText(article))]; ok {
+			embedding.used = now
+			result[i] = semanticSimilarity(queryVector, embedding.vector)
+		}
+	}
+	return result
+}
+
+// cosineSimilarity косинусная близость векторов; 0 для векторов разной длины или нулевых
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// semanticSimilarity переводит косинусную близость в шкалу 0–1 с учетом semanticFloor
+func semanticSimilarity(a, b []float32) float64 {
+	similarity := (cosineSimilarity(a, b) - semanticFloor) / (1 - semanticFloor)
+	return math.Max(0, math.Min(1, similarity))
+}
+
+// blendMatch смешивает долю выполненных условий запроса и смысловую близость (обе 0–1)
+func blendMatch(keywords, similarity, weight float64) float64 {
+	return weight*similarity + (1-weight)*keywords
+}
@@ -0,0 +1,208 @@
+package news
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeEmbedder возвращает заранее заданные векторы и считает запросы
+type fakeEmbedder struct {
+	documents map[string][]float32
+	query     []float32
+	err       error
+
+	mu      sync.Mutex
+	batches [][]string
+	queries []string
+}
+
+func (e *fakeEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.batches = append(e.batches, texts)
+	if e.err != nil {
+		return nil, e.err
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.documents[text]
+	}
+	return vectors, nil
+}
+
+func (e *fakeEmbedder) EmbedQuery(ctx context.Context, text string) ([]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.queries = append(e.queries, text)
+	return e.query, e.err
+}
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+func TestCosineSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []float32
+		want float64
+	}{
+		{"одинаковые", []float32{1, 2, 3}, []float32{1, 2, 3}, 1},
+		{"пропорциональные", []float32{1, 2, 3}, []float32{2, 4, 6}, 1},
+		{"ортогональные", []float32{1, 0}, []float32{0, 1}, 0},
+		{"противоположные", []float32{1, 0}, []float32{-1, 0}, -1},
+		{"под 60 градусов", []float32{1, 0}, []float32{0.5, float32(math.Sqrt(3) / 2)}, 0.5},
+		{"разная длина", []float32{1, 0}, []float32{1, 0, 0}, 0},
+		{"нулевой вектор", []float32{0, 0}, []float32{1, 0}, 0},
+		{"пустые", nil, nil, 0},
+	}
+	for _, tt := range tests {
+		if got := cosineSimilarity(tt.a, tt.b); !almostEqual(got, tt.want) {
+			t.Errorf("%s: cosineSimilarity = %v, ожидалось %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSemanticSimilarityScale(t *testing.T) {
+	// Близость 0,3 и ниже — несвязанные тексты, выше растягивается на 0–1
+	tests := []struct {
+		cosine float64
+		want   float64
+	}{
+		{1, 1},
+		{0.65, 0.5},
+		{semanticFloor, 0},
+		{0.1, 0},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		angle := math.Acos(tt.cosine)
+		b := []float32{float32(math.Cos(angle)), float32(math.Sin(angle))}
+		if got := semanticSimilarity([]float32{1, 0}, b); !almostEqual(got, tt.want) {
+			t.Errorf("близость при косинусе %v = %v, ожидалось %v", tt.cosine, got, tt.want)
+		}
+	}
+}
+
+func TestBlendMatch(t *testing.T) {
+	tests := []struct {
+		keywords, similarity, weight float64
+		want                         float64
+	}{
+		{0, 1, 0.6, 0.6},
+		{1, 0, 0.6, 0.4},
+		{0.5, 1, 0.6, 0.8},
+		{0.5, 0.5, 0.6, 0.5},
+		{0.7, 0.2, 0, 0.7},
+		{0.7, 0.2, 1, 0.2},
+	}
+	for _, tt := range tests {
+		if got := blendMatch(tt.keywords, tt.similarity, tt.weight); !almostEqual(got, tt.want) {
+			t.Errorf("blendMatch(%v, %v, %v) = %v, ожидалось %v", tt.keywords, tt.similarity, tt.weight, got, tt.want)
+		}
+	}
+}
+
+// housingArticles статья о росте цен на недвижимость без слов запроса и статья не по теме
+var housingArticles = []Article{
+	{Title: "Матч Спартака перенесли", Summary: "Игра пройдет в воскресенье", URL: "u1"},
+	{Title: "Рост цен на недвижимость", Summary: "Квадратный метр подорожал на 8%", URL: "u2"},
+}
+
+// newHousingEmbedder векторы, в которых запрос о подорожании жилья близок ко второй статье
+func newHousingEmbedder() *fakeEmbedder {
+	return &fakeEmbedder{
+		documents: map[string][]float32{
+			articleEmbeddingText(housingArticles[0]): {0, 0, 1},
+			articleEmbeddingText(housingArticles[1]): {0.9, 0.1, 0},
+		},
+		query: []float32{1, 0, 0},
+	}
+}
+
+func TestEmbeddingIndexSimilarities(t *testing.T) {
+	embedder := newHousingEmbedder()
+	na := newTestAggregator()
+	na.SetEmbedder(embedder)
+	index := na.semanticIndex()
+
+	got := index.similarities(context.Background(), "подорожание жилья", housingArticles)
+	cosine := 0.9 / math.Sqrt(0.82)
+	want := []float64{0, (cosine - semanticFloor) / (1 - semanticFloor)}
+	if len(got) != 2 || !almostEqual(got[0], want[0]) || !almostEqual(got[1], want[1]) {
+		t.Fatalf("similarities = %v, ожидалось %v", got, want)
+	}
+
+	// Повторный поиск не строит векторы заново: статьи и запрос берутся по хешу текста
+	index.similarities(context.Background(), "подорожание жилья", housingArticles)
+	if len(embedder.batches) != 1 || len(embedder.batches[0]) != 2 || len(embedder.queries) != 1 {
+		t.Errorf("пакетов %d, запросов %d", len(embedder.batches), len(embedder.queries))
+	}
+
+	// Новая статья эмбеддится отдельно, одинаковые тексты — один раз
+	extra := Article{Title: "Ипотека дорожает", Summary: "Банки подняли ставки"}
+	index.similarities(context.Background(), "подорожание жилья", append([]Article{extra, extra}, housingArticles...))
+	if len(embedder.batches) != 2 || len(embedder.batches[1]) != 1 {
+		t.Errorf("пакеты = %q", embedder.batches)
+	}
+}
+
+func TestEmbeddingIndexFallsBackOnError(t *testing.T) {
+	embedder := newHousingEmbedder()
+	embedder.err = errors.New("503")
+	na := newTestAggregator()
+	na.SetEmbedder(embedder)
+
+	if got := na.semanticIndex().similarities(context.Background(), "жилье", housingArticles); got != nil {
+		t.Errorf("при ошибке эмбеддингов similarities = %v", got)
+	}
+}
+
+func TestEmbeddingIndexPrune(t *testing.T) {
+	index := &embeddingIndex{articles: make(map[string]*articleEmbedding)}
+	now := time.Now()
+	for i, hash := range []string{"старый", "средний", "новый"} {
+		index.articles[hash] = &articleEmbedding{used: now.Add(time.Duration(i) * time.Minute)}
+	}
+
+	index.prune(2)
+	if _, ok := index.articles["старый"]; ok || len(index.articles) != 2 {
+		t.Errorf("после очистки: %v", index.articles)
+	}
+}
+
+func TestSemanticSearchFindsParaphrasedArticle(t *testing.T) {
+	fresh := time.Now().Add(-time.Hour)
+	articles := make([]Article, len(housingArticles))
+	for i, article := range housingArticles {
+		article.PublishedAt = fresh
+		articles[i] = article
+	}
+
+	plain := newTestAggregator(newFakeSource("s", articles...))
+	_, plainDiag, err := plain.FindRelevantArticles(context.Background(), "подорожание жилья", 5, SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	semantic := newTestAggregator(newFakeSource("s", articles...))
+	semantic.semanticWeight = defaultSemanticWeight
+	semantic.SetEmbedder(newHousingEmbedder())
+	found, diag, err := semantic.FindRelevantArticles(context.Background(), "подорожание жилья", 5, SearchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) == 0 || found[0].Title != "Рост цен на недвижимость" {
+		t.Fatalf("первой найдена %v", found)
+	}
+	// Ни одного слова запроса в статье нет: вся прибавка — смысловая близость с весом 0,6
+	similarity := (0.9/math.Sqrt(0.82) - semanticFloor) / (1 - semanticFloor)
+	if gain := diag.TopScore - plainDiag.TopScore; gain < defaultSemanticWeight*similarity*60-1 {
+		t.Errorf("прибавка к релевантности %.1f", gain)
+	}
+}
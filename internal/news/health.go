@@ -0,0 +1,181 @@
+package news
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// quarantineThreshold число подряд идущих временных ошибок до карантина
+	quarantineThreshold = 3
+	// transientQuarantine время карантина после серии временных ошибок
+	transientQuarantine = 10 * time.Minute
+	// permanentQuarantine время карантина после постоянной ошибки (404, битый XML)
+	permanentQuarantine = time.Hour
+)
+
+// sourceHealth хранит историю ошибок источника
+type sourceHealth struct {
+	consecutiveFailures int
+	lastError           string
+	lastErrorAt         time.Time
+	lastSuccessAt       time.Time
+	quarantinedUntil    time.Time
+	permanent           bool
+}
+
+// SourceStatus снимок состояния источника для отображения администратору
+type SourceStatus struct {
+	Name                string
+	ConsecutiveFailures int
+	LastError           string
+	LastErrorAt         time.Time
+	LastSuccessAt       time.Time
+	QuarantinedUntil    time.Time
+	Permanent           bool
+}
+
+// Quarantined возвращает true, если источник сейчас в карантине
+func (s SourceStatus) Quarantined() bool {
+	return time.Now().Before(s.QuarantinedUntil)
+}
+
+// isQuarantined проверяет, находится ли источник в карантине
+func (na *NewsAggregator) isQuarantined(sourceName string) bool {
+	na.mu.RLock()
+	defer na.mu.RUnlock()
+
+	health, exists := na.health[sourceName]
+	return exists && time.Now().Before(health.quarantinedUntil)
+}
+
+// recordSuccess сбрасывает счетчик ошибок источника
+func (na *NewsAggregator) recordSuccess(sourceName string) {
+	na.mu.Lock()
+	defer na.mu.Unlock()
+
+	health := na.healthFor(sourceName)
+	health.consecutiveFailures = 0
+	health.permanent = false
+	health.quarantinedUntil = time.Time{}
+	health.lastSuccessAt = time.Now()
+}
+
+// recordFailure учитывает ошибку источника и при необходимости отправляет его в карантин
+func (na *NewsAggregator) recordFailure(sourceName string, err error) {
+	na.mu.Lock()
+	defer na.mu.Unlock()
+
+	health := na.healthFor(sourceName)
+	health.consecutiveFailures++
+	health.lastError = err.Error()
+	health.lastErrorAt = time.Now()
+
+	var fetchErr *FetchError
+	health.permanent = errors.As(err, &fetchErr) && fetchErr.Permanent
+
+	switch {
+	case health.permanent:
+		health.quarantinedUntil = time.Now().Add(permanentQuarantine)
+		log.Printf("[NEWS] ⛔ Источник %s в карантине на %v: постоянная ошибка", sourceName, permanentQuarantine)
+	case health.consecutiveFailures >= quarantineThreshold:
+		health.quarantinedUntil = time.Now().Add(transientQuarantine)
+		log.Printf("[NEWS] ⛔ Источник %s в карантине на %v: %d ошибок подряд",
+			sourceName, transientQuarantine, health.consecutiveFailures)
+	}
+}
+
+// healthFor возвращает запись состояния источника, создавая ее при необходимости.
+// Вызывается под na.mu.
+func (na *NewsAggregator) healthFor(sourceName string) *sourceHealth {
+	health, exists := na.health[sourceName]
+	if !exists {
+		health = &sourceHealth{}
+		na.health[sourceName] = health
+	}
+	return health
+}
+
+// SourceStatuses возвращает состояние всех источников
+func (na *NewsAggregator) SourceStatuses() []SourceStatus {
+	na.mu.RLock()
+	defer na.mu.RUnlock()
+
+	statuses := make([]SourceStatus, 0, len(na.sources))
+	for _, source := range na.sources {
+		status := SourceStatus{Name: source.GetName()}
+		if health, exists := na.health[source.GetName()]; exists {
+			status.ConsecutiveFailures = health.consecutiveFailures
+			status.LastError = health.lastError
+			status.LastErrorAt = health.lastErrorAt
+			status.LastSuccessAt = health.lastSuccessAt
+			status.QuarantinedUntil = health.quarantinedUntil
+			status.Permanent = health.permanent
+		}
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// HealthySources возвращает число источников не в карантине и общее число источников
+func (na *NewsAggregator) HealthySources() (healthy, total int) {
+	for _, status := range na.SourceStatuses() {
+		if !status.Quarantined() {
+			healthy++
+		}
+		total++
+	}
+	return healthy, total
+}
+
+// CheckRandomSources загружает count случайно выбранных источников, минуя кэш свежести.
+// Возвращает ошибку, если не ответил ни один: так самопроверка замечает, что
+// источники недоступны целиком, а не ждет жалоб пользователей.
+func (na *NewsAggregator) CheckRandomSources(ctx context.Context, count int) (string, error) {
+	na.mu.RLock()
+	sources := make([]NewsSource, len(na.sources))
+	copy(sources, na.sources)
+	na.mu.RUnlock()
+
+	if len(sources) == 0 {
+		return "", errors.New("источники не настроены")
+	}
+	rand.Shuffle(len(sources), func(i, j int) { sources[i], sources[j] = sources[j], sources[i] })
+	sources = sources[:min(count, len(sources))]
+
+	details := make([]string, len(sources))
+	errs := make([]error, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			articles, _, err := na.refreshSource(ctx, source)
+			if err != nil {
+				details[i], errs[i] = fmt.Sprintf("%s: %v", source.GetName(), err), err
+				return
+			}
+			details[i] = fmt.Sprintf("%s: %d статей", source.GetName(), len(articles))
+		}()
+	}
+	wg.Wait()
+
+	detail := strings.Join(details, "; ")
+	for _, err := range errs {
+		if err == nil {
+			return detail, nil
+		}
+	}
+	return "", errors.New(detail)
+}
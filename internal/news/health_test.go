@@ -0,0 +1,98 @@
+package news
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPermanentFailureQuarantinesImmediately(t *testing.T) {
+	na := newTestAggregator(newFakeSource("gone"))
+	na.recordFailure("gone", &FetchError{Source: "gone", StatusCode: 404, Permanent: true, Err: errors.New("404")})
+
+	status := na.SourceStatuses()[0]
+	if !status.Quarantined() || !status.Permanent {
+		t.Fatalf("постоянная ошибка не отправила источник в карантин: %+v", status)
+	}
+	if until := time.Until(status.QuarantinedUntil); until < permanentQuarantine-time.Minute {
+		t.Errorf("карантин на %v, ожидалось около %v", until, permanentQuarantine)
+	}
+}
+
+func TestTransientFailuresQuarantineAfterThreshold(t *testing.T) {
+	na := newTestAggregator(newFakeSource("flaky"))
+	transient := &FetchError{Source: "flaky", StatusCode: 502, Err: errors.New("502")}
+
+	for i := 1; i < quarantineThreshold; i++ {
+		na.recordFailure("flaky", transient)
+		if na.isQuarantined("flaky") {
+			t.Fatalf("источник в карантине после %d временных ошибок", i)
+		}
+	}
+	na.recordFailure("flaky", transient)
+	if !na.isQuarantined("flaky") {
+		t.Fatalf("источник не в карантине после %d ошибок подряд", quarantineThreshold)
+	}
+
+	na.recordSuccess("flaky")
+	if na.isQuarantined("flaky") {
+		t.Fatal("успешный запрос не снял карантин")
+	}
+}
+
+func TestCheckRandomSources(t *testing.T) {
+	tests := []struct {
+		name       string
+		sources    []NewsSource
+		wantErr    bool
+		wantDetail string
+	}{
+		{"нет источников", nil, true, ""},
+		{"оба отвечают", []NewsSource{
+			newFakeSource("РБК", Article{Title: "A", URL: "https://a"}),
+			newFakeSource("ТАСС", Article{Title: "B", URL: "https://b"}, Article{Title: "C", URL: "https://c"}),
+		}, false, "статей"},
+		{"один из двух отвечает", []NewsSource{
+			newFakeSource("РБК", Article{Title: "A", URL: "https://a"}),
+			&fakeSource{name: "ТАСС", err: errors.New("403"), fetched: make(chan struct{}, 1)},
+		}, false, "ТАСС: 403"},
+		{"все недоступны", []NewsSource{
+			&fakeSource{name: "РБК", err: errors.New("403"), fetched: make(chan struct{}, 1)},
+			&fakeSource{name: "ТАСС", err: errors.New("403"), fetched: make(chan struct{}, 1)},
+		}, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			na := newTestAggregator(tt.sources...)
+			detail, err := na.CheckRandomSources(context.Background(), 2)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ошибка %v", err)
+			}
+			if !strings.Contains(detail, tt.wantDetail) {
+				t.Errorf("detail %q, ожидалось %q", detail, tt.wantDetail)
+			}
+		})
+	}
+}
+
+func TestCheckRandomSourcesLimitsCount(t *testing.T) {
+	var sources []NewsSource
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		sources = append(sources, newFakeSource(name))
+	}
+	na := newTestAggregator(sources...)
+
+	if _, err := na.CheckRandomSources(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+	fetched := 0
+	for _, source := range sources {
+		fetched += source.(*fakeSource).fetchCount()
+	}
+	if fetched != 2 {
+		t.Errorf("опрошено %d источников, ожидалось 2", fetched)
+	}
+}
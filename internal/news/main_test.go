@@ -0,0 +1,18 @@
+package news
+
+import (
+	"os"
+	"testing"
+
+	"AIGenerator/internal/httpx"
+)
+
+func TestMain(m *testing.M) {
+	// Тестовые серверы работают на одном хосте: без паузы между запросами
+	// к домену тесты повторов не ждут лишние полсекунды на каждый запрос
+	config := httpx.DefaultConfig()
+	config.DomainInterval = 0
+	httpx.Configure(config)
+
+	os.Exit(m.Run())
+}
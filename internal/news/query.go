@@ -0,0 +1,145 @@
+package news
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Query разобранный поисковый запрос.
+// Статья должна совпадать с как можно большим числом условий и не содержать исключений.
+type Query struct {
+	Clauses  []Clause
+	Excluded []string
+}
+
+// Clause одно условие запроса: слово, фраза или группа альтернатив через OR
+type Clause struct {
+	Alternatives []string
+}
+
+// QueryError ошибка разбора запроса с понятным пользователю описанием
+type QueryError struct {
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return e.Message
+}
+
+// queryToken элемент запроса после лексического разбора
+type queryToken struct {
+	text    string
+	quoted  bool
+	exclude bool
+}
+
+// ParseQuery разбирает запрос пользователя:
+// "фраза в кавычках" ищется целиком, -слово исключает статьи, A OR B задает альтернативы
+func ParseQuery(input string) (*Query, error) {
+	tokens, err := tokenizeQuery(input)
+	if err != nil {
+		return nil, err
+	}
+
+	query := &Query{}
+	expectAlternative := false
+
+	for i, token := range tokens {
+		isOr := !token.quoted && !token.exclude && token.text == "OR"
+
+		if isOr {
+			if len(query.Clauses) == 0 || expectAlternative || i == len(tokens)-1 {
+				return nil, &QueryError{Message: "OR должен стоять между двумя словами, например: биткоин OR эфириум"}
+			}
+			expectAlternative = true
+			continue
+		}
+
+		term := strings.ToLower(token.text)
+
+		if token.exclude {
+			if expectAlternative {
+				return nil, &QueryError{Message: "исключение (-слово) нельзя использовать как альтернативу в OR"}
+			}
+			query.Excluded = append(query.Excluded, term)
+			continue
+		}
+
+		if expectAlternative {
+			last := &query.Clauses[len(query.Clauses)-1]
+			last.Alternatives = append(last.Alternatives, term)
+			expectAlternative = false
+			continue
+		}
+
+		query.Clauses = append(query.Clauses, Clause{Alternatives: []string{term}})
+	}
+
+	if len(query.Clauses) == 0 {
+		return nil, &QueryError{Message: "в запросе должно быть хотя бы одно слово для поиска, а не только исключения"}
+	}
+
+	return query, nil
+}
+
+// tokenizeQuery разбивает запрос на слова и фразы в кавычках
+func tokenizeQuery(input string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(strings.TrimSpace(input))
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		exclude := false
+		if runes[i] == '-' {
+			exclude = true
+			i++
+			if i >= len(runes) || unicode.IsSpace(runes[i]) {
+				return nil, &QueryError{Message: "после минуса должно идти слово, например: телефон -samsung"}
+			}
+		}
+
+		if isQuote(runes[i]) {
+			end := i + 1
+			for end < len(runes) && !isQuote(runes[end]) {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, &QueryError{Message: "не закрыта кавычка во фразе"}
+			}
+			phrase := strings.Join(strings.Fields(string(runes[i+1:end])), " ")
+			if phrase == "" {
+				return nil, &QueryError{Message: "пустая фраза в кавычках"}
+			}
+			tokens = append(tokens, queryToken{text: phrase, quoted: true, exclude: exclude})
+			i = end + 1
+			continue
+		}
+
+		end := i
+		for end < len(runes) && !unicode.IsSpace(runes[end]) && !isQuote(runes[end]) {
+			end++
+		}
+		tokens = append(tokens, queryToken{text: string(runes[i:end]), exclude: exclude})
+		i = end
+	}
+
+	return tokens, nil
+}
+
+// isQuote проверяет, является ли символ кавычкой
+func isQuote(r rune) bool {
+	return r == '"' || r == '«' || r == '»' || r == '“' || r == '”'
+}
+
+// Terms возвращает все искомые слова и фразы запроса
+func (q *Query) Terms() []string {
+	var terms []string
+	for _, clause := range q.Clauses {
+		terms = append(terms, clause.Alternatives...)
+	}
+	return terms
+}
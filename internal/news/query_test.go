@@ -0,0 +1,124 @@
+package news
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		clauses  [][]string
+		excluded []string
+	}{
+		{"одно слово", "биткоин", [][]string{{"биткоин"}}, nil},
+		{"нижний регистр", "Нейросети ИИ", [][]string{{"нейросети"}, {"ии"}}, nil},
+		{"фраза в кавычках", `"искусственный интеллект"`, [][]string{{"искусственный интеллект"}}, nil},
+		{"фраза в елочках", "«умный   дом»", [][]string{{"умный дом"}}, nil},
+		{"фраза в лапках", "“электронные деньги”", [][]string{{"электронные деньги"}}, nil},
+		{"исключение", "телефон -samsung", [][]string{{"телефон"}}, []string{"samsung"}},
+		{"исключение фразы", `смартфон -"galaxy s24"`, [][]string{{"смартфон"}}, []string{"galaxy s24"}},
+		{"OR", "биткоин OR эфириум", [][]string{{"биткоин", "эфириум"}}, nil},
+		{"цепочка OR", "футбол OR хоккей OR теннис", [][]string{{"футбол", "хоккей", "теннис"}}, nil},
+		{"OR с фразой", `"умный дом" OR iot`, [][]string{{"умный дом", "iot"}}, nil},
+		{"or в нижнем регистре — обычное слово", "рок or поп", [][]string{{"рок"}, {"or"}, {"поп"}}, nil},
+		{"OR в кавычках — фраза", `"OR" новости`, [][]string{{"or"}, {"новости"}}, nil},
+		{"смешанный запрос", `"искусственный интеллект" медицина OR здоровье -реклама`,
+			[][]string{{"искусственный интеллект"}, {"медицина", "здоровье"}}, []string{"реклама"}},
+		{"дефис внутри слова", "онлайн-кинотеатр", [][]string{{"онлайн-кинотеатр"}}, nil},
+		{"лишние пробелы", "  космос   марс  ", [][]string{{"космос"}, {"марс"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := ParseQuery(tt.input)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q): %v", tt.input, err)
+			}
+			var clauses [][]string
+			for _, clause := range query.Clauses {
+				clauses = append(clauses, clause.Alternatives)
+			}
+			if !reflect.DeepEqual(clauses, tt.clauses) {
+				t.Errorf("условия = %q, ожидалось %q", clauses, tt.clauses)
+			}
+			if !reflect.DeepEqual(query.Excluded, tt.excluded) {
+				t.Errorf("исключения = %q, ожидалось %q", query.Excluded, tt.excluded)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"пустой запрос", ""},
+		{"только пробелы", "   "},
+		{"только исключения", "-реклама -спам"},
+		{"OR в начале", "OR биткоин"},
+		{"OR в конце", "биткоин OR"},
+		{"два OR подряд", "биткоин OR OR эфириум"},
+		{"исключение после OR", "биткоин OR -эфириум"},
+		{"одинокий минус", "телефон - samsung"},
+		{"минус в конце", "телефон -"},
+		{"незакрытая кавычка", `"искусственный интеллект`},
+		{"пустая фраза", `"  " новости`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseQuery(tt.input)
+			var queryErr *QueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("ParseQuery(%q) = %v, ожидалась QueryError", tt.input, err)
+			}
+			if queryErr.Message == "" {
+				t.Error("сообщение об ошибке пустое")
+			}
+		})
+	}
+}
+
+func TestQueryTerms(t *testing.T) {
+	query, err := ParseQuery(`"умный дом" OR iot безопасность -реклама`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"умный дом", "iot", "безопасность"}
+	if got := query.Terms(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Terms() = %q, ожидалось %q", got, want)
+	}
+}
+
+func TestKeywordMatchUsesParsedQuery(t *testing.T) {
+	na := newTestAggregator()
+	article := Article{Title: "Искусственный интеллект в медицине", Summary: "Новые исследования"}
+
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{`"искусственный интеллект"`, 1},
+		{`"интеллект искусственный"`, 0},
+		{"медицине OR образовании", 1},
+		{"медицине спорт", 0.5},
+	}
+	for _, tt := range tests {
+		query, err := ParseQuery(tt.input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := keywordMatch(article, na.expandKeywords(query)); got != tt.want {
+			t.Errorf("keywordMatch(%q) = %v, ожидалось %v", tt.input, got, tt.want)
+		}
+	}
+
+	query, _ := ParseQuery("медицине -исследования")
+	if got := na.filterExcluded([]Article{article}, query.Excluded); len(got) != 0 {
+		t.Error("статья с исключенным словом не отфильтрована")
+	}
+}
@@ -1,16 +1,40 @@
 package news
 
 import (
+	"AIGenerator/internal/httpx"
+	"context"
 	"encoding/xml"
 	"fmt"
+	"html"
 	"io"
 	"log"
+	"math/rand/v2"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+const (
+	// rssSourceTimeout общее время на загрузку одного источника, включая повторы
+	rssSourceTimeout = 25 * time.Second
+	// rssMaxRetries количество повторов при временных ошибках
+	rssMaxRetries = 2
+	// rssRetryBaseDelay базовая задержка перед повтором
+	rssRetryBaseDelay = 1 * time.Second
+	// rssMaxRetryDelay максимальная задержка перед повтором
+	rssMaxRetryDelay = 10 * time.Second
+	// minSummaryLength описание короче этого дополняется из полного текста
+	minSummaryLength = 120
+	// maxSummaryLength длина описания, построенного из полного текста
+	maxSummaryLength = 600
+)
+
+// rssClient общий HTTP-клиент для загрузки лент
+var rssClient = httpx.NewClient(10 * time.Second)
+
 // RSSSource представляет RSS-ленту как источник новостей с категориями
 type RSSSource struct {
 	Name        string
@@ -18,72 +42,144 @@ type RSSSource struct {
 	Category    string
 	Subcategory string
 	Language    string
+	// Weight ручная настройка веса источника (0 — вес рассчитывается по оценкам)
+	Weight float64
 }
 
 // RSS структура для парсинга RSS-лент
 type RSS struct {
 	Channel struct {
-		Title string `xml:"title"`
-		Item  []struct {
-			Title       string `xml:"title"`
-			Link        string `xml:"link"`
-			Description string `xml:"description"`
-			PubDate     string `xml:"pubDate"`
-			Category    string `xml:"category"`
-			Enclosure   []struct {
-				URL    string `xml:"url,attr"`
-				Type   string `xml:"type,attr"`
-				Length string `xml:"length,attr"`
-			} `xml:"enclosure"`
-			MediaContent struct {
-				URL    string `xml:"url,attr"`
-				Medium string `xml:"medium,attr"`
-				Type   string `xml:"type,attr"`
-			} `xml:"content"`
-			MediaThumbnail struct {
-				URL string `xml:"url,attr"`
-			} `xml:"thumbnail"`
-		} `xml:"item"`
+		Title string    `xml:"title"`
+		Item  []RSSItem `xml:"item"`
 	} `xml:"channel"`
 }
 
+// RSSItem элемент RSS-ленты с расширениями Media RSS, content и Яндекс.Новостей
+type RSSItem struct {
+	Title          string           `xml:"title"`
+	Link           string           `xml:"link"`
+	Description    string           `xml:"description"`
+	PubDate        string           `xml:"pubDate"`
+	Category       string           `xml:"category"`
+	Enclosure      []RSSEnclosure   `xml:"enclosure"`
+	MediaContent   []MediaContent   `xml:"content"`
+	MediaGroup     []MediaGroup     `xml:"group"`
+	MediaThumbnail []MediaThumbnail `xml:"thumbnail"`
+	// FullText полный текст статьи (yandex:full-text)
+	FullText string `xml:"full-text"`
+	// Encoded полный HTML статьи (content:encoded)
+	Encoded string `xml:"encoded"`
+}
+
+// RSSEnclosure вложение элемента RSS
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// MediaContent элемент media:content
+type MediaContent struct {
+	URL    string `xml:"url,attr"`
+	Medium string `xml:"medium,attr"`
+	Type   string `xml:"type,attr"`
+	Width  string `xml:"width,attr"`
+}
+
+// MediaGroup элемент media:group с несколькими вариантами медиа
+type MediaGroup struct {
+	Content []MediaContent `xml:"content"`
+}
+
+// MediaThumbnail элемент media:thumbnail
+type MediaThumbnail struct {
+	URL   string `xml:"url,attr"`
+	Width string `xml:"width,attr"`
+}
+
+// isImage проверяет, что media:content описывает изображение
+func (m MediaContent) isImage() bool {
+	if m.URL == "" {
+		return false
+	}
+	if m.Medium != "" || m.Type != "" {
+		return m.Medium == "image" || strings.Contains(m.Type, "image")
+	}
+	// Без medium и type угадываем по расширению файла
+	lower := strings.ToLower(m.URL)
+	for _, ext := range []string{".jpg", ".jpeg", ".png", ".webp", ".gif"} {
+		if strings.Contains(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSize разбирает числовой атрибут (width, length); некорректное значение считается нулем
+func parseSize(value string) int64 {
+	size, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || size < 0 {
+		return 0
+	}
+	return size
+}
+
+// content возвращает полный текст статьи: yandex:full-text, иначе content:encoded
+func (item RSSItem) content() string {
+	if text := cleanText(item.FullText); text != "" {
+		return text
+	}
+	return cleanText(item.Encoded)
+}
+
 // extractImageFromItem извлекает URL изображения из элемента RSS
-func extractImageFromItem(item struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-	Category    string `xml:"category"`
-	Enclosure   []struct {
-		URL    string `xml:"url,attr"`
-		Type   string `xml:"type,attr"`
-		Length string `xml:"length,attr"`
-	} `xml:"enclosure"`
-	MediaContent struct {
-		URL    string `xml:"url,attr"`
-		Medium string `xml:"medium,attr"`
-		Type   string `xml:"type,attr"`
-	} `xml:"content"`
-	MediaThumbnail struct {
-		URL string `xml:"url,attr"`
-	} `xml:"thumbnail"`
-}) string {
-	// 1. Проверяем media:content (обычно для медиа)
-	if item.MediaContent.URL != "" && (item.MediaContent.Medium == "image" ||
-		strings.Contains(item.MediaContent.Type, "image")) {
-		return item.MediaContent.URL
-	}
-
-	// 2. Проверяем enclosure (вложение)
+func extractImageFromItem(item RSSItem) string {
+	// 1. Проверяем media:content и media:group, выбираем самое широкое изображение
+	media := item.MediaContent
+	for _, group := range item.MediaGroup {
+		media = append(media, group.Content...)
+	}
+
+	bestURL := ""
+	var bestWidth int64 = -1
+	for _, content := range media {
+		if !content.isImage() {
+			continue
+		}
+		if width := parseSize(content.Width); width > bestWidth {
+			bestURL, bestWidth = content.URL, width
+		}
+	}
+	if bestURL != "" {
+		return bestURL
+	}
+
+	// 2. Проверяем enclosure (вложение), выбираем самое большое по размеру
+	var bestLength int64 = -1
 	for _, enclosure := range item.Enclosure {
-		if strings.Contains(enclosure.Type, "image") {
-			return enclosure.URL
+		if !strings.Contains(enclosure.Type, "image") {
+			continue
+		}
+		if length := parseSize(enclosure.Length); length > bestLength {
+			bestURL, bestLength = enclosure.URL, length
 		}
 	}
+	if bestURL != "" {
+		return bestURL
+	}
 
 	// 3. Проверяем thumbnail
-	if item.MediaThumbnail.URL != "" {
-		return item.MediaThumbnail.URL
+	bestWidth = -1
+	for _, thumbnail := range item.MediaThumbnail {
+		if thumbnail.URL == "" {
+			continue
+		}
+		if width := parseSize(thumbnail.Width); width > bestWidth {
+			bestURL, bestWidth = thumbnail.URL, width
+		}
+	}
+	if bestURL != "" {
+		return bestURL
 	}
 
 	// 4. Извлекаем из описания HTML
@@ -125,64 +221,99 @@ func (r *RSSSource) GetSubcategory() string {
 	return r.Subcategory
 }
 
-func (r *RSSSource) FetchArticles() ([]Article, error) {
+func (r *RSSSource) GetWeight() float64 {
+	return r.Weight
+}
+
+func (r *RSSSource) FetchArticles(ctx context.Context) ([]Article, error) {
+	articles, _, err := r.FetchArticlesConditional(ctx, CacheValidators{})
+	return articles, err
+}
+
+// FetchArticlesConditional загружает RSS с учетом ETag/Last-Modified прошлого запроса
+func (r *RSSSource) FetchArticlesConditional(ctx context.Context, validators CacheValidators) ([]Article, CacheValidators, error) {
 	log.Printf("[RSS] Загрузка RSS из %s", r.Name)
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, err := http.NewRequest("GET", r.URL, nil)
-	if err != nil {
-		log.Printf("[RSS] ❌ Ошибка создания запроса: %v", err)
-		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
-	}
+	ctx, cancel := context.WithTimeout(ctx, rssSourceTimeout)
+	defer cancel()
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	resp, err := fetchWithRetry(ctx, rssClient, r.Name, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "GET", r.URL, nil)
+		if err != nil {
+			return nil, err
+		}
 
-	resp, err := client.Do(req)
+		if validators.ETag != "" {
+			req.Header.Set("If-None-Match", validators.ETag)
+		}
+		if validators.LastModified != "" {
+			req.Header.Set("If-Modified-Since", validators.LastModified)
+		}
+		return req, nil
+	})
 	if err != nil {
 		log.Printf("[RSS] ❌ Ошибка получения RSS: %v", err)
-		return nil, fmt.Errorf("ошибка получения RSS: %w", err)
+		return nil, validators, &FetchError{Source: r.Name, Err: fmt.Errorf("ошибка получения RSS: %w", err)}
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		log.Printf("[RSS] %s не изменился с прошлого запроса", r.Name)
+		return nil, validators, ErrNotModified
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		log.Printf("[RSS] ❌ Ошибка статуса RSS: %d", resp.StatusCode)
-		return nil, fmt.Errorf("ошибка статуса RSS: %d", resp.StatusCode)
+		return nil, validators, &FetchError{
+			Source:     r.Name,
+			StatusCode: resp.StatusCode,
+			Permanent:  !isRetryableStatus(resp.StatusCode),
+			Err:        fmt.Errorf("ошибка статуса RSS: %d", resp.StatusCode),
+		}
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		log.Printf("[RSS] ❌ Ошибка чтения RSS: %v", err)
-		return nil, fmt.Errorf("ошибка чтения RSS: %w", err)
+		return nil, validators, &FetchError{Source: r.Name, Err: fmt.Errorf("ошибка чтения RSS: %w", err)}
 	}
 
 	var rss RSS
 	if err := xml.Unmarshal(body, &rss); err != nil {
 		log.Printf("[RSS] ❌ Ошибка парсинга RSS: %v", err)
-		return nil, fmt.Errorf("ошибка парсинга RSS: %w", err)
+		return nil, validators, &FetchError{Source: r.Name, Permanent: true, Err: fmt.Errorf("ошибка парсинга RSS: %w", err)}
 	}
 
 	var articles []Article
 	log.Printf("[RSS] Найдено %d элементов в RSS", len(rss.Channel.Item))
 
 	for i, item := range rss.Channel.Item {
+		// При ошибке дата остается нулевой: статья считается статьей с неизвестной датой
 		pubDate, err := parseDate(item.PubDate)
 		if err != nil {
 			log.Printf("[RSS] ❌ Ошибка парсинга даты для элемента %d: %v", i, err)
-			pubDate = time.Now()
 		}
 
 		// Пропускаем старые новости (больше 7 дней)
-		if time.Since(pubDate) > 7*24*time.Hour {
+		if !pubDate.IsZero() && time.Since(pubDate) > maxArticleAge {
 			continue
 		}
 
 		// Извлекаем изображение
 		imageURL := extractImageFromItem(item)
 
+		// Полный текст дает более содержательное описание, если описание короткое
+		content := item.content()
+		summary := cleanText(item.Description)
+		if utf8.RuneCountInString(summary) < minSummaryLength && utf8.RuneCountInString(content) > utf8.RuneCountInString(summary) {
+			summary = truncateRunes(content, maxSummaryLength)
+		}
+
 		article := Article{
 			Title:       cleanText(item.Title),
 			URL:         item.Link,
-			Summary:     cleanText(item.Description),
+			Summary:     summary,
+			Content:     content,
 			PublishedAt: pubDate,
 			Source:      r.Name,
 			Tags:        []string{item.Category},
@@ -196,16 +327,112 @@ func (r *RSSSource) FetchArticles() ([]Article, error) {
 		articles = append(articles, article)
 	}
 
+	newValidators := CacheValidators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
 	log.Printf("[RSS] Загружено %d статей из %s", len(articles), r.Name)
-	return articles, nil
+	return articles, newValidators, nil
+}
+
+// FetchError ошибка загрузки источника. Permanent отличает постоянные ошибки
+// (404, битый XML) от временных (таймаут, 5xx, 429).
+type FetchError struct {
+	Source     string
+	StatusCode int
+	Permanent  bool
+	Err        error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Source, e.Err)
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// isRetryableStatus проверяет, стоит ли повторять запрос с таким статусом
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// fetchWithRetry выполняет запрос с повторами при сетевых ошибках и статусах 5xx/429.
+// Возвращает последний полученный ответ; проверка статуса остается за вызывающим кодом.
+func fetchWithRetry(ctx context.Context, client *httpx.Client, sourceName string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, fmt.Errorf("ошибка создания запроса: %w", err)
+		}
+
+		resp, err := client.Do(req)
+		if err == nil && !isRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		if attempt >= rssMaxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay := retryDelay(attempt, resp)
+		if err != nil {
+			log.Printf("[RSS] ⚠️ %s: ошибка запроса (%v), повтор через %v", sourceName, err, delay)
+		} else {
+			log.Printf("[RSS] ⚠️ %s: статус %d, повтор через %v", sourceName, resp.StatusCode, delay)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// retryDelay вычисляет паузу перед повтором: Retry-After или экспоненциальная задержка со случайным разбросом
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if retryAfter := resp.Header.Get("Retry-After"); retryAfter != "" {
+			if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds >= 0 {
+				return min(time.Duration(seconds)*time.Second, rssMaxRetryDelay)
+			}
+			if at, err := http.ParseTime(retryAfter); err == nil {
+				return min(max(time.Until(at), 0), rssMaxRetryDelay)
+			}
+		}
+	}
+
+	delay := rssRetryBaseDelay << attempt
+	jitter := time.Duration(rand.Int64N(int64(delay / 2)))
+	return delay + jitter
 }
 
-// cleanText очищает текст от HTML тегов и лишних пробелов
+// truncateRunes обрезает текст до limit символов по границе слова
+func truncateRunes(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	cut := string(runes[:limit])
+	if idx := strings.LastIndex(cut, " "); idx > 0 {
+		cut = cut[:idx]
+	}
+	return strings.TrimSpace(cut) + "…"
+}
+
+// cleanText очищает текст от HTML тегов, CDATA, HTML-сущностей и лишних пробелов
 func cleanText(text string) string {
 	if text == "" {
 		return ""
 	}
 
+	// Некоторые ленты дважды оборачивают текст в CDATA или экранируют HTML
+	text = strings.ReplaceAll(text, "<![CDATA[", "")
+	text = strings.ReplaceAll(text, "]]>", "")
+	text = html.UnescapeString(text)
+
 	text = strings.TrimSpace(text)
 	text = strings.ReplaceAll(text, "\n", " ")
 	text = strings.ReplaceAll(text, "\t", " ")
@@ -228,6 +455,10 @@ func cleanText(text string) string {
 
 	text = result.String()
 
+	// Сущности могли быть экранированы дважды (&amp;quot;)
+	text = html.UnescapeString(text)
+	text = strings.ReplaceAll(text, "\u00a0", " ")
+
 	// Убираем множественные пробелы
 	for strings.Contains(text, "  ") {
 		text = strings.ReplaceAll(text, "  ", " ")
@@ -236,10 +467,78 @@ func cleanText(text string) string {
 	return strings.TrimSpace(text)
 }
 
-// parseDate пытается распарсить различные форматы дат
+// russianMonths сопоставляет началу русского названия месяца английское сокращение
+var russianMonths = []struct {
+	prefix  string
+	english string
+}{
+	{"янв", "Jan"}, {"фев", "Feb"}, {"мар", "Mar"}, {"апр", "Apr"},
+	{"май", "May"}, {"мая", "May"}, {"июн", "Jun"}, {"июл", "Jul"},
+	{"авг", "Aug"}, {"сен", "Sep"}, {"окт", "Oct"}, {"ноя", "Nov"},
+	{"дек", "Dec"},
+}
+
+// russianWeekdays начала русских названий дней недели, которые удаляются из даты
+var russianWeekdays = []string{"пн", "вт", "ср", "чт", "пт", "сб", "вс",
+	"пон", "вто", "сре", "чет", "пят", "суб", "вос"}
+
+// timeZoneOffsets смещения для нестандартных обозначений часовых поясов
+var timeZoneOffsets = map[string]string{
+	"MSK":  "+0300",
+	"МСК":  "+0300",
+	"UTC":  "+0000",
+	"GMT":  "+0000",
+	"UT":   "+0000",
+	"EEST": "+0300",
+	"EET":  "+0200",
+	"CEST": "+0200",
+	"CET":  "+0100",
+}
+
+var cyrillicWordRegex = regexp.MustCompile(`[А-Яа-яЁё]+\.?,?`)
+
+// normalizeDate приводит русские названия месяцев и нестандартные часовые пояса к форматам Go
+func normalizeDate(dateStr string) string {
+	dateStr = cyrillicWordRegex.ReplaceAllStringFunc(dateStr, func(word string) string {
+		trimmed := strings.TrimRight(word, ".,")
+		lower := strings.ToLower(trimmed)
+
+		// Обозначения часового пояса на кириллице
+		if offset, ok := timeZoneOffsets[strings.ToUpper(trimmed)]; ok {
+			return offset
+		}
+
+		for _, month := range russianMonths {
+			if strings.HasPrefix(lower, month.prefix) {
+				return month.english
+			}
+		}
+
+		for _, weekday := range russianWeekdays {
+			if strings.HasPrefix(lower, weekday) {
+				return ""
+			}
+		}
+
+		return word
+	})
+
+	fields := strings.Fields(dateStr)
+	for i, field := range fields {
+		if offset, ok := timeZoneOffsets[field]; ok {
+			fields[i] = offset
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// parseDate пытается распарсить различные форматы дат.
+// При неудаче возвращает нулевое время, чтобы дата считалась неизвестной.
 func parseDate(dateStr string) (time.Time, error) {
+	dateStr = strings.TrimSpace(dateStr)
 	if dateStr == "" {
-		return time.Now(), nil
+		return time.Time{}, nil
 	}
 
 	formats := []string{
@@ -247,25 +546,46 @@ func parseDate(dateStr string) (time.Time, error) {
 		time.RFC1123Z,
 		time.RFC822,
 		time.RFC822Z,
+		time.RFC3339,
+		time.RFC3339Nano,
 		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04 -0700",
+		"Mon, 02 Jan 2006 15:04:05 -0700",
 		"2006-01-02T15:04:05Z",
 		"2006-01-02T15:04:05-07:00",
+		"2006-01-02T15:04:05-0700",
+		"2006-01-02 15:04:05 -0700",
+		"2006-01-02 15:04:05",
 		"02.01.2006 15:04",
+		"02.01.2006 15:04:05",
 		"Mon, 02 Jan 2006 15:04:05 GMT",
 		"Mon, 2 Jan 2006 15:04:05 MST",
 		"2 Jan 2006 15:04:05 -0700",
+		"2 Jan 2006 15:04 -0700",
+		"2 Jan 2006 15:04:05",
+		"2 Jan 2006 15:04",
+		"2 Jan 2006, 15:04",
+		"2 Jan 2006",
 		"2006-01-02",
 		"02.01.2006",
 	}
 
-	for _, format := range formats {
-		if t, err := time.Parse(format, dateStr); err == nil {
-			return t, nil
+	// Сначала пробуем нормализованную строку: иначе "MSK" распарсится с нулевым смещением
+	candidates := []string{normalizeDate(dateStr)}
+	if candidates[0] != dateStr {
+		candidates = append(candidates, dateStr)
+	}
+
+	for _, candidate := range candidates {
+		for _, format := range formats {
+			if t, err := time.Parse(format, candidate); err == nil {
+				return t, nil
+			}
 		}
 	}
 
 	log.Printf("[DATE] Не удалось распарсить дату: %s", dateStr)
-	return time.Now(), fmt.Errorf("не удалось распарсить дату: %s", dateStr)
+	return time.Time{}, fmt.Errorf("не удалось распарсить дату: %s", dateStr)
 }
 
 // GetDefaultSources возвращает список RSS-лент с категориями
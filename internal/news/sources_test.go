@@ -0,0 +1,283 @@
+package news
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestParseDate(t *testing.T) {
+	msk := time.FixedZone("", 3*60*60)
+
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{"RFC1123Z", "Sun, 12 Jan 2025 14:30:00 +0300", time.Date(2025, 1, 12, 14, 30, 0, 0, msk)},
+		{"RFC1123 GMT", "Sun, 12 Jan 2025 11:30:00 GMT", time.Date(2025, 1, 12, 11, 30, 0, 0, time.UTC)},
+		{"одна цифра дня", "Sun, 2 Feb 2025 09:05:00 +0300", time.Date(2025, 2, 2, 9, 5, 0, 0, msk)},
+		{"RFC3339", "2025-01-12T14:30:00+03:00", time.Date(2025, 1, 12, 14, 30, 0, 0, msk)},
+		{"русский месяц", "12 янв 2025", time.Date(2025, 1, 12, 0, 0, 0, 0, time.UTC)},
+		{"русский месяц полностью", "3 марта 2025 10:15", time.Date(2025, 3, 3, 10, 15, 0, 0, time.UTC)},
+		{"родительный падеж мая", "9 мая 2025, 18:00", time.Date(2025, 5, 9, 18, 0, 0, 0, time.UTC)},
+		{"русский день недели и МСК", "Пн, 13 янв 2025 08:00:00 МСК", time.Date(2025, 1, 13, 8, 0, 0, 0, msk)},
+		{"сокращение с точкой", "21 дек. 2024 23:59 +0300", time.Date(2024, 12, 21, 23, 59, 0, 0, msk)},
+		{"зона MSK латиницей", "Mon, 13 Jan 2025 08:00:00 MSK", time.Date(2025, 1, 13, 8, 0, 0, 0, msk)},
+		{"точки в дате", "13.01.2025 08:00", time.Date(2025, 1, 13, 8, 0, 0, 0, time.UTC)},
+		{"дата без времени", "2025-01-13", time.Date(2025, 1, 13, 0, 0, 0, 0, time.UTC)},
+		{"пробелы по краям", "  Sun, 12 Jan 2025 14:30:00 +0300\n", time.Date(2025, 1, 12, 14, 30, 0, 0, msk)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDate(tt.input)
+			if err != nil {
+				t.Fatalf("parseDate(%q): %v", tt.input, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("parseDate(%q) = %v, ожидалось %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDateFailureReturnsZeroTime(t *testing.T) {
+	for _, input := range []string{"вчера", "not a date", "32 янв 2025", "12/31/2025 noon"} {
+		got, err := parseDate(input)
+		if err == nil {
+			t.Errorf("parseDate(%q) без ошибки вернул %v", input, got)
+		}
+		if !got.IsZero() {
+			t.Errorf("parseDate(%q) = %v, ожидалось нулевое время", input, got)
+		}
+	}
+
+	got, err := parseDate("")
+	if err != nil || !got.IsZero() {
+		t.Errorf("пустая дата: %v, %v", got, err)
+	}
+}
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"CDATA", "<![CDATA[Новый смартфон]]>", "Новый смартфон"},
+		{"двойной CDATA", "<![CDATA[<![CDATA[Заголовок]]>]]>", "Заголовок"},
+		{"HTML внутри CDATA", "<![CDATA[<p>Первый <b>абзац</b></p>]]>", "Первый абзац"},
+		{"сущности", "Компания &laquo;Яндекс&raquo; &amp; партнеры", "Компания «Яндекс» & партнеры"},
+		{"экранированный HTML", "&lt;p&gt;Текст&lt;/p&gt;", "Текст"},
+		{"двойное экранирование", "&amp;quot;Цитата&amp;quot;", `"Цитата"`},
+		{"неразрывные пробелы", "10&nbsp;000 рублей", "10 000 рублей"},
+		{"переносы и табуляции", "Строка\nвторая\tтретья", "Строка вторая третья"},
+		{"множественные пробелы", "  много    пробелов  ", "много пробелов"},
+		{"пусто", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cleanText(tt.input); got != tt.want {
+				t.Errorf("cleanText(%q) = %q, ожидалось %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// testFeed минимальная RSS-лента с одной статьей
+const testFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"><channel><title>Тест</title>
+<item><title>Новость</title><link>https://example.com/1</link><description>Описание</description></item>
+</channel></rss>`
+
+func TestFetchArticlesRetriesTransientFailure(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fmt.Fprint(w, testFeed)
+	}))
+	defer server.Close()
+
+	source := &RSSSource{Name: "test", URL: server.URL}
+	articles, err := source.FetchArticles(context.Background())
+	if err != nil {
+		t.Fatalf("FetchArticles: %v", err)
+	}
+	if len(articles) != 1 || articles[0].Title != "Новость" {
+		t.Fatalf("статьи = %+v", articles)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("запросов = %d, ожидалось 2", got)
+	}
+}
+
+func TestFetchArticlesClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		wantRequests int32
+		permanent    bool
+	}{
+		{"404 без повторов", http.StatusNotFound, "", 1, true},
+		{"битый XML", http.StatusOK, "<rss><channel><item>", 1, true},
+		{"5xx после всех повторов", http.StatusServiceUnavailable, "", rssMaxRetries + 1, false},
+		{"429 после всех повторов", http.StatusTooManyRequests, "", rssMaxRetries + 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer server.Close()
+
+			source := &RSSSource{Name: "test", URL: server.URL}
+			_, err := source.FetchArticles(context.Background())
+
+			var fetchErr *FetchError
+			if !errors.As(err, &fetchErr) {
+				t.Fatalf("ошибка %v не является FetchError", err)
+			}
+			if fetchErr.Permanent != tt.permanent {
+				t.Errorf("Permanent = %v, ожидалось %v", fetchErr.Permanent, tt.permanent)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("запросов = %d, ожидалось %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetryDelayHonorsRetryAfter(t *testing.T) {
+	resp := &http.Response{Header: http.Header{"Retry-After": []string{"3"}}}
+	if got := retryDelay(0, resp); got != 3*time.Second {
+		t.Errorf("retryDelay = %v, ожидалось 3s", got)
+	}
+
+	resp.Header.Set("Retry-After", "3600")
+	if got := retryDelay(0, resp); got != rssMaxRetryDelay {
+		t.Errorf("retryDelay = %v, ожидалось ограничение %v", got, rssMaxRetryDelay)
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		base := rssRetryBaseDelay << attempt
+		if got := retryDelay(attempt, nil); got < base || got >= base+base/2 {
+			t.Errorf("попытка %d: retryDelay = %v вне [%v, %v)", attempt, got, base, base+base/2)
+		}
+	}
+}
+
+// serveFixture отдает ленту из testdata с датами публикации, сдвинутыми на текущее время,
+// чтобы статьи не отбрасывались как устаревшие
+func serveFixture(t *testing.T, name string) *RSSSource {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Format(time.RFC1123Z)
+	feed := regexp.MustCompile(`<pubDate>[^<]*</pubDate>`).ReplaceAll(data, []byte("<pubDate>"+now+"</pubDate>"))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(feed)
+	}))
+	t.Cleanup(server.Close)
+	return &RSSSource{Name: name, URL: server.URL}
+}
+
+func TestYandexFullTextAndMultipleMediaContent(t *testing.T) {
+	articles, err := serveFixture(t, "lenta.xml").FetchArticles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 1 {
+		t.Fatalf("статей: %d", len(articles))
+	}
+	article := articles[0]
+
+	if !strings.HasPrefix(article.Content, "Ракета-носитель стартовала с космодрома Восточный") {
+		t.Errorf("Content = %q, ожидался текст yandex:full-text", article.Content)
+	}
+	// Короткое описание дополняется из полного текста
+	if !strings.HasPrefix(article.Summary, "Ракета-носитель") || utf8.RuneCountInString(article.Summary) <= minSummaryLength {
+		t.Errorf("Summary = %q, ожидалось описание из полного текста", article.Summary)
+	}
+	// Из нескольких media:content выбирается самое широкое изображение, видео пропускается
+	if article.ImageURL != "https://icdn.lenta.ru/large.jpg" {
+		t.Errorf("ImageURL = %q", article.ImageURL)
+	}
+}
+
+func TestContentEncodedMediaGroupAndEnclosures(t *testing.T) {
+	articles, err := serveFixture(t, "ria.xml").FetchArticles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(articles) != 2 {
+		t.Fatalf("статей: %d", len(articles))
+	}
+
+	rate := articles[0]
+	if !strings.Contains(rate.Content, "допустил снижение ставки") || strings.Contains(rate.Content, "<p>") {
+		t.Errorf("Content = %q, ожидался очищенный content:encoded", rate.Content)
+	}
+	// media:group и отдельные media:content сравниваются вместе
+	if rate.ImageURL != "https://cdnn21.img.ria.ru/1920.jpg" {
+		t.Errorf("ImageURL = %q", rate.ImageURL)
+	}
+
+	// Без media:content выбирается самое большое вложение; некорректная длина считается нулем
+	if articles[1].ImageURL != "https://cdnn21.img.ria.ru/big.jpg" {
+		t.Errorf("ImageURL вложения = %q", articles[1].ImageURL)
+	}
+	if articles[1].Content != "" || articles[1].Summary != "Котировки на открытии торгов." {
+		t.Errorf("статья без полного текста: %+v", articles[1])
+	}
+}
+
+func TestFetchArticlesCancelsInFlightRequest(t *testing.T) {
+	released := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(released)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	started := time.Now()
+	_, err := (&RSSSource{Name: "slow", URL: server.URL}).FetchArticles(ctx)
+	if err == nil {
+		t.Fatal("запрос завершился без ошибки")
+	}
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("запрос остановился через %v после отмены", elapsed)
+	}
+	select {
+	case <-released:
+	case <-time.After(time.Second):
+		t.Fatal("сервер не увидел отмену запроса")
+	}
+}
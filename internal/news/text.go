@@ -0,0 +1,97 @@
+package news
+
+import (
+	"strings"
+	"unicode"
+)
+
+// stopwords частые слова, не несущие смысла для выделения тем
+var stopwords = makeSet(
+	// Русские служебные слова
+	"и", "в", "во", "не", "что", "он", "на", "я", "с", "со", "как", "а", "то", "все", "она", "так",
+	"его", "но", "да", "ты", "к", "у", "же", "вы", "за", "бы", "по", "только", "ее", "её", "мне",
+	"было", "вот", "от", "меня", "еще", "ещё", "нет", "о", "из", "ему", "теперь", "когда", "даже",
+	"ну", "вдруг", "ли", "если", "уже", "или", "ни", "быть", "был", "него", "до", "вас", "нибудь",
+	"опять", "уж", "вам", "ведь", "там", "потом", "себя", "ничего", "ей", "может", "они", "тут",
+	"где", "есть", "надо", "ней", "для", "мы", "тебя", "их", "чем", "была", "сам", "чтоб", "без",
+	"будто", "чего", "раз", "тоже", "себе", "под", "будет", "ж", "тогда", "кто", "этот", "того",
+	"потому", "этого", "какой", "совсем", "ним", "здесь", "этом", "один", "почти", "мой", "тем",
+	"чтобы", "нее", "сейчас", "были", "куда", "зачем", "всех", "никогда", "можно", "при",
+	"наконец", "два", "об", "другой", "хоть", "после", "над", "больше", "тот", "через", "эти",
+	"нас", "про", "всего", "них", "какая", "много", "разве", "три", "эту", "моя", "впрочем",
+	"хорошо", "свою", "этой", "перед", "иногда", "лучше", "чуть", "том", "нельзя", "такой", "им",
+	"более", "всегда", "конечно", "всю", "между", "это", "также", "который", "которые", "которая",
+	"которых", "которой", "котором", "свой", "своих", "своей", "года", "году", "лет", "год",
+	"время", "новый", "новая", "новые", "новых", "стал", "стала", "стали", "будут", "является",
+	"заявил", "заявила", "сообщил", "сообщила", "сообщили", "рассказал", "отметил", "сказал",
+	"могут", "должны", "должен", "около", "менее", "млн", "млрд", "тыс",
+	"рублей", "руб", "россии", "рф", "сегодня", "вчера", "завтра", "пока", "ранее",
+	// Английские служебные слова
+	"the", "a", "an", "and", "or", "of", "to", "in", "on", "for", "with", "is", "are", "was",
+	"were", "be", "by", "at", "as", "it", "its", "this", "that", "from", "new", "has", "have",
+)
+
+// russianEndings окончания, отсекаемые упрощенным стеммером (от длинных к коротким)
+var russianEndings = []string{
+	"иями", "ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими", "ией", "ия", "ие",
+	"ий", "ый", "ой", "ая", "яя", "ое", "ее", "ые", "ов", "ев", "ей", "ам", "ям", "ах",
+	"ях", "ом", "ем", "ую", "юю", "ых", "их", "ть", "ет", "ит", "ут", "ют", "ат", "ят",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+// minStemLength минимальная длина основы после отсечения окончания
+const minStemLength = 4
+
+func makeSet(words ...string) map[string]bool {
+	set := make(map[string]bool, len(words))
+	for _, word := range words {
+		set[word] = true
+	}
+	return set
+}
+
+// Tokenize разбивает текст на слова в нижнем регистре (буквы и цифры)
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// IsStopword проверяет, является ли слово служебным
+func IsStopword(word string) bool {
+	return stopwords[word]
+}
+
+// Stem возвращает упрощенную основу слова: отсекает типичное русское окончание
+func Stem(word string) string {
+	runes := []rune(word)
+	for _, ending := range russianEndings {
+		endingRunes := []rune(ending)
+		if len(runes)-len(endingRunes) >= minStemLength && strings.HasSuffix(word, ending) {
+			return string(runes[:len(runes)-len(endingRunes)])
+		}
+	}
+	return word
+}
+
+// MeaningfulTokens возвращает значимые слова текста: без служебных слов, чисел и коротких слов
+func MeaningfulTokens(text string) []string {
+	var tokens []string
+	for _, token := range Tokenize(text) {
+		if len([]rune(token)) < 3 || IsStopword(token) || isNumber(token) {
+			continue
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// isNumber проверяет, состоит ли слово только из цифр
+func isNumber(word string) bool {
+	for _, r := range word {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package news
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Trend популярная тема в свежих новостях
+type Trend struct {
+	// Label отображаемое название темы
+	Label string
+	// Articles число статей, в которых встречается тема
+	Articles int
+	// stems основы слов темы, используются для склейки дублей
+	stems []string
+}
+
+const (
+	// minTrendArticles минимальное число статей, чтобы тема считалась трендом
+	minTrendArticles = 2
+	// bigramBoost вес словосочетаний относительно отдельных слов
+	bigramBoost = 1.5
+)
+
+// trendCandidate кандидат в тренды с подсчетом форм слов
+type trendCandidate struct {
+	stems    []string
+	docs     map[int]bool
+	surfaces map[string]int
+}
+
+// ExtractTrends выделяет самые частые темы (слова и словосочетания) из статей,
+// склеивая близкие по основам темы
+func ExtractTrends(articles []Article, limit int) []Trend {
+	candidates := make(map[string]*trendCandidate)
+
+	addCandidate := func(key string, stems []string, surface string, doc int) {
+		candidate, exists := candidates[key]
+		if !exists {
+			candidate = &trendCandidate{
+				stems:    stems,
+				docs:     make(map[int]bool),
+				surfaces: make(map[string]int),
+			}
+			candidates[key] = candidate
+		}
+		candidate.docs[doc] = true
+		candidate.surfaces[surface]++
+	}
+
+	for i, article := range articles {
+		tokens := MeaningfulTokens(article.Title + ". " + article.Summary)
+		for j, token := range tokens {
+			stem := Stem(token)
+			addCandidate(stem, []string{stem}, token, i)
+
+			if j+1 < len(tokens) {
+				next := tokens[j+1]
+				nextStem := Stem(next)
+				if nextStem != stem {
+					addCandidate(stem+" "+nextStem, []string{stem, nextStem}, token+" "+next, i)
+				}
+			}
+		}
+	}
+
+	type scored struct {
+		candidate *trendCandidate
+		score     float64
+	}
+
+	var ranked []scored
+	for _, candidate := range candidates {
+		if len(candidate.docs) < minTrendArticles {
+			continue
+		}
+		score := float64(len(candidate.docs))
+		if len(candidate.stems) > 1 {
+			score *= bigramBoost
+		}
+		ranked = append(ranked, scored{candidate: candidate, score: score})
+	}
+
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return strings.Join(ranked[i].candidate.stems, " ") < strings.Join(ranked[j].candidate.stems, " ")
+	})
+
+	// Жадно выбираем темы, пропуская те, что пересекаются по основам с уже выбранными
+	var trends []Trend
+	usedStems := make(map[string]bool)
+	for _, item := range ranked {
+		if len(trends) >= limit {
+			break
+		}
+
+		duplicate := false
+		for _, stem := range item.candidate.stems {
+			if usedStems[stem] {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		for _, stem := range item.candidate.stems {
+			usedStems[stem] = true
+		}
+		trends = append(trends, Trend{
+			Label:    mostFrequentSurface(item.candidate.surfaces),
+			Articles: len(item.candidate.docs),
+			stems:    item.candidate.stems,
+		})
+	}
+
+	return trends
+}
+
+// mostFrequentSurface возвращает самую частую форму написания темы
+func mostFrequentSurface(surfaces map[string]int) string {
+	best, bestCount := "", 0
+	for surface, count := range surfaces {
+		if count > bestCount || (count == bestCount && surface < best) {
+			best, bestCount = surface, count
+		}
+	}
+	return best
+}
+
+// RecentArticles возвращает статьи из кэша не старше maxAge без военных тем.
+// Если кэш пуст, статьи загружаются из источников.
+func (na *NewsAggregator) RecentArticles(ctx context.Context, maxAge time.Duration) []Article {
+	na.mu.RLock()
+	var articles []Article
+	for _, entry := range na.cache {
+		articles = append(articles, entry.articles...)
+	}
+	na.mu.RUnlock()
+
+	if len(articles) == 0 {
+		articles, _ = na.fetchAll(ctx)
+	}
+
+	// Одна и та же новость может прийти из нескольких источников
+	seen := make(map[string]bool)
+	var recent []Article
+	for _, article := range filterByAge(articles, maxAge) {
+		if !article.PublishedAt.IsZero() && !seen[article.URL] {
+			seen[article.URL] = true
+			recent = append(recent, article)
+		}
+	}
+
+	return na.FilterOutMilitaryTopics(recent)
+}
@@ -0,0 +1,102 @@
+package news
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func loadTrendsCorpus(t *testing.T) []Article {
+	t.Helper()
+	data, err := os.ReadFile("testdata/trends_corpus.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var articles []Article
+	if err := json.Unmarshal(data, &articles); err != nil {
+		t.Fatal(err)
+	}
+	return articles
+}
+
+func TestExtractTrends(t *testing.T) {
+	trends := ExtractTrends(loadTrendsCorpus(t), 10)
+	if len(trends) == 0 {
+		t.Fatal("тренды не найдены")
+	}
+
+	// Словосочетание из трех статей важнее отдельных слов
+	if trends[0].Label != "ключевую ставку" || trends[0].Articles != 3 {
+		t.Errorf("первый тренд = %+v, ожидалось «ключевую ставку» в 3 статьях", trends[0])
+	}
+
+	labels := make(map[string]bool)
+	usedStems := make(map[string]bool)
+	for _, trend := range trends {
+		labels[trend.Label] = true
+		if trend.Articles < minTrendArticles {
+			t.Errorf("тренд %q встречается только в %d статьях", trend.Label, trend.Articles)
+		}
+		for _, word := range strings.Fields(trend.Label) {
+			if IsStopword(word) {
+				t.Errorf("тренд %q содержит служебное слово %q", trend.Label, word)
+			}
+		}
+		// Близкие темы склеены: основы слов не повторяются между трендами
+		for _, stem := range trend.stems {
+			if usedStems[stem] {
+				t.Errorf("основа %q встречается в нескольких трендах", stem)
+			}
+			usedStems[stem] = true
+		}
+	}
+
+	for _, want := range []string{"apple", "iphone", "ипотека"} {
+		if !labels[want] {
+			t.Errorf("нет ожидаемого тренда %q среди %v", want, labels)
+		}
+	}
+	for _, unwanted := range []string{"хоккею", "погода", "ставка", "ключевая"} {
+		if labels[unwanted] {
+			t.Errorf("лишний тренд %q", unwanted)
+		}
+	}
+}
+
+func TestExtractTrendsRespectsLimit(t *testing.T) {
+	if got := ExtractTrends(loadTrendsCorpus(t), 3); len(got) != 3 {
+		t.Fatalf("получено %d трендов, ожидалось 3", len(got))
+	}
+	if got := ExtractTrends(nil, 10); len(got) != 0 {
+		t.Fatalf("из пустого набора получено %d трендов", len(got))
+	}
+}
+
+func TestMeaningfulTokens(t *testing.T) {
+	got := MeaningfulTokens("В 2025 году Банк России и ЦБ повысили ставку до 18%!")
+	want := []string{"банк", "повысили", "ставку"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MeaningfulTokens = %q, ожидалось %q", got, want)
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"ставку":    "ставк",
+		"ставка":    "ставк",
+		"ставкой":   "ставк",
+		"ипотека":   "ипотек",
+		"ипотеки":   "ипотек",
+		"банки":     "банк",
+		"iphone":    "iphone",
+		"мир":       "мир",
+		"новостями": "новост",
+	}
+	for word, want := range tests {
+		if got := Stem(word); got != want {
+			t.Errorf("Stem(%q) = %q, ожидалось %q", word, got, want)
+		}
+	}
+}
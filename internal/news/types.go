@@ -1,6 +1,8 @@
 package news
 
 import (
+	"context"
+	"errors"
 	"time"
 )
 
@@ -19,6 +21,26 @@ type Article struct {
 
 // NewsSource представляет источник новостей
 type NewsSource interface {
-	FetchArticles() ([]Article, error)
+	FetchArticles(ctx context.Context) ([]Article, error)
 	GetName() string
 }
+
+// WeightedSource источник с заданным вручную весом релевантности
+type WeightedSource interface {
+	GetWeight() float64
+}
+
+// CacheValidators значения для условных HTTP-запросов (ETag / Last-Modified)
+type CacheValidators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// ConditionalSource источник, поддерживающий условные запросы.
+// Если данные не изменились, возвращает ErrNotModified.
+type ConditionalSource interface {
+	FetchArticlesConditional(ctx context.Context, validators CacheValidators) ([]Article, CacheValidators, error)
+}
+
+// ErrNotModified означает, что источник не изменился с прошлого запроса
+var ErrNotModified = errors.New("источник не изменился")
@@ -1,22 +1,37 @@
 package payment
 
 import (
+	"AIGenerator/internal/logging"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// defaultBaseURL адрес API ЮKassa
+const defaultBaseURL = "https://api.yookassa.ru/v3/"
+
+// Config доступ к ЮKassa
+type Config struct {
+	ShopID    string
+	SecretKey string
+	// ReturnURL куда вернуть пользователя после оплаты
+	ReturnURL string
+	// BaseURL адрес API; пустой — API ЮKassa. Другой адрес задается в тестах.
+	BaseURL string
+}
+
 // YooMoneyClient клиент для работы с API ЮKassa
 type YooMoneyClient struct {
 	shopID     string
 	secretKey  string
+	returnURL  string
 	baseURL    string
 	httpClient *http.Client
 }
@@ -74,28 +89,32 @@ type PaymentResponse struct {
 	Paid     bool                   `json:"paid"`
 }
 
-// NewYooMoneyClient создает новый клиент ЮKassa
-func NewYooMoneyClient() (*YooMoneyClient, error) {
-	shopID := os.Getenv("YOOMONEY_SHOP_ID")
-	secretKey := os.Getenv("YOOMONEY_SECRET_KEY")
+// logger возвращает логгер пакета
+func logger() *slog.Logger {
+	return logging.For("yoomoney")
+}
 
-	if shopID == "" {
-		log.Println("[YOOMONEY] ⚠️ YOOMONEY_SHOP_ID не установлен")
-	}
-	if secretKey == "" {
-		log.Println("[YOOMONEY] ⚠️ YOOMONEY_SECRET_KEY не установлен")
-	}
+// NewYooMoneyClient создает новый клиент ЮKassa
+func NewYooMoneyClient(config Config) (*YooMoneyClient, error) {
+	shopID := config.ShopID
+	secretKey := config.SecretKey
 
 	if shopID == "" || secretKey == "" {
 		return nil, fmt.Errorf("YOOMONEY_SHOP_ID или YOOMONEY_SECRET_KEY не установлены")
 	}
 
-	log.Printf("[YOOMONEY] Клиент создан с shopID: %s", shopID)
+	logger().Info("клиент создан", "shop_id", shopID)
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
 
 	return &YooMoneyClient{
 		shopID:    shopID,
 		secretKey: secretKey,
-		baseURL:   "https://api.yookassa.ru/v3/",
+		returnURL: config.ReturnURL,
+		baseURL:   baseURL,
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
@@ -105,11 +124,11 @@ func NewYooMoneyClient() (*YooMoneyClient, error) {
 // CreatePayment создает новый платеж
 func (c *YooMoneyClient) CreatePayment(amount float64, description string, userID int64, packageType string, count int) (*PaymentResponse, error) {
 	url := c.baseURL + "payments"
-	log.Printf("[YOOMONEY] Создание платежа: %.2f RUB, описание: %s", amount, description)
+	log := logger().With(logging.KeyUserID, userID)
+	log.Info("создание платежа", "amount", amount, "package", packageType)
 
 	// Генерируем уникальный ключ идемпотентности
 	idempotenceKey := uuid.New().String()
-	log.Printf("[YOOMONEY] Idempotence-Key: %s", idempotenceKey)
 
 	// Создаем запрос
 	paymentReq := PaymentRequest{}
@@ -118,12 +137,12 @@ func (c *YooMoneyClient) CreatePayment(amount float64, description string, userI
 	paymentReq.Capture = true
 	paymentReq.Description = description
 	paymentReq.Confirmation.Type = "redirect"
-	paymentReq.Confirmation.ReturnURL = os.Getenv("YOOMONEY_RETURN_URL")
+	paymentReq.Confirmation.ReturnURL = c.returnURL
 
 	// Устанавливаем возвратный URL
 	if paymentReq.Confirmation.ReturnURL == "" {
 		paymentReq.Confirmation.ReturnURL = "https://t.me/"
-		log.Printf("[YOOMONEY] Return URL не установлен, используется: %s", paymentReq.Confirmation.ReturnURL)
+		log.Debug("return URL не установлен", "return_url", paymentReq.Confirmation.ReturnURL)
 	}
 
 	// Устанавливаем метаданные
@@ -161,15 +180,13 @@ func (c *YooMoneyClient) CreatePayment(amount float64, description string, userI
 
 	jsonData, err := json.Marshal(paymentReq)
 	if err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка маршалинга запроса: %v", err)
+		log.Error("ошибка маршалинга запроса", logging.KeyError, err)
 		return nil, fmt.Errorf("ошибка маршалинга: %w", err)
 	}
 
-	log.Printf("[YOOMONEY] JSON запрос: %s", string(jsonData))
-
 	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка создания запроса: %v", err)
+		log.Error("ошибка создания запроса", logging.KeyError, err)
 		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
 	}
 
@@ -178,27 +195,22 @@ func (c *YooMoneyClient) CreatePayment(amount float64, description string, userI
 	req.Header.Set("Idempotence-Key", idempotenceKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	log.Printf("[YOOMONEY] Отправка запроса на %s", url)
-	log.Printf("[YOOMONEY] Auth header: Basic %s:****", c.shopID)
-
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка отправки запроса: %v", err)
+		log.Error("ошибка отправки запроса", logging.KeyError, err, logging.KeyDuration, time.Since(start))
 		return nil, fmt.Errorf("ошибка отправки запроса: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка чтения ответа: %v", err)
+		log.Error("ошибка чтения ответа", logging.KeyError, err)
 		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
 	}
 
-	log.Printf("[YOOMONEY] Ответ от API: статус %d", resp.StatusCode)
-	log.Printf("[YOOMONEY] Тело ответа: %s", string(body))
-
+	// Тело ответа не пишется: в нем email из чека
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("[YOOMONEY] ❌ Ошибка API: статус %d", resp.StatusCode)
 
 		// Пробуем распарсить ошибку
 		var errorResp struct {
@@ -210,35 +222,59 @@ func (c *YooMoneyClient) CreatePayment(amount float64, description string, userI
 		}
 
 		if err := json.Unmarshal(body, &errorResp); err == nil && errorResp.Description != "" {
-			log.Printf("[YOOMONEY] Ошибка ЮKassa: %s (код: %s)", errorResp.Description, errorResp.Code)
+			log.Error("ошибка ЮKassa", "status", resp.StatusCode, "code", errorResp.Code,
+				"description", errorResp.Description, logging.KeyDuration, time.Since(start))
 			return nil, fmt.Errorf("ошибка ЮKassa: %s", errorResp.Description)
 		}
 
+		log.Error("ошибка API", "status", resp.StatusCode, logging.KeyDuration, time.Since(start))
 		return nil, fmt.Errorf("ошибка API: статус %d", resp.StatusCode)
 	}
 
 	var paymentResp PaymentResponse
 	if err := json.Unmarshal(body, &paymentResp); err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка парсинга ответа: %v", err)
+		log.Error("ошибка парсинга ответа", logging.KeyError, err)
 		return nil, fmt.Errorf("ошибка парсинга ответа: %w", err)
 	}
 
-	log.Printf("[YOOMONEY] ✅ Платеж создан: ID=%s, статус=%s", paymentResp.ID, paymentResp.Status)
-	if paymentResp.Confirmation.ConfirmationURL != "" {
-		log.Printf("[YOOMONEY] URL для оплаты: %s", paymentResp.Confirmation.ConfirmationURL)
-	}
+	log.Info("платеж создан", logging.KeyPaymentID, paymentResp.ID, "status", paymentResp.Status,
+		logging.KeyDuration, time.Since(start))
 
 	return &paymentResp, nil
 }
 
+// CheckCredentials проверяет ключи запросом информации о магазине
+func (c *YooMoneyClient) CheckCredentials(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"me", nil)
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.SetBasicAuth(c.shopID, c.secretKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка отправки запроса: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("ключи ЮKassa не приняты: статус %d", resp.StatusCode)
+	default:
+		return fmt.Errorf("ошибка API: статус %d", resp.StatusCode)
+	}
+}
+
 // CheckPayment проверяет статус платежа
 func (c *YooMoneyClient) CheckPayment(paymentID string) (*PaymentResponse, error) {
 	url := c.baseURL + "payments/" + paymentID
-	log.Printf("[YOOMONEY] Проверка статуса платежа: %s", paymentID)
+	log := logger().With(logging.KeyPaymentID, paymentID)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка создания запроса: %v", err)
+		log.Error("ошибка создания запроса", logging.KeyError, err)
 		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
 	}
 
@@ -246,43 +282,44 @@ func (c *YooMoneyClient) CheckPayment(paymentID string) (*PaymentResponse, error
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка отправки запроса: %v", err)
+		log.Error("ошибка отправки запроса", logging.KeyError, err)
 		return nil, fmt.Errorf("ошибка отправки запроса: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка чтения ответа: %v", err)
+		log.Error("ошибка чтения ответа", logging.KeyError, err)
 		return nil, fmt.Errorf("ошибка чтения ответа: %w", err)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		log.Printf("[YOOMONEY] ❌ Ошибка API при проверке: статус %d, тело: %s", resp.StatusCode, string(body))
+		log.Error("ошибка API при проверке", "status", resp.StatusCode)
 		return nil, fmt.Errorf("ошибка API: статус %d", resp.StatusCode)
 	}
 
 	var paymentResp PaymentResponse
 	if err := json.Unmarshal(body, &paymentResp); err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка парсинга ответа: %v", err)
+		log.Error("ошибка парсинга ответа", logging.KeyError, err)
 		return nil, fmt.Errorf("ошибка парсинга ответа: %w", err)
 	}
 
-	log.Printf("[YOOMONEY] Статус платежа %s: %s", paymentID, paymentResp.Status)
+	log.Debug("статус платежа", "status", paymentResp.Status)
 	return &paymentResp, nil
 }
 
 // CancelPayment отменяет платеж
 func (c *YooMoneyClient) CancelPayment(paymentID string) error {
 	url := c.baseURL + "payments/" + paymentID + "/cancel"
-	log.Printf("[YOOMONEY] Отмена платежа: %s", paymentID)
+	log := logger().With(logging.KeyPaymentID, paymentID)
+	log.Info("отмена платежа")
 
 	// Генерируем новый ключ идемпотентности для отмены
 	idempotenceKey := uuid.New().String()
 
 	req, err := http.NewRequest("POST", url, nil)
 	if err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка создания запроса: %v", err)
+		log.Error("ошибка создания запроса", logging.KeyError, err)
 		return fmt.Errorf("ошибка создания запроса: %w", err)
 	}
 
@@ -291,17 +328,16 @@ func (c *YooMoneyClient) CancelPayment(paymentID string) error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		log.Printf("[YOOMONEY] ❌ Ошибка отправки запроса: %v", err)
+		log.Error("ошибка отправки запроса", logging.KeyError, err)
 		return fmt.Errorf("ошибка отправки запроса: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		log.Printf("[YOOMONEY] ❌ Ошибка API при отмене: статус %d, тело: %s", resp.StatusCode, string(body))
+		log.Error("ошибка API при отмене", "status", resp.StatusCode)
 		return fmt.Errorf("ошибка API: статус %d", resp.StatusCode)
 	}
 
-	log.Printf("[YOOMONEY] ✅ Платеж %s отменен", paymentID)
+	log.Info("платеж отменен")
 	return nil
 }
@@ -0,0 +1,343 @@
+package reporting
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"AIGenerator/internal/logging"
+)
+
+// Уровни событий, от менее к более серьезным
+const (
+	LevelWarning = "warning"
+	LevelError   = "error"
+	LevelFatal   = "fatal"
+)
+
+const (
+	// flushInterval как часто накопленные события отправляются
+	flushInterval = 10 * time.Second
+	// maxBatch после стольких событий пачка отправляется, не дожидаясь flushInterval
+	maxBatch = 20
+	// queueSize сколько событий ждут отправки; лишние отбрасываются
+	queueSize = 200
+	// sendTimeout предельное время отправки одной пачки
+	sendTimeout = 10 * time.Second
+)
+
+var levelOrder = map[string]int{LevelWarning: 1, LevelError: 2, LevelFatal: 3}
+
+// Config настройки отправки ошибок
+type Config struct {
+	// DSN адрес проекта в Sentry или совместимом сервисе; пустой — отправка выключена
+	DSN string
+	// Environment окружение, например production или staging
+	Environment string
+	// MinLevel события ниже этого уровня не отправляются
+	MinLevel string
+}
+
+// DefaultConfig возвращает настройки по умолчанию: отправка выключена
+func DefaultConfig() Config {
+	return Config{Environment: "production", MinLevel: LevelError}
+}
+
+// Levels возвращает допустимые значения MinLevel
+func Levels() []string {
+	return []string{LevelWarning, LevelError, LevelFatal}
+}
+
+// Event одно событие. Содержимое сообщений пользователей и постов в события не попадает:
+// только операция, ID пользователя и текст ошибки.
+type Event struct {
+	Level string
+	// Operation что делал бот: generate, payment_credit, panic
+	Operation string
+	// Message текст ошибки; секреты и адреса почты вырезаются перед отправкой
+	Message string
+	// UserID пользователь, запрос которого не удался; 0 — без пользователя
+	UserID int64
+	// Tags дополнительные метки: payment_id, handler
+	Tags map[string]string
+	// Fingerprint группирует события; пустой — группировка по сообщению
+	Fingerprint []string
+	// Stack стек вызовов для паник
+	Stack string
+}
+
+// transport доставляет событие в сервис
+type transport interface {
+	send(ctx context.Context, event payload) error
+}
+
+// Reporter копит события в очереди и отправляет их пачками из одной горутины
+type Reporter struct {
+	environment string
+	minLevel    int
+	transport   transport
+
+	queue   chan Event
+	flushes chan chan struct{}
+	dropped atomic.Int64
+}
+
+// current активный Reporter; nil — события отбрасываются
+var current atomic.Pointer[Reporter]
+
+// Configure включает отправку ошибок. Без DSN отправка выключается.
+func Configure(config Config) error {
+	if config.DSN == "" {
+		current.Store(nil)
+		return nil
+	}
+	dsn, err := ParseDSN(config.DSN)
+	if err != nil {
+		return err
+	}
+	current.Store(newReporter(config, dsn))
+	return nil
+}
+
+func newReporter(config Config, transport transport) *Reporter {
+	r := &Reporter{
+		environment: config.Environment,
+		minLevel:    levelOrder[config.MinLevel],
+		transport:   transport,
+		queue:       make(chan Event, queueSize),
+		flushes:     make(chan chan struct{}),
+	}
+	go r.run()
+	return r
+}
+
+// Enabled сообщает, включена ли отправка
+func Enabled() bool {
+	return current.Load() != nil
+}
+
+// Capture ставит событие в очередь. Не блокирует: при переполненной очереди событие
+// отбрасывается.
+func Capture(event Event) {
+	r := current.Load()
+	if r == nil || levelOrder[event.Level] < r.minLevel {
+		return
+	}
+	select {
+	case r.queue <- event:
+	default:
+		if r.dropped.Add(1) == 1 {
+			log.Printf("[REPORTING] ⚠️ Очередь событий переполнена, события отбрасываются")
+		}
+	}
+}
+
+// Flush отправляет накопленные события и ждет отправки не дольше timeout.
+// Возвращает false, если не успел.
+func Flush(timeout time.Duration) bool {
+	r := current.Load()
+	if r == nil {
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	done := make(chan struct{})
+	select {
+	case r.flushes <- done:
+	case <-timer.C:
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// run собирает события в пачки и отправляет их
+func (r *Reporter) run() {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []Event
+	for {
+		select {
+		case event := <-r.queue:
+			batch = append(batch, event)
+			if len(batch) >= maxBatch {
+				r.send(batch)
+				batch = nil
+			}
+		case <-ticker.C:
+			r.send(batch)
+			batch = nil
+		case done := <-r.flushes:
+			for drained := false; !drained; {
+				select {
+				case event := <-r.queue:
+					batch = append(batch, event)
+				default:
+					drained = true
+				}
+			}
+			r.send(batch)
+			batch = nil
+			close(done)
+		}
+	}
+}
+
+// send отправляет пачку; ошибки отправки только пишутся в лог
+func (r *Reporter) send(batch []Event) {
+	if len(batch) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+
+	failed := 0
+	var lastErr error
+	for _, event := range batch {
+		if err := r.transport.send(ctx, r.payload(event)); err != nil {
+			failed++
+			lastErr = err
+		}
+	}
+	if failed > 0 {
+		log.Printf("[REPORTING] ⚠️ Не отправлено %d из %d событий: %v", failed, len(batch), lastErr)
+	}
+}
+
+// payload событие в формате Sentry
+type payload struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Platform    string            `json:"platform"`
+	Level       string            `json:"level"`
+	Logger      string            `json:"logger"`
+	Environment string            `json:"environment,omitempty"`
+	Message     map[string]string `json:"message"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	User        map[string]string `json:"user,omitempty"`
+	Fingerprint []string          `json:"fingerprint,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+func (r *Reporter) payload(event Event) payload {
+	p := payload{
+		EventID:     newEventID(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		Platform:    "go",
+		Level:       event.Level,
+		Logger:      event.Operation,
+		Environment: r.environment,
+		Message:     map[string]string{"formatted": logging.Redact(event.Message)},
+		Tags:        map[string]string{"operation": event.Operation},
+		Fingerprint: event.Fingerprint,
+	}
+	for key, value := range event.Tags {
+		p.Tags[key] = value
+	}
+	if event.UserID != 0 {
+		p.User = map[string]string{"id": strconv.FormatInt(event.UserID, 10)}
+	}
+	if event.Stack != "" {
+		p.Extra = map[string]string{"stack": event.Stack}
+	}
+	return p
+}
+
+// newEventID случайный идентификатор события: 32 шестнадцатеричных символа
+func newEventID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// DSN разобранный адрес проекта: https://<ключ>@<хост>/<проект>
+type DSN struct {
+	raw       string
+	publicKey string
+	endpoint  string
+	client    *http.Client
+}
+
+// ParseDSN разбирает DSN
+func ParseDSN(value string) (*DSN, error) {
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Host == "" || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return nil, fmt.Errorf("неверный DSN: ожидается https://ключ@хост/проект")
+	}
+	if parsed.User == nil || parsed.User.Username() == "" {
+		return nil, fmt.Errorf("в DSN нет ключа")
+	}
+
+	path := strings.Trim(parsed.Path, "/")
+	prefix, project := "", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		prefix, project = "/"+path[:i], path[i+1:]
+	}
+	if project == "" {
+		return nil, fmt.Errorf("в DSN нет номера проекта")
+	}
+
+	return &DSN{
+		raw:       value,
+		publicKey: parsed.User.Username(),
+		endpoint:  fmt.Sprintf("%s://%s%s/api/%s/envelope/", parsed.Scheme, parsed.Host, prefix, project),
+		client:    &http.Client{Timeout: sendTimeout},
+	}, nil
+}
+
+// send отправляет событие конвертом (envelope) Sentry
+func (d *DSN) send(ctx context.Context, event payload) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("ошибка сериализации события: %w", err)
+	}
+	header, _ := json.Marshal(map[string]string{
+		"event_id": event.EventID,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339Nano),
+		"dsn":      d.raw,
+	})
+	itemHeader, _ := json.Marshal(map[string]any{"type": "event", "length": len(body)})
+
+	var envelope bytes.Buffer
+	for _, part := range [][]byte{header, itemHeader, body} {
+		envelope.Write(part)
+		envelope.WriteByte('\n')
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.endpoint, &envelope)
+	if err != nil {
+		return fmt.Errorf("ошибка создания запроса: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", fmt.Sprintf("Sentry sentry_version=7, sentry_client=aigenerator/1.0, sentry_key=%s", d.publicKey))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("ошибка отправки события: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("сервис вернул статус %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package reporting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTransport запоминает отправленные события вместо сервиса
+type fakeTransport struct {
+	mu     sync.Mutex
+	events []payload
+	err    error
+}
+
+func (f *fakeTransport) send(ctx context.Context, event payload) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, event)
+	return f.err
+}
+
+func (f *fakeTransport) sent() []payload {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]payload(nil), f.events...)
+}
+
+// useTransport включает отправку через transport на время теста
+func useTransport(t *testing.T, config Config, transport transport) {
+	t.Helper()
+	saved := current.Load()
+	current.Store(newReporter(config, transport))
+	t.Cleanup(func() { current.Store(saved) })
+}
+
+func TestConfigureWithoutDSN(t *testing.T) {
+	saved := current.Load()
+	t.Cleanup(func() { current.Store(saved) })
+
+	if err := Configure(DefaultConfig()); err != nil {
+		t.Fatal(err)
+	}
+	if Enabled() {
+		t.Fatal("отправка включена без DSN")
+	}
+	// Без DSN события молча отбрасываются
+	Capture(Event{Level: LevelFatal, Operation: "panic", Message: "паника"})
+	if !Flush(time.Second) {
+		t.Error("Flush без отправки не завершился")
+	}
+
+	if err := Configure(Config{DSN: "не адрес"}); err == nil || Enabled() {
+		t.Errorf("неверный DSN принят: %v", err)
+	}
+}
+
+func TestCaptureFiltersByLevel(t *testing.T) {
+	tests := []struct {
+		minLevel string
+		want     []string
+	}{
+		{LevelWarning, []string{LevelWarning, LevelError, LevelFatal}},
+		{LevelError, []string{LevelError, LevelFatal}},
+		{LevelFatal, []string{LevelFatal}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.minLevel, func(t *testing.T) {
+			fake := &fakeTransport{}
+			useTransport(t, Config{MinLevel: tt.minLevel}, fake)
+
+			for _, level := range Levels() {
+				Capture(Event{Level: level, Operation: "generate", Message: level})
+			}
+			Flush(time.Second)
+
+			var got []string
+			for _, event := range fake.sent() {
+				got = append(got, event.Level)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("отправлены %v, ожидались %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlushSendsBatch(t *testing.T) {
+	fake := &fakeTransport{}
+	useTransport(t, Config{MinLevel: LevelError}, fake)
+
+	// Меньше maxBatch событий ждут flushInterval или Flush
+	for i := 0; i < maxBatch-1; i++ {
+		Capture(Event{Level: LevelError, Operation: "generate", Message: "таймаут"})
+	}
+	time.Sleep(50 * time.Millisecond)
+	if sent := len(fake.sent()); sent != 0 {
+		t.Fatalf("отправлено %d событий до Flush", sent)
+	}
+	if !Flush(time.Second) {
+		t.Fatal("Flush не успел")
+	}
+	if sent := len(fake.sent()); sent != maxBatch-1 {
+		t.Errorf("отправлено %d, ожидалось %d", sent, maxBatch-1)
+	}
+
+	// Полная пачка уходит сразу
+	for i := 0; i < maxBatch; i++ {
+		Capture(Event{Level: LevelError, Operation: "generate", Message: "таймаут"})
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(fake.sent()) < 2*maxBatch-1 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if sent := len(fake.sent()); sent != 2*maxBatch-1 {
+		t.Errorf("полная пачка не отправлена: %d", sent)
+	}
+}
+
+func TestSendErrorsDoNotStopReporter(t *testing.T) {
+	fake := &fakeTransport{err: errors.New("503")}
+	useTransport(t, Config{MinLevel: LevelError}, fake)
+
+	Capture(Event{Level: LevelError, Operation: "generate", Message: "первое"})
+	Flush(time.Second)
+	Capture(Event{Level: LevelError, Operation: "generate", Message: "второе"})
+	Flush(time.Second)
+	if sent := len(fake.sent()); sent != 2 {
+		t.Errorf("попыток отправки %d, ожидалось 2", sent)
+	}
+}
+
+func TestPayload(t *testing.T) {
+	fake := &fakeTransport{}
+	useTransport(t, Config{Environment: "staging", MinLevel: LevelError}, fake)
+
+	// Паника обработчика и сбой зачисления платежа — так их отправляет бот
+	Capture(Event{
+		Level:       LevelFatal,
+		Operation:   "panic",
+		Message:     "паника в обработчике callback: nil map",
+		UserID:      42,
+		Tags:        map[string]string{"handler": "callback", "stack_hash": "abc123"},
+		Fingerprint: []string{"panic", "abc123"},
+		Stack:       "goroutine 7 [running]:",
+	})
+	Capture(Event{
+		Level:     LevelError,
+		Operation: "payment_credit",
+		Message:   "ошибка записи для ivan.petrov@example.com",
+		UserID:    7,
+		Tags:      map[string]string{"payment_id": "pay-1"},
+	})
+	Flush(time.Second)
+
+	sent := fake.sent()
+	if len(sent) != 2 {
+		t.Fatalf("отправлено %d событий", len(sent))
+	}
+
+	panicEvent := sent[0]
+	if panicEvent.Level != LevelFatal || panicEvent.Logger != "panic" || panicEvent.Environment != "staging" ||
+		panicEvent.Platform != "go" || len(panicEvent.EventID) != 32 {
+		t.Errorf("событие паники: %+v", panicEvent)
+	}
+	if panicEvent.Tags["operation"] != "panic" || panicEvent.Tags["handler"] != "callback" || panicEvent.Tags["stack_hash"] != "abc123" {
+		t.Errorf("метки паники: %v", panicEvent.Tags)
+	}
+	if panicEvent.User["id"] != "42" || panicEvent.Extra["stack"] != "goroutine 7 [running]:" ||
+		strings.Join(panicEvent.Fingerprint, ",") != "panic,abc123" {
+		t.Errorf("пользователь %v, стек %v, группировка %v", panicEvent.User, panicEvent.Extra, panicEvent.Fingerprint)
+	}
+
+	creditEvent := sent[1]
+	if creditEvent.Tags["operation"] != "payment_credit" || creditEvent.Tags["payment_id"] != "pay-1" || creditEvent.User["id"] != "7" {
+		t.Errorf("событие платежа: %+v", creditEvent)
+	}
+	if message := creditEvent.Message["formatted"]; message != "ошибка записи для i***@example.com" {
+		t.Errorf("адрес почты не скрыт: %q", message)
+	}
+	if creditEvent.Extra != nil || creditEvent.Fingerprint != nil {
+		t.Errorf("лишние поля: %+v", creditEvent)
+	}
+	if sent[0].EventID == sent[1].EventID {
+		t.Error("одинаковые идентификаторы событий")
+	}
+}
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn          string
+		wantEndpoint string
+		wantErr      string
+	}{
+		{"https://abc@o1.ingest.sentry.io/42", "https://o1.ingest.sentry.io/api/42/envelope/", ""},
+		{"http://abc@localhost:9000/7", "http://localhost:9000/api/7/envelope/", ""},
+		{"https://abc@errors.example.com/sentry/3/", "https://errors.example.com/sentry/api/3/envelope/", ""},
+		{"ftp://abc@host/1", "", "неверный DSN"},
+		{"https:///1", "", "неверный DSN"},
+		{"https://host/1", "", "нет ключа"},
+		{"https://abc@host/", "", "нет номера проекта"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dsn, func(t *testing.T) {
+			dsn, err := ParseDSN(tt.dsn)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ошибка %v, ожидалась %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dsn.endpoint != tt.wantEndpoint || dsn.publicKey != "abc" {
+				t.Errorf("адрес %q, ключ %q", dsn.endpoint, dsn.publicKey)
+			}
+		})
+	}
+}
+
+func TestDSNSendsEnvelope(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		path     string
+		auth     string
+		envelope []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		path, auth = r.URL.Path, r.Header.Get("X-Sentry-Auth")
+		envelope = strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	dsn, err := ParseDSN(strings.Replace(server.URL, "http://", "http://public@", 1) + "/5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reporter := &Reporter{environment: "production"}
+	if err := dsn.send(context.Background(), reporter.payload(Event{Level: LevelError, Operation: "generate", Message: "таймаут"})); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if path != "/api/5/envelope/" || !strings.Contains(auth, "sentry_key=public") {
+		t.Errorf("путь %q, авторизация %q", path, auth)
+	}
+	// Конверт: заголовок, заголовок элемента и само событие
+	if len(envelope) != 3 {
+		t.Fatalf("строк в конверте %d", len(envelope))
+	}
+	var item struct {
+		Type   string `json:"type"`
+		Length int    `json:"length"`
+	}
+	var event payload
+	if err := json.Unmarshal([]byte(envelope[1]), &item); err != nil || item.Type != "event" || item.Length != len(envelope[2]) {
+		t.Errorf("заголовок элемента %q", envelope[1])
+	}
+	if err := json.Unmarshal([]byte(envelope[2]), &event); err != nil || event.Message["formatted"] != "таймаут" {
+		t.Errorf("событие %q", envelope[2])
+	}
+	if !strings.Contains(envelope[0], event.EventID) {
+		t.Errorf("заголовок %q без event_id", envelope[0])
+	}
+
+	// Ответ не 200 — ошибка отправки
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer failing.Close()
+	dsn, _ = ParseDSN(strings.Replace(failing.URL, "http://", "http://public@", 1) + "/5")
+	if err := dsn.send(context.Background(), event); err == nil || !strings.Contains(err.Error(), "429") {
+		t.Errorf("ошибка %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+// Package richtext собирает текст сообщений Telegram вместе с разметкой в виде сущностей
+// (MessageEntity) вместо Markdown. Разметка хранится отдельно от текста, поэтому звездочки
+// и скобки в заголовках статей и ответах модели остаются обычными символами и не ломают
+// сообщение. Смещения сущностей считаются в единицах UTF-16, как требует Telegram.
+package richtext
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Типы сущностей Telegram
+const (
+	EntityBold     = "bold"
+	EntityItalic   = "italic"
+	EntityCode     = "code"
+	EntityTextLink = "text_link"
+)
+
+// Text текст сообщения с сущностями разметки. Нулевое значение — пустой текст.
+type Text struct {
+	text     string
+	entities []tgbotapi.MessageEntity
+}
+
+// Plain текст без разметки
+func Plain(s string) Text {
+	return Text{text: s}
+}
+
+// Bold жирный текст
+func Bold(s string) Text {
+	return Plain(s).wrap(tgbotapi.MessageEntity{Type: EntityBold})
+}
+
+// Link текст label, ведущий на url
+func Link(label, url string) Text {
+	return Plain(label).wrap(tgbotapi.MessageEntity{Type: EntityTextLink, URL: url})
+}
+
+// Concat склеивает части в один текст
+func Concat(parts ...Text) Text {
+	return Text{}.Append(parts...)
+}
+
+// Append дописывает части в конец текста, сдвигая их сущности на длину текста перед ними
+func (t Text) Append(parts ...Text) Text {
+	result := Text{text: t.text, entities: slices.Clone(t.entities)}
+	for _, part := range parts {
+		shift := UTF16Len(result.text)
+		for _, entity := range part.entities {
+			entity.Offset += shift
+			result.entities = append(result.entities, entity)
+		}
+		result.text += part.text
+	}
+	return result
+}
+
+// wrap оборачивает весь текст сущностью entity; пустой текст не размечается
+func (t Text) wrap(entity tgbotapi.MessageEntity) Text {
+	if t.text == "" {
+		return t
+	}
+	entity.Offset, entity.Length = 0, UTF16Len(t.text)
+	return Text{text: t.text, entities: append([]tgbotapi.MessageEntity{entity}, t.entities...)}
+}
+
+// String текст без разметки
+func (t Text) String() string {
+	return t.text
+}
+
+// Entities сущности разметки в порядке начала; nil, если разметки нет
+func (t Text) Entities() []tgbotapi.MessageEntity {
+	if len(t.entities) == 0 {
+		return nil
+	}
+	return slices.Clone(t.entities)
+}
+
+// IsEmpty пуст ли текст
+func (t Text) IsEmpty() bool {
+	return t.text == ""
+}
+
+// Len длина текста в единицах UTF-16: так Telegram считает длину сообщения и подписи
+func (t Text) Len() int {
+	return UTF16Len(t.text)
+}
+
+// UTF16Len длина строки в единицах UTF-16: символы вне базовой плоскости (большинство
+// эмодзи) занимают две единицы, кириллица и латиница — одну
+func UTF16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeLen(r)
+	}
+	return n
+}
+
+// runeLen длина символа в UTF-16; неверный UTF-8 range заменяет на U+FFFD
+func runeLen(r rune) int {
+	if n := utf16.RuneLen(r); n > 0 {
+		return n
+	}
+	return 1
+}
+
+// Cut возвращает начало текста не длиннее limit единиц UTF-16, по возможности
+// по границе слова. Сущности обрезаются вместе с текстом.
+func (t Text) Cut(limit int) Text {
+	if t.Len() <= limit {
+		return t
+	}
+
+	end, units, lastSpace := len(t.text), 0, -1
+	for i, r := range t.text {
+		n := runeLen(r)
+		if units+n > limit {
+			end = i
+			// Предел пришелся на конец слова: оно помещается целиком
+			if unicode.IsSpace(r) {
+				lastSpace = i
+			}
+			break
+		}
+		if unicode.IsSpace(r) {
+			lastSpace = i
+		}
+		units += n
+	}
+	if lastSpace > 0 {
+		end = lastSpace
+	}
+
+	text := strings.TrimRightFunc(t.text[:end], unicode.IsSpace)
+	return Text{text: text, entities: clip(t.entities, UTF16Len(text))}
+}
+
+// Truncate сокращает текст до limit единиц UTF-16, обозначая обрезку многоточием
+func (t Text) Truncate(limit int) Text {
+	if t.Len() <= limit {
+		return t
+	}
+	return t.Cut(limit - 3).Append(Plain("..."))
+}
+
+// clip обрезает сущности по длине текста length
+func clip(entities []tgbotapi.MessageEntity, length int) []tgbotapi.MessageEntity {
+	var clipped []tgbotapi.MessageEntity
+	for _, entity := range entities {
+		if entity.Offset >= length {
+			continue
+		}
+		entity.Length = min(entity.Length, length-entity.Offset)
+		clipped = append(clipped, entity)
+	}
+	return clipped
+}
+
+// markers маркеры разметки модели и типы сущностей; ** проверяется раньше *
+var markers = []struct {
+	marker, entity string
+}{
+	{"**", EntityBold},
+	{"*", EntityBold},
+	{"__", EntityItalic},
+	{"_", EntityItalic},
+	{"`", EntityCode},
+}
+
+// Markers превращает разметку из ответа модели (*жирный*, **жирный**, _курсив_, `код`)
+// в сущности. Маркер считается разметкой, только если у него есть пара в том же абзаце,
+// а текст между ними не пустой и не начинается и не заканчивается пробелом; _ к тому же
+// должен стоять на границе слова, чтобы не ломать snake_case. Остальные маркеры
+// остаются в тексте как есть. Внутри кода разметка не разбирается.
+func Markers(s string) Text {
+	var result Text
+	plain := 0
+	for i := 0; i < len(s); {
+		marker, entity, content, ok := markerAt(s, i)
+		if !ok {
+			i++
+			continue
+		}
+		inner := Plain(content)
+		if entity != EntityCode {
+			inner = Markers(content)
+		}
+		result = result.Append(Plain(s[plain:i]), inner.wrap(tgbotapi.MessageEntity{Type: entity}))
+		i += 2*len(marker) + len(content)
+		plain = i
+	}
+	return result.Append(Plain(s[plain:]))
+}
+
+// markerAt проверяет, начинается ли в позиции i строки s размеченный фрагмент
+func markerAt(s string, i int) (marker, entity, content string, ok bool) {
+	for _, candidate := range markers {
+		if !strings.HasPrefix(s[i:], candidate.marker) {
+			continue
+		}
+		marker = candidate.marker
+		rest := s[i+len(marker):]
+		end := strings.Index(rest, marker)
+		if end <= 0 {
+			return "", "", "", false
+		}
+		content = rest[:end]
+		if strings.Contains(content, "\n\n") || strings.TrimSpace(content) != content {
+			return "", "", "", false
+		}
+		if marker[0] == '_' && (wordAt(s[:i], true) || wordAt(rest[end+len(marker):], false)) {
+			return "", "", "", false
+		}
+		return marker, candidate.entity, content, true
+	}
+	return "", "", "", false
+}
+
+// wordAt стоит ли буква или цифра в конце (last) или в начале строки s
+func wordAt(s string, last bool) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	if last {
+		r, _ = utf8.DecodeLastRuneInString(s)
+	}
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// verbPattern глагол форматирования fmt, как в каталогах сообщений
+var verbPattern = regexp.MustCompile(`^%[-+# 0]*[0-9]*(?:\.[0-9]+)?[a-zA-Z%]`)
+
+// Sprintf собирает текст по шаблону из каталога сообщений. Разметка шаблона (*жирный*
+// и [текст](ссылка)) становится сущностями, а аргументы подставляются обычным текстом:
+// символы разметки в них не разбираются. Ссылку задает аргумент внутри (...).
+func Sprintf(format string, args ...any) Text {
+	p := &templateParser{format: format, args: args}
+	return p.parse("")
+}
+
+// templateParser разбирает шаблон сообщения, по очереди подставляя аргументы
+type templateParser struct {
+	format string
+	pos    int
+	args   []any
+	arg    int
+}
+
+// parse разбирает шаблон до строки until или до конца шаблона
+func (p *templateParser) parse(until string) Text {
+	var result Text
+	for p.pos < len(p.format) {
+		rest := p.format[p.pos:]
+		switch {
+		case until != "" && strings.HasPrefix(rest, until):
+			p.pos += len(until)
+			return result
+		case rest[0] == '%':
+			result = result.Append(Plain(p.verb()))
+		case rest[0] == '*':
+			p.pos++
+			result = result.Append(p.parse("*").wrap(tgbotapi.MessageEntity{Type: EntityBold}))
+		case rest[0] == '[' && strings.Contains(rest, "]("):
+			p.pos++
+			label := p.parse("](")
+			url := p.parse(")")
+			result = result.Append(label.wrap(tgbotapi.MessageEntity{Type: EntityTextLink, URL: strings.TrimSpace(url.String())}))
+		default:
+			next := strings.IndexAny(rest[1:], "%*[]()")
+			if next < 0 {
+				next = len(rest) - 1
+			}
+			result = result.Append(Plain(rest[:next+1]))
+			p.pos += next + 1
+		}
+	}
+	return result
+}
+
+// verb подставляет следующий аргумент по глаголу в текущей позиции шаблона
+func (p *templateParser) verb() string {
+	verb := verbPattern.FindString(p.format[p.pos:])
+	if verb == "" {
+		p.pos++
+		return "%"
+	}
+	p.pos += len(verb)
+	if verb == "%%" {
+		return "%"
+	}
+	if p.arg >= len(p.args) {
+		return "%!" + verb[len(verb)-1:] + "(MISSING)"
+	}
+	p.arg++
+	return fmt.Sprintf(verb, p.args[p.arg-1])
+}
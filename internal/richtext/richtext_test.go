@@ -0,0 +1,197 @@
+package richtext
+
+import (
+	"slices"
+	"testing"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// bold, italic и code сущности с заданными смещением и длиной
+func bold(offset, length int) tgbotapi.MessageEntity {
+	return tgbotapi.MessageEntity{Type: EntityBold, Offset: offset, Length: length}
+}
+
+func italic(offset, length int) tgbotapi.MessageEntity {
+	return tgbotapi.MessageEntity{Type: EntityItalic, Offset: offset, Length: length}
+}
+
+func code(offset, length int) tgbotapi.MessageEntity {
+	return tgbotapi.MessageEntity{Type: EntityCode, Offset: offset, Length: length}
+}
+
+func link(offset, length int, url string) tgbotapi.MessageEntity {
+	return tgbotapi.MessageEntity{Type: EntityTextLink, Offset: offset, Length: length, URL: url}
+}
+
+// covered текст, который Telegram выделит сущностью: смещения в единицах UTF-16
+func covered(s string, entity tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(s))
+	if entity.Offset < 0 || entity.Offset+entity.Length > len(units) {
+		return "<за пределами текста>"
+	}
+	return string(utf16.Decode(units[entity.Offset : entity.Offset+entity.Length]))
+}
+
+// checkText сравнивает текст и сущности с ожидаемыми
+func checkText(t *testing.T, got Text, wantText string, wantEntities []tgbotapi.MessageEntity) {
+	t.Helper()
+	if got.String() != wantText {
+		t.Errorf("текст %q, ожидался %q", got.String(), wantText)
+	}
+	if !slices.Equal(got.Entities(), wantEntities) {
+		t.Errorf("сущности %+v, ожидались %+v", got.Entities(), wantEntities)
+	}
+}
+
+func TestUTF16Len(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"Привет", 6},
+		{"🚀", 2},
+		// Эмодзи с модификатором и флаг — по две суррогатные пары
+		{"👍🏽", 4},
+		{"🇷🇺", 4},
+		{"é", 2},
+		// Неверный UTF-8 Telegram получит как U+FFFD
+		{"\xff", 1},
+	}
+	for _, tt := range tests {
+		if got := UTF16Len(tt.s); got != tt.want {
+			t.Errorf("UTF16Len(%q) = %d, ожидалось %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestMarkers(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+		wantEntities   []tgbotapi.MessageEntity
+	}{
+		{"кириллица", "Курс *рубля* вырос", "Курс рубля вырос", []tgbotapi.MessageEntity{bold(5, 5)}},
+		// Байтовое, символьное и UTF-16 смещения расходятся
+		{"эмодзи перед разметкой", "🚀 *Ракета* 🚀 _взлетела_", "🚀 Ракета 🚀 взлетела",
+			[]tgbotapi.MessageEntity{bold(3, 6), italic(13, 8)}},
+		{"суррогатные пары внутри", "👍🏽 **🇷🇺 Россия**", "👍🏽 🇷🇺 Россия", []tgbotapi.MessageEntity{bold(5, 11)}},
+		{"вложенная разметка", "*жирный _курсив_*", "жирный курсив", []tgbotapi.MessageEntity{bold(0, 13), italic(7, 6)}},
+		{"код без разметки внутри", "**Жирный** и `код *не* разметка`", "Жирный и код *не* разметка",
+			[]tgbotapi.MessageEntity{bold(0, 6), code(9, 17)}},
+		{"snake_case", "поле snake_case_name", "поле snake_case_name", nil},
+		{"маркер без пары", "5 * 3 = 15", "5 * 3 = 15", nil},
+		{"пробел у маркера", "* не жирный *", "* не жирный *", nil},
+		{"пустая разметка", "**", "**", nil},
+		{"через абзац", "*начало\n\nконец*", "*начало\n\nконец*", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkText(t, Markers(tt.in), tt.want, tt.wantEntities)
+		})
+	}
+
+	// Смещения указывают ровно на размеченные слова
+	text := Markers("🚀 *Ракета* 🚀 _взлетела_")
+	if got := []string{covered(text.String(), text.Entities()[0]), covered(text.String(), text.Entities()[1])}; !slices.Equal(got, []string{"Ракета", "взлетела"}) {
+		t.Errorf("выделено %q", got)
+	}
+}
+
+func TestConcat(t *testing.T) {
+	text := Concat(Bold("😀 Итог"), Plain(" и "), Link("ссылка", "https://example.com/а"), Bold(""))
+	checkText(t, text, "😀 Итог и ссылка", []tgbotapi.MessageEntity{bold(0, 7), link(10, 6, "https://example.com/а")})
+
+	// Append не меняет исходный текст
+	base := Bold("заголовок")
+	base.Append(Bold("хвост"))
+	checkText(t, base, "заголовок", []tgbotapi.MessageEntity{bold(0, 9)})
+	if !(Text{}).IsEmpty() || (Text{}).Entities() != nil {
+		t.Error("нулевой текст")
+	}
+}
+
+func TestCut(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         Text
+		limit        int
+		want         string
+		wantEntities []tgbotapi.MessageEntity
+	}{
+		{"короче предела", Bold("Привет"), 6, "Привет", []tgbotapi.MessageEntity{bold(0, 6)}},
+		{"по границе слова", Plain("Привет большой мир"), 10, "Привет", nil},
+		// Предел пришелся ровно на конец слова: слово остается
+		{"конец слова на пределе", Plain("Привет мир"), 6, "Привет", nil},
+		{"слово без пробелов", Plain("Приветмир"), 4, "Прив", nil},
+		// Эмодзи из двух единиц не разрезается пополам
+		{"эмодзи на пределе", Plain("ab🚀"), 3, "ab", nil},
+		{"эмодзи помещается", Plain("ab🚀c"), 4, "ab🚀", nil},
+		{"сущность обрезается", Concat(Plain("Курс "), Bold("рубля вырос сильно")), 12, "Курс рубля",
+			[]tgbotapi.MessageEntity{bold(5, 5)}},
+		{"сущность за пределом", Concat(Plain("Курс рубля "), Bold("вырос")), 12, "Курс рубля", nil},
+		{"эмодзи в сущности", Concat(Bold("🇷🇺 Россия"), Plain(" и мир")), 11, "🇷🇺 Россия",
+			[]tgbotapi.MessageEntity{bold(0, 11)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.text.Cut(tt.limit)
+			checkText(t, got, tt.want, tt.wantEntities)
+			if got.Len() > tt.limit {
+				t.Errorf("длина %d больше предела %d", got.Len(), tt.limit)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         Text
+		limit        int
+		want         string
+		wantEntities []tgbotapi.MessageEntity
+	}{
+		{"помещается", Plain("Привет мир"), 10, "Привет мир", nil},
+		{"обрезка", Plain("Привет мир"), 9, "Привет...", nil},
+		// Многоточие не входит в сущность
+		{"обрезка сущности", Bold("Очень длинный заголовок"), 10, "Очень...", []tgbotapi.MessageEntity{bold(0, 5)}},
+		{"эмодзи", Plain("🚀🚀🚀🚀"), 7, "🚀🚀...", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.text.Truncate(tt.limit)
+			checkText(t, got, tt.want, tt.wantEntities)
+			if got.Len() > tt.limit {
+				t.Errorf("длина %d больше предела %d", got.Len(), tt.limit)
+			}
+		})
+	}
+}
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		name, format string
+		args         []any
+		want         string
+		wantEntities []tgbotapi.MessageEntity
+	}{
+		{"жирный", "*Баланс:* %d генераций", []any{5}, "Баланс: 5 генераций", []tgbotapi.MessageEntity{bold(0, 7)}},
+		{"эмодзи перед жирным", "🎉 *%d* генераций", []any{10}, "🎉 10 генераций", []tgbotapi.MessageEntity{bold(3, 2)}},
+		// Разметка в аргументах остается текстом
+		{"разметка в аргументе", "Тема: %s", []any{"*не* [жирный](https://x.ru)"}, "Тема: *не* [жирный](https://x.ru)", nil},
+		{"ссылка из аргументов", "📰 [%s](%s)", []any{"Статья 🚀", "https://example.com/a"}, "📰 Статья 🚀",
+			[]tgbotapi.MessageEntity{link(3, 9, "https://example.com/a")}},
+		{"скобки без ссылки", "Цена (руб.) [важно]", nil, "Цена (руб.) [важно]", nil},
+		{"процент", "Скидка 50%% и 100 %", nil, "Скидка 50% и 100 %", nil},
+		{"ширина и точность", "%5.1f|%-3d|", []any{4.25, 7}, "  4.2|7  |", nil},
+		{"нет аргумента", "Всего: %d", nil, "Всего: %!d(MISSING)", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkText(t, Sprintf(tt.format, tt.args...), tt.want, tt.wantEntities)
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package selftest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Режимы самопроверки при запуске
+const (
+	// ModeOff самопроверка при запуске не выполняется, /selftest работает
+	ModeOff = "off"
+	// ModeWarn о непройденных проверках только сообщается
+	ModeWarn = "warn"
+	// ModeStrict бот не запускается, если не пройдена критичная проверка
+	ModeStrict = "strict"
+)
+
+// defaultTimeout общее время на все проверки
+const defaultTimeout = 20 * time.Second
+
+// Config настройки самопроверки
+type Config struct {
+	Mode string
+	// Timeout общее время на все проверки: они выполняются параллельно,
+	// не успевшие считаются непройденными
+	Timeout time.Duration
+}
+
+// DefaultConfig возвращает настройки по умолчанию
+func DefaultConfig() Config {
+	return Config{Mode: ModeWarn, Timeout: defaultTimeout}
+}
+
+// Modes возвращает допустимые режимы
+func Modes() []string {
+	return []string{ModeOff, ModeWarn, ModeStrict}
+}
+
+// Check проверяет одну зависимость. detail попадает в отчет и при успехе
+type Check func(ctx context.Context) (detail string, err error)
+
+// Result результат одной проверки
+type Result struct {
+	Name     string
+	Critical bool
+	OK       bool
+	Detail   string
+	Error    string
+	Latency  time.Duration
+}
+
+// Report результаты самопроверки
+type Report struct {
+	Results []Result
+	// Duration сколько заняла самопроверка целиком
+	Duration time.Duration
+}
+
+type namedCheck struct {
+	name     string
+	critical bool
+	check    Check
+}
+
+// Suite набор проверок внешних зависимостей
+type Suite struct {
+	timeout time.Duration
+	checks  []namedCheck
+}
+
+// NewSuite создает пустой набор с общим ограничением времени timeout
+func NewSuite(timeout time.Duration) *Suite {
+	return &Suite{timeout: timeout}
+}
+
+// Add добавляет проверку. Непройденная критичная проверка в строгом режиме
+// не дает боту запуститься.
+func (s *Suite) Add(name string, critical bool, check Check) {
+	s.checks = append(s.checks, namedCheck{name, critical, check})
+}
+
+// Run выполняет проверки параллельно. Проверка, не уложившаяся в общее время,
+// считается непройденной, даже если она не реагирует на отмену контекста.
+func (s *Suite) Run(ctx context.Context) Report {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	results := make([]Result, len(s.checks))
+	done := make([]bool, len(s.checks))
+
+	var wg sync.WaitGroup
+	for i, check := range s.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkStarted := time.Now()
+			detail, err := check.check(ctx)
+			result := Result{Name: check.name, Critical: check.critical, OK: err == nil, Detail: detail,
+				Latency: time.Since(checkStarted)}
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			results[i], done[i] = result, true
+		}()
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-ctx.Done():
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	report := Report{Results: make([]Result, len(s.checks)), Duration: time.Since(started)}
+	for i, check := range s.checks {
+		if done[i] {
+			report.Results[i] = results[i]
+			continue
+		}
+		report.Results[i] = Result{Name: check.name, Critical: check.critical, Latency: report.Duration,
+			Error: fmt.Sprintf("не уложилась в %v", s.timeout)}
+	}
+	return report
+}
+
+// OK сообщает, что пройдены все проверки
+func (r Report) OK() bool {
+	for _, result := range r.Results {
+		if !result.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// CriticalFailed возвращает имена непройденных критичных проверок
+func (r Report) CriticalFailed() []string {
+	var names []string
+	for _, result := range r.Results {
+		if result.Critical && !result.OK {
+			names = append(names, result.Name)
+		}
+	}
+	return names
+}
+
+// Format оформляет отчет: по строке на проверку с задержкой
+func (r Report) Format() string {
+	var text strings.Builder
+	passed := 0
+	for _, result := range r.Results {
+		if result.OK {
+			passed++
+		}
+	}
+	fmt.Fprintf(&text, "🧪 Самопроверка: пройдено %d из %d за %v\n", passed, len(r.Results), r.Duration.Round(time.Millisecond))
+
+	for _, result := range r.Results {
+		icon, detail := "✅", result.Detail
+		if !result.OK {
+			icon, detail = "❌", result.Error
+			if !result.Critical {
+				icon = "⚠️"
+			}
+		}
+		fmt.Fprintf(&text, "%s %s (%v)", icon, result.Name, result.Latency.Round(time.Millisecond))
+		if detail != "" {
+			fmt.Fprintf(&text, ": %s", detail)
+		}
+		text.WriteString("\n")
+	}
+	return strings.TrimRight(text.String(), "\n")
+}
@@ -0,0 +1,146 @@
+package selftest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fixed проверка-заглушка, которая отвечает через delay
+func fixed(detail string, err error, delay time.Duration) Check {
+	return func(ctx context.Context) (string, error) {
+		time.Sleep(delay)
+		return detail, err
+	}
+}
+
+func TestRunReports(t *testing.T) {
+	tests := []struct {
+		name         string
+		checks       []namedCheck
+		wantOK       bool
+		wantCritical []string
+	}{
+		{"пустой набор", nil, true, nil},
+		{
+			name: "все пройдены",
+			checks: []namedCheck{
+				{"ai", true, fixed("", nil, 0)},
+				{"yookassa", false, fixed("не настроена", nil, 0)},
+			},
+			wantOK: true,
+		},
+		{
+			name: "некритичная не пройдена",
+			checks: []namedCheck{
+				{"ai", true, fixed("", nil, 0)},
+				{"yookassa", false, fixed("", errors.New("401"), 0)},
+			},
+		},
+		{
+			name: "критичные не пройдены",
+			checks: []namedCheck{
+				{"ai", true, fixed("", errors.New("неверный ключ"), 0)},
+				{"yookassa", false, fixed("", nil, 0)},
+				{"news", true, fixed("0 из 2 источников", errors.New("403"), 0)},
+			},
+			wantCritical: []string{"ai", "news"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suite := NewSuite(time.Second)
+			for _, check := range tt.checks {
+				suite.Add(check.name, check.critical, check.check)
+			}
+			report := suite.Run(context.Background())
+
+			if report.OK() != tt.wantOK {
+				t.Errorf("OK = %t", report.OK())
+			}
+			if got := strings.Join(report.CriticalFailed(), ","); got != strings.Join(tt.wantCritical, ",") {
+				t.Errorf("критичные %q, ожидались %q", got, strings.Join(tt.wantCritical, ","))
+			}
+			// Результаты идут в порядке добавления проверок
+			for i, result := range report.Results {
+				if result.Name != tt.checks[i].name || result.Critical != tt.checks[i].critical {
+					t.Errorf("результат %d: %+v", i, result)
+				}
+			}
+		})
+	}
+}
+
+func TestRunChecksConcurrently(t *testing.T) {
+	suite := NewSuite(time.Second)
+	for _, name := range []string{"ai", "yookassa", "news", "database"} {
+		suite.Add(name, true, fixed("", nil, 100*time.Millisecond))
+	}
+
+	report := suite.Run(context.Background())
+	if !report.OK() {
+		t.Fatalf("отчет %+v", report)
+	}
+	if report.Duration > 250*time.Millisecond {
+		t.Errorf("проверки выполнены последовательно: %v", report.Duration)
+	}
+	for _, result := range report.Results {
+		if result.Latency < 100*time.Millisecond {
+			t.Errorf("задержка %s: %v", result.Name, result.Latency)
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	suite := NewSuite(50 * time.Millisecond)
+	suite.Add("database", true, fixed("", nil, 0))
+	// Проверка не реагирует на отмену контекста и все равно не задерживает запуск
+	suite.Add("news", true, fixed("", nil, time.Minute))
+	suite.Add("ai", true, func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+
+	started := time.Now()
+	report := suite.Run(context.Background())
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Fatalf("самопроверка шла %v", elapsed)
+	}
+
+	if !report.Results[0].OK {
+		t.Errorf("быстрая проверка: %+v", report.Results[0])
+	}
+	if news := report.Results[1]; news.OK || news.Error != "не уложилась в 50ms" {
+		t.Errorf("зависшая проверка: %+v", news)
+	}
+	if ai := report.Results[2]; ai.OK || ai.Error == "" {
+		t.Errorf("отмененная проверка: %+v", ai)
+	}
+	if got := strings.Join(report.CriticalFailed(), ","); got != "news,ai" {
+		t.Errorf("критичные %q", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	report := Report{
+		Duration: 1234 * time.Millisecond,
+		Results: []Result{
+			{Name: "ai", Critical: true, OK: true, Latency: 800 * time.Millisecond},
+			{Name: "yookassa", OK: false, Error: "401", Latency: 120 * time.Millisecond},
+			{Name: "news", Critical: true, OK: false, Error: "403", Latency: 1234 * time.Millisecond},
+			{Name: "database", Critical: true, OK: true, Detail: "запись и чтение", Latency: 2 * time.Millisecond},
+		},
+	}
+
+	want := `🧪 Самопроверка: пройдено 2 из 4 за 1.234s
+✅ ai (800ms)
+⚠️ yookassa (120ms): 401
+❌ news (1.234s): 403
+✅ database (2ms): запись и чтение`
+	if got := report.Format(); got != want {
+		t.Errorf("отчет:\n%s\n\nожидался:\n%s", got, want)
+	}
+}
@@ -0,0 +1,215 @@
+package testutil
+
+import (
+	"sync"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Sent одно исходящее сообщение или правка, записанные FakeTelegram
+type Sent struct {
+	// Method send или request — каким методом клиента передано
+	Method string
+	// ChatID чат получателя; 0, если его не удалось определить
+	ChatID int64
+	// MessageID номер сообщения, выданный при отправке, или номер правленого сообщения
+	MessageID int
+	// Text текст или подпись
+	Text string
+	// Keyboard клавиатура под сообщением; nil, если ее нет
+	Keyboard *tgbotapi.InlineKeyboardMarkup
+	// Config исходный объект запроса
+	Config tgbotapi.Chattable
+}
+
+// FakeTelegram заглушка клиента Telegram: записывает исходящие сообщения и отдает
+// обновления, переданные через Feed. Безопасна для одновременного использования.
+// Реализует bot.TelegramSender.
+type FakeTelegram struct {
+	mu      sync.Mutex
+	sent    []Sent
+	lastID  int
+	failFor map[int64]error
+	// failNext ошибки следующих отправок в чат, по одной на отправку
+	failNext map[int64][]error
+
+	updates  chan tgbotapi.Update
+	stopOnce sync.Once
+	// lastUpdateID номер последнего обновления, переданного через Feed
+	lastUpdateID int
+	// offset смещение, с которым бот запросил обновления
+	offset int
+}
+
+// NewFakeTelegram создает заглушку с буфером на bufferSize обновлений
+func NewFakeTelegram(bufferSize int) *FakeTelegram {
+	return &FakeTelegram{
+		failFor:  make(map[int64]error),
+		failNext: make(map[int64][]error),
+		updates:  make(chan tgbotapi.Update, bufferSize),
+	}
+}
+
+// FailFor заставляет отправку в чат chatID возвращать err; nil снимает ошибку
+func (f *FakeTelegram) FailFor(chatID int64, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.failFor, chatID)
+		return
+	}
+	f.failFor[chatID] = err
+}
+
+// FailNext заставляет следующие отправки в чат chatID по очереди возвращать errs:
+// так имитируются сбои, которые проходят при повторе
+func (f *FakeTelegram) FailNext(chatID int64, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failNext[chatID] = append(f.failNext[chatID], errs...)
+}
+
+// Send записывает сообщение и возвращает его с новым номером
+func (f *FakeTelegram) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record := describe(c)
+	record.Method = "send"
+	if errs := f.failNext[record.ChatID]; len(errs) > 0 {
+		f.failNext[record.ChatID] = errs[1:]
+		return tgbotapi.Message{}, errs[0]
+	}
+	if err := f.failFor[record.ChatID]; err != nil {
+		return tgbotapi.Message{}, err
+	}
+	if record.MessageID == 0 {
+		f.lastID++
+		record.MessageID = f.lastID
+	}
+	f.sent = append(f.sent, record)
+
+	return tgbotapi.Message{
+		MessageID: record.MessageID,
+		Chat:      &tgbotapi.Chat{ID: record.ChatID},
+		Text:      record.Text,
+	}, nil
+}
+
+// Request записывает запрос и отвечает успехом
+func (f *FakeTelegram) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	record := describe(c)
+	record.Method = "request"
+	if err := f.failFor[record.ChatID]; err != nil {
+		return nil, err
+	}
+	f.sent = append(f.sent, record)
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+// GetUpdatesChan запоминает смещение и возвращает канал обновлений, переданных через Feed
+func (f *FakeTelegram) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.offset = config.Offset
+	return f.updates
+}
+
+// Offset возвращает смещение, с которым бот запросил обновления
+func (f *FakeTelegram) Offset() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.offset
+}
+
+// StopReceivingUpdates закрывает канал обновлений
+func (f *FakeTelegram) StopReceivingUpdates() {
+	f.stopOnce.Do(func() { close(f.updates) })
+}
+
+// Feed передает боту обновление. Обновлению без номера присваивается следующий номер;
+// обновление с уже выданным номером имитирует повторную доставку Telegram.
+func (f *FakeTelegram) Feed(update tgbotapi.Update) {
+	f.mu.Lock()
+	if update.UpdateID == 0 {
+		f.lastUpdateID++
+		update.UpdateID = f.lastUpdateID
+	}
+	f.lastUpdateID = max(f.lastUpdateID, update.UpdateID)
+	f.mu.Unlock()
+	f.updates <- update
+}
+
+// Sent возвращает копию всех записанных сообщений по порядку
+func (f *FakeTelegram) Sent() []Sent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Sent(nil), f.sent...)
+}
+
+// SentTo возвращает сообщения и правки в чат chatID
+func (f *FakeTelegram) SentTo(chatID int64) []Sent {
+	var result []Sent
+	for _, sent := range f.Sent() {
+		if sent.ChatID == chatID {
+			result = append(result, sent)
+		}
+	}
+	return result
+}
+
+// LastText возвращает текст последнего сообщения или правки в чате chatID
+func (f *FakeTelegram) LastText(chatID int64) string {
+	sent := f.SentTo(chatID)
+	for i := len(sent) - 1; i >= 0; i-- {
+		if sent[i].Text != "" {
+			return sent[i].Text
+		}
+	}
+	return ""
+}
+
+// Reset забывает записанные сообщения
+func (f *FakeTelegram) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = nil
+}
+
+// describe извлекает из запроса чат, текст и клавиатуру
+func describe(c tgbotapi.Chattable) Sent {
+	record := Sent{Config: c}
+	switch config := c.(type) {
+	case tgbotapi.MessageConfig:
+		record.ChatID, record.Text = config.ChatID, config.Text
+		record.Keyboard = inlineKeyboard(config.ReplyMarkup)
+	case tgbotapi.PhotoConfig:
+		record.ChatID, record.Text = config.ChatID, config.Caption
+		record.Keyboard = inlineKeyboard(config.ReplyMarkup)
+	case tgbotapi.DocumentConfig:
+		record.ChatID, record.Text = config.ChatID, config.Caption
+	case tgbotapi.EditMessageTextConfig:
+		record.ChatID, record.MessageID, record.Text = config.ChatID, config.MessageID, config.Text
+		record.Keyboard = config.ReplyMarkup
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		record.ChatID, record.MessageID = config.ChatID, config.MessageID
+		record.Keyboard = config.ReplyMarkup
+	case tgbotapi.DeleteMessageConfig:
+		record.ChatID, record.MessageID = config.ChatID, config.MessageID
+	}
+	return record
+}
+
+// inlineKeyboard возвращает встроенную клавиатуру из reply_markup
+func inlineKeyboard(markup any) *tgbotapi.InlineKeyboardMarkup {
+	switch keyboard := markup.(type) {
+	case tgbotapi.InlineKeyboardMarkup:
+		return &keyboard
+	case *tgbotapi.InlineKeyboardMarkup:
+		return keyboard
+	}
+	return nil
+}
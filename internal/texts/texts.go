@@ -0,0 +1,173 @@
+package texts
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"AIGenerator/internal/i18n"
+)
+
+//go:embed templates/*.tmpl
+var defaultTemplates embed.FS
+
+// Имена текстов; файл шаблона — <имя>_<код языка>.tmpl
+const (
+	Start = "start"
+	Help  = "help"
+)
+
+var names = []string{Start, Help}
+
+// Config настройки текстов бота
+type Config struct {
+	// Dir каталог, файлы которого переопределяют встроенные шаблоны; пустой — только встроенные
+	Dir string
+}
+
+// Package пакет генераций в тарифах
+type Package struct {
+	Count int
+	Price int
+}
+
+// Data значения, доступные в шаблонах
+type Data struct {
+	// FreeGenerations сколько бесплатных генераций получает новый пользователь
+	FreeGenerations int
+	// Packages пакеты генераций по возрастанию размера
+	Packages []Package
+	// BlockedCategories категории тем, которые отклоняют правила модерации
+	BlockedCategories []string
+}
+
+// sampleData данные для проверки шаблонов при загрузке: заполнены все поля, чтобы
+// выполнились все ветки с условиями и циклами
+var sampleData = Data{
+	FreeGenerations:   10,
+	Packages:          []Package{{Count: 10, Price: 99}},
+	BlockedCategories: []string{"18+"},
+}
+
+// funcs функции, доступные в шаблонах
+var funcs = template.FuncMap{
+	"join": strings.Join,
+}
+
+var (
+	config    Config
+	templates map[string]*template.Template
+	mu        sync.RWMutex
+)
+
+func init() {
+	if err := Reload(); err != nil {
+		log.Printf("[TEXTS] ❌ Ошибка загрузки текстов: %v", err)
+	}
+}
+
+// Configure запоминает настройки и загружает шаблоны. Ошибка означает, что шаблон
+// не разбирается или ссылается на неизвестное значение; запускать бота с ним нельзя.
+func Configure(c Config) error {
+	mu.Lock()
+	config = c
+	mu.Unlock()
+	return Reload()
+}
+
+// Reload загружает шаблоны заново. Каждый шаблон сразу заполняется проверочными данными,
+// так что опечатка в имени значения обнаруживается здесь, а не при ответе пользователю.
+// При ошибке остаются ранее загруженные шаблоны.
+func Reload() error {
+	mu.RLock()
+	dir := config.Dir
+	mu.RUnlock()
+
+	loaded := make(map[string]*template.Template)
+	var errs []error
+	for _, name := range names {
+		for _, language := range i18n.Languages() {
+			key := name + "_" + language.Code
+			text, source, err := readTemplate(dir, key)
+			if errors.Is(err, os.ErrNotExist) && language.Code != i18n.DefaultLanguage {
+				// Без перевода показывается текст на языке по умолчанию
+				continue
+			}
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			tmpl, err := template.New(key).Funcs(funcs).Option("missingkey=error").Parse(text)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("ошибка разбора шаблона %s (%s): %w", key, source, err))
+				continue
+			}
+			for _, data := range []Data{sampleData, {}} {
+				if err := tmpl.Execute(&bytes.Buffer{}, data); err != nil {
+					errs = append(errs, fmt.Errorf("ошибка проверки шаблона %s (%s): %w", key, source, err))
+					break
+				}
+			}
+			loaded[key] = tmpl
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	mu.Lock()
+	templates = loaded
+	mu.Unlock()
+
+	log.Printf("[TEXTS] ✅ Загружено %d шаблонов текстов", len(loaded))
+	return nil
+}
+
+// readTemplate читает шаблон из каталога Dir, а если его там нет — из встроенных
+func readTemplate(dir, key string) (string, string, error) {
+	fileName := key + ".tmpl"
+	if dir != "" {
+		path := filepath.Join(dir, fileName)
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return string(data), path, nil
+		}
+		if !os.IsNotExist(err) {
+			return "", path, fmt.Errorf("ошибка чтения шаблона %s: %w", path, err)
+		}
+	}
+
+	data, err := defaultTemplates.ReadFile("templates/" + fileName)
+	if err != nil {
+		return "", "встроенный", fmt.Errorf("встроенный шаблон %s не найден: %w", key, err)
+	}
+	return string(data), "встроенный", nil
+}
+
+// Render заполняет текст name на языке lang; если шаблона на этом языке нет,
+// используется язык по умолчанию
+func Render(name, lang string, data Data) (string, error) {
+	mu.RLock()
+	tmpl, ok := templates[name+"_"+lang]
+	if !ok {
+		tmpl, ok = templates[name+"_"+i18n.DefaultLanguage]
+	}
+	mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("шаблон текста %s не загружен", name)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("ошибка заполнения шаблона %s: %w", name, err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
@@ -0,0 +1,153 @@
+package texts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"AIGenerator/internal/i18n"
+)
+
+// defaultData значения по умолчанию: тарифы и пробные генерации бота
+var defaultData = Data{
+	FreeGenerations:   3,
+	Packages:          []Package{{Count: 10, Price: 99}, {Count: 25, Price: 199}, {Count: 100, Price: 499}},
+	BlockedCategories: []string{"политика", "18+"},
+}
+
+// useDir загружает шаблоны с каталогом переопределений dir и возвращает встроенные после теста
+func useDir(t *testing.T, dir string) error {
+	t.Helper()
+	t.Cleanup(func() {
+		if err := Configure(Config{}); err != nil {
+			t.Errorf("встроенные шаблоны: %v", err)
+		}
+	})
+	return Configure(Config{Dir: dir})
+}
+
+// writeTemplate записывает шаблон key в каталог dir
+func writeTemplate(t *testing.T, dir, key, text string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, key+".tmpl"), []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultTemplates(t *testing.T) {
+	tests := []struct {
+		name, lang string
+		want       []string
+	}{
+		{Start, "ru", []string{"🎯 Для всех новых пользователей 3 бесплатных генераций!", "• Не обрабатываются темы: политика, 18+."}},
+		{Start, "en", []string{"🎯 Every new user gets 3 free generations!"}},
+		{Help, "ru", []string{"• 10 генераций - 99 руб\n• 25 генераций - 199 руб\n• 100 генераций - 499 руб",
+			"• Первые 3 генераций - бесплатно", "• Не обрабатываются темы: политика, 18+."}},
+		{Help, "en", []string{"• 10 generations - 99 RUB\n• 25 generations - 199 RUB\n• 100 generations - 499 RUB",
+			"• The first 3 generations are free"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"_"+tt.lang, func(t *testing.T) {
+			text, err := Render(tt.name, tt.lang, defaultData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(text, want) {
+					t.Errorf("нет %q в тексте:\n%s", want, text)
+				}
+			}
+			if strings.Contains(text, "{{") || strings.Contains(text, "<no value>") {
+				t.Errorf("незаполненный шаблон:\n%s", text)
+			}
+		})
+	}
+}
+
+func TestTemplatesWithoutOptionalData(t *testing.T) {
+	for _, name := range names {
+		for _, language := range i18n.Languages() {
+			// Без пробных генераций и правил модерации строки о них не выводятся
+			text, err := Render(name, language.Code, Data{Packages: defaultData.Packages})
+			if err != nil {
+				t.Fatalf("%s_%s: %v", name, language.Code, err)
+			}
+			for _, unwanted := range []string{"бесплатн", "free generations", "are free", "Не обрабатываются темы"} {
+				if strings.Contains(text, unwanted) {
+					t.Errorf("%s_%s: лишняя строка %q", name, language.Code, unwanted)
+				}
+			}
+		}
+	}
+}
+
+func TestRenderFallsBackToDefaultLanguage(t *testing.T) {
+	want, err := Render(Help, i18n.DefaultLanguage, defaultData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Render(Help, "de", defaultData); err != nil || got != want {
+		t.Errorf("текст на неизвестном языке: %v", err)
+	}
+	if _, err := Render("nosuchtext", "ru", defaultData); err == nil {
+		t.Error("неизвестный текст заполнен")
+	}
+}
+
+func TestOverrideDirAndReload(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "help_ru", "Тарифов: {{len .Packages}}")
+	if err := useDir(t, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := Render(Help, "ru", defaultData); got != "Тарифов: 3" {
+		t.Errorf("переопределенная справка: %q", got)
+	}
+	// Непереопределенные тексты берутся из встроенных
+	if got, _ := Render(Start, "ru", defaultData); !strings.HasPrefix(got, "🤖 AI Content Generator") {
+		t.Errorf("встроенное приветствие: %q", got)
+	}
+
+	writeTemplate(t, dir, "help_ru", "Бесплатно: {{.FreeGenerations}}")
+	if err := Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := Render(Help, "ru", defaultData); got != "Бесплатно: 3" {
+		t.Errorf("справка после перезагрузки: %q", got)
+	}
+}
+
+func TestUnknownVariableFailsLoudly(t *testing.T) {
+	tests := []struct {
+		name, text, wantErr string
+	}{
+		{"неизвестное значение", "Цена: {{.Price}}", "can't evaluate field Price"},
+		{"неизвестное значение в цикле", "{{range .Packages}}{{.Cost}}{{end}}", "can't evaluate field Cost"},
+		{"неизвестная функция", "{{upper .FreeGenerations}}", `function "upper" not defined`},
+		{"ошибка разбора", "{{if .FreeGenerations}}", "ошибка разбора шаблона start_ru"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := useDir(t, dir); err != nil {
+				t.Fatal(err)
+			}
+			before, _ := Render(Start, "ru", defaultData)
+
+			writeTemplate(t, dir, "start_ru", tt.text)
+			err := Reload()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("ошибка %v, ожидалось %q", err, tt.wantErr)
+			}
+			// Ошибочный шаблон не подменяет работающий
+			if after, _ := Render(Start, "ru", defaultData); after != before {
+				t.Errorf("после ошибки текст изменился: %q", after)
+			}
+			if err := Configure(Config{Dir: dir}); err == nil {
+				t.Error("Configure с ошибочным шаблоном прошел")
+			}
+		})
+	}
+}
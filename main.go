@@ -3,15 +3,28 @@ package main
 import (
 	"AIGenerator/internal/ai"
 	"AIGenerator/internal/bot"
+	"AIGenerator/internal/config"
 	"AIGenerator/internal/database"
+	"AIGenerator/internal/diagnostics"
+	"AIGenerator/internal/events"
+	"AIGenerator/internal/health"
+	"AIGenerator/internal/httpx"
+	"AIGenerator/internal/i18n"
+	"AIGenerator/internal/logging"
 	"AIGenerator/internal/news"
 	"AIGenerator/internal/payment"
+	"AIGenerator/internal/reporting"
+	"AIGenerator/internal/selftest"
+	"AIGenerator/internal/texts"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
@@ -19,15 +32,11 @@ import (
 )
 
 func main() {
-	// Настройка логирования
-	logFile, err := os.OpenFile("logs.txt", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
-	if err != nil {
-		fmt.Printf("❌ Ошибка создания лог-файла: %v\n", err)
-		os.Exit(1)
-	}
-	defer logFile.Close()
-	log.SetOutput(logFile)
+	os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
+}
 
+// runBot запускает бота и работает до сигнала завершения: команда run
+func runBot() {
 	// Консольный вывод процесса запуска
 	fmt.Println("=========================================")
 	fmt.Println("🚀 ЗАПУСК AI CONTENT GENERATOR")
@@ -39,9 +48,45 @@ func main() {
 		fmt.Println("⚠️  .env файл не найден, проверяю системные переменные")
 	}
 
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Printf("❌ ОШИБКА: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Настройка логирования: файл с ротацией, предупреждения и ошибки еще и в консоль
+	logFile, err := logging.OpenFile(cfg.Logging)
+	if err != nil {
+		fmt.Printf("❌ Ошибка создания лог-файла: %v\n", err)
+		os.Exit(1)
+	}
+	defer logFile.Close()
+	logging.Setup(logFile, os.Stderr, cfg.Logging)
+	logging.AddSecrets(cfg.Secrets()...)
+	httpx.Configure(cfg.HTTP)
+	if err := reporting.Configure(cfg.Reporting); err != nil {
+		fmt.Printf("⚠️  Отправка ошибок выключена: %v\n", err)
+	} else if reporting.Enabled() {
+		fmt.Printf("✅ Отправка ошибок включена (%s, от уровня %s)\n", cfg.Reporting.Environment, cfg.Reporting.MinLevel)
+	}
+	// Недостающие переводы не мешают работе: вместо них показывается русский текст
+	if err := i18n.Validate(); err != nil {
+		log.Printf("[I18N] ⚠️ Каталоги сообщений расходятся:\n%v", err)
+	}
+	// Шаблон, ссылающийся на неизвестное значение, сломал бы /start и /help
+	if err := texts.Configure(cfg.Texts); err != nil {
+		fmt.Printf("❌ ОШИБКА: шаблоны текстов: %v\n", err)
+		os.Exit(1)
+	}
+	if cfg.Bot.AdminChatID == 0 {
+		fmt.Println("⚠️  ADMIN_CHAT_ID не установлен, отзывы и оценки не будут отправляться")
+	} else {
+		fmt.Printf("✅ ADMIN_CHAT_ID: %d\n", cfg.Bot.AdminChatID)
+	}
+
 	// 2. Инициализация базы данных
 	fmt.Println("[2/7] Инициализация базы данных...")
-	db := database.NewDatabase("users.json")
+	db := database.NewDatabase(cfg.Database)
 	if err := db.Load(); err != nil {
 		fmt.Printf("⚠️  Ошибка загрузки базы: %v\n", err)
 		fmt.Println("📁 Создана новая база данных")
@@ -49,59 +94,54 @@ func main() {
 		fmt.Println("✅ База данных загружена")
 	}
 
-	// 3. Инициализация YandexGPT
-	fmt.Println("[3/7] Инициализация YandexGPT...")
-	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	yandexAPIKey := os.Getenv("YANDEX_GPT_API_KEY")
-	yandexFolderID := os.Getenv("YANDEX_FOLDER_ID")
-	adminChatIDStr := os.Getenv("ADMIN_CHAT_ID")
-
-	// Проверка обязательных переменных
-	if botToken == "" {
-		fmt.Println("❌ ОШИБКА: TELEGRAM_BOT_TOKEN не установлен")
-		fmt.Println("Добавьте в .env файл: TELEGRAM_BOT_TOKEN=ваш_токен_бота")
+	// 3. Инициализация AI
+	fmt.Printf("[3/7] Инициализация AI (%s)...\n", cfg.AI.Provider)
+	if err := ai.Configure(cfg.AI); err != nil {
+		fmt.Printf("❌ ОШИБКА: %v\n", err)
 		os.Exit(1)
 	}
 
-	if yandexAPIKey == "" || yandexFolderID == "" {
-		fmt.Println("❌ ОШИБКА: Переменные YandexGPT не установлены")
-		fmt.Println("Добавьте в .env файл:")
-		fmt.Println("YANDEX_GPT_API_KEY=ваш_api_ключ")
-		fmt.Println("YANDEX_FOLDER_ID=ваш_folder_id")
+	gptClient, err := ai.NewTextGenerator(cfg.AI)
+	if err != nil {
+		fmt.Printf("❌ ОШИБКА: Не удалось создать AI клиент: %v\n", err)
 		os.Exit(1)
 	}
+	fmt.Println("✅ AI клиент создан")
 
-	if adminChatIDStr == "" {
-		fmt.Println("⚠️  ADMIN_CHAT_ID не установлен, отзывы и оценки не будут отправляться")
-	}
-
-	// Преобразуем ADMIN_CHAT_ID в int64
-	var adminChatID int64 = 0
-	if adminChatIDStr != "" {
-		if id, err := strconv.ParseInt(adminChatIDStr, 10, 64); err == nil {
-			adminChatID = id
-			fmt.Printf("✅ ADMIN_CHAT_ID: %d\n", adminChatID)
-		} else {
-			fmt.Printf("⚠️  Неверный формат ADMIN_CHAT_ID: %s\n", adminChatIDStr)
-		}
-	}
-
-	gptClient, err := ai.NewYandexGPTClient()
+	// Иллюстрации YandexART доступны при наличии ключа Yandex Cloud
+	imageClient, err := ai.NewImageClient(cfg.AI)
 	if err != nil {
-		fmt.Printf("❌ ОШИБКА: Не удалось создать клиент YandexGPT: %v\n", err)
-		os.Exit(1)
+		fmt.Printf("⚠️  Генерация иллюстраций недоступна: %v\n", err)
+	} else {
+		fmt.Println("✅ Клиент YandexART создан")
 	}
-	fmt.Println("✅ YandexGPT клиент создан")
 
 	// 4. Инициализация новостного агрегатора
 	fmt.Println("[4/7] Инициализация новостного агрегатора...")
-	newsAggregator := news.NewNewsAggregator()
+	newsAggregator := news.NewNewsAggregator(cfg.News)
 	newsAggregator.AddDefaultSources()
+
+	// Семантический поиск включается моделью эмбеддингов EMBEDDING_MODEL
+	embedder, err := ai.NewEmbedder(cfg.AI)
+	if err != nil {
+		fmt.Printf("⚠️  Семантический поиск недоступен: %v\n", err)
+	} else if embedder != nil {
+		newsAggregator.SetEmbedder(embedder)
+		fmt.Println("✅ Семантический поиск включен")
+	}
+	newsCachePath := news.CacheFilePath(cfg.DataDir)
+	newsAggregator.LoadCache(newsCachePath)
+	newsAggregator.SetSourceWeights(db.GetSourceWeights())
 	fmt.Println("✅ Новостной агрегатор создан")
 
 	// 5. Инициализация платежной системы
 	fmt.Println("[5/7] Инициализация платежной системы ЮKassa...")
-	yooMoneyClient, err := payment.NewYooMoneyClient()
+	var yooMoneyClient *payment.YooMoneyClient
+	if cfg.PaymentEnabled() {
+		yooMoneyClient, err = payment.NewYooMoneyClient(cfg.Payment)
+	} else {
+		err = fmt.Errorf("YOOMONEY_SHOP_ID и YOOMONEY_SECRET_KEY не установлены")
+	}
 	if err != nil {
 		fmt.Printf("⚠️  ЮKassa недоступна: %v\n", err)
 		fmt.Println("💡 Функция покупки будет недоступна")
@@ -112,23 +152,95 @@ func main() {
 
 	// 6. Создание бота
 	fmt.Println("[6/7] Создание Telegram бота...")
-	telegramBot, err := bot.New(botToken, newsAggregator, gptClient, db, yooMoneyClient, adminChatID)
+	telegramBot, err := bot.New(cfg.Bot, newsAggregator, gptClient, imageClient, db, yooMoneyClient)
 	if err != nil {
 		fmt.Printf("❌ ОШИБКА: Не удалось создать бота: %v\n", err)
 		os.Exit(1)
 	}
 
+	// Журнал событий для статистики
+	eventLog, err := openEvents(cfg.Events, db)
+	if err != nil {
+		fmt.Printf("⚠️  Журнал событий недоступен, статистика считается по базе: %v\n", err)
+	} else {
+		telegramBot.SetEvents(eventLog)
+		fmt.Println("✅ Журнал событий открыт")
+	}
+
+	// Самопроверка зависимостей: при запуске и по /selftest
+	suite := newSelfTest(cfg.SelfTest, db, gptClient, yooMoneyClient, newsAggregator)
+	telegramBot.SetSelfTest(suite)
+	if cfg.SelfTest.Mode != selftest.ModeOff {
+		fmt.Println("🧪 Проверка внешних зависимостей...")
+		report := suite.Run(context.Background())
+		fmt.Println(report.Format())
+		if !report.OK() {
+			telegramBot.NotifyAdmin("⚠️ Бот запускается с ошибками\n\n" + report.Format())
+		}
+		if failed := report.CriticalFailed(); len(failed) > 0 && cfg.SelfTest.Mode == selftest.ModeStrict {
+			fmt.Printf("❌ ОШИБКА: не пройдены критичные проверки: %s (SELFTEST_MODE=strict)\n", strings.Join(failed, ", "))
+			os.Exit(1)
+		}
+	}
+
+	// Проверки состояния для /status и HTTP-сервера /healthz, /readyz
+	checker := newHealthChecker(cfg.Health, db, telegramBot, gptClient, yooMoneyClient, newsAggregator)
+	telegramBot.SetHealthChecker(checker)
+	var healthServer *http.Server
+	if cfg.Health.Port > 0 {
+		healthServer = health.NewServer(cfg.Health.Port, checker)
+		go func() {
+			if err := healthServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("[HEALTH] ❌ Ошибка HTTP-сервера: %v", err)
+			}
+		}()
+		fmt.Printf("✅ Проверки состояния: http://localhost:%d/healthz\n", cfg.Health.Port)
+	}
+
 	// 7. Настройка graceful shutdown
 	fmt.Println("[7/7] Настройка graceful shutdown...")
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Периодическое сохранение кэша новостей на диск
+	newsAggregator.StartCacheAutosave(ctx, newsCachePath, 5*time.Minute)
+
+	// Фоновый прогрев кэша новостей (опционально)
+	if interval := cfg.News.PrefetchInterval; interval > 0 {
+		newsAggregator.StartPrefetch(ctx, interval)
+		fmt.Printf("✅ Фоновое обновление новостей каждые %v\n", interval)
+	}
+
+	// Предупреждение о росте числа горутин и отладочные endpoints (DEBUG_ENDPOINTS=true)
+	diagnostics.WatchGoroutines(ctx, cfg.Debug.GoroutineWarn)
+	debugServer := diagnostics.NewServer(cfg.Debug)
+	if debugServer != nil {
+		diagnostics.Publish("news_cached_articles", func() any { return newsAggregator.CachedArticles() })
+		diagnostics.Publish("post_cache", func() any { return ai.PostCacheStats() })
+		diagnostics.Publish("database", func() any { return db.Counts() })
+		diagnostics.Publish("bot_panics", func() any { return telegramBot.Panics() })
+		diagnostics.Publish("generation_queue", func() any { return telegramBot.QueueStats() })
+		diagnostics.Publish("events_dropped", func() any { return eventLog.Dropped() })
+		diagnostics.Publish("telegram_update_age_seconds", func() any {
+			return time.Since(telegramBot.LastUpdateAt()).Seconds()
+		})
+
+		go func() {
+			if err := debugServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Printf("[DIAGNOSTICS] ❌ Ошибка отладочного сервера: %v", err)
+			}
+		}()
+		fmt.Printf("✅ Отладка: http://%s/debug/pprof/\n", cfg.Debug.Addr)
+	}
+
 	// Обработка сигналов завершения
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Запуск бота в отдельной горутине
+	botDone := make(chan struct{})
 	go func() {
+		defer close(botDone)
 		fmt.Println("=========================================")
 		fmt.Println("✅ ВСЕ СИСТЕМЫ ЗАПУЩЕНЫ УСПЕШНО!")
 		fmt.Println("✨ Ожидание команд...")
@@ -138,9 +250,147 @@ func main() {
 	}()
 
 	// Ожидание сигнала завершения
-	<-sigChan
-	fmt.Println("\n🔄 Получен сигнал завершения...")
+	select {
+	case <-sigChan:
+		fmt.Println("\n🔄 Получен сигнал завершения, жду завершения начатых запросов...")
+	case <-botDone:
+		fmt.Println("\n⚠️  Бот перестал получать обновления, завершаю работу...")
+	}
 	cancel()
-	time.Sleep(2 * time.Second)
+	newsAggregator.StopPrefetch()
+
+	// Start возвращается, когда начатые обработчики завершены и база сохранена
+	<-botDone
+
+	if err := newsAggregator.SaveCache(newsCachePath); err != nil {
+		log.Printf("[SHUTDOWN] ❌ Ошибка сохранения кэша новостей: %v", err)
+	}
+	if !reporting.Flush(5 * time.Second) {
+		log.Printf("[SHUTDOWN] ⚠️ Не все ошибки отправлены в сервис отчетов")
+	}
+	for _, server := range []*http.Server{healthServer, debugServer} {
+		if server == nil {
+			continue
+		}
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), time.Second)
+		server.Shutdown(shutdownCtx)
+		shutdownCancel()
+	}
 	fmt.Println("👋 Бот завершил работу")
 }
+
+// openEvents открывает журнал событий. При первом запуске в него переносится история
+// генераций, покупок и оценок из базы, чтобы статистика не начиналась с нуля.
+func openEvents(config events.Config, db *database.Database) (*events.Pipeline, error) {
+	pipeline := events.New(config)
+	if err := pipeline.Open(); err != nil {
+		return nil, err
+	}
+	if pipeline.Loaded() > 0 {
+		return pipeline, nil
+	}
+
+	generations, purchases, ratings := db.History()
+	history := make([]events.Event, 0, len(generations)+len(purchases)+len(ratings))
+	for _, generation := range generations {
+		outcome := generation.Outcome
+		if outcome == "" {
+			outcome = events.OutcomeSuccess
+		}
+		history = append(history, events.Event{Type: events.GenerationFinished, Time: generation.Timestamp,
+			UserID: generation.UserID, Topic: generation.Keywords, Outcome: outcome})
+	}
+	for _, purchase := range purchases {
+		if purchase.Status == "succeeded" {
+			history = append(history, events.Event{Type: events.PaymentSucceeded, Time: purchase.CreatedAt,
+				UserID: purchase.UserID, PaymentID: purchase.PaymentID, Package: purchase.PackageType, Amount: purchase.Price})
+		}
+	}
+	for _, rating := range ratings {
+		history = append(history, events.Event{Type: events.RatingGiven, Time: rating.Timestamp,
+			UserID: rating.UserID, Topic: rating.Topic, Rating: rating.Rating})
+	}
+	slices.SortStableFunc(history, func(a, b events.Event) int { return a.Time.Compare(b.Time) })
+
+	if err := pipeline.Import(history); err != nil {
+		pipeline.Close(time.Second)
+		return nil, fmt.Errorf("ошибка переноса истории из базы: %w", err)
+	}
+	if len(history) > 0 {
+		log.Printf("[EVENTS] Перенесено событий из базы: %d", len(history))
+	}
+	return pipeline, nil
+}
+
+// selfTestSources сколько случайных источников новостей загружает самопроверка
+const selfTestSources = 2
+
+// newSelfTest собирает самопроверку: модель, ЮKassa, источники новостей и база.
+// Без модели, новостей и записи на диск бот бесполезен, поэтому эти проверки критичные.
+func newSelfTest(config selftest.Config, db *database.Database, gptClient ai.TextGenerator,
+	yooMoney *payment.YooMoneyClient, newsAggregator *news.NewsAggregator) *selftest.Suite {
+	suite := selftest.NewSuite(config.Timeout)
+
+	suite.Add("ai", true, func(ctx context.Context) (string, error) {
+		return "", gptClient.Ping(ctx)
+	})
+	suite.Add("yookassa", false, func(ctx context.Context) (string, error) {
+		if yooMoney == nil {
+			return "не настроена", nil
+		}
+		return "", yooMoney.CheckCredentials(ctx)
+	})
+	suite.Add("news", true, func(ctx context.Context) (string, error) {
+		return newsAggregator.CheckRandomSources(ctx, selfTestSources)
+	})
+	suite.Add("database", true, func(ctx context.Context) (string, error) {
+		return "", db.CheckRoundTrip()
+	})
+
+	return suite
+}
+
+// newHealthChecker собирает проверки: liveness — база доступна для записи и бот получает
+// обновления, readiness — модель, ЮKassa и источники новостей. Обращения к внешним
+// сервисам кэшируются на config.ProbeInterval.
+func newHealthChecker(config health.Config, db *database.Database, telegramBot *bot.Bot, gptClient ai.TextGenerator,
+	yooMoney *payment.YooMoneyClient, newsAggregator *news.NewsAggregator) *health.Checker {
+	checker := health.NewChecker()
+
+	checker.AddLiveness("database", func(ctx context.Context) (string, error) {
+		return "", db.CheckWritable()
+	})
+	checker.AddLiveness("telegram", func(ctx context.Context) (string, error) {
+		age := time.Since(telegramBot.LastUpdateAt()).Round(time.Second)
+		detail := fmt.Sprintf("последнее обновление %v назад", age)
+		if config.MaxUpdateAge > 0 && age > config.MaxUpdateAge {
+			return detail, fmt.Errorf("нет обновлений %v (допустимо %v)", age, config.MaxUpdateAge)
+		}
+		return detail, nil
+	})
+
+	checker.AddReadiness("ai", health.Cached(func(ctx context.Context) (string, error) {
+		if breaker := ai.Breaker(); breaker.State == ai.BreakerOpen {
+			return "", fmt.Errorf("автомат AI: %s", breaker.State)
+		}
+		return "", gptClient.Ping(ctx)
+	}, config.ProbeInterval))
+
+	checker.AddReadiness("yookassa", health.Cached(func(ctx context.Context) (string, error) {
+		if yooMoney == nil {
+			return "не настроена", nil
+		}
+		return "", yooMoney.CheckCredentials(ctx)
+	}, config.ProbeInterval))
+
+	checker.AddReadiness("news", func(ctx context.Context) (string, error) {
+		healthy, total := newsAggregator.HealthySources()
+		detail := fmt.Sprintf("доступно %d из %d источников", healthy, total)
+		if healthy == 0 {
+			return detail, fmt.Errorf("все источники в карантине")
+		}
+		return detail, nil
+	})
+
+	return checker
+}